@@ -7,6 +7,7 @@ import (
 	. "github.com/go-git/go-git/v6/_examples"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/promisor"
 )
 
 // Updates server info (info/refs & objects/info/packs)
@@ -23,8 +24,14 @@ func main() {
 	CheckIfError(err)
 	defer func() { _ = r.Close() }()
 
-	// Update the server info files & save them to the file-system.
-	fs := r.Storer.(*filesystem.Storage).Filesystem()
+	// Update the server info files & save them to the file-system. The
+	// storage of a partial clone is a *promisor.Storage, wrapping the
+	// *filesystem.Storage.
+	st := r.Storer
+	if ps, ok := st.(*promisor.Storage); ok {
+		st = ps.Unwrap()
+	}
+	fs := st.(*filesystem.Storage).Filesystem()
 	err = transport.UpdateServerInfo(r.Storer, fs)
 	CheckIfError(err)
 }
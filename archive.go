@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 
+	"github.com/go-git/go-git/v6/internal/archive"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 )
 
@@ -65,3 +67,44 @@ func ArchiveRemoteContext(ctx context.Context, url string, o *ArchiveOptions) (i
 		Progress: o.Progress,
 	})
 }
+
+// archiveAttributes resolves the export-ignore and export-subst attributes
+// of the paths of an archive.
+type archiveAttributes struct {
+	a *attributes
+}
+
+// archiveAttributes returns the attributes of the paths archived from t,
+// read from t and $GIT_DIR/info/attributes, or if worktree is set, from the
+// worktree and then the index instead of t, as git does.
+func (r *Repository) archiveAttributes(t *object.Tree, worktree bool) (archive.Attributes, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !worktree {
+		return archiveAttributes{r.newAttributes(cfg, treeAttributesSource(t))}, nil
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return archiveAttributes{w.checkinAttributes(cfg, idx)}, nil
+}
+
+func (a archiveAttributes) ExportIgnore(path string) bool {
+	attr, ok := a.a.match(path, "export-ignore")["export-ignore"]
+	return ok && attr.IsSet()
+}
+
+func (a archiveAttributes) ExportSubst(path string) bool {
+	attr, ok := a.a.match(path, "export-subst")["export-subst"]
+	return ok && attr.IsSet()
+}
@@ -3,7 +3,8 @@
 //
 // Use [Backend.Serve] or [Backend.ServeConn] for stream-based transports
 // (TCP, SSH, pipes). Use [Backend.ServeHTTP] for HTTP (both smart and dumb
-// protocols).
+// protocols). [SSHServer] serves a Backend over SSH and [Daemon] over the
+// git:// protocol, as git-daemon does.
 package backend
 
 import (
@@ -13,8 +14,10 @@ import (
 	"log"
 	"net/url"
 
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage"
 )
 
 // Request describes a Git server-side operation.
@@ -55,6 +58,15 @@ type Backend struct {
 	// Prefix is an HTTP path prefix stripped before route matching.
 	// Only used by [ServeHTTP].
 	Prefix string
+
+	// Hooks are the server-side callbacks run by receive-pack, whatever the
+	// transport. The zero value installs none.
+	Hooks transport.ReceivePackHooks
+
+	// PushCertVerifier verifies the signatures of the push certificates
+	// received by receive-pack, whose outcome is reported to the Hooks. If
+	// nil, signed pushes are reported as unverified.
+	PushCertVerifier object.SignatureVerifier
 }
 
 // New creates a Backend with the given loader.
@@ -88,6 +100,12 @@ func (b *Backend) Serve(ctx context.Context, r io.ReadCloser, w io.WriteCloser,
 	if err != nil {
 		return err
 	}
+
+	return b.serve(ctx, st, r, w, req)
+}
+
+// serve runs the server command of req on st, and closes st.
+func (b *Backend) serve(ctx context.Context, st storage.Storer, r io.ReadCloser, w io.WriteCloser, req *Request) error {
 	defer func() {
 		if closer, ok := st.(io.Closer); ok {
 			_ = closer.Close()
@@ -103,9 +121,11 @@ func (b *Backend) Serve(ctx context.Context, r io.ReadCloser, w io.WriteCloser,
 		})
 	case transport.ReceivePackService:
 		return transport.ReceivePack(ctx, st, r, w, &transport.ReceivePackRequest{
-			GitProtocol:   req.GitProtocol,
-			AdvertiseRefs: req.AdvertiseRefs,
-			StatelessRPC:  req.StatelessRPC,
+			GitProtocol:      req.GitProtocol,
+			AdvertiseRefs:    req.AdvertiseRefs,
+			StatelessRPC:     req.StatelessRPC,
+			Hooks:            b.Hooks,
+			PushCertVerifier: b.PushCertVerifier,
 		})
 	case transport.UploadArchiveService:
 		return transport.UploadArchive(ctx, st, r, w, &transport.UploadArchiveRequest{})
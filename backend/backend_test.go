@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+type stubVerifier struct{ payload *object.SignedPayload }
+
+func (v *stubVerifier) Verify(_ context.Context, p *object.SignedPayload) (*object.VerifiedSignature, error) {
+	v.payload = p
+	return &object.VerifiedSignature{Signer: "A U Thor <author@example.com>"}, nil
+}
+
+func TestBackendPushCertVerifier(t *testing.T) {
+	t.Parallel()
+
+	ref := plumbing.ReferenceName("refs/heads/main")
+	hash := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	st := memory.NewStorage()
+	require.NoError(t, st.SetReference(plumbing.NewHashReference(ref, hash)))
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.Receive.CertNonceSeed = "seed"
+	require.NoError(t, st.SetConfig(cfg))
+
+	var (
+		info     *transport.PreReceiveInfo
+		verifier stubVerifier
+	)
+	b := New(transport.MapLoader{"/repo.git": st})
+	b.PushCertVerifier = &verifier
+	b.Hooks.PreReceive = func(_ context.Context, i *transport.PreReceiveInfo) error {
+		info = i
+		return nil
+	}
+
+	cert := &packp.PushCertificate{
+		Pusher:    "A U Thor <author@example.com> 1700000000 +0000",
+		Nonce:     "1700000000-deadbeef",
+		Commands:  []*packp.Command{{Name: ref, Old: hash, New: plumbing.ZeroHash}},
+		Signature: []byte("-----BEGIN PGP SIGNATURE-----\n\nstub\n-----END PGP SIGNATURE-----\n"),
+	}
+	upreq := &packp.UpdateRequests{Commands: cert.Commands, Certificate: cert}
+	upreq.Capabilities.Add(capability.ReportStatus)
+	var in, out bytes.Buffer
+	require.NoError(t, upreq.Encode(&in))
+
+	err = b.Serve(context.Background(), io.NopCloser(&in), ioutil.WriteNopCloser(&out), &Request{
+		URL:          &url.URL{Path: "/repo.git"},
+		Service:      transport.ReceivePackService,
+		StatelessRPC: true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "ok refs/heads/main")
+
+	require.NotNil(t, info)
+	require.NotNil(t, info.PushCert)
+	assert.NoError(t, info.PushCert.SignatureErr)
+	require.NotNil(t, verifier.payload)
+	assert.Equal(t, "author@example.com", verifier.payload.Signer.Email)
+}
@@ -0,0 +1,534 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// exportOKFile is the file marking a repository as exported by a [Daemon].
+const exportOKFile = "git-daemon-export-ok"
+
+// Daemon errors.
+var (
+	// ErrDaemonClosed is returned by [Daemon.Serve] once the daemon is
+	// closed.
+	ErrDaemonClosed = errors.New("daemon: server closed")
+	// ErrServiceNotEnabled is returned for a request of a service the
+	// daemon does not enable.
+	ErrServiceNotEnabled = errors.New("service not enabled")
+	// ErrNotExported is returned for a request of a repository the daemon
+	// does not export.
+	ErrNotExported = errors.New("repository not exported")
+
+	errTooManyConnections = errors.New("daemon: too many connections")
+)
+
+// Daemon serves a [Backend] over the git:// protocol, as git-daemon does.
+//
+// As git-daemon, it only serves the repositories containing a
+// git-daemon-export-ok file unless ExportAll is set, and only enables
+// upload-pack unless told otherwise. Clients asking for protocol v2 through
+// the extra parameters of their request are served with it.
+type Daemon struct {
+	// Backend serves the requests. If nil, a Backend using
+	// [transport.DefaultLoader] is used.
+	Backend *Backend
+
+	// ExportAll serves every repository, whether or not it contains a
+	// git-daemon-export-ok file. Repositories whose storage is not on a
+	// filesystem are only served with ExportAll. Corresponds to
+	// git-daemon's --export-all.
+	ExportAll bool
+
+	// BasePath is prepended to the path of every request, which must then be
+	// absolute. Corresponds to git-daemon's --base-path.
+	BasePath string
+
+	// InterpolatedPath, when set, is the template the path of a request
+	// naming its host is built from, to serve virtual hosts. %H is replaced
+	// by the host name, %CH by the canonical host name, which is the host
+	// name as no lookup is made, %IP by the address the daemon accepted the
+	// connection on, %P by the port, %D by the path of the request and %% by
+	// a %. Corresponds to git-daemon's --interpolated-path.
+	InterpolatedPath string
+
+	// DisableUploadPack refuses git-upload-pack requests, that is fetches
+	// and clones.
+	DisableUploadPack bool
+	// EnableUploadArchive serves git-upload-archive requests.
+	EnableUploadArchive bool
+	// EnableReceivePack serves git-receive-pack requests, that is pushes,
+	// which are not authenticated.
+	EnableReceivePack bool
+
+	// InformativeErrors tells clients why their request is refused, such
+	// as the repository not being found or not exported, instead of a
+	// generic error. Corresponds to git-daemon's --informative-errors.
+	InformativeErrors bool
+
+	// Authorize, when set, decides whether to serve req, whose URL path is
+	// the repository one. Returning an error refuses the request, with the
+	// error as message when InformativeErrors is set. Corresponds to
+	// git-daemon's --access-hook.
+	Authorize func(ctx context.Context, conn net.Conn, req *Request) error
+
+	// Timeout is the idle timeout for each connection. If a connection
+	// has no read or write activity for this duration, it is closed.
+	// If zero, there is no idle timeout. Corresponds to git-daemon's
+	// --timeout.
+	Timeout time.Duration
+
+	// InitTimeout is the timeout for the initial protocol handshake
+	// (reading the GitProtoRequest). If zero, there is no handshake
+	// timeout. Corresponds to git-daemon's --init-timeout.
+	InitTimeout time.Duration
+
+	// MaxTimeout is the absolute maximum duration a connection is
+	// allowed to live, regardless of activity. If zero, there is no
+	// maximum. This is useful to prevent long-lived connections from
+	// consuming server resources indefinitely.
+	MaxTimeout time.Duration
+
+	// MaxConnections is the maximum number of simultaneous connections.
+	// If zero, there is no limit. Corresponds to git-daemon's
+	// --max-connections. Connections beyond the limit are immediately
+	// closed.
+	MaxConnections int
+
+	// ErrorLog is used to log errors. If nil, errors are not logged.
+	ErrorLog *log.Logger
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]context.CancelFunc
+	wg        sync.WaitGroup
+	closed    bool
+}
+
+// Serve accepts connections on ln and serves each in its own goroutine. It
+// returns ErrDaemonClosed once Close is called.
+func (d *Daemon) Serve(ln net.Listener) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrDaemonClosed
+	}
+	if d.listeners == nil {
+		d.listeners = make(map[net.Listener]struct{})
+	}
+	d.listeners[ln] = struct{}{}
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.listeners, ln)
+		d.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if d.isClosed() {
+				return ErrDaemonClosed
+			}
+			return err
+		}
+
+		d.wg.Go(func() {
+			if err := d.ServeConn(context.Background(), conn); err != nil {
+				d.logf("daemon: %s: %v", conn.RemoteAddr(), err)
+			}
+		})
+	}
+}
+
+// ServeConn serves the request of conn, closing it once done or when ctx
+// is done.
+func (d *Daemon) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer func() { _ = conn.Close() }()
+
+	ctx, ok := d.addConn(ctx, conn)
+	if !ok {
+		return errTooManyConnections
+	}
+	defer d.removeConn(conn)
+
+	now := time.Now()
+	sc := &daemonConn{
+		Conn:        conn,
+		idleTimeout: d.Timeout,
+		// When the connection times out, cancel its context so the
+		// backend can abort gracefully.
+		closeCanceler: func() { d.cancelConn(conn) },
+	}
+	if d.MaxTimeout > 0 {
+		sc.maxDeadline = now.Add(d.MaxTimeout)
+	}
+	if d.InitTimeout > 0 {
+		sc.initDeadline = now.Add(d.InitTimeout)
+	}
+	sc.updateDeadline()
+
+	br := bufio.NewReader(sc)
+	var proto packp.GitProtoRequest
+	if err := proto.Decode(br); err != nil {
+		return fmt.Errorf("decode request: %w", err)
+	}
+
+	// Handshake complete — clear init deadline so only idle + max
+	// remain in effect.
+	sc.clearInitDeadline()
+
+	st, req, err := d.accept(ctx, conn, &proto)
+	if err != nil {
+		msg := "access denied or repository not exported"
+		if d.InformativeErrors {
+			msg = err.Error()
+		}
+		_, _ = pktline.WriteError(sc, fmt.Errorf("%s: %s", msg, proto.Pathname))
+		return fmt.Errorf("%s %s: %w", proto.RequestCommand, proto.Pathname, err)
+	}
+
+	if err := d.backend().serve(ctx, st, io.NopCloser(br), ioutil.WriteNopCloser(sc), req); err != nil {
+		return fmt.Errorf("serve %s %s: %w", req.Service, req.URL.Path, err)
+	}
+
+	return nil
+}
+
+// Close closes the listeners and the connections of the daemon.
+func (d *Daemon) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	var err error
+	for ln := range d.listeners {
+		if cerr := ln.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for conn, cancel := range d.conns {
+		_ = conn.Close()
+		cancel()
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	return err
+}
+
+// accept checks proto is a request the daemon serves, and returns the
+// storage of its repository and the request to serve.
+func (d *Daemon) accept(ctx context.Context, conn net.Conn, proto *packp.GitProtoRequest) (storage.Storer, *Request, error) {
+	req := RequestFromProto(proto)
+	if !d.enabled(req.Service) {
+		return nil, nil, ErrServiceNotEnabled
+	}
+
+	path, err := d.repositoryPath(proto, conn.LocalAddr())
+	if err != nil {
+		d.logf("daemon: %v", err)
+		return nil, nil, transport.ErrRepositoryNotFound
+	}
+	req.URL.Path = path
+
+	b := d.backend()
+	loader := b.Loader
+	if loader == nil {
+		loader = transport.DefaultLoader
+	}
+
+	st, err := loader.Load(req.URL)
+	if err != nil {
+		if !errors.Is(err, transport.ErrRepositoryNotFound) {
+			d.logf("daemon: load %s: %v", path, err)
+		}
+		return nil, nil, transport.ErrRepositoryNotFound
+	}
+
+	if !d.ExportAll && !exportOK(st) {
+		err = ErrNotExported
+	} else if d.Authorize != nil {
+		err = d.Authorize(ctx, conn, req)
+	}
+	if err != nil {
+		if closer, ok := st.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, nil, err
+	}
+
+	return st, req, nil
+}
+
+// enabled returns whether the daemon serves service.
+func (d *Daemon) enabled(service string) bool {
+	switch service {
+	case transport.UploadPackService:
+		return !d.DisableUploadPack
+	case transport.UploadArchiveService:
+		return d.EnableUploadArchive
+	case transport.ReceivePackService:
+		return d.EnableReceivePack
+	default:
+		return false
+	}
+}
+
+// repositoryPath returns the path of the repository proto asks for, built
+// from InterpolatedPath or BasePath when set.
+func (d *Daemon) repositoryPath(proto *packp.GitProtoRequest, local net.Addr) (string, error) {
+	path := proto.Pathname
+	if (d.BasePath != "" || d.InterpolatedPath != "") && !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("%q: non-absolute path denied", path)
+	}
+	if slices.Contains(strings.Split(path, "/"), "..") {
+		return "", fmt.Errorf("%q: path outside of the repositories denied", path)
+	}
+
+	switch {
+	case d.InterpolatedPath != "" && proto.Host != "":
+		return interpolatePath(d.InterpolatedPath, proto.Host, local, path), nil
+	case d.BasePath != "":
+		return strings.TrimSuffix(d.BasePath, "/") + path, nil
+	default:
+		return path, nil
+	}
+}
+
+func (d *Daemon) backend() *Backend {
+	if d.Backend == nil {
+		return New(nil)
+	}
+	return d.Backend
+}
+
+func (d *Daemon) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// addConn registers conn with a context derived from ctx. It returns false
+// if the daemon is closed or conn exceeds MaxConnections.
+func (d *Daemon) addConn(ctx context.Context, conn net.Conn) (context.Context, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || (d.MaxConnections > 0 && len(d.conns) >= d.MaxConnections) {
+		return nil, false
+	}
+	if d.conns == nil {
+		d.conns = make(map[net.Conn]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	d.conns[conn] = cancel
+	return ctx, true
+}
+
+// removeConn unregisters conn, cancelling its context.
+func (d *Daemon) removeConn(conn net.Conn) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cancel, ok := d.conns[conn]; ok {
+		cancel()
+		delete(d.conns, conn)
+	}
+}
+
+// cancelConn cancels the context of conn if still registered.
+func (d *Daemon) cancelConn(conn net.Conn) {
+	d.mu.Lock()
+	cancel, ok := d.conns[conn]
+	d.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (d *Daemon) logf(format string, v ...any) {
+	if d.ErrorLog != nil {
+		d.ErrorLog.Printf(format, v...)
+	}
+}
+
+// exportOK returns whether the repository of st contains a
+// git-daemon-export-ok file.
+func exportOK(st storage.Storer) bool {
+	fss, ok := st.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return false
+	}
+	_, err := fss.Filesystem().Stat(exportOKFile)
+	return err == nil
+}
+
+// interpolatePath expands the placeholders of tmpl for a request of path
+// naming host, accepted on local.
+func interpolatePath(tmpl, host string, local net.Addr, path string) string {
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	hostname = sanitizeHost(hostname)
+	port = strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, port)
+
+	var ip string
+	if addr, ok := local.(*net.TCPAddr); ok {
+		ip = addr.IP.String()
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+
+		switch rest := tmpl[i+1:]; {
+		case strings.HasPrefix(rest, "CH"):
+			b.WriteString(hostname)
+			i += 2
+		case strings.HasPrefix(rest, "IP"):
+			b.WriteString(ip)
+			i += 2
+		case strings.HasPrefix(rest, "H"):
+			b.WriteString(hostname)
+			i++
+		case strings.HasPrefix(rest, "P"):
+			b.WriteString(port)
+			i++
+		case strings.HasPrefix(rest, "D"):
+			b.WriteString(path)
+			i++
+		case strings.HasPrefix(rest, "%"):
+			b.WriteByte('%')
+			i++
+		default:
+			b.WriteByte('%')
+		}
+	}
+
+	return b.String()
+}
+
+// sanitizeHost lowercases host and drops its path separators and leading
+// dots, as git-daemon does, so it cannot point outside of the interpolated
+// path.
+func sanitizeHost(host string) string {
+	host = strings.ToLower(strings.ReplaceAll(host, "/", ""))
+	return strings.TrimLeft(host, ".")
+}
+
+// daemonConn wraps a net.Conn with deadline management, inspired by
+// gliderlabs/ssh. Every Read and Write resets the idle deadline.
+// Three deadlines are computed:
+//
+//   - initDeadline: absolute deadline for the initial handshake phase
+//     (reading the GitProtoRequest). Fixed at connection accept time
+//     so that a slow client trickling bytes cannot keep extending it.
+//     Cleared once the handshake completes.
+//   - idleTimeout: resets on each Read/Write. If no I/O occurs
+//     within this duration, the connection times out.
+//   - maxDeadline: absolute deadline for the connection lifetime,
+//     set once when the connection is accepted.
+//
+// When a timeout net.Error is returned from Read or Write, the
+// connection's context is cancelled via closeCanceler so the
+// backend handler can abort gracefully.
+type daemonConn struct {
+	net.Conn
+
+	idleTimeout   time.Duration
+	initDeadline  time.Time
+	maxDeadline   time.Time
+	initCleared   atomic.Bool
+	closeCanceler func()
+}
+
+func (c *daemonConn) Read(b []byte) (n int, err error) {
+	if c.idleTimeout > 0 {
+		c.updateDeadline()
+	}
+	n, err = c.Conn.Read(b)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && c.closeCanceler != nil {
+		c.closeCanceler()
+	}
+	return n, err
+}
+
+func (c *daemonConn) Write(p []byte) (n int, err error) {
+	if c.idleTimeout > 0 {
+		c.updateDeadline()
+	}
+	n, err = c.Conn.Write(p)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && c.closeCanceler != nil {
+		c.closeCanceler()
+	}
+	return n, err
+}
+
+func (c *daemonConn) Close() (err error) {
+	err = c.Conn.Close()
+	if c.closeCanceler != nil {
+		c.closeCanceler()
+	}
+	return err
+}
+
+// clearInitDeadline clears the handshake deadline so only idle +
+// max remain in effect.
+func (c *daemonConn) clearInitDeadline() {
+	c.initCleared.Store(true)
+	if c.idleTimeout > 0 || !c.maxDeadline.IsZero() {
+		c.updateDeadline()
+	}
+}
+
+// updateDeadline recomputes and sets the connection deadline from the
+// three configured timeouts. The earliest non-zero deadline wins.
+func (c *daemonConn) updateDeadline() {
+	var deadline time.Time
+
+	if !c.maxDeadline.IsZero() {
+		deadline = c.maxDeadline
+	}
+
+	if !c.initCleared.Load() && !c.initDeadline.IsZero() {
+		if deadline.IsZero() || c.initDeadline.Before(deadline) {
+			deadline = c.initDeadline
+		}
+	}
+
+	if c.idleTimeout > 0 {
+		idleDeadline := time.Now().Add(c.idleTimeout)
+		if deadline.IsZero() || idleDeadline.Before(deadline) {
+			deadline = idleDeadline
+		}
+	}
+
+	_ = c.SetDeadline(deadline)
+}
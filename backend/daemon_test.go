@@ -0,0 +1,243 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/osfs"
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// newDaemonRepository extracts the basic fixture to base/path.
+func newDaemonRepository(t *testing.T, base, path string) string {
+	t.Helper()
+	dir := filepath.Join(base, path)
+	_, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(func() string { return dir }))
+	require.NoError(t, err)
+	return dir
+}
+
+// startDaemon serves d on a local port and returns its address.
+func startDaemon(t *testing.T, d *Daemon) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- d.Serve(ln) }()
+	t.Cleanup(func() {
+		require.NoError(t, d.Close())
+		require.ErrorIs(t, <-done, ErrDaemonClosed)
+	})
+
+	return ln.Addr().String()
+}
+
+// daemonRequest sends proto to the daemon at addr and returns the first
+// pkt-line of its answer.
+func daemonRequest(t *testing.T, addr string, proto *packp.GitProtoRequest) (string, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.NoError(t, proto.Encode(conn))
+	_, p, err := pktline.ReadLine(bufio.NewReader(conn))
+	return string(p), err
+}
+
+func TestDaemonExport(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	repo := newDaemonRepository(t, base, "repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend: New(transport.NewFilesystemLoader(osfs.New(base), false)),
+	})
+	proto := &packp.GitProtoRequest{RequestCommand: transport.UploadPackService, Pathname: "/repo.git"}
+
+	_, err := daemonRequest(t, addr, proto)
+	var errLine *pktline.ErrorLine
+	require.ErrorAs(t, err, &errLine)
+	assert.Equal(t, "access denied or repository not exported: /repo.git", errLine.Text)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, exportOKFile), nil, 0o644))
+	line, err := daemonRequest(t, addr, proto)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5 HEAD\x00"), line)
+}
+
+func TestDaemonInformativeErrors(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend:           New(transport.NewFilesystemLoader(osfs.New(base), false)),
+		InformativeErrors: true,
+	})
+
+	for _, tc := range []struct {
+		command, path, expected string
+	}{
+		{transport.UploadPackService, "/repo.git", "repository not exported: /repo.git"},
+		{transport.UploadPackService, "/missing.git", "repository not found: /missing.git"},
+		{transport.ReceivePackService, "/repo.git", "service not enabled: /repo.git"},
+		{transport.UploadArchiveService, "/repo.git", "service not enabled: /repo.git"},
+		{transport.UploadPackService, "/../repo.git", "repository not found: /../repo.git"},
+	} {
+		_, err := daemonRequest(t, addr, &packp.GitProtoRequest{RequestCommand: tc.command, Pathname: tc.path})
+		var errLine *pktline.ErrorLine
+		require.ErrorAs(t, err, &errLine, tc.path)
+		assert.Equal(t, tc.expected, errLine.Text)
+	}
+}
+
+func TestDaemonServices(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend:           New(transport.NewFilesystemLoader(osfs.New(base), false)),
+		ExportAll:         true,
+		DisableUploadPack: true,
+		EnableReceivePack: true,
+	})
+
+	_, err := daemonRequest(t, addr, &packp.GitProtoRequest{RequestCommand: transport.UploadPackService, Pathname: "/repo.git"})
+	var errLine *pktline.ErrorLine
+	require.ErrorAs(t, err, &errLine)
+
+	line, err := daemonRequest(t, addr, &packp.GitProtoRequest{RequestCommand: transport.ReceivePackService, Pathname: "/repo.git"})
+	require.NoError(t, err)
+	assert.Contains(t, line, "refs/heads/")
+}
+
+func TestDaemonBasePath(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend:           New(nil),
+		ExportAll:         true,
+		BasePath:          base,
+		InformativeErrors: true,
+	})
+
+	line, err := daemonRequest(t, addr, &packp.GitProtoRequest{RequestCommand: transport.UploadPackService, Pathname: "/repo.git"})
+	require.NoError(t, err)
+	assert.Contains(t, line, "HEAD")
+
+	_, err = daemonRequest(t, addr, &packp.GitProtoRequest{RequestCommand: transport.UploadPackService, Pathname: "repo.git"})
+	var errLine *pktline.ErrorLine
+	require.ErrorAs(t, err, &errLine)
+	assert.Equal(t, "repository not found: repo.git", errLine.Text)
+}
+
+func TestDaemonInterpolatedPath(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "example.com/repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend:          New(transport.NewFilesystemLoader(osfs.New(base), false)),
+		ExportAll:        true,
+		BasePath:         "/elsewhere",
+		InterpolatedPath: "/%H%D",
+	})
+
+	line, err := daemonRequest(t, addr, &packp.GitProtoRequest{
+		RequestCommand: transport.UploadPackService,
+		Pathname:       "/repo.git",
+		Host:           "Example.COM:9418",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, line, "HEAD")
+}
+
+func TestDaemonProtocolV2(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "repo.git")
+	addr := startDaemon(t, &Daemon{
+		Backend:   New(transport.NewFilesystemLoader(osfs.New(base), false)),
+		ExportAll: true,
+	})
+
+	line, err := daemonRequest(t, addr, &packp.GitProtoRequest{
+		RequestCommand: transport.UploadPackService,
+		Pathname:       "/repo.git",
+		Host:           "localhost",
+		ExtraParams:    []string{"version=2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "version 2\n", line)
+}
+
+func TestDaemonAuthorize(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	newDaemonRepository(t, base, "repo.git")
+
+	var authorized *Request
+	addr := startDaemon(t, &Daemon{
+		Backend:           New(transport.NewFilesystemLoader(osfs.New(base), false)),
+		ExportAll:         true,
+		InformativeErrors: true,
+		Authorize: func(_ context.Context, _ net.Conn, req *Request) error {
+			authorized = req
+			return errors.New("read-only mirror")
+		},
+	})
+
+	_, err := daemonRequest(t, addr, &packp.GitProtoRequest{
+		RequestCommand: transport.UploadPackService,
+		Pathname:       "/repo.git",
+		Host:           "example.com",
+	})
+	var errLine *pktline.ErrorLine
+	require.ErrorAs(t, err, &errLine)
+	assert.Equal(t, "read-only mirror: /repo.git", errLine.Text)
+
+	require.NotNil(t, authorized)
+	assert.Equal(t, transport.UploadPackService, authorized.Service)
+	assert.Equal(t, "example.com", authorized.URL.Host)
+	assert.Equal(t, "/repo.git", authorized.URL.Path)
+}
+
+func TestInterpolatePath(t *testing.T) {
+	t.Parallel()
+
+	local := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 9418}
+	for tmpl, expected := range map[string]string{
+		"/srv/%H%D":           "/srv/example.com/repo.git",
+		"/srv/%CH/%P%D":       "/srv/example.com/9418/repo.git",
+		"/srv/%IP%D":          "/srv/192.0.2.1/repo.git",
+		"/srv/100%%/%D":       "/srv/100%//repo.git",
+		"/srv/%X%D":           "/srv/%X/repo.git",
+		"/srv/%":              "/srv/%",
+		"/srv/%H/%D/%H/%P/%%": "/srv/example.com//repo.git/example.com/9418/%",
+	} {
+		assert.Equal(t, expected, interpolatePath(tmpl, "Example.COM:9418", local, "/repo.git"), tmpl)
+	}
+
+	assert.Equal(t, "/srv/etc/repo.git", interpolatePath("/srv/%H%D", "../etc", local, "/repo.git"))
+}
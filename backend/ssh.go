@@ -0,0 +1,353 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// SSH server errors.
+var (
+	// ErrSSHServerClosed is returned by [SSHServer.Serve] once the server is
+	// closed.
+	ErrSSHServerClosed = errors.New("ssh: server closed")
+	// ErrNoHostKeys is returned when an [SSHServer] has no host key.
+	ErrNoHostKeys = errors.New("ssh: no host keys")
+	// ErrNoPublicKeyCallback is returned to clients of an [SSHServer]
+	// without a PublicKeyCallback, which refuses every key.
+	ErrNoPublicKeyCallback = errors.New("ssh: no public key callback")
+	// ErrInvalidCommand is returned for an exec request that is not a git
+	// command.
+	ErrInvalidCommand = errors.New("ssh: invalid git command")
+)
+
+// SSHServer serves a [Backend] over SSH, as a git host whose users run
+// git-upload-pack, git-receive-pack and git-upload-archive through ssh
+// exec requests.
+type SSHServer struct {
+	// Backend serves the requests. If nil, a Backend using
+	// [transport.DefaultLoader] is used.
+	Backend *Backend
+
+	// HostKeys are the keys the server authenticates with. At least one is
+	// required.
+	HostKeys []ssh.Signer
+
+	// PublicKeyCallback authenticates a client by its public key. The
+	// permissions it returns are passed to Authorize. If nil, every client
+	// is refused.
+	PublicKeyCallback func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error)
+
+	// Authorize, when set, decides whether an authenticated client can run
+	// req, such as pushing to a given repository. Returning an error
+	// refuses the request with the error as message.
+	Authorize func(ctx context.Context, conn ssh.ConnMetadata, perms *ssh.Permissions, req *Request) error
+
+	// ErrorLog is used to log errors. If nil, errors are not logged.
+	ErrorLog *log.Logger
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[ssh.Conn]struct{}
+	wg        sync.WaitGroup
+	closed    bool
+}
+
+// Serve accepts connections on ln and serves each in its own goroutine. It
+// returns ErrSSHServerClosed once Close is called.
+func (s *SSHServer) Serve(ln net.Listener) error {
+	if len(s.HostKeys) == 0 {
+		return ErrNoHostKeys
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSSHServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrSSHServerClosed
+			}
+			return err
+		}
+
+		s.wg.Go(func() {
+			if err := s.ServeConn(context.Background(), conn); err != nil {
+				s.logf("ssh: %s: %v", conn.RemoteAddr(), err)
+			}
+		})
+	}
+}
+
+// ServeConn runs the SSH handshake on conn and serves its sessions until
+// the client disconnects or ctx is done. It closes conn.
+func (s *SSHServer) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer func() { _ = conn.Close() }()
+
+	if len(s.HostKeys) == 0 {
+		return ErrNoHostKeys
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if s.PublicKeyCallback == nil {
+				return nil, ErrNoPublicKeyCallback
+			}
+			return s.PublicKeyCallback(meta, key)
+		},
+	}
+	for _, k := range s.HostKeys {
+		config.AddHostKey(k)
+	}
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return err
+	}
+	if !s.addConn(sconn) {
+		_ = sconn.Close()
+		return ErrSSHServerClosed
+	}
+	defer s.removeConn(sconn)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = sconn.Close()
+	}()
+
+	go ssh.DiscardRequests(reqs)
+
+	var wg sync.WaitGroup
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			s.logf("ssh: accept channel: %v", err)
+			continue
+		}
+
+		wg.Go(func() {
+			s.serveSession(ctx, sconn, ch, chReqs)
+		})
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Close closes the listeners and the connections of the server.
+func (s *SSHServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	for ln := range s.listeners {
+		if cerr := ln.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *SSHServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *SSHServer) addConn(conn ssh.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[ssh.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *SSHServer) removeConn(conn ssh.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// serveSession runs the git command of the exec request of a session
+// channel, taking GIT_PROTOCOL from its env requests.
+func (s *SSHServer) serveSession(ctx context.Context, conn *ssh.ServerConn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer func() { _ = ch.Close() }()
+
+	var gitProtocol string
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var env struct{ Name, Value string }
+			ok := ssh.Unmarshal(req.Payload, &env) == nil && env.Name == "GIT_PROTOCOL"
+			if ok {
+				gitProtocol = env.Value
+			}
+			_ = req.Reply(ok, nil)
+		case "exec":
+			var exec struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			go ssh.DiscardRequests(reqs)
+			status := s.exec(ctx, conn, ch, exec.Command, gitProtocol)
+			_ = ch.CloseWrite()
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// exec runs command on ch and returns its exit status.
+func (s *SSHServer) exec(ctx context.Context, conn *ssh.ServerConn, ch ssh.Channel, command, gitProtocol string) uint32 {
+	service, path, err := parseSSHCommand(command)
+	if err != nil {
+		_, _ = fmt.Fprintf(ch.Stderr(), "fatal: %v\n", err)
+		return 128
+	}
+
+	req := &Request{
+		URL:         &url.URL{Scheme: "ssh", User: url.User(conn.User()), Path: path},
+		Service:     service,
+		GitProtocol: gitProtocol,
+	}
+
+	if s.Authorize != nil {
+		if err := s.Authorize(ctx, conn, conn.Permissions, req); err != nil {
+			_, _ = fmt.Fprintf(ch.Stderr(), "fatal: %v\n", err)
+			return 128
+		}
+	}
+
+	b := s.Backend
+	if b == nil {
+		b = New(nil)
+	}
+	if err := b.Serve(ctx, io.NopCloser(ch), ioutil.WriteNopCloser(ch), req); err != nil {
+		s.logf("ssh: serve %s %s: %v", service, path, err)
+		_, _ = fmt.Fprintf(ch.Stderr(), "fatal: %v\n", err)
+		return 128
+	}
+
+	return 0
+}
+
+func (s *SSHServer) logf(format string, v ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, v...)
+	}
+}
+
+// parseSSHCommand parses the command of an exec request, as in
+// "git-upload-pack '/repo.git'" or "git upload-pack '/repo.git'", into a
+// service and a repository path.
+func parseSSHCommand(command string) (service, path string, err error) {
+	name, arg, ok := strings.Cut(strings.TrimSpace(command), " ")
+	if !ok {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidCommand, command)
+	}
+	if name == "git" {
+		sub, rest, ok := strings.Cut(strings.TrimLeft(arg, " "), " ")
+		if !ok {
+			return "", "", fmt.Errorf("%w: %q", ErrInvalidCommand, command)
+		}
+		name, arg = "git-"+sub, rest
+	}
+
+	switch name {
+	case transport.UploadPackService, transport.ReceivePackService, transport.UploadArchiveService:
+	default:
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidCommand, command)
+	}
+
+	path, err = sqDequote(strings.TrimSpace(arg))
+	if err != nil || path == "" {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidCommand, command)
+	}
+
+	return name, path, nil
+}
+
+// sqDequote returns the single argument s quotes as git's sq_quote_buf
+// does, where an embedded single quote or exclamation mark closes the
+// quote, is escaped with a backslash and reopens it. An unquoted s without
+// whitespace is returned as is.
+func sqDequote(s string) (string, error) {
+	if !strings.HasPrefix(s, "'") {
+		if strings.ContainsAny(s, " \t'\\") {
+			return "", ErrInvalidCommand
+		}
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		// The closing quote, either the last byte or followed by an
+		// escaped ' or ! and a reopening quote.
+		rest := s[i+1:]
+		if rest == "" {
+			return b.String(), nil
+		}
+		if len(rest) >= 3 && rest[0] == '\\' && (rest[1] == '\'' || rest[1] == '!') && rest[2] == '\'' {
+			b.WriteByte(rest[1])
+			i += 3
+			continue
+		}
+		return "", ErrInvalidCommand
+	}
+
+	return "", ErrInvalidCommand
+}
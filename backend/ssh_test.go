@@ -0,0 +1,239 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+func newSSHSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return signer
+}
+
+// startSSHServer serves srv on a local port and returns its address.
+func startSSHServer(t *testing.T, srv *SSHServer) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Close())
+		require.ErrorIs(t, <-done, ErrSSHServerClosed)
+	})
+
+	return ln.Addr().String()
+}
+
+// newSSHTestServer returns a server authenticating the client key.
+func newSSHTestServer(t *testing.T, client ssh.Signer) *SSHServer {
+	t.Helper()
+	return &SSHServer{
+		Backend:  New(&fixturesLoader{t}),
+		HostKeys: []ssh.Signer{newSSHSigner(t)},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), client.PublicKey().Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"user": "alice"}}, nil
+		},
+	}
+}
+
+func dialSSH(t *testing.T, addr string, signer ssh.Signer) (*ssh.Client, error) {
+	t.Helper()
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "git",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec // test server
+	})
+}
+
+// runSSHCommand runs command with stdin and returns its output.
+func runSSHCommand(t *testing.T, client *ssh.Client, command string, stdin []byte) (stdout, stderr string, err error) {
+	t.Helper()
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	var out, errOut bytes.Buffer
+	session.Stdin = bytes.NewReader(stdin)
+	session.Stdout = &out
+	session.Stderr = &errOut
+	err = session.Run(command)
+	return out.String(), errOut.String(), err
+}
+
+func TestSSHServerUploadPack(t *testing.T) {
+	t.Parallel()
+
+	client := newSSHSigner(t)
+	addr := startSSHServer(t, newSSHTestServer(t, client))
+
+	conn, err := dialSSH(t, addr, client)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	out, _, err := runSSHCommand(t, conn, "git-upload-pack 'basic.git'", []byte("0000"))
+	require.NoError(t, err)
+
+	ar := &packp.AdvRefs{}
+	require.NoError(t, ar.Decode(bytes.NewReader([]byte(out))))
+	assert.Contains(t, ar.References, plumbing.NewHashReference(
+		"refs/heads/master",
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	))
+}
+
+func TestSSHServerReceivePackHooks(t *testing.T) {
+	t.Parallel()
+
+	client := newSSHSigner(t)
+	srv := newSSHTestServer(t, client)
+
+	var (
+		authorized *Request
+		user       string
+		refused    = errors.New("branch is protected")
+	)
+	srv.Authorize = func(_ context.Context, _ ssh.ConnMetadata, perms *ssh.Permissions, req *Request) error {
+		authorized = req
+		user = perms.Extensions["user"]
+		return nil
+	}
+	srv.Backend.Hooks.PreReceive = func(context.Context, *transport.PreReceiveInfo) error {
+		return refused
+	}
+	addr := startSSHServer(t, srv)
+
+	conn, err := dialSSH(t, addr, client)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	ref := plumbing.ReferenceName("refs/heads/branch")
+	upreq := &packp.UpdateRequests{Commands: []*packp.Command{{
+		Name: ref,
+		Old:  plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+		New:  plumbing.ZeroHash,
+	}}}
+	upreq.Capabilities.Set(capability.ReportStatus)
+	var stdin bytes.Buffer
+	require.NoError(t, upreq.Encode(&stdin))
+
+	out, _, err := runSSHCommand(t, conn, "git receive-pack 'basic.git'", stdin.Bytes())
+	require.Error(t, err)
+	assert.Contains(t, out, "ng refs/heads/branch branch is protected")
+
+	require.NotNil(t, authorized)
+	assert.Equal(t, transport.ReceivePackService, authorized.Service)
+	assert.Equal(t, "basic.git", authorized.URL.Path)
+	assert.Equal(t, "git", authorized.URL.User.Username())
+	assert.Equal(t, "alice", user)
+}
+
+func TestSSHServerAuthorizeRefused(t *testing.T) {
+	t.Parallel()
+
+	client := newSSHSigner(t)
+	srv := newSSHTestServer(t, client)
+	srv.Authorize = func(context.Context, ssh.ConnMetadata, *ssh.Permissions, *Request) error {
+		return errors.New("access denied")
+	}
+	addr := startSSHServer(t, srv)
+
+	conn, err := dialSSH(t, addr, client)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	out, stderr, err := runSSHCommand(t, conn, "git-upload-pack 'basic.git'", nil)
+	var exitErr *ssh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 128, exitErr.ExitStatus())
+	assert.Empty(t, out)
+	assert.Equal(t, "fatal: access denied\n", stderr)
+}
+
+func TestSSHServerInvalidCommand(t *testing.T) {
+	t.Parallel()
+
+	client := newSSHSigner(t)
+	addr := startSSHServer(t, newSSHTestServer(t, client))
+
+	conn, err := dialSSH(t, addr, client)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, stderr, err := runSSHCommand(t, conn, "rm -rf /", nil)
+	require.Error(t, err)
+	assert.Contains(t, stderr, ErrInvalidCommand.Error())
+}
+
+func TestSSHServerUnknownKey(t *testing.T) {
+	t.Parallel()
+
+	addr := startSSHServer(t, newSSHTestServer(t, newSSHSigner(t)))
+
+	_, err := dialSSH(t, addr, newSSHSigner(t))
+	require.Error(t, err)
+}
+
+func TestSSHServerNoHostKeys(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	assert.ErrorIs(t, (&SSHServer{}).Serve(ln), ErrNoHostKeys)
+}
+
+func TestParseSSHCommand(t *testing.T) {
+	t.Parallel()
+
+	for command, expected := range map[string][2]string{
+		"git-upload-pack '/repo.git'":         {transport.UploadPackService, "/repo.git"},
+		"git-receive-pack 'repo.git'":         {transport.ReceivePackService, "repo.git"},
+		"git upload-archive '/repo.git'":      {transport.UploadArchiveService, "/repo.git"},
+		"git-upload-pack /repo.git":           {transport.UploadPackService, "/repo.git"},
+		`git-upload-pack '/it'\''s/repo.git'`: {transport.UploadPackService, "/it's/repo.git"},
+		`git-upload-pack '/wow'\!'/repo.git'`: {transport.UploadPackService, "/wow!/repo.git"},
+	} {
+		service, path, err := parseSSHCommand(command)
+		require.NoError(t, err, command)
+		assert.Equal(t, expected[0], service, command)
+		assert.Equal(t, expected[1], path, command)
+	}
+
+	for _, command := range []string{
+		"",
+		"git-upload-pack",
+		"git-shell '/repo.git'",
+		"git-upload-pack '/repo.git",
+		"git-upload-pack '/a' '/b'",
+		"git-upload-pack /a b",
+	} {
+		_, _, err := parseSSHCommand(command)
+		assert.ErrorIs(t, err, ErrInvalidCommand, command)
+	}
+}
@@ -0,0 +1,224 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bundle"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// bundleRefPrefix is where the branches of the bundles downloaded before a
+// clone are stored, so that the fetch that follows advertises them as haves.
+const bundleRefPrefix = "refs/bundles/"
+
+// maxBundleListDepth bounds how deep bundle lists may point at other bundle
+// lists, as git's max_bundle_uri_depth does.
+const maxBundleListDepth = 4
+
+// fetchBundleURI downloads the bundle, or the bundles of the bundle list, at
+// uri with client and stores them in the repository.
+func (r *Remote) fetchBundleURI(ctx context.Context, client transport.HTTPClient, uri string) error {
+	files, err := downloadBundles(ctx, client, uri, 0)
+	defer removeFiles(files)
+	if err != nil {
+		return err
+	}
+	return r.unbundle(files)
+}
+
+// fetchAdvertisedBundles downloads the bundles of the bundle list the server
+// advertises with the bundle-uri command, if any, with client and stores them
+// in the repository. Relative bundle URIs are resolved against remoteURL.
+func (r *Remote) fetchAdvertisedBundles(ctx context.Context, sess transport.Session, client transport.HTTPClient, remoteURL string) error {
+	list, err := transport.BundleList(ctx, sess)
+	if err != nil || list == nil {
+		return err
+	}
+
+	files, err := downloadBundleList(ctx, client, list, remoteURL, 0)
+	defer removeFiles(files)
+	if err != nil {
+		return err
+	}
+	return r.unbundle(files)
+}
+
+// unbundle stores the bundles at paths in the repository, and their branches
+// under refs/bundles/. A bundle whose prerequisites are missing is retried
+// after the others, since the bundles of a list need not be ordered.
+func (r *Remote) unbundle(paths []string) error {
+	pending := paths
+	for len(pending) > 0 {
+		var retry []string
+		var lastErr error
+		for _, path := range pending {
+			err := r.unbundleFile(path)
+			if errors.Is(err, bundle.ErrMissingPrerequisites) {
+				retry = append(retry, path)
+				lastErr = err
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(retry) == len(pending) {
+			return lastErr
+		}
+		pending = retry
+	}
+	return nil
+}
+
+func (r *Remote) unbundleFile(path string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(f, &err)
+
+	h, err := bundle.Unbundle(r.s, f)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range h.References {
+		if !ref.Name().IsBranch() {
+			continue
+		}
+		name := plumbing.ReferenceName(bundleRefPrefix + ref.Name().Short())
+		if err := r.s.SetReference(plumbing.NewHashReference(name, ref.Hash())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadBundles downloads the bundle at uri, or every bundle of the bundle
+// list at uri, into temporary files, returned in the order they are meant to
+// be applied. The caller removes the files, even on error.
+func downloadBundles(ctx context.Context, client transport.HTTPClient, uri string, depth int) ([]string, error) {
+	path, err := downloadBundleURI(ctx, client, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{path}, err
+	}
+	br := bufio.NewReader(f)
+	if bundle.IsBundle(br) {
+		_ = f.Close()
+		return []string{path}, nil
+	}
+
+	list, err := bundle.DecodeList(br)
+	_ = f.Close()
+	_ = os.Remove(path)
+	if err != nil {
+		return nil, err
+	}
+	return downloadBundleList(ctx, client, list, uri, depth)
+}
+
+// downloadBundleList downloads the bundles of list, resolving relative URIs
+// against base. Under bundle.ModeAny it stops at the first bundle downloaded.
+func downloadBundleList(ctx context.Context, client transport.HTTPClient, list *bundle.List, base string, depth int) ([]string, error) {
+	if depth >= maxBundleListDepth {
+		return nil, fmt.Errorf("bundle lists nested deeper than %d", maxBundleListDepth)
+	}
+
+	var files []string
+	var lastErr error
+	for _, b := range list.Sorted() {
+		got, err := downloadBundles(ctx, client, resolveBundleURI(base, b.URI), depth+1)
+		files = append(files, got...)
+		if err != nil {
+			if list.Mode == bundle.ModeAll {
+				return files, err
+			}
+			lastErr = err
+			continue
+		}
+		if list.Mode == bundle.ModeAny {
+			return files, nil
+		}
+	}
+	return files, lastErr
+}
+
+// downloadBundleURI copies the content at uri, a HTTP(S) or file URL or a
+// local path, into a temporary file and returns its path. HTTP(S) URLs are
+// requested with client.
+func downloadBundleURI(ctx context.Context, client transport.HTTPClient, uri string) (path string, err error) {
+	var src io.ReadCloser
+	switch {
+	case strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return "", err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusOK {
+			_ = res.Body.Close()
+			return "", fmt.Errorf("downloading bundle %s: unexpected status %s", uri, res.Status)
+		}
+		src = res.Body
+	default:
+		local := uri
+		if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+			local = u.Path
+		}
+		if src, err = os.Open(filepath.FromSlash(local)); err != nil {
+			return "", err
+		}
+	}
+	defer ioutil.CheckClose(src, &err)
+
+	dst, err := os.CreateTemp("", "go-git-bundle-*")
+	if err != nil {
+		return "", err
+	}
+	defer ioutil.CheckClose(dst, &err)
+
+	if _, err := io.Copy(dst, ioutil.NewContextReader(ctx, src)); err != nil {
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// resolveBundleURI resolves uri, as listed in a bundle list, against the URI
+// base the list was read from.
+func resolveBundleURI(base, uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.IsAbs() || filepath.IsAbs(uri) {
+		return uri
+	}
+	b, err := url.Parse(base)
+	if err != nil || !b.IsAbs() {
+		return filepath.Join(filepath.Dir(base), filepath.FromSlash(uri))
+	}
+	return b.ResolveReference(u).String()
+}
+
+func removeFiles(paths []string) {
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
@@ -0,0 +1,185 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/client"
+	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// bundleSource returns the git directory of a copy of the basic fixture and
+// creates in dir a bundle of it for each of revs, named after its index.
+func bundleSource(t *testing.T, dir string, revs ...string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git CLI not found in PATH")
+	}
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	for i, rev := range revs {
+		path := filepath.Join(dir, string(rune('a'+i))+".bundle")
+		git(t, dotgit.Root(), "bundle", "create", path, rev)
+	}
+	return dotgit.Root()
+}
+
+func TestCloneBundleURI(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := bundleSource(t, dir, "master")
+
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL:       src,
+		BundleURI: filepath.Join(dir, "a.bundle"),
+	})
+	require.NoError(t, err)
+
+	ref, err := r.Reference("refs/bundles/master", false)
+	require.NoError(t, err)
+	assert.Equal(t, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), ref.Hash())
+
+	head, err := r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, ref.Hash(), head.Hash())
+}
+
+func TestCloneBundleURIList(t *testing.T) {
+	t.Parallel()
+
+	// The incremental bundle is listed first, so it is only applied once
+	// the bundle it builds on is.
+	dir := t.TempDir()
+	src := bundleSource(t, dir, "b029517f6300c2da0f4b651b8642506cd6aaf45d..master", "branch")
+	list := filepath.Join(dir, "list")
+	require.NoError(t, os.WriteFile(list, []byte(`[bundle]
+	version = 1
+	mode = all
+[bundle "incremental"]
+	uri = a.bundle
+[bundle "base"]
+	uri = b.bundle
+`), 0o644))
+
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL:       src,
+		BundleURI: list,
+	})
+	require.NoError(t, err)
+
+	for _, name := range []plumbing.ReferenceName{"refs/bundles/master", "refs/bundles/branch"} {
+		_, err := r.Reference(name, false)
+		require.NoError(t, err, name)
+	}
+}
+
+func TestCloneBundleURIUnavailable(t *testing.T) {
+	t.Parallel()
+
+	src := bundleSource(t, t.TempDir())
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL:       src,
+		BundleURI: filepath.Join(t.TempDir(), "missing.bundle"),
+	})
+	require.NoError(t, err, "a bundle that cannot be fetched does not fail the clone")
+
+	_, err = r.Head()
+	require.NoError(t, err)
+}
+
+func TestCloneAdvertisedBundles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := bundleSource(t, dir, "master")
+	git(t, src, "config", "uploadpack.advertiseBundleURIs", "true")
+	git(t, src, "config", "bundle.version", "1")
+	git(t, src, "config", "bundle.mode", "all")
+	git(t, src, "config", "bundle.main.uri", filepath.Join(dir, "a.bundle"))
+
+	for _, enabled := range []bool{false, true} {
+		r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+		require.NoError(t, err)
+		cfg, err := r.Config()
+		require.NoError(t, err)
+		cfg.Transfer.BundleURI = config.NewOptBool(enabled)
+		require.NoError(t, r.SetConfig(cfg))
+
+		require.NoError(t, r.clone(context.Background(), &CloneOptions{URL: src}))
+
+		_, err = r.Reference("refs/bundles/master", false)
+		if enabled {
+			require.NoError(t, err)
+		} else {
+			require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+		}
+	}
+}
+
+func TestHTTPClientCredentialsOrigin(t *testing.T) {
+	t.Parallel()
+
+	var authorization []string
+	handler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+	})
+	origin := httptest.NewServer(handler)
+	t.Cleanup(origin.Close)
+	foreign := httptest.NewServer(handler)
+	t.Cleanup(foreign.Close)
+
+	// The URIs the server points at are only sent the credentials of the
+	// remote if they are on its origin.
+	cl := client.New(client.WithHTTPAuth(&xhttp.TokenAuth{Token: "secret-token"}))
+	hc := httpClient(cl, origin.URL+"/repo.git")
+	for _, uri := range []string{origin.URL + "/pack", foreign.URL + "/pack"} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, uri, nil)
+		require.NoError(t, err)
+		res, err := hc.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	assert.Equal(t, []string{"Bearer secret-token", ""}, authorization)
+}
+
+func TestCloneBundleURIHTTPAuth(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := bundleSource(t, dir, "master")
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(dir, "a.bundle"))
+	}))
+	t.Cleanup(hs.Close)
+
+	// The bundle is downloaded with the HTTP settings of the clone.
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL:           src,
+		BundleURI:     hs.URL + "/a.bundle",
+		ClientOptions: []client.Option{client.WithHTTPAuth(&xhttp.BasicAuth{Username: "user", Password: "secret"})},
+	})
+	require.NoError(t, err)
+
+	_, err = r.Reference("refs/bundles/master", false)
+	require.NoError(t, err)
+}
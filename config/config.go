@@ -79,6 +79,40 @@ type Config struct {
 		// converted to LF when added to the repository, and vice versa on checkout.
 		// If set to "input", only worktree-to-repository conversion is performed.
 		AutoCRLF string
+		// EOL is the line ending of the text files checked out in the worktree
+		// whose eol attribute is unspecified: "lf", "crlf" or "native", the
+		// default, for the line ending of the platform. It is ignored when
+		// AutoCRLF is "true" or "input".
+		EOL string
+		// CheckRoundtripEncoding is the comma or space separated list of the
+		// working-tree-encoding attribute values whose files are checked to
+		// convert back to their content in the worktree when they are added
+		// to the repository. If empty, "SHIFT-JIS" is checked, as git does.
+		CheckRoundtripEncoding string
+		// UntrackedCache is whether the untracked cache index extension is
+		// used to skip listing the unchanged directories: "true" to add it
+		// to the index, "false" to remove it, or "keep", the default, to use
+		// it only if the index already has it.
+		UntrackedCache string
+		// FSMonitor is the path of the hook queried for the files changed
+		// since the last query, so the others are not checked. The builtin
+		// file system monitor, "true", is not supported.
+		FSMonitor string
+		// FSMonitorHookVersion is the version of the protocol used with the
+		// FSMonitor hook: 1 or 2. If unset, 2 is tried, and then 1.
+		FSMonitorHookVersion int
+		// SplitIndex if true, the index is written as a split index: most of
+		// its entries in a shared index, $GIT_DIR/sharedindex.<hash>, and the
+		// changes made to them in the index file. If false, it is written as
+		// a single file. If unset, an index is written as it was read.
+		SplitIndex OptBool
+		// SparseCheckout if true, only the files matching the patterns of
+		// $GIT_DIR/info/sparse-checkout are checked out in the worktree.
+		SparseCheckout OptBool
+		// SparseCheckoutCone if true, the sparse-checkout patterns are those
+		// of cone mode, which only list directories. If false, they are
+		// gitignore-style patterns.
+		SparseCheckoutCone OptBool
 		// FileMode defines whether the executable bit of working tree files is to be honored.
 		// If "false", when an index node is an Executable and is comparing hash
 		// against local file, 0644 will be used as the value of its mode. The original
@@ -155,6 +189,35 @@ type Config struct {
 		// which skips the trailing SHA-1/SHA-256 computation for performance
 		// on large repositories.
 		SkipHash OptBool
+		// Sparse if true, the directories out of a cone-mode sparse checkout
+		// are written to the index as a single sparse directory entry, instead
+		// of an entry per file. If false, sparse directory entries are
+		// expanded as the index is written.
+		Sparse OptBool
+		// Threads is the number of threads the entries of the index are read
+		// with, when it has an index entry offset table: true or 0 use as
+		// many as there are CPUs, false or 1 read them in sequence.
+		Threads string
+		// RecordOffsetTable if true, an index entry offset table is written
+		// for the index to be read with threads. If unset, it is written if
+		// Threads is set to something else than one thread.
+		RecordOffsetTable OptBool
+		// RecordEndOfIndexEntries if true, the end of index entries extension
+		// is written. If unset, it is written as RecordOffsetTable.
+		RecordEndOfIndexEntries OptBool
+	}
+
+	SplitIndex struct {
+		// MaxPercentChange is the percentage of the entries of a split index
+		// that may differ from those of its shared index before a new shared
+		// index is written. If zero, 20 is used, as git does.
+		MaxPercentChange int
+	}
+
+	Merge struct {
+		// ConflictStyle is the style the conflicts of a merge are written
+		// in to the worktree: "merge", the default, "diff3" or "zdiff3".
+		ConflictStyle string
 	}
 
 	Init struct {
@@ -171,6 +234,55 @@ type Config struct {
 		AllowUnreachable OptBool
 	}
 
+	UploadPack struct {
+		// AdvertiseBundleURIs when true advertises the bundle-uri command
+		// to protocol v2 clients, answering it with the bundle section of
+		// this config.
+		AdvertiseBundleURIs OptBool
+		// BlobPackfileURIs are the blobs served out of band to protocol v2
+		// clients that accept packfile URIs, each as
+		// "<object-hash> <pack-hash> <uri>": a blob, and the URI of a
+		// pre-generated packfile holding it whose checksum is pack-hash.
+		BlobPackfileURIs []string
+		// AllowRefInWant when true lets protocol v2 clients fetch references
+		// by name with want-ref.
+		AllowRefInWant OptBool
+		// AllowSidebandAll when true lets protocol v2 clients ask for the
+		// whole fetch response to be multiplexed over the sideband.
+		AllowSidebandAll OptBool
+		// AllowFilter when true lets clients ask for a filtered packfile,
+		// as partial clones do.
+		AllowFilter OptBool
+	}
+
+	Receive struct {
+		// CertNonceSeed is the secret the nonces of push certificates are
+		// derived from. The push-cert capability is only advertised, and
+		// pushes can only be signed, when it is set.
+		CertNonceSeed string
+		// CertNonceSlop is how many seconds old the nonce of a push
+		// certificate sent to a stateless server, such as over HTTP, can
+		// be and still be accepted.
+		CertNonceSlop int
+		// ProcReceiveRefs are the prefixes of the references whose updates
+		// are handed to the proc-receive hook of receive-pack, which can
+		// update other references in their place, as AGit-style refs/for/*
+		// pushes do.
+		ProcReceiveRefs []string
+	}
+
+	Transfer struct {
+		// BundleURI when true makes clones download the bundles the server
+		// advertises with the bundle-uri command before fetching the rest.
+		BundleURI OptBool
+	}
+
+	Fetch struct {
+		// URIProtocols are the protocols, such as "https", of the packfile
+		// URIs a fetch accepts. Packfile URIs are not requested when empty.
+		URIProtocols []string
+	}
+
 	Extensions struct {
 		// ObjectFormat specifies the hash algorithm to use. The
 		// acceptable values are sha1 and sha256. If not specified,
@@ -209,6 +321,9 @@ type Config struct {
 	// Branches list of branches, the key is the branch name and should
 	// equal Branch.Name
 	Branches map[string]*Branch
+	// Filters list of filter drivers, the key is the driver name and should
+	// equal Filter.Name.
+	Filters map[string]*Filter
 	// URLs list of url rewrite rules, if repo url starts with URL.InsteadOf value, it will be replaced with the
 	// URL.Name instead. Ordered by appearance in config file.
 	URLs []*URL
@@ -233,7 +348,11 @@ type user struct {
 	// directly (e.g.: "key::ssh-rsa XXXXXX identifier"). The private
 	// key needs to be available via ssh-agent.
 	//
-	// If not set go-git will use the first key available.
+	// If gpg.format is set to openpgp this can contain a key ID, a
+	// fingerprint or a user ID, or the path to a file holding the
+	// private key.
+	//
+	// If not set go-git will use the key of the committer or tagger.
 	SigningKey string
 }
 
@@ -326,6 +445,7 @@ func NewConfig() *Config {
 		Remotes:    make(map[string]*RemoteConfig),
 		Submodules: make(map[string]*Submodule),
 		Branches:   make(map[string]*Branch),
+		Filters:    make(map[string]*Filter),
 		URLs:       make([]*URL, 0),
 		Raw:        format.New(),
 	}
@@ -435,6 +555,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for name, f := range c.Filters {
+		if f.Name != name {
+			return ErrInvalid
+		}
+
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -478,17 +608,51 @@ const (
 	partialCloneFilterKey      = "partialclonefilter"
 	versionKey                 = "version"
 	autoCRLFKey                = "autocrlf"
+	eolKey                     = "eol"
+	checkRoundtripEncodingKey  = "checkRoundtripEncoding"
+	untrackedCacheKey          = "untrackedCache"
+	fsmonitorKey               = "fsmonitor"
+	fsmonitorHookVersionKey    = "fsmonitorHookVersion"
 	fileModeKey                = "filemode"
 	hooksPathKey               = "hooksPath"
 	protectNTFSKey             = "protectNTFS"
 	protectHFSKey              = "protectHFS"
 	indexSection               = "index"
 	skipHashKey                = "skipHash"
+	sparseKey                  = "sparse"
+	threadsKey                 = "threads"
+	recordOffsetTableKey       = "recordOffsetTable"
+	recordEndOfIndexEntriesKey = "recordEndOfIndexEntries"
+	splitIndexSection          = "splitIndex"
+	splitIndexKey              = "splitIndex"
+	sparseCheckoutKey          = "sparseCheckout"
+	sparseCheckoutConeKey      = "sparseCheckoutCone"
+	maxPercentChangeKey        = "maxPercentChange"
+	mergeSection               = "merge"
+	conflictStyleKey           = "conflictStyle"
 	formatKey                  = "format"
 	allowedSignersFileKey      = "allowedSignersFile"
 	gpgSignKey                 = "gpgSign"
 	uploadArchiveSection       = "uploadArchive"
 	allowUnreachableKey        = "allowUnreachable"
+	uploadPackSection          = "uploadpack"
+	advertiseBundleURIsKey     = "advertiseBundleURIs"
+	blobPackfileURIKey         = "blobPackfileUri"
+	allowRefInWantKey          = "allowRefInWant"
+	allowSidebandAllKey        = "allowSidebandAll"
+	allowFilterKey             = "allowFilter"
+	receiveSection             = "receive"
+	certNonceSeedKey           = "certNonceSeed"
+	certNonceSlopKey           = "certNonceSlop"
+	procReceiveRefsKey         = "procReceiveRefs"
+	transferSection            = "transfer"
+	bundleURIKey               = "bundleURI"
+	uriProtocolsKey            = "uriProtocols"
+	filterSection              = "filter"
+	cleanKey                   = "clean"
+	smudgeKey                  = "smudge"
+	processKey                 = "process"
+	requiredKey                = "required"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -510,12 +674,18 @@ func (c *Config) Unmarshal(b []byte) error {
 	c.unmarshalCore()
 	c.unmarshalExtensions()
 	c.unmarshalIndex()
+	c.unmarshalSplitIndex()
+	c.unmarshalMerge()
 	c.unmarshalTag()
 	c.unmarshalCommit()
 	c.unmarshalUser()
 	c.unmarshalGPG()
 	c.unmarshalInit()
 	c.unmarshalUploadArchive()
+	c.unmarshalUploadPack()
+	c.unmarshalReceive()
+	c.unmarshalTransfer()
+	c.unmarshalFetch()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -525,6 +695,10 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	if err := c.unmarshalFilters(); err != nil {
+		return err
+	}
+
 	if err := c.unmarshalURLs(); err != nil {
 		return err
 	}
@@ -545,8 +719,27 @@ func (c *Config) unmarshalCore() {
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
 	c.Core.AutoCRLF = s.Options.Get(autoCRLFKey)
+	c.Core.EOL = s.Options.Get(eolKey)
+	c.Core.CheckRoundtripEncoding = s.Options.Get(checkRoundtripEncodingKey)
+	c.Core.UntrackedCache = s.Options.Get(untrackedCacheKey)
+	c.Core.FSMonitor = s.Options.Get(fsmonitorKey)
+	if v, err := strconv.Atoi(s.Options.Get(fsmonitorHookVersionKey)); err == nil {
+		c.Core.FSMonitorHookVersion = v
+	}
 	c.Core.HooksPath = s.Options.Get(hooksPathKey)
 
+	if parsed := parseConfigBool(s.Options.Get(splitIndexKey)); parsed.IsSet() {
+		c.Core.SplitIndex = parsed
+	}
+
+	if parsed := parseConfigBool(s.Options.Get(sparseCheckoutKey)); parsed.IsSet() {
+		c.Core.SparseCheckout = parsed
+	}
+
+	if parsed := parseConfigBool(s.Options.Get(sparseCheckoutConeKey)); parsed.IsSet() {
+		c.Core.SparseCheckoutCone = parsed
+	}
+
 	if parsed := parseConfigBool(s.Options.Get(protectNTFSKey)); parsed.IsSet() {
 		c.Core.ProtectNTFS = parsed
 	}
@@ -710,6 +903,20 @@ func (c *Config) unmarshalBranches() error {
 	return nil
 }
 
+func (c *Config) unmarshalFilters() error {
+	fs := c.Raw.Section(filterSection)
+	for _, sub := range fs.Subsections {
+		f := &Filter{}
+
+		if err := f.unmarshal(sub); err != nil {
+			return err
+		}
+
+		c.Filters[f.Name] = f
+	}
+	return nil
+}
+
 func (c *Config) unmarshalProtocol() error {
 	s := c.Raw.Section(protocolSection)
 
@@ -734,6 +941,31 @@ func (c *Config) unmarshalIndex() {
 	if err == nil {
 		c.Index.SkipHash = NewOptBool(v)
 	}
+	v, err = strconv.ParseBool(s.Options.Get(sparseKey))
+	if err == nil {
+		c.Index.Sparse = NewOptBool(v)
+	}
+	c.Index.Threads = s.Options.Get(threadsKey)
+	v, err = strconv.ParseBool(s.Options.Get(recordOffsetTableKey))
+	if err == nil {
+		c.Index.RecordOffsetTable = NewOptBool(v)
+	}
+	v, err = strconv.ParseBool(s.Options.Get(recordEndOfIndexEntriesKey))
+	if err == nil {
+		c.Index.RecordEndOfIndexEntries = NewOptBool(v)
+	}
+}
+
+func (c *Config) unmarshalSplitIndex() {
+	s := c.Raw.Section(splitIndexSection)
+	if v, err := strconv.Atoi(s.Options.Get(maxPercentChangeKey)); err == nil {
+		c.SplitIndex.MaxPercentChange = v
+	}
+}
+
+func (c *Config) unmarshalMerge() {
+	s := c.Raw.Section(mergeSection)
+	c.Merge.ConflictStyle = s.Options.Get(conflictStyleKey)
 }
 
 func (c *Config) unmarshalInit() {
@@ -749,6 +981,51 @@ func (c *Config) unmarshalUploadArchive() {
 	}
 }
 
+func (c *Config) unmarshalUploadPack() {
+	if !c.Raw.HasSection(uploadPackSection) {
+		return
+	}
+	s := c.Raw.Section(uploadPackSection)
+	c.UploadPack.AdvertiseBundleURIs = parseConfigBool(s.Options.Get(advertiseBundleURIsKey))
+	c.UploadPack.BlobPackfileURIs = s.Options.GetAll(blobPackfileURIKey)
+	c.UploadPack.AllowRefInWant = parseConfigBool(s.Options.Get(allowRefInWantKey))
+	c.UploadPack.AllowSidebandAll = parseConfigBool(s.Options.Get(allowSidebandAllKey))
+	c.UploadPack.AllowFilter = parseConfigBool(s.Options.Get(allowFilterKey))
+}
+
+func (c *Config) unmarshalReceive() {
+	if !c.Raw.HasSection(receiveSection) {
+		return
+	}
+	s := c.Raw.Section(receiveSection)
+	c.Receive.CertNonceSeed = s.Options.Get(certNonceSeedKey)
+	if v, err := strconv.Atoi(s.Options.Get(certNonceSlopKey)); err == nil {
+		c.Receive.CertNonceSlop = v
+	}
+	c.Receive.ProcReceiveRefs = s.Options.GetAll(procReceiveRefsKey)
+}
+
+func (c *Config) unmarshalTransfer() {
+	if !c.Raw.HasSection(transferSection) {
+		return
+	}
+	s := c.Raw.Section(transferSection)
+	c.Transfer.BundleURI = parseConfigBool(s.Options.Get(bundleURIKey))
+}
+
+func (c *Config) unmarshalFetch() {
+	if !c.Raw.HasSection(fetchKey) {
+		return
+	}
+	s := c.Raw.Section(fetchKey)
+	c.Fetch.URIProtocols = nil
+	for p := range strings.SplitSeq(s.Options.Get(uriProtocolsKey), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			c.Fetch.URIProtocols = append(c.Fetch.URIProtocols, p)
+		}
+	}
+}
+
 // Marshal returns Config encoded as a git-config file.
 //
 // This call populates the field Raw with the current values of
@@ -761,6 +1038,8 @@ func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
 	c.marshalExtensions()
 	c.marshalIndex()
+	c.marshalSplitIndex()
+	c.marshalMerge()
 	c.marshalTag()
 	c.marshalCommit()
 	c.marshalUser()
@@ -769,10 +1048,15 @@ func (c *Config) Marshal() ([]byte, error) {
 	c.marshalRemotes()
 	c.marshalSubmodules()
 	c.marshalBranches()
+	c.marshalFilters()
 	c.marshalURLs()
 	c.marshalProtocol()
 	c.marshalInit()
 	c.marshalUploadArchive()
+	c.marshalUploadPack()
+	c.marshalReceive()
+	c.marshalTransfer()
+	c.marshalFetch()
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
@@ -797,6 +1081,38 @@ func (c *Config) marshalCore() {
 		s.SetOption(autoCRLFKey, c.Core.AutoCRLF)
 	}
 
+	if c.Core.EOL != "" {
+		s.SetOption(eolKey, c.Core.EOL)
+	}
+
+	if c.Core.CheckRoundtripEncoding != "" {
+		s.SetOption(checkRoundtripEncodingKey, c.Core.CheckRoundtripEncoding)
+	}
+
+	if c.Core.UntrackedCache != "" {
+		s.SetOption(untrackedCacheKey, c.Core.UntrackedCache)
+	}
+
+	if c.Core.FSMonitor != "" {
+		s.SetOption(fsmonitorKey, c.Core.FSMonitor)
+	}
+
+	if c.Core.FSMonitorHookVersion != 0 {
+		s.SetOption(fsmonitorHookVersionKey, strconv.Itoa(c.Core.FSMonitorHookVersion))
+	}
+
+	if c.Core.SplitIndex.IsSet() {
+		s.SetOption(splitIndexKey, c.Core.SplitIndex.FormatBool())
+	}
+
+	if c.Core.SparseCheckout.IsSet() {
+		s.SetOption(sparseCheckoutKey, c.Core.SparseCheckout.FormatBool())
+	}
+
+	if c.Core.SparseCheckoutCone.IsSet() {
+		s.SetOption(sparseCheckoutConeKey, c.Core.SparseCheckoutCone.FormatBool())
+	}
+
 	s.SetOption(fileModeKey, fmt.Sprintf("%t", c.Core.FileMode))
 
 	if c.Core.HooksPath != "" {
@@ -978,6 +1294,33 @@ func (c *Config) marshalBranches() {
 	s.Subsections = newSubsections
 }
 
+func (c *Config) marshalFilters() {
+	s := c.Raw.Section(filterSection)
+	newSubsections := make(format.Subsections, 0, len(c.Filters))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if filter, ok := c.Filters[subsection.Name]; ok {
+			newSubsections = append(newSubsections, filter.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	filterNames := make([]string, 0, len(c.Filters))
+	for name := range c.Filters {
+		filterNames = append(filterNames, name)
+	}
+
+	sort.Strings(filterNames)
+
+	for _, name := range filterNames {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.Filters[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
 func (c *Config) marshalURLs() {
 	s := c.Raw.Section(urlSection)
 	s.Subsections = make(format.Subsections, len(c.URLs))
@@ -1018,6 +1361,36 @@ func (c *Config) marshalIndex() {
 		s := c.Raw.Section(indexSection)
 		s.SetOption(skipHashKey, c.Index.SkipHash.FormatBool())
 	}
+	if c.Index.Sparse.IsSet() {
+		s := c.Raw.Section(indexSection)
+		s.SetOption(sparseKey, c.Index.Sparse.FormatBool())
+	}
+	if c.Index.Threads != "" {
+		s := c.Raw.Section(indexSection)
+		s.SetOption(threadsKey, c.Index.Threads)
+	}
+	if c.Index.RecordOffsetTable.IsSet() {
+		s := c.Raw.Section(indexSection)
+		s.SetOption(recordOffsetTableKey, c.Index.RecordOffsetTable.FormatBool())
+	}
+	if c.Index.RecordEndOfIndexEntries.IsSet() {
+		s := c.Raw.Section(indexSection)
+		s.SetOption(recordEndOfIndexEntriesKey, c.Index.RecordEndOfIndexEntries.FormatBool())
+	}
+}
+
+func (c *Config) marshalSplitIndex() {
+	if c.SplitIndex.MaxPercentChange != 0 {
+		s := c.Raw.Section(splitIndexSection)
+		s.SetOption(maxPercentChangeKey, strconv.Itoa(c.SplitIndex.MaxPercentChange))
+	}
+}
+
+func (c *Config) marshalMerge() {
+	if c.Merge.ConflictStyle != "" {
+		s := c.Raw.Section(mergeSection)
+		s.SetOption(conflictStyleKey, c.Merge.ConflictStyle)
+	}
 }
 
 func (c *Config) marshalInit() {
@@ -1034,6 +1407,66 @@ func (c *Config) marshalUploadArchive() {
 	}
 }
 
+func (c *Config) marshalUploadPack() {
+	if c.UploadPack.AdvertiseBundleURIs.IsSet() {
+		s := c.Raw.Section(uploadPackSection)
+		s.SetOption(advertiseBundleURIsKey, c.UploadPack.AdvertiseBundleURIs.FormatBool())
+	}
+	if c.UploadPack.AllowRefInWant.IsSet() {
+		s := c.Raw.Section(uploadPackSection)
+		s.SetOption(allowRefInWantKey, c.UploadPack.AllowRefInWant.FormatBool())
+	}
+	if c.UploadPack.AllowSidebandAll.IsSet() {
+		s := c.Raw.Section(uploadPackSection)
+		s.SetOption(allowSidebandAllKey, c.UploadPack.AllowSidebandAll.FormatBool())
+	}
+	if c.UploadPack.AllowFilter.IsSet() {
+		s := c.Raw.Section(uploadPackSection)
+		s.SetOption(allowFilterKey, c.UploadPack.AllowFilter.FormatBool())
+	}
+	if len(c.UploadPack.BlobPackfileURIs) > 0 || c.Raw.HasSection(uploadPackSection) {
+		s := c.Raw.Section(uploadPackSection)
+		s.RemoveOption(blobPackfileURIKey)
+		for _, v := range c.UploadPack.BlobPackfileURIs {
+			s.AddOption(blobPackfileURIKey, v)
+		}
+	}
+}
+
+func (c *Config) marshalReceive() {
+	if c.Receive.CertNonceSeed != "" {
+		s := c.Raw.Section(receiveSection)
+		s.SetOption(certNonceSeedKey, c.Receive.CertNonceSeed)
+	}
+	if c.Receive.CertNonceSlop != 0 {
+		s := c.Raw.Section(receiveSection)
+		s.SetOption(certNonceSlopKey, strconv.Itoa(c.Receive.CertNonceSlop))
+	}
+	if len(c.Receive.ProcReceiveRefs) > 0 || c.Raw.HasSection(receiveSection) {
+		s := c.Raw.Section(receiveSection)
+		s.RemoveOption(procReceiveRefsKey)
+		for _, v := range c.Receive.ProcReceiveRefs {
+			s.AddOption(procReceiveRefsKey, v)
+		}
+	}
+}
+
+func (c *Config) marshalTransfer() {
+	if c.Transfer.BundleURI.IsSet() {
+		s := c.Raw.Section(transferSection)
+		s.SetOption(bundleURIKey, c.Transfer.BundleURI.FormatBool())
+	}
+}
+
+func (c *Config) marshalFetch() {
+	if len(c.Fetch.URIProtocols) > 0 {
+		s := c.Raw.Section(fetchKey)
+		s.SetOption(uriProtocolsKey, strings.Join(c.Fetch.URIProtocols, ","))
+	} else if c.Raw.HasSection(fetchKey) {
+		c.Raw.Section(fetchKey).RemoveOption(uriProtocolsKey)
+	}
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -30,6 +30,11 @@ func (s *ConfigSuite) TestUnmarshal() {
 		worktree = foo
 		commentchar = bar
 		autocrlf = true
+		eol = crlf
+		checkRoundtripEncoding = SHIFT-JIS, UTF-16
+		untrackedCache = true
+		fsmonitor = .git/hooks/query-watchman
+		fsmonitorHookVersion = 2
 		filemode = false
 		hooksPath = custom-hooks
 [user]
@@ -77,6 +82,11 @@ func (s *ConfigSuite) TestUnmarshal() {
 	s.Equal("foo", cfg.Core.Worktree)
 	s.Equal("bar", cfg.Core.CommentChar)
 	s.Equal("true", cfg.Core.AutoCRLF)
+	s.Equal("crlf", cfg.Core.EOL)
+	s.Equal("SHIFT-JIS, UTF-16", cfg.Core.CheckRoundtripEncoding)
+	s.Equal("true", cfg.Core.UntrackedCache)
+	s.Equal(".git/hooks/query-watchman", cfg.Core.FSMonitor)
+	s.Equal(2, cfg.Core.FSMonitorHookVersion)
 	s.False(cfg.Core.FileMode)
 	s.Equal("custom-hooks", cfg.Core.HooksPath)
 	s.Equal("John Doe", cfg.User.Name)
@@ -917,6 +927,106 @@ func TestMarshalIndexSkipHash(t *testing.T) {
 	assert.Equal(t, OptBoolTrue, cfg2.Index.SkipHash)
 }
 
+func TestUnmarshalMarshalSplitAndSparseIndex(t *testing.T) {
+	t.Parallel()
+
+	input := []byte("[core]\n\tsplitIndex = true\n[index]\n\tsparse = true\n[splitIndex]\n\tmaxPercentChange = 50\n")
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal(input))
+	assert.Equal(t, OptBoolTrue, cfg.Core.SplitIndex)
+	assert.Equal(t, OptBoolTrue, cfg.Index.Sparse)
+	assert.Equal(t, 50, cfg.SplitIndex.MaxPercentChange)
+
+	cfg.Core.SplitIndex = OptBoolFalse
+	b, err := cfg.Marshal()
+	require.NoError(t, err)
+
+	cfg2 := NewConfig()
+	require.NoError(t, cfg2.Unmarshal(b))
+	assert.Equal(t, OptBoolFalse, cfg2.Core.SplitIndex)
+	assert.Equal(t, OptBoolTrue, cfg2.Index.Sparse)
+	assert.Equal(t, 50, cfg2.SplitIndex.MaxPercentChange)
+
+	cfg3 := NewConfig()
+	require.NoError(t, cfg3.Unmarshal([]byte("[core]\n\tbare = false\n")))
+	assert.Equal(t, OptBoolUnset, cfg3.Core.SplitIndex)
+	assert.Equal(t, OptBoolUnset, cfg3.Index.Sparse)
+	assert.Zero(t, cfg3.SplitIndex.MaxPercentChange)
+}
+
+func TestUnmarshalMarshalIndexThreads(t *testing.T) {
+	t.Parallel()
+
+	input := []byte("[index]\n\tthreads = 4\n\trecordOffsetTable = true\n\trecordEndOfIndexEntries = false\n")
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal(input))
+	assert.Equal(t, "4", cfg.Index.Threads)
+	assert.Equal(t, OptBoolTrue, cfg.Index.RecordOffsetTable)
+	assert.Equal(t, OptBoolFalse, cfg.Index.RecordEndOfIndexEntries)
+
+	cfg.Index.Threads = "true"
+	b, err := cfg.Marshal()
+	require.NoError(t, err)
+
+	cfg2 := NewConfig()
+	require.NoError(t, cfg2.Unmarshal(b))
+	assert.Equal(t, "true", cfg2.Index.Threads)
+	assert.Equal(t, OptBoolTrue, cfg2.Index.RecordOffsetTable)
+	assert.Equal(t, OptBoolFalse, cfg2.Index.RecordEndOfIndexEntries)
+}
+
+func TestUnmarshalMarshalSparseCheckout(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal([]byte("[core]\n\tsparseCheckout = true\n\tsparseCheckoutCone = false\n")))
+	assert.Equal(t, OptBoolTrue, cfg.Core.SparseCheckout)
+	assert.Equal(t, OptBoolFalse, cfg.Core.SparseCheckoutCone)
+
+	b, err := cfg.Marshal()
+	require.NoError(t, err)
+
+	cfg2 := NewConfig()
+	require.NoError(t, cfg2.Unmarshal(b))
+	assert.Equal(t, OptBoolTrue, cfg2.Core.SparseCheckout)
+	assert.Equal(t, OptBoolFalse, cfg2.Core.SparseCheckoutCone)
+}
+
+func TestUnmarshalMarshalMerge(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal([]byte("[merge]\n\tconflictStyle = zdiff3\n")))
+	assert.Equal(t, "zdiff3", cfg.Merge.ConflictStyle)
+
+	b, err := cfg.Marshal()
+	require.NoError(t, err)
+
+	cfg2 := NewConfig()
+	require.NoError(t, cfg2.Unmarshal(b))
+	assert.Equal(t, "zdiff3", cfg2.Merge.ConflictStyle)
+}
+
+func TestUnmarshalMarshalReceive(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal([]byte("[receive]\n\tcertNonceSeed = secret\n\tcertNonceSlop = 60\n"+
+		"\tprocReceiveRefs = refs/for\n\tprocReceiveRefs = refs/drafts\n")))
+	assert.Equal(t, "secret", cfg.Receive.CertNonceSeed)
+	assert.Equal(t, 60, cfg.Receive.CertNonceSlop)
+	assert.Equal(t, []string{"refs/for", "refs/drafts"}, cfg.Receive.ProcReceiveRefs)
+
+	b, err := cfg.Marshal()
+	require.NoError(t, err)
+
+	cfg2 := NewConfig()
+	require.NoError(t, cfg2.Unmarshal(b))
+	assert.Equal(t, cfg.Receive, cfg2.Receive)
+}
+
 func TestMerge(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+var errFilterEmptyName = errors.New("filter config: empty name")
+
+// Filter contains the configuration of a filter driver, which converts the
+// content of the files whose filter attribute names it when they are added
+// to the repository and checked out in the worktree.
+type Filter struct {
+	// Name of the filter driver.
+	Name string
+	// Clean is the command converting the content of a file of the worktree
+	// into the content stored in the repository. Any %f in it is replaced
+	// with the path of the file.
+	Clean string
+	// Smudge is the command converting the content of a file stored in the
+	// repository into the content checked out in the worktree. Any %f in it
+	// is replaced with the path of the file.
+	Smudge string
+	// Process is the command of a long-running filter process, speaking the
+	// filter protocol, used instead of Clean and Smudge when set.
+	Process string
+	// Required makes the failure of the filter, or a missing command, an
+	// error instead of leaving the content unconverted.
+	Required bool
+
+	raw *format.Subsection
+}
+
+// Validate validates the fields of the filter.
+func (f *Filter) Validate() error {
+	if f.Name == "" {
+		return errFilterEmptyName
+	}
+
+	return nil
+}
+
+func (f *Filter) marshal() *format.Subsection {
+	if f.raw == nil {
+		f.raw = &format.Subsection{}
+	}
+
+	f.raw.Name = f.Name
+
+	for _, opt := range []struct{ key, value string }{
+		{cleanKey, f.Clean},
+		{smudgeKey, f.Smudge},
+		{processKey, f.Process},
+	} {
+		if opt.value == "" {
+			f.raw.RemoveOption(opt.key)
+		} else {
+			f.raw.SetOption(opt.key, opt.value)
+		}
+	}
+
+	if f.Required {
+		f.raw.SetOption(requiredKey, "true")
+	} else {
+		f.raw.RemoveOption(requiredKey)
+	}
+
+	return f.raw
+}
+
+func (f *Filter) unmarshal(s *format.Subsection) error {
+	f.raw = s
+
+	f.Name = f.raw.Name
+	f.Clean = f.raw.Options.Get(cleanKey)
+	f.Smudge = f.raw.Options.Get(smudgeKey)
+	f.Process = f.raw.Options.Get(processKey)
+	f.Required, _ = strconv.ParseBool(f.raw.Options.Get(requiredKey))
+
+	return f.Validate()
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterValidate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, (&Filter{Name: "lfs"}).Validate())
+	assert.Error(t, (&Filter{Clean: "cat"}).Validate())
+}
+
+func TestFilterMarshal(t *testing.T) {
+	t.Parallel()
+
+	expected := `[filter "crypt"]
+	smudge = git-crypt smudge
+	clean = git-crypt clean
+	required = true
+[filter "lfs"]
+	process = git-lfs filter-process
+[core]
+	bare = false
+	filemode = true
+`
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal([]byte(`[filter "crypt"]
+	smudge = git-crypt smudge
+	clean = old
+`)))
+	cfg.Filters["crypt"].Clean = "git-crypt clean"
+	cfg.Filters["crypt"].Required = true
+	cfg.Filters["lfs"] = &Filter{Name: "lfs", Process: "git-lfs filter-process"}
+
+	actual, err := cfg.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual))
+}
+
+func TestFilterUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Unmarshal([]byte(`[filter "lfs"]
+	clean = git-lfs clean -- %f
+	smudge = git-lfs smudge -- %f
+	process = git-lfs filter-process
+	required = true
+`)))
+
+	f := cfg.Filters["lfs"]
+	require.NotNil(t, f)
+	assert.Equal(t, "lfs", f.Name)
+	assert.Equal(t, "git-lfs clean -- %f", f.Clean)
+	assert.Equal(t, "git-lfs smudge -- %f", f.Smudge)
+	assert.Equal(t, "git-lfs filter-process", f.Process)
+	assert.True(t, f.Required)
+	assert.NoError(t, cfg.Validate())
+}
@@ -199,9 +199,12 @@ func ResolveRef(st storage.Storer, name string, allowHash bool) (plumbing.Hash,
 	return plumbing.ZeroHash, fmt.Errorf("cannot resolve %q", name)
 }
 
-// WriteTarArchive writes a tar archive from a tree.
-func WriteTarArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, prefix string, pathFilter []string, modTime time.Time) error {
+// WriteTarArchive writes a tar archive from a tree. If attrs is not nil,
+// the paths with the export-ignore attribute are left out, and the
+// placeholders of the files with the export-subst one are expanded.
+func WriteTarArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, prefix string, pathFilter []string, modTime time.Time, attrs Attributes) error {
 	tw := tar.NewWriter(w)
+	export := newExportFilter(st, attrs, commitHash)
 
 	// Write PAX global extended header with commit ID if available.
 	// This matches the behavior of git archive and allows extraction
@@ -248,6 +251,10 @@ func WriteTarArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHa
 		}
 		matchedAny = true
 
+		if export.ignore(name, entry) {
+			continue
+		}
+
 		fullName := prefix + name
 
 		// Extract Unix permission bits from git mode.
@@ -307,6 +314,21 @@ func WriteTarArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHa
 		isExec := entry.Mode == filemode.Executable
 		hdr.Mode = ApplyUmask(unixMode, isExec)
 
+		if export.substitutes(name, entry) {
+			content, err := export.substitute(blob)
+			if err != nil {
+				return err
+			}
+			hdr.Size = int64(len(content))
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(content); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if err := tw.WriteHeader(hdr); err != nil {
 			return err
 		}
@@ -332,9 +354,12 @@ func WriteTarArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHa
 	return tw.Close()
 }
 
-// WriteZipArchive writes a zip archive from a tree.
-func WriteZipArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, prefix string, pathFilter []string, modTime time.Time) error {
+// WriteZipArchive writes a zip archive from a tree. If attrs is not nil,
+// the paths with the export-ignore attribute are left out, and the
+// placeholders of the files with the export-subst one are expanded.
+func WriteZipArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, prefix string, pathFilter []string, modTime time.Time, attrs Attributes) error {
 	zw := zip.NewWriter(w)
+	export := newExportFilter(st, attrs, commitHash)
 
 	walker := object.NewTreeWalker(tree, true, nil)
 	defer walker.Close()
@@ -354,6 +379,10 @@ func WriteZipArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHa
 		}
 		matchedAny = true
 
+		if export.ignore(name, entry) {
+			continue
+		}
+
 		if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
 			continue
 		}
@@ -387,6 +416,17 @@ func WriteZipArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHa
 			return err
 		}
 
+		if export.substitutes(name, entry) {
+			content, err := export.substitute(blob)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(content); err != nil {
+				return err
+			}
+			continue
+		}
+
 		rc, err := blob.Reader()
 		if err != nil {
 			return err
@@ -470,22 +510,24 @@ func GetTarCommitID(r io.Reader) (*plumbing.Hash, error) {
 // Supported formats: tar, zip, tar.gz, tgz.
 // The prefix is prepended to all file paths in the archive.
 // The paths slice can be used to filter which files are included.
-func WriteArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, commitTime time.Time, format, prefix string, paths []string) error {
+// If attrs is not nil, the export-ignore and export-subst attributes it
+// resolves are honored, as git does.
+func WriteArchive(st storage.Storer, w io.Writer, tree *object.Tree, commitHash *plumbing.Hash, commitTime time.Time, format, prefix string, paths []string, attrs Attributes) error {
 	if HasInvalidPrefix(prefix) {
 		return fmt.Errorf("%w: %s", ErrInvalidPrefix, prefix)
 	}
 
 	switch format {
 	case "tar":
-		return WriteTarArchive(st, w, tree, commitHash, prefix, paths, commitTime)
+		return WriteTarArchive(st, w, tree, commitHash, prefix, paths, commitTime, attrs)
 	case "tar.gz", "tgz":
 		gw := gzip.NewWriter(w)
-		if err := WriteTarArchive(st, gw, tree, commitHash, prefix, paths, commitTime); err != nil {
+		if err := WriteTarArchive(st, gw, tree, commitHash, prefix, paths, commitTime, attrs); err != nil {
 			return err
 		}
 		return gw.Close()
 	case "zip":
-		return WriteZipArchive(st, w, tree, commitHash, prefix, paths, commitTime)
+		return WriteZipArchive(st, w, tree, commitHash, prefix, paths, commitTime, attrs)
 	default:
 		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
 	}
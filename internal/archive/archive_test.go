@@ -1,7 +1,10 @@
 package archive
 
 import (
+	"archive/zip"
 	"bytes"
+	"io"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -490,12 +493,86 @@ func TestWriteTarArchive_RejectsOversizedSymlinkTarget(t *testing.T) {
 	require.NoError(t, err)
 
 	var buf bytes.Buffer
-	err = WriteTarArchive(st, &buf, tree, nil, "", nil, time.Now())
+	err = WriteTarArchive(st, &buf, tree, nil, "", nil, time.Now(), nil)
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrSymlinkTargetTooLarge)
 	assert.Contains(t, err.Error(), "link")
 }
 
+// exportAttributes sets export-ignore on the paths of ignore, and
+// export-subst on the ones of subst.
+type exportAttributes struct {
+	ignore, subst []string
+}
+
+func (a exportAttributes) ExportIgnore(path string) bool { return slices.Contains(a.ignore, path) }
+func (a exportAttributes) ExportSubst(path string) bool  { return slices.Contains(a.subst, path) }
+
+func TestWriteZipArchive_ExportAttributes(t *testing.T) {
+	t.Parallel()
+
+	st := memory.NewStorage()
+	setObject := func(o interface {
+		Encode(plumbing.EncodedObject) error
+	},
+	) plumbing.Hash {
+		obj := st.NewEncodedObject()
+		require.NoError(t, o.Encode(obj))
+		h, err := st.SetEncodedObject(obj)
+		require.NoError(t, err)
+		return h
+	}
+	setBlob := func(content string) plumbing.Hash {
+		obj := &plumbing.MemoryObject{}
+		obj.SetType(plumbing.BlobObject)
+		_, err := obj.Write([]byte(content))
+		require.NoError(t, err)
+		h, err := st.SetEncodedObject(obj)
+		require.NoError(t, err)
+		return h
+	}
+
+	content := "version $Format:%H$, $Format:%s$\n"
+	treeHash := setObject(&object.Tree{Entries: []object.TreeEntry{
+		{Name: "ignored", Mode: filemode.Regular, Hash: setBlob("ignored")},
+		{Name: "link", Mode: filemode.Symlink, Hash: setBlob("$Format:%H$")},
+		{Name: "version", Mode: filemode.Executable, Hash: setBlob(content)},
+	}})
+	commitHash := setObject(&object.Commit{
+		Author:    object.Signature{Name: "foo", Email: "foo@foo.foo"},
+		Committer: object.Signature{Name: "foo", Email: "foo@foo.foo"},
+		Message:   "Release\n",
+		TreeHash:  treeHash,
+	})
+	tree, err := object.GetTree(st, treeHash)
+	require.NoError(t, err)
+
+	files := func(commitHash *plumbing.Hash) map[string]string {
+		var buf bytes.Buffer
+		attrs := exportAttributes{ignore: []string{"ignored"}, subst: []string{"link", "version"}}
+		require.NoError(t, WriteZipArchive(st, &buf, tree, commitHash, "", nil, time.Now(), attrs))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		files := make(map[string]string)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	assert.Equal(t, map[string]string{
+		"link":    "$Format:%H$",
+		"version": "version " + commitHash.String() + ", Release\n",
+	}, files(&commitHash))
+	assert.Equal(t, content, files(nil)["version"])
+}
+
 func TestSupportedFormats(t *testing.T) {
 	t.Parallel()
 	formats := SupportedFormats()
@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/internal/pretty"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+// Attributes resolves the gitattributes of the archived paths that change
+// how they are archived.
+type Attributes interface {
+	// ExportIgnore reports whether the file or directory at path, relative
+	// to the archived tree, is left out of the archive.
+	ExportIgnore(path string) bool
+	// ExportSubst reports whether the $Format:...$ placeholders of the file
+	// at path are expanded.
+	ExportSubst(path string) bool
+}
+
+// exportFilter leaves out of an archive the paths having the export-ignore
+// attribute, and expands the placeholders of the files having the
+// export-subst one.
+type exportFilter struct {
+	st     storage.Storer
+	attrs  Attributes
+	commit *plumbing.Hash
+
+	// ignored is the last directory left out, whose entries follow it as
+	// the tree is walked depth first.
+	ignored string
+	opts    *pretty.Options
+}
+
+func newExportFilter(st storage.Storer, attrs Attributes, commitHash *plumbing.Hash) *exportFilter {
+	return &exportFilter{st: st, attrs: attrs, commit: commitHash}
+}
+
+// ignore reports whether the entry at name is left out of the archive.
+func (f *exportFilter) ignore(name string, entry object.TreeEntry) bool {
+	if f.attrs == nil {
+		return false
+	}
+
+	if f.ignored != "" && strings.HasPrefix(name, f.ignored+"/") {
+		return true
+	}
+
+	if !f.attrs.ExportIgnore(name) {
+		return false
+	}
+
+	if entry.Mode == filemode.Dir {
+		f.ignored = name
+	}
+
+	return true
+}
+
+// substitutes reports whether the placeholders of the file at name, whose
+// entry is entry, are expanded. As git, they are only expanded in regular
+// files, when archiving a commit.
+func (f *exportFilter) substitutes(name string, entry object.TreeEntry) bool {
+	if f.attrs == nil || f.commit == nil || !entry.Mode.IsFile() || entry.Mode == filemode.Symlink {
+		return false
+	}
+
+	return f.attrs.ExportSubst(name)
+}
+
+// substitute returns the content of blob with its $Format:...$
+// placeholders expanded as pretty formats of the archived commit.
+func (f *exportFilter) substitute(blob *object.Blob) ([]byte, error) {
+	rc, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(rc)
+	closeErr := rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+
+	commit, err := object.GetCommit(f.st, *f.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for {
+		start := bytes.Index(content, []byte("$Format:"))
+		if start < 0 {
+			break
+		}
+		end := bytes.IndexByte(content[start+len("$Format:"):], '$')
+		if end < 0 {
+			break
+		}
+		end += start + len("$Format:")
+
+		if f.opts == nil {
+			decorations, err := pretty.LoadDecorations(f.st)
+			if err != nil {
+				return nil, err
+			}
+			f.opts = &pretty.Options{Decorations: decorations, Objects: f.st}
+		}
+
+		out.Write(content[:start])
+		out.WriteString(pretty.Format(commit, string(content[start+len("$Format:"):end]), f.opts))
+		content = content[end+1:]
+	}
+	out.Write(content)
+
+	return out.Bytes(), nil
+}
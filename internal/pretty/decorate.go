@@ -0,0 +1,113 @@
+package pretty
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+type decoration struct {
+	name plumbing.ReferenceName
+	tag  bool
+}
+
+// LoadDecorations returns the names of the references pointing at each
+// commit of s, directly or through annotated tags, in the order git shows
+// them in %d: HEAD first, as "HEAD -> main" if it points at a branch of the
+// commit, and then the other references, in reverse order of their names.
+// Branches and remote-tracking branches are shortened, and tags prefixed
+// with "tag: ". Replace references are left out.
+func LoadDecorations(s storage.Storer) (map[plumbing.Hash][]string, error) {
+	iter, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() == plumbing.HEAD || strings.HasPrefix(ref.Name().String(), "refs/replace/") {
+			return nil
+		}
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(refs, func(a, b *plumbing.Reference) int {
+		return strings.Compare(a.Name().String(), b.Name().String())
+	})
+
+	decorations := make(map[plumbing.Hash][]decoration)
+	add := func(name plumbing.ReferenceName, h plumbing.Hash) {
+		d := decoration{name: name, tag: name.IsTag()}
+		decorations[h] = append(decorations[h], d)
+
+		// As git, tags decorate the objects they peel to.
+		for d.tag {
+			tag, err := object.GetTag(s, h)
+			if err != nil {
+				return
+			}
+			h = tag.Target
+			decorations[h] = append(decorations[h], d)
+		}
+	}
+
+	for _, ref := range refs {
+		resolved, err := storer.ResolveReference(s, ref.Name())
+		if err != nil {
+			continue
+		}
+		add(ref.Name(), resolved.Hash())
+	}
+
+	var current plumbing.ReferenceName
+	if head, err := s.Reference(plumbing.HEAD); err == nil {
+		if resolved, err := storer.ResolveReference(s, plumbing.HEAD); err == nil {
+			add(plumbing.HEAD, resolved.Hash())
+			if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+				current = head.Target()
+			}
+		}
+	}
+
+	names := make(map[plumbing.Hash][]string, len(decorations))
+	for h, ds := range decorations {
+		slices.Reverse(ds)
+
+		// The branch HEAD points at is shown along with it.
+		headHere := slices.ContainsFunc(ds, func(d decoration) bool { return d.name == plumbing.HEAD })
+		branchHere := headHere && slices.ContainsFunc(ds, func(d decoration) bool { return d.name == current })
+
+		for _, d := range ds {
+			switch {
+			case branchHere && d.name == current:
+			case branchHere && d.name == plumbing.HEAD:
+				names[h] = append(names[h], "HEAD -> "+shortName(current))
+			case d.tag:
+				names[h] = append(names[h], "tag: "+shortName(d.name))
+			default:
+				names[h] = append(names[h], shortName(d.name))
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// shortName returns name without its refs/heads/, refs/tags/ or
+// refs/remotes/ prefix, as git shows it in decorations.
+func shortName(name plumbing.ReferenceName) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(name.String(), prefix); ok {
+			return short
+		}
+	}
+	return name.String()
+}
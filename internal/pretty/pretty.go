@@ -0,0 +1,446 @@
+// Package pretty expands the placeholders of git pretty formats, as
+// `git log --format` does, for a commit.
+package pretty
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// DefaultAbbrev is the length of the abbreviated hashes when
+// Options.Abbrev is not set.
+const DefaultAbbrev = 7
+
+// Options are the options of Format.
+type Options struct {
+	// Abbrev is the minimum length of the abbreviated hashes of %h, %t
+	// and %p. Defaults to DefaultAbbrev.
+	Abbrev int
+	// Objects is the object storage the abbreviated hashes are unique in.
+	// As git, they are made longer than Abbrev when another object shares
+	// their prefix. If nil, they are always Abbrev long.
+	Objects storer.EncodedObjectStorer
+	// Decorations are the names of the references pointing at each
+	// commit, as returned by LoadDecorations, for %d and %D.
+	Decorations map[plumbing.Hash][]string
+	// Now is the time the relative dates of %ar and %cr are computed
+	// from. Defaults to the current time.
+	Now time.Time
+}
+
+// Format returns format with the placeholders it has expanded for c. It
+// supports the placeholders of git for hashes, identities, dates in the
+// default, RFC 2822, ISO 8601, short, relative and Unix formats, the
+// subject and body of the message, the decorations, literal characters,
+// and the +, - and space modifiers. Colors are not emitted. As git,
+// unknown placeholders are left as they are.
+func Format(c *object.Commit, format string, o *Options) string {
+	if o == nil {
+		o = &Options{}
+	}
+
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			sb.WriteString(format)
+			return sb.String()
+		}
+
+		sb.WriteString(format[:i])
+		format = format[i+1:]
+
+		n := expandModified(&sb, c, format, o)
+		if n == 0 {
+			sb.WriteByte('%')
+		}
+		format = format[n:]
+	}
+}
+
+// expandModified expands the placeholder at the start of format, following
+// a %, with its modifier if it has one, and returns the length of format it
+// consumed, or 0 if it is not a placeholder.
+func expandModified(sb *strings.Builder, c *object.Commit, format string, o *Options) int {
+	if format == "" {
+		return 0
+	}
+
+	modifier := format[0]
+	switch modifier {
+	case '+', '-', ' ':
+	default:
+		return expand(sb, c, format, o)
+	}
+
+	var out strings.Builder
+	n := expand(&out, c, format[1:], o)
+	if n == 0 {
+		return 0
+	}
+
+	switch {
+	case out.Len() == 0 && modifier == '-':
+		s := strings.TrimRight(sb.String(), "\n")
+		sb.Reset()
+		sb.WriteString(s)
+	case out.Len() > 0 && modifier == '+':
+		sb.WriteByte('\n')
+	case out.Len() > 0 && modifier == ' ':
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(out.String())
+
+	return n + 1
+}
+
+// expand expands the placeholder at the start of format, following a %, and
+// returns the length of format it consumed, or 0 if it is not a
+// placeholder.
+func expand(sb *strings.Builder, c *object.Commit, format string, o *Options) int {
+	if format == "" {
+		return 0
+	}
+
+	switch format[0] {
+	case '%':
+		sb.WriteByte('%')
+		return 1
+	case 'n':
+		sb.WriteByte('\n')
+		return 1
+	case 'x':
+		if len(format) < 3 {
+			return 0
+		}
+		b, err := hex.DecodeString(format[1:3])
+		if err != nil {
+			return 0
+		}
+		sb.Write(b)
+		return 3
+	case 'C':
+		return color(format)
+	case 'H':
+		sb.WriteString(c.Hash.String())
+		return 1
+	case 'h':
+		sb.WriteString(abbrev(c.Hash, o))
+		return 1
+	case 'T':
+		sb.WriteString(c.TreeHash.String())
+		return 1
+	case 't':
+		sb.WriteString(abbrev(c.TreeHash, o))
+		return 1
+	case 'P', 'p':
+		for i, h := range c.ParentHashes {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			if format[0] == 'P' {
+				sb.WriteString(h.String())
+			} else {
+				sb.WriteString(abbrev(h, o))
+			}
+		}
+		return 1
+	case 'a', 'c':
+		if len(format) < 2 {
+			return 0
+		}
+		sig := c.Author
+		if format[0] == 'c' {
+			sig = c.Committer
+		}
+		if !identity(sb, sig, format[1], o) {
+			return 0
+		}
+		return 2
+	case 'd', 'D':
+		names := o.Decorations[c.Hash]
+		if len(names) == 0 {
+			return 1
+		}
+		if format[0] == 'd' {
+			sb.WriteString(" (" + strings.Join(names, ", ") + ")")
+		} else {
+			sb.WriteString(strings.Join(names, ", "))
+		}
+		return 1
+	case 'm':
+		sb.WriteByte('>')
+		return 1
+	case 'e':
+		if c.Encoding != "" && c.Encoding != "UTF-8" {
+			sb.WriteString(string(c.Encoding))
+		}
+		return 1
+	case 's':
+		subject, _ := splitMessage(c.Message)
+		sb.WriteString(joinSubject(subject))
+		return 1
+	case 'f':
+		subject, _ := splitMessage(c.Message)
+		sb.WriteString(sanitizeSubject(subject))
+		return 1
+	case 'b':
+		_, body := splitMessage(c.Message)
+		sb.WriteString(body)
+		return 1
+	case 'B':
+		sb.WriteString(c.Message)
+		return 1
+	}
+
+	return 0
+}
+
+// color returns the length of the color placeholder at the start of format,
+// which expands to nothing, as colors are not enabled, or 0 if it is not
+// one.
+func color(format string) int {
+	if strings.HasPrefix(format, "C(") {
+		end := strings.IndexByte(format, ')')
+		if end < 0 {
+			return 0
+		}
+		return end + 1
+	}
+
+	for _, name := range []string{"red", "green", "blue", "reset"} {
+		if strings.HasPrefix(format[1:], name) {
+			return len(name) + 1
+		}
+	}
+
+	return 0
+}
+
+func abbrev(h plumbing.Hash, o *Options) string {
+	n := o.Abbrev
+	if n <= 0 {
+		n = DefaultAbbrev
+	}
+
+	s := h.String()
+	if o.Objects != nil {
+		n = max(n, uniqueAbbrev(o.Objects, h, n))
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+
+	return s[:n]
+}
+
+// uniqueAbbrev returns the length of the shortest abbreviation of h, of at
+// least n characters, that no other object of st starts with.
+func uniqueAbbrev(st storer.EncodedObjectStorer, h plumbing.Hash, n int) int {
+	s := h.String()
+	for _, other := range hashesWithPrefix(st, h.Bytes()[:n/2]) {
+		if other == h {
+			continue
+		}
+
+		o := other.String()
+		common := 0
+		for common < len(s) && common < len(o) && s[common] == o[common] {
+			common++
+		}
+		n = max(n, common+1)
+	}
+
+	return n
+}
+
+// hashesWithPrefix returns the hashes of the objects of st that start with
+// prefix.
+func hashesWithPrefix(st storer.EncodedObjectStorer, prefix []byte) []plumbing.Hash {
+	// The fast version is implemented by storage/filesystem.ObjectStorage.
+	type fastIter interface {
+		HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+	}
+	if fi, ok := st.(fastIter); ok {
+		hashes, err := fi.HashesWithPrefix(prefix)
+		if err != nil {
+			return nil
+		}
+		return hashes
+	}
+
+	iter, err := st.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil
+	}
+
+	var hashes []plumbing.Hash
+	_ = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		if h := obj.Hash(); h.HasPrefix(prefix) {
+			hashes = append(hashes, h)
+		}
+		return nil
+	})
+	return hashes
+}
+
+// identity writes the part of sig named by the placeholder p, following %a
+// or %c, and reports whether p is one. Mail maps are not supported, so the
+// placeholders honoring them are those ignoring them.
+func identity(sb *strings.Builder, sig object.Signature, p byte, o *Options) bool {
+	switch p {
+	case 'n', 'N':
+		sb.WriteString(sig.Name)
+	case 'e', 'E':
+		sb.WriteString(sig.Email)
+	case 'l', 'L':
+		local, _, _ := strings.Cut(sig.Email, "@")
+		sb.WriteString(local)
+	case 'd':
+		sb.WriteString(sig.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	case 'D':
+		sb.WriteString(sig.When.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	case 'i':
+		sb.WriteString(sig.When.Format("2006-01-02 15:04:05 -0700"))
+	case 'I':
+		sb.WriteString(sig.When.Format(time.RFC3339))
+	case 's':
+		sb.WriteString(sig.When.Format(time.DateOnly))
+	case 't':
+		fmt.Fprintf(sb, "%d", sig.When.Unix())
+	case 'r':
+		now := o.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		sb.WriteString(relativeDate(sig.When, now))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// relativeDate returns how long before now t is, rounded as git does.
+func relativeDate(t, now time.Time) string {
+	if now.Before(t) {
+		return "in the future"
+	}
+
+	diff := int64(now.Sub(t) / time.Second)
+	if diff < 90 {
+		return plural(diff, "second") + " ago"
+	}
+
+	diff = (diff + 30) / 60
+	if diff < 90 {
+		return plural(diff, "minute") + " ago"
+	}
+
+	diff = (diff + 30) / 60
+	if diff < 36 {
+		return plural(diff, "hour") + " ago"
+	}
+
+	diff = (diff + 12) / 24
+	switch {
+	case diff < 14:
+		return plural(diff, "day") + " ago"
+	case diff < 70:
+		return plural((diff+3)/7, "week") + " ago"
+	case diff < 365:
+		return plural((diff+15)/30, "month") + " ago"
+	case diff < 1825:
+		months := (diff*12*2 + 365) / (365 * 2)
+		if months%12 != 0 {
+			return plural(months/12, "year") + ", " + plural(months%12, "month") + " ago"
+		}
+		return plural(months/12, "year") + " ago"
+	}
+
+	return plural((diff+183)/365, "year") + " ago"
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// splitMessage returns the subject of message, its first paragraph once
+// leading blank lines are skipped, and its body, what follows the blank
+// lines after the subject.
+func splitMessage(message string) (subject, body string) {
+	message = skipBlankLines(message)
+
+	rest := message
+	for rest != "" {
+		line, next, _ := strings.Cut(rest, "\n")
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		rest = next
+	}
+
+	return message[:len(message)-len(rest)], skipBlankLines(rest)
+}
+
+func skipBlankLines(s string) string {
+	for s != "" {
+		line, next, _ := strings.Cut(s, "\n")
+		if strings.TrimSpace(line) != "" {
+			break
+		}
+		s = next
+	}
+	return s
+}
+
+// joinSubject joins the lines of subject with spaces, once trailing
+// whitespace is trimmed from them.
+func joinSubject(subject string) string {
+	lines := strings.Split(strings.TrimRight(subject, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r\v\f")
+	}
+	return strings.Join(lines, " ")
+}
+
+// sanitizeSubject returns subject fit for a file name, as git does for %f:
+// runs of characters other than letters, digits, dots and underscores are
+// replaced with a hyphen, runs of dots with a single one, and trailing dots
+// and hyphens are trimmed.
+func sanitizeSubject(subject string) string {
+	var sb strings.Builder
+	space := 2
+	for i := 0; i < len(subject); i++ {
+		ch := subject[i]
+		if !isTitleChar(ch) {
+			space |= 1
+			continue
+		}
+
+		if space == 1 {
+			sb.WriteByte('-')
+		}
+		space = 0
+		sb.WriteByte(ch)
+		for ch == '.' && i+1 < len(subject) && subject[i+1] == '.' {
+			i++
+		}
+	}
+
+	return strings.TrimRight(sb.String(), ".-")
+}
+
+func isTitleChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' ||
+		ch >= '0' && ch <= '9' || ch == '.' || ch == '_'
+}
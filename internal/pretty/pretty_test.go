@@ -0,0 +1,183 @@
+package pretty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+func testCommit() *object.Commit {
+	when := time.Date(2005, 4, 7, 15, 13, 13, 0, time.FixedZone("", -7*60*60))
+	return &object.Commit{
+		Hash:         plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+		TreeHash:     plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c"),
+		ParentHashes: []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"), plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")},
+		Author:       object.Signature{Name: "Jane Doe", Email: "jane@example.com", When: when},
+		Committer:    object.Signature{Name: "John Doe", Email: "john@example.com", When: when.Add(time.Hour)},
+		Message:      "\n\nFirst line\nsecond line  \n\n\nThe body.\n",
+		Encoding:     "UTF-8",
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"%H", "e8d3ffab552895c19b9fcf7aa264d277cde33881"},
+		{"%h %t", "e8d3ffa a8d315b"},
+		{"%P", "918c48b83bd081e863dbe1b80f8998f058cd8294 af2d6a6954d532f8ffb47615169c8fdf9d383a1a"},
+		{"%p", "918c48b af2d6a6"},
+		{"%an <%ae> %al", "Jane Doe <jane@example.com> jane"},
+		{"%cN %cE %cL", "John Doe john@example.com john"},
+		{"%ad", "Thu Apr 7 15:13:13 2005 -0700"},
+		{"%aD", "Thu, 7 Apr 2005 15:13:13 -0700"},
+		{"%ai", "2005-04-07 15:13:13 -0700"},
+		{"%aI", "2005-04-07T15:13:13-07:00"},
+		{"%as %at", "2005-04-07 1112911993"},
+		{"%cd", "Thu Apr 7 16:13:13 2005 -0700"},
+		{"%s", "First line second line"},
+		{"%f", "First-line-second-line"},
+		{"%b", "The body.\n"},
+		{"%B", "\n\nFirst line\nsecond line  \n\n\nThe body.\n"},
+		{"%e|%m", "|>"},
+		{"a%nb%%c%x41", "a\nb%cA"},
+		{"%Cred%h%Creset%C(bold blue)", "e8d3ffa"},
+		{"%z %q %x4 %", "%z %q %x4 %"},
+		{"a%+d|a%+s", "a|a\nFirst line second line"},
+		{"a\n\n%-d|% s", "a| First line second line"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Format(testCommit(), test.format, nil), test.format)
+	}
+
+	assert.Equal(t, "e8d3ffab55", Format(testCommit(), "%h", &Options{Abbrev: 10}))
+}
+
+// prefixStorage is a storage whose objects are the hashes of HashesWithPrefix.
+type prefixStorage struct {
+	*memory.Storage
+	hashes []plumbing.Hash
+}
+
+func (s *prefixStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	var hashes []plumbing.Hash
+	for _, h := range s.hashes {
+		if h.HasPrefix(prefix) {
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes, nil
+}
+
+func TestFormatUniqueAbbrev(t *testing.T) {
+	t.Parallel()
+
+	c := testCommit()
+	st := &prefixStorage{Storage: memory.NewStorage(), hashes: []plumbing.Hash{
+		c.Hash,
+		plumbing.NewHash("e8d3ffab5f000000000000000000000000000000"),
+		plumbing.NewHash("a8d315c000000000000000000000000000000000"),
+		plumbing.NewHash("918c48b83b000000000000000000000000000000"),
+	}}
+
+	assert.Equal(t, "e8d3ffab55 a8d315b 918c48b83bd af2d6a6", Format(c, "%h %t %p", &Options{Objects: st}))
+	assert.Equal(t, "e8d3ffab55 a8d315b2b", Format(c, "%h %t", &Options{Objects: st, Abbrev: 9}))
+
+	mst := memory.NewStorage()
+	obj := mst.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := mst.SetEncodedObject(obj)
+	require.NoError(t, err)
+	c.Hash = h
+	assert.Equal(t, h.String()[:7], Format(c, "%h", &Options{Objects: mst}))
+}
+
+func TestFormatDecorations(t *testing.T) {
+	t.Parallel()
+
+	c := testCommit()
+	o := &Options{Decorations: map[plumbing.Hash][]string{
+		c.Hash: {"HEAD -> main", "tag: v1.0"},
+	}}
+
+	assert.Equal(t, " (HEAD -> main, tag: v1.0)|HEAD -> main, tag: v1.0", Format(c, "%d|%D", o))
+	assert.Equal(t, "|", Format(c, "%d|%D", nil))
+}
+
+func TestFormatRelativeDate(t *testing.T) {
+	t.Parallel()
+
+	c := testCommit()
+	tests := []struct {
+		ago      time.Duration
+		expected string
+	}{
+		{-time.Hour, "in the future"},
+		{time.Second, "1 second ago"},
+		{89 * time.Second, "89 seconds ago"},
+		{45 * time.Minute, "45 minutes ago"},
+		{5 * time.Hour, "5 hours ago"},
+		{3 * 24 * time.Hour, "3 days ago"},
+		{30 * 24 * time.Hour, "4 weeks ago"},
+		{100 * 24 * time.Hour, "3 months ago"},
+		{400 * 24 * time.Hour, "1 year, 1 month ago"},
+		{730 * 24 * time.Hour, "2 years ago"},
+		{3650 * 24 * time.Hour, "10 years ago"},
+	}
+
+	for _, test := range tests {
+		o := &Options{Now: c.Author.When.Add(test.ago)}
+		assert.Equal(t, test.expected, Format(c, "%ar", o), test.ago)
+	}
+}
+
+func TestLoadDecorations(t *testing.T) {
+	t.Parallel()
+
+	s := memory.NewStorage()
+	c := testCommit()
+	other := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	tag := &object.Tag{
+		Name:       "v2.0",
+		Tagger:     c.Author,
+		Message:    "v2.0\n",
+		TargetType: plumbing.CommitObject,
+		Target:     c.Hash,
+	}
+	obj := s.NewEncodedObject()
+	require.NoError(t, tag.Encode(obj))
+	tagHash, err := s.SetEncodedObject(obj)
+	require.NoError(t, err)
+
+	for _, ref := range []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/main"),
+		plumbing.NewHashReference("refs/heads/main", c.Hash),
+		plumbing.NewHashReference("refs/heads/dev", other),
+		plumbing.NewHashReference("refs/remotes/origin/main", c.Hash),
+		plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/main"),
+		plumbing.NewHashReference("refs/tags/v1.0", c.Hash),
+		plumbing.NewHashReference("refs/tags/v2.0", tagHash),
+		plumbing.NewHashReference("refs/stash", other),
+		plumbing.NewHashReference(plumbing.ReferenceName("refs/replace/"+other.String()), c.Hash),
+	} {
+		require.NoError(t, storer.ReferenceStorer(s).SetReference(ref))
+	}
+
+	decorations, err := LoadDecorations(s)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HEAD -> main", "tag: v2.0", "tag: v1.0", "origin/main", "origin/HEAD"}, decorations[c.Hash])
+	assert.Equal(t, []string{"refs/stash", "dev"}, decorations[other])
+	assert.Equal(t, []string{"tag: v2.0"}, decorations[tagHash])
+}
@@ -0,0 +1,162 @@
+// Package lfs provides an in-process Git LFS server for testing.
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const mediaType = "application/vnd.git-lfs+json"
+
+// Server is a Git LFS server keeping the objects in memory. It implements
+// the batch API with the basic transfer adapter, serving the objects from
+// the objects/<oid> path of the endpoint the batch request was sent to.
+type Server struct {
+	// Username and Password, when set, are required to access the server
+	// with basic authentication.
+	Username, Password string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests []string
+}
+
+// NewServer returns a server without objects.
+func NewServer() *Server {
+	return &Server{objects: make(map[string][]byte)}
+}
+
+// Add adds content to the server, and returns its oid.
+func (s *Server) Add(content []byte) string {
+	sum := sha256.Sum256(content)
+	oid := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[oid] = content
+
+	return oid
+}
+
+// Object returns the content of the object oid, if the server has it.
+func (s *Server) Object(oid string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.objects[oid]
+	return content, ok
+}
+
+// Requests returns the method and path of the requests served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+type object struct {
+	OID     string             `json:"oid"`
+	Size    int64              `json:"size"`
+	Actions map[string]*action `json:"actions,omitempty"`
+	Error   *objectError       `json:"error,omitempty"`
+}
+
+type action struct {
+	Href string `json:"href"`
+}
+
+type objectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	if s.Username != "" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != s.Username || pass != s.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="lfs"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	prefix, oid, ok := strings.Cut(r.URL.Path, "/objects/")
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case oid == "batch" && r.Method == http.MethodPost:
+		s.batch(w, r, "http://"+r.Host+prefix)
+	case oid == "verify" && r.Method == http.MethodPost:
+		s.verify(w, r)
+	case r.Method == http.MethodGet:
+		content, ok := s.Object(oid)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	case r.Method == http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.Add(content) != oid {
+			http.Error(w, "oid mismatch", http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) batch(w http.ResponseWriter, r *http.Request, base string) {
+	var req struct {
+		Operation string    `json:"operation"`
+		Objects   []*object `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, o := range req.Objects {
+		href := base + "/objects/" + o.OID
+		_, has := s.Object(o.OID)
+		switch {
+		case req.Operation == "download" && has:
+			o.Actions = map[string]*action{"download": {Href: href}}
+		case req.Operation == "download":
+			o.Error = &objectError{Code: http.StatusNotFound, Message: "object does not exist"}
+		case req.Operation == "upload" && !has:
+			o.Actions = map[string]*action{
+				"upload": {Href: href},
+				"verify": {Href: base + "/objects/verify"},
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	_ = json.NewEncoder(w).Encode(map[string]any{"transfer": "basic", "objects": req.Objects})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) {
+	var o object
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if content, ok := s.Object(o.OID); !ok || int64(len(content)) != o.Size {
+		http.NotFound(w, r)
+	}
+}
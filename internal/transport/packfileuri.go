@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// fetchPackfileURIs downloads into st the packs a server offloaded to the URIs
+// of a packfile-uris section, with client, or http.DefaultClient when nil.
+// Each pack is checked against the hash the server listed it with, which is
+// the checksum its trailer holds, before it is stored, so that a bad download
+// leaves nothing behind.
+func fetchPackfileURIs(ctx context.Context, st storage.Storer, client HTTPClient, uris *packp.PackfileURIs, promisor bool) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for _, line := range uris.URIs {
+		hash, uri, err := packp.ParsePackfileURI(line)
+		if err != nil {
+			return err
+		}
+		if err := fetchPackfileURI(ctx, st, client, hash, uri, promisor); err != nil {
+			return fmt.Errorf("fetching packfile %s: %w", uri, err)
+		}
+	}
+	return nil
+}
+
+func fetchPackfileURI(ctx context.Context, st storage.Storer, client HTTPClient, hash plumbing.Hash, uri string, promisor bool) (err error) {
+	f, err := downloadPackfile(ctx, client, hash, uri)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ioutil.CheckClose(f, &err)
+		_ = os.Remove(f.Name())
+	}()
+
+	if promisor {
+		return packfile.UpdatePromisorObjectStorage(st, f, "")
+	}
+	return packfile.UpdateObjectStorage(st, f)
+}
+
+// downloadPackfile downloads the pack at uri into a temporary file, checking
+// that its checksum is hash, and returns the file positioned at its start.
+func downloadPackfile(ctx context.Context, client HTTPClient, hash plumbing.Hash, uri string) (_ *os.File, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(res.Body, &err)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	f, err := os.CreateTemp("", "go-git-packfile-uri-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	tr := &trailerReader{r: res.Body, trailer: make([]byte, 0, hash.Size())}
+	if _, err := io.Copy(f, ioutil.NewContextReader(ctx, tr)); err != nil {
+		return nil, err
+	}
+	if got := tr.Trailer(); hash.Compare(got) != 0 {
+		return nil, fmt.Errorf("packfile checksum mismatch: expected %s, got %x", hash, got)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// trailerReader keeps the last cap(trailer) bytes read through it, which are
+// the checksum of a packfile once it was read to the end.
+type trailerReader struct {
+	r       io.Reader
+	trailer []byte
+}
+
+func (t *trailerReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	size := cap(t.trailer)
+	if n >= size {
+		t.trailer = append(t.trailer[:0], p[n-size:n]...)
+	} else if n > 0 {
+		keep := max(0, len(t.trailer)+n-size)
+		t.trailer = append(t.trailer[:0], t.trailer[keep:]...)
+		t.trailer = append(t.trailer, p[:n]...)
+	}
+	return n, err
+}
+
+// Trailer returns the last bytes read.
+func (t *trailerReader) Trailer() []byte {
+	return t.trailer
+}
@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// countingClient is an HTTPClient counting the requests it sends.
+type countingClient struct {
+	requests int
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultClient.Do(req)
+}
+
+func TestFetchPackfileURIs(t *testing.T) {
+	t.Parallel()
+
+	f := fixtures.Basic().One()
+	pf, err := f.Packfile()
+	require.NoError(t, err)
+	pack, err := io.ReadAll(pf)
+	require.NoError(t, err)
+	require.NoError(t, pf.Close())
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(pack)
+	}))
+	t.Cleanup(hs.Close)
+
+	countObjects := func(st *memory.Storage) int {
+		iter, err := st.IterEncodedObjects(plumbing.AnyObject)
+		require.NoError(t, err)
+		var n int
+		require.NoError(t, iter.ForEach(func(plumbing.EncodedObject) error {
+			n++
+			return nil
+		}))
+		return n
+	}
+
+	// A pack not matching the hash it is listed with is not stored.
+	st := memory.NewStorage()
+	client := &countingClient{}
+	uris := &packp.PackfileURIs{URIs: []string{plumbing.ZeroHash.String() + " " + hs.URL + "/pack"}}
+	err = fetchPackfileURIs(context.Background(), st, client, uris, false)
+	require.ErrorContains(t, err, "checksum mismatch")
+	assert.Equal(t, 1, client.requests)
+	assert.Zero(t, countObjects(st))
+
+	uris = &packp.PackfileURIs{URIs: []string{f.PackfileHash + " " + hs.URL + "/pack"}}
+	require.NoError(t, fetchPackfileURIs(context.Background(), st, client, uris, false))
+	assert.Equal(t, 2, client.requests)
+	assert.Equal(t, 31, countObjects(st))
+}
@@ -293,6 +293,43 @@ func (s *UploadPackSuite) testUploadPackFetch(req *transport.FetchRequest, expec
 	s.Require().Equal(expectedObjects, afterCount)
 }
 
+// TestUploadPackDeepenNot tests a shallow fetch excluding the history of a
+// branch.
+func (s *UploadPackSuite) TestUploadPackDeepenNot() {
+	req := &transport.FetchRequest{}
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	req.DeepenNot = append(req.DeepenNot, "refs/heads/branch")
+	s.testUploadPackShallow(req, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+}
+
+// TestUploadPackDeepenRelative tests deepening a shallow fetch from its
+// current boundary.
+func (s *UploadPackSuite) TestUploadPackDeepenRelative() {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	req := &transport.FetchRequest{Depth: 1}
+	req.Wants = append(req.Wants, head)
+	s.testUploadPackShallow(req, head)
+
+	req = &transport.FetchRequest{Depth: 2, DeepenRelative: true}
+	req.Wants = append(req.Wants, head)
+	req.Haves = append(req.Haves, head)
+	s.testUploadPackShallow(req, plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"))
+}
+
+func (s *UploadPackSuite) testUploadPackShallow(req *transport.FetchRequest, expectedShallows ...plumbing.Hash) {
+	pc := s.packClient()
+	conn, err := pc.Handshake(context.TODO(), s.request(s.Endpoint, transport.UploadPackService))
+	s.Require().NoError(err)
+	defer func() { s.Require().NoError(conn.Close()) }()
+
+	err = conn.Fetch(context.Background(), s.EmptyStorer, req)
+	s.Require().NoError(err)
+
+	shallows, err := s.EmptyStorer.Shallow()
+	s.Require().NoError(err)
+	s.Require().ElementsMatch(expectedShallows, shallows)
+}
+
 // TestFetchError tests that fetching a non-existent object returns an error.
 func (s *UploadPackSuite) TestFetchError() {
 	pc := s.packClient()
@@ -4,6 +4,8 @@
 package transport
 
 import (
+	"net/http"
+
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
@@ -27,13 +29,119 @@ type FetchRequest struct {
 	// ancestors. Once implemented, remove this field.
 	Haves []plumbing.Hash
 
+	// Negotiator, when set, chooses the haves in place of Haves, learning
+	// from the commits the server acknowledges as common.
+	Negotiator Negotiator
+
 	// Depth is the depth of the fetch.
 	Depth int
 
+	// DeepenRelative counts Depth from the current shallow boundary rather
+	// than from the wanted commits, deepening a shallow repository by Depth
+	// commits, as git fetch --deepen does.
+	DeepenRelative bool
+
+	// DeepenNot bounds a shallow fetch at the history reachable from these
+	// references or objects of the remote, as git fetch --shallow-exclude
+	// does. It cannot be combined with Depth.
+	DeepenNot []string
+
 	// Filter holds the filters to be applied when deciding what
 	// objects will be added to the packfile.
 	Filter packp.Filter
 
 	// IncludeTags indicates whether tags should be fetched.
 	IncludeTags bool
+
+	// Promisor records the packfile as coming from a promisor remote even
+	// when no Filter is set, as the objects fetched lazily into a partial
+	// clone are.
+	Promisor bool
+
+	// PackfileURIs lists the protocols, such as "https", of the URIs the
+	// server may offload parts of the packfile to. The packs are downloaded
+	// from those URIs once the packfile is received. They are only requested
+	// from a protocol v2 server advertising packfile-uris.
+	PackfileURIs []string
+
+	// HTTPClient sends the requests downloading the packs of PackfileURIs,
+	// so that they are made with the proxy, TLS and authentication settings
+	// of the remote. When nil, http.DefaultClient is used.
+	HTTPClient HTTPClient
+}
+
+// HTTPClient sends HTTP requests, as *http.Client and the HTTP transport do.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Negotiator chooses the commits a fetch tells the server it has, as git's
+// fetch negotiation algorithms do.
+type Negotiator interface {
+	// Next returns the next commit to send as a have, or false when there
+	// are none left.
+	Next() (plumbing.Hash, bool)
+	// Ack records that the server has the given commit, and so all its
+	// ancestors, in common with the client.
+	Ack(plumbing.Hash)
+}
+
+// Haves yields the haves of a fetch request, from its Negotiator when set
+// and otherwise from the end of its Haves.
+type Haves struct {
+	negotiator Negotiator
+	haves      []plumbing.Hash
+	exhausted  bool
+}
+
+// NewHaves returns the haves of req, leaving req untouched.
+func NewHaves(req *FetchRequest) *Haves {
+	if req.Negotiator != nil {
+		return &Haves{negotiator: req.Negotiator}
+	}
+	haves := append([]plumbing.Hash(nil), req.Haves...)
+	return &Haves{haves: haves, exhausted: len(haves) == 0}
+}
+
+// Next returns the next have, or false when there are none left.
+func (h *Haves) Next() (plumbing.Hash, bool) {
+	if h.exhausted {
+		return plumbing.ZeroHash, false
+	}
+
+	if h.negotiator != nil {
+		have, ok := h.negotiator.Next()
+		h.exhausted = !ok
+		return have, ok
+	}
+
+	have := h.haves[len(h.haves)-1]
+	h.haves = h.haves[:len(h.haves)-1]
+	h.exhausted = len(h.haves) == 0
+	return have, true
+}
+
+// Exhausted reports whether there are no haves left to send. A Negotiator
+// is only known to be exhausted once Next returned false.
+func (h *Haves) Exhausted() bool {
+	return h.exhausted
+}
+
+// Ack records a commit the server acknowledged as common.
+func (h *Haves) Ack(hash plumbing.Hash) {
+	if h.negotiator != nil && !hash.IsZero() {
+		h.negotiator.Ack(hash)
+	}
+}
+
+// IsShallow reports whether r asks for a shallow fetch, whose response
+// carries the shallow boundary.
+func (r *FetchRequest) IsShallow() bool {
+	return r.Depth > 0 || len(r.DeepenNot) > 0
+}
+
+// IsPromisor reports whether the packfile fetched for r is recorded as
+// coming from a promisor remote.
+func (r *FetchRequest) IsPromisor() bool {
+	return r.Filter != "" || r.Promisor
 }
@@ -75,6 +75,18 @@ func FetchSupports(server capability.List, feature string) bool {
 	return false
 }
 
+// SupportedFetchRequest returns req without the optional features the server
+// did not advertise and that a fetch does without, such as packfile-uris, so
+// they are not requested from it.
+func SupportedFetchRequest(server capability.List, req *FetchRequest) *FetchRequest {
+	if len(req.PackfileURIs) > 0 && !FetchSupports(server, "packfile-uris") {
+		r := *req
+		r.PackfileURIs = nil
+		return &r
+	}
+	return req
+}
+
 // lsRefsSupportsUnborn reports whether the server advertised the ls-refs
 // "unborn" feature (ls-refs=unborn).
 func lsRefsSupportsUnborn(server capability.List) bool {
@@ -148,25 +160,29 @@ type FetchRound func(args *packp.FetchArgs) (out *packp.FetchOutput, packReader
 // streamed here, and any shallow-info from the response is applied to st.
 //
 // The caller is responsible for validating optional features against the server
-// advertisement (see FetchSupports) before requesting Filter or Depth.
+// advertisement (see FetchSupports) before requesting Filter, Depth or
+// DeepenNot.
 func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round FetchRound) error {
 	// Everything wanted is already local and no shallow change was requested:
 	// short-circuit before opening negotiation, matching git's everything_local.
-	if req.Depth == 0 && wantsLocal(req.Wants, req.Haves) {
+	if !req.IsShallow() && wantsLocal(req.Wants, req.Haves) {
 		return ErrNoChange
 	}
 
 	baseArgs := &packp.FetchArgs{
-		Wants:      req.Wants,
-		OFSDelta:   true,
-		NoProgress: req.Progress == nil,
-		IncludeTag: req.IncludeTags,
+		Wants:        req.Wants,
+		OFSDelta:     true,
+		NoProgress:   req.Progress == nil,
+		IncludeTag:   req.IncludeTags,
+		PackfileURIs: req.PackfileURIs,
 	}
 	if req.Filter != "" {
 		baseArgs.Filter = req.Filter
 	}
-	if req.Depth > 0 {
+	if req.IsShallow() {
 		baseArgs.Deepen = req.Depth
+		baseArgs.DeepenRelative = req.DeepenRelative && req.Depth > 0
+		baseArgs.DeepenNot = req.DeepenNot
 		shallows, err := st.Shallow()
 		if err != nil {
 			return err
@@ -174,8 +190,7 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 		baseArgs.Shallows = shallows
 	}
 
-	// Pop haves from a private copy so the caller's slice is left untouched.
-	remaining := append([]plumbing.Hash(nil), req.Haves...)
+	remaining := NewHaves(req)
 	var common []plumbing.Hash
 	seen := make(map[plumbing.Hash]struct{})
 	havesToSend := initialFlush
@@ -183,15 +198,19 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 	seenAck := false
 
 	var shallowInfo *packp.ShallowUpdate
+	var packfileURIs *packp.PackfileURIs
 	for {
 		args := *baseArgs
 		// v2 fetch is stateless per command: re-send every common commit acked
 		// so far, then a fresh batch of haves.
 		roundHaves := append([]plumbing.Hash(nil), common...)
 		batch := 0
-		for batch < havesToSend && len(remaining) > 0 {
-			roundHaves = append(roundHaves, remaining[len(remaining)-1])
-			remaining = remaining[:len(remaining)-1]
+		for batch < havesToSend {
+			have, ok := remaining.Next()
+			if !ok {
+				break
+			}
+			roundHaves = append(roundHaves, have)
 			batch++
 			inVain++
 		}
@@ -213,6 +232,7 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 
 		if out.Acknowledgments != nil {
 			for _, ack := range out.Acknowledgments.ACKs {
+				remaining.Ack(ack)
 				if _, ok := seen[ack]; !ok {
 					seen[ack] = struct{}{}
 					common = append(common, ack)
@@ -223,7 +243,8 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 		}
 
 		if out.Packfile {
-			streamErr := streamPackfile(ctx, st, packReader, req.Progress, req.Filter)
+			packfileURIs = out.PackfileURIs
+			streamErr := streamPackfile(ctx, st, packReader, req.Progress, req.IsPromisor())
 			// Skip draining/closing on cancellation: streamPackfile wraps
 			// packReader in a NewContextReader, whose background goroutine
 			// can still be blocked in the underlying Read after the
@@ -256,6 +277,12 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 		}
 	}
 
+	if packfileURIs != nil {
+		if err := fetchPackfileURIs(ctx, st, req.HTTPClient, packfileURIs, req.IsPromisor()); err != nil {
+			return err
+		}
+	}
+
 	if shallowInfo != nil {
 		if err := updateShallow(st, shallowInfo); err != nil {
 			return err
@@ -267,16 +294,17 @@ func FetchV2(ctx context.Context, st storage.Storer, req *FetchRequest, round Fe
 
 // streamPackfile demultiplexes the sideband-64k packfile stream into st.
 //
-// A non-empty filter means the server withheld the objects it matched, so the
-// pack is recorded as coming from a promisor remote. Git reads unmarked
-// absences as corruption: fsck reports broken links to them and gc fails.
-func streamPackfile(ctx context.Context, st storage.Storer, packReader io.Reader, progress sideband.Progress, filter packp.Filter) error {
+// A promisor fetch, such as a filtered one whose server withheld the objects
+// the filter matched, is recorded as coming from a promisor remote. Git reads
+// unmarked absences as corruption: fsck reports broken links to them and gc
+// fails.
+func streamPackfile(ctx context.Context, st storage.Storer, packReader io.Reader, progress sideband.Progress, promisor bool) error {
 	reader := ioutil.NewContextReader(ctx, packReader)
 	demuxer := sideband.NewDemuxer(sideband.Sideband64k, reader)
 	if progress != nil {
 		demuxer.Progress = progress
 	}
-	if filter != "" {
+	if promisor {
 		// The marker is left empty. Git fills it with the refs it sought on
 		// this path and leaves it empty when repacking, and accepts either,
 		// because only the file's presence is ever consulted.
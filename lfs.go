@@ -0,0 +1,259 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/client"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/filter"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// lfsFilterDriver is the filter driver of the files stored with Git LFS,
+// which is built in.
+const lfsFilterDriver = "lfs"
+
+// lfsSkipSmudgeEnv leaves the pointer files whose content is not in the LFS
+// store in the worktree, rather than downloading it, as Git LFS does.
+const lfsSkipSmudgeEnv = "GIT_LFS_SKIP_SMUDGE"
+
+// lfsStore returns the LFS store of s, or nil if s is not a filesystem
+// storage.
+func lfsStore(s storage.Storer) *lfs.Store {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+	st, ok := unwrapPromisor(s).(fsBased)
+	if !ok {
+		return nil
+	}
+
+	return lfs.NewStore(st.Filesystem())
+}
+
+// runLFS runs command, filter.Clean or filter.Smudge, of the LFS filter
+// driver on content.
+func (f *filters) runLFS(command string, content []byte) ([]byte, error) {
+	store := lfsStore(f.w.r.Storer)
+	if command == filter.Clean {
+		return lfsClean(store, content)
+	}
+
+	return f.lfsSmudge(store, content)
+}
+
+// lfsClean stores content in store, and returns its pointer file. As Git
+// LFS, empty files and pointer files are kept as they are.
+func lfsClean(store *lfs.Store, content []byte) ([]byte, error) {
+	if len(content) == 0 {
+		return content, nil
+	}
+	if _, err := lfs.DecodePointer(content); err == nil {
+		return content, nil
+	}
+
+	p, err := store.Clean(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Encode(), nil
+}
+
+// lfsSmudge returns the content of the pointer file content, downloading it
+// into store first if it is not there. Content that is not a pointer file
+// is kept as it is.
+func (f *filters) lfsSmudge(store *lfs.Store, content []byte) (_ []byte, err error) {
+	p, err := lfs.DecodePointer(content)
+	if err != nil {
+		return content, nil
+	}
+
+	if !store.Has(p) {
+		if os.Getenv(lfsSkipSmudgeEnv) == "1" {
+			return content, nil
+		}
+		if err := f.lfsDownload(store, p); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := store.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(r, &err)
+
+	return io.ReadAll(r)
+}
+
+// lfsDownload downloads the content of p into store from the LFS server of
+// the remote of the current branch, or else of origin, as Git LFS does.
+func (f *filters) lfsDownload(store *lfs.Store, p *lfs.Pointer) error {
+	if f.lfs == nil {
+		c, err := f.w.r.lfsDownloadClient()
+		if err != nil {
+			return fmt.Errorf("lfs: cannot download %s: %w", p, err)
+		}
+		f.lfs = c
+	}
+
+	return f.lfs.Download(f.ctx, store, p)
+}
+
+// lfsDownloadClient returns the client of the LFS server content is
+// downloaded from.
+func (r *Repository) lfsDownloadClient() (*lfs.Client, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	name := DefaultRemoteName
+	if head, err := r.Storer.Reference(plumbing.HEAD); err == nil && head.Type() == plumbing.SymbolicReference {
+		if b, ok := cfg.Branches[head.Target().Short()]; ok && b.Remote != "" {
+			name = b.Remote
+		}
+	}
+
+	rc, ok := cfg.Remotes[name]
+	if !ok || len(rc.URLs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteNotFound, name)
+	}
+
+	remote := NewRemote(r.Storer, rc)
+	endpoint, err := remote.lfsEndpoint(rc.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, fmt.Errorf("no LFS server for remote %s", name)
+	}
+
+	return remote.lfsClient(endpoint, nil)
+}
+
+// lfsEndpoint returns the endpoint of the LFS server of the remote at rawURL:
+// the one set with remote.<name>.lfsurl or lfs.url, or else the one derived
+// from rawURL. It returns nil if there is none, which is the case of the
+// remotes that are not served over HTTP or SSH.
+func (r *Remote) lfsEndpoint(rawURL string) (*url.URL, error) {
+	cfgs, err := rawConfigs(r.s)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := rawOption(cfgs, "lfs", "url")
+	for _, cfg := range cfgs {
+		if cfg == nil || !cfg.HasSection("remote") {
+			continue
+		}
+		if v := cfg.Section("remote").Subsection(r.c.Name).Option("lfsurl"); v != "" {
+			endpoint = v
+		}
+	}
+	if endpoint != "" {
+		return url.Parse(endpoint)
+	}
+
+	u, err := lfs.Endpoint(rawURL)
+	if err != nil {
+		return nil, nil //nolint:nilerr // the remote has no LFS server
+	}
+	return u, nil
+}
+
+// lfsClient returns the client of the LFS server at endpoint, sending its
+// requests with the HTTP transport configured for it as for a remote, with
+// opts taking precedence.
+func (r *Remote) lfsClient(endpoint *url.URL, opts []client.Option) (*lfs.Client, error) {
+	opts, err := r.clientOptions(endpoint.String(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tr, err := client.New(opts...).Transport(endpoint.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	htr, ok := tr.(*xhttp.Transport)
+	if !ok {
+		return nil, fmt.Errorf("lfs: the %s transport does not send HTTP requests", endpoint.Scheme)
+	}
+
+	return lfs.NewClient(endpoint, htr), nil
+}
+
+// uploadLFSObjects uploads the LFS content of the pointer files among the
+// objects of hashes to the LFS server of the remote at rawURL, before they
+// are pushed, as the pre-push hook of Git LFS does. Nothing is uploaded,
+// and the objects are not read, if the storage has no LFS content, or the
+// remote no LFS server.
+func (r *Remote) uploadLFSObjects(ctx context.Context, rawURL string, opts []client.Option, hashes []plumbing.Hash) error {
+	store := lfsStore(r.s)
+	if store == nil || store.Empty() {
+		return nil
+	}
+
+	pointers, err := r.lfsPointers(hashes)
+	if err != nil || len(pointers) == 0 {
+		return err
+	}
+
+	endpoint, err := r.lfsEndpoint(rawURL)
+	if err != nil || endpoint == nil {
+		return err
+	}
+
+	c, err := r.lfsClient(endpoint, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.Upload(ctx, store, pointers...)
+}
+
+// lfsPointers returns the pointers of the blobs of hashes that are pointer
+// files.
+func (r *Remote) lfsPointers(hashes []plumbing.Hash) ([]*lfs.Pointer, error) {
+	var pointers []*lfs.Pointer
+	for _, h := range hashes {
+		obj, err := r.s.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return nil, err
+		}
+		if obj.Type() != plumbing.BlobObject || obj.Size() > lfs.MaxPointerSize {
+			continue
+		}
+
+		content, err := readEncodedObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		if p, err := lfs.DecodePointer(content); err == nil {
+			pointers = append(pointers, p)
+		}
+	}
+
+	return pointers, nil
+}
+
+func readEncodedObject(obj plumbing.EncodedObject) (_ []byte, err error) {
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(r, &err)
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/internal/server/lfs"
+	xlfs "github.com/go-git/go-git/v6/plumbing/lfs"
+)
+
+const lfsAttributes = "*.bin filter=lfs diff=lfs merge=lfs -text\n"
+
+// newLFSServer starts a stand-in LFS server, and returns it with its URL.
+func newLFSServer(t *testing.T) (*lfs.Server, string) {
+	t.Helper()
+
+	srv := lfs.NewServer()
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+
+	return srv, hs.URL
+}
+
+func lfsPointerFile(t *testing.T, content string) string {
+	t.Helper()
+
+	p, err := xlfs.NewPointer(strings.NewReader(content))
+	require.NoError(t, err)
+	return string(p.Encode())
+}
+
+func TestLFSAddAndCheckout(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": lfsAttributes,
+		"a.bin":          "large content\n",
+		"empty.bin":      "",
+		"b.txt":          "text\n",
+	})
+
+	h, err := w.Add("a.bin")
+	require.NoError(t, err)
+	pointer := lfsPointerFile(t, "large content\n")
+	assert.Equal(t, pointer, readBlob(t, r, h))
+
+	h, err = w.Add("empty.bin")
+	require.NoError(t, err)
+	assert.Empty(t, readBlob(t, r, h))
+
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	checkoutAgain(t, w, "a.bin")
+	assert.Equal(t, "large content\n", readWorktreeFile(t, w, "a.bin"))
+
+	// Pointer files are stored as they are.
+	require.NoError(t, w.Filesystem().Remove("a.bin"))
+	h, err = w.Add("a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, pointer, readBlob(t, r, h))
+}
+
+// newLFSPointerRepository returns a repository with a commit of the pointer
+// file a.bin, whose content is not in the LFS store, and an origin remote
+// at url.
+func newLFSPointerRepository(t *testing.T, url, content string) (*Repository, *Worktree) {
+	t.Helper()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": lfsAttributes,
+		"a.bin":          lfsPointerFile(t, content),
+	})
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err := w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	require.NoError(t, err)
+
+	return r, w
+}
+
+func TestLFSCheckoutDownload(t *testing.T) {
+	t.Parallel()
+
+	srv, url := newLFSServer(t)
+	srv.Add([]byte("large content\n"))
+	_, w := newLFSPointerRepository(t, url+"/repo", "large content\n")
+
+	checkoutAgain(t, w, "a.bin")
+	assert.Equal(t, "large content\n", readWorktreeFile(t, w, "a.bin"))
+	assert.Contains(t, srv.Requests(), "POST /repo.git/info/lfs/objects/batch")
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+}
+
+func TestLFSCheckoutMissing(t *testing.T) {
+	t.Parallel()
+
+	_, url := newLFSServer(t)
+	_, w := newLFSPointerRepository(t, url+"/repo", "large content\n")
+
+	require.NoError(t, w.Filesystem().Remove("a.bin"))
+	err := w.Reset(&ResetOptions{Mode: HardReset})
+	require.ErrorIs(t, err, xlfs.ErrObjectNotFound)
+}
+
+func TestLFSCheckoutSkipSmudge(t *testing.T) { //nolint:paralleltest // modifies the environment
+	t.Setenv(lfsSkipSmudgeEnv, "1")
+
+	srv, url := newLFSServer(t)
+	_, w := newLFSPointerRepository(t, url+"/repo", "large content\n")
+
+	checkoutAgain(t, w, "a.bin")
+	assert.Equal(t, lfsPointerFile(t, "large content\n"), readWorktreeFile(t, w, "a.bin"))
+	assert.Empty(t, srv.Requests())
+}
+
+func TestLFSPush(t *testing.T) {
+	t.Parallel()
+
+	srv, url := newLFSServer(t)
+	dir := t.TempDir()
+	server, err := PlainInit(dir, true)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": lfsAttributes,
+		"a.bin":          "large content\n",
+		"b.bin":          lfsPointerFile(t, "remote content\n"),
+	})
+	srv.Add([]byte("remote content\n"))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{dir},
+	})
+	require.NoError(t, err)
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Raw.Section("remote").Subsection(DefaultRemoteName).SetOption("lfsurl", url+"/lfs")
+	require.NoError(t, r.SetConfig(cfg))
+
+	require.NoError(t, r.Push(&PushOptions{RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"}}))
+
+	p, err := xlfs.NewPointer(strings.NewReader("large content\n"))
+	require.NoError(t, err)
+	content, ok := srv.Object(p.OID)
+	require.True(t, ok)
+	assert.Equal(t, "large content\n", string(content))
+	assert.Equal(t, []string{
+		"POST /lfs/objects/batch",
+		"PUT /lfs/objects/" + p.OID,
+		"POST /lfs/objects/verify",
+	}, srv.Requests())
+}
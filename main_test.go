@@ -8,6 +8,10 @@ import (
 )
 
 func TestMain(m *testing.M) {
+	if os.Getenv(filterProcessEnv) != "" {
+		os.Exit(runDelayingFilterProcess(os.Stdin, os.Stdout))
+	}
+
 	// Set the trace targets based on the environment variables.
 	trace.ReadEnv()
 
@@ -0,0 +1,297 @@
+package git
+
+import (
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// Flags of the commits walked by the negotiators, as in git's negotiators.
+const (
+	// negotiationSeen marks a commit that was queued.
+	negotiationSeen = 1 << iota
+	// negotiationCommon marks a commit the server has.
+	negotiationCommon
+	// negotiationCommonRef marks a commit a server reference points to.
+	negotiationCommonRef
+	// negotiationPopped marks a commit taken out of the queue.
+	negotiationPopped
+)
+
+// negotiationCommit is a commit walked by a negotiator.
+type negotiationCommit struct {
+	commit *object.Commit
+	flags  int
+	// ttl is the number of commits the skipping negotiator still skips
+	// before sending one, and originalTTL the interval it counts down from.
+	ttl         uint16
+	originalTTL uint16
+}
+
+// walkNegotiator walks back the local history from the negotiation tips in
+// commit date order, implementing git's consecutive and skipping negotiation
+// algorithms.
+type walkNegotiator struct {
+	s        storer.EncodedObjectStorer
+	skipping bool
+	shallows map[plumbing.Hash]bool
+	commits  map[plumbing.Hash]*negotiationCommit
+	queue    *binaryheap.Heap
+	// nonCommon counts the queued commits not known to be common, the walk
+	// ends when there are none left.
+	nonCommon int
+}
+
+// newNegotiator returns the negotiator of the given algorithm, walking the
+// history of tips. common are the commits the server is known to have, such
+// as those its references point to. Shallow commits are walked as if they had
+// no parents and never sent, as their ancestors are missing.
+func newNegotiator(
+	algorithm NegotiationAlgorithm,
+	s storer.EncodedObjectStorer,
+	tips, common, shallows []plumbing.Hash,
+) transport.Negotiator {
+	if algorithm == NoopNegotiation {
+		return noopNegotiator{}
+	}
+
+	n := &walkNegotiator{
+		s:        s,
+		skipping: algorithm == SkippingNegotiation,
+		shallows: make(map[plumbing.Hash]bool, len(shallows)),
+		commits:  make(map[plumbing.Hash]*negotiationCommit),
+		queue: binaryheap.NewWith(func(a, b any) int {
+			if a.(*negotiationCommit).commit.Committer.When.Before(b.(*negotiationCommit).commit.Committer.When) {
+				return 1
+			}
+			return -1
+		}),
+	}
+	for _, h := range shallows {
+		n.shallows[h] = true
+	}
+
+	for _, h := range common {
+		n.knownCommon(h)
+	}
+	for _, h := range tips {
+		n.addTip(h)
+	}
+
+	return n
+}
+
+// commit returns the walked commit h, or nil when it is not a commit of the
+// local repository.
+func (n *walkNegotiator) commit(h plumbing.Hash) *negotiationCommit {
+	if c, ok := n.commits[h]; ok {
+		return c
+	}
+
+	commit, err := object.GetCommit(n.s, h)
+	if err != nil {
+		return nil
+	}
+
+	c := &negotiationCommit{commit: commit}
+	n.commits[h] = c
+	return c
+}
+
+// parents returns the walked parents of c, the ones missing locally aside.
+func (n *walkNegotiator) parents(c *negotiationCommit) []*negotiationCommit {
+	if n.shallows[c.commit.Hash] {
+		return nil
+	}
+
+	parents := make([]*negotiationCommit, 0, len(c.commit.ParentHashes))
+	for _, h := range c.commit.ParentHashes {
+		if p := n.commit(h); p != nil {
+			parents = append(parents, p)
+		}
+	}
+	return parents
+}
+
+func (n *walkNegotiator) push(c *negotiationCommit, flags int) {
+	c.flags |= flags | negotiationSeen
+	n.queue.Push(c)
+	if c.flags&negotiationCommon == 0 {
+		n.nonCommon++
+	}
+}
+
+func (n *walkNegotiator) setCommon(c *negotiationCommit) {
+	if c.flags&(negotiationSeen|negotiationPopped|negotiationCommon) == negotiationSeen {
+		n.nonCommon--
+	}
+	c.flags |= negotiationCommon
+}
+
+func (n *walkNegotiator) addTip(h plumbing.Hash) {
+	if c := n.commit(h); c != nil && c.flags&negotiationSeen == 0 {
+		n.push(c, 0)
+	}
+}
+
+func (n *walkNegotiator) knownCommon(h plumbing.Hash) {
+	c := n.commit(h)
+	if c == nil || c.flags&negotiationSeen != 0 {
+		return
+	}
+
+	if n.skipping {
+		n.push(c, 0)
+		return
+	}
+
+	n.push(c, negotiationCommonRef)
+	n.markCommon(c, true)
+}
+
+// Ack honors the transport.Negotiator interface.
+func (n *walkNegotiator) Ack(h plumbing.Hash) {
+	c, ok := n.commits[h]
+	if !ok || c.flags&negotiationSeen == 0 {
+		// Not a commit that was sent as a have.
+		return
+	}
+
+	n.markCommon(c, false)
+}
+
+// markCommon marks c, unless ancestorsOnly, and its ancestors as common.
+func (n *walkNegotiator) markCommon(c *negotiationCommit, ancestorsOnly bool) {
+	if c.flags&negotiationCommon != 0 {
+		return
+	}
+	if !ancestorsOnly {
+		n.setCommon(c)
+	}
+
+	queue := []*negotiationCommit{c}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		if n.skipping {
+			// The skipping negotiator only propagates through the commits
+			// it walked, as in git.
+			if c.flags&negotiationPopped == 0 {
+				continue
+			}
+		} else if c.flags&negotiationSeen == 0 {
+			// Queued so its ancestors are marked once it is popped.
+			n.push(c, 0)
+			continue
+		}
+
+		for _, p := range n.parents(c) {
+			if p.flags&negotiationCommon != 0 ||
+				(n.skipping && p.flags&negotiationSeen == 0) {
+				continue
+			}
+			n.setCommon(p)
+			queue = append(queue, p)
+		}
+	}
+}
+
+// Next honors the transport.Negotiator interface.
+func (n *walkNegotiator) Next() (plumbing.Hash, bool) {
+	for n.nonCommon > 0 {
+		v, ok := n.queue.Pop()
+		if !ok {
+			break
+		}
+
+		c := v.(*negotiationCommit)
+		if n.pop(c) && !n.shallows[c.commit.Hash] {
+			return c.commit.Hash, true
+		}
+	}
+
+	return plumbing.ZeroHash, false
+}
+
+// pop takes c out of the queue, queuing its parents, and reports whether it
+// is to be sent.
+func (n *walkNegotiator) pop(c *negotiationCommit) bool {
+	c.flags |= negotiationPopped
+	common := c.flags&negotiationCommon != 0
+	if !common {
+		n.nonCommon--
+	}
+
+	if n.skipping {
+		return n.popSkipping(c, common)
+	}
+
+	var flags int
+	if common || c.flags&negotiationCommonRef != 0 {
+		flags = negotiationCommon
+	}
+	for _, p := range n.parents(c) {
+		if p.flags&negotiationSeen == 0 {
+			n.push(p, flags)
+		}
+		if flags != 0 {
+			n.markCommon(p, true)
+		}
+	}
+
+	return !common
+}
+
+// popSkipping queues the parents of c for the skipping negotiator, as
+// push_parent of git's skipping.c. A commit is sent once ttl commits were
+// skipped since the previous one sent along its line of history, the
+// interval growing by half, plus one, each time: 1, 2, 4, 7, 11 and so on.
+func (n *walkNegotiator) popSkipping(c *negotiationCommit, common bool) bool {
+	send := !common && c.ttl == 0
+
+	var pushed bool
+	for _, p := range n.parents(c) {
+		if p.flags&negotiationSeen != 0 {
+			if p.flags&negotiationPopped != 0 {
+				continue
+			}
+		} else {
+			n.push(p, 0)
+		}
+		pushed = true
+
+		if common {
+			p.ttl, p.originalTTL = 0, 0
+			n.markCommon(p, false)
+			continue
+		}
+
+		originalTTL, ttl := c.originalTTL, c.ttl-1
+		if c.ttl == 0 {
+			originalTTL = c.originalTTL*3/2 + 1
+			ttl = originalTTL
+		}
+		if p.originalTTL < originalTTL {
+			p.originalTTL, p.ttl = originalTTL, ttl
+		}
+	}
+
+	// A commit whose parents were all popped already, or that has none, is
+	// sent anyway.
+	return send || (!common && !pushed)
+}
+
+// noopNegotiator sends no haves.
+type noopNegotiator struct{}
+
+// Next honors the transport.Negotiator interface.
+func (noopNegotiator) Next() (plumbing.Hash, bool) {
+	return plumbing.ZeroHash, false
+}
+
+// Ack honors the transport.Negotiator interface.
+func (noopNegotiator) Ack(plumbing.Hash) {}
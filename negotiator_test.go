@@ -0,0 +1,173 @@
+package git
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+var negotiationTip = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func newNegotiationStorage(t *testing.T) *filesystem.Storage {
+	t.Helper()
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	st := filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func negotiationHaves(n transport.Negotiator) []plumbing.Hash {
+	var haves []plumbing.Hash
+	for {
+		h, ok := n.Next()
+		if !ok {
+			return haves
+		}
+		haves = append(haves, h)
+	}
+}
+
+func TestConsecutiveNegotiator(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	haves := negotiationHaves(newNegotiator(ConsecutiveNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil))
+	require.Len(t, haves, 8)
+	assert.Equal(t, negotiationTip, haves[0])
+	for i := 1; i < len(haves); i++ {
+		prev, err := object.GetCommit(st, haves[i-1])
+		require.NoError(t, err)
+		c, err := object.GetCommit(st, haves[i])
+		require.NoError(t, err)
+		assert.False(t, c.Committer.When.After(prev.Committer.When))
+	}
+}
+
+func TestConsecutiveNegotiatorAck(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	n := newNegotiator(ConsecutiveNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil)
+	h, ok := n.Next()
+	require.True(t, ok)
+	assert.Equal(t, negotiationTip, h)
+
+	// The parent of the tip is common, and so is all the rest of the history.
+	parent, ok := n.Next()
+	require.True(t, ok)
+	n.Ack(parent)
+	assert.Empty(t, negotiationHaves(n))
+}
+
+func TestConsecutiveNegotiatorKnownCommon(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	common := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	haves := negotiationHaves(newNegotiator(ConsecutiveNegotiation, st, []plumbing.Hash{negotiationTip}, []plumbing.Hash{common}, nil))
+	assert.Equal(t, []plumbing.Hash{negotiationTip, common}, haves)
+}
+
+func TestConsecutiveNegotiatorShallow(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	shallow := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	haves := negotiationHaves(newNegotiator(ConsecutiveNegotiation, st, []plumbing.Hash{negotiationTip}, nil, []plumbing.Hash{shallow}))
+	assert.Equal(t, []plumbing.Hash{negotiationTip}, haves)
+}
+
+func TestSkippingNegotiator(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	consecutive := negotiationHaves(newNegotiator(ConsecutiveNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil))
+	skipping := negotiationHaves(newNegotiator(SkippingNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil))
+	require.GreaterOrEqual(t, len(skipping), 2)
+	assert.Less(t, len(skipping), len(consecutive))
+	assert.Equal(t, consecutive[0], skipping[0])
+	assert.Subset(t, consecutive, skipping)
+}
+
+func TestSkippingNegotiatorLinearHistory(t *testing.T) {
+	t.Parallel()
+
+	st := memory.NewStorage()
+	var history []plumbing.Hash
+	for i := range 60 {
+		c := &object.Commit{
+			Author:    object.Signature{Name: "a", Email: "a@example.com", When: time.Unix(int64(i), 0)},
+			Committer: object.Signature{Name: "a", Email: "a@example.com", When: time.Unix(int64(i), 0)},
+			Message:   fmt.Sprintf("%d\n", i),
+			TreeHash:  plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		}
+		if i > 0 {
+			c.ParentHashes = []plumbing.Hash{history[0]}
+		}
+		obj := st.NewEncodedObject()
+		require.NoError(t, c.Encode(obj))
+		h, err := st.SetEncodedObject(obj)
+		require.NoError(t, err)
+		history = append([]plumbing.Hash{h}, history...)
+	}
+
+	// As git, 1, 2, 4, 7, 11 and then 17 commits are skipped between the
+	// haves, and the root commit is sent.
+	var expected []plumbing.Hash
+	for _, i := range []int{0, 2, 5, 10, 18, 30, 48, 59} {
+		expected = append(expected, history[i])
+	}
+	haves := negotiationHaves(newNegotiator(SkippingNegotiation, st, []plumbing.Hash{history[0]}, nil, nil))
+	assert.Equal(t, expected, haves)
+}
+
+func TestSkippingNegotiatorAck(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	n := newNegotiator(SkippingNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil)
+	h, ok := n.Next()
+	require.True(t, ok)
+	n.Ack(h)
+	assert.Empty(t, negotiationHaves(n))
+}
+
+func TestNoopNegotiator(t *testing.T) {
+	t.Parallel()
+	st := newNegotiationStorage(t)
+
+	n := newNegotiator(NoopNegotiation, st, []plumbing.Hash{negotiationTip}, nil, nil)
+	assert.Empty(t, negotiationHaves(n))
+}
+
+func TestNegotiationAlgorithmConfig(t *testing.T) {
+	t.Parallel()
+
+	for v, expected := range map[string]NegotiationAlgorithm{
+		"":            "",
+		"skipping":    SkippingNegotiation,
+		"noop":        NoopNegotiation,
+		"consecutive": ConsecutiveNegotiation,
+		"default":     ConsecutiveNegotiation,
+		"unknown":     ConsecutiveNegotiation,
+	} {
+		cfg := formatcfg.New()
+		if v != "" {
+			cfg.Section("fetch").SetOption("negotiationAlgorithm", v)
+		}
+		assert.Equal(t, expected, negotiationAlgorithm([]*formatcfg.Config{cfg}), v)
+	}
+}
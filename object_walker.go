@@ -33,6 +33,9 @@ type objectWalker struct {
 }
 
 func newObjectWalker(s storage.Storer) *objectWalker {
+	// The walk tolerates the objects a promisor remote withheld rather than
+	// fetching them.
+	s = unwrapPromisor(s)
 	return &objectWalker{
 		Storer:   s,
 		seen:     map[plumbing.Hash]struct{}{},
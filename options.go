@@ -95,6 +95,12 @@ type CloneOptions struct {
 	// will not return an error. The resulting repository will be initialized
 	// with the remote configured but no commits.
 	AllowEmptyRepo bool
+	// BundleURI is the location of a bundle, or of a bundle list, to
+	// download before fetching the rest from the remote. It can be a HTTP(S)
+	// or file URL, or a local path. When empty, the bundles the server
+	// advertises are downloaded if transfer.bundleURI is true.
+	// See https://git-scm.com/docs/git-clone#Documentation/git-clone.txt---bundle-urilturigt
+	BundleURI string
 
 	// worktree defines the worktree filesystem for non-bare clone operations.
 	// This is only used internally due to partial inits.
@@ -222,6 +228,24 @@ const (
 	NoTags = plumbing.NoTags
 )
 
+// NegotiationAlgorithm selects the commits a fetch tells the server it has,
+// as git's fetch.negotiationAlgorithm does.
+type NegotiationAlgorithm string
+
+// Negotiation algorithms for fetching.
+const (
+	// ConsecutiveNegotiation walks back the local history in commit date
+	// order, sending every commit not yet known to be common.
+	ConsecutiveNegotiation NegotiationAlgorithm = "consecutive"
+	// SkippingNegotiation walks back the local history like
+	// ConsecutiveNegotiation, but skips commits at exponentially growing
+	// intervals, converging faster on the common history of large
+	// repositories at the cost of a possibly larger packfile.
+	SkippingNegotiation NegotiationAlgorithm = "skipping"
+	// NoopNegotiation sends no commits at all.
+	NoopNegotiation NegotiationAlgorithm = "noop"
+)
+
 // FetchOptions describes how a fetch should be performed
 type FetchOptions struct {
 	// Name of the remote to fetch from. Defaults to origin.
@@ -232,6 +256,18 @@ type FetchOptions struct {
 	// Depth limit fetching to the specified number of commits from the tip of
 	// each remote branch history.
 	Depth int
+	// DeepenRelative makes Depth count from the current shallow boundary
+	// instead of from the tip of each remote branch, deepening an existing
+	// shallow repository by Depth commits, as git fetch --deepen does. It
+	// requires Depth.
+	DeepenRelative bool
+	// DeepenNot limits fetching to the history not reachable from the given
+	// remote branches or tags, so that the excluded commits become the
+	// shallow boundary. It cannot be used with Depth.
+	DeepenNot []string
+	// Unshallow fetches the whole history of a shallow repository, turning
+	// it into a complete one. It cannot be used with Depth or DeepenNot.
+	Unshallow bool
 	// ClientOptions configures the transport client used for this operation.
 	ClientOptions []client.Option
 	// Progress is where the human readable information sent by the server is
@@ -250,7 +286,30 @@ type FetchOptions struct {
 	// Filter requests that the server to send only a subset of the objects.
 	// See https://git-scm.com/docs/git-clone#Documentation/git-clone.txt-code--filterltfilter-specgtcode
 	Filter packp.Filter
-}
+	// NegotiationAlgorithm selects the commits the fetch tells the server it
+	// has. By default it is read from fetch.negotiationAlgorithm and, when
+	// that is not set either, the history of each local reference is sent
+	// up front.
+	NegotiationAlgorithm NegotiationAlgorithm
+	// NegotiationTips restricts the commits the fetch tells the server it has
+	// to the history of these local references or commits. A reference glob,
+	// such as refs/remotes/origin/*, matches several references, and is
+	// ignored if it matches none.
+	NegotiationTips []string
+
+	// bundleURI and bundleURIs are set by clone, which downloads the bundle
+	// at bundleURI or, when bundleURIs is set, those the server advertises.
+	bundleURI  string
+	bundleURIs bool
+}
+
+// Fetch errors.
+var (
+	ErrDeepenRelativeRequiresDepth = errors.New("DeepenRelative requires Depth")
+	ErrDepthDeepenNotExclusive     = errors.New("Depth and DeepenNot are mutually exclusive")
+	ErrUnshallowExclusive          = errors.New("Unshallow cannot be used with Depth or DeepenNot")
+	ErrInvalidNegotiationAlgorithm = errors.New("invalid negotiation algorithm")
+)
 
 // Validate validates the fields and sets the default values.
 func (o *FetchOptions) Validate() error {
@@ -258,6 +317,24 @@ func (o *FetchOptions) Validate() error {
 		o.RemoteName = DefaultRemoteName
 	}
 
+	if o.DeepenRelative && o.Depth <= 0 {
+		return ErrDeepenRelativeRequiresDepth
+	}
+
+	if o.Depth != 0 && len(o.DeepenNot) > 0 {
+		return ErrDepthDeepenNotExclusive
+	}
+
+	if o.Unshallow && (o.Depth != 0 || len(o.DeepenNot) > 0) {
+		return ErrUnshallowExclusive
+	}
+
+	switch o.NegotiationAlgorithm {
+	case "", ConsecutiveNegotiation, SkippingNegotiation, NoopNegotiation:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidNegotiationAlgorithm, o.NegotiationAlgorithm)
+	}
+
 	if o.Tags == plumbing.InvalidTagMode {
 		o.Tags = plumbing.TagFollowing
 	}
@@ -312,6 +389,14 @@ type PushOptions struct {
 	// Quiet indicates whether the server should suppress human-readable
 	// output.
 	Quiet bool
+	// Signer, when set, signs a push certificate recording the reference
+	// updates, as git push --signed does. The remote must advertise support
+	// for signed pushes.
+	Signer Signer
+	// Pusher identifies the signer in the push certificate. If Pusher is
+	// nil, the Name and Email are read from the config, and time.Now is
+	// used as When.
+	Pusher *object.Signature
 }
 
 // ForceWithLease sets fields on the lease
@@ -849,3 +934,14 @@ func (o *RestoreOptions) Validate() error {
 
 	return nil
 }
+
+// SparseCheckoutOptions describes how a sparse checkout is enabled.
+type SparseCheckoutOptions struct {
+	// NoCone, if true, the patterns of the sparse checkout are
+	// gitignore-style patterns of the files to check out, rather than the
+	// directories of cone mode.
+	NoCone bool
+	// SparseIndex, if true, index.sparse is enabled: the directories out of
+	// a cone-mode sparse checkout are written as a single index entry.
+	SparseIndex bool
+}
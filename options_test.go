@@ -116,6 +116,20 @@ func (s *OptionsSuite) TestCloneOptionsValidate_RelativeDotDot() {
 	}
 }
 
+func (s *OptionsSuite) TestFetchOptionsValidateDeepen() {
+	s.ErrorIs((&FetchOptions{DeepenRelative: true}).Validate(), ErrDeepenRelativeRequiresDepth)
+	s.ErrorIs((&FetchOptions{Depth: 1, DeepenNot: []string{"v1.0"}}).Validate(), ErrDepthDeepenNotExclusive)
+	s.NoError((&FetchOptions{Depth: 1, DeepenRelative: true}).Validate())
+	s.NoError((&FetchOptions{DeepenNot: []string{"v1.0"}}).Validate())
+	s.ErrorIs((&FetchOptions{Depth: 1, Unshallow: true}).Validate(), ErrUnshallowExclusive)
+	s.NoError((&FetchOptions{Unshallow: true}).Validate())
+}
+
+func (s *OptionsSuite) TestFetchOptionsValidateNegotiationAlgorithm() {
+	s.NoError((&FetchOptions{NegotiationAlgorithm: SkippingNegotiation}).Validate())
+	s.ErrorIs((&FetchOptions{NegotiationAlgorithm: "default"}).Validate(), ErrInvalidNegotiationAlgorithm)
+}
+
 // registerGlobalConfig registers a static ConfigSource plugin with the
 // given config as the global config. It returns a cleanup function that
 // restores the default test ConfigSource.
@@ -15,6 +15,7 @@ import (
 	"golang.org/x/net/proxy"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 	"github.com/go-git/go-git/v6/plumbing/transport/file"
 	xgit "github.com/go-git/go-git/v6/plumbing/transport/git"
 	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
@@ -55,6 +56,7 @@ type options struct {
 	git  xgit.Options
 	file file.Options
 
+	prompt  credential.Prompter
 	schemes map[string]transport.Transport
 }
 
@@ -81,6 +83,38 @@ func WithHTTPAuth(a HTTPAuth) Option {
 	}
 }
 
+// WithCredentials sets the Git credential helpers consulted when an HTTP
+// server requires authentication and no HTTP authentication was set. See
+// credential.NewManagerFromConfig to build them from Git configuration.
+func WithCredentials(m *credential.Manager) Option {
+	return func(o *options) {
+		o.http.Credentials = m
+	}
+}
+
+// WithPrompt sets the callback used to interactively ask the user for
+// credentials: HTTP usernames and passwords the credential helpers did not
+// provide, and SSH key passphrases, keyboard-interactive answers and
+// passwords when no SSH authentication was set. See credential.NewAskPass
+// for an implementation honoring GIT_ASKPASS, core.askPass and SSH_ASKPASS.
+func WithPrompt(p credential.Prompter) Option {
+	return func(o *options) {
+		o.prompt = p
+	}
+}
+
+// WithSSHCommand runs the given external SSH command for ssh:// URLs,
+// instead of the built-in SSH client, as Git does with core.sshCommand and
+// GIT_SSH_COMMAND. variant tells how to pass it options; see
+// ssh.Options.SSHVariant. It is ignored when SSH authentication is set with
+// WithSSHAuth. See ssh.ResolveSSHCommand.
+func WithSSHCommand(command, variant string) Option {
+	return func(o *options) {
+		o.ssh.SSHCommand = command
+		o.ssh.SSHVariant = variant
+	}
+}
+
 // WithProxyURL routes all transport connections through the given proxy URL.
 // For HTTP, this uses http.ProxyURL. For SSH and Git TCP, this uses
 // golang.org/x/net/proxy.FromURL to wrap the underlying dialer.
@@ -248,9 +282,25 @@ func (c *Client) builtin(scheme string) (transport.Transport, error) {
 	case "git":
 		return xgit.NewTransport(c.opts.git), nil
 	case "ssh":
-		return xssh.NewTransport(c.opts.ssh), nil
+		opts := c.opts.ssh
+		if c.opts.prompt != nil {
+			opts.Prompt = c.opts.prompt
+		}
+		if opts.ClientConfig != nil {
+			opts.SSHCommand = ""
+		}
+		return xssh.NewTransport(opts), nil
 	case "http", "https":
-		return xhttp.NewTransport(c.opts.http), nil
+		opts := c.opts.http
+		if c.opts.prompt != nil {
+			var m credential.Manager
+			if opts.Credentials != nil {
+				m = *opts.Credentials
+			}
+			m.Prompt = c.opts.prompt
+			opts.Credentials = &m
+		}
+		return xhttp.NewTransport(opts), nil
 	default:
 		return nil, fmt.Errorf("transport: unsupported scheme %q", scheme)
 	}
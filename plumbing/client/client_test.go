@@ -145,6 +145,16 @@ func TestWithRedirectPolicy(t *testing.T) {
 	assert.Equal(t, xhttp.NoFollowRedirects, o.http.FollowRedirects)
 }
 
+func TestWithSSHCommand(t *testing.T) {
+	t.Parallel()
+
+	var o options
+	WithSSHCommand("plink -batch", "plink")(&o)
+
+	assert.Equal(t, "plink -batch", o.ssh.SSHCommand)
+	assert.Equal(t, "plink", o.ssh.SSHVariant)
+}
+
 func TestWithProxyURL(t *testing.T) {
 	t.Parallel()
 
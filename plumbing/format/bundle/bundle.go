@@ -0,0 +1,201 @@
+// Package bundle implements the git bundle format, a header listing
+// references and prerequisites followed by a packfile, and the bundle lists
+// that bundle URIs advertise.
+//
+// See https://git-scm.com/docs/gitformat-bundle.
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+const (
+	signatureV2 = "# v2 git bundle"
+	signatureV3 = "# v3 git bundle"
+)
+
+var (
+	// ErrNotBundle is returned when the data does not start with a bundle
+	// signature.
+	ErrNotBundle = errors.New("bundle: not a bundle")
+	// ErrMalformedHeader is returned when the bundle header cannot be parsed.
+	ErrMalformedHeader = errors.New("bundle: malformed header")
+	// ErrMissingPrerequisites is returned when unbundling into a repository
+	// that lacks an object the bundle requires.
+	ErrMissingPrerequisites = errors.New("bundle: missing prerequisites")
+)
+
+// Prerequisite is an object the bundle's packfile depends on but does not
+// hold.
+type Prerequisite struct {
+	Hash    plumbing.Hash
+	Comment string
+}
+
+// Header is the header of a bundle.
+type Header struct {
+	// Version is the bundle format version, 2 or 3.
+	Version int
+	// Capabilities are the v3 capabilities, such as "object-format" and
+	// "filter", with their values.
+	Capabilities map[string]string
+	// Prerequisites are the objects the packfile depends on.
+	Prerequisites []Prerequisite
+	// References are the references the bundle holds.
+	References []*plumbing.Reference
+}
+
+// Filter returns the object filter the bundle's packfile was created with, or
+// an empty string when it holds every object.
+func (h *Header) Filter() string {
+	return h.Capabilities["filter"]
+}
+
+// IsBundle reports whether the data in r starts with a bundle signature. It
+// peeks at r without consuming it.
+func IsBundle(r *bufio.Reader) bool {
+	b, _ := r.Peek(len(signatureV2))
+	return string(b) == signatureV2 || string(b) == signatureV3
+}
+
+// Decoder reads a bundle.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br}
+}
+
+// Decode reads the bundle header into h, leaving the decoder positioned at the
+// packfile, which Packfile returns.
+func (d *Decoder) Decode(h *Header) error {
+	line, err := d.r.ReadString('\n')
+	switch strings.TrimSuffix(line, "\n") {
+	case signatureV2:
+		h.Version = 2
+	case signatureV3:
+		h.Version = 3
+	default:
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return ErrNotBundle
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unexpected end of header", ErrMalformedHeader)
+	}
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@"):
+			if h.Version < 3 {
+				return fmt.Errorf("%w: capability in a v2 bundle: %q", ErrMalformedHeader, line)
+			}
+			key, value, _ := strings.Cut(line[1:], "=")
+			if h.Capabilities == nil {
+				h.Capabilities = make(map[string]string)
+			}
+			h.Capabilities[key] = value
+		case strings.HasPrefix(line, "-"):
+			oid, comment, _ := strings.Cut(line[1:], " ")
+			hash, ok := parseHash(oid)
+			if !ok {
+				return fmt.Errorf("%w: invalid prerequisite %q", ErrMalformedHeader, line)
+			}
+			h.Prerequisites = append(h.Prerequisites, Prerequisite{Hash: hash, Comment: comment})
+		default:
+			oid, name, ok := strings.Cut(line, " ")
+			hash, valid := parseHash(oid)
+			if !ok || !valid || name == "" {
+				return fmt.Errorf("%w: invalid reference %q", ErrMalformedHeader, line)
+			}
+			h.References = append(h.References, plumbing.NewHashReference(plumbing.ReferenceName(name), hash))
+		}
+	}
+}
+
+// Packfile returns the packfile that follows the header. It is only valid
+// after a successful Decode.
+func (d *Decoder) Packfile() io.Reader {
+	return d.r
+}
+
+func (d *Decoder) readLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("%w: unexpected end of header", ErrMalformedHeader)
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func parseHash(s string) (plumbing.Hash, bool) {
+	if !plumbing.IsHash(s) {
+		return plumbing.ZeroHash, false
+	}
+	return plumbing.FromHex(s)
+}
+
+// Unbundle reads the bundle in r and stores its packfile in s, returning the
+// bundle's header. It fails with ErrMissingPrerequisites, before storing
+// anything, when s lacks an object the bundle requires. A bundle created with
+// an object filter is stored as a promisor pack.
+//
+// The bundle's references are returned in the header; Unbundle does not write
+// them, since where they belong is up to the caller.
+func Unbundle(s storer.Storer, r io.Reader) (*Header, error) {
+	d := NewDecoder(r)
+	h := &Header{}
+	if err := d.Decode(h); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, p := range h.Prerequisites {
+		if err := s.HasEncodedObject(p.Hash); err != nil {
+			if !errors.Is(err, plumbing.ErrObjectNotFound) {
+				return nil, err
+			}
+			missing = append(missing, p.Hash.String())
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingPrerequisites, strings.Join(missing, ", "))
+	}
+
+	var err error
+	if h.Filter() != "" {
+		err = packfile.UpdatePromisorObjectStorage(s, d.Packfile(), "")
+	} else {
+		err = packfile.UpdateObjectStorage(s, d.Packfile())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
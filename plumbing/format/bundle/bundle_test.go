@@ -0,0 +1,134 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+const (
+	masterCommit  = "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	initialCommit = "b029517f6300c2da0f4b651b8642506cd6aaf45d"
+)
+
+// gitBundle creates a bundle of the basic fixture with git bundle create,
+// passing it args, and returns its content.
+func gitBundle(t *testing.T, args ...string) []byte {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git CLI not found in PATH")
+	}
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "test.bundle")
+	cmd := exec.Command("git", append([]string{"--git-dir", dotgit.Root(), "bundle", "create", path}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git bundle create: %s", out)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	b := gitBundle(t, "master")
+	require.True(t, IsBundle(bufio.NewReader(bytes.NewReader(b))))
+
+	d := NewDecoder(bytes.NewReader(b))
+	h := &Header{}
+	require.NoError(t, d.Decode(h))
+	assert.Equal(t, 2, h.Version)
+	assert.Empty(t, h.Prerequisites)
+	assert.Equal(t, []*plumbing.Reference{
+		plumbing.NewHashReference("refs/heads/master", plumbing.NewHash(masterCommit)),
+	}, h.References)
+
+	sig := make([]byte, 4)
+	_, err := d.Packfile().Read(sig)
+	require.NoError(t, err)
+	assert.Equal(t, "PACK", string(sig))
+}
+
+func TestDecodeV3(t *testing.T) {
+	t.Parallel()
+
+	header := "# v3 git bundle\n" +
+		"@object-format=sha1\n" +
+		"@filter=blob:none\n" +
+		"-" + initialCommit + " initial\n" +
+		masterCommit + " refs/heads/master\n" +
+		"\n"
+
+	h := &Header{}
+	require.NoError(t, NewDecoder(strings.NewReader(header)).Decode(h))
+	assert.Equal(t, 3, h.Version)
+	assert.Equal(t, map[string]string{"object-format": "sha1", "filter": "blob:none"}, h.Capabilities)
+	assert.Equal(t, "blob:none", h.Filter())
+	assert.Equal(t, []Prerequisite{{Hash: plumbing.NewHash(initialCommit), Comment: "initial"}}, h.Prerequisites)
+	assert.Len(t, h.References, 1)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	for header, want := range map[string]error{
+		"PACK":              ErrNotBundle,
+		"# v2 git bundle\n": ErrMalformedHeader,
+		"# v2 git bundle\n@object-format=sha1\n\n":  ErrMalformedHeader,
+		"# v2 git bundle\n-xyz\n\n":                 ErrMalformedHeader,
+		"# v2 git bundle\n" + masterCommit + "\n\n": ErrMalformedHeader,
+	} {
+		err := NewDecoder(strings.NewReader(header)).Decode(&Header{})
+		require.ErrorIs(t, err, want, header)
+	}
+}
+
+func TestUnbundle(t *testing.T) {
+	t.Parallel()
+
+	b := gitBundle(t, "master")
+	st := memory.NewStorage()
+	h, err := Unbundle(st, bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Len(t, h.References, 1)
+
+	require.NoError(t, st.HasEncodedObject(plumbing.NewHash(masterCommit)))
+	require.NoError(t, st.HasEncodedObject(plumbing.NewHash(initialCommit)))
+}
+
+func TestUnbundleMissingPrerequisites(t *testing.T) {
+	t.Parallel()
+
+	b := gitBundle(t, initialCommit+"..master")
+	h := &Header{}
+	require.NoError(t, NewDecoder(bytes.NewReader(b)).Decode(h))
+	require.NotEmpty(t, h.Prerequisites)
+
+	st := memory.NewStorage()
+	_, err := Unbundle(st, bytes.NewReader(b))
+	require.ErrorIs(t, err, ErrMissingPrerequisites)
+	assert.Empty(t, st.Objects)
+
+	full := gitBundle(t, "branch")
+	_, err = Unbundle(st, bytes.NewReader(full))
+	require.NoError(t, err)
+	_, err = Unbundle(st, bytes.NewReader(b))
+	require.NoError(t, err)
+	require.NoError(t, st.HasEncodedObject(plumbing.NewHash(masterCommit)))
+}
@@ -0,0 +1,179 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+// Bundle list modes.
+const (
+	// ModeAll requires every bundle of the list to be applied.
+	ModeAll = "all"
+	// ModeAny requires any one bundle of the list to be applied.
+	ModeAny = "any"
+)
+
+// HeuristicCreationToken orders the bundles of a list by their creation
+// token.
+const HeuristicCreationToken = "creationToken"
+
+// ErrInvalidList is returned when a bundle list is malformed.
+var ErrInvalidList = errors.New("bundle: invalid bundle list")
+
+// List is a bundle list, as served at a bundle URI or advertised by the
+// bundle-uri command. It is made of the options of the bundle section of a
+// git config file:
+//
+//	[bundle]
+//		version = 1
+//		mode = all
+//	[bundle "<id>"]
+//		uri = <uri>
+type List struct {
+	// Version is the bundle list format version; only 1 is defined.
+	Version int
+	// Mode is ModeAll or ModeAny.
+	Mode string
+	// Heuristic is the order the bundles are meant to be applied in, such
+	// as HeuristicCreationToken, or empty when there is none.
+	Heuristic string
+	// Bundles are the bundles of the list, in the order they were listed.
+	Bundles []*Info
+}
+
+// Info describes a bundle of a list.
+type Info struct {
+	// ID is the unique identifier of the bundle within the list.
+	ID string
+	// URI locates the bundle. It may be relative to the list's URI.
+	URI string
+	// CreationToken orders the bundle relative to the others of the list
+	// when the list's heuristic is HeuristicCreationToken.
+	CreationToken uint64
+	// Filter is the object filter the bundle was created with, if any.
+	Filter string
+}
+
+// DecodeList reads a bundle list in git config format.
+func DecodeList(r io.Reader) (*List, error) {
+	cfg := formatcfg.New()
+	if err := formatcfg.NewDecoder(r).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidList, err)
+	}
+
+	l := &List{}
+	for _, s := range cfg.Sections {
+		if !s.IsName("bundle") {
+			continue
+		}
+		for _, o := range s.Options {
+			if err := l.Set("bundle."+o.Key, o.Value); err != nil {
+				return nil, err
+			}
+		}
+		for _, ss := range s.Subsections {
+			for _, o := range ss.Options {
+				if err := l.Set("bundle."+ss.Name+"."+o.Key, o.Value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return l, l.Validate()
+}
+
+// Set applies a key of the list, such as "bundle.mode" or "bundle.<id>.uri",
+// as sent by the bundle-uri command. Unknown keys are ignored, so that lists
+// written for newer versions of git still parse.
+func (l *List) Set(key, value string) error {
+	section, rest, ok := strings.Cut(key, ".")
+	if !ok || !strings.EqualFold(section, "bundle") {
+		return nil
+	}
+
+	dot := strings.LastIndexByte(rest, '.')
+	if dot < 0 {
+		switch strings.ToLower(rest) {
+		case "version":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%w: version %q", ErrInvalidList, value)
+			}
+			l.Version = v
+		case "mode":
+			l.Mode = value
+		case "heuristic":
+			l.Heuristic = value
+		}
+		return nil
+	}
+
+	info := l.bundle(rest[:dot])
+	switch strings.ToLower(rest[dot+1:]) {
+	case "uri":
+		info.URI = value
+	case "creationtoken":
+		t, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: creationToken %q of bundle %q", ErrInvalidList, value, info.ID)
+		}
+		info.CreationToken = t
+	case "filter":
+		info.Filter = value
+	}
+	return nil
+}
+
+func (l *List) bundle(id string) *Info {
+	for _, b := range l.Bundles {
+		if b.ID == id {
+			return b
+		}
+	}
+	b := &Info{ID: id}
+	l.Bundles = append(l.Bundles, b)
+	return b
+}
+
+// Validate checks that the list has a known version and mode, and that every
+// bundle has a URI.
+func (l *List) Validate() error {
+	if l.Version != 1 {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidList, l.Version)
+	}
+	if l.Mode != ModeAll && l.Mode != ModeAny {
+		return fmt.Errorf("%w: unsupported mode %q", ErrInvalidList, l.Mode)
+	}
+	for _, b := range l.Bundles {
+		if b.URI == "" {
+			return fmt.Errorf("%w: bundle %q has no uri", ErrInvalidList, b.ID)
+		}
+	}
+	return nil
+}
+
+// Sorted returns the bundles in the order they are meant to be applied: by
+// increasing creation token under HeuristicCreationToken, and in list order
+// otherwise.
+func (l *List) Sorted() []*Info {
+	bundles := slices.Clone(l.Bundles)
+	if l.Heuristic == HeuristicCreationToken {
+		slices.SortStableFunc(bundles, func(a, b *Info) int {
+			switch {
+			case a.CreationToken < b.CreationToken:
+				return -1
+			case a.CreationToken > b.CreationToken:
+				return 1
+			}
+			return 0
+		})
+	}
+	return bundles
+}
@@ -0,0 +1,74 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeList(t *testing.T) {
+	t.Parallel()
+
+	l, err := DecodeList(strings.NewReader(`[bundle]
+	version = 1
+	mode = all
+	heuristic = creationToken
+[bundle "daily"]
+	uri = daily.bundle
+	creationToken = 2
+[bundle "base"]
+	uri = https://example.com/base.bundle
+	creationToken = 1
+	filter = blob:none
+[other]
+	key = value
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, l.Version)
+	assert.Equal(t, ModeAll, l.Mode)
+	assert.Equal(t, HeuristicCreationToken, l.Heuristic)
+	assert.Equal(t, []*Info{
+		{ID: "daily", URI: "daily.bundle", CreationToken: 2},
+		{ID: "base", URI: "https://example.com/base.bundle", CreationToken: 1, Filter: "blob:none"},
+	}, l.Bundles)
+
+	sorted := l.Sorted()
+	assert.Equal(t, "base", sorted[0].ID)
+	assert.Equal(t, "daily", sorted[1].ID)
+	assert.Equal(t, "daily", l.Bundles[0].ID, "Sorted must not reorder the list")
+}
+
+func TestListSet(t *testing.T) {
+	t.Parallel()
+
+	l := &List{}
+	for _, kv := range [][2]string{
+		{"bundle.version", "1"},
+		{"bundle.mode", "any"},
+		{"bundle.with.dots.uri", "file:///tmp/x.bundle"},
+		{"bundle.with.dots.unknown", "ignored"},
+		{"core.bare", "ignored"},
+	} {
+		require.NoError(t, l.Set(kv[0], kv[1]))
+	}
+	require.NoError(t, l.Validate())
+	assert.Equal(t, []*Info{{ID: "with.dots", URI: "file:///tmp/x.bundle"}}, l.Bundles)
+
+	require.ErrorIs(t, l.Set("bundle.version", "x"), ErrInvalidList)
+	require.ErrorIs(t, l.Set("bundle.id.creationToken", "-1"), ErrInvalidList)
+}
+
+func TestListValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, l := range []*List{
+		{Version: 2, Mode: ModeAll},
+		{Version: 1, Mode: "some"},
+		{Version: 1, Mode: ModeAny, Bundles: []*Info{{ID: "x"}}},
+	} {
+		require.ErrorIs(t, l.Validate(), ErrInvalidList)
+	}
+}
@@ -0,0 +1,343 @@
+// Package cms implements the verification of detached CMS (PKCS #7)
+// signatures, as produced by gpgsm for Git objects when gpg.format is set
+// to x509.
+//
+// See RFC 5652 for the specification of the format.
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature cannot be parsed.
+	ErrInvalidSignature = errors.New("cms: invalid signature")
+	// ErrUnsupportedAlgorithm is returned when a signature uses an
+	// algorithm that is not supported.
+	ErrUnsupportedAlgorithm = errors.New("cms: unsupported algorithm")
+	// ErrSignerNotFound is returned when the certificate of the signer is
+	// not part of the signature.
+	ErrSignerNotFound = errors.New("cms: signer certificate not found")
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSASSAPSS = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// Signature is a parsed detached CMS signature with a single signer.
+type Signature struct {
+	// Certificates are the certificates included in the signature.
+	Certificates []*x509.Certificate
+	// Signer is the certificate of the signer.
+	Signer *x509.Certificate
+	// SigningTime is the signing time claimed by the signer, if any.
+	SigningTime time.Time
+
+	info signerInfo
+}
+
+// Parse parses a PEM-armored or DER-encoded detached CMS signature. Git
+// armors them as "SIGNED MESSAGE" blocks.
+func Parse(b []byte) (*Signature, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(b, &ci); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidSignature)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: not signed data", ErrInvalidSignature)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if len(sd.EncapContentInfo.EContent.Bytes) != 0 {
+		return nil, fmt.Errorf("%w: not a detached signature", ErrInvalidSignature)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("%w: %d signers", ErrInvalidSignature, len(sd.SignerInfos))
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	s := &Signature{Certificates: certs, info: sd.SignerInfos[0]}
+	if s.Signer, err = s.findSigner(); err != nil {
+		return nil, err
+	}
+	if s.SigningTime, err = s.signingTime(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// findSigner returns the certificate identified by the signer info.
+func (s *Signature) findSigner() (*x509.Certificate, error) {
+	sid := s.info.SID
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		var ias issuerAndSerialNumber
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		for _, c := range s.Certificates {
+			if c.SerialNumber.Cmp(ias.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+				return c, nil
+			}
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+		for _, c := range s.Certificates {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown signer identifier", ErrInvalidSignature)
+	}
+	return nil, ErrSignerNotFound
+}
+
+// attributes returns the signed attributes.
+func (s *Signature) attributes() ([]attribute, error) {
+	if len(s.info.SignedAttrs.Bytes) == 0 {
+		return nil, nil
+	}
+
+	var attrs []attribute
+	rest := s.info.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var a attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, nil
+}
+
+// attribute returns the single value of the signed attribute of type oid,
+// or nil if there is none.
+func (s *Signature) attribute(oid asn1.ObjectIdentifier) ([]byte, error) {
+	attrs, err := s.attributes()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attrs {
+		if a.Type.Equal(oid) {
+			return a.Values.Bytes, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Signature) signingTime() (time.Time, error) {
+	v, err := s.attribute(oidSigningTime)
+	if err != nil || v == nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	if _, err := asn1.Unmarshal(v, &t); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return t, nil
+}
+
+// Verify checks that s is a valid signature of message, made by a
+// certificate that chains up to a root of opts. The certificates included
+// in the signature are used as intermediates. If opts.KeyUsages is empty,
+// any extended key usage is accepted. It returns the verified chains of
+// the signer certificate.
+func (s *Signature) Verify(message io.Reader, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	h, err := hashFor(s.info.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := h.New()
+	if _, err := io.Copy(hasher, message); err != nil {
+		return nil, err
+	}
+	digest := hasher.Sum(nil)
+
+	signed, err := s.signedBytes(digest)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignature(s.Signer, s.info.SignatureAlgorithm, h, signed, s.info.Signature); err != nil {
+		return nil, err
+	}
+
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+	}
+	for _, c := range s.Certificates {
+		if c != s.Signer {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	return s.Signer.Verify(opts)
+}
+
+// signedBytes returns the bytes the signer signed, given the digest of the
+// message: the signed attributes if any, after checking that they hold the
+// digest, and the message itself otherwise.
+func (s *Signature) signedBytes(digest []byte) ([]byte, error) {
+	if len(s.info.SignedAttrs.Bytes) == 0 {
+		return nil, fmt.Errorf("%w: signatures without signed attributes", ErrUnsupportedAlgorithm)
+	}
+
+	v, err := s.attribute(oidMessageDigest)
+	if err != nil {
+		return nil, err
+	}
+	var md []byte
+	if _, err := asn1.Unmarshal(v, &md); err != nil {
+		return nil, fmt.Errorf("%w: missing message digest", ErrInvalidSignature)
+	}
+	if !bytes.Equal(md, digest) {
+		return nil, fmt.Errorf("%w: message digest mismatch", ErrInvalidSignature)
+	}
+
+	if v, err := s.attribute(oidContentType); err != nil {
+		return nil, err
+	} else if v != nil {
+		var ct asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(v, &ct); err != nil || !ct.Equal(oidData) {
+			return nil, fmt.Errorf("%w: unexpected content type", ErrInvalidSignature)
+		}
+	}
+
+	// The signed attributes are signed with their SET OF tag, instead of
+	// the implicit tag they are encoded with.
+	signed := bytes.Clone(s.info.SignedAttrs.FullBytes)
+	signed[0] = 0x31
+	return signed, nil
+}
+
+func hashFor(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		// crypto/x509 refuses the signatures made over SHA-1 digests.
+		return 0, fmt.Errorf("%w: SHA-1 digest", ErrUnsupportedAlgorithm)
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("%w: digest %s", ErrUnsupportedAlgorithm, oid)
+	}
+}
+
+// checkSignature checks sig over signed with the key of cert. The
+// signature algorithm is derived from the key type and the digest, as
+// signers commonly set the algorithm to the key type only.
+func checkSignature(cert *x509.Certificate, alg pkix.AlgorithmIdentifier, h crypto.Hash, signed, sig []byte) error {
+	if alg.Algorithm.Equal(oidRSASSAPSS) {
+		return fmt.Errorf("%w: RSASSA-PSS", ErrUnsupportedAlgorithm)
+	}
+
+	var algo x509.SignatureAlgorithm
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		}[h]
+	case *ecdsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		}[h]
+	case ed25519.PublicKey:
+		algo = x509.PureEd25519
+	}
+	if algo == x509.UnknownSignatureAlgorithm {
+		return fmt.Errorf("%w: %T key with %s", ErrUnsupportedAlgorithm, cert.PublicKey, h)
+	}
+
+	if err := cert.CheckSignature(algo, signed, sig); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return nil
+}
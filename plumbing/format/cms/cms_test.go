@@ -0,0 +1,132 @@
+package cms
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureSignature was generated for "hello\n" with:
+//
+//	openssl cms -sign -binary -in msg -signer user.crt -inkey user.key -outform PEM
+//
+// with the armor renamed to SIGNED MESSAGE, as gpgsm writes it. The signer
+// certificate, for alice@example.com, is issued by fixtureCA.
+const (
+	fixtureSignature = `-----BEGIN SIGNED MESSAGE-----
+MIIDiQYJKoZIhvcNAQcCoIIDejCCA3YCAQExDTALBglghkgBZQMEAgEwCwYJKoZI
+hvcNAQcBoIIB0zCCAc8wggF1oAMCAQICFEvei2fNy4qX65zLCjZ0YhoVFV3zMAoG
+CCqGSM49BAMCMBIxEDAOBgNVBAMMB1Rlc3QgQ0EwIBcNMjYxMDE4MTQwMzUzWhgP
+MjEyNjA5MjQxNDAzNTNaMDIxDjAMBgNVBAMMBUFsaWNlMSAwHgYJKoZIhvcNAQkB
+FhFhbGljZUBleGFtcGxlLmNvbTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABAbN
+2L8IXo6nBmRR10BZbALnRHufu8jRx0+Wiky/kVogbqpj0g0p6Z/DnkvFjjOlApcG
+13WJfzy7RG18i5d6iqGjgYYwgYMwHAYDVR0RBBUwE4ERYWxpY2VAZXhhbXBsZS5j
+b20wDgYDVR0PAQH/BAQDAgeAMBMGA1UdJQQMMAoGCCsGAQUFBwMEMB0GA1UdDgQW
+BBQD/6i/E+RE4tcilfWRLHP3FS+tPDAfBgNVHSMEGDAWgBTWkk4Qjia5rRrfQTSV
+nRFnhsJ+CDAKBggqhkjOPQQDAgNIADBFAiEAwjzOByCcF5DdsjjMJDgmCu3EfpQW
+FjWevLcRfOtVr2cCIB6+PQQV8AGzhgf+xwhhyZYtN8GtK5botc6N+flph36EMYIB
+fDCCAXgCAQEwKjASMRAwDgYDVQQDDAdUZXN0IENBAhRL3otnzcuKl+ucywo2dGIa
+FRVd8zALBglghkgBZQMEAgGggeQwGAYJKoZIhvcNAQkDMQsGCSqGSIb3DQEHATAc
+BgkqhkiG9w0BCQUxDxcNMjYxMDE4MTQwMzUzWjAvBgkqhkiG9w0BCQQxIgQgWJG1
+tSLV3whtD/CxEPvZ0hu0/HFjrzTQgoai6Eb2vgMweQYJKoZIhvcNAQkPMWwwajAL
+BglghkgBZQMEASowCwYJYIZIAWUDBAEWMAsGCWCGSAFlAwQBAjAKBggqhkiG9w0D
+BzAOBggqhkiG9w0DAgICAIAwDQYIKoZIhvcNAwICAUAwBwYFKw4DAgcwDQYIKoZI
+hvcNAwICASgwCgYIKoZIzj0EAwIERzBFAiAqyTKYBqOymyDGfnyqZdR1bkerRcLu
+3WPNuW/qfRnsQAIhAPLsPeKn1yTHmVkk7oq3LOe+ZJKXho1MwQhgEtHVDLwj
+-----END SIGNED MESSAGE-----`
+	fixtureCA = `-----BEGIN CERTIFICATE-----
+MIIBizCCATGgAwIBAgIUfFc8QmRUh40WM+9V50lB8bqxStUwCgYIKoZIzj0EAwIw
+EjEQMA4GA1UEAwwHVGVzdCBDQTAgFw0yNjEwMTgxNDAzNTNaGA8yMTI2MDkyNDE0
+MDM1M1owEjEQMA4GA1UEAwwHVGVzdCBDQTBZMBMGByqGSM49AgEGCCqGSM49AwEH
+A0IABMqIzkXMZg2uCriAmf5P0FIP5uREQS6EgZqqTXYiGSSH2FgYvb539AQWvNLt
+acxrjNmT8lZbWJCVUHUG4Vry3A6jYzBhMB0GA1UdDgQWBBTWkk4Qjia5rRrfQTSV
+nRFnhsJ+CDAfBgNVHSMEGDAWgBTWkk4Qjia5rRrfQTSVnRFnhsJ+CDAPBgNVHRMB
+Af8EBTADAQH/MA4GA1UdDwEB/wQEAwICBDAKBggqhkjOPQQDAgNIADBFAiASMJ/y
+ib6qpP90f770HgeMPbAiZRL8y/ZY4LFchqUroAIhAPtoZI5XKtBzhOp+5TXJgPDo
+qVdQ7DSyDm2tmHP82BMq
+-----END CERTIFICATE-----`
+)
+
+func fixtureRoots(t *testing.T) *x509.CertPool {
+	t.Helper()
+
+	block, _ := pem.Decode([]byte(fixtureCA))
+	require.NotNil(t, block)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	sig, err := Parse([]byte(fixtureSignature))
+	require.NoError(t, err)
+	require.Len(t, sig.Certificates, 1)
+	assert.Equal(t, "Alice", sig.Signer.Subject.CommonName)
+	assert.Equal(t, []string{"alice@example.com"}, sig.Signer.EmailAddresses)
+	assert.False(t, sig.SigningTime.IsZero())
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	sig, err := Parse([]byte(fixtureSignature))
+	require.NoError(t, err)
+
+	opts := x509.VerifyOptions{
+		Roots:       fixtureRoots(t),
+		CurrentTime: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	chains, err := sig.Verify(strings.NewReader("hello\n"), opts)
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+	assert.Equal(t, "Test CA", chains[0][1].Subject.CommonName)
+
+	_, err = sig.Verify(strings.NewReader("hello"), opts)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// The signer certificate is not valid yet.
+	opts.CurrentTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = sig.Verify(strings.NewReader("hello\n"), opts)
+	require.Error(t, err)
+
+	// Untrusted root.
+	_, err = sig.Verify(strings.NewReader("hello\n"), x509.VerifyOptions{
+		Roots:       x509.NewCertPool(),
+		CurrentTime: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.Error(t, err)
+}
+
+func TestVerifySHA1(t *testing.T) {
+	t.Parallel()
+
+	sig, err := Parse([]byte(fixtureSignature))
+	require.NoError(t, err)
+	sig.info.DigestAlgorithm.Algorithm = oidSHA1
+
+	_, err = sig.Verify(strings.NewReader("hello\n"), x509.VerifyOptions{Roots: fixtureRoots(t)})
+	require.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		"",
+		"-----BEGIN SIGNED MESSAGE-----\nAAAA\n-----END SIGNED MESSAGE-----",
+		fixtureCA,
+	} {
+		_, err := Parse([]byte(s))
+		require.ErrorIs(t, err, ErrInvalidSignature, s)
+	}
+}
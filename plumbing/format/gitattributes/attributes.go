@@ -21,6 +21,17 @@ var (
 	ErrInvalidAttributeName = errors.New("invalid attribute name")
 )
 
+// binaryMacro is the built-in binary macro, which the root .gitattributes
+// may redefine.
+var binaryMacro = MatchAttribute{
+	Name: "binary",
+	Attributes: []Attribute{
+		attribute{name: "diff", state: attributeUnset},
+		attribute{name: "merge", state: attributeUnset},
+		attribute{name: "text", state: attributeUnset},
+	},
+}
+
 // MatchAttribute represents a gitattribute pattern match with its attributes.
 type MatchAttribute struct {
 	Name       string
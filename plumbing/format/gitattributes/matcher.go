@@ -1,5 +1,7 @@
 package gitattributes
 
+import "slices"
+
 // Matcher defines a global multi-pattern matcher for gitattributes patterns
 type Matcher interface {
 	// Match matches patterns in the order of priorities.
@@ -26,7 +28,7 @@ type matcher struct {
 }
 
 func (m *matcher) init() {
-	m.macros = make(map[string]MatchAttribute)
+	m.macros = map[string]MatchAttribute{binaryMacro.Name: binaryMacro}
 
 	for _, attr := range m.stack {
 		if attr.Pattern == nil {
@@ -39,11 +41,14 @@ func (m *matcher) init() {
 // the attributes associated with the path.
 //
 // Specific attributes can be specified otherwise all attributes are returned.
+// As in git, an attribute takes the value of the last line assigning it, and
+// the attributes of a macro only apply where no later line assigns them.
 //
 // Matched is true if any path was matched to a rule, even if the results map
 // is empty.
 func (m *matcher) Match(path, attributes []string) (results map[string]Attribute, matched bool) {
 	results = make(map[string]Attribute, len(attributes))
+	assigned := make(map[string]bool)
 
 	n := len(m.stack)
 	for i := n - 1; i >= 0; i-- {
@@ -58,22 +63,30 @@ func (m *matcher) Match(path, attributes []string) (results map[string]Attribute
 
 		if match := pattern.Match(path); match {
 			matched = true
-			for _, attr := range m.stack[i].Attributes {
-				if attr.IsSet() {
-					m.expandMacro(attr.Name(), results)
-				}
-				results[attr.Name()] = attr
+			attrs := m.stack[i].Attributes
+			for j := len(attrs) - 1; j >= 0; j-- {
+				m.assign(attrs[j], attributes, results, assigned)
 			}
 		}
 	}
 	return results, matched
 }
 
-func (m *matcher) expandMacro(name string, results map[string]Attribute) bool {
-	if macro, ok := m.macros[name]; ok {
-		for _, attr := range macro.Attributes {
-			results[attr.Name()] = attr
+// assign records attr in results, unless a line of higher priority already
+// assigned it, along with the attributes of its macro when it is a set one.
+func (m *matcher) assign(attr Attribute, attributes []string, results map[string]Attribute, assigned map[string]bool) {
+	if assigned[attr.Name()] {
+		return
+	}
+	assigned[attr.Name()] = true
+
+	if len(attributes) == 0 || slices.Contains(attributes, attr.Name()) {
+		results[attr.Name()] = attr
+	}
+
+	if macro, ok := m.macros[attr.Name()]; ok && attr.IsSet() {
+		for j := len(macro.Attributes) - 1; j >= 0; j-- {
+			m.assign(macro.Attributes[j], attributes, results, assigned)
 		}
 	}
-	return false
 }
@@ -25,3 +25,46 @@ func (s *MatcherSuite) TestMatcher_Match() {
 	s.True(results["text"].IsSet())
 	s.Equal("crlf", results["eol"].Value())
 }
+
+func (s *MatcherSuite) TestMatcher_MatchPriority() {
+	lines := []string{
+		"* text=auto",
+		"*.sh eol=lf",
+		"*.bin binary",
+		"*.txt text -text",
+		"special.bin text",
+		"[attr]binary -text",
+	}
+
+	ma, err := ReadAttributes(strings.NewReader(strings.Join(lines, "\n")), nil, true)
+	s.NoError(err)
+
+	m := NewMatcher(ma)
+	attributes := []string{"text", "eol"}
+
+	results, _ := m.Match([]string{"run.sh"}, attributes)
+	s.Equal("auto", results["text"].Value())
+	s.Equal("lf", results["eol"].Value())
+
+	results, _ = m.Match([]string{"data.bin"}, attributes)
+	s.True(results["text"].IsUnset())
+	s.NotContains(results, "binary")
+
+	results, _ = m.Match([]string{"special.bin"}, attributes)
+	s.True(results["text"].IsSet())
+
+	results, _ = m.Match([]string{"notes.txt"}, attributes)
+	s.True(results["text"].IsUnset())
+}
+
+func (s *MatcherSuite) TestMatcher_MatchBuiltinBinary() {
+	ma, err := ReadAttributes(strings.NewReader("*.png binary"), nil, true)
+	s.NoError(err)
+
+	results, matched := NewMatcher(ma).Match([]string{"logo.png"}, nil)
+	s.True(matched)
+	s.True(results["binary"].IsSet())
+	s.True(results["diff"].IsUnset())
+	s.True(results["merge"].IsUnset())
+	s.True(results["text"].IsUnset())
+}
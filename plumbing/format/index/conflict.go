@@ -0,0 +1,112 @@
+package index
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// ErrNotConflicted is returned by Index.ResolveConflict, if the path has no
+// conflict to resolve.
+var ErrNotConflicted = errors.New("path is not conflicted")
+
+// Conflict is a path left unmerged in the index: instead of an entry, it
+// has an entry for each side of the merge it has, in the stages 1 to 3.
+type Conflict struct {
+	Path string
+	// Ancestor is the entry of the path in the common ancestor, if any.
+	Ancestor *Entry
+	// Ours is the entry of the path in our side of the merge, if any.
+	Ours *Entry
+	// Theirs is the entry of the path in their side of the merge, if any.
+	Theirs *Entry
+}
+
+// Conflicts returns the paths left unmerged in the index, sorted by path.
+func (i *Index) Conflicts() []Conflict {
+	var conflicts []Conflict
+	byPath := make(map[string]int)
+	for _, e := range i.Entries {
+		if e.Stage == 0 {
+			continue
+		}
+
+		n, ok := byPath[e.Name]
+		if !ok {
+			n = len(conflicts)
+			byPath[e.Name] = n
+			conflicts = append(conflicts, Conflict{Path: e.Name})
+		}
+
+		switch e.Stage {
+		case AncestorMode:
+			conflicts[n].Ancestor = e
+		case OurMode:
+			conflicts[n].Ours = e
+		case TheirMode:
+			conflicts[n].Theirs = e
+		}
+	}
+
+	sort.Slice(conflicts, func(a, b int) bool { return conflicts[a].Path < conflicts[b].Path })
+	return conflicts
+}
+
+// ResolveConflict resolves the conflict of path with the entry e, or by
+// removing the path if e is nil: as git add and git rm do, the entries of
+// its stages are replaced by e, and recorded in the resolve undo extension.
+// It returns ErrNotConflicted if path has no conflict.
+func (i *Index) ResolveConflict(path string, e *Entry) error {
+	path = filepath.ToSlash(path)
+
+	undo := ResolveUndoEntry{
+		Path:   path,
+		Stages: make(map[Stage]plumbing.Hash),
+		Modes:  make(map[Stage]filemode.FileMode),
+	}
+
+	entries := make([]*Entry, 0, len(i.Entries))
+	for _, entry := range i.Entries {
+		if entry.Name != path || entry.Stage == 0 {
+			entries = append(entries, entry)
+			continue
+		}
+
+		if len(undo.Stages) == 0 && e != nil {
+			e.Name = path
+			e.Stage = 0
+			entries = append(entries, e)
+		}
+		undo.Stages[entry.Stage] = entry.Hash
+		undo.Modes[entry.Stage] = entry.Mode
+	}
+
+	if len(undo.Stages) == 0 {
+		return ErrNotConflicted
+	}
+
+	i.Entries = entries
+	i.UntrackedCache.Invalidate(path)
+	i.recordResolveUndo(undo)
+	return nil
+}
+
+// recordResolveUndo records the resolve undo entry undo, replacing that of
+// the same path, if any.
+func (i *Index) recordResolveUndo(undo ResolveUndoEntry) {
+	if i.ResolveUndo == nil {
+		i.ResolveUndo = &ResolveUndo{}
+	}
+
+	entries := i.ResolveUndo.Entries
+	n := sort.Search(len(entries), func(n int) bool { return entries[n].Path >= undo.Path })
+	if n < len(entries) && entries[n].Path == undo.Path {
+		entries[n] = undo
+		return
+	}
+
+	i.ResolveUndo.Entries = append(entries[:n], append([]ResolveUndoEntry{undo}, entries[n:]...)...)
+}
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+func conflictTestIndex() *Index {
+	return &Index{Version: 2, Entries: []*Entry{
+		{Name: "a", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), Mode: filemode.Regular},
+		{Name: "b", Hash: plumbing.NewHash("1111111111111111111111111111111111111111"), Mode: filemode.Regular, Stage: AncestorMode},
+		{Name: "b", Hash: plumbing.NewHash("2222222222222222222222222222222222222222"), Mode: filemode.Regular, Stage: OurMode},
+		{Name: "b", Hash: plumbing.NewHash("3333333333333333333333333333333333333333"), Mode: filemode.Executable, Stage: TheirMode},
+		{Name: "c", Hash: plumbing.NewHash("4444444444444444444444444444444444444444"), Mode: filemode.Regular, Stage: OurMode},
+		{Name: "d", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), Mode: filemode.Regular},
+	}}
+}
+
+func TestIndexConflicts(t *testing.T) {
+	t.Parallel()
+
+	idx := conflictTestIndex()
+	conflicts := idx.Conflicts()
+	require.Len(t, conflicts, 2)
+
+	assert.Equal(t, "b", conflicts[0].Path)
+	assert.Equal(t, idx.Entries[1], conflicts[0].Ancestor)
+	assert.Equal(t, idx.Entries[2], conflicts[0].Ours)
+	assert.Equal(t, idx.Entries[3], conflicts[0].Theirs)
+
+	assert.Equal(t, "c", conflicts[1].Path)
+	assert.Nil(t, conflicts[1].Ancestor)
+	assert.Equal(t, idx.Entries[4], conflicts[1].Ours)
+	assert.Nil(t, conflicts[1].Theirs)
+}
+
+func TestIndexResolveConflict(t *testing.T) {
+	t.Parallel()
+
+	idx := conflictTestIndex()
+	resolved := &Entry{Hash: plumbing.NewHash("5555555555555555555555555555555555555555"), Mode: filemode.Regular}
+	require.NoError(t, idx.ResolveConflict("b", resolved))
+	require.NoError(t, idx.ResolveConflict("c", nil))
+	assert.ErrorIs(t, idx.ResolveConflict("a", nil), ErrNotConflicted)
+	assert.ErrorIs(t, idx.ResolveConflict("e", nil), ErrNotConflicted)
+
+	assert.Empty(t, idx.Conflicts())
+	require.Len(t, idx.Entries, 3)
+	assert.Equal(t, "b", idx.Entries[1].Name)
+	assert.Equal(t, Stage(0), idx.Entries[1].Stage)
+
+	output := encodeDecode(t, idx)
+	require.NotNil(t, output.ResolveUndo)
+	assert.Equal(t, []ResolveUndoEntry{{
+		Path: "b",
+		Stages: map[Stage]plumbing.Hash{
+			AncestorMode: plumbing.NewHash("1111111111111111111111111111111111111111"),
+			OurMode:      plumbing.NewHash("2222222222222222222222222222222222222222"),
+			TheirMode:    plumbing.NewHash("3333333333333333333333333333333333333333"),
+		},
+		Modes: map[Stage]filemode.FileMode{
+			AncestorMode: filemode.Regular,
+			OurMode:      filemode.Regular,
+			TheirMode:    filemode.Executable,
+		},
+	}, {
+		Path:   "c",
+		Stages: map[Stage]plumbing.Hash{OurMode: plumbing.NewHash("4444444444444444444444444444444444444444")},
+		Modes:  map[Stage]filemode.FileMode{OurMode: filemode.Regular},
+	}}, output.ResolveUndo.Entries)
+}
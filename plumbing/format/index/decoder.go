@@ -3,6 +3,8 @@ package index
 import (
 	"bufio"
 	"bytes"
+	"crypto"
+	encbin "encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -10,9 +12,11 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/hash"
 	"github.com/go-git/go-git/v6/utils/binary"
 	"github.com/go-git/go-git/v6/utils/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -38,6 +42,10 @@ const (
 	nameMask          = 0xfff
 	intentToAddMask   = 1 << 13
 	skipWorkTreeMask  = 1 << 14
+
+	// maxUntrackedCacheIdentLen bounds the environments of an untracked
+	// cache, which git writes as a single short string.
+	maxUntrackedCacheIdentLen = 1 << 16
 )
 
 // A Decoder reads and decodes index files from an input stream.
@@ -47,8 +55,17 @@ type Decoder struct {
 	hash      hash.Hash
 	lastEntry *Entry
 	skipHash  bool
+	// block is set when decoding a block of an entry offset table, whose
+	// first entry strips, with version 4, a name it has not read.
+	block bool
 
 	extReader *bufio.Reader
+	// threads is the number of goroutines the entries are decoded with,
+	// when the index has an entry offset table.
+	threads int
+	// fsMonitorDirty is the bitmap of the fsmonitor extension, applied to
+	// the entries once all the extensions are read.
+	fsMonitorDirty *ewahBitmap
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -64,21 +81,42 @@ func NewDecoder(r io.Reader, h hash.Hash, opts ...Option) *Decoder {
 		hash:      h,
 		skipHash:  cfg.skipHash,
 		extReader: bufio.NewReader(nil),
+		threads:   cfg.threads,
 	}
 
+	d.reset(buf)
+	return d
+}
+
+// reset makes the decoder read from buf, the checksum being computed from
+// there on.
+func (d *Decoder) reset(buf *bufio.Reader) {
+	d.buf = buf
 	if d.skipHash {
 		d.r = buf
 	} else {
-		h.Reset()
-		d.r = io.TeeReader(buf, h)
+		d.hash.Reset()
+		d.r = io.TeeReader(buf, d.hash)
 	}
-
-	return d
 }
 
 // Decode reads the whole index object from its input and stores it in the
 // value pointed to by idx.
 func (d *Decoder) Decode(idx *Index) error {
+	if d.threads > 1 {
+		data, err := io.ReadAll(d.buf)
+		if err != nil {
+			return err
+		}
+
+		ok, err := d.decodeBlocks(idx, data)
+		if ok || err != nil {
+			return err
+		}
+
+		d.reset(bufio.NewReader(bytes.NewReader(data)))
+	}
+
 	var err error
 	idx.Version, err = validateHeader(d.r)
 	if err != nil {
@@ -98,7 +136,174 @@ func (d *Decoder) Decode(idx *Index) error {
 		return err
 	}
 
-	return d.readExtensions(idx)
+	if err := d.readExtensions(idx); err != nil {
+		return err
+	}
+
+	d.applyFSMonitorDirty(idx)
+	return nil
+}
+
+// decodeBlocks decodes the index file data with the entries read in
+// parallel, a block of its entry offset table each. It returns false, with
+// idx left untouched, if the index has no such table or it cannot be found,
+// for the index to be read as usual.
+func (d *Decoder) decodeBlocks(idx *Index, data []byte) (bool, error) {
+	end, table, ok := findEntryOffsetTable(data, d.hash.Size())
+	if !ok {
+		trace.Internal.Printf("index: no index entry offset table, decoding entries sequentially")
+		return false, nil
+	}
+
+	r := bytes.NewReader(data)
+	version, err := validateHeader(r)
+	if err != nil {
+		return true, err
+	}
+
+	count, err := binary.ReadUint32(r)
+	if err != nil {
+		return true, err
+	}
+
+	var total uint32
+	for _, b := range table.Blocks {
+		if b.Offset < 12 || b.Offset >= end {
+			return false, nil
+		}
+		total += b.Count
+	}
+	if total != count {
+		return false, nil
+	}
+
+	trace.Internal.Printf("index: decode %d entries in %d blocks", count, len(table.Blocks))
+
+	blocks := make([][]*Entry, len(table.Blocks))
+	var g errgroup.Group
+	g.SetLimit(d.threads)
+	for i, b := range table.Blocks {
+		g.Go(func() error {
+			block := &Index{Version: version}
+			dec := &Decoder{
+				r:     bufio.NewReader(bytes.NewReader(data[b.Offset:end])),
+				hash:  d.hash,
+				block: true,
+			}
+			if err := dec.readEntries(block, int(b.Count)); err != nil {
+				return err
+			}
+
+			blocks[i] = block.Entries
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return true, err
+	}
+
+	idx.Version = version
+	for _, entries := range blocks {
+		idx.Entries = append(idx.Entries, entries...)
+	}
+
+	// The extensions are read as usual, the checksum being computed over
+	// the entries read in parallel too.
+	d.reset(bufio.NewReader(bytes.NewReader(data[end:])))
+	if !d.skipHash {
+		d.hash.Write(data[:end])
+	}
+
+	if err := d.readExtensions(idx); err != nil {
+		return true, err
+	}
+
+	d.applyFSMonitorDirty(idx)
+	return true, nil
+}
+
+// findEntryOffsetTable finds, in the index file data, the end of the entries
+// and the entry offset table through the end of index entry extension,
+// which git writes last to be found from the end of the file.
+func findEntryOffsetTable(data []byte, hashSize int) (uint32, *EntryOffsetTable, bool) {
+	eoieSize := 4 + hashSize
+	eoieStart := len(data) - hashSize - eoieSize - 8
+	if eoieStart < 12 {
+		return 0, nil, false
+	}
+
+	header := data[eoieStart:]
+	if !bytes.Equal(header[:4], endOfIndexEntryExtSignature) ||
+		int(encbin.BigEndian.Uint32(header[4:8])) != eoieSize {
+		return 0, nil, false
+	}
+
+	var eoie EndOfIndexEntry
+	extDec := &endOfIndexEntryDecoder{bufio.NewReader(bytes.NewReader(header[8:])), hash.New(hashFunction(hashSize))}
+	if err := extDec.Decode(&eoie); err != nil || eoie.Offset < 12 || int(eoie.Offset) > eoieStart {
+		return 0, nil, false
+	}
+
+	// The hash of the extension is that of the signatures and sizes of the
+	// other extensions, so they can be told apart from entries.
+	h := hash.New(hashFunction(hashSize))
+	var table *EntryOffsetTable
+	for pos := int(eoie.Offset); pos < eoieStart; {
+		if pos+8 > eoieStart {
+			return 0, nil, false
+		}
+
+		signature := data[pos : pos+4]
+		size := int(encbin.BigEndian.Uint32(data[pos+4 : pos+8]))
+		h.Write(data[pos : pos+8])
+		if size > eoieStart-pos-8 {
+			return 0, nil, false
+		}
+
+		if bytes.Equal(signature, entryOffsetTableExtSignature) {
+			table = &EntryOffsetTable{}
+			extDec := &entryOffsetTableDecoder{bufio.NewReader(bytes.NewReader(data[pos+8 : pos+8+size]))}
+			if err := extDec.Decode(table); err != nil {
+				return 0, nil, false
+			}
+		}
+
+		pos += 8 + size
+	}
+
+	if !bytes.Equal(h.Sum(nil), eoie.Hash.Bytes()) || table == nil {
+		return 0, nil, false
+	}
+
+	return eoie.Offset, table, true
+}
+
+// hashFunction returns the hash function of the hashes of the given size.
+func hashFunction(size int) crypto.Hash {
+	if size == crypto.SHA256.Size() {
+		return crypto.SHA256
+	}
+	return crypto.SHA1
+}
+
+// applyFSMonitorDirty marks the entries the fsmonitor extension holds may
+// have changed. Its bitmap is of the entries of the whole index, so that of
+// a split index is kept to be applied as it is merged with its shared index.
+func (d *Decoder) applyFSMonitorDirty(idx *Index) {
+	dirty := d.fsMonitorDirty
+	if dirty == nil {
+		return
+	}
+
+	if s := idx.SplitIndex; s != nil && !s.BaseHash.IsZero() {
+		s.fsMonitorDirty = dirty
+		return
+	}
+
+	for i, e := range idx.Entries {
+		e.FSMonitorValid = !dirty.isSet(i)
+	}
 }
 
 func (d *Decoder) readEntries(idx *Index, count int) error {
@@ -232,7 +437,7 @@ func (d *Decoder) doReadEntryNameV4() (string, error) {
 				ErrMalformedIndexFile, l, len(d.lastEntry.Name))
 		}
 		base = d.lastEntry.Name[:len(d.lastEntry.Name)-int(l)]
-	} else if l > 0 {
+	} else if l > 0 && !d.block {
 		return "", fmt.Errorf("%w: non-zero strip length %d on first V4 entry",
 			ErrMalformedIndexFile, l)
 	}
@@ -265,9 +470,6 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read, nameConsumed int) error {
 }
 
 func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
-
 	var expected []byte
 	var peeked []byte
 	var err error
@@ -341,6 +543,55 @@ func (d *Decoder) readExtension(idx *Index) error {
 			return err
 		}
 		trace.Internal.Printf("index: end-of-index-entry extension decoded, offset %d hash %s", idx.EndOfIndexEntry.Offset, idx.EndOfIndexEntry.Hash)
+	case bytes.Equal(header[:], entryOffsetTableExtSignature):
+		trace.Internal.Printf("index: decoding index entry offset table extension")
+		idx.EntryOffsetTable = &EntryOffsetTable{}
+		extDec := &entryOffsetTableDecoder{r}
+		if err := extDec.Decode(idx.EntryOffsetTable); err != nil {
+			// As git, a table that cannot be read is dropped.
+			trace.Internal.Printf("index: dropping malformed index entry offset table extension: %v", err)
+			idx.EntryOffsetTable = nil
+			return (&unknownExtensionDecoder{r}).Decode()
+		}
+		trace.Internal.Printf("index: index entry offset table extension decoded, %d blocks", len(idx.EntryOffsetTable.Blocks))
+	case bytes.Equal(header[:], untrackedCacheExtSignature):
+		trace.Internal.Printf("index: decoding untracked cache extension")
+		idx.UntrackedCache = &UntrackedCache{}
+		extDec := &untrackedCacheDecoder{r, d.hash}
+		if err := extDec.Decode(idx.UntrackedCache); err != nil {
+			// As git, a cache that cannot be read is dropped.
+			trace.Internal.Printf("index: dropping malformed untracked cache extension: %v", err)
+			idx.UntrackedCache = nil
+			return (&unknownExtensionDecoder{r}).Decode()
+		}
+		trace.Internal.Printf("index: untracked cache extension decoded")
+	case bytes.Equal(header[:], fsMonitorExtSignature):
+		trace.Internal.Printf("index: decoding fsmonitor extension")
+		idx.FSMonitor = &FSMonitor{}
+		extDec := &fsMonitorDecoder{r}
+		dirty, err := extDec.Decode(idx.FSMonitor)
+		if err != nil {
+			trace.Internal.Printf("index: dropping malformed fsmonitor extension: %v", err)
+			idx.FSMonitor = nil
+			return (&unknownExtensionDecoder{r}).Decode()
+		}
+		d.fsMonitorDirty = dirty
+		trace.Internal.Printf("index: fsmonitor extension decoded, token %q", idx.FSMonitor.Token)
+	case bytes.Equal(header[:], splitIndexExtSignature):
+		trace.Internal.Printf("index: decoding split index extension")
+		idx.SplitIndex = &SplitIndex{}
+		extDec := &splitIndexDecoder{r, d.hash}
+		if err := extDec.Decode(idx.SplitIndex); err != nil {
+			return err
+		}
+		trace.Internal.Printf("index: split index extension decoded, shared index %s", idx.SplitIndex.BaseHash)
+	case bytes.Equal(header[:], sparseDirExtSignature):
+		// The extension has no data: it only tells the index may hold sparse
+		// directory entries, which Index.IsSparse finds out from the entries.
+		trace.Internal.Printf("index: sparse directory entries extension found")
+		if err := (&unknownExtensionDecoder{r}).Decode(); err != nil {
+			return err
+		}
 	default:
 		// See https://git-scm.com/docs/index-format, which says:
 		// If the first byte is 'A'..'Z' the extension is optional and can be ignored.
@@ -349,11 +600,12 @@ func (d *Decoder) readExtension(idx *Index) error {
 			return ErrUnknownExtension
 		}
 
-		trace.Internal.Printf("index: skipping optional unknown extension %s", string(header[:]))
-		extDec := &unknownExtensionDecoder{r}
-		if err := extDec.Decode(); err != nil {
+		trace.Internal.Printf("index: keeping optional unknown extension %s", string(header[:]))
+		data, err := io.ReadAll(r)
+		if err != nil {
 			return err
 		}
+		idx.Extensions = append(idx.Extensions, Extension{Signature: string(header[:]), Data: data})
 	}
 
 	return nil
@@ -519,6 +771,7 @@ func (d *resolveUndoDecoder) Decode(ru *ResolveUndo) error {
 func (d *resolveUndoDecoder) readEntry() (*ResolveUndoEntry, error) {
 	e := &ResolveUndoEntry{
 		Stages: make(map[Stage]plumbing.Hash),
+		Modes:  make(map[Stage]filemode.FileMode),
 	}
 
 	path, err := binary.ReadUntil(d.r, '\x00')
@@ -528,13 +781,18 @@ func (d *resolveUndoDecoder) readEntry() (*ResolveUndoEntry, error) {
 
 	e.Path = string(path)
 
-	for i := range 3 {
-		if err := d.readStage(e, Stage(i+1)); err != nil {
+	for s := AncestorMode; s <= TheirMode; s++ {
+		if err := d.readStage(e, s); err != nil {
 			return nil, err
 		}
 	}
 
-	for s := range e.Stages {
+	// The hashes follow in the order of the stages, for those with a mode.
+	for s := AncestorMode; s <= TheirMode; s++ {
+		if _, ok := e.Stages[s]; !ok {
+			continue
+		}
+
 		var h plumbing.Hash
 		h.ResetBySize(d.h.Size())
 		if _, err := h.ReadFrom(d.r); err != nil {
@@ -554,13 +812,14 @@ func (d *resolveUndoDecoder) readStage(e *ResolveUndoEntry, s Stage) error {
 		return err
 	}
 
-	stage, err := strconv.ParseInt(string(ascii), 8, 64)
+	mode, err := strconv.ParseUint(string(ascii), 8, 32)
 	if err != nil {
 		return err
 	}
 
-	if stage != 0 {
+	if mode != 0 {
 		e.Stages[s] = plumbing.ZeroHash
+		e.Modes[s] = filemode.FileMode(mode)
 	}
 
 	return nil
@@ -583,6 +842,303 @@ func (d *endOfIndexEntryDecoder) Decode(e *EndOfIndexEntry) error {
 	return err
 }
 
+type entryOffsetTableDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *entryOffsetTableDecoder) Decode(t *EntryOffsetTable) error {
+	version, err := binary.ReadUint32(d.r)
+	if err != nil {
+		return err
+	}
+
+	if version != 1 {
+		return fmt.Errorf("%w: unsupported index entry offset table version %d", ErrMalformedIndexFile, version)
+	}
+
+	for {
+		var b EntryOffsetBlock
+		if err := binary.Read(d.r, &b.Offset, &b.Count); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		t.Blocks = append(t.Blocks, b)
+	}
+}
+
+type untrackedCacheDecoder struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (d *untrackedCacheDecoder) Decode(uc *UntrackedCache) error {
+	identLen, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return err
+	}
+	if identLen < 0 || identLen > maxUntrackedCacheIdentLen {
+		return fmt.Errorf("%w: invalid untracked cache ident length %d", ErrMalformedIndexFile, identLen)
+	}
+
+	ident := make([]byte, identLen)
+	if _, err := io.ReadFull(d.r, ident); err != nil {
+		return err
+	}
+	if len(ident) > 0 {
+		for _, env := range bytes.Split(bytes.TrimSuffix(ident, []byte{0}), []byte{0}) {
+			uc.Environments = append(uc.Environments, string(env))
+		}
+	}
+
+	if err := readStatData(d.r, &uc.InfoExcludeStats); err != nil {
+		return err
+	}
+	if err := readStatData(d.r, &uc.ExcludesFileStats); err != nil {
+		return err
+	}
+	if uc.Flags, err = binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+	if err := d.readHash(&uc.InfoExcludeHash); err != nil {
+		return err
+	}
+	if err := d.readHash(&uc.ExcludesFileHash); err != nil {
+		return err
+	}
+
+	perDir, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+	if err != nil {
+		return err
+	}
+	uc.ExcludePerDir = string(perDir)
+
+	count, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return d.discard()
+	}
+
+	// The blocks are in depth-first order, each followed by those of its
+	// sub-directories.
+	var dirs []*UntrackedCacheDir
+	var readDir func() (*UntrackedCacheDir, error)
+	readDir = func() (*UntrackedCacheDir, error) {
+		if int64(len(dirs)) >= count {
+			return nil, fmt.Errorf("%w: too many untracked cache directories", ErrMalformedIndexFile)
+		}
+
+		untracked, err := binary.ReadVariableWidthInt(d.r)
+		if err != nil {
+			return nil, err
+		}
+		subdirs, err := binary.ReadVariableWidthInt(d.r)
+		if err != nil {
+			return nil, err
+		}
+		if untracked < 0 || subdirs < 0 || subdirs > count {
+			return nil, fmt.Errorf("%w: invalid untracked cache directory", ErrMalformedIndexFile)
+		}
+
+		name, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+
+		dir := &UntrackedCacheDir{Name: string(name)}
+		dirs = append(dirs, dir)
+		for range untracked {
+			name, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+			if err != nil {
+				return nil, err
+			}
+			dir.Untracked = append(dir.Untracked, string(name))
+		}
+
+		for range subdirs {
+			sub, err := readDir()
+			if err != nil {
+				return nil, err
+			}
+			dir.Dirs = append(dir.Dirs, sub)
+		}
+
+		return dir, nil
+	}
+
+	if uc.Root, err = readDir(); err != nil {
+		return err
+	}
+
+	valid, err := decodeEWAH(d.r)
+	if err != nil {
+		return err
+	}
+	checkOnly, err := decodeEWAH(d.r)
+	if err != nil {
+		return err
+	}
+	hashValid, err := decodeEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	inRange := func(i int) error {
+		if i >= len(dirs) {
+			return fmt.Errorf("%w: untracked cache bitmap past the directories", ErrMalformedIndexFile)
+		}
+		return nil
+	}
+
+	err = valid.forEach(func(i int) error {
+		if err := inRange(i); err != nil {
+			return err
+		}
+		dirs[i].Valid = true
+		return readStatData(d.r, &dirs[i].Stats)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = checkOnly.forEach(func(i int) error {
+		if err := inRange(i); err != nil {
+			return err
+		}
+		dirs[i].CheckOnly = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = hashValid.forEach(func(i int) error {
+		if err := inRange(i); err != nil {
+			return err
+		}
+		return d.readHash(&dirs[i].ExcludeHash)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only valid blocks have untracked entries.
+	for _, dir := range dirs {
+		if !dir.Valid {
+			dir.Untracked = nil
+		}
+	}
+
+	return d.discard()
+}
+
+func (d *untrackedCacheDecoder) readHash(h *plumbing.Hash) error {
+	h.ResetBySize(d.h.Size())
+	_, err := h.ReadFrom(d.r)
+	return err
+}
+
+// discard skips the trailing NUL ending the extension.
+func (d *untrackedCacheDecoder) discard() error {
+	_, err := io.Copy(io.Discard, d.r)
+	return err
+}
+
+func readStatData(r io.Reader, s *StatData) error {
+	var sec, nsec, msec, mnsec uint32
+	if err := binary.Read(r, &sec, &nsec, &msec, &mnsec, &s.Dev, &s.Inode, &s.UID, &s.GID, &s.Size); err != nil {
+		return err
+	}
+
+	s.CreatedAt, s.ModifiedAt = time.Time{}, time.Time{}
+	if sec != 0 || nsec != 0 {
+		s.CreatedAt = time.Unix(int64(sec), int64(nsec))
+	}
+	if msec != 0 || mnsec != 0 {
+		s.ModifiedAt = time.Unix(int64(msec), int64(mnsec))
+	}
+
+	return nil
+}
+
+type fsMonitorDecoder struct {
+	r *bufio.Reader
+}
+
+// Decode reads the extension into m, and returns its bitmap of the entries
+// that may have changed.
+func (d *fsMonitorDecoder) Decode(m *FSMonitor) (*ewahBitmap, error) {
+	version, err := binary.ReadUint32(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch version {
+	case 1:
+		ns, err := binary.ReadUint64(d.r)
+		if err != nil {
+			return nil, err
+		}
+		m.Token = strconv.FormatUint(ns, 10)
+	case 2:
+		token, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+		m.Token = string(token)
+	default:
+		return nil, fmt.Errorf("%w: unsupported fsmonitor extension version %d", ErrMalformedIndexFile, version)
+	}
+
+	if _, err := binary.ReadUint32(d.r); err != nil {
+		return nil, err
+	}
+
+	dirty, err := decodeEWAH(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(io.Discard, d.r)
+	return dirty, err
+}
+
+type splitIndexDecoder struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (d *splitIndexDecoder) Decode(s *SplitIndex) error {
+	s.BaseHash.ResetBySize(d.h.Size())
+	if _, err := s.BaseHash.ReadFrom(d.r); err != nil {
+		return err
+	}
+
+	// The bitmaps are left out when the index has no shared index.
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil
+	}
+
+	deleted, err := decodeEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	replaced, err := decodeEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	s.deleted, s.replaced = deleted, replaced
+	_, err = io.Copy(io.Discard, d.r)
+	return err
+}
+
 type unknownExtensionDecoder struct {
 	r *bufio.Reader
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"crypto"
+	encbin "encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -384,6 +385,7 @@ func TestDecodeUnknownOptionalExt(t *testing.T) {
 	d := NewDecoder(f, crypto.SHA1.New())
 	err := d.Decode(idx)
 	require.NoError(t, err)
+	assert.Equal(t, []Extension{{Signature: "TEST", Data: []byte("testdata")}}, idx.Extensions)
 }
 
 func TestDecodeUnknownMandatoryExt(t *testing.T) {
@@ -396,6 +398,58 @@ func TestDecodeUnknownMandatoryExt(t *testing.T) {
 	assert.ErrorContains(t, err, ErrUnknownExtension.Error())
 }
 
+// extensionBytes returns the extension of the given signature of the index
+// file data, with its header.
+func extensionBytes(tb testing.TB, data []byte, signature string) []byte {
+	tb.Helper()
+
+	i := bytes.Index(data, []byte(signature))
+	require.GreaterOrEqual(tb, i, 0, signature)
+	size := encbin.BigEndian.Uint32(data[i+4 : i+8])
+	return data[i : i+8+int(size)]
+}
+
+func TestDecodeResolveUndoRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dotgit, err := fixtures.Basic().ByTag("resolve-undo").One().DotGit()
+	require.NoError(t, err)
+	f, err := dotgit.Open("index")
+	require.NoError(t, err)
+	raw, err := io.ReadAll(f)
+	require.NoError(t, f.Close())
+	require.NoError(t, err)
+
+	idx := &Index{}
+	require.NoError(t, NewDecoder(bytes.NewReader(raw), crypto.SHA1.New()).Decode(idx))
+	require.Len(t, idx.ResolveUndo.Entries, 2)
+	e := idx.ResolveUndo.Entries[1]
+	assert.Equal(t, "haskal/haskal.hs", e.Path)
+	assert.Len(t, e.Stages, 2)
+	assert.Equal(t, filemode.Regular, e.Modes[OurMode])
+	assert.False(t, e.Stages[OurMode].IsZero())
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(idx))
+	assert.Equal(t, extensionBytes(t, raw, "REUC"), extensionBytes(t, buf.Bytes(), "REUC"))
+}
+
+func TestDecodeThreadsWithoutEntryOffsetTable(t *testing.T) {
+	t.Parallel()
+
+	dotgit, err := fixtures.Basic().ByTag("end-of-index-entry").One().DotGit()
+	require.NoError(t, err)
+	f, err := dotgit.Open("index")
+	require.NoError(t, err)
+	defer f.Close()
+
+	idx := &Index{}
+	require.NoError(t, NewDecoder(f, crypto.SHA1.New(), WithThreads(4)).Decode(idx))
+	assert.Len(t, idx.Entries, 9)
+	require.NotNil(t, idx.EndOfIndexEntry)
+	assert.Nil(t, idx.EntryOffsetTable)
+}
+
 func TestDecodeTruncatedExt(t *testing.T) {
 	t.Parallel()
 	idx := readSimpleIndex(t)
@@ -502,7 +556,8 @@ func TestDecodeSkipHashWithKnownAndUnknownExtensions(t *testing.T) {
 	// Strip the trailing checksum, keeping header + entries + TREE extension.
 	body := raw[:len(raw)-hashSize]
 
-	// Append unknown optional extensions (matching UNTR + FSMN scenario).
+	// Append malformed UNTR and FSMN extensions, which are dropped as git
+	// does.
 	var extra bytes.Buffer
 	for _, sig := range []string{"UNTR", "FSMN"} {
 		extra.Write([]byte(sig))
@@ -523,6 +578,8 @@ func TestDecodeSkipHashWithKnownAndUnknownExtensions(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, idx.Cache, "TREE cache should be decoded")
 	assert.Len(t, idx.Entries, 9)
+	assert.Nil(t, idx.UntrackedCache)
+	assert.Nil(t, idx.FSMonitor)
 }
 
 func TestDecodeInvalidHash(t *testing.T) {
@@ -2,12 +2,16 @@ package index
 
 import (
 	"bytes"
+	encbin "encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"time"
 
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/hash"
 	"github.com/go-git/go-git/v6/utils/binary"
 )
@@ -27,6 +31,12 @@ type Encoder struct {
 	hash      hash.Hash
 	lastEntry *Entry
 	skipHash  bool
+	opts      options
+	// offset is the number of bytes written.
+	offset int64
+	// blockStart is set as a block of the entry offset table starts, for
+	// the name of its first entry to be written whole.
+	blockStart bool
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -39,34 +49,59 @@ func NewEncoder(w io.Writer, h hash.Hash, opts ...Option) *Encoder {
 	e := &Encoder{
 		hash:     h,
 		skipHash: cfg.skipHash,
+		opts:     cfg,
 	}
 
-	if e.skipHash {
-		e.w = w
-	} else {
+	if !e.skipHash {
 		h.Reset()
-		e.w = io.MultiWriter(w, h)
+		w = io.MultiWriter(w, h)
 	}
+	e.w = &offsetWriter{w: w, offset: &e.offset}
 
 	return e
 }
 
+// offsetWriter counts the bytes written to w.
+type offsetWriter struct {
+	w      io.Writer
+	offset *int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	*w.offset += int64(n)
+	return n, err
+}
+
 // Encode writes the Index to the stream of the encoder.
 func (e *Encoder) Encode(idx *Index) error {
 	return e.encode(idx, true)
 }
 
 func (e *Encoder) encode(idx *Index, footer bool) error {
-	// TODO: support extensions
 	if idx.Version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
-	if err := e.encodeHeader(idx); err != nil {
+	sort.Sort(byNameAndStage(idx.Entries))
+
+	// A split index only writes the changes made to its shared index.
+	entries := idx.Entries
+	var deleted, replaced *ewahBitmap
+	if s := idx.SplitIndex; s != nil && s.Base != nil {
+		entries, deleted, replaced = s.split(idx.Entries)
+	}
+
+	if err := e.encodeHeader(idx, len(entries)); err != nil {
 		return err
 	}
 
-	if err := e.encodeEntries(idx); err != nil {
+	table, err := e.encodeEntries(idx, entries)
+	if err != nil {
+		return err
+	}
+
+	if err := e.encodeExtensions(idx, table, deleted, replaced); err != nil {
 		return err
 	}
 
@@ -76,20 +111,46 @@ func (e *Encoder) encode(idx *Index, footer bool) error {
 	return nil
 }
 
-func (e *Encoder) encodeHeader(idx *Index) error {
+func (e *Encoder) encodeHeader(idx *Index, count int) error {
 	return binary.Write(e.w,
 		indexSignature,
 		idx.Version,
-		uint32(len(idx.Entries)),
+		uint32(count),
 	)
 }
 
-func (e *Encoder) encodeEntries(idx *Index) error {
-	sort.Sort(byNameAndStage(idx.Entries))
+// encodeEntries writes entries, and returns their entry offset table if
+// they are split in blocks for one to be written.
+func (e *Encoder) encodeEntries(idx *Index, entries []*Entry) (*EntryOffsetTable, error) {
+	blocks := 0
+	if idx.EntryOffsetTable != nil {
+		blocks = len(idx.EntryOffsetTable.Blocks)
+	}
+	if e.opts.setOffsetBlocks {
+		blocks = e.opts.offsetBlocks
+	}
+	blocks = min(blocks, len(entries))
+
+	var table *EntryOffsetTable
+	perBlock := len(entries)
+	if blocks > 1 {
+		table = &EntryOffsetTable{}
+		perBlock = (len(entries) + blocks - 1) / blocks
+	}
+
+	for i, entry := range entries {
+		// Each block is read on its own, so with version 4 the name of its
+		// first entry is not compressed.
+		if table != nil && i%perBlock == 0 {
+			table.Blocks = append(table.Blocks, EntryOffsetBlock{Offset: uint32(e.offset)})
+			e.blockStart = true
+		}
+		if table != nil {
+			table.Blocks[len(table.Blocks)-1].Count++
+		}
 
-	for _, entry := range idx.Entries {
 		if err := e.encodeEntry(idx, entry); err != nil {
-			return err
+			return nil, err
 		}
 		entryLength := entryHeaderLength + e.hash.Size()
 		if entry.IntentToAdd || entry.SkipWorktree {
@@ -98,11 +159,11 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 
 		wrote := entryLength + len(entry.Name)
 		if err := e.padEntry(idx, wrote); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return table, nil
 }
 
 func (e *Encoder) encodeEntry(idx *Index, entry *Entry) error {
@@ -179,7 +240,7 @@ func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
 	// the decoder how many bytes to remove from the end of the previous
 	// name, and the suffix is the remainder of the current name.
 	prefix := 0
-	if e.lastEntry != nil {
+	if e.lastEntry != nil && !e.blockStart {
 		prefix = commonPrefixLen(e.lastEntry.Name, entry.Name)
 	}
 	stripLen := 0
@@ -188,6 +249,7 @@ func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
 	}
 
 	e.lastEntry = entry
+	e.blockStart = false
 
 	if err := binary.WriteVariableWidthInt(e.w, int64(stripLen)); err != nil {
 		return err
@@ -209,6 +271,324 @@ func commonPrefixLen(a, b string) int {
 	return n
 }
 
+// encodeExtensions writes the extensions of idx go-git maintains, in the
+// order git writes them, and the optional extensions it does not know. The
+// cached tree, which would not be kept up to date as the entries change, is
+// dropped. table is the entry offset table of the entries written, if any,
+// and deleted and replaced are the bitmaps of the split index, if they are
+// the changes made to its shared index.
+func (e *Encoder) encodeExtensions(idx *Index, table *EntryOffsetTable, deleted, replaced *ewahBitmap) error {
+	start := e.offset
+	var headers []byte
+
+	encode := func(signature []byte, f func(w *bytes.Buffer) error) error {
+		var buf bytes.Buffer
+		if err := f(&buf); err != nil {
+			return err
+		}
+
+		headers = append(headers, signature...)
+		headers = encbin.BigEndian.AppendUint32(headers, uint32(buf.Len()))
+		return e.encodeRawExtension(string(signature), buf.Bytes())
+	}
+
+	// The entry offset table comes first, to be found quickly.
+	if table != nil {
+		err := encode(entryOffsetTableExtSignature, func(w *bytes.Buffer) error {
+			return e.encodeEntryOffsetTable(w, table)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if idx.SplitIndex != nil {
+		err := encode(splitIndexExtSignature, func(w *bytes.Buffer) error {
+			return e.encodeSplitIndex(w, idx.SplitIndex, deleted, replaced)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if idx.ResolveUndo != nil && len(idx.ResolveUndo.Entries) > 0 {
+		err := encode(resolveUndoExtSignature, func(w *bytes.Buffer) error {
+			return e.encodeResolveUndo(w, idx.ResolveUndo)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if idx.UntrackedCache != nil {
+		err := encode(untrackedCacheExtSignature, func(w *bytes.Buffer) error {
+			return e.encodeUntrackedCache(w, idx.UntrackedCache)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if idx.FSMonitor != nil {
+		err := encode(fsMonitorExtSignature, func(w *bytes.Buffer) error {
+			return e.encodeFSMonitor(w, idx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if idx.IsSparse() {
+		err := encode(sparseDirExtSignature, func(*bytes.Buffer) error { return nil })
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ext := range idx.Extensions {
+		if len(ext.Signature) != 4 || ext.Signature[0] < 'A' || ext.Signature[0] > 'Z' {
+			return fmt.Errorf("invalid optional extension signature %q", ext.Signature)
+		}
+
+		err := encode([]byte(ext.Signature), func(w *bytes.Buffer) error {
+			_, err := w.Write(ext.Data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	idx.EntryOffsetTable = table
+
+	eoie := idx.EndOfIndexEntry != nil
+	if e.opts.setEndOfIndexEntry {
+		eoie = e.opts.endOfIndexEntry
+	}
+	if !eoie && table == nil {
+		idx.EndOfIndexEntry = nil
+		return nil
+	}
+
+	// The end of index entry extension must come last.
+	h := hash.New(hashFunction(e.hash.Size()))
+	h.Write(headers)
+	idx.EndOfIndexEntry = &EndOfIndexEntry{Offset: uint32(start)}
+	idx.EndOfIndexEntry.Hash, _ = plumbing.FromBytes(h.Sum(nil))
+
+	var buf bytes.Buffer
+	if err := binary.WriteUint32(&buf, idx.EndOfIndexEntry.Offset); err != nil {
+		return err
+	}
+	buf.Write(h.Sum(nil))
+	return e.encodeRawExtension(string(endOfIndexEntryExtSignature), buf.Bytes())
+}
+
+func (e *Encoder) encodeEntryOffsetTable(w *bytes.Buffer, t *EntryOffsetTable) error {
+	if err := binary.WriteUint32(w, 1); err != nil {
+		return err
+	}
+
+	for _, b := range t.Blocks {
+		if err := binary.Write(w, b.Offset, b.Count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeResolveUndo(w *bytes.Buffer, ru *ResolveUndo) error {
+	for _, entry := range ru.Entries {
+		w.WriteString(entry.Path)
+		w.WriteByte(0)
+
+		for s := AncestorMode; s <= TheirMode; s++ {
+			var mode filemode.FileMode
+			if _, ok := entry.Stages[s]; ok {
+				mode = filemode.Regular
+				if m, ok := entry.Modes[s]; ok && m != filemode.Empty {
+					mode = m
+				}
+			}
+
+			w.WriteString(strconv.FormatUint(uint64(mode), 8))
+			w.WriteByte(0)
+		}
+
+		for s := AncestorMode; s <= TheirMode; s++ {
+			if h, ok := entry.Stages[s]; ok {
+				w.Write(e.hashBytes(h))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeUntrackedCache(w *bytes.Buffer, uc *UntrackedCache) error {
+	var ident bytes.Buffer
+	for _, env := range uc.Environments {
+		ident.WriteString(env)
+		ident.WriteByte(0)
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(ident.Len())); err != nil {
+		return err
+	}
+	w.Write(ident.Bytes())
+
+	if err := e.encodeStatData(w, &uc.InfoExcludeStats); err != nil {
+		return err
+	}
+	if err := e.encodeStatData(w, &uc.ExcludesFileStats); err != nil {
+		return err
+	}
+	if err := binary.WriteUint32(w, uc.Flags); err != nil {
+		return err
+	}
+	w.Write(e.hashBytes(uc.InfoExcludeHash))
+	w.Write(e.hashBytes(uc.ExcludesFileHash))
+	w.WriteString(uc.ExcludePerDir)
+	w.WriteByte(0)
+
+	if uc.Root == nil {
+		return binary.WriteVariableWidthInt(w, 0)
+	}
+
+	var (
+		blocks                  bytes.Buffer
+		stats, hashes           bytes.Buffer
+		valid, checkOnly, known ewahBitmap
+		count                   int
+	)
+
+	var writeDir func(d *UntrackedCacheDir) error
+	writeDir = func(d *UntrackedCacheDir) error {
+		i := count
+		count++
+
+		var untracked []string
+		if d.Valid {
+			untracked = d.Untracked
+			valid.set(i)
+			if err := e.encodeStatData(&stats, &d.Stats); err != nil {
+				return err
+			}
+			if d.CheckOnly {
+				checkOnly.set(i)
+			}
+		}
+		if !d.ExcludeHash.IsZero() {
+			known.set(i)
+			hashes.Write(e.hashBytes(d.ExcludeHash))
+		}
+
+		if err := binary.WriteVariableWidthInt(&blocks, int64(len(untracked))); err != nil {
+			return err
+		}
+		if err := binary.WriteVariableWidthInt(&blocks, int64(len(d.Dirs))); err != nil {
+			return err
+		}
+		blocks.WriteString(d.Name)
+		blocks.WriteByte(0)
+		for _, name := range untracked {
+			blocks.WriteString(name)
+			blocks.WriteByte(0)
+		}
+
+		for _, sub := range d.Dirs {
+			if err := writeDir(sub); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := writeDir(uc.Root); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(count)); err != nil {
+		return err
+	}
+	w.Write(blocks.Bytes())
+	for _, b := range []*ewahBitmap{&valid, &checkOnly, &known} {
+		if err := b.encode(w); err != nil {
+			return err
+		}
+	}
+	w.Write(stats.Bytes())
+	w.Write(hashes.Bytes())
+
+	return w.WriteByte(0)
+}
+
+func (e *Encoder) encodeSplitIndex(w *bytes.Buffer, s *SplitIndex, deleted, replaced *ewahBitmap) error {
+	// With no shared index to split from, all the entries were written and
+	// the index must not point to one.
+	if s.Base == nil {
+		w.Write(e.hashBytes(plumbing.ZeroHash))
+		deleted, replaced = &ewahBitmap{}, &ewahBitmap{}
+	} else {
+		w.Write(e.hashBytes(s.BaseHash))
+	}
+
+	if err := deleted.encode(w); err != nil {
+		return err
+	}
+	return replaced.encode(w)
+}
+
+func (e *Encoder) encodeFSMonitor(w *bytes.Buffer, idx *Index) error {
+	var dirty ewahBitmap
+	for i, entry := range idx.Entries {
+		if !entry.FSMonitorValid {
+			dirty.set(i)
+		}
+	}
+
+	var bitmap bytes.Buffer
+	if err := dirty.encode(&bitmap); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(w, 2); err != nil {
+		return err
+	}
+	w.WriteString(idx.FSMonitor.Token)
+	w.WriteByte(0)
+	if err := binary.WriteUint32(w, uint32(bitmap.Len())); err != nil {
+		return err
+	}
+	_, err := w.Write(bitmap.Bytes())
+	return err
+}
+
+func (e *Encoder) encodeStatData(w io.Writer, s *StatData) error {
+	sec, nsec, err := e.timeToUint32(&s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	msec, mnsec, err := e.timeToUint32(&s.ModifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w, sec, nsec, msec, mnsec, s.Dev, s.Inode, s.UID, s.GID, s.Size)
+}
+
+// hashBytes returns the bytes of h, or a null hash of the size of the
+// hash of the encoder if h is the zero value or of another size.
+func (e *Encoder) hashBytes(h plumbing.Hash) []byte {
+	if h.IsZero() || h.Size() != e.hash.Size() {
+		return make([]byte, e.hash.Size())
+	}
+	return h.Bytes()
+}
+
 func (e *Encoder) encodeRawExtension(signature string, data []byte) error {
 	if len(signature) != 4 {
 		return fmt.Errorf("invalid signature length")
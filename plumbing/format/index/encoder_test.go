@@ -3,6 +3,7 @@ package index
 import (
 	"bytes"
 	"crypto"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 )
 
 func TestEncode(t *testing.T) {
@@ -339,3 +341,201 @@ func TestEncodeSkipHash(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeUntrackedCacheAndFSMonitor(t *testing.T) {
+	t.Parallel()
+
+	stats := StatData{
+		CreatedAt:  time.Unix(1700000000, 12),
+		ModifiedAt: time.Unix(1700000001, 34),
+		Dev:        1,
+		Inode:      2,
+		Size:       4096,
+	}
+
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "a/f", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), FSMonitorValid: true},
+			{Name: "b", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Name: "c", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), FSMonitorValid: true},
+		},
+		UntrackedCache: &UntrackedCache{
+			Environments:    []string{"Location /tmp/repo, system Linux"},
+			InfoExcludeHash: plumbing.NewHash("cc30ca8b9b10bb92f8e5c96ee94348c6c4ac93e6"),
+			ExcludePerDir:   ".gitignore",
+			Root: &UntrackedCacheDir{
+				Valid:       true,
+				Untracked:   []string{"u"},
+				Stats:       stats,
+				ExcludeHash: plumbing.NewHash("397b4a7624e35fa60563a9c03b1213d93f7b6546"),
+				Dirs: []*UntrackedCacheDir{
+					{Name: "a", Valid: true, Stats: stats, Dirs: []*UntrackedCacheDir{
+						{Name: "b", Valid: true, CheckOnly: true, Untracked: []string{"x", "y"}, Stats: stats},
+					}},
+					{Name: "c"},
+				},
+			},
+		},
+		FSMonitor: &FSMonitor{Token: "token"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf, crypto.SHA1.New()).Decode(output))
+	assert.Equal(t, idx.UntrackedCache, output.UntrackedCache)
+	assert.Equal(t, idx.FSMonitor, output.FSMonitor)
+	for i, e := range idx.Entries {
+		assert.Equal(t, e.FSMonitorValid, output.Entries[i].FSMonitorValid, e.Name)
+	}
+}
+
+func TestEncodeEmptyUntrackedCache(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version:        2,
+		UntrackedCache: &UntrackedCache{ExcludePerDir: ".gitignore"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf, crypto.SHA1.New()).Decode(output))
+	assert.Equal(t, idx.UntrackedCache, output.UntrackedCache)
+}
+
+func TestEncodeResolveUndo(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version: 2,
+		ResolveUndo: &ResolveUndo{Entries: []ResolveUndoEntry{{
+			Path: "go/example.go",
+			Stages: map[Stage]plumbing.Hash{
+				AncestorMode: plumbing.NewHash("880cd14280f4b9b6ed3986d6671f907d7cc2a198"),
+				OurMode:      plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa"),
+				TheirMode:    plumbing.NewHash("9a48f23120e880dfbe41f7c9b7b708e9ee62a492"),
+			},
+			Modes: map[Stage]filemode.FileMode{
+				AncestorMode: filemode.Regular,
+				OurMode:      filemode.Executable,
+				TheirMode:    filemode.Regular,
+			},
+		}, {
+			Path: "haskal/haskal.hs",
+			Stages: map[Stage]plumbing.Hash{
+				OurMode:   plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa"),
+				TheirMode: plumbing.NewHash("9a48f23120e880dfbe41f7c9b7b708e9ee62a492"),
+			},
+		}}},
+	}
+
+	output := encodeDecode(t, idx)
+	require.NotNil(t, output.ResolveUndo)
+	require.Len(t, output.ResolveUndo.Entries, 2)
+	assert.Equal(t, idx.ResolveUndo.Entries[0], output.ResolveUndo.Entries[0])
+
+	// A stage with no mode is written as a regular file.
+	assert.Equal(t, idx.ResolveUndo.Entries[1].Stages, output.ResolveUndo.Entries[1].Stages)
+	assert.Equal(t, map[Stage]filemode.FileMode{
+		OurMode:   filemode.Regular,
+		TheirMode: filemode.Regular,
+	}, output.ResolveUndo.Entries[1].Modes)
+}
+
+func TestEncodeUnknownExtensions(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version:    2,
+		Entries:    []*Entry{{Name: "a", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")}},
+		Extensions: []Extension{{Signature: "TEST", Data: []byte("test\x00data")}, {Signature: "ZERO", Data: []byte{}}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(idx))
+	assert.Contains(t, buf.String(), "TEST\x00\x00\x00\x09test\x00dataZERO\x00\x00\x00\x00")
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf, crypto.SHA1.New()).Decode(output))
+	assert.Equal(t, idx.Extensions, output.Extensions)
+
+	idx.Extensions = []Extension{{Signature: "test"}}
+	require.Error(t, NewEncoder(bytes.NewBuffer(nil), crypto.SHA1.New()).Encode(idx))
+}
+
+func TestEncodeEntryOffsetTable(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []uint32{2, 4} {
+		t.Run(fmt.Sprintf("Version %d", version), func(t *testing.T) {
+			t.Parallel()
+
+			idx := &Index{Version: version}
+			for i := range 10 {
+				idx.Entries = append(idx.Entries, &Entry{
+					Name: fmt.Sprintf("dir/file-%02d", i),
+					Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+					Size: uint32(i),
+				})
+			}
+			idx.FSMonitor = &FSMonitor{Token: "token"}
+			idx.Entries[3].FSMonitorValid = true
+
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, NewEncoder(buf, crypto.SHA1.New(), WithEntryOffsetTable(3)).Encode(idx))
+			require.NotNil(t, idx.EntryOffsetTable)
+			require.NotNil(t, idx.EndOfIndexEntry)
+
+			var counts []uint32
+			for _, b := range idx.EntryOffsetTable.Blocks {
+				counts = append(counts, b.Count)
+			}
+			assert.Equal(t, []uint32{4, 4, 2}, counts)
+
+			data := buf.Bytes()
+			sequential := &Index{}
+			require.NoError(t, NewDecoder(bytes.NewReader(data), crypto.SHA1.New()).Decode(sequential))
+			parallel := &Index{}
+			require.NoError(t, NewDecoder(bytes.NewReader(data), crypto.SHA1.New(), WithThreads(4)).Decode(parallel))
+
+			assert.EqualExportedValues(t, idx, sequential)
+			assert.EqualExportedValues(t, idx, parallel)
+
+			// The table of the index is kept as it is written again.
+			buf.Reset()
+			require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(parallel))
+			assert.Equal(t, data, buf.Bytes())
+
+			buf.Reset()
+			require.NoError(t, NewEncoder(buf, crypto.SHA1.New(), WithEntryOffsetTable(0), WithEndOfIndexEntry(false)).Encode(parallel))
+			assert.Nil(t, parallel.EntryOffsetTable)
+			assert.Nil(t, parallel.EndOfIndexEntry)
+			assert.NotContains(t, buf.String(), "IEOT")
+			assert.NotContains(t, buf.String(), "EOIE")
+		})
+	}
+}
+
+func TestEncodeEndOfIndexEntry(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "a", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New(), WithEndOfIndexEntry(true)).Encode(idx))
+	require.NotNil(t, idx.EndOfIndexEntry)
+	assert.Nil(t, idx.EntryOffsetTable)
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf, crypto.SHA1.New(), WithThreads(4)).Decode(output))
+	assert.Equal(t, idx.EndOfIndexEntry, output.EndOfIndexEntry)
+	assert.Len(t, output.Entries, 1)
+}
@@ -0,0 +1,189 @@
+package index
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+const (
+	ewahWordBits      = 64
+	ewahMaxRunLength  = 1<<32 - 1
+	ewahMaxLiteralLen = 1<<31 - 1
+)
+
+// ewahBitmap is a set of bit positions, read and written with the EWAH
+// compressed bitmap format git uses in the index extensions:
+//
+//   - 32-bit number of bits
+//   - 32-bit number of 64-bit words
+//   - the 64-bit words, each either a literal word or a run length word
+//     (RLW), whose bit 0 is the bit the run is made of, bits 1 to 32 the
+//     number of words of the run, and bits 33 to 63 the number of literal
+//     words following it
+//   - 32-bit position of the last RLW
+//
+// All the numbers are in network byte order.
+type ewahBitmap struct {
+	bits []bool
+}
+
+// set sets the bit at position i.
+func (b *ewahBitmap) set(i int) {
+	for len(b.bits) <= i {
+		b.bits = append(b.bits, false)
+	}
+	b.bits[i] = true
+}
+
+// isSet reports whether the bit at position i is set.
+func (b *ewahBitmap) isSet(i int) bool {
+	return i < len(b.bits) && b.bits[i]
+}
+
+// forEach calls fn with the position of each set bit, in increasing order.
+func (b *ewahBitmap) forEach(fn func(i int) error) error {
+	for i, set := range b.bits {
+		if !set {
+			continue
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count returns the number of set bits.
+func (b *ewahBitmap) count() int {
+	n := 0
+	for _, set := range b.bits {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *ewahBitmap) word(i int) uint64 {
+	var w uint64
+	for j := range ewahWordBits {
+		if b.isSet(i*ewahWordBits + j) {
+			w |= 1 << j
+		}
+	}
+	return w
+}
+
+// encode writes the bitmap to w.
+func (b *ewahBitmap) encode(w io.Writer) error {
+	count := (len(b.bits) + ewahWordBits - 1) / ewahWordBits
+
+	// Each RLW holds a run of clean words, made only of zeros or only of
+	// ones, and counts the literal words following it, up to the next
+	// clean one.
+	var words []uint64
+	var rlw int
+	for i := 0; ; {
+		words = append(words, 0)
+		rlw = len(words) - 1
+
+		var run, runBit uint64
+		if i < count {
+			if cw := b.word(i); isCleanWord(cw) {
+				runBit = cw & 1
+				for i < count && b.word(i) == cw && run < ewahMaxRunLength {
+					i++
+					run++
+				}
+			}
+		}
+
+		var literals uint64
+		for i < count && literals < ewahMaxLiteralLen {
+			lw := b.word(i)
+			if isCleanWord(lw) {
+				break
+			}
+			words = append(words, lw)
+			literals++
+			i++
+		}
+
+		words[rlw] = runBit | run<<1 | literals<<33
+		if i >= count {
+			break
+		}
+	}
+
+	if err := binary.Write(w, uint32(len(b.bits)), uint32(len(words))); err != nil {
+		return err
+	}
+	for _, word := range words {
+		if err := binary.WriteUint64(w, word); err != nil {
+			return err
+		}
+	}
+	return binary.WriteUint32(w, uint32(rlw))
+}
+
+func isCleanWord(w uint64) bool {
+	return w == 0 || w == ^uint64(0)
+}
+
+// decodeEWAH reads a bitmap from r.
+func decodeEWAH(r io.Reader) (*ewahBitmap, error) {
+	var size, count uint32
+	if err := binary.Read(r, &size, &count); err != nil {
+		return nil, err
+	}
+
+	words := make([]uint64, 0, min(count, 1<<16))
+	for range count {
+		w, err := binary.ReadUint64(r)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+
+	if _, err := binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	b := &ewahBitmap{}
+	pos := 0
+	for i := 0; i < len(words); {
+		rlw := words[i]
+		i++
+
+		run := int((rlw >> 1) & ewahMaxRunLength)
+		literals := int(rlw >> 33)
+		if run > int(size) || i+literals > len(words) {
+			return nil, fmt.Errorf("%w: ewah bitmap words past its end", ErrMalformedIndexFile)
+		}
+
+		if rlw&1 != 0 {
+			for j := range run * ewahWordBits {
+				if pos+j >= int(size) {
+					break
+				}
+				b.set(pos + j)
+			}
+		}
+		pos += run * ewahWordBits
+
+		for _, lw := range words[i : i+literals] {
+			for j := range ewahWordBits {
+				if lw&(1<<j) != 0 {
+					b.set(pos + j)
+				}
+			}
+			pos += ewahWordBits
+		}
+		i += literals
+	}
+
+	return b, nil
+}
@@ -0,0 +1,88 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEWAHBitmapRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]int{
+		"empty":   nil,
+		"first":   {0},
+		"literal": {1, 3, 63, 64, 100},
+		"run":     {500, 1000},
+		"ones run": func() []int {
+			var bits []int
+			for i := range 200 {
+				bits = append(bits, i)
+			}
+			return append(bits, 300)
+		}(),
+	}
+
+	for name, bits := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var b ewahBitmap
+			for _, i := range bits {
+				b.set(i)
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, b.encode(&buf))
+
+			decoded, err := decodeEWAH(&buf)
+			require.NoError(t, err)
+			assert.Zero(t, buf.Len())
+
+			var got []int
+			require.NoError(t, decoded.forEach(func(i int) error {
+				got = append(got, i)
+				return nil
+			}))
+			assert.Equal(t, bits, got)
+		})
+	}
+}
+
+func TestEWAHBitmapEncoding(t *testing.T) {
+	t.Parallel()
+
+	var b ewahBitmap
+	b.set(1)
+	b.set(130)
+
+	var buf bytes.Buffer
+	require.NoError(t, b.encode(&buf))
+
+	// One RLW with no run and a literal word, then one RLW with a run of
+	// a zero word and a literal word.
+	assert.Equal(t, []byte{
+		0, 0, 0, 131, 0, 0, 0, 4,
+		0, 0, 0, 2, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 2,
+		0, 0, 0, 2, 0, 0, 0, 2,
+		0, 0, 0, 0, 0, 0, 0, 4,
+		0, 0, 0, 2,
+	}, buf.Bytes())
+}
+
+func TestDecodeEWAHMalformed(t *testing.T) {
+	t.Parallel()
+
+	// An RLW announcing a literal word that is not there.
+	data := []byte{
+		0, 0, 0, 64, 0, 0, 0, 1,
+		0, 0, 0, 2, 0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+
+	_, err := decodeEWAH(bytes.NewReader(data))
+	assert.ErrorIs(t, err, ErrMalformedIndexFile)
+}
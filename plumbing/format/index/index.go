@@ -20,10 +20,15 @@ var (
 	// ErrEntryNotFound is returned by Index.Entry, if an entry is not found.
 	ErrEntryNotFound = errors.New("entry not found")
 
-	indexSignature              = []byte{'D', 'I', 'R', 'C'}
-	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
-	resolveUndoExtSignature     = []byte{'R', 'E', 'U', 'C'}
-	endOfIndexEntryExtSignature = []byte{'E', 'O', 'I', 'E'}
+	indexSignature               = []byte{'D', 'I', 'R', 'C'}
+	treeExtSignature             = []byte{'T', 'R', 'E', 'E'}
+	resolveUndoExtSignature      = []byte{'R', 'E', 'U', 'C'}
+	endOfIndexEntryExtSignature  = []byte{'E', 'O', 'I', 'E'}
+	untrackedCacheExtSignature   = []byte{'U', 'N', 'T', 'R'}
+	fsMonitorExtSignature        = []byte{'F', 'S', 'M', 'N'}
+	entryOffsetTableExtSignature = []byte{'I', 'E', 'O', 'T'}
+	splitIndexExtSignature       = []byte{'l', 'i', 'n', 'k'}
+	sparseDirExtSignature        = []byte{'s', 'd', 'i', 'r'}
 )
 
 // Stage during merge
@@ -55,6 +60,17 @@ type Index struct {
 	ResolveUndo *ResolveUndo
 	// EndOfIndexEntry represents the 'End of Index Entry' extension
 	EndOfIndexEntry *EndOfIndexEntry
+	// UntrackedCache represents the 'Untracked cache' extension
+	UntrackedCache *UntrackedCache
+	// FSMonitor represents the 'File System Monitor cache' extension
+	FSMonitor *FSMonitor
+	// SplitIndex represents the 'Split index' extension
+	SplitIndex *SplitIndex
+	// EntryOffsetTable represents the 'Index Entry Offset Table' extension
+	EntryOffsetTable *EntryOffsetTable
+	// Extensions are the optional extensions go-git does not know, written
+	// back as they were read
+	Extensions []Extension
 	// ModTime is the modification time of the index file
 	ModTime time.Time
 }
@@ -75,6 +91,8 @@ func (i *Index) Add(path string) (*Entry, error) {
 		Name: filepath.ToSlash(path),
 	}
 
+	i.UntrackedCache.Invalidate(e.Name)
+
 	i.Entries = append(i.Entries, e)
 	return e, nil
 }
@@ -97,6 +115,7 @@ func (i *Index) Remove(path string) (*Entry, error) {
 	for index, e := range i.Entries {
 		if e.Name == path {
 			i.Entries = append(i.Entries[:index], i.Entries[index+1:]...)
+			i.UntrackedCache.Invalidate(path)
 			return e, nil
 		}
 	}
@@ -122,6 +141,17 @@ func (i *Index) Glob(pattern string) (matches []*Entry, err error) {
 	return matches, err
 }
 
+// IsSparse reports whether the index has sparse directory entries.
+func (i *Index) IsSparse() bool {
+	for _, e := range i.Entries {
+		if e.IsSparseDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
 // String is equivalent to `git ls-files --stage --debug`
 func (i *Index) String() string {
 	buf := bytes.NewBuffer(nil)
@@ -161,6 +191,19 @@ type Entry struct {
 	// IntentToAdd record only the fact that the path will be added later
 	// https://git-scm.com/docs/git-add ("git add -N")
 	IntentToAdd bool
+	// FSMonitorValid reports whether the file system monitor has not seen
+	// the tracked path change since the FSMonitor token, so it is known to
+	// be unchanged without being checked. It is only meaningful when the
+	// index has the 'File System Monitor cache' extension.
+	FSMonitorValid bool
+}
+
+// IsSparseDir reports whether e is the sparse directory entry of a sparse
+// index, which stands for all the files below the directory, outside of
+// the sparse checkout. Its name ends with a slash, and its hash is the one
+// of the tree of the directory.
+func (e *Entry) IsSparseDir() bool {
+	return e.Mode == filemode.Dir && e.SkipWorktree && strings.HasSuffix(e.Name, "/")
 }
 
 func (e Entry) String() string {
@@ -208,6 +251,9 @@ type ResolveUndo struct {
 type ResolveUndoEntry struct {
 	Path   string
 	Stages map[Stage]plumbing.Hash
+	// Modes are the modes of the stages. A stage with no mode is written as
+	// a regular file.
+	Modes map[Stage]filemode.FileMode
 }
 
 // EndOfIndexEntry is the End of Index Entry (EOIE) is used to locate the end of
@@ -225,13 +271,168 @@ type EndOfIndexEntry struct {
 	Hash plumbing.Hash
 }
 
+// EntryOffsetTable is the Index Entry Offset Table (IEOT), which splits the
+// entries in blocks that can be decoded in parallel. It is found through the
+// End of Index Entry extension, written along with it.
+type EntryOffsetTable struct {
+	Blocks []EntryOffsetBlock
+}
+
+// EntryOffsetBlock is a block of entries of an EntryOffsetTable.
+type EntryOffsetBlock struct {
+	// Offset is the offset from the beginning of the file of the first entry
+	// of the block.
+	Offset uint32
+	// Count is the number of entries of the block.
+	Count uint32
+}
+
+// Extension is an optional index extension go-git does not know, kept so
+// that the index is written back without losing what other tools stored.
+type Extension struct {
+	// Signature is the 4-byte signature of the extension.
+	Signature string
+	// Data is the content of the extension.
+	Data []byte
+}
+
+// UntrackedCache saves the untracked files of the worktree directories,
+// with the data needed to tell whether they may have changed, so listing
+// the unchanged directories can be skipped.
+type UntrackedCache struct {
+	// Environments describe the environments where the cache can be used,
+	// such as "Location /path/to/worktree, system Linux".
+	Environments []string
+	// InfoExcludeStats are the stat data of $GIT_DIR/info/exclude.
+	InfoExcludeStats StatData
+	// ExcludesFileStats are the stat data of core.excludesFile.
+	ExcludesFileStats StatData
+	// Flags are the flags of the directory listing the cache was
+	// populated by. With 0, every untracked file is listed, and untracked
+	// directories have their own blocks.
+	Flags uint32
+	// InfoExcludeHash is the hash of $GIT_DIR/info/exclude, or the zero
+	// hash if it does not exist.
+	InfoExcludeHash plumbing.Hash
+	// ExcludesFileHash is the hash of core.excludesFile, or the zero hash
+	// if it does not exist.
+	ExcludesFileHash plumbing.Hash
+	// ExcludePerDir is the name of the per-directory ignore files, usually
+	// ".gitignore".
+	ExcludePerDir string
+	// Root is the block of the top directory of the worktree, if any.
+	Root *UntrackedCacheDir
+}
+
+// UntrackedCacheDir is the block of a directory in the untracked cache.
+type UntrackedCacheDir struct {
+	// Name is the name of the directory, empty for the top directory.
+	Name string
+	// Untracked are the names of the untracked entries of the directory,
+	// untracked directories ending with a slash.
+	Untracked []string
+	// Dirs are the blocks of the sub-directories.
+	Dirs []*UntrackedCacheDir
+	// Valid reports whether Untracked and Stats are valid.
+	Valid bool
+	// CheckOnly records the "check-only" state of git's directory listing.
+	CheckOnly bool
+	// Stats are the stat data of the directory when it was listed.
+	Stats StatData
+	// ExcludeHash is the hash of the ignore file of the directory, or the
+	// zero hash if it has none.
+	ExcludeHash plumbing.Hash
+}
+
+// StatData are the stat data of a file, as the index stores them.
+type StatData struct {
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	Dev, Inode uint32
+	UID, GID   uint32
+	Size       uint32
+}
+
+// Dir returns the block of the sub-directory name, or nil if there is
+// none.
+func (d *UntrackedCacheDir) Dir(name string) *UntrackedCacheDir {
+	for _, sub := range d.Dirs {
+		if sub.Name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// Invalidate drops the untracked entries cached for the directories
+// leading to path, which is relative to the top directory of the worktree,
+// so they are listed again. A path ending with a slash is a directory, whose
+// whole tree is invalidated.
+//
+// It is called when path is added to or removed from the index, since it
+// then changes from untracked to tracked or the other way around without its
+// directory being modified, and when a file system monitor reports path as
+// changed.
+func (c *UntrackedCache) Invalidate(path string) {
+	if c == nil {
+		return
+	}
+
+	d := c.Root
+	for d != nil {
+		d.invalidate(false)
+
+		name, rest, ok := strings.Cut(path, "/")
+		if !ok {
+			return
+		}
+		d, path = d.Dir(name), rest
+
+		if d != nil && rest == "" {
+			d.invalidate(true)
+			return
+		}
+	}
+}
+
+func (d *UntrackedCacheDir) invalidate(tree bool) {
+	d.Valid = false
+	d.Untracked = nil
+	if !tree {
+		return
+	}
+
+	for _, sub := range d.Dirs {
+		sub.invalidate(true)
+	}
+}
+
+// FSMonitor represents the 'File System Monitor cache' extension, which
+// records the entries the file system monitor has not seen change since
+// Token, in their FSMonitorValid field.
+type FSMonitor struct {
+	// Token is the opaque token of the file system monitor the changes are
+	// queried since. When the index was written with version 1 of the
+	// extension, it is the time of the last query, in nanoseconds since the
+	// Unix epoch.
+	Token string
+}
+
 // SkipUnless applies patterns in the form of A, A/B, A/B/C
 // to the index to prevent the files from being checked out.
 // Files whose names match one of the patterns have SkipWorktree cleared;
 // all other files have it set. This handles sparse-checkout dir switching
 // correctly: files moving into the active set are un-skipped.
+//
+// Sparse directory entries are left out of the worktree: those the
+// patterns include are to be expanded first.
 func (i *Index) SkipUnless(patterns []string) {
 	for _, e := range i.Entries {
+		if e.IsSparseDir() {
+			continue
+		}
+
 		var include bool
 		for _, pattern := range patterns {
 			if strings.HasPrefix(e.Name, pattern) {
@@ -116,3 +116,39 @@ func TestIndexGlob(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, m, 1)
 }
+
+func TestUntrackedCacheInvalidate(t *testing.T) {
+	t.Parallel()
+
+	b := &UntrackedCacheDir{Name: "b", Valid: true, Untracked: []string{"u"}}
+	c := &UntrackedCacheDir{Name: "c", Valid: true, Untracked: []string{"v"}}
+	a := &UntrackedCacheDir{Name: "a", Valid: true, Untracked: []string{"w"}, Dirs: []*UntrackedCacheDir{b}}
+	root := &UntrackedCacheDir{Valid: true, Untracked: []string{"x"}, Dirs: []*UntrackedCacheDir{a, c}}
+	idx := &Index{UntrackedCache: &UntrackedCache{Root: root}}
+
+	_, err := idx.Add("a/b/u")
+	require.NoError(t, err)
+
+	for _, d := range []*UntrackedCacheDir{root, a, b} {
+		assert.False(t, d.Valid, d.Name)
+		assert.Nil(t, d.Untracked, d.Name)
+	}
+	assert.True(t, c.Valid)
+	assert.Equal(t, []string{"v"}, c.Untracked)
+
+	_, err = idx.Add("c/v")
+	require.NoError(t, err)
+	c.Valid = true
+	_, err = idx.Remove("c/v")
+	require.NoError(t, err)
+	assert.False(t, c.Valid)
+
+	a.Valid, b.Valid, c.Valid = true, true, true
+	idx.UntrackedCache.Invalidate("a/")
+	assert.False(t, a.Valid)
+	assert.False(t, b.Valid)
+	assert.True(t, c.Valid)
+
+	var none *UntrackedCache
+	none.Invalidate("a")
+}
@@ -5,6 +5,12 @@ type Option func(*options)
 
 type options struct {
 	skipHash bool
+	threads  int
+
+	offsetBlocks       int
+	setOffsetBlocks    bool
+	endOfIndexEntry    bool
+	setEndOfIndexEntry bool
 }
 
 // WithSkipHash disables checksum computation when encoding and checksum
@@ -16,3 +22,33 @@ func WithSkipHash() Option {
 		o.skipHash = true
 	}
 }
+
+// WithThreads makes the decoder read the entries with up to n goroutines
+// when the index has an Index Entry Offset Table, as git's index.threads.
+// With n less than 2, or no such table, the entries are read in sequence.
+func WithThreads(n int) Option {
+	return func(o *options) {
+		o.threads = n
+	}
+}
+
+// WithEntryOffsetTable makes the encoder split the entries in the given
+// number of blocks and write an Index Entry Offset Table for them, in place
+// of as many blocks as the table of the index has. With less than two
+// blocks, no table is written.
+func WithEntryOffsetTable(blocks int) Option {
+	return func(o *options) {
+		o.offsetBlocks = blocks
+		o.setOffsetBlocks = true
+	}
+}
+
+// WithEndOfIndexEntry sets whether the encoder writes the End of Index Entry
+// extension, in place of writing it only if the index has one. It is always
+// written along with an Index Entry Offset Table, which is found through it.
+func WithEndOfIndexEntry(write bool) Option {
+	return func(o *options) {
+		o.endOfIndexEntry = write
+		o.setEndOfIndexEntry = true
+	}
+}
@@ -0,0 +1,193 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// SplitIndex represents the 'Split index' extension of an index split in two
+// files: the shared index, $GIT_DIR/sharedindex.<BaseHash>, which holds most
+// of the entries, and the index file, which holds the changes made to them.
+type SplitIndex struct {
+	// BaseHash is the checksum of the shared index. It is zero if the index
+	// does not need one.
+	BaseHash plumbing.Hash
+	// Base is the shared index. The entries of the index are merged with its
+	// own once it is read, and only the changes made to them are written.
+	Base *Index
+
+	// deleted and replaced mark the entries of Base deleted and replaced by
+	// the index file, as it was read and until it is merged with Base.
+	deleted, replaced *ewahBitmap
+	// fsMonitorDirty marks the entries of the merged index the file system
+	// monitor may have seen change.
+	fsMonitorDirty *ewahBitmap
+}
+
+// MergeSharedIndex merges the entries of the index, as read from a split
+// index file, with those of base, the shared index its SplitIndex points
+// to. The entries of the index are then those of the whole index, and base
+// becomes its SplitIndex Base.
+func (i *Index) MergeSharedIndex(base *Index) error {
+	s := i.SplitIndex
+	if s == nil {
+		return nil
+	}
+
+	entries := make([]*Entry, len(base.Entries))
+	for n, e := range base.Entries {
+		cp := *e
+		entries[n] = &cp
+	}
+
+	// The replacements come first, in the order of the entries they replace,
+	// and have no name to save space.
+	next := 0
+	if s.replaced != nil {
+		err := s.replaced.forEach(func(pos int) error {
+			if pos >= len(entries) || next >= len(i.Entries) {
+				return fmt.Errorf("%w: split index replaces entry %d of %d", ErrMalformedIndexFile, pos, len(entries))
+			}
+
+			e := i.Entries[next]
+			if e.Name != "" {
+				return fmt.Errorf("%w: split index replacement %d has a name", ErrMalformedIndexFile, next)
+			}
+
+			cp := *e
+			cp.Name = entries[pos].Name
+			entries[pos] = &cp
+			next++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.deleted != nil {
+		err := s.deleted.forEach(func(pos int) error {
+			if pos >= len(entries) {
+				return fmt.Errorf("%w: split index deletes entry %d of %d", ErrMalformedIndexFile, pos, len(entries))
+			}
+
+			entries[pos] = nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	merged := make([]*Entry, 0, len(entries)+len(i.Entries)-next)
+	positions := make(map[entryKey]int, len(entries))
+	for _, e := range entries {
+		if e != nil {
+			positions[keyOf(e)] = len(merged)
+			merged = append(merged, e)
+		}
+	}
+
+	// The others are added, replacing the entries of the same name and stage.
+	for _, e := range i.Entries[next:] {
+		if e.Name == "" {
+			return fmt.Errorf("%w: split index entry with no name", ErrMalformedIndexFile)
+		}
+
+		if pos, ok := positions[keyOf(e)]; ok {
+			merged[pos] = e
+			continue
+		}
+
+		positions[keyOf(e)] = len(merged)
+		merged = append(merged, e)
+	}
+
+	sort.Sort(byNameAndStage(merged))
+	i.Entries = merged
+
+	if s.fsMonitorDirty != nil {
+		for n, e := range i.Entries {
+			e.FSMonitorValid = !s.fsMonitorDirty.isSet(n)
+		}
+	}
+
+	i.SplitIndex = &SplitIndex{BaseHash: s.BaseHash, Base: base}
+	return nil
+}
+
+// SplitChanges returns the number of changes the index makes to its shared
+// index: the entries it adds, replaces or deletes. It is the number of its
+// entries if it has no shared index.
+func (i *Index) SplitChanges() int {
+	if i.SplitIndex == nil || i.SplitIndex.Base == nil {
+		return len(i.Entries)
+	}
+
+	entries, deleted, _ := i.SplitIndex.split(i.Entries)
+	return len(entries) + deleted.count()
+}
+
+// split returns the entries of the index file of a split index whose
+// entries are entries, sorted, and the bitmaps of the entries of the shared
+// index it deletes and replaces. The replacements come first, with no name.
+func (s *SplitIndex) split(entries []*Entry) (changes []*Entry, deleted, replaced *ewahBitmap) {
+	deleted, replaced = &ewahBitmap{}, &ewahBitmap{}
+
+	positions := make(map[entryKey]int, len(s.Base.Entries))
+	for pos, e := range s.Base.Entries {
+		positions[keyOf(e)] = pos
+	}
+
+	matched := make([]*Entry, len(s.Base.Entries))
+	var added []*Entry
+	for _, e := range entries {
+		pos, ok := positions[keyOf(e)]
+		if !ok || matched[pos] != nil {
+			added = append(added, e)
+			continue
+		}
+
+		matched[pos] = e
+	}
+
+	for pos, e := range matched {
+		switch {
+		case e == nil:
+			deleted.set(pos)
+		case !sameEntry(e, s.Base.Entries[pos]):
+			replaced.set(pos)
+			cp := *e
+			cp.Name = ""
+			changes = append(changes, &cp)
+		}
+	}
+
+	return append(changes, added...), deleted, replaced
+}
+
+type entryKey struct {
+	name  string
+	stage Stage
+}
+
+func keyOf(e *Entry) entryKey {
+	return entryKey{name: e.Name, stage: e.Stage}
+}
+
+// sameEntry reports whether a and b are written the same in an index file.
+func sameEntry(a, b *Entry) bool {
+	return a.Hash.Equal(b.Hash) &&
+		a.Name == b.Name &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.ModifiedAt.Equal(b.ModifiedAt) &&
+		a.Dev == b.Dev && a.Inode == b.Inode &&
+		a.Mode == b.Mode &&
+		a.UID == b.UID && a.GID == b.GID &&
+		a.Size == b.Size &&
+		a.Stage == b.Stage &&
+		a.SkipWorktree == b.SkipWorktree &&
+		a.IntentToAdd == b.IntentToAdd
+}
@@ -0,0 +1,144 @@
+package index
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+func splitTestEntry(name, hash string) *Entry {
+	return &Entry{Name: name, Hash: plumbing.NewHash(hash), Mode: filemode.Regular}
+}
+
+func encodeDecode(t *testing.T, idx *Index) *Index {
+	t.Helper()
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, crypto.SHA1.New()).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf, crypto.SHA1.New()).Decode(output))
+	return output
+}
+
+func TestSplitIndexRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	base := &Index{Version: 2, Entries: []*Entry{
+		splitTestEntry("a", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		splitTestEntry("b", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		splitTestEntry("c", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		splitTestEntry("d", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+	}}
+	baseHash := plumbing.NewHash("cc30ca8b9b10bb92f8e5c96ee94348c6c4ac93e6")
+
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			splitTestEntry("a", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			splitTestEntry("b", "397b4a7624e35fa60563a9c03b1213d93f7b6546"),
+			splitTestEntry("bb", "397b4a7624e35fa60563a9c03b1213d93f7b6546"),
+			splitTestEntry("d", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		},
+		SplitIndex: &SplitIndex{BaseHash: baseHash, Base: base},
+	}
+	idx.Entries[1].FSMonitorValid = true
+	idx.Entries[3].FSMonitorValid = true
+	idx.FSMonitor = &FSMonitor{Token: "token"}
+
+	// b is replaced, c deleted and bb added.
+	assert.Equal(t, 3, idx.SplitChanges())
+
+	output := encodeDecode(t, idx)
+	require.NotNil(t, output.SplitIndex)
+	assert.Equal(t, baseHash, output.SplitIndex.BaseHash)
+	require.Len(t, output.Entries, 2)
+	assert.Empty(t, output.Entries[0].Name)
+	assert.Equal(t, "bb", output.Entries[1].Name)
+
+	require.NoError(t, output.MergeSharedIndex(base))
+	assert.Same(t, base, output.SplitIndex.Base)
+	require.Len(t, output.Entries, 4)
+	for i, e := range idx.Entries {
+		assert.Equal(t, e.Name, output.Entries[i].Name)
+		assert.Equal(t, e.Hash, output.Entries[i].Hash, e.Name)
+		assert.Equal(t, e.FSMonitorValid, output.Entries[i].FSMonitorValid, e.Name)
+	}
+
+	// The shared index is left as it was.
+	assert.Equal(t, "b", base.Entries[1].Name)
+	assert.Equal(t, plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), base.Entries[1].Hash)
+}
+
+func TestSplitIndexWithoutSharedIndex(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			splitTestEntry("a", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		},
+		SplitIndex: &SplitIndex{BaseHash: plumbing.NewHash("cc30ca8b9b10bb92f8e5c96ee94348c6c4ac93e6")},
+	}
+
+	output := encodeDecode(t, idx)
+	require.NotNil(t, output.SplitIndex)
+	assert.True(t, output.SplitIndex.BaseHash.IsZero())
+	require.Len(t, output.Entries, 1)
+	assert.Equal(t, "a", output.Entries[0].Name)
+}
+
+func TestMergeSharedIndexMalformed(t *testing.T) {
+	t.Parallel()
+
+	base := &Index{Version: 2, Entries: []*Entry{
+		splitTestEntry("a", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+	}}
+
+	replaced := &ewahBitmap{}
+	replaced.set(3)
+	idx := &Index{
+		Entries:    []*Entry{splitTestEntry("", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+		SplitIndex: &SplitIndex{replaced: replaced},
+	}
+	require.ErrorIs(t, idx.MergeSharedIndex(base), ErrMalformedIndexFile)
+
+	idx = &Index{
+		Entries:    []*Entry{splitTestEntry("", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+		SplitIndex: &SplitIndex{},
+	}
+	require.ErrorIs(t, idx.MergeSharedIndex(base), ErrMalformedIndexFile)
+}
+
+func TestSparseIndexRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	idx := &Index{
+		Version: 3,
+		Entries: []*Entry{
+			splitTestEntry("a", "e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			{
+				Name:         "dir/",
+				Hash:         plumbing.NewHash("397b4a7624e35fa60563a9c03b1213d93f7b6546"),
+				Mode:         filemode.Dir,
+				SkipWorktree: true,
+			},
+		},
+	}
+	require.True(t, idx.IsSparse())
+	assert.True(t, idx.Entries[1].IsSparseDir())
+
+	output := encodeDecode(t, idx)
+	assert.True(t, output.IsSparse())
+	require.Len(t, output.Entries, 2)
+	assert.True(t, output.Entries[1].IsSparseDir())
+
+	idx.Entries = idx.Entries[:1]
+	assert.False(t, encodeDecode(t, idx).IsSparse())
+}
@@ -0,0 +1,283 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrSignerNotAllowed is returned when the key of a signature is not
+// allowed for the principal, namespace and time it is checked for.
+var ErrSignerNotAllowed = errors.New("sshsig: signer not allowed")
+
+// AllowedSigner is an entry of an allowed signers file, as described in the
+// ALLOWED SIGNERS section of ssh-keygen(1).
+type AllowedSigner struct {
+	// Principals is the comma-separated list of principal patterns the key
+	// is allowed to sign for.
+	Principals string
+	// CertAuthority tells that Key is a certificate authority, trusted to
+	// certify the keys of the principals.
+	CertAuthority bool
+	// Namespaces is the comma-separated list of namespace patterns the key
+	// is allowed to sign in. If empty, all namespaces are allowed.
+	Namespaces string
+	// ValidAfter and ValidBefore bound the time the key is valid at, if not
+	// zero.
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	// Key is the public key of the signer or certificate authority.
+	Key gossh.PublicKey
+}
+
+// AllowedSigners is the content of an allowed signers file, as used by
+// gpg.ssh.allowedSignersFile.
+type AllowedSigners []*AllowedSigner
+
+// ParseAllowedSigners parses an allowed signers file.
+func ParseAllowedSigners(r io.Reader) (AllowedSigners, error) {
+	var signers AllowedSigners
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		signer, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("allowed signers line %d: %w", n, err)
+		}
+		signers = append(signers, signer)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+// ReadAllowedSignersFile reads the allowed signers file at path.
+func ReadAllowedSignersFile(path string) (AllowedSigners, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseAllowedSigners(f)
+}
+
+func parseAllowedSigner(line string) (*AllowedSigner, error) {
+	principals, rest := nextField(line)
+	if principals == "" || rest == "" {
+		return nil, errors.New("missing key")
+	}
+
+	// The options and the key are in the authorized_keys format.
+	key, _, options, _, err := gossh.ParseAuthorizedKey([]byte(rest))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AllowedSigner{Principals: principals, Key: key}
+	for _, opt := range options {
+		name, value, _ := strings.Cut(opt, "=")
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(name) {
+		case "cert-authority":
+			a.CertAuthority = true
+		case "namespaces":
+			a.Namespaces = value
+		case "valid-after":
+			if a.ValidAfter, err = parseTime(value); err != nil {
+				return nil, err
+			}
+		case "valid-before":
+			if a.ValidBefore, err = parseTime(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+	return a, nil
+}
+
+// nextField returns the first field of s, which may be double-quoted, and
+// the rest of s.
+func nextField(s string) (field, rest string) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s[1:], '"')
+		if end < 0 {
+			return "", ""
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:])
+	}
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// parseTime parses a YYYYMMDD[HHMM[SS]][Z] time. Times without a Z suffix
+// are in the local time zone.
+func parseTime(s string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(s, "Z") || strings.HasSuffix(s, "z") {
+		loc = time.UTC
+		s = s[:len(s)-1]
+	}
+
+	var layout string
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return time.ParseInLocation(layout, s, loc)
+}
+
+// validAt reports whether the entry is valid at t.
+func (a *AllowedSigner) validAt(t time.Time) bool {
+	if !a.ValidAfter.IsZero() && t.Before(a.ValidAfter) {
+		return false
+	}
+	if !a.ValidBefore.IsZero() && t.After(a.ValidBefore) {
+		return false
+	}
+	return true
+}
+
+// allows reports whether the entry allows key to sign in namespace at t,
+// returning the principals it allows key to sign for: the principal
+// patterns of the entry for plain keys, the principals of the certificate
+// matching them for certificates.
+func (a *AllowedSigner) allows(key gossh.PublicKey, namespace string, t time.Time) ([]string, bool) {
+	if !a.validAt(t) {
+		return nil, false
+	}
+	if a.Namespaces != "" && !MatchPatternList(namespace, a.Namespaces) {
+		return nil, false
+	}
+
+	cert, isCert := key.(*gossh.Certificate)
+	if !a.CertAuthority {
+		if isCert || !bytes.Equal(key.Marshal(), a.Key.Marshal()) {
+			return nil, false
+		}
+		return strings.Split(a.Principals, ","), true
+	}
+
+	if !isCert || cert.CertType != gossh.UserCert ||
+		!bytes.Equal(cert.SignatureKey.Marshal(), a.Key.Marshal()) {
+		return nil, false
+	}
+	checker := &gossh.CertChecker{Clock: func() time.Time { return t }}
+	var principals []string
+	for _, p := range cert.ValidPrincipals {
+		if MatchPatternList(p, a.Principals) && checker.CheckCert(p, cert) == nil {
+			principals = append(principals, p)
+		}
+	}
+	return principals, len(principals) > 0
+}
+
+// FindPrincipals returns the principals key is allowed to sign for in
+// namespace at t, as `ssh-keygen -Y find-principals` does.
+func (s AllowedSigners) FindPrincipals(key gossh.PublicKey, namespace string, t time.Time) []string {
+	var principals []string
+	for _, a := range s {
+		if p, ok := a.allows(key, namespace, t); ok {
+			principals = append(principals, p...)
+		}
+	}
+	return principals
+}
+
+// Allowed returns nil if key is allowed to sign for principal in namespace
+// at t, as checked by `ssh-keygen -Y verify`, and ErrSignerNotAllowed
+// otherwise.
+func (s AllowedSigners) Allowed(principal string, key gossh.PublicKey, namespace string, t time.Time) error {
+	for _, a := range s {
+		principals, ok := a.allows(key, namespace, t)
+		if !ok {
+			continue
+		}
+		if a.CertAuthority {
+			for _, p := range principals {
+				if p == principal {
+					return nil
+				}
+			}
+			continue
+		}
+		if MatchPatternList(principal, a.Principals) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s for %q", ErrSignerNotAllowed, gossh.FingerprintSHA256(key), principal)
+}
+
+// MatchPatternList reports whether s matches the comma-separated list of
+// patterns, as OpenSSH does: patterns can hold * and ? wildcards, and s
+// does not match if it matches a pattern negated with !.
+func MatchPatternList(s, patterns string) bool {
+	matched := false
+	for p := range strings.SplitSeq(patterns, ",") {
+		p = strings.TrimSpace(p)
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		if !matchPattern(s, p) {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// matchPattern reports whether s matches pattern, which can hold * and ?
+// wildcards.
+func matchPattern(s, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(s[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		s, pattern = s[1:], pattern[1:]
+	}
+	return s == ""
+}
@@ -0,0 +1,122 @@
+package sshsig
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+)
+
+func TestParseAllowedSigners(t *testing.T) {
+	t.Parallel()
+
+	signers, err := ParseAllowedSigners(strings.NewReader(`
+# comment
+alice@example.com,bob@example.com ` + fixtureKey + ` alice
+"*@example.com" cert-authority,namespaces="git,file",valid-after="20240101",valid-before="20250101120000Z" ` + fixtureKey + `
+`))
+	require.NoError(t, err)
+	require.Len(t, signers, 2)
+
+	assert.Equal(t, "alice@example.com,bob@example.com", signers[0].Principals)
+	assert.False(t, signers[0].CertAuthority)
+	assert.Empty(t, signers[0].Namespaces)
+	assert.True(t, signers[0].ValidAfter.IsZero())
+
+	assert.Equal(t, "*@example.com", signers[1].Principals)
+	assert.True(t, signers[1].CertAuthority)
+	assert.Equal(t, "git,file", signers[1].Namespaces)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), signers[1].ValidAfter)
+	assert.Equal(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), signers[1].ValidBefore)
+
+	for _, line := range []string{
+		"alice@example.com",
+		"alice@example.com unknown-option " + fixtureKey,
+		`alice@example.com valid-after="2024" ` + fixtureKey,
+	} {
+		_, err := ParseAllowedSigners(strings.NewReader(line))
+		require.Error(t, err, line)
+	}
+}
+
+func TestAllowedSigners(t *testing.T) {
+	t.Parallel()
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(fixtureKey))
+	require.NoError(t, err)
+	signers, err := ParseAllowedSigners(strings.NewReader(
+		`*@example.com,!mallory@example.com namespaces="git",valid-after="20240101Z",valid-before="20250101Z" ` + fixtureKey,
+	))
+	require.NoError(t, err)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, signers.Allowed("alice@example.com", key, "git", now))
+	require.ErrorIs(t, signers.Allowed("mallory@example.com", key, "git", now), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@other.com", key, "git", now), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@example.com", key, "file", now), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@example.com", key, "git", now.AddDate(1, 0, 0)), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@example.com", key, "git", now.AddDate(-1, 0, 0)), ErrSignerNotAllowed)
+
+	assert.Equal(t, []string{"*@example.com", "!mallory@example.com"}, signers.FindPrincipals(key, "git", now))
+	assert.Empty(t, signers.FindPrincipals(key, "file", now))
+}
+
+func TestAllowedSignersCertAuthority(t *testing.T) {
+	t.Parallel()
+
+	ca, err := gossh.ParsePrivateKey(testdata.PEMBytes["ecdsa"])
+	require.NoError(t, err)
+	user, err := gossh.ParsePrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+
+	now := time.Now()
+	cert := &gossh.Certificate{
+		Key:             user.PublicKey(),
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{"alice@example.com", "root"},
+		ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+		ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+
+	signers := AllowedSigners{{
+		Principals:    "*@example.com",
+		CertAuthority: true,
+		Key:           ca.PublicKey(),
+	}}
+
+	require.NoError(t, signers.Allowed("alice@example.com", cert, "git", now))
+	require.ErrorIs(t, signers.Allowed("root", cert, "git", now), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("bob@example.com", cert, "git", now), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@example.com", cert, "git", now.Add(2*time.Hour)), ErrSignerNotAllowed)
+	require.ErrorIs(t, signers.Allowed("alice@example.com", user.PublicKey(), "git", now), ErrSignerNotAllowed)
+	assert.Equal(t, []string{"alice@example.com"}, signers.FindPrincipals(cert, "git", now))
+}
+
+func TestMatchPatternList(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		s, patterns string
+		want        bool
+	}{
+		{"git", "git", true},
+		{"git", "file,git", true},
+		{"git", "g?t", true},
+		{"git", "g*", true},
+		{"git", "*", true},
+		{"git", "file", false},
+		{"git", "*,!git", false},
+		{"alice@example.com", "*@example.com", true},
+		{"alice@example.org", "*@example.com", false},
+		{"", "*", true},
+		{"", "?", false},
+	} {
+		assert.Equal(t, tc.want, MatchPatternList(tc.s, tc.patterns), "%q ~ %q", tc.s, tc.patterns)
+	}
+}
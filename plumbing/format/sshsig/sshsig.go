@@ -0,0 +1,234 @@
+// Package sshsig implements the SSH signature format used by Git when
+// gpg.format is set to ssh, as produced by `ssh-keygen -Y sign`.
+//
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+// for the specification of the format.
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA512
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	magic   = "SSHSIG"
+	version = 1
+
+	armorStart = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd   = "-----END SSH SIGNATURE-----"
+
+	// lineLength is the length of the base64 lines of armored signatures,
+	// as written by ssh-keygen.
+	lineLength = 70
+)
+
+// The hash algorithms supported by SSH signatures.
+const (
+	HashSHA256 = "sha256"
+	HashSHA512 = "sha512"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature cannot be parsed.
+	ErrInvalidSignature = errors.New("sshsig: invalid signature")
+	// ErrNamespaceMismatch is returned when a signature was made for
+	// another namespace than the expected one.
+	ErrNamespaceMismatch = errors.New("sshsig: namespace mismatch")
+)
+
+// Signature is a parsed SSH signature.
+type Signature struct {
+	// PublicKey is the key, or certificate, of the signer.
+	PublicKey gossh.PublicKey
+	// Namespace is the domain of the signature, such as "git" for Git
+	// objects, preventing its use in another context.
+	Namespace string
+	// HashAlgorithm is the algorithm used to hash the message, HashSHA256
+	// or HashSHA512.
+	HashAlgorithm string
+	// Signature is the signature of the hashed message.
+	Signature *gossh.Signature
+}
+
+// blob is the wire format of a signature.
+type blob struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// signedData is the data actually signed: the hash of the message, bound to
+// the namespace.
+type signedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// Parse parses an armored SSH signature.
+func Parse(armored []byte) (*Signature, error) {
+	armored = bytes.TrimSpace(armored)
+	if !bytes.HasPrefix(armored, []byte(armorStart)) || !bytes.HasSuffix(armored, []byte(armorEnd)) {
+		return nil, fmt.Errorf("%w: missing armor", ErrInvalidSignature)
+	}
+
+	body := armored[len(armorStart) : len(armored)-len(armorEnd)]
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	return ParseRaw(raw)
+}
+
+// ParseRaw parses an SSH signature in its binary form.
+func ParseRaw(raw []byte) (*Signature, error) {
+	var b blob
+	if err := gossh.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if string(b.Magic[:]) != magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidSignature)
+	}
+	if b.Version != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSignature, b.Version)
+	}
+
+	pub, err := gossh.ParsePublicKey(b.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	sig := new(gossh.Signature)
+	if err := gossh.Unmarshal(b.Signature, sig); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	return &Signature{
+		PublicKey:     pub,
+		Namespace:     b.Namespace,
+		HashAlgorithm: b.HashAlgorithm,
+		Signature:     sig,
+	}, nil
+}
+
+// Verify checks that s is a valid signature of message in namespace. It
+// does not check whether the signer is trusted; see AllowedSigners.
+func (s *Signature) Verify(message io.Reader, namespace string) error {
+	if s.Namespace != namespace {
+		return fmt.Errorf("%w: got %q, want %q", ErrNamespaceMismatch, s.Namespace, namespace)
+	}
+
+	// RSA signatures using SHA-1 are not allowed for SSH signatures.
+	if s.Signature.Format == gossh.KeyAlgoRSA {
+		return fmt.Errorf("%w: unsupported signature algorithm %s", ErrInvalidSignature, s.Signature.Format)
+	}
+
+	data, err := signed(message, namespace, s.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	key := s.PublicKey
+	if cert, ok := key.(*gossh.Certificate); ok {
+		key = cert.Key
+	}
+	return key.Verify(data, s.Signature)
+}
+
+// Sign signs message in namespace with signer, hashing it with SHA-512,
+// and returns the armored signature. RSA keys sign with rsa-sha2-512.
+func Sign(rand io.Reader, signer gossh.Signer, message io.Reader, namespace string) ([]byte, error) {
+	data, err := signed(message, namespace, HashSHA512)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig *gossh.Signature
+	if as, ok := signer.(gossh.AlgorithmSigner); ok && isRSA(signer.PublicKey()) {
+		sig, err = as.SignWithAlgorithm(rand, data, gossh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := blob{
+		Version:       version,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: HashSHA512,
+		Signature:     gossh.Marshal(sig),
+	}
+	copy(b.Magic[:], magic)
+
+	return armor(gossh.Marshal(b)), nil
+}
+
+func isRSA(key gossh.PublicKey) bool {
+	if cert, ok := key.(*gossh.Certificate); ok {
+		key = cert.Key
+	}
+	return key.Type() == gossh.KeyAlgoRSA
+}
+
+// signed returns the data signed for message in namespace.
+func signed(message io.Reader, namespace, hashAlgorithm string) ([]byte, error) {
+	var h crypto.Hash
+	switch hashAlgorithm {
+	case HashSHA256:
+		h = crypto.SHA256
+	case HashSHA512:
+		h = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("%w: unsupported hash algorithm %q", ErrInvalidSignature, hashAlgorithm)
+	}
+
+	hasher := h.New()
+	if _, err := io.Copy(hasher, message); err != nil {
+		return nil, err
+	}
+
+	d := signedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Hash:          hasher.Sum(nil),
+	}
+	copy(d.Magic[:], magic)
+	return gossh.Marshal(d), nil
+}
+
+// armor encodes a binary signature in its armored form.
+func armor(raw []byte) []byte {
+	enc := base64.StdEncoding.EncodeToString(raw)
+
+	var b bytes.Buffer
+	b.WriteString(armorStart)
+	b.WriteByte('\n')
+	for len(enc) > lineLength {
+		b.WriteString(enc[:lineLength])
+		b.WriteByte('\n')
+		enc = enc[lineLength:]
+	}
+	b.WriteString(enc)
+	b.WriteByte('\n')
+	b.WriteString(armorEnd)
+	b.WriteByte('\n')
+	return b.Bytes()
+}
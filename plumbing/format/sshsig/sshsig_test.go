@@ -0,0 +1,83 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+)
+
+// fixtureKey and fixtureSignature were generated with:
+//
+//	ssh-keygen -t ed25519 -f key
+//	printf 'hello\n' | ssh-keygen -Y sign -n git -f key
+const (
+	fixtureKey       = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAZ0GKQ9Fyf/BdqXrTa+g9dtLiVxBFjd51VqxMIKyt8r"
+	fixtureSignature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgBnQYpD0XJ/8F2petNr6D120uJX
+EEWN3nVWrEwgrK3ysAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5
+AAAAQHdSelBfL+hVrLvL2DA/hlQl02ll6NT57b80c3eIKV7ED5zWgh8xbel1nfqGy3wR08
+JXXaCZo4HAhfZHZiaZqgk=
+-----END SSH SIGNATURE-----
+`
+)
+
+func TestParseAndVerify(t *testing.T) {
+	t.Parallel()
+
+	sig, err := Parse([]byte(fixtureSignature))
+	require.NoError(t, err)
+	assert.Equal(t, "git", sig.Namespace)
+	assert.Equal(t, HashSHA512, sig.HashAlgorithm)
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(fixtureKey))
+	require.NoError(t, err)
+	assert.Equal(t, key.Marshal(), sig.PublicKey.Marshal())
+
+	require.NoError(t, sig.Verify(strings.NewReader("hello\n"), "git"))
+	require.Error(t, sig.Verify(strings.NewReader("hello"), "git"))
+	require.ErrorIs(t, sig.Verify(strings.NewReader("hello\n"), "file"), ErrNamespaceMismatch)
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		"",
+		"-----BEGIN SSH SIGNATURE-----\n-----END SSH SIGNATURE-----",
+		"-----BEGIN SSH SIGNATURE-----\n!!!\n-----END SSH SIGNATURE-----",
+		"-----BEGIN PGP SIGNATURE-----\n-----END PGP SIGNATURE-----",
+	} {
+		_, err := Parse([]byte(s))
+		require.ErrorIs(t, err, ErrInvalidSignature, s)
+	}
+}
+
+func TestSignRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"ed25519", "rsa", "ecdsa"} {
+		signer, err := gossh.ParsePrivateKey(testdata.PEMBytes[name])
+		require.NoError(t, err)
+
+		armored, err := Sign(rand.Reader, signer, strings.NewReader("message"), "git")
+		require.NoError(t, err, name)
+		assert.True(t, bytes.HasPrefix(armored, []byte(armorStart+"\n")), name)
+		for _, line := range strings.Split(string(armored), "\n") {
+			assert.LessOrEqual(t, len(line), lineLength, name)
+		}
+
+		sig, err := Parse(armored)
+		require.NoError(t, err, name)
+		if name == "rsa" {
+			assert.Equal(t, gossh.KeyAlgoRSASHA512, sig.Signature.Format)
+		}
+		require.NoError(t, sig.Verify(strings.NewReader("message"), "git"), name)
+		require.Error(t, sig.Verify(strings.NewReader("other"), "git"), name)
+	}
+}
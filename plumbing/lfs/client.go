@@ -0,0 +1,325 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// Operations of the batch API.
+const (
+	Download = "download"
+	Upload   = "upload"
+)
+
+const (
+	mediaType     = "application/vnd.git-lfs+json"
+	basicTransfer = "basic"
+	verifyAction  = "verify"
+
+	// batchSize is the number of objects of a batch request, as Git LFS.
+	batchSize = 100
+	// maxErrorSize is the size of the body of an error response kept in
+	// the error.
+	maxErrorSize = 1024
+)
+
+// Client transfers the content of pointers between a Store and an LFS
+// server, with its batch API and the basic transfer adapter.
+//
+// See https://github.com/git-lfs/git-lfs/blob/main/docs/api/batch.md.
+type Client struct {
+	endpoint  *url.URL
+	transport *xhttp.Transport
+}
+
+// NewClient returns a client of the LFS server at endpoint, such as the one
+// returned by Endpoint. The requests to the server are sent, and
+// authenticated, with t. The transfers requested to other hosts are sent
+// with the headers given by the server only.
+func NewClient(endpoint *url.URL, t *xhttp.Transport) *Client {
+	return &Client{endpoint: endpoint, transport: t}
+}
+
+// Endpoint returns the LFS server endpoint of the repository at rawURL, as
+// Git LFS derives it when none is configured: the info/lfs path of the
+// repository, over HTTPS for SSH and git:// URLs.
+func Endpoint(rawURL string) (*url.URL, error) {
+	u, err := transport.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &url.URL{Scheme: u.Scheme, Host: u.Host, User: u.User}
+	switch u.Scheme {
+	case "http", "https":
+	case "ssh", "git":
+		e.Scheme, e.Host, e.User = "https", u.Hostname(), nil
+	default:
+		return nil, fmt.Errorf("lfs: unsupported URL scheme %q", u.Scheme)
+	}
+
+	p := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(p, ".git") {
+		p += ".git"
+	}
+	e.Path = "/" + strings.TrimPrefix(p, "/") + "/info/lfs"
+
+	return e, nil
+}
+
+// Download downloads the content of the pointers that is not in store yet
+// into it.
+func (c *Client) Download(ctx context.Context, store *Store, pointers ...*Pointer) error {
+	var missing []*Pointer
+	for _, p := range pointers {
+		if !store.Has(p) {
+			missing = append(missing, p)
+		}
+	}
+
+	return c.transfer(ctx, Download, missing, func(o *batchObject) error {
+		a, ok := o.Actions[Download]
+		if !ok {
+			return fmt.Errorf("lfs: no download action for %s", o.pointer())
+		}
+
+		req, err := a.request(ctx, http.MethodGet, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		return store.Write(o.pointer(), res.Body)
+	})
+}
+
+// Upload uploads the content of the pointers from store, unless the server
+// already has it. It fails with ErrObjectNotFound if content the server
+// does not have is not in store.
+func (c *Client) Upload(ctx context.Context, store *Store, pointers ...*Pointer) error {
+	return c.transfer(ctx, Upload, pointers, func(o *batchObject) (err error) {
+		a, ok := o.Actions[Upload]
+		if !ok {
+			// The server already has the content.
+			return nil
+		}
+
+		p := o.pointer()
+		f, err := store.Open(p)
+		if err != nil {
+			return err
+		}
+		defer ioutil.CheckClose(f, &err)
+
+		// The file is closed here rather than by the HTTP client.
+		req, err := a.request(ctx, http.MethodPut, io.NopCloser(f))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = p.Size
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+
+		res, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		_ = res.Body.Close()
+
+		a, ok = o.Actions[verifyAction]
+		if !ok {
+			return nil
+		}
+
+		body, err := json.Marshal(batchObject{OID: p.OID, Size: p.Size})
+		if err != nil {
+			return err
+		}
+		req, err = a.request(ctx, http.MethodPost, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", mediaType)
+		req.Header.Set("Content-Type", mediaType)
+
+		res, err = c.do(req)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	})
+}
+
+// transfer requests the operation on the pointers in batches, and runs fn
+// on each object of the responses.
+func (c *Client) transfer(ctx context.Context, operation string, pointers []*Pointer, fn func(*batchObject) error) error {
+	var errs []error
+	for len(pointers) > 0 {
+		n := min(len(pointers), batchSize)
+		objects, err := c.batch(ctx, operation, pointers[:n])
+		if err != nil {
+			return err
+		}
+		requested := make(map[string]int64, n)
+		for _, p := range pointers[:n] {
+			requested[p.OID] = p.Size
+		}
+		pointers = pointers[n:]
+
+		for _, o := range objects {
+			// The objects of the response are used as paths of the store,
+			// so only those requested are trusted.
+			if size, ok := requested[o.OID]; !ok || size != o.Size || !validOID(o.OID) {
+				errs = append(errs, fmt.Errorf("lfs: batch %s: unexpected object %q of size %d in response", operation, o.OID, o.Size))
+				continue
+			}
+			if o.Error != nil {
+				errs = append(errs, o.Error.err(o.pointer()))
+				continue
+			}
+			if err := fn(o); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type batchRequest struct {
+	Operation string         `json:"operation"`
+	Transfers []string       `json:"transfers"`
+	Objects   []*batchObject `json:"objects"`
+	HashAlgo  string         `json:"hash_algo"`
+}
+
+type batchResponse struct {
+	Transfer string         `json:"transfer"`
+	Objects  []*batchObject `json:"objects"`
+}
+
+type batchObject struct {
+	OID     string             `json:"oid"`
+	Size    int64              `json:"size"`
+	Actions map[string]*action `json:"actions,omitempty"`
+	Error   *objectError       `json:"error,omitempty"`
+}
+
+func (o *batchObject) pointer() *Pointer {
+	return &Pointer{OID: o.OID, Size: o.Size}
+}
+
+type action struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+func (a *action) request(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.Href, body)
+	if err != nil {
+		return nil, fmt.Errorf("lfs: %w", err)
+	}
+	for k, v := range a.Header {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+type objectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *objectError) err(p *Pointer) error {
+	if e.Code == http.StatusNotFound {
+		return fmt.Errorf("%w: %s: %s", ErrObjectNotFound, p, e.Message)
+	}
+	return fmt.Errorf("lfs: %s: error %d: %s", p, e.Code, e.Message)
+}
+
+// batch sends a batch request of operation on the pointers, and returns the
+// objects of the response.
+func (c *Client) batch(ctx context.Context, operation string, pointers []*Pointer) ([]*batchObject, error) {
+	breq := batchRequest{
+		Operation: operation,
+		Transfers: []string{basicTransfer},
+		HashAlgo:  "sha256",
+	}
+	for _, p := range pointers {
+		breq.Objects = append(breq.Objects, &batchObject{OID: p.OID, Size: p.Size})
+	}
+
+	body, err := json.Marshal(breq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(c.endpoint.String(), "/")+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("lfs: %w", err)
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+
+	res, err := c.transport.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lfs: batch %s: %w", operation, err)
+	}
+	defer res.Body.Close()
+
+	var bres batchResponse
+	if err := json.NewDecoder(res.Body).Decode(&bres); err != nil {
+		return nil, fmt.Errorf("lfs: batch %s: %w", operation, err)
+	}
+	if bres.Transfer != "" && bres.Transfer != basicTransfer {
+		return nil, fmt.Errorf("lfs: batch %s: unsupported transfer %q", operation, bres.Transfer)
+	}
+
+	return bres.Objects, nil
+}
+
+// do sends the request of an action. As Git LFS, it is authenticated with
+// the transport when it does not have its own authentication and is sent
+// to the server; actions pointing to other hosts, such as object storage
+// services, are sent as given.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == c.endpoint.Host {
+		res, err := c.transport.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("lfs: %w", err)
+		}
+		return res, nil
+	}
+
+	res, err := c.transport.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lfs: %w", err)
+	}
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		defer res.Body.Close()
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+		return nil, fmt.Errorf("lfs: %s %s: status %d: %s",
+			req.Method, req.URL.Redacted(), res.StatusCode, bytes.TrimSpace(reason))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,151 @@
+package lfs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/internal/server/lfs"
+	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+)
+
+func newTestClient(t *testing.T, srv *lfs.Server, opts xhttp.Options) *Client {
+	t.Helper()
+
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+
+	endpoint, err := url.Parse(hs.URL + "/repo.git/info/lfs")
+	require.NoError(t, err)
+	return NewClient(endpoint, xhttp.NewTransport(opts))
+}
+
+func TestEndpoint(t *testing.T) {
+	t.Parallel()
+
+	for rawURL, expected := range map[string]string{
+		"https://example.com/org/repo":        "https://example.com/org/repo.git/info/lfs",
+		"https://example.com/org/repo.git/":   "https://example.com/org/repo.git/info/lfs",
+		"http://user@example.com:8080/repo":   "http://user@example.com:8080/repo.git/info/lfs",
+		"git@example.com:org/repo.git":        "https://example.com/org/repo.git/info/lfs",
+		"ssh://git@example.com:2222/org/repo": "https://example.com/org/repo.git/info/lfs",
+		"git://example.com/org/repo":          "https://example.com/org/repo.git/info/lfs",
+	} {
+		e, err := Endpoint(rawURL)
+		require.NoError(t, err, rawURL)
+		assert.Equal(t, expected, e.String(), rawURL)
+	}
+
+	_, err := Endpoint("/path/to/repo")
+	require.Error(t, err)
+}
+
+func TestClientDownload(t *testing.T) {
+	t.Parallel()
+
+	srv := lfs.NewServer()
+	srv.Username, srv.Password = "user", "secret"
+	oid := srv.Add([]byte("hello"))
+	c := newTestClient(t, srv, xhttp.Options{
+		Authorizer: (&xhttp.BasicAuth{Username: "user", Password: "secret"}).Authorizer,
+	})
+
+	s := NewStore(memfs.New())
+	hello := &Pointer{OID: oid, Size: 5}
+	require.NoError(t, c.Download(context.Background(), s, hello))
+	assert.True(t, s.Has(hello))
+
+	// Objects already in the store are not downloaded again.
+	n := len(srv.Requests())
+	require.NoError(t, c.Download(context.Background(), s, hello))
+	assert.Len(t, srv.Requests(), n)
+
+	missing := &Pointer{OID: strings.Repeat("0", 64), Size: 1}
+	err := c.Download(context.Background(), s, missing)
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestClientDownloadUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	srv := lfs.NewServer()
+	srv.Username, srv.Password = "user", "secret"
+	oid := srv.Add([]byte("hello"))
+	c := newTestClient(t, srv, xhttp.Options{})
+
+	err := c.Download(context.Background(), NewStore(memfs.New()), &Pointer{OID: oid, Size: 5})
+	require.ErrorContains(t, err, "401")
+}
+
+func TestClientUpload(t *testing.T) {
+	t.Parallel()
+
+	srv := lfs.NewServer()
+	c := newTestClient(t, srv, xhttp.Options{})
+	s := NewStore(memfs.New())
+
+	hello, err := s.Clean(strings.NewReader("hello"))
+	require.NoError(t, err)
+	world, err := s.Clean(strings.NewReader("world"))
+	require.NoError(t, err)
+	srv.Add([]byte("world"))
+
+	require.NoError(t, c.Upload(context.Background(), s, hello, world))
+	content, ok := srv.Object(hello.OID)
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, []string{
+		"POST /repo.git/info/lfs/objects/batch",
+		"PUT /repo.git/info/lfs/objects/" + hello.OID,
+		"POST /repo.git/info/lfs/objects/verify",
+	}, srv.Requests())
+
+	missing := &Pointer{OID: strings.Repeat("0", 64), Size: 1}
+	err = c.Upload(context.Background(), s, missing)
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestClientUnexpectedObjects(t *testing.T) {
+	t.Parallel()
+
+	s := NewStore(memfs.New())
+	hello, err := s.Clean(strings.NewReader("hello"))
+	require.NoError(t, err)
+
+	var transfers []string
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/objects/batch") {
+			transfers = append(transfers, r.Method+" "+r.URL.Path)
+			return
+		}
+
+		href := "http://" + r.Host + "/object"
+		actions := map[string]any{"download": map[string]string{"href": href}, "upload": map[string]string{"href": href}}
+		w.Header().Set("Content-Type", mediaType)
+		_ = json.NewEncoder(w).Encode(map[string]any{"objects": []map[string]any{
+			{"oid": "", "size": 0, "actions": actions},
+			{"oid": "a/../../../config", "size": 0, "actions": actions},
+			{"oid": hello.OID, "size": hello.Size + 1, "actions": actions},
+			{"oid": strings.Repeat("1", 64), "size": 1, "actions": actions},
+		}})
+	}))
+	t.Cleanup(hs.Close)
+
+	endpoint, err := url.Parse(hs.URL + "/repo.git/info/lfs")
+	require.NoError(t, err)
+	c := NewClient(endpoint, xhttp.NewTransport(xhttp.Options{}))
+
+	err = c.Upload(context.Background(), s, hello)
+	require.ErrorContains(t, err, "unexpected object")
+	err = c.Download(context.Background(), NewStore(memfs.New()), hello)
+	require.ErrorContains(t, err, "unexpected object")
+	assert.Empty(t, transfers)
+}
@@ -0,0 +1,129 @@
+// Package lfs implements Git LFS: the pointer files stored in the repository
+// in place of the large files, the local store of their content, and the
+// batch API client transferring it with LFS servers.
+//
+// See https://github.com/git-lfs/git-lfs/tree/main/docs.
+package lfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the version of the pointer files this package writes.
+	Version = "https://git-lfs.github.com/spec/v1"
+	// legacyVersion is the version of the pointer files written by the
+	// pre-release versions of Git LFS, which are still read.
+	legacyVersion = "https://hawser.github.com/spec/v1"
+
+	// MaxPointerSize is the size over which a file is not a pointer file.
+	MaxPointerSize = 1024
+
+	oidPrefix = "sha256:"
+)
+
+// ErrNotPointer is returned when decoding content that is not a pointer file.
+var ErrNotPointer = errors.New("lfs: not a pointer file")
+
+// Pointer is a pointer file, which identifies the content of a large file.
+type Pointer struct {
+	// OID is the hex encoded SHA-256 of the content.
+	OID string
+	// Size is the size of the content.
+	Size int64
+}
+
+// NewPointer returns the pointer of the content read from r.
+func NewPointer(r io.Reader) (*Pointer, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pointer{OID: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+// DecodePointer decodes the pointer file b. It fails with ErrNotPointer if b
+// is not one.
+func DecodePointer(b []byte) (*Pointer, error) {
+	if len(b) == 0 || len(b) > MaxPointerSize || b[len(b)-1] != '\n' {
+		return nil, ErrNotPointer
+	}
+
+	var p Pointer
+	var prev string
+	hasOID, hasSize := false, false
+	for i, line := range strings.Split(string(b[:len(b)-1]), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok || key == "" {
+			return nil, ErrNotPointer
+		}
+
+		if i == 0 {
+			if key != "version" || (value != Version && value != legacyVersion) {
+				return nil, ErrNotPointer
+			}
+			continue
+		}
+		// The keys following the version are sorted.
+		if key <= prev {
+			return nil, fmt.Errorf("%w: key %q is out of order", ErrNotPointer, key)
+		}
+		prev = key
+
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, oidPrefix)
+			if !ok || !validOID(oid) {
+				return nil, fmt.Errorf("%w: invalid oid %q", ErrNotPointer, value)
+			}
+			p.OID, hasOID = oid, true
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("%w: invalid size %q", ErrNotPointer, value)
+			}
+			p.Size, hasSize = size, true
+		}
+	}
+
+	if !hasOID || !hasSize {
+		return nil, ErrNotPointer
+	}
+
+	return &p, nil
+}
+
+// Encode returns the pointer file of p.
+func (p *Pointer) Encode() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "version %s\n", Version)
+	fmt.Fprintf(&b, "oid %s%s\n", oidPrefix, p.OID)
+	fmt.Fprintf(&b, "size %d\n", p.Size)
+	return b.Bytes()
+}
+
+func (p *Pointer) String() string {
+	return oidPrefix + p.OID
+}
+
+// validOID reports whether oid is a lowercase hex encoded SHA-256.
+func validOID(oid string) bool {
+	if len(oid) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range oid {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	helloOID     = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	helloPointer = "version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:" + helloOID + "\n" +
+		"size 5\n"
+)
+
+func TestNewPointer(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPointer(strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, &Pointer{OID: helloOID, Size: 5}, p)
+	assert.Equal(t, helloPointer, string(p.Encode()))
+	assert.Equal(t, "sha256:"+helloOID, p.String())
+}
+
+func TestDecodePointer(t *testing.T) {
+	t.Parallel()
+
+	p, err := DecodePointer([]byte(helloPointer))
+	require.NoError(t, err)
+	assert.Equal(t, &Pointer{OID: helloOID, Size: 5}, p)
+
+	legacy := strings.Replace(helloPointer, "git-lfs.github.com", "hawser.github.com", 1)
+	p, err = DecodePointer([]byte(legacy))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), p.Size)
+
+	// Extension keys are sorted between the version and the oid.
+	ext := strings.Replace(helloPointer, "oid ", "ext-0-foo sha256:"+helloOID+"\noid ", 1)
+	_, err = DecodePointer([]byte(ext))
+	require.NoError(t, err)
+}
+
+func TestDecodePointerInvalid(t *testing.T) {
+	t.Parallel()
+
+	for name, content := range map[string]string{
+		"empty":          "",
+		"content":        "hello\n",
+		"no newline":     strings.TrimSuffix(helloPointer, "\n"),
+		"version":        strings.Replace(helloPointer, "spec/v1", "spec/v2", 1),
+		"version second": "oid sha256:" + helloOID + "\nversion https://git-lfs.github.com/spec/v1\nsize 5\n",
+		"unsorted":       "version https://git-lfs.github.com/spec/v1\nsize 5\noid sha256:" + helloOID + "\n",
+		"no size":        "version https://git-lfs.github.com/spec/v1\noid sha256:" + helloOID + "\n",
+		"no oid":         "version https://git-lfs.github.com/spec/v1\nsize 5\n",
+		"oid hash":       strings.Replace(helloPointer, "sha256:", "sha1:", 1),
+		"oid length":     strings.Replace(helloPointer, helloOID, helloOID[:10], 1),
+		"oid uppercase":  strings.Replace(helloPointer, helloOID, strings.ToUpper(helloOID), 1),
+		"size":           strings.Replace(helloPointer, "size 5", "size -5", 1),
+		"too large":      helloPointer + strings.Repeat("x", MaxPointerSize) + "\n",
+	} {
+		_, err := DecodePointer([]byte(content))
+		assert.ErrorIs(t, err, ErrNotPointer, name)
+	}
+}
@@ -0,0 +1,121 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+const (
+	objectsPath = "lfs/objects"
+	tmpPath     = "lfs/tmp"
+)
+
+// ErrObjectNotFound is returned when the content of a pointer is not in the
+// store.
+var ErrObjectNotFound = errors.New("lfs: object not found")
+
+// Store is the local store of the content of the pointers, kept in the
+// lfs/objects directory of the git directory as Git LFS does.
+type Store struct {
+	fs billy.Filesystem
+}
+
+// NewStore returns the store of the git directory fs.
+func NewStore(fs billy.Filesystem) *Store {
+	return &Store{fs: fs}
+}
+
+// Empty reports whether the store has no content.
+func (s *Store) Empty() bool {
+	dirs, err := s.fs.ReadDir(objectsPath)
+	return err != nil || len(dirs) == 0
+}
+
+// Has reports whether the content of p is in the store.
+func (s *Store) Has(p *Pointer) bool {
+	if !validOID(p.OID) {
+		return false
+	}
+
+	fi, err := s.fs.Stat(objectPath(p.OID))
+	return err == nil && fi.Size() == p.Size
+}
+
+// Open opens the content of p. It fails with ErrObjectNotFound if it is not
+// in the store.
+func (s *Store) Open(p *Pointer) (billy.File, error) {
+	if !s.Has(p) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, p)
+	}
+
+	return s.fs.Open(objectPath(p.OID))
+}
+
+// Write stores the content of p read from r, once checked against p.
+func (s *Store) Write(p *Pointer, r io.Reader) error {
+	_, err := s.write(io.LimitReader(r, p.Size+1), p)
+	return err
+}
+
+// Clean stores the content read from r, and returns its pointer.
+func (s *Store) Clean(r io.Reader) (*Pointer, error) {
+	return s.write(r, nil)
+}
+
+// write stores the content read from r, and returns its pointer. If want is
+// set, the content is only stored if it is the one of want.
+func (s *Store) write(r io.Reader, want *Pointer) (_ *Pointer, err error) {
+	if err := s.fs.MkdirAll(tmpPath, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := s.fs.TempFile(tmpPath, "object-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = s.fs.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pointer{OID: hex.EncodeToString(h.Sum(nil)), Size: n}
+	if want != nil && *p != *want {
+		return nil, fmt.Errorf("lfs: content of %s does not match its pointer: got %s of size %d", want, p, p.Size)
+	}
+
+	name := objectPath(p.OID)
+	if s.Has(p) {
+		return p, s.fs.Remove(tmp.Name())
+	}
+
+	if err := s.fs.MkdirAll(path.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.fs.Rename(tmp.Name(), name); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// objectPath returns the path of the content of oid, which must be valid.
+func objectPath(oid string) string {
+	return path.Join(objectsPath, oid[0:2], oid[2:4], oid)
+}
@@ -0,0 +1,78 @@
+package lfs
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	fs := memfs.New()
+	s := NewStore(fs)
+	hello := &Pointer{OID: helloOID, Size: 5}
+	assert.True(t, s.Empty())
+	assert.False(t, s.Has(hello))
+	_, err := s.Open(hello)
+	require.ErrorIs(t, err, ErrObjectNotFound)
+
+	p, err := s.Clean(strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, hello, p)
+	assert.True(t, s.Has(hello))
+	assert.False(t, s.Empty())
+
+	content, err := util.ReadFile(fs, "lfs/objects/2c/f2/"+helloOID)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+
+	f, err := s.Open(hello)
+	require.NoError(t, err)
+	content, err = io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "hello", string(content))
+
+	// Storing the same content again is a no-op.
+	_, err = s.Clean(strings.NewReader("hello"))
+	require.NoError(t, err)
+
+	tmp, err := fs.ReadDir("lfs/tmp")
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+}
+
+func TestStoreWrite(t *testing.T) {
+	t.Parallel()
+
+	s := NewStore(memfs.New())
+	hello := &Pointer{OID: helloOID, Size: 5}
+
+	require.Error(t, s.Write(hello, strings.NewReader("hello, world")))
+	require.Error(t, s.Write(hello, strings.NewReader("olleh")))
+	assert.False(t, s.Has(hello))
+
+	require.NoError(t, s.Write(hello, strings.NewReader("hello")))
+	assert.True(t, s.Has(hello))
+}
+
+func TestStoreInvalidOID(t *testing.T) {
+	t.Parallel()
+
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "config", nil, 0o644))
+	s := NewStore(fs)
+
+	for _, oid := range []string{"", "a", "a/../../../config"} {
+		p := &Pointer{OID: oid}
+		assert.False(t, s.Has(p), oid)
+		_, err := s.Open(p)
+		require.ErrorIs(t, err, ErrObjectNotFound, oid)
+	}
+}
@@ -477,8 +477,9 @@ func (c *Commit) String() string {
 	)
 }
 
-// ErrMultipleSignatures is returned by Verify when the commit carries more
-// than one armored signature block. Mirrors upstream's parse_gpg_output
+// ErrMultipleSignatures is returned by Verify, and by the VerifySignature
+// methods of commits and tags, when the object carries more than one armored
+// signature block. Mirrors upstream's parse_gpg_output
 // rejection of GOODSIG/BADSIG status lines after the first
 // (gpg-interface.c:257-269): multi-signature commits are intentionally
 // unsupported because their provenance cannot be reduced to a single
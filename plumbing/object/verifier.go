@@ -0,0 +1,341 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/cms"
+	"github.com/go-git/go-git/v6/plumbing/format/sshsig"
+)
+
+var (
+	// ErrNotSigned is returned by VerifySignature when the object carries
+	// no signature.
+	ErrNotSigned = errors.New("object is not signed")
+	// ErrUnsupportedSignatureFormat is returned when no verifier handles
+	// the format of a signature.
+	ErrUnsupportedSignatureFormat = errors.New("unsupported signature format")
+)
+
+// SignatureFormat is the format of an object signature, named as in the
+// gpg.format configuration.
+type SignatureFormat string
+
+const (
+	// SignatureFormatOpenPGP is the format of OpenPGP signatures.
+	SignatureFormatOpenPGP SignatureFormat = "openpgp"
+	// SignatureFormatX509 is the format of X.509 (CMS) signatures.
+	SignatureFormatX509 SignatureFormat = "x509"
+	// SignatureFormatSSH is the format of SSH signatures.
+	SignatureFormatSSH SignatureFormat = "ssh"
+)
+
+// format returns the SignatureFormat of a signatureType.
+func (t signatureType) format() SignatureFormat {
+	switch t {
+	case signatureTypeOpenPGP:
+		return SignatureFormatOpenPGP
+	case signatureTypeX509:
+		return SignatureFormatX509
+	case signatureTypeSSH:
+		return SignatureFormatSSH
+	default:
+		return ""
+	}
+}
+
+// SignedPayload is a signature along with the object data it signs.
+type SignedPayload struct {
+	// Format is the format of Signature.
+	Format SignatureFormat
+	// Message is the signed data: the object encoded without its
+	// signature.
+	Message []byte
+	// Signature is the armored signature.
+	Signature []byte
+	// Signer is the committer of a commit or the tagger of a tag. Its time
+	// is the time the validity of the signing key is checked at.
+	Signer Signature
+}
+
+// VerifiedSignature describes a valid signature.
+type VerifiedSignature struct {
+	// Format is the format of the signature.
+	Format SignatureFormat
+	// Signer identifies the signer: the principal of an SSH key, the
+	// e-mail address or subject of an X.509 certificate, or the identity
+	// of an OpenPGP key.
+	Signer string
+	// Fingerprint is the fingerprint of the signing key or certificate.
+	Fingerprint string
+
+	// Entity is the OpenPGP entity of the signing key, for OpenPGP
+	// signatures.
+	Entity *openpgp.Entity
+	// PublicKey is the signing key, or certificate, for SSH signatures.
+	PublicKey gossh.PublicKey
+	// Chains are the verified certificate chains of the signer, for X.509
+	// signatures.
+	Chains [][]*x509.Certificate
+}
+
+// SignatureVerifier verifies object signatures.
+type SignatureVerifier interface {
+	// Verify checks the signature of p and returns a description of it
+	// if it is valid.
+	Verify(ctx context.Context, p *SignedPayload) (*VerifiedSignature, error)
+}
+
+// SignatureVerifiers is a SignatureVerifier delegating to the verifier
+// registered for the format of each signature.
+type SignatureVerifiers map[SignatureFormat]SignatureVerifier
+
+// Verify implements SignatureVerifier.
+func (vs SignatureVerifiers) Verify(ctx context.Context, p *SignedPayload) (*VerifiedSignature, error) {
+	v, ok := vs[p.Format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSignatureFormat, p.Format)
+	}
+	return v.Verify(ctx, p)
+}
+
+// NewSignedPayload returns the payload of message signed with signature by
+// signer, for data other than objects, such as push certificates. The format
+// of signature is detected from its armor.
+func NewSignedPayload(message, signature []byte, signer Signature) *SignedPayload {
+	return &SignedPayload{
+		Format:    typeForSignature(signature).format(),
+		Message:   message,
+		Signature: signature,
+		Signer:    signer,
+	}
+}
+
+// VerifySignature verifies the signature of the commit with v.
+func (c *Commit) VerifySignature(ctx context.Context, v SignatureVerifier) (*VerifiedSignature, error) {
+	if c.Signature == "" {
+		return nil, ErrNotSigned
+	}
+	if countSignatureBlocks([]byte(c.Signature)) > 1 {
+		return nil, ErrMultipleSignatures
+	}
+
+	p, err := signedPayload(c, c.Signature, c.Committer)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(ctx, p)
+}
+
+// VerifySignature verifies the signature of the tag with v.
+func (t *Tag) VerifySignature(ctx context.Context, v SignatureVerifier) (*VerifiedSignature, error) {
+	if t.Signature == "" {
+		return nil, ErrNotSigned
+	}
+	if countSignatureBlocks([]byte(t.Signature)) > 1 {
+		return nil, ErrMultipleSignatures
+	}
+
+	p, err := signedPayload(t, t.Signature, t.Tagger)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(ctx, p)
+}
+
+// signedPayload returns the payload of an object signed with signature.
+func signedPayload(o interface {
+	EncodeWithoutSignature(plumbing.EncodedObject) error
+}, signature string, signer Signature,
+) (*SignedPayload, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := o.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+	message, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedPayload{
+		Format:    typeForSignature([]byte(signature)).format(),
+		Message:   message,
+		Signature: []byte(signature),
+		Signer:    signer,
+	}, nil
+}
+
+// verifyTime returns the time the validity of the signing key of p is
+// checked at.
+func (p *SignedPayload) verifyTime() time.Time {
+	if p.Signer.When.IsZero() {
+		return time.Now()
+	}
+	return p.Signer.When
+}
+
+// OpenPGPVerifier verifies OpenPGP signatures against a key ring.
+type OpenPGPVerifier struct {
+	// KeyRing holds the trusted keys.
+	KeyRing openpgp.KeyRing
+}
+
+// NewOpenPGPVerifier returns an OpenPGPVerifier trusting the keys of an
+// armored key ring.
+func NewOpenPGPVerifier(armoredKeyRing string) (*OpenPGPVerifier, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKeyRing))
+	if err != nil {
+		return nil, err
+	}
+	return &OpenPGPVerifier{KeyRing: keyring}, nil
+}
+
+// Verify implements SignatureVerifier.
+func (v *OpenPGPVerifier) Verify(_ context.Context, p *SignedPayload) (*VerifiedSignature, error) {
+	if p.Format != SignatureFormatOpenPGP {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSignatureFormat, p.Format)
+	}
+
+	entity, err := openpgp.CheckArmoredDetachedSignature(
+		v.KeyRing, bytes.NewReader(p.Message), bytes.NewReader(p.Signature), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	vs := &VerifiedSignature{
+		Format:      SignatureFormatOpenPGP,
+		Fingerprint: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+		Entity:      entity,
+	}
+	if id := entity.PrimaryIdentity(); id != nil {
+		vs.Signer = id.Name
+	}
+	return vs, nil
+}
+
+// DefaultSSHNamespace is the namespace of the SSH signatures of Git
+// objects.
+const DefaultSSHNamespace = "git"
+
+// SSHVerifier verifies SSH signatures against allowed signers, as Git does
+// with gpg.ssh.allowedSignersFile.
+type SSHVerifier struct {
+	// AllowedSigners are the keys allowed to sign, with the principals they
+	// are allowed to sign for.
+	AllowedSigners sshsig.AllowedSigners
+	// Namespace is the namespace signatures must be made in. If empty,
+	// DefaultSSHNamespace is used.
+	Namespace string
+	// MatchSigner requires the e-mail address of the committer or tagger
+	// to be a principal the key is allowed to sign for. Otherwise, any
+	// allowed key is accepted, as Git does.
+	MatchSigner bool
+}
+
+// NewSSHVerifier returns an SSHVerifier trusting the keys of an allowed
+// signers file, as described in ssh-keygen(1).
+func NewSSHVerifier(allowedSigners io.Reader) (*SSHVerifier, error) {
+	signers, err := sshsig.ParseAllowedSigners(allowedSigners)
+	if err != nil {
+		return nil, err
+	}
+	return &SSHVerifier{AllowedSigners: signers}, nil
+}
+
+// Verify implements SignatureVerifier.
+func (v *SSHVerifier) Verify(_ context.Context, p *SignedPayload) (*VerifiedSignature, error) {
+	if p.Format != SignatureFormatSSH {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSignatureFormat, p.Format)
+	}
+
+	namespace := v.Namespace
+	if namespace == "" {
+		namespace = DefaultSSHNamespace
+	}
+
+	sig, err := sshsig.Parse(p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if err := sig.Verify(bytes.NewReader(p.Message), namespace); err != nil {
+		return nil, err
+	}
+
+	at := p.verifyTime()
+	var signer string
+	if v.MatchSigner {
+		signer = p.Signer.Email
+		if err := v.AllowedSigners.Allowed(signer, sig.PublicKey, namespace, at); err != nil {
+			return nil, err
+		}
+	} else {
+		principals := v.AllowedSigners.FindPrincipals(sig.PublicKey, namespace, at)
+		if len(principals) == 0 {
+			return nil, fmt.Errorf("%w: %s", sshsig.ErrSignerNotAllowed, gossh.FingerprintSHA256(sig.PublicKey))
+		}
+		signer = strings.Join(principals, ",")
+	}
+
+	key := sig.PublicKey
+	if cert, ok := key.(*gossh.Certificate); ok {
+		key = cert.Key
+	}
+	return &VerifiedSignature{
+		Format:      SignatureFormatSSH,
+		Signer:      signer,
+		Fingerprint: gossh.FingerprintSHA256(key),
+		PublicKey:   sig.PublicKey,
+	}, nil
+}
+
+// X509Verifier verifies X.509 (CMS) signatures, as made by gpgsm.
+type X509Verifier struct {
+	// Options are the options certificate chains are verified with. If
+	// Roots is nil, the system roots are used. If KeyUsages is empty, any
+	// extended key usage is accepted.
+	Options x509.VerifyOptions
+}
+
+// Verify implements SignatureVerifier.
+func (v *X509Verifier) Verify(_ context.Context, p *SignedPayload) (*VerifiedSignature, error) {
+	if p.Format != SignatureFormatX509 {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSignatureFormat, p.Format)
+	}
+
+	sig, err := cms.Parse(p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	chains, err := sig.Verify(bytes.NewReader(p.Message), v.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := sha256.Sum256(sig.Signer.Raw)
+	vs := &VerifiedSignature{
+		Format:      SignatureFormatX509,
+		Signer:      sig.Signer.Subject.String(),
+		Fingerprint: strings.ToUpper(hex.EncodeToString(fingerprint[:])),
+		Chains:      chains,
+	}
+	if len(sig.Signer.EmailAddresses) > 0 {
+		vs.Signer = sig.Signer.EmailAddresses[0]
+	}
+	return vs, nil
+}
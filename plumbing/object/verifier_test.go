@@ -0,0 +1,170 @@
+package object
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/sshsig"
+)
+
+func sshSignedPayload(t *testing.T, o interface {
+	EncodeWithoutSignature(plumbing.EncodedObject) error
+}, signer gossh.Signer, namespace string,
+) string {
+	t.Helper()
+
+	encoded := &plumbing.MemoryObject{}
+	require.NoError(t, o.EncodeWithoutSignature(encoded))
+	r, err := encoded.Reader()
+	require.NoError(t, err)
+	defer r.Close()
+
+	sig, err := sshsig.Sign(rand.Reader, signer, r, namespace)
+	require.NoError(t, err)
+	return string(sig)
+}
+
+func testSSHSigner(t *testing.T) (gossh.Signer, *SSHVerifier) {
+	t.Helper()
+
+	signer, err := gossh.ParsePrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+	v, err := NewSSHVerifier(strings.NewReader(
+		`alice@example.com namespaces="git",valid-after="20200101Z" ` +
+			string(gossh.MarshalAuthorizedKey(signer.PublicKey()))))
+	require.NoError(t, err)
+	return signer, v
+}
+
+func TestCommitVerifySignatureSSH(t *testing.T) {
+	t.Parallel()
+
+	signer, v := testSSHSigner(t)
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := &Commit{
+		Author:    Signature{Name: "Alice", Email: "alice@example.com", When: when},
+		Committer: Signature{Name: "Alice", Email: "alice@example.com", When: when},
+		Message:   "signed\n",
+		TreeHash:  plumbing.NewHash("52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24"),
+	}
+
+	_, err := commit.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, ErrNotSigned)
+
+	commit.Signature = sshSignedPayload(t, commit, signer, "git")
+	vs, err := commit.VerifySignature(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureFormatSSH, vs.Format)
+	assert.Equal(t, "alice@example.com", vs.Signer)
+	assert.Equal(t, gossh.FingerprintSHA256(signer.PublicKey()), vs.Fingerprint)
+
+	v.MatchSigner = true
+	_, err = commit.VerifySignature(context.Background(), v)
+	require.NoError(t, err)
+
+	// The committer is not the principal of the key.
+	other := *commit
+	other.Committer.Email = "bob@example.com"
+	other.Signature = sshSignedPayload(t, &other, signer, "git")
+	_, err = other.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, sshsig.ErrSignerNotAllowed)
+
+	// The key is not valid yet when the commit was made.
+	other = *commit
+	other.Committer.When = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	other.Signature = sshSignedPayload(t, &other, signer, "git")
+	_, err = other.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, sshsig.ErrSignerNotAllowed)
+
+	// The signature is made in another namespace.
+	other = *commit
+	other.Signature = sshSignedPayload(t, &other, signer, "file")
+	_, err = other.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, sshsig.ErrNamespaceMismatch)
+
+	// The commit was modified after being signed.
+	other = *commit
+	other.Message = "modified\n"
+	_, err = other.VerifySignature(context.Background(), v)
+	require.Error(t, err)
+}
+
+func TestTagVerifySignatureSSH(t *testing.T) {
+	t.Parallel()
+
+	signer, v := testSSHSigner(t)
+	tag := &Tag{
+		Name:       "v1.0.0",
+		Tagger:     Signature{Name: "Alice", Email: "alice@example.com", When: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Message:    "release\n",
+		TargetType: plumbing.CommitObject,
+		Target:     plumbing.NewHash("1eca38290a3131d0c90709496a9b2207a872631e"),
+	}
+	tag.Signature = sshSignedPayload(t, tag, signer, "git")
+
+	vs, err := tag.VerifySignature(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", vs.Signer)
+
+	// Another key is not allowed.
+	unknown, err := gossh.ParsePrivateKey(testdata.PEMBytes["ecdsa"])
+	require.NoError(t, err)
+	tag.Signature = sshSignedPayload(t, tag, unknown, "git")
+	_, err = tag.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, sshsig.ErrSignerNotAllowed)
+
+	// A tag with more than one signature is refused.
+	tag.Signature = sshSignedPayload(t, tag, signer, "git") + sshSignedPayload(t, tag, signer, "git")
+	_, err = tag.VerifySignature(context.Background(), v)
+	require.ErrorIs(t, err, ErrMultipleSignatures)
+}
+
+func TestSignatureVerifiers(t *testing.T) {
+	t.Parallel()
+
+	signer, v := testSSHSigner(t)
+	commit := &Commit{
+		Author:    Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()},
+		Committer: Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()},
+		Message:   "signed\n",
+		TreeHash:  plumbing.NewHash("52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24"),
+	}
+	commit.Signature = sshSignedPayload(t, commit, signer, "git")
+
+	_, err := commit.VerifySignature(context.Background(), SignatureVerifiers{
+		SignatureFormatOpenPGP: &OpenPGPVerifier{},
+	})
+	require.ErrorIs(t, err, ErrUnsupportedSignatureFormat)
+
+	vs, err := commit.VerifySignature(context.Background(), SignatureVerifiers{
+		SignatureFormatOpenPGP: &OpenPGPVerifier{},
+		SignatureFormatSSH:     v,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, SignatureFormatSSH, vs.Format)
+
+	_, err = (&OpenPGPVerifier{}).Verify(context.Background(), &SignedPayload{Format: SignatureFormatSSH})
+	require.ErrorIs(t, err, ErrUnsupportedSignatureFormat)
+	_, err = (&X509Verifier{}).Verify(context.Background(), &SignedPayload{Format: SignatureFormatSSH})
+	require.ErrorIs(t, err, ErrUnsupportedSignatureFormat)
+}
+
+func TestX509VerifierInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&X509Verifier{}).Verify(context.Background(), &SignedPayload{
+		Format:    SignatureFormatX509,
+		Message:   []byte("hello\n"),
+		Signature: []byte("-----BEGIN SIGNED MESSAGE-----\nAAAA\n-----END SIGNED MESSAGE-----\n"),
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package packp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+// BundleURIArgs represents the arguments for the v2 bundle-uri command, which
+// takes none.
+type BundleURIArgs struct{}
+
+// Encode implements Encoder. The bundle-uri command has no arguments.
+func (*BundleURIArgs) Encode(io.Writer) error { return nil }
+
+// Decode reads bundle-uri arguments from a reader until a flush-pkt is
+// encountered. The bundle-uri command has no arguments, so any is rejected.
+func (*BundleURIArgs) Decode(rd io.Reader) error {
+	for {
+		l, pkt, err := pktline.ReadLine(rd)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if l == pktline.Flush {
+			return nil
+		}
+		return fmt.Errorf("unexpected bundle-uri argument: %q", strings.TrimSuffix(string(pkt), "\n"))
+	}
+}
+
+// BundleURIEntry is a key and value of a bundle list, as a git config
+// variable of the bundle section, such as "bundle.version" or
+// "bundle.<id>.uri".
+type BundleURIEntry struct {
+	Key   string
+	Value string
+}
+
+// BundleURIOutput represents the server response to a bundle-uri command: the
+// bundle list advertised by the server, as a line per key and value:
+//
+//	bundle.version=1
+//	bundle.mode=all
+//	bundle.<id>.uri=<uri>
+//
+// The response ends with a flush-pkt. For HTTP, response-end (0002) is
+// consumed by the transport layer and not seen by Decode.
+type BundleURIOutput struct {
+	Entries []BundleURIEntry
+}
+
+// Encode writes the bundle-uri response lines. The caller is responsible for
+// writing the flush-pkt after these lines.
+func (r *BundleURIOutput) Encode(w io.Writer) error {
+	for _, e := range r.Entries {
+		if e.Key == "" || strings.ContainsAny(e.Key, "=\n") || strings.Contains(e.Value, "\n") {
+			return fmt.Errorf("invalid bundle-uri entry %q", e.Key)
+		}
+		if _, err := pktline.Writef(w, "%s=%s\n", e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads the bundle-uri response until a flush-pkt.
+func (r *BundleURIOutput) Decode(rd io.Reader) error {
+	for {
+		l, pkt, err := pktline.ReadLine(rd)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if l == pktline.Flush || l == pktline.ResponseEnd {
+			return nil
+		}
+
+		line := strings.TrimSuffix(string(pkt), "\n")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("malformed bundle-uri line: %q", line)
+		}
+		if len(r.Entries) >= maxSectionLines {
+			return fmt.Errorf("too many bundle-uri lines (limit %d)", maxSectionLines)
+		}
+		r.Entries = append(r.Entries, BundleURIEntry{Key: key, Value: value})
+	}
+}
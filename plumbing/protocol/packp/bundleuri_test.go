@@ -0,0 +1,62 @@
+package packp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+func TestBundleURIArgs(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, (&BundleURIArgs{}).Encode(&buf))
+	assert.Zero(t, buf.Len())
+
+	require.NoError(t, pktline.WriteFlush(&buf))
+	require.NoError(t, (&BundleURIArgs{}).Decode(&buf))
+
+	_, err := pktline.WriteString(&buf, "unexpected\n")
+	require.NoError(t, err)
+	require.NoError(t, pktline.WriteFlush(&buf))
+	require.Error(t, (&BundleURIArgs{}).Decode(&buf))
+}
+
+func TestBundleURIOutputRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	out := &BundleURIOutput{Entries: []BundleURIEntry{
+		{Key: "bundle.version", Value: "1"},
+		{Key: "bundle.mode", Value: "all"},
+		{Key: "bundle.one.uri", Value: "https://example.com/one.bundle?a=b"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, out.Encode(&buf))
+	assert.Equal(t, "0015bundle.version=1\n"+
+		"0014bundle.mode=all\n"+
+		"0036bundle.one.uri=https://example.com/one.bundle?a=b\n", buf.String())
+
+	require.NoError(t, pktline.WriteFlush(&buf))
+	got := &BundleURIOutput{}
+	require.NoError(t, got.Decode(&buf))
+	assert.Equal(t, out, got)
+}
+
+func TestBundleURIOutputErrors(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.Error(t, (&BundleURIOutput{Entries: []BundleURIEntry{{Key: "a=b", Value: "c"}}}).Encode(&buf))
+	require.Error(t, (&BundleURIOutput{Entries: []BundleURIEntry{{Key: "a", Value: "b\nc"}}}).Encode(&buf))
+
+	buf.Reset()
+	_, err := pktline.WriteString(&buf, "no-equals-sign\n")
+	require.NoError(t, err)
+	require.NoError(t, pktline.WriteFlush(&buf))
+	require.Error(t, (&BundleURIOutput{}).Decode(&buf))
+}
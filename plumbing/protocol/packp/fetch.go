@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
 )
 
 // maxSectionLines bounds how many entries a single fetch section (want, have,
@@ -37,6 +38,10 @@ func (e *MalformedResponseError) Error() string {
 type FetchArgs struct {
 	// Wants is the list of object IDs the client wants.
 	Wants []plumbing.Hash
+	// WantRefs is the list of references the client wants, by name. The
+	// server resolves them and reports their values in the wanted-refs
+	// section. It requires the ref-in-want fetch feature.
+	WantRefs []plumbing.ReferenceName
 	// Haves is the list of object IDs the client already has.
 	Haves []plumbing.Hash
 	// Done indicates the client is done sending wants and haves.
@@ -64,9 +69,16 @@ type FetchArgs struct {
 
 	// Filter specifies a partial clone filter.
 	Filter Filter
-	// WaitForDone indicates that the client will wait for the server to send a
-	// done acknowledgment before sending additional want/have lines.
+	// WaitForDone asks the server not to send a packfile until the client
+	// sends done, even once it is ready to. A client only negotiating common
+	// commits, as git fetch --negotiate-only does, sends no wants.
 	WaitForDone bool
+	// SidebandAll asks the server to multiplex the whole response, not just
+	// the packfile, over the sideband.
+	SidebandAll bool
+	// PackfileURIs lists the protocols, such as "https", of the URIs the
+	// client accepts for packfiles the server offloads to them.
+	PackfileURIs []string
 }
 
 // Encode writes the v2 fetch command arguments to a writer.
@@ -74,7 +86,7 @@ type FetchArgs struct {
 // The caller is responsible for writing the delim-pkt before and
 // the flush-pkt after these arguments.
 func (r *FetchArgs) Encode(w io.Writer) error {
-	if len(r.Wants) == 0 {
+	if len(r.Wants) == 0 && len(r.WantRefs) == 0 && !r.WaitForDone {
 		return fmt.Errorf("empty wants provided")
 	}
 
@@ -86,6 +98,12 @@ func (r *FetchArgs) Encode(w io.Writer) error {
 		}
 	}
 
+	for _, ref := range r.WantRefs {
+		if _, err := pktline.Writef(w, "want-ref %s\n", ref); err != nil {
+			return fmt.Errorf("encoding want-ref %q: %w", ref, err)
+		}
+	}
+
 	haves := append([]plumbing.Hash(nil), r.Haves...)
 	plumbing.HashesSort(haves)
 	for _, h := range haves {
@@ -170,6 +188,19 @@ func (r *FetchArgs) Encode(w io.Writer) error {
 		}
 	}
 
+	if r.SidebandAll {
+		if _, err := pktline.WriteString(w, "sideband-all\n"); err != nil {
+			return fmt.Errorf("encoding sideband-all: %w", err)
+		}
+	}
+
+	if len(r.PackfileURIs) > 0 {
+		protocols := strings.Join(r.PackfileURIs, ",")
+		if _, err := pktline.Writef(w, "packfile-uris %s\n", protocols); err != nil {
+			return fmt.Errorf("encoding packfile-uris %s: %w", protocols, err)
+		}
+	}
+
 	return nil
 }
 
@@ -206,6 +237,12 @@ func (r *FetchArgs) Decode(rd io.Reader) error {
 			}
 			r.Wants = append(r.Wants, h)
 
+		case strings.HasPrefix(line, "want-ref "):
+			if len(r.WantRefs) >= maxSectionLines {
+				return fmt.Errorf("too many want-ref lines (limit %d)", maxSectionLines)
+			}
+			r.WantRefs = append(r.WantRefs, plumbing.ReferenceName(line[9:]))
+
 		case strings.HasPrefix(line, "have "):
 			h, ok := parseFullHash(line[5:])
 			if !ok {
@@ -274,6 +311,16 @@ func (r *FetchArgs) Decode(rd io.Reader) error {
 
 		case line == "wait-for-done":
 			r.WaitForDone = true
+
+		case line == "sideband-all":
+			r.SidebandAll = true
+
+		case strings.HasPrefix(line, "packfile-uris "):
+			for p := range strings.SplitSeq(line[14:], ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					r.PackfileURIs = append(r.PackfileURIs, p)
+				}
+			}
 		}
 	}
 }
@@ -314,10 +361,21 @@ type WantedRefs struct {
 // alternate URIs the server suggests for fetching the packfile.
 type PackfileURIs struct {
 	// URIs is the list of alternate URIs the server suggests for fetching the
-	// packfile.
+	// packfile. Each is a line "<pack-hash> SP <uri>"; see ParsePackfileURI.
 	URIs []string
 }
 
+// ParsePackfileURI splits a line of the packfile-uris section into the hash
+// of the pack, the checksum its trailer holds, and the URI it is served at.
+func ParsePackfileURI(line string) (plumbing.Hash, string, error) {
+	hash, uri, ok := strings.Cut(line, " ")
+	h, valid := parseFullHash(hash)
+	if !ok || !valid || uri == "" {
+		return plumbing.ZeroHash, "", &MalformedResponseError{Reason: fmt.Sprintf("malformed packfile-uris line: %q", line)}
+	}
+	return h, uri, nil
+}
+
 // FetchOutput represents the server response to a v2 fetch command.
 //
 // The response has explicit sections separated by delim-pkt:
@@ -350,6 +408,14 @@ type FetchOutput struct {
 	// When false, the response is a negotiation round
 	// (acknowledgments flush-pkt) that carries no packfile.
 	Packfile bool
+
+	// SidebandAll reports whether the response is multiplexed over the
+	// sideband as a whole, as the client asked with sideband-all. Decode and
+	// Encode then read and write each section line on the data channel.
+	SidebandAll bool
+	// Progress receives the progress messages Decode reads from a
+	// sideband-all response.
+	Progress sideband.Progress
 }
 
 // Decode reads the v2 fetch response from a reader. The response has
@@ -383,7 +449,14 @@ type FetchOutput struct {
 //
 // For HTTP, the transport layer consumes response-end (0002) after
 // Decode returns.
+//
+// With SidebandAll, the packfile that follows is multiplexed as it is
+// without it, so the caller streams it the same way.
 func (r *FetchOutput) Decode(rd io.Reader) error {
+	if r.SidebandAll {
+		rd = &sidebandAllReader{r: rd, progress: r.Progress}
+	}
+
 	// Sections appear at most once and in the fixed grammar order
 	// (acknowledgments < shallow-info < wanted-refs < packfile-uris <
 	// packfile). lastRank enforces both: a header whose rank is not strictly
@@ -527,6 +600,10 @@ func (r *FetchOutput) decodeMetadataSection(rd io.Reader, decode func(io.Reader)
 // nothing else. In that case the acknowledgments section must be present
 // and must not be ready, and no other metadata sections may be set.
 func (r *FetchOutput) Encode(w io.Writer) error {
+	if r.SidebandAll {
+		w = &sidebandAllWriter{w: w}
+	}
+
 	if !r.Packfile {
 		if r.Acknowledgments == nil {
 			return fmt.Errorf("fetch response without a packfile must carry acknowledgments")
@@ -109,6 +109,42 @@ func TestFetchArgsEncode(t *testing.T) {
 		assert.True(t, got.WaitForDone)
 	})
 
+	t.Run("want-ref without wants", func(t *testing.T) {
+		t.Parallel()
+		req := &FetchArgs{
+			WantRefs: []plumbing.ReferenceName{"refs/heads/main"},
+			Done:     true,
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, req.Encode(&buf))
+		assert.Contains(t, buf.String(), "want-ref refs/heads/main\n")
+		pktline.WriteFlush(&buf)
+
+		got := &FetchArgs{}
+		require.NoError(t, got.Decode(&buf))
+		assert.Empty(t, got.Wants)
+		assert.Equal(t, req.WantRefs, got.WantRefs)
+	})
+
+	t.Run("wait-for-done without wants", func(t *testing.T) {
+		t.Parallel()
+		req := &FetchArgs{
+			Haves:       []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")},
+			WaitForDone: true,
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, req.Encode(&buf))
+		pktline.WriteFlush(&buf)
+
+		got := &FetchArgs{}
+		require.NoError(t, got.Decode(&buf))
+		assert.Empty(t, got.Wants)
+		assert.Equal(t, req.Haves, got.Haves)
+		assert.True(t, got.WaitForDone)
+	})
+
 	t.Run("deepen-relative", func(t *testing.T) {
 		t.Parallel()
 		req := &FetchArgs{
@@ -487,17 +523,20 @@ func TestFetchArgsRoundTrip(t *testing.T) {
 			Haves: []plumbing.Hash{
 				plumbing.NewHash("5dc01c595e6c6ec9ccda4f6f69c131c0dd945f8c"),
 			},
-			Done:        true,
-			ThinPack:    true,
-			NoProgress:  true,
-			IncludeTag:  true,
-			OFSDelta:    true,
-			Shallows:    []plumbing.Hash{plumbing.NewHash("3333333333333333333333333333333333333333")},
-			Deepen:      10,
-			DeepenSince: ts,
-			DeepenNot:   []string{"refs/heads/feature"},
-			Filter:      FilterBlobNone(),
-			WaitForDone: true,
+			Done:         true,
+			ThinPack:     true,
+			NoProgress:   true,
+			IncludeTag:   true,
+			OFSDelta:     true,
+			Shallows:     []plumbing.Hash{plumbing.NewHash("3333333333333333333333333333333333333333")},
+			Deepen:       10,
+			DeepenSince:  ts,
+			DeepenNot:    []string{"refs/heads/feature"},
+			Filter:       FilterBlobNone(),
+			WaitForDone:  true,
+			SidebandAll:  true,
+			PackfileURIs: []string{"https", "http"},
+			WantRefs:     []plumbing.ReferenceName{"refs/heads/main"},
 		}
 
 		var buf bytes.Buffer
@@ -519,5 +558,85 @@ func TestFetchArgsRoundTrip(t *testing.T) {
 		assert.Equal(t, req.DeepenNot, got.DeepenNot)
 		assert.Equal(t, req.Filter, got.Filter)
 		assert.Equal(t, req.WaitForDone, got.WaitForDone)
+		assert.Equal(t, req.SidebandAll, got.SidebandAll)
+		assert.Equal(t, req.PackfileURIs, got.PackfileURIs)
+		assert.Equal(t, req.WantRefs, got.WantRefs)
 	})
 }
+
+func TestParsePackfileURI(t *testing.T) {
+	t.Parallel()
+
+	h, uri, err := ParsePackfileURI("6ecf0ef2c2dffb796033e5a02219af86ec6584e5 https://example.com/p.pack")
+	require.NoError(t, err)
+	assert.Equal(t, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), h)
+	assert.Equal(t, "https://example.com/p.pack", uri)
+
+	for _, line := range []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 ",
+		"6ecf0ef2 https://example.com/p.pack",
+	} {
+		_, _, err := ParsePackfileURI(line)
+		var malformed *MalformedResponseError
+		require.ErrorAs(t, err, &malformed, line)
+	}
+}
+
+func TestFetchOutputSidebandAll(t *testing.T) {
+	t.Parallel()
+
+	resp := &FetchOutput{
+		Acknowledgments: &Acknowledgments{
+			ACKs:  []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")},
+			Ready: true,
+		},
+		WantedRefs: &WantedRefs{Refs: []*plumbing.Reference{
+			plumbing.NewHashReference("refs/heads/main", plumbing.NewHash("a6930aaee06755d1bdcfd943fbf614e4d92bb0c7")),
+		}},
+		Packfile:    true,
+		SidebandAll: true,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, resp.Encode(&buf))
+	assert.Contains(t, buf.String(), "\x01acknowledgments\n")
+	assert.Contains(t, buf.String(), "0001")
+
+	// A progress message may be interleaved anywhere, and the packfile that
+	// follows is read from the same reader.
+	var wire bytes.Buffer
+	_, err := pktline.Write(&wire, []byte("\x02Enumerating objects\n"))
+	require.NoError(t, err)
+	wire.Write(buf.Bytes())
+	_, err = pktline.Write(&wire, []byte("\x01PACK"))
+	require.NoError(t, err)
+	require.NoError(t, pktline.WriteFlush(&wire))
+
+	var progress bytes.Buffer
+	got := &FetchOutput{SidebandAll: true, Progress: &progress}
+	require.NoError(t, got.Decode(&wire))
+	assert.True(t, got.Packfile)
+	require.NotNil(t, got.Acknowledgments)
+	assert.True(t, got.Acknowledgments.Ready)
+	require.NotNil(t, got.WantedRefs)
+	assert.Equal(t, resp.WantedRefs.Refs, got.WantedRefs.Refs)
+	assert.Equal(t, "Enumerating objects\n", progress.String())
+
+	_, pkt, err := pktline.ReadLine(&wire)
+	require.NoError(t, err)
+	assert.Equal(t, "\x01PACK", string(pkt))
+}
+
+func TestFetchOutputSidebandAllError(t *testing.T) {
+	t.Parallel()
+
+	var wire bytes.Buffer
+	_, err := pktline.Write(&wire, []byte("\x03something went wrong\n"))
+	require.NoError(t, err)
+
+	got := &FetchOutput{SidebandAll: true}
+	err = got.Decode(&wire)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "something went wrong")
+}
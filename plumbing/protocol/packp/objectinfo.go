@@ -0,0 +1,170 @@
+package packp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+// ObjectInfoArgs represents the arguments for the v2 object-info command.
+//
+// Each argument is a pkt-line:
+//
+//	size
+//	oid <oid>
+type ObjectInfoArgs struct {
+	// Size requests the size of each object.
+	Size bool
+	// OIDs is the list of objects to report on.
+	OIDs []plumbing.Hash
+}
+
+// Encode writes the object-info arguments to a writer. The caller is
+// responsible for writing the delim-pkt before and the flush-pkt after these
+// arguments.
+func (r *ObjectInfoArgs) Encode(w io.Writer) error {
+	if len(r.OIDs) == 0 {
+		return fmt.Errorf("empty oids provided")
+	}
+
+	if r.Size {
+		if _, err := pktline.WriteString(w, "size\n"); err != nil {
+			return err
+		}
+	}
+	for _, h := range r.OIDs {
+		if _, err := pktline.Writef(w, "oid %s\n", h); err != nil {
+			return fmt.Errorf("encoding oid %q: %w", h, err)
+		}
+	}
+	return nil
+}
+
+// Decode reads object-info arguments from a reader until a flush-pkt is
+// encountered.
+func (r *ObjectInfoArgs) Decode(rd io.Reader) error {
+	for {
+		l, pkt, err := pktline.ReadLine(rd)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if l == pktline.Flush {
+			return nil
+		}
+
+		line := strings.TrimSuffix(string(pkt), "\n")
+		switch {
+		case line == "size":
+			r.Size = true
+		case strings.HasPrefix(line, "oid "):
+			h, ok := parseFullHash(line[4:])
+			if !ok {
+				return fmt.Errorf("malformed oid: %q", line[4:])
+			}
+			if len(r.OIDs) >= maxSectionLines {
+				return fmt.Errorf("too many oid lines (limit %d)", maxSectionLines)
+			}
+			r.OIDs = append(r.OIDs, h)
+		default:
+			return fmt.Errorf("unexpected object-info argument: %q", line)
+		}
+	}
+}
+
+// ObjectInfo is the information about an object reported by the object-info
+// command.
+type ObjectInfo struct {
+	Hash plumbing.Hash
+	// Size is the size of the object, or -1 if the server does not have it.
+	Size int64
+}
+
+// ObjectInfoOutput represents the server response to an object-info command.
+//
+// When sizes were requested, the response starts with the attribute line
+// "size", and each object line carries the object's size:
+//
+//	size
+//	<oid> SP <size>
+//
+// An object the server does not have is reported with an empty size. The
+// response ends with a flush-pkt. For HTTP, response-end (0002) is consumed by
+// the transport layer and not seen by Decode.
+type ObjectInfoOutput struct {
+	// Size reports whether the response carries object sizes.
+	Size    bool
+	Objects []ObjectInfo
+}
+
+// Encode writes the object-info response lines. The caller is responsible for
+// writing the flush-pkt after these lines.
+func (r *ObjectInfoOutput) Encode(w io.Writer) error {
+	if r.Size {
+		if _, err := pktline.WriteString(w, "size\n"); err != nil {
+			return err
+		}
+	}
+	for _, o := range r.Objects {
+		line := o.Hash.String()
+		if r.Size {
+			line += " "
+			if o.Size >= 0 {
+				line += strconv.FormatInt(o.Size, 10)
+			}
+		}
+		if _, err := pktline.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads the object-info response until a flush-pkt.
+func (r *ObjectInfoOutput) Decode(rd io.Reader) error {
+	for {
+		l, pkt, err := pktline.ReadLine(rd)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if l == pktline.Flush || l == pktline.ResponseEnd {
+			return nil
+		}
+
+		line := strings.TrimSuffix(string(pkt), "\n")
+		if line == "size" && len(r.Objects) == 0 {
+			r.Size = true
+			continue
+		}
+
+		oid, size, _ := strings.Cut(line, " ")
+		h, ok := parseFullHash(oid)
+		if !ok {
+			return fmt.Errorf("malformed object-info hash: %q", oid)
+		}
+		info := ObjectInfo{Hash: h, Size: -1}
+		if r.Size && size != "" {
+			n, err := strconv.ParseInt(size, 10, 64)
+			if err != nil || n < 0 {
+				return fmt.Errorf("malformed object-info size: %q", size)
+			}
+			info.Size = n
+		}
+		if len(r.Objects) >= maxSectionLines {
+			return fmt.Errorf("too many object-info lines (limit %d)", maxSectionLines)
+		}
+		r.Objects = append(r.Objects, info)
+	}
+}
@@ -0,0 +1,106 @@
+package packp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+func TestObjectInfoArgsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	req := &ObjectInfoArgs{
+		Size: true,
+		OIDs: []plumbing.Hash{
+			plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+			plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa"),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, req.Encode(&buf))
+	assert.Equal(t, "0009size\n"+
+		"0031oid 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"+
+		"0031oid d3ff53e0564a9f87d8e84b6e28e5060e517008aa\n", buf.String())
+
+	require.NoError(t, pktline.WriteFlush(&buf))
+	got := &ObjectInfoArgs{}
+	require.NoError(t, got.Decode(&buf))
+	assert.Equal(t, req, got)
+
+	require.Error(t, (&ObjectInfoArgs{Size: true}).Encode(&buf))
+}
+
+func TestObjectInfoArgsDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, line := range []string{"oid xyz\n", "type\n"} {
+		var buf bytes.Buffer
+		_, err := pktline.WriteString(&buf, line)
+		require.NoError(t, err)
+		require.NoError(t, pktline.WriteFlush(&buf))
+		require.Error(t, (&ObjectInfoArgs{}).Decode(&buf), line)
+	}
+}
+
+func TestObjectInfoOutputRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	out := &ObjectInfoOutput{
+		Size: true,
+		Objects: []ObjectInfo{
+			{Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), Size: 245},
+			{Hash: plumbing.NewHash("0000000000000000000000000000000000000001"), Size: -1},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, out.Encode(&buf))
+	assert.Equal(t, "0009size\n"+
+		"00316ecf0ef2c2dffb796033e5a02219af86ec6584e5 245\n"+
+		"002e0000000000000000000000000000000000000001 \n", buf.String())
+
+	require.NoError(t, pktline.WriteFlush(&buf))
+	got := &ObjectInfoOutput{}
+	require.NoError(t, got.Decode(&buf))
+	assert.Equal(t, out, got)
+}
+
+func TestObjectInfoOutputWithoutSize(t *testing.T) {
+	t.Parallel()
+
+	out := &ObjectInfoOutput{
+		Objects: []ObjectInfo{{Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), Size: -1}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, out.Encode(&buf))
+	assert.Equal(t, "002d6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n", buf.String())
+
+	require.NoError(t, pktline.WriteFlush(&buf))
+	got := &ObjectInfoOutput{}
+	require.NoError(t, got.Decode(&buf))
+	assert.Equal(t, out, got)
+}
+
+func TestObjectInfoOutputDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, lines := range [][]string{
+		{"size\n", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5 x\n"},
+		{"size\n", "6ecf0ef2 12\n"},
+	} {
+		var buf bytes.Buffer
+		for _, l := range lines {
+			_, err := pktline.WriteString(&buf, l)
+			require.NoError(t, err)
+		}
+		require.NoError(t, pktline.WriteFlush(&buf))
+		require.Error(t, (&ObjectInfoOutput{}).Decode(&buf), lines)
+	}
+}
@@ -0,0 +1,153 @@
+package packp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+// PushCertVersion is the version of the push certificates this package
+// encodes.
+const PushCertVersion = "0.1"
+
+var (
+	pushCert      = []byte("push-cert")
+	pushCertEnd   = "push-cert-end"
+	certVersion   = "certificate version "
+	certPusher    = "pusher "
+	certPushee    = "pushee "
+	certNonce     = "nonce "
+	certOption    = "push-option "
+	signatureHead = "-----BEGIN "
+)
+
+// ErrMalformedPushCert is returned when decoding a malformed push
+// certificate.
+var ErrMalformedPushCert = errors.New("malformed push certificate")
+
+// PushCertificate is a signed push certificate, sent by the client in place
+// of the command list to record who requested the reference updates of a
+// push.
+//
+// See https://git-scm.com/docs/gitprotocol-pack#_push_certificate
+type PushCertificate struct {
+	// Version is the version of the certificate format. If empty,
+	// PushCertVersion is used.
+	Version string
+	// Pusher identifies the signer and the time of the push, as in
+	// "A U Thor <author@example.com> 1700000000 +0000".
+	Pusher string
+	// Pushee is the URL of the repository pushed to, without credentials.
+	Pushee string
+	// Nonce is the nonce the server advertised with the push-cert
+	// capability.
+	Nonce string
+	// Options are the push options of the push.
+	Options []string
+	// Commands are the reference updates of the push.
+	Commands []*Command
+	// Signature is the armored signature of the payload.
+	Signature []byte
+}
+
+// Payload returns the signed part of the certificate: its header and its
+// commands.
+func (c *PushCertificate) Payload() []byte {
+	var b bytes.Buffer
+	version := c.Version
+	if version == "" {
+		version = PushCertVersion
+	}
+
+	fmt.Fprintf(&b, "%s%s\n", certVersion, version)
+	fmt.Fprintf(&b, "%s%s\n", certPusher, c.Pusher)
+	if c.Pushee != "" {
+		fmt.Fprintf(&b, "%s%s\n", certPushee, c.Pushee)
+	}
+	fmt.Fprintf(&b, "%s%s\n", certNonce, c.Nonce)
+	for _, opt := range c.Options {
+		fmt.Fprintf(&b, "%s%s\n", certOption, opt)
+	}
+	b.WriteString("\n")
+	for _, cmd := range c.Commands {
+		fmt.Fprintf(&b, "%s\n", formatCommand(cmd))
+	}
+
+	return b.Bytes()
+}
+
+// encode writes the certificate, one line per pkt-line, with the
+// capabilities on its first line.
+func (c *PushCertificate) encode(w io.Writer, caps string) error {
+	if _, err := pktline.Writef(w, "%s\x00%s\n", pushCert, caps); err != nil {
+		return err
+	}
+
+	content := append(c.Payload(), c.Signature...)
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	sc.Buffer(nil, pktline.MaxPayloadSize)
+	for sc.Scan() {
+		if _, err := pktline.Writef(w, "%s\n", sc.Text()); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if _, err := pktline.Writef(w, "%s\n", pushCertEnd); err != nil {
+		return err
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// decode parses the lines of a certificate, pkt-line framing and line
+// feeds removed, from its version line to its signature.
+func (c *PushCertificate) decode(lines []string) error {
+	i := 0
+	for ; i < len(lines) && lines[i] != ""; i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, certVersion):
+			c.Version = strings.TrimPrefix(line, certVersion)
+		case strings.HasPrefix(line, certPusher):
+			c.Pusher = strings.TrimPrefix(line, certPusher)
+		case strings.HasPrefix(line, certPushee):
+			c.Pushee = strings.TrimPrefix(line, certPushee)
+		case strings.HasPrefix(line, certNonce):
+			c.Nonce = strings.TrimPrefix(line, certNonce)
+		case strings.HasPrefix(line, certOption):
+			c.Options = append(c.Options, strings.TrimPrefix(line, certOption))
+		}
+	}
+
+	if c.Version != PushCertVersion {
+		return fmt.Errorf("%w: unsupported version %q", ErrMalformedPushCert, c.Version)
+	}
+	if i == len(lines) {
+		return fmt.Errorf("%w: no commands", ErrMalformedPushCert)
+	}
+
+	for i++; i < len(lines) && !strings.HasPrefix(lines[i], signatureHead); i++ {
+		cmd, err := parseCommand([]byte(lines[i]))
+		if err != nil {
+			return err
+		}
+		c.Commands = append(c.Commands, cmd)
+	}
+
+	var sig bytes.Buffer
+	for ; i < len(lines); i++ {
+		sig.WriteString(lines[i])
+		sig.WriteString("\n")
+	}
+	c.Signature = sig.Bytes()
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package packp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+)
+
+type PushCertSuite struct {
+	suite.Suite
+}
+
+func TestPushCertSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(PushCertSuite))
+}
+
+func (s *PushCertSuite) request() *UpdateRequests {
+	cmds := []*Command{{
+		Name: plumbing.ReferenceName("refs/heads/master"),
+		Old:  plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		New:  plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	}}
+
+	r := &UpdateRequests{Commands: cmds}
+	r.Capabilities.Set(capability.ReportStatus)
+	r.Certificate = &PushCertificate{
+		Pusher:    "A U Thor <author@example.com> 1700000000 +0000",
+		Pushee:    "https://example.com/repo.git",
+		Nonce:     "1700000000-abcdef",
+		Options:   []string{"ci.skip"},
+		Commands:  cmds,
+		Signature: []byte("-----BEGIN PGP SIGNATURE-----\n\nsignature\n-----END PGP SIGNATURE-----\n"),
+	}
+	return r
+}
+
+func (s *PushCertSuite) TestPayload() {
+	s.Equal("certificate version 0.1\n"+
+		"pusher A U Thor <author@example.com> 1700000000 +0000\n"+
+		"pushee https://example.com/repo.git\n"+
+		"nonce 1700000000-abcdef\n"+
+		"push-option ci.skip\n"+
+		"\n"+
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n",
+		string(s.request().Certificate.Payload()))
+}
+
+func (s *PushCertSuite) TestEncode() {
+	expected := pktlines(s.T(),
+		"push-cert\x00 report-status\n",
+		"certificate version 0.1\n",
+		"pusher A U Thor <author@example.com> 1700000000 +0000\n",
+		"pushee https://example.com/repo.git\n",
+		"nonce 1700000000-abcdef\n",
+		"push-option ci.skip\n",
+		"\n",
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n",
+		"-----BEGIN PGP SIGNATURE-----\n",
+		"\n",
+		"signature\n",
+		"-----END PGP SIGNATURE-----\n",
+		"push-cert-end\n",
+		"",
+	)
+
+	var buf bytes.Buffer
+	s.Require().NoError(s.request().Encode(&buf))
+	s.Equal(string(expected), buf.String())
+}
+
+func (s *PushCertSuite) TestDecode() {
+	expected := s.request()
+
+	var buf bytes.Buffer
+	s.Require().NoError(expected.Encode(&buf))
+
+	obtained := &UpdateRequests{}
+	s.Require().NoError(obtained.Decode(&buf))
+	s.True(obtained.Capabilities.Supports(capability.ReportStatus))
+	s.Equal(expected.Commands, obtained.Commands)
+
+	expected.Certificate.Version = PushCertVersion
+	s.Equal(expected.Certificate, obtained.Certificate)
+	s.Equal(expected.Certificate.Payload(), obtained.Certificate.Payload())
+}
+
+func (s *PushCertSuite) TestDecodeUnsupportedVersion() {
+	input := pktlines(s.T(),
+		"push-cert\x00 report-status\n",
+		"certificate version 0.2\n",
+		"\n",
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n",
+		"push-cert-end\n",
+		"",
+	)
+
+	err := (&UpdateRequests{}).Decode(bytes.NewReader(input))
+	s.ErrorIs(err, ErrMalformedPushCert)
+}
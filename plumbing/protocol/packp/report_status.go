@@ -36,7 +36,10 @@ func (e CommandStatusErr) Error() string {
 }
 
 // ReportStatus is a report status message, as used in the git-receive-pack
-// process whenever the 'report-status' capability is negotiated.
+// process whenever the 'report-status' or 'report-status-v2' capability is
+// negotiated. The report-status-v2 options of a command status are only
+// encoded when set, so a server must leave them empty for clients that
+// negotiated report-status.
 // The zero value is safe to use.
 type ReportStatus struct {
 	UnpackStatus    string
@@ -143,6 +146,10 @@ func (s *ReportStatus) decodeCommandStatus(b []byte) error {
 	b = bytes.TrimSuffix(b, eol)
 
 	line := string(b)
+	if opt, isOption := strings.CutPrefix(line, "option "); isOption {
+		return s.decodeCommandStatusOption(opt)
+	}
+
 	fields := strings.SplitN(line, " ", 3)
 	status := ok
 	if len(fields) == 3 && fields[0] == "ng" {
@@ -159,11 +166,65 @@ func (s *ReportStatus) decodeCommandStatus(b []byte) error {
 	return nil
 }
 
+// decodeCommandStatusOption decodes a report-status-v2 option line of the
+// last command status. Unknown options are ignored, as git does, so that
+// servers can report new ones.
+func (s *ReportStatus) decodeCommandStatusOption(opt string) error {
+	if len(s.CommandStatuses) == 0 {
+		return fmt.Errorf("malformed command status: option %s", opt)
+	}
+	cs := s.CommandStatuses[len(s.CommandStatuses)-1]
+	if cs.Status != ok {
+		return fmt.Errorf("malformed command status: option %s of rejected %s", opt, cs.ReferenceName)
+	}
+
+	key, value, _ := strings.Cut(opt, " ")
+	switch key {
+	case "refname":
+		cs.RefName = plumbing.ReferenceName(value)
+	case "old-oid", "new-oid":
+		h, valid := plumbing.FromHex(value)
+		if !valid {
+			return fmt.Errorf("malformed command status: invalid %s %q", key, value)
+		}
+		if key == "old-oid" {
+			cs.OldHash = &h
+		} else {
+			cs.NewHash = &h
+		}
+	case "forced-update":
+		cs.ForcedUpdate = true
+	}
+
+	return nil
+}
+
 // CommandStatus is the status of a reference in a report status.
 // See ReportStatus struct.
+//
+// A server reporting with report-status-v2 may report a command more than
+// once, with options describing the reference it updated in its place, as
+// git's proc-receive hook does for AGit-style refs/for/* pushes.
 type CommandStatus struct {
 	ReferenceName plumbing.ReferenceName
 	Status        string
+
+	// RefName is the reference updated in place of ReferenceName, if the
+	// server rewrote it.
+	RefName plumbing.ReferenceName
+	// OldHash is the old value of the updated reference, if it differs from
+	// the one of the command.
+	OldHash *plumbing.Hash
+	// NewHash is the new value of the updated reference, if it differs from
+	// the one of the command.
+	NewHash *plumbing.Hash
+	// ForcedUpdate reports a non fast-forward update.
+	ForcedUpdate bool
+}
+
+// HasOptions reports whether the status has report-status-v2 options.
+func (s *CommandStatus) HasOptions() bool {
+	return s.RefName != "" || s.OldHash != nil || s.NewHash != nil || s.ForcedUpdate
 }
 
 // Error returns the error, if any.
@@ -180,10 +241,36 @@ func (s *CommandStatus) Error() error {
 
 func (s *CommandStatus) encode(w io.Writer) error {
 	if s.Error() == nil {
-		_, err := pktline.Writef(w, "ok %s\n", s.ReferenceName.String())
-		return err
+		if _, err := pktline.Writef(w, "ok %s\n", s.ReferenceName.String()); err != nil {
+			return err
+		}
+		return s.encodeOptions(w)
 	}
 
 	_, err := pktline.Writef(w, "ng %s %s\n", s.ReferenceName.String(), s.Status)
 	return err
 }
+
+func (s *CommandStatus) encodeOptions(w io.Writer) error {
+	if s.RefName != "" {
+		if _, err := pktline.Writef(w, "option refname %s\n", s.RefName.String()); err != nil {
+			return err
+		}
+	}
+	if s.OldHash != nil {
+		if _, err := pktline.Writef(w, "option old-oid %s\n", s.OldHash.String()); err != nil {
+			return err
+		}
+	}
+	if s.NewHash != nil {
+		if _, err := pktline.Writef(w, "option new-oid %s\n", s.NewHash.String()); err != nil {
+			return err
+		}
+	}
+	if s.ForcedUpdate {
+		if _, err := pktline.Writef(w, "option forced-update\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
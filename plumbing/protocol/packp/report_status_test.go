@@ -195,6 +195,80 @@ func (s *ReportStatusSuite) TestEncodeDecodeOkNoReferencesFailed() {
 	)
 }
 
+func (s *ReportStatusSuite) TestEncodeDecodeOkOptions() {
+	oldHash := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	newHash := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	rs := &ReportStatus{}
+	rs.UnpackStatus = "ok"
+	rs.CommandStatuses = []*CommandStatus{{
+		ReferenceName: plumbing.ReferenceName("refs/for/master/topic"),
+		Status:        "ok",
+		RefName:       plumbing.ReferenceName("refs/changes/24/124/1"),
+		OldHash:       &oldHash,
+		NewHash:       &newHash,
+		ForcedUpdate:  true,
+	}, {
+		ReferenceName: plumbing.ReferenceName("refs/for/master/topic"),
+		Status:        "ok",
+		RefName:       plumbing.ReferenceName("refs/changes/25/125/1"),
+	}, {
+		ReferenceName: plumbing.ReferenceName("refs/heads/master"),
+		Status:        "ok",
+	}}
+
+	s.testEncodeDecodeOk(rs,
+		"unpack ok\n",
+		"ok refs/for/master/topic\n",
+		"option refname refs/changes/24/124/1\n",
+		"option old-oid 1ecf0ef2c2dffb796033e5a02219af86ec6584e5\n",
+		"option new-oid 2ecf0ef2c2dffb796033e5a02219af86ec6584e5\n",
+		"option forced-update\n",
+		"ok refs/for/master/topic\n",
+		"option refname refs/changes/25/125/1\n",
+		"ok refs/heads/master\n",
+		"",
+	)
+	s.True(rs.CommandStatuses[0].HasOptions())
+	s.False(rs.CommandStatuses[2].HasOptions())
+}
+
+func (s *ReportStatusSuite) TestDecodeErrorOptionWithoutStatus() {
+	s.testDecodeError("malformed command status: option refname refs/heads/master",
+		"unpack ok\n",
+		"option refname refs/heads/master\n",
+		"",
+	)
+}
+
+func (s *ReportStatusSuite) TestDecodeErrorOptionOfRejectedStatus() {
+	s.testDecodeError("malformed command status: option forced-update of rejected refs/heads/master",
+		"unpack ok\n",
+		"ng refs/heads/master non-fast-forward\n",
+		"option forced-update\n",
+		"",
+	)
+}
+
+func (s *ReportStatusSuite) TestDecodeUnknownOption() {
+	rs := &ReportStatus{}
+	rs.UnpackStatus = "ok"
+	rs.CommandStatuses = []*CommandStatus{{
+		ReferenceName: plumbing.ReferenceName("refs/for/master"),
+		Status:        "ok",
+		RefName:       plumbing.ReferenceName("refs/changes/24/124/1"),
+	}}
+
+	s.testDecodeOk(rs,
+		"unpack ok\n",
+		"ok refs/for/master\n",
+		"option foo\n",
+		"option refname refs/changes/24/124/1\n",
+		"option bar baz\n",
+		"",
+	)
+}
+
 func (s *ReportStatusSuite) TestDecodeErrorOneReferenceNoFlush() {
 	s.testDecodeError("missing flush",
 		"unpack ok\n",
@@ -0,0 +1,100 @@
+package packp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
+)
+
+// sidebandAllReader reads a response sent with the sideband-all fetch
+// feature, where every pkt-line but the flush, delim and response-end packets
+// carries a sideband channel byte. It returns the pkt-lines of the data
+// channel without it, writes the progress channel to progress, and fails on
+// the error channel.
+//
+// It reads a single pkt-line from r at a time, so once the caller stops
+// reading, r is positioned right after the last pkt-line returned.
+type sidebandAllReader struct {
+	r        io.Reader
+	progress sideband.Progress
+	pending  []byte
+}
+
+func (s *sidebandAllReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		l, pkt, err := pktline.ReadLine(s.r)
+		if err != nil {
+			return 0, err
+		}
+
+		if l < pktline.LenSize {
+			s.pending = fmt.Appendf(nil, "%04x", l)
+			break
+		}
+		if len(pkt) == 0 {
+			return 0, fmt.Errorf("invalid sideband pktline %04x", l)
+		}
+
+		switch sideband.Channel(pkt[0]) {
+		case sideband.PackData:
+			var buf bytes.Buffer
+			if _, err := pktline.Write(&buf, pkt[1:]); err != nil {
+				return 0, err
+			}
+			s.pending = buf.Bytes()
+		case sideband.ProgressMessage:
+			if s.progress != nil {
+				if _, err := s.progress.Write(pkt[1:]); err != nil {
+					return 0, err
+				}
+			}
+		case sideband.ErrorMessage:
+			return 0, fmt.Errorf("unexpected error: %s", bytes.TrimSpace(pkt[1:]))
+		default:
+			return 0, fmt.Errorf("unknown channel %s", pkt)
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// sidebandAllWriter writes the pkt-lines written to it on the sideband data
+// channel, as a response sent with the sideband-all fetch feature is. The
+// flush, delim and response-end packets are written as they are.
+type sidebandAllWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (s *sidebandAllWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) >= pktline.LenSize {
+		l, err := pktline.ParseLength(s.buf[:pktline.LenSize])
+		if err != nil {
+			return 0, err
+		}
+
+		if l < pktline.LenSize {
+			if _, err := s.w.Write(s.buf[:pktline.LenSize]); err != nil {
+				return 0, err
+			}
+			s.buf = s.buf[pktline.LenSize:]
+			continue
+		}
+		if len(s.buf) < l {
+			break
+		}
+
+		payload := sideband.PackData.WithPayload(s.buf[pktline.LenSize:l])
+		if _, err := pktline.Write(s.w, payload); err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[l:]
+	}
+	return len(p), nil
+}
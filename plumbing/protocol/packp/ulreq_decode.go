@@ -165,8 +165,11 @@ func (req *UploadRequest) Decode(r io.Reader) error {
 		if !ok || len(line) == 0 {
 			return nil
 		}
+		if bytes.HasPrefix(line, filter) {
+			break
+		}
 
-		// After deepen <n>, only flush-pkt is valid
+		// After deepen <n>, only filter or flush-pkt is valid
 		if req.Depth.Deepen > 0 {
 			if bytes.HasPrefix(line, deepenSince) || bytes.HasPrefix(line, deepenReference) {
 				return ErrDeepenMutuallyExclusive
@@ -179,7 +182,19 @@ func (req *UploadRequest) Decode(r io.Reader) error {
 		}
 	}
 
-	// Unexpected payload after shallows or wants
+	if bytes.HasPrefix(line, filter) {
+		req.Filter = Filter(bytes.TrimPrefix(line, filter))
+
+		ok, err := nextLine()
+		if err != nil {
+			return err
+		}
+		if !ok || len(line) == 0 {
+			return nil
+		}
+	}
+
+	// Unexpected payload after shallows, wants, deepen or filter
 	if len(line) != 0 {
 		return decodeError("unexpected payload while expecting a flush-pkt: %q", line)
 	}
@@ -505,6 +505,42 @@ func (s *UlReqDecodeSuite) TestDeepenReference() {
 	s.Equal(DepthRequest{DeepenNot: []string{expected}}, ur.Depth)
 }
 
+func (s *UlReqDecodeSuite) TestFilter() {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"filter blob:none",
+		"",
+	}
+	ur, _ := s.testDecodeOK(payloads, 0)
+
+	s.Equal(FilterBlobNone(), ur.Filter)
+}
+
+func (s *UlReqDecodeSuite) TestFilterAfterDeepen() {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"shallow 1111111111111111111111111111111111111111",
+		"deepen 1",
+		"filter tree:0",
+		"",
+	}
+	ur, _ := s.testDecodeOK(payloads, 0)
+
+	s.Equal(DepthRequest{Deepen: 1}, ur.Depth)
+	s.Equal(FilterTreeDepth(0), ur.Filter)
+}
+
+func (s *UlReqDecodeSuite) TestFilterExtraData() {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"filter blob:none",
+		"deepen 1",
+		"",
+	}
+	r := toPktLines(s.T(), payloads)
+	s.testDecoderErrorMatches(r, ".*unexpected payload.*")
+}
+
 func (s *UlReqDecodeSuite) TestDeepenCommitsWithSinceError() {
 	payloads := []string{
 		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
@@ -20,7 +20,9 @@ type UpdateRequests struct {
 	Capabilities capability.List
 	Commands     []*Command
 	Shallows     []plumbing.Hash
-	// TODO: Support push-cert
+	// Certificate, when set, is sent in place of the command list. Its
+	// commands are the ones of the request.
+	Certificate *PushCertificate
 }
 
 func validateUpdateRequests(req *UpdateRequests) error {
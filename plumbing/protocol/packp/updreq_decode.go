@@ -138,6 +138,15 @@ func (req *UpdateRequests) Decode(r io.Reader) error {
 		return nil
 	}
 
+	if bytes.HasPrefix(payload, append(pushCert, 0)) {
+		return req.decodePushCert(payload, func() ([]byte, error) {
+			if err := readLine(errNoFlush); err != nil {
+				return nil, err
+			}
+			return payload, nil
+		})
+	}
+
 	// The first command line must contain capabilities separated by a null byte
 	before, after, ok := bytes.Cut(payload, []byte{0})
 	if !ok {
@@ -183,6 +192,47 @@ func (req *UpdateRequests) Decode(r io.Reader) error {
 	return validateUpdateRequests(req)
 }
 
+// decodePushCert reads a push certificate sent in place of the command
+// list. first is its first line, and next returns the following ones, nil
+// for a flush.
+func (req *UpdateRequests) decodePushCert(first []byte, next func() ([]byte, error)) error {
+	caps := bytes.TrimSuffix(first[len(pushCert)+1:], eol)
+	capability.DecodeList(caps, &req.Capabilities)
+
+	var lines []string
+	for {
+		line, err := next()
+		if err != nil {
+			return err
+		}
+		if line == nil {
+			return fmt.Errorf("%w: unexpected flush", ErrMalformedPushCert)
+		}
+		l := string(bytes.TrimSuffix(line, eol))
+		if l == pushCertEnd {
+			break
+		}
+		lines = append(lines, l)
+	}
+
+	line, err := next()
+	if err != nil {
+		return err
+	}
+	if line != nil {
+		return errMalformedRequest("unexpected data after push certificate")
+	}
+
+	cert := &PushCertificate{}
+	if err := cert.decode(lines); err != nil {
+		return err
+	}
+	req.Certificate = cert
+	req.Commands = cert.Commands
+
+	return validateUpdateRequests(req)
+}
+
 func parseCommand(b []byte) (*Command, error) {
 	if len(b) < minCommandLength {
 		return nil, errInvalidCommandLineLength(len(b))
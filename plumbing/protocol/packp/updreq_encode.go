@@ -18,6 +18,10 @@ func (req *UpdateRequests) Encode(w io.Writer) error {
 		return err
 	}
 
+	if req.Certificate != nil {
+		return req.Certificate.encode(w, formatCapabilities(&req.Capabilities))
+	}
+
 	if err := req.encodeCommands(w, req.Commands, &req.Capabilities); err != nil {
 		return err
 	}
@@ -40,14 +44,8 @@ func (req *UpdateRequests) encodeShallow(w io.Writer) error {
 func (req *UpdateRequests) encodeCommands(w io.Writer,
 	cmds []*Command, caps *capability.List,
 ) error {
-	capStr := caps.String()
-	if len(capStr) > 0 {
-		// Canonical Git adds a space before the capabilities.
-		// See https://github.com/git/git/blob/57da342c786f59eaeb436c18635cc1c7597733d9/send-pack.c#L594
-		capStr = " " + capStr
-	}
 	if _, err := pktline.Writef(w, "%s\x00%s",
-		formatCommand(cmds[0]), capStr); err != nil {
+		formatCommand(cmds[0]), formatCapabilities(caps)); err != nil {
 		return err
 	}
 
@@ -60,6 +58,16 @@ func (req *UpdateRequests) encodeCommands(w io.Writer,
 	return pktline.WriteFlush(w)
 }
 
+func formatCapabilities(caps *capability.List) string {
+	capStr := caps.String()
+	if len(capStr) > 0 {
+		// Canonical Git adds a space before the capabilities.
+		// See https://github.com/git/git/blob/57da342c786f59eaeb436c18635cc1c7597733d9/send-pack.c#L594
+		capStr = " " + capStr
+	}
+	return capStr
+}
+
 func formatCommand(cmd *Command) string {
 	o := cmd.Old.String()
 	n := cmd.New.String()
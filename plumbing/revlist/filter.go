@@ -0,0 +1,502 @@
+package revlist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// ErrInvalidFilter is returned when an object filter spec cannot be parsed.
+var ErrInvalidFilter = errors.New("invalid object filter")
+
+type filterKind int
+
+const (
+	filterBlobNone filterKind = iota
+	filterBlobLimit
+	filterTreeDepth
+	filterObjectType
+	filterSparse
+	filterCombine
+)
+
+// Filter omits objects from an object walk, as the --filter option of
+// git-rev-list does for a partial clone. It is parsed from a packp.Filter
+// spec with ParseFilter.
+type Filter struct {
+	kind filterKind
+
+	limit      int64
+	depth      uint64
+	objectType plumbing.ObjectType
+	sparse     string
+	filters    []*Filter
+}
+
+// ParseFilter parses an object filter spec: blob:none, blob:limit=<n>[kmg],
+// tree:<depth>, object:type=<type>, sparse:oid=<blob-ish> or a combine: of
+// those.
+func ParseFilter(spec packp.Filter) (*Filter, error) {
+	s := string(spec)
+	switch {
+	case s == "blob:none":
+		return &Filter{kind: filterBlobNone}, nil
+	case strings.HasPrefix(s, "blob:limit="):
+		limit, err := parseFilterSize(strings.TrimPrefix(s, "blob:limit="))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidFilter, s, err)
+		}
+		return &Filter{kind: filterBlobLimit, limit: limit}, nil
+	case strings.HasPrefix(s, "tree:"):
+		depth, err := strconv.ParseUint(strings.TrimPrefix(s, "tree:"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidFilter, s, err)
+		}
+		return &Filter{kind: filterTreeDepth, depth: depth}, nil
+	case strings.HasPrefix(s, "object:type="):
+		t, err := plumbing.ParseObjectType(strings.TrimPrefix(s, "object:type="))
+		if err != nil || t == plumbing.OFSDeltaObject || t == plumbing.REFDeltaObject {
+			return nil, fmt.Errorf("%w %q: unknown object type", ErrInvalidFilter, s)
+		}
+		return &Filter{kind: filterObjectType, objectType: t}, nil
+	case strings.HasPrefix(s, "sparse:oid="):
+		oid := strings.TrimPrefix(s, "sparse:oid=")
+		if oid == "" {
+			return nil, fmt.Errorf("%w %q: missing object", ErrInvalidFilter, s)
+		}
+		return &Filter{kind: filterSparse, sparse: oid}, nil
+	case strings.HasPrefix(s, "combine:"):
+		parts := strings.Split(strings.TrimPrefix(s, "combine:"), "+")
+		f := &Filter{kind: filterCombine}
+		for _, p := range parts {
+			sub, err := url.QueryUnescape(p)
+			if err != nil || sub == "" {
+				return nil, fmt.Errorf("%w %q: bad combine part %q", ErrInvalidFilter, s, p)
+			}
+			parsed, err := ParseFilter(packp.Filter(sub))
+			if err != nil {
+				return nil, err
+			}
+			f.filters = append(f.filters, parsed)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidFilter, s)
+	}
+}
+
+// parseFilterSize parses a size with an optional k, m or g unit suffix, as
+// git_parse_ulong does.
+func parseFilterSize(s string) (int64, error) {
+	var unit int64 = 1
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			unit = 1 << 10
+		case 'm', 'M':
+			unit = 1 << 20
+		case 'g', 'G':
+			unit = 1 << 30
+		}
+		if unit != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("negative size")
+	}
+	return n * unit, nil
+}
+
+// String returns the spec of f.
+func (f *Filter) String() string {
+	switch f.kind {
+	case filterBlobNone:
+		return "blob:none"
+	case filterBlobLimit:
+		return fmt.Sprintf("blob:limit=%d", f.limit)
+	case filterTreeDepth:
+		return fmt.Sprintf("tree:%d", f.depth)
+	case filterObjectType:
+		return "object:type=" + f.objectType.String()
+	case filterSparse:
+		return "sparse:oid=" + f.sparse
+	default:
+		parts := make([]string, 0, len(f.filters))
+		for _, sub := range f.filters {
+			parts = append(parts, url.QueryEscape(sub.String()))
+		}
+		return "combine:" + strings.Join(parts, "+")
+	}
+}
+
+// FilteredObjects computes, as Objects does, the object hashes reachable
+// from wants while excluding commits reachable from haves, and omits those
+// that filter rejects. The filter is applied while walking the trees, so
+// that those it drops, and the objects below them, are not read. The
+// objects named by wants are always included, as git does unless
+// --filter-provided-objects is given. A nil filter omits nothing.
+func FilteredObjects(
+	s storer.EncodedObjectStorer,
+	wants,
+	haves []plumbing.Hash,
+	filter *Filter,
+) ([]plumbing.Hash, error) {
+	if filter == nil {
+		return Objects(s, wants, haves)
+	}
+
+	ow, err := newObjectWalk(s)
+	if err != nil {
+		return nil, err
+	}
+	ow.commitsOnly = true
+	if err := ow.seedHaves(haves); err != nil {
+		return nil, err
+	}
+	if err := ow.seedWants(wants); err != nil {
+		return nil, err
+	}
+	if err := ow.walk(); err != nil {
+		return nil, err
+	}
+
+	w, err := newFilterWalk(s, ow.result, ow.seen, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range wants {
+		if err := w.walkWant(h); err != nil {
+			return nil, err
+		}
+	}
+	return w.result, nil
+}
+
+// filterWalk walks the commits an Objects walk selected down to the blobs,
+// keeping the path and depth of each tree entry the filter needs.
+type filterWalk struct {
+	s      storer.EncodedObjectStorer
+	filter *Filter
+	// commits are those the walk may include; excluded holds the trees
+	// and blobs reachable from the haves.
+	commits  map[plumbing.Hash]struct{}
+	excluded map[plumbing.Hash]struct{}
+	included map[plumbing.Hash]struct{}
+	result   []plumbing.Hash
+	walked   map[plumbing.Hash]struct{}
+	// trees records the smallest depth each tree was walked at, and
+	// whether it was walked at that depth with each path; see visit.
+	trees    map[plumbing.Hash]uint64
+	paths    map[string]struct{}
+	matchers map[*Filter]gitignore.Matcher
+}
+
+func newFilterWalk(s storer.EncodedObjectStorer, commits []plumbing.Hash, excluded map[plumbing.Hash]struct{}, filter *Filter) (*filterWalk, error) {
+	w := &filterWalk{
+		s:        s,
+		filter:   filter,
+		commits:  make(map[plumbing.Hash]struct{}, len(commits)),
+		excluded: excluded,
+		included: make(map[plumbing.Hash]struct{}),
+		walked:   make(map[plumbing.Hash]struct{}),
+		trees:    make(map[plumbing.Hash]uint64),
+		paths:    make(map[string]struct{}),
+		matchers: make(map[*Filter]gitignore.Matcher),
+	}
+	for _, h := range commits {
+		w.commits[h] = struct{}{}
+	}
+	if err := w.loadSparse(filter); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// include adds h to the result, once.
+func (w *filterWalk) include(h plumbing.Hash) {
+	if _, ok := w.included[h]; ok {
+		return
+	}
+	w.included[h] = struct{}{}
+	w.result = append(w.result, h)
+}
+
+// loadSparse reads the patterns of the sparse filters of f.
+func (w *filterWalk) loadSparse(f *Filter) error {
+	switch f.kind {
+	case filterSparse:
+		m, err := readSparsePatterns(w.s, f.sparse)
+		if err != nil {
+			return err
+		}
+		w.matchers[f] = m
+	case filterCombine:
+		for _, sub := range f.filters {
+			if err := w.loadSparse(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readSparsePatterns reads the sparse-checkout patterns of the blob oid,
+// given as a hash or, if s holds references, as <rev>:<path>.
+func readSparsePatterns(s storer.EncodedObjectStorer, oid string) (gitignore.Matcher, error) {
+	h, err := resolveBlobish(s, oid)
+	if err != nil {
+		return nil, fmt.Errorf("sparse:oid=%s: %w", oid, err)
+	}
+	blob, err := object.GetBlob(s, h)
+	if err != nil {
+		return nil, fmt.Errorf("sparse:oid=%s: %w", oid, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var ps []gitignore.Pattern
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := string(bytes.TrimSuffix(sc.Bytes(), []byte("\r")))
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ps = append(ps, gitignore.ParsePattern(line, nil))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return gitignore.NewMatcher(ps), nil
+}
+
+func resolveBlobish(s storer.EncodedObjectStorer, oid string) (plumbing.Hash, error) {
+	if h, ok := plumbing.FromHex(oid); ok {
+		return h, nil
+	}
+
+	rev, path, ok := strings.Cut(oid, ":")
+	rs, isRefs := s.(storer.ReferenceStorer)
+	if !ok || !isRefs {
+		return plumbing.ZeroHash, plumbing.ErrObjectNotFound
+	}
+
+	var tip plumbing.Hash
+	if h, ok := plumbing.FromHex(rev); ok {
+		tip = h
+	} else {
+		for _, name := range []string{rev, "refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev} {
+			ref, err := storer.ResolveReference(rs, plumbing.ReferenceName(name))
+			if err == nil {
+				tip = ref.Hash()
+				break
+			}
+		}
+		if tip.IsZero() {
+			return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+		}
+	}
+
+	c, err := object.GetCommit(s, tip)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	t, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	e, err := t.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return e.Hash, nil
+}
+
+// walkWant includes want, and walks the commits, trees and blobs it
+// reaches.
+func (w *filterWalk) walkWant(want plumbing.Hash) error {
+	h := want
+	for {
+		o, err := w.s.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return fmt.Errorf("getting wanted object %s: %w", h, err)
+		}
+		w.include(h)
+
+		switch o.Type() {
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(w.s, o)
+			if err != nil {
+				return fmt.Errorf("decoding tag %s: %w", h, err)
+			}
+			h = tag.Target
+			continue
+		case plumbing.CommitObject:
+			return w.walkCommits(h)
+		case plumbing.TreeObject:
+			return w.walkTree(h, 0, nil)
+		}
+		return nil
+	}
+}
+
+// walkCommits walks the commits from tip that the object walk selected.
+// The others are reachable from the haves, as their ancestors are.
+func (w *filterWalk) walkCommits(tip plumbing.Hash) error {
+	queue := []plumbing.Hash{tip}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+
+		if _, ok := w.walked[h]; ok {
+			continue
+		}
+		if _, ok := w.commits[h]; !ok {
+			continue
+		}
+		w.walked[h] = struct{}{}
+
+		c, err := object.GetCommit(w.s, h)
+		if err != nil {
+			return fmt.Errorf("getting commit %s: %w", h, err)
+		}
+		if w.filter.include(w, plumbing.CommitObject, h, 0, nil) {
+			w.include(h)
+		}
+		if err := w.walkTree(c.TreeHash, 0, nil); err != nil {
+			return err
+		}
+		queue = append(queue, c.ParentHashes...)
+	}
+	return nil
+}
+
+// walkTree walks the tree h found at path, depth levels below a root tree.
+func (w *filterWalk) walkTree(h plumbing.Hash, depth uint64, path []string) error {
+	if _, ok := w.excluded[h]; ok {
+		return nil
+	}
+	if !w.visit(h, depth, path) {
+		return nil
+	}
+
+	if w.filter.include(w, plumbing.TreeObject, h, depth, path) {
+		w.include(h)
+	}
+	if !w.filter.descend(depth) {
+		return nil
+	}
+
+	t, err := object.GetTree(w.s, h)
+	if err != nil {
+		return fmt.Errorf("getting tree %s: %w", h, err)
+	}
+	for _, e := range t.Entries {
+		if e.Mode == filemode.Submodule {
+			continue
+		}
+		entryPath := append(path[:len(path):len(path)], e.Name)
+		if e.Mode == filemode.Dir {
+			if err := w.walkTree(e.Hash, depth+1, entryPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := w.excluded[e.Hash]; ok {
+			continue
+		}
+		if w.filter.include(w, plumbing.BlobObject, e.Hash, depth+1, entryPath) {
+			w.include(e.Hash)
+		}
+	}
+	return nil
+}
+
+// visit reports whether the tree h at depth and path must be walked. A tree
+// is walked again when it is found closer to the root, as the depth of its
+// entries then changes, and when a sparse filter is found at another path,
+// as the path of its entries then does.
+func (w *filterWalk) visit(h plumbing.Hash, depth uint64, path []string) bool {
+	if len(w.matchers) > 0 {
+		key := h.String() + "\x00" + strings.Join(path, "/")
+		if _, ok := w.paths[key]; ok {
+			return false
+		}
+		w.paths[key] = struct{}{}
+		return true
+	}
+
+	if seen, ok := w.trees[h]; ok && seen <= depth {
+		return false
+	}
+	w.trees[h] = depth
+	return true
+}
+
+// include reports whether f keeps the object h of type t, found at path
+// depth levels below a root tree.
+func (f *Filter) include(w *filterWalk, t plumbing.ObjectType, h plumbing.Hash, depth uint64, path []string) bool {
+	switch f.kind {
+	case filterBlobNone:
+		return t != plumbing.BlobObject
+	case filterBlobLimit:
+		if t != plumbing.BlobObject {
+			return true
+		}
+		size, err := w.s.EncodedObjectSize(h)
+		return err != nil || size < f.limit
+	case filterTreeDepth:
+		if t == plumbing.CommitObject || t == plumbing.TagObject {
+			return true
+		}
+		return depth < f.depth
+	case filterObjectType:
+		return t == f.objectType
+	case filterSparse:
+		if t != plumbing.BlobObject {
+			return true
+		}
+		return w.matchers[f].Match(path, false)
+	default:
+		for _, sub := range f.filters {
+			if !sub.include(w, t, h, depth, path) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// descend reports whether the entries of a tree depth levels below a root
+// tree may be kept by f.
+func (f *Filter) descend(depth uint64) bool {
+	switch f.kind {
+	case filterTreeDepth:
+		return depth+1 < f.depth
+	case filterCombine:
+		for _, sub := range f.filters {
+			if !sub.descend(depth) {
+				return false
+			}
+		}
+	}
+	return true
+}
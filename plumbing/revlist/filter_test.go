@@ -0,0 +1,211 @@
+package revlist
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	for spec, want := range map[packp.Filter]string{
+		"blob:none":               "blob:none",
+		"blob:limit=0":            "blob:limit=0",
+		"blob:limit=2k":           "blob:limit=2048",
+		"blob:limit=1m":           "blob:limit=1048576",
+		"tree:0":                  "tree:0",
+		"tree:3":                  "tree:3",
+		"object:type=commit":      "object:type=commit",
+		"sparse:oid=main:.sparse": "sparse:oid=main:.sparse",
+		packp.FilterCombine(packp.FilterBlobNone(), packp.FilterTreeDepth(2)): "combine:blob%3Anone+tree%3A2",
+	} {
+		f, err := ParseFilter(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, want, f.String(), spec)
+	}
+
+	for _, spec := range []packp.Filter{
+		"", "blob:some", "blob:limit=", "blob:limit=-1", "tree:x",
+		"object:type=ofs-delta", "object:type=other", "sparse:oid=",
+		"sparse:path=x", "combine:", "combine:blob:none+%zz",
+	} {
+		_, err := ParseFilter(spec)
+		require.ErrorIs(t, err, ErrInvalidFilter, spec)
+	}
+}
+
+func TestFilteredObjectsMatchesGit(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git CLI not found in PATH")
+	}
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	sto := filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	defer func() { _ = sto.Close() }()
+
+	patterns := &plumbing.MemoryObject{}
+	patterns.SetType(plumbing.BlobObject)
+	_, err = patterns.Write([]byte("# sparse\n/go/\n*.json\n"))
+	require.NoError(t, err)
+	sparse, err := sto.SetEncodedObject(patterns)
+	require.NoError(t, err)
+
+	want := plumbing.NewHash(someCommitOtherBranch)
+	have := plumbing.NewHash(initialCommit)
+	blob := plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa")
+
+	for _, spec := range []packp.Filter{
+		"blob:none",
+		"blob:limit=1k",
+		"blob:limit=0",
+		"tree:0",
+		"tree:1",
+		"tree:2",
+		"object:type=commit",
+		"object:type=tree",
+		"object:type=blob",
+		packp.Filter("sparse:oid=" + sparse.String()),
+		packp.FilterCombine(packp.FilterBlobLimit(2, packp.BlobLimitPrefixKibi), packp.FilterTreeDepth(2)),
+	} {
+		f, err := ParseFilter(spec)
+		require.NoError(t, err, spec)
+
+		for _, tc := range []struct {
+			wants, haves []plumbing.Hash
+		}{
+			{wants: []plumbing.Hash{want}},
+			{wants: []plumbing.Hash{want}, haves: []plumbing.Hash{have}},
+			{wants: []plumbing.Hash{want, blob}},
+		} {
+			got, err := FilteredObjects(sto, tc.wants, tc.haves, f)
+			require.NoError(t, err, spec)
+
+			gotSet := make(map[plumbing.Hash]bool, len(got))
+			for _, h := range got {
+				gotSet[h] = true
+			}
+			assert.Len(t, got, len(gotSet), "%s: duplicate objects", spec)
+			assert.Equal(t, gitRevListFiltered(t, dotgit.Root(), spec, tc.wants, tc.haves), gotSet,
+				"%s: wants %v haves %v", spec, tc.wants, tc.haves)
+		}
+	}
+}
+
+func TestFilteredObjectsNilFilter(t *testing.T) {
+	t.Parallel()
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	sto := filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	defer func() { _ = sto.Close() }()
+
+	wants := []plumbing.Hash{plumbing.NewHash(someCommit)}
+	all, err := Objects(sto, wants, nil)
+	require.NoError(t, err)
+	got, err := FilteredObjects(sto, wants, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, all, got)
+}
+
+func TestFilteredObjectsSparseRevPath(t *testing.T) {
+	t.Parallel()
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	sto := filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	defer func() { _ = sto.Close() }()
+
+	// The .gitignore of the fixture holds "*.class", which no file matches.
+	f, err := ParseFilter("sparse:oid=master:.gitignore")
+	require.NoError(t, err)
+	got, err := FilteredObjects(sto, []plumbing.Hash{plumbing.NewHash(someCommit)}, nil, f)
+	require.NoError(t, err)
+
+	for _, h := range got {
+		o, err := sto.EncodedObject(plumbing.AnyObject, h)
+		require.NoError(t, err)
+		assert.NotEqual(t, plumbing.BlobObject, o.Type(), "blob %s is not in the sparse patterns", h)
+	}
+
+	f, err = ParseFilter("sparse:oid=nonexistent:.gitignore")
+	require.NoError(t, err)
+	_, err = FilteredObjects(sto, []plumbing.Hash{plumbing.NewHash(someCommit)}, nil, f)
+	require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+}
+
+// readCountingStorer counts the objects read from it by type.
+type readCountingStorer struct {
+	storer.EncodedObjectStorer
+	reads map[plumbing.ObjectType]int
+}
+
+func (s *readCountingStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	o, err := s.EncodedObjectStorer.EncodedObject(t, h)
+	if err == nil {
+		s.reads[o.Type()]++
+	}
+	return o, err
+}
+
+func TestFilteredObjectsSkipsReads(t *testing.T) {
+	t.Parallel()
+
+	dotgit, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(t.TempDir))
+	require.NoError(t, err)
+	fs := filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	defer func() { _ = fs.Close() }()
+
+	// The objects a filter drops are not read, nor are those below them.
+	for spec, skipped := range map[packp.Filter]plumbing.ObjectType{
+		"tree:0":    plumbing.TreeObject,
+		"blob:none": plumbing.BlobObject,
+	} {
+		f, err := ParseFilter(spec)
+		require.NoError(t, err)
+
+		sto := &readCountingStorer{EncodedObjectStorer: fs, reads: make(map[plumbing.ObjectType]int)}
+		got, err := FilteredObjects(sto, []plumbing.Hash{plumbing.NewHash(someCommit)}, nil, f)
+		require.NoError(t, err, spec)
+		assert.NotEmpty(t, got, spec)
+		assert.NotZero(t, sto.reads[plumbing.CommitObject], spec)
+		assert.Zero(t, sto.reads[skipped], spec)
+	}
+}
+
+// gitRevListFiltered runs git rev-list --objects --filter against gitDir and
+// returns the set of object hashes.
+func gitRevListFiltered(t *testing.T, gitDir string, filter packp.Filter, wants, haves []plumbing.Hash) map[plumbing.Hash]bool {
+	t.Helper()
+
+	args := []string{"--git-dir", gitDir, "rev-list", "--objects", "--filter=" + string(filter)}
+	for _, h := range wants {
+		args = append(args, h.String())
+	}
+	for _, h := range haves {
+		args = append(args, "^"+h.String())
+	}
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(t, err, "git rev-list: %s", out)
+
+	set := make(map[plumbing.Hash]bool)
+	for line := range strings.SplitSeq(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			set[plumbing.NewHash(fields[0])] = true
+		}
+	}
+	return set
+}
@@ -21,6 +21,10 @@ type objectWalk struct {
 	havesSeen  map[plumbing.Hash]struct{}
 	seen       map[plumbing.Hash]struct{}
 	result     []plumbing.Hash
+	// commitsOnly leaves the trees and blobs of the commits out of the
+	// result, marking as seen instead those reachable from the parents the
+	// haves reach, for FilteredObjects to walk the trees itself.
+	commitsOnly bool
 }
 
 func newObjectWalk(s storer.EncodedObjectStorer) (*objectWalk, error) {
@@ -91,6 +95,9 @@ func (w *objectWalk) seedWants(wants []plumbing.Hash) error {
 			w.result = append(w.result, tag.Hash)
 			wants = append(wants, tag.Target)
 		case plumbing.TreeObject:
+			if w.commitsOnly {
+				break
+			}
 			t, err := object.GetTree(w.s, h)
 			if err != nil {
 				return fmt.Errorf("getting tree %s: %w", h, err)
@@ -99,6 +106,9 @@ func (w *objectWalk) seedWants(wants []plumbing.Hash) error {
 				return err
 			}
 		case plumbing.BlobObject:
+			if w.commitsOnly {
+				break
+			}
 			w.seen[h] = struct{}{}
 			w.result = append(w.result, h)
 		default:
@@ -241,6 +251,12 @@ func (w *objectWalk) walk() error {
 		if flags[lc.Hash]&havePaint != 0 {
 			continue
 		}
+		if w.commitsOnly {
+			if err := w.processCommitBoundary(lc, flags); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := w.processCommitTrees(lc); err != nil {
 			return err
 		}
@@ -311,13 +327,15 @@ func (w *objectWalk) walkFull() error {
 		w.seen[lc.Hash] = struct{}{}
 		w.result = append(w.result, lc.Hash)
 
-		tree, err := lc.Tree()
-		if err != nil {
-			return fmt.Errorf("getting tree for %s: %w", lc.Hash, err)
-		}
+		if !w.commitsOnly {
+			tree, err := lc.Tree()
+			if err != nil {
+				return fmt.Errorf("getting tree for %s: %w", lc.Hash, err)
+			}
 
-		if err := collectAllTreeObjects(w.s, tree, w.seen, &w.result); err != nil {
-			return fmt.Errorf("collecting tree objects for %s: %w", lc.Hash, err)
+			if err := collectAllTreeObjects(w.s, tree, w.seen, &w.result); err != nil {
+				return fmt.Errorf("collecting tree objects for %s: %w", lc.Hash, err)
+			}
 		}
 
 		if _, ok := w.shallows[lc.Hash]; ok {
@@ -375,6 +393,33 @@ func (w *objectWalk) processCommitTrees(lc *object.Commit) error {
 	return nil
 }
 
+// processCommitBoundary adds a commit to the result without its trees, and
+// marks as seen the trees and blobs of its parents painted by haves, as the
+// client has them.
+func (w *objectWalk) processCommitBoundary(lc *object.Commit, flags map[plumbing.Hash]uint8) error {
+	if _, ok := w.seen[lc.Hash]; !ok {
+		w.seen[lc.Hash] = struct{}{}
+		w.result = append(w.result, lc.Hash)
+	}
+
+	for _, ph := range lc.ParentHashes {
+		if flags[ph]&havePaint == 0 {
+			continue
+		}
+		parent, err := object.GetCommit(w.s, ph)
+		if err != nil {
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				continue // parent may be beyond haves boundary
+			}
+			return fmt.Errorf("getting parent commit %s: %w", ph, err)
+		}
+		if t, err := parent.Tree(); err == nil {
+			markTreeSeen(w.s, t, w.seen)
+		}
+	}
+	return nil
+}
+
 // insertSorted inserts a commit into a slice sorted by committer time
 // descending (newest first).
 func insertSorted(q *[]*object.Commit, c *object.Commit) {
@@ -148,3 +148,14 @@ func (w *mockWriter) Write(p []byte) (int, error) {
 	w.data = append(w.data, p...)
 	return len(p), nil
 }
+
+func TestBuildUpdateRequestsWithReportStatusV2(t *testing.T) {
+	t.Parallel()
+	caps := capability.List{}
+	caps.Add(capability.ReportStatus)
+	caps.Add(capability.ReportStatusV2)
+
+	upreq := buildUpdateRequests(caps, &PushRequest{})
+	assert.True(t, upreq.Capabilities.Supports(capability.ReportStatusV2))
+	assert.False(t, upreq.Capabilities.Supports(capability.ReportStatus))
+}
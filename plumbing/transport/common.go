@@ -49,6 +49,15 @@ func NewRemoteError(reason string) error {
 // exact same request.
 type FetchRequest = internal.FetchRequest
 
+// HTTPClient sends the HTTP requests of a fetch made outside of the
+// transport, such as those downloading packfile URIs. It is an alias of the
+// shared internal type.
+type HTTPClient = internal.HTTPClient
+
+// Negotiator chooses the commits a fetch tells the server it has. It is an
+// alias of the shared internal type.
+type Negotiator = internal.Negotiator
+
 // PushRequest contains the parameters for a push request.
 type PushRequest struct {
 	// Packfile is the packfile reader.
@@ -75,4 +84,23 @@ type PushRequest struct {
 	// Quiet indicates whether the server should suppress human-readable
 	// output.
 	Quiet bool
+
+	// Signer, when set, signs a push certificate recording the commands,
+	// sent in their place as git push --signed does. The server must
+	// advertise push-cert.
+	Signer Signer
+
+	// Pusher identifies the signer in the push certificate, along with the
+	// time of the push, as in "A U Thor <author@example.com> 1700000000 +0000".
+	Pusher string
+
+	// Pushee is the URL of the remote, without credentials, recorded in the
+	// push certificate.
+	Pushee string
+
+	// Report, when set, is filled with the report-status of the server,
+	// holding the outcome of each command. With report-status-v2 it also
+	// describes the references the server updated in place of the ones of
+	// the commands.
+	Report *packp.ReportStatus
 }
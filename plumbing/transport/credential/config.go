@@ -0,0 +1,159 @@
+package credential
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v6/internal/pathutil"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+const (
+	credentialSection = "credential"
+	helperKey         = "helper"
+	usernameKey       = "username"
+	useHTTPPathKey    = "useHttpPath"
+)
+
+// NewManagerFromConfig returns a Manager for u built from the credential
+// section of cfgs, which must be given from lowest to highest precedence
+// (system, global, local).
+//
+// Both credential.* and credential.<url>.* options are honored, the latter
+// only when <url> matches u. credential.helper is multi-valued: every
+// value adds a helper, and an empty value clears the helpers configured
+// before it. The "store" helper is served in-process by Store; any other
+// helper is run with ExecHelper.
+func NewManagerFromConfig(u *url.URL, cfgs ...*format.Config) *Manager {
+	m := &Manager{}
+	for _, cfg := range cfgs {
+		if cfg == nil || !cfg.HasSection(credentialSection) {
+			continue
+		}
+
+		s := cfg.Section(credentialSection)
+		m.apply(s.Options)
+		for _, ss := range s.Subsections {
+			if matchURL(ss.Name, u) {
+				m.apply(ss.Options)
+			}
+		}
+	}
+
+	return m
+}
+
+func (m *Manager) apply(opts format.Options) {
+	for _, o := range opts {
+		switch {
+		case o.IsKey(helperKey):
+			if o.Value == "" {
+				m.Helpers = nil
+				continue
+			}
+			m.Helpers = append(m.Helpers, newHelper(o.Value))
+		case o.IsKey(usernameKey):
+			m.Username = o.Value
+		case o.IsKey(useHTTPPathKey):
+			m.UseHTTPPath = isTrue(o.Value)
+		}
+	}
+}
+
+func newHelper(value string) Helper {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || fields[0] != "store" {
+		return NewExecHelper(value)
+	}
+
+	var files []string
+	for i := 1; i < len(fields); i++ {
+		var file string
+		switch {
+		case strings.HasPrefix(fields[i], "--file="):
+			file = strings.TrimPrefix(fields[i], "--file=")
+		case fields[i] == "--file" && i+1 < len(fields):
+			i++
+			file = fields[i]
+		default:
+			continue
+		}
+		file, _ = pathutil.ReplaceTildeWithHome(file)
+		files = append(files, file)
+	}
+
+	return NewStore(files...)
+}
+
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "", "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// matchURL reports whether the credential.<url> pattern matches u, using
+// the rules of Git's urlmatch: the scheme and host must match (a "*" host
+// component matches any single component), the port must match if the
+// pattern has one, the pattern path must be a prefix of the URL path on a
+// component boundary, and the user must match if the pattern has one. A
+// pattern without a scheme is matched against the host only.
+func matchURL(pattern string, u *url.URL) bool {
+	if !strings.Contains(pattern, "://") {
+		return matchHost(pattern, u.Hostname())
+	}
+
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+
+	if !strings.EqualFold(p.Scheme, u.Scheme) {
+		return false
+	}
+	if !matchHost(p.Hostname(), u.Hostname()) {
+		return false
+	}
+	if p.Port() != "" && p.Port() != urlPort(u) {
+		return false
+	}
+	if p.User != nil && (u.User == nil || p.User.Username() != u.User.Username()) {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(p.Path, "/")
+	if prefix == "" {
+		return true
+	}
+	target := path.Clean("/" + u.Path)
+	return target == prefix || strings.HasPrefix(target, prefix+"/")
+}
+
+func matchHost(pattern, host string) bool {
+	pp := strings.Split(strings.ToLower(pattern), ".")
+	hp := strings.Split(strings.ToLower(host), ".")
+	if len(pp) != len(hp) {
+		return false
+	}
+	for i := range pp {
+		if ok, err := path.Match(pp[i], hp[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch u.Scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package credential
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+func decodeConfig(t *testing.T, s string) *format.Config {
+	t.Helper()
+
+	cfg := format.New()
+	require.NoError(t, format.NewDecoder(strings.NewReader(s)).Decode(cfg))
+	return cfg
+}
+
+func TestNewManagerFromConfig(t *testing.T) {
+	t.Parallel()
+
+	global := decodeConfig(t, `
+[credential]
+	helper = cache
+	username = global
+[credential "https://example.com"]
+	helper = store --file=/tmp/creds
+`)
+	local := decodeConfig(t, `
+[credential "https://other.com"]
+	helper = other
+[credential "https://*.example.com/org"]
+	useHttpPath = true
+	username = local
+`)
+
+	u, err := url.Parse("https://example.com/org/repo.git")
+	require.NoError(t, err)
+
+	m := NewManagerFromConfig(u, nil, global, local)
+	require.Len(t, m.Helpers, 2)
+	assert.Equal(t, NewExecHelper("cache"), m.Helpers[0])
+	assert.Equal(t, NewStore("/tmp/creds"), m.Helpers[1])
+	assert.Equal(t, "global", m.Username)
+	assert.False(t, m.UseHTTPPath)
+
+	u, err = url.Parse("https://git.example.com/org/repo.git")
+	require.NoError(t, err)
+
+	m = NewManagerFromConfig(u, global, local)
+	require.Len(t, m.Helpers, 1)
+	assert.Equal(t, "local", m.Username)
+	assert.True(t, m.UseHTTPPath)
+}
+
+func TestNewManagerFromConfigReset(t *testing.T) {
+	t.Parallel()
+
+	global := decodeConfig(t, `
+[credential]
+	helper = cache
+	helper = osxkeychain
+`)
+	local := decodeConfig(t, `
+[credential]
+	helper =
+	helper = store
+`)
+
+	u, err := url.Parse("https://example.com/repo.git")
+	require.NoError(t, err)
+
+	m := NewManagerFromConfig(u, global, local)
+	require.Len(t, m.Helpers, 1)
+	assert.IsType(t, &Store{}, m.Helpers[0])
+}
+
+func TestMatchURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern, url string
+		want         bool
+	}{
+		{"https://example.com", "https://example.com/repo.git", true},
+		{"https://example.com", "http://example.com/repo.git", false},
+		{"https://example.com:443", "https://example.com/repo.git", true},
+		{"https://example.com:8443", "https://example.com/repo.git", false},
+		{"https://*.example.com", "https://git.example.com/repo.git", true},
+		{"https://*.example.com", "https://example.com/repo.git", false},
+		{"https://example.com/org", "https://example.com/org/repo.git", true},
+		{"https://example.com/org/", "https://example.com/org/repo.git", true},
+		{"https://example.com/org", "https://example.com/organization/repo.git", false},
+		{"https://user@example.com", "https://user@example.com/repo.git", true},
+		{"https://user@example.com", "https://example.com/repo.git", false},
+		{"example.com", "https://example.com/repo.git", true},
+		{"example.com", "https://other.com/repo.git", false},
+	}
+	for _, tc := range tests {
+		u, err := url.Parse(tc.url)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, matchURL(tc.pattern, u), "%s ~ %s", tc.pattern, tc.url)
+	}
+}
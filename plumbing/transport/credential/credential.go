@@ -0,0 +1,333 @@
+// Package credential implements Git's credential helper protocol.
+//
+// A Credential is the set of attributes exchanged with credential helpers
+// (see gitcredentials(7)). Helpers are consulted through the Helper
+// interface: ExecHelper spawns external programs configured through
+// credential.helper, and Store is an in-process implementation of
+// git-credential-store that reads and writes the same files.
+//
+// A Manager combines a list of helpers the way Git's credential_fill,
+// credential_approve and credential_reject do, and can be built from Git
+// configuration with NewManagerFromConfig.
+package credential
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Credential errors.
+var (
+	// ErrNotFound is returned by Manager.Fill when no helper provided a
+	// username and password for the request.
+	ErrNotFound = errors.New("credential: no credentials found")
+	// ErrInvalidValue is returned when encoding an attribute whose value
+	// contains a newline or NUL byte, which the protocol cannot represent.
+	ErrInvalidValue = errors.New("credential: invalid attribute value")
+)
+
+// Credential holds the attributes of the credential protocol.
+type Credential struct {
+	// Protocol is the protocol over which the credential is used, for
+	// example "https".
+	Protocol string
+	// Host is the remote hostname, including the port if one was given.
+	Host string
+	// Path is the path of the repository. It is only set for HTTP URLs when
+	// credential.useHttpPath is enabled.
+	Path string
+	// Username is the credential's username.
+	Username string
+	// Password is the credential's password.
+	Password string
+	// PasswordExpiryUTC is the password expiry as a Unix timestamp, as
+	// reported by helpers that generate short-lived credentials.
+	PasswordExpiryUTC string
+	// OAuthRefreshToken is an OAuth refresh token stored alongside the
+	// password by helpers that support it.
+	OAuthRefreshToken string
+	// WWWAuth holds the WWW-Authenticate headers of the server response
+	// that triggered the credential request.
+	WWWAuth []string
+	// Quit is set by a helper to stop Git from consulting further helpers.
+	Quit bool
+}
+
+// FromURL returns the credential describing u. The path is kept only if
+// useHTTPPath is true, matching Git's credential.useHttpPath.
+func FromURL(u *url.URL, useHTTPPath bool) *Credential {
+	c := &Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+	}
+	if useHTTPPath {
+		c.Path = strings.TrimPrefix(u.Path, "/")
+	}
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+	return c
+}
+
+// URL returns the credential as a URL, including its username and password.
+func (c *Credential) URL() *url.URL {
+	u := &url.URL{
+		Scheme: c.Protocol,
+		Host:   c.Host,
+	}
+	if c.Path != "" {
+		u.Path = "/" + c.Path
+	}
+	switch {
+	case c.Password != "":
+		u.User = url.UserPassword(c.Username, c.Password)
+	case c.Username != "":
+		u.User = url.User(c.Username)
+	}
+	return u
+}
+
+// Complete reports whether the credential has both a username and a
+// password.
+func (c *Credential) Complete() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// Match reports whether have matches the attributes set in c, following
+// Git's credential_match. Empty attributes in c match anything. The
+// password is only compared when matchPassword is true.
+func (c *Credential) Match(have *Credential, matchPassword bool) bool {
+	check := func(want, have string) bool {
+		return want == "" || want == have
+	}
+	return check(c.Protocol, have.Protocol) &&
+		check(c.Host, have.Host) &&
+		check(c.Path, have.Path) &&
+		check(c.Username, have.Username) &&
+		(!matchPassword || check(c.Password, have.Password))
+}
+
+// Encode writes the credential to w in the key=value format of the
+// credential helper protocol. Empty attributes are omitted.
+func (c *Credential) Encode(w io.Writer) error {
+	var b strings.Builder
+	write := func(key, value string) error {
+		if value == "" {
+			return nil
+		}
+		if strings.ContainsAny(value, "\n\x00") {
+			return fmt.Errorf("%w: %s", ErrInvalidValue, key)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return nil
+	}
+
+	for _, kv := range [][2]string{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+		{"password_expiry_utc", c.PasswordExpiryUTC},
+		{"oauth_refresh_token", c.OAuthRefreshToken},
+	} {
+		if err := write(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	for _, v := range c.WWWAuth {
+		if err := write("wwwauth[]", v); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Decode reads key=value attributes from r until a blank line or EOF and
+// sets them on c. Unknown attributes are ignored, as Git does.
+func (c *Credential) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("credential: invalid line %q", line)
+		}
+
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "password_expiry_utc":
+			c.PasswordExpiryUTC = value
+		case "oauth_refresh_token":
+			c.OAuthRefreshToken = value
+		case "wwwauth[]":
+			if value == "" {
+				c.WWWAuth = nil
+				continue
+			}
+			c.WWWAuth = append(c.WWWAuth, value)
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return fmt.Errorf("credential: invalid url: %w", err)
+			}
+			*c = *FromURL(u, true)
+		case "quit":
+			c.Quit = value == "1" || value == "true"
+		}
+	}
+
+	return s.Err()
+}
+
+// Helper is a credential helper, as configured with credential.helper.
+type Helper interface {
+	// Get returns the attributes the helper knows for c. Helpers that
+	// know nothing about c return an empty credential without error.
+	Get(ctx context.Context, c *Credential) (*Credential, error)
+	// Store asks the helper to remember c.
+	Store(ctx context.Context, c *Credential) error
+	// Erase asks the helper to forget the credentials matching c.
+	Erase(ctx context.Context, c *Credential) error
+}
+
+// Manager consults an ordered list of helpers.
+type Manager struct {
+	// Helpers are consulted in order.
+	Helpers []Helper
+	// Username is the default username, from credential.username. It is
+	// used when the URL does not contain one.
+	Username string
+	// UseHTTPPath makes the URL path part of the credential for HTTP URLs,
+	// from credential.useHttpPath.
+	UseHTTPPath bool
+	// Prompt asks the user for the username and password when no helper
+	// provided them. If nil, the user is never asked.
+	Prompt Prompter
+}
+
+// Credential returns the credential describing u, before asking any
+// helper.
+func (m *Manager) Credential(u *url.URL) *Credential {
+	useHTTPPath := m.UseHTTPPath || (u.Scheme != "http" && u.Scheme != "https")
+	c := FromURL(u, useHTTPPath)
+	if c.Username == "" {
+		c.Username = m.Username
+	}
+	return c
+}
+
+// Fill asks the helpers in order for the credential of u, until one returns
+// both a username and a password or sets quit. If the credential is still
+// incomplete, the missing values are asked through Prompt. wwwAuth holds
+// the WWW-Authenticate headers of the response that required
+// authentication, if any. It returns ErrNotFound if the credential could
+// not be completed.
+func (m *Manager) Fill(ctx context.Context, u *url.URL, wwwAuth []string) (*Credential, error) {
+	c := m.Credential(u)
+	c.WWWAuth = wwwAuth
+	if c.Complete() {
+		return c, nil
+	}
+
+	var errs []error
+	var quit bool
+	for _, h := range m.Helpers {
+		got, err := h.Get(ctx, c)
+		if err != nil {
+			// Git warns about a failing helper and moves on to the next.
+			errs = append(errs, err)
+			continue
+		}
+		merge(c, got)
+		if quit = got.Quit; c.Complete() || quit {
+			break
+		}
+	}
+
+	if !c.Complete() && !quit && m.Prompt != nil {
+		if err := prompt(ctx, m.Prompt, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if !c.Complete() {
+		errs = append([]error{ErrNotFound}, errs...)
+		return nil, errors.Join(errs...)
+	}
+
+	return c, nil
+}
+
+// Approve tells all helpers that c was accepted by the server, so that
+// they can store it.
+func (m *Manager) Approve(ctx context.Context, c *Credential) error {
+	if !c.Complete() {
+		return nil
+	}
+
+	var errs []error
+	for _, h := range m.Helpers {
+		if err := h.Store(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reject tells all helpers that c was rejected by the server, so that they
+// can erase it.
+func (m *Manager) Reject(ctx context.Context, c *Credential) error {
+	var errs []error
+	for _, h := range m.Helpers {
+		if err := h.Erase(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// merge copies the attributes a helper returned into c. Git lets helpers
+// override any attribute, but the lookup keys are kept stable so that a
+// helper cannot redirect the credential to a different host.
+func merge(c, got *Credential) {
+	if got == nil {
+		return
+	}
+	if got.Username != "" {
+		c.Username = got.Username
+	}
+	if got.Password != "" {
+		c.Password = got.Password
+	}
+	if got.PasswordExpiryUTC != "" {
+		c.PasswordExpiryUTC = got.PasswordExpiryUTC
+	}
+	if got.OAuthRefreshToken != "" {
+		c.OAuthRefreshToken = got.OAuthRefreshToken
+	}
+}
@@ -0,0 +1,206 @@
+package credential
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	c := &Credential{
+		Protocol: "https",
+		Host:     "example.com:8443",
+		Path:     "org/repo.git",
+		Username: "user",
+		Password: "p=ss",
+		WWWAuth:  []string{`Basic realm="git"`, "Bearer"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Encode(&buf))
+	assert.Equal(t, "protocol=https\nhost=example.com:8443\npath=org/repo.git\n"+
+		"username=user\npassword=p=ss\nwwwauth[]=Basic realm=\"git\"\nwwwauth[]=Bearer\n", buf.String())
+
+	got := &Credential{}
+	require.NoError(t, got.Decode(&buf))
+	assert.Equal(t, c, got)
+}
+
+func TestCredentialEncodeInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	c := &Credential{Protocol: "https", Password: "a\nb"}
+	err := c.Encode(&bytes.Buffer{})
+	require.ErrorIs(t, err, ErrInvalidValue)
+}
+
+func TestCredentialDecode(t *testing.T) {
+	t.Parallel()
+
+	c := &Credential{}
+	require.NoError(t, c.Decode(strings.NewReader("url=https://u@example.com/repo\nunknown=x\npassword=pw\nquit=1\n\nusername=ignored\n")))
+	assert.Equal(t, &Credential{
+		Protocol: "https",
+		Host:     "example.com",
+		Path:     "repo",
+		Username: "u",
+		Password: "pw",
+		Quit:     true,
+	}, c)
+
+	require.Error(t, c.Decode(strings.NewReader("garbage\n")))
+}
+
+func TestCredentialMatch(t *testing.T) {
+	t.Parallel()
+
+	have := &Credential{Protocol: "https", Host: "example.com", Username: "u", Password: "p"}
+
+	assert.True(t, (&Credential{Protocol: "https", Host: "example.com"}).Match(have, false))
+	assert.True(t, (&Credential{Protocol: "https", Host: "example.com", Password: "x"}).Match(have, false))
+	assert.False(t, (&Credential{Protocol: "https", Host: "example.com", Password: "x"}).Match(have, true))
+	assert.False(t, (&Credential{Protocol: "http", Host: "example.com"}).Match(have, false))
+	assert.False(t, (&Credential{Protocol: "https", Host: "example.com", Path: "repo"}).Match(have, false))
+}
+
+type staticHelper struct {
+	got            *Credential
+	err            error
+	stored, erased int
+}
+
+func (h *staticHelper) Get(context.Context, *Credential) (*Credential, error) {
+	return h.got, h.err
+}
+
+func (h *staticHelper) Store(context.Context, *Credential) error {
+	h.stored++
+	return nil
+}
+
+func (h *staticHelper) Erase(context.Context, *Credential) error {
+	h.erased++
+	return nil
+}
+
+func TestManagerFill(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/org/repo.git")
+	require.NoError(t, err)
+
+	failing := &staticHelper{err: errors.New("boom")}
+	userOnly := &staticHelper{got: &Credential{Username: "u"}}
+	full := &staticHelper{got: &Credential{Username: "other", Password: "p"}}
+	never := &staticHelper{got: &Credential{Username: "never", Password: "never"}}
+
+	m := &Manager{Helpers: []Helper{failing, userOnly, full, never}}
+	c, err := m.Fill(context.Background(), u, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "other", c.Username)
+	assert.Equal(t, "p", c.Password)
+	assert.Empty(t, c.Path)
+
+	require.NoError(t, m.Approve(context.Background(), c))
+	require.NoError(t, m.Reject(context.Background(), c))
+	for _, h := range []*staticHelper{failing, userOnly, full, never} {
+		assert.Equal(t, 1, h.stored)
+		assert.Equal(t, 1, h.erased)
+	}
+}
+
+func TestManagerFillQuit(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/repo")
+	require.NoError(t, err)
+
+	m := &Manager{Helpers: []Helper{
+		&staticHelper{got: &Credential{Quit: true}},
+		&staticHelper{got: &Credential{Username: "u", Password: "p"}},
+	}}
+	_, err = m.Fill(context.Background(), u, nil)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManagerCredential(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/org/repo.git")
+	require.NoError(t, err)
+
+	m := &Manager{Username: "default", UseHTTPPath: true}
+	c := m.Credential(u)
+	assert.Equal(t, &Credential{Protocol: "https", Host: "example.com", Path: "org/repo.git", Username: "default"}, c)
+
+	u.User = url.User("explicit")
+	assert.Equal(t, "explicit", m.Credential(u).Username)
+}
+
+func TestExecHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "helper.sh")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "$1" >> `+log+`
+cat >> `+log+`
+if [ "$1" = get ]; then
+	echo username=alice
+	echo password=s3cret
+fi
+`), 0o755))
+
+	h := NewExecHelper(script)
+	c := &Credential{Protocol: "https", Host: "example.com"}
+
+	got, err := h.Get(context.Background(), c)
+	require.NoError(t, err)
+	assert.Equal(t, &Credential{Username: "alice", Password: "s3cret"}, got)
+
+	c.Username, c.Password = got.Username, got.Password
+	require.NoError(t, h.Store(context.Background(), c))
+	require.NoError(t, h.Erase(context.Background(), c))
+
+	b, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, "get\nprotocol=https\nhost=example.com\n"+
+		"store\nprotocol=https\nhost=example.com\nusername=alice\npassword=s3cret\n"+
+		"erase\nprotocol=https\nhost=example.com\nusername=alice\npassword=s3cret\n", string(b))
+
+	_, err = NewExecHelper("!exit 1").Get(context.Background(), c)
+	require.Error(t, err)
+}
+
+func TestExecHelperCommandLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command, want string
+	}{
+		{"cache --timeout=60", "git credential-cache --timeout=60 get"},
+		{"!f() { echo password=x; }; f", "f() { echo password=x; }; f get"},
+		{"/usr/bin/helper --flag", "/usr/bin/helper --flag get"},
+	}
+	for _, tc := range tests {
+		if tc.command[0] == '/' && runtime.GOOS == "windows" {
+			continue
+		}
+		assert.Equal(t, tc.want, NewExecHelper(tc.command).commandLine("get"))
+	}
+}
@@ -0,0 +1,97 @@
+package credential
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExecHelper runs an external credential helper, as configured with
+// credential.helper.
+//
+// The command is interpreted the way Git does: a value starting with "!"
+// is run as a shell snippet, an absolute path is run as is, and anything
+// else names a "git credential-<name>" program. The action ("get",
+// "store" or "erase") is appended to the command line.
+type ExecHelper struct {
+	// Command is the credential.helper value.
+	Command string
+	// Dir is the working directory of the helper. If empty, the current
+	// directory is used.
+	Dir string
+	// Env is the environment of the helper. If nil, the current process
+	// environment is used.
+	Env []string
+	// Stderr receives the helper's standard error, which helpers use to
+	// talk to the user. If nil, it is discarded.
+	Stderr io.Writer
+}
+
+var _ Helper = (*ExecHelper)(nil)
+
+// NewExecHelper returns an ExecHelper running command.
+func NewExecHelper(command string) *ExecHelper {
+	return &ExecHelper{Command: command}
+}
+
+// Get implements Helper.
+func (h *ExecHelper) Get(ctx context.Context, c *Credential) (*Credential, error) {
+	out, err := h.run(ctx, "get", c)
+	if err != nil {
+		return nil, err
+	}
+
+	got := &Credential{}
+	if err := got.Decode(bytes.NewReader(out)); err != nil {
+		return nil, fmt.Errorf("credential helper %q: %w", h.Command, err)
+	}
+	return got, nil
+}
+
+// Store implements Helper.
+func (h *ExecHelper) Store(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "store", c)
+	return err
+}
+
+// Erase implements Helper.
+func (h *ExecHelper) Erase(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "erase", c)
+	return err
+}
+
+func (h *ExecHelper) run(ctx context.Context, action string, c *Credential) ([]byte, error) {
+	var in bytes.Buffer
+	if err := c.Encode(&in); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.commandLine(action))
+	cmd.Dir = h.Dir
+	cmd.Env = h.Env
+	cmd.Stdin = &in
+	cmd.Stderr = h.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %q %s: %w", h.Command, action, err)
+	}
+	return out, nil
+}
+
+// commandLine mirrors run_credential_helper in Git's credential.c.
+func (h *ExecHelper) commandLine(action string) string {
+	command := h.Command
+	switch {
+	case strings.HasPrefix(command, "!"):
+		command = command[1:]
+	case filepath.IsAbs(command):
+	default:
+		command = "git credential-" + command
+	}
+	return command + " " + action
+}
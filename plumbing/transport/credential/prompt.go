@@ -0,0 +1,131 @@
+package credential
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+// Prompter interactively asks the user for a value, such as a username, a
+// password or a key passphrase.
+type Prompter interface {
+	// Prompt shows prompt to the user and returns the answer. echo is false
+	// for secrets that should not be displayed while typed.
+	Prompt(ctx context.Context, prompt string, echo bool) (string, error)
+}
+
+// PromptFunc is an adapter to allow the use of ordinary functions as a
+// Prompter.
+type PromptFunc func(ctx context.Context, prompt string, echo bool) (string, error)
+
+// Prompt implements Prompter.
+func (f PromptFunc) Prompt(ctx context.Context, prompt string, echo bool) (string, error) {
+	return f(ctx, prompt, echo)
+}
+
+// AskPass is a Prompter that runs an askpass program, as Git does for
+// GIT_ASKPASS, core.askPass and SSH_ASKPASS. The program receives the
+// prompt as its only argument and prints the answer on its standard
+// output.
+type AskPass struct {
+	// Program is the askpass program.
+	Program string
+	// Env is the environment of the program. If nil, the current process
+	// environment is used.
+	Env []string
+}
+
+var _ Prompter = (*AskPass)(nil)
+
+// NewAskPass returns the AskPass Git would use, given the value of
+// core.askPass: the GIT_ASKPASS environment variable takes precedence over
+// coreAskPass, which takes precedence over SSH_ASKPASS. It returns nil if
+// none of them is set.
+func NewAskPass(coreAskPass string) *AskPass {
+	for _, program := range []string{
+		os.Getenv("GIT_ASKPASS"),
+		coreAskPass,
+		os.Getenv("SSH_ASKPASS"),
+	} {
+		if program != "" {
+			return &AskPass{Program: program}
+		}
+	}
+	return nil
+}
+
+// NewAskPassFromConfig is like NewAskPass, reading core.askPass from cfgs,
+// which must be given from lowest to highest precedence.
+func NewAskPassFromConfig(cfgs ...*format.Config) *AskPass {
+	var coreAskPass string
+	for _, cfg := range cfgs {
+		if cfg == nil || !cfg.HasSection("core") {
+			continue
+		}
+		if v := cfg.Section("core").Option("askPass"); v != "" {
+			coreAskPass = v
+		}
+	}
+	return NewAskPass(coreAskPass)
+}
+
+// Prompt implements Prompter. Only the first line of the program output is
+// returned, without its line terminator.
+func (a *AskPass) Prompt(ctx context.Context, prompt string, _ bool) (string, error) {
+	cmd := exec.CommandContext(ctx, a.Program, prompt)
+	cmd.Env = a.Env
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("unable to read askpass response from %q: %w", a.Program, err)
+	}
+
+	answer, _, _ := strings.Cut(out.String(), "\n")
+	return strings.TrimSuffix(answer, "\r"), nil
+}
+
+// describe returns the URL shown in prompts, following Git's
+// credential_describe.
+func describe(c *Credential, withUsername bool) string {
+	var b strings.Builder
+	b.WriteString(c.Protocol)
+	b.WriteString("://")
+	if withUsername && c.Username != "" {
+		b.WriteString(c.Username)
+		b.WriteByte('@')
+	}
+	b.WriteString(c.Host)
+	if c.Path != "" {
+		b.WriteByte('/')
+		b.WriteString(c.Path)
+	}
+	return b.String()
+}
+
+// prompt asks p for the username and password missing from c, using the
+// same prompts as Git.
+func prompt(ctx context.Context, p Prompter, c *Credential) error {
+	if c.Username == "" {
+		v, err := p.Prompt(ctx, fmt.Sprintf("Username for '%s': ", describe(c, false)), true)
+		if err != nil {
+			return err
+		}
+		c.Username = v
+	}
+
+	if c.Password == "" {
+		v, err := p.Prompt(ctx, fmt.Sprintf("Password for '%s': ", describe(c, true)), false)
+		if err != nil {
+			return err
+		}
+		c.Password = v
+	}
+
+	return nil
+}
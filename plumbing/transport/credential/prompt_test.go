@@ -0,0 +1,125 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskPass(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	script := filepath.Join(t.TempDir(), "askpass.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nprintf 'answer to %s\\r\\nignored\\n' \"$1\"\n"), 0o755))
+
+	got, err := (&AskPass{Program: script}).Prompt(context.Background(), "Password: ", false)
+	require.NoError(t, err)
+	assert.Equal(t, "answer to Password: ", got)
+
+	_, err = (&AskPass{Program: filepath.Join(t.TempDir(), "missing")}).Prompt(context.Background(), "x", true)
+	require.Error(t, err)
+}
+
+func TestNewAskPass(t *testing.T) {
+	t.Setenv("GIT_ASKPASS", "")
+	t.Setenv("SSH_ASKPASS", "")
+	assert.Nil(t, NewAskPass(""))
+
+	t.Setenv("SSH_ASKPASS", "ssh-askpass")
+	assert.Equal(t, &AskPass{Program: "ssh-askpass"}, NewAskPass(""))
+	assert.Equal(t, &AskPass{Program: "core-askpass"}, NewAskPass("core-askpass"))
+
+	t.Setenv("GIT_ASKPASS", "git-askpass")
+	assert.Equal(t, &AskPass{Program: "git-askpass"}, NewAskPass("core-askpass"))
+}
+
+func TestNewAskPassFromConfig(t *testing.T) {
+	t.Setenv("GIT_ASKPASS", "")
+	t.Setenv("SSH_ASKPASS", "")
+
+	global := decodeConfig(t, "[core]\n\taskPass = global-askpass\n")
+	local := decodeConfig(t, "[core]\n\taskPass = local-askpass\n")
+	assert.Equal(t, &AskPass{Program: "local-askpass"}, NewAskPassFromConfig(global, local, nil))
+	assert.Equal(t, &AskPass{Program: "global-askpass"}, NewAskPassFromConfig(global))
+}
+
+func TestManagerFillPrompt(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/repo.git")
+	require.NoError(t, err)
+
+	var prompts []string
+	m := &Manager{
+		Helpers: []Helper{&staticHelper{got: &Credential{Username: "helper-user"}}},
+		Prompt: PromptFunc(func(_ context.Context, prompt string, echo bool) (string, error) {
+			prompts = append(prompts, prompt)
+			assert.False(t, echo)
+			return "typed", nil
+		}),
+	}
+
+	c, err := m.Fill(context.Background(), u, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "helper-user", c.Username)
+	assert.Equal(t, "typed", c.Password)
+	assert.Equal(t, []string{"Password for 'https://helper-user@example.com': "}, prompts)
+}
+
+func TestManagerFillPromptUsername(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/repo.git")
+	require.NoError(t, err)
+
+	var prompts []string
+	m := &Manager{
+		UseHTTPPath: true,
+		Prompt: PromptFunc(func(_ context.Context, prompt string, _ bool) (string, error) {
+			prompts = append(prompts, prompt)
+			return "value", nil
+		}),
+	}
+
+	_, err = m.Fill(context.Background(), u, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Username for 'https://example.com/repo.git': ",
+		"Password for 'https://value@example.com/repo.git': ",
+	}, prompts)
+}
+
+func TestManagerFillPromptError(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("https://example.com/repo.git")
+	require.NoError(t, err)
+
+	boom := errors.New("boom")
+	m := &Manager{Prompt: PromptFunc(func(context.Context, string, bool) (string, error) {
+		return "", boom
+	})}
+
+	_, err = m.Fill(context.Background(), u, nil)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, err, boom)
+
+	// A helper asking to quit prevents prompting.
+	m.Helpers = []Helper{&staticHelper{got: &Credential{Quit: true}}}
+	m.Prompt = PromptFunc(func(context.Context, string, bool) (string, error) {
+		t.Fatal("unexpected prompt")
+		return "", nil
+	})
+	_, err = m.Fill(context.Background(), u, nil)
+	require.ErrorIs(t, err, ErrNotFound)
+}
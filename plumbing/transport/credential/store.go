@@ -0,0 +1,201 @@
+package credential
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store is an in-process implementation of git-credential-store. It reads
+// and writes the same plain-text files, one URL with embedded username and
+// password per line.
+type Store struct {
+	// Files are the credential files, in lookup order. New credentials are
+	// written to the first file that exists, or to the first file if none
+	// exists.
+	Files []string
+}
+
+var _ Helper = (*Store)(nil)
+
+// NewStore returns a Store using files. If none are given, the default
+// git-credential-store files are used: ~/.git-credentials and
+// $XDG_CONFIG_HOME/git/credentials.
+func NewStore(files ...string) *Store {
+	if len(files) == 0 {
+		files = DefaultStoreFiles()
+	}
+	return &Store{Files: files}
+}
+
+// DefaultStoreFiles returns the files git-credential-store uses when no
+// --file option is given.
+func DefaultStoreFiles() []string {
+	var files []string
+	home, err := os.UserHomeDir()
+	if err == nil {
+		files = append(files, filepath.Join(home, ".git-credentials"))
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		files = append(files, filepath.Join(xdg, "git", "credentials"))
+	} else if home != "" {
+		files = append(files, filepath.Join(home, ".config", "git", "credentials"))
+	}
+
+	return files
+}
+
+// Get implements Helper. It returns the first stored credential matching
+// c, or an empty credential if there is none.
+func (s *Store) Get(_ context.Context, c *Credential) (*Credential, error) {
+	for _, file := range s.Files {
+		entries, err := readStoreFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if e.cred != nil && e.cred.Complete() && c.Match(e.cred, false) {
+				return &Credential{
+					Username: e.cred.Username,
+					Password: e.cred.Password,
+				}, nil
+			}
+		}
+	}
+
+	return &Credential{}, nil
+}
+
+// Store implements Helper. Existing entries for the same protocol, host,
+// path and username are replaced.
+func (s *Store) Store(_ context.Context, c *Credential) error {
+	// Without a protocol and a host or path there is no key to look the
+	// credential up by later.
+	if c.Protocol == "" || (c.Host == "" && c.Path == "") || !c.Complete() {
+		return nil
+	}
+	if len(s.Files) == 0 {
+		return nil
+	}
+
+	file := s.Files[0]
+	for _, f := range s.Files {
+		if _, err := os.Stat(f); err == nil {
+			file = f
+			break
+		}
+	}
+
+	key := &Credential{
+		Protocol: c.Protocol,
+		Host:     c.Host,
+		Path:     c.Path,
+		Username: c.Username,
+	}
+	return rewriteStoreFile(file, key, false, c)
+}
+
+// Erase implements Helper. It removes the entries matching c from all
+// files. The password is only compared if c has one.
+func (s *Store) Erase(_ context.Context, c *Credential) error {
+	if c.Protocol == "" || (c.Host == "" && c.Path == "") {
+		return nil
+	}
+
+	var errs []error
+	for _, file := range s.Files {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if err := rewriteStoreFile(file, c, true, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type storeEntry struct {
+	line string
+	cred *Credential
+}
+
+func readStoreFile(file string) ([]storeEntry, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []storeEntry
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		// Lines that do not parse are kept verbatim, but never match.
+		e := storeEntry{line: line}
+		if u, err := url.Parse(line); err == nil && u.Scheme != "" {
+			e.cred = FromURL(u, true)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+// rewriteStoreFile removes the entries matching key from file and, if add
+// is not nil, appends it.
+func rewriteStoreFile(file string, key *Credential, matchPassword bool, add *Credential) error {
+	entries, err := readStoreFile(file)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		if e.cred != nil && key.Match(e.cred, matchPassword) {
+			continue
+		}
+		buf.WriteString(e.line)
+		buf.WriteByte('\n')
+	}
+	if add != nil {
+		buf.WriteString(add.URL().String())
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".lock")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
@@ -30,10 +30,15 @@ var (
 	ErrInvalidResponse           = errors.New("invalid response")
 	ErrTimeoutExceeded           = errors.New("timeout exceeded")
 	ErrPackedObjectsNotSupported = errors.New("packed objects not supported")
+	ErrObjectInfoNotSupported    = errors.New("server does not support object-info")
+	ErrBundleURINotSupported     = errors.New("server does not support bundle-uri")
 )
 
 // Negotiation errors.
 var (
 	ErrFilterNotSupported  = errors.New("server does not support filters")
 	ErrShallowNotSupported = errors.New("server does not support shallow clients")
+
+	ErrDeepenNotNotSupported      = errors.New("server does not support deepen-not")
+	ErrDeepenRelativeNotSupported = errors.New("server does not support deepen-relative")
 )
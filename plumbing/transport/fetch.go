@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 
+	internal "github.com/go-git/go-git/v6/internal/transport"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
@@ -12,6 +13,12 @@ import (
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
+// SupportsFilter reports whether a server advertising caps accepts filtered
+// fetches, in either protocol version.
+func SupportsFilter(caps *capability.List) bool {
+	return caps.Supports(capability.Filter) || internal.FetchSupports(*caps, "filter")
+}
+
 // FetchPack fetches a packfile from the remote into the given storage.
 func FetchPack(
 	ctx context.Context,
@@ -37,7 +44,8 @@ func FetchPack(
 	}
 
 	// A filtered fetch deliberately leaves out objects, so the pack has to be
-	// recorded as coming from a promisor remote. Git otherwise reads those
+	// recorded as coming from a promisor remote, as do the objects fetched
+	// lazily into a partial clone. Git otherwise reads those
 	// absences as corruption: fsck reports broken links to them and gc fails
 	// with "unable to read".
 	//
@@ -46,7 +54,7 @@ func FetchPack(
 	// repacking (repack-promisor.c), and accepts either, because only the
 	// file's presence is ever consulted — packfile.c tests it with access(2)
 	// and never opens it.
-	if req.Filter != "" {
+	if req.IsPromisor() {
 		if err := packfile.UpdatePromisorObjectStorage(st, reader, ""); err != nil {
 			return err
 		}
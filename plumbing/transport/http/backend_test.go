@@ -89,6 +89,9 @@ func setupGoGitBackendServer(t testing.TB) (baseURL, repoName string) {
 	run(t, repoDir, "git", "symbolic-ref", "HEAD", "refs/heads/main")
 	run(t, repoDir, "git", "config", "http.receivepack", "true")
 	run(t, repoDir, "git", "config", "http.uploadpack", "true")
+	// Partial clones need the filters the go-git server only serves when
+	// allowed, as git's upload-pack.
+	run(t, repoDir, "git", "config", "uploadpack.allowFilter", "true")
 
 	work := filepath.Join(tmp, "work")
 	require.NoError(t, os.MkdirAll(work, 0o755))
@@ -316,3 +319,93 @@ func TestBackend_HTTP_E2E_ShallowClone(t *testing.T) {
 	catFile.Dir = cloned
 	require.Error(t, catFile.Run(), "parent commit %s must be absent from a bounded --depth 1 clone", parent)
 }
+
+// TestBackend_HTTP_E2E_Deepen verifies that a real git CLI can deepen a shallow
+// clone with "fetch --deepen" (deepen-relative) and clone with
+// "--shallow-exclude" (deepen-not) against the go-git backend, over protocol v0
+// and v2.
+func TestBackend_HTTP_E2E_Deepen(t *testing.T) {
+	t.Parallel()
+
+	requireGitV2(t)
+
+	tmp := t.TempDir()
+	repoName := "deepenrepo.git"
+	repoDir := filepath.Join(tmp, repoName)
+	require.NoError(t, os.MkdirAll(repoDir, 0o755))
+	run(t, tmp, "git", "init", "--bare", repoName)
+	run(t, repoDir, "git", "symbolic-ref", "HEAD", "refs/heads/main")
+
+	work := filepath.Join(tmp, "work")
+	require.NoError(t, os.MkdirAll(work, 0o755))
+	run(t, work, "git", "init")
+	run(t, work, "git", "symbolic-ref", "HEAD", "refs/heads/main")
+	run(t, work, "git", "config", "user.name", "tester")
+	run(t, work, "git", "config", "user.email", "tester@test")
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(work, "f.txt"), fmt.Appendf(nil, "commit %d\n", i), 0o644))
+		run(t, work, "git", "add", "f.txt")
+		run(t, work, "git", "commit", "-m", fmt.Sprintf("c%d", i))
+		if i == 2 {
+			run(t, work, "git", "tag", "v2")
+		}
+	}
+	run(t, work, "git", "remote", "add", "origin", "file://"+repoDir)
+	run(t, work, "git", "push", "--tags", "-u", "origin", "main")
+
+	loader := transport.NewFilesystemLoader(osfs.New(tmp), false)
+	srv, err := internalhttp.FromLoader(loader)
+	require.NoError(t, err)
+	ep, err := srv.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	remote := fmt.Sprintf("%s/%s", ep, repoName)
+	for _, version := range []string{"0", "2"} {
+		t.Run("v"+version, func(t *testing.T) {
+			dir := t.TempDir()
+			proto := "protocol.version=" + version
+
+			run(t, dir, "git", "-c", proto, "clone", "--depth", "1", remote, "deepen")
+			deepen := filepath.Join(dir, "deepen")
+			run(t, deepen, "git", "-c", proto, "fetch", "--deepen", "2")
+			require.Equal(t, "3", gitOut(t, deepen, "rev-list", "--count", "HEAD"),
+				"--deepen 2 must extend a depth 1 clone by two commits")
+
+			run(t, dir, "git", "-c", proto, "clone", "--shallow-exclude", "v2", remote, "exclude")
+			require.Equal(t, "3", gitOut(t, filepath.Join(dir, "exclude"), "rev-list", "--count", "HEAD"),
+				"--shallow-exclude v2 must stop the history right after v2")
+		})
+	}
+}
+
+// TestBackend_HTTP_E2E_PartialClone verifies that a real git CLI
+// "clone --filter" against the go-git backend produces a partial clone, over
+// protocol v0 and v2: the filtered blobs are missing, and git fetches them
+// back on demand from the server. As with git's upload-pack, fetching the
+// missing blobs needs protocol v2: v0 only serves the advertised tips.
+func TestBackend_HTTP_E2E_PartialClone(t *testing.T) {
+	t.Parallel()
+
+	requireGitV2(t)
+
+	ep, repoName := setupGoGitBackendServer(t)
+	authed := fmt.Sprintf("http://u:p@%s/%s", strings.TrimPrefix(ep, "http://"), repoName)
+
+	for _, version := range []string{"0", "2"} {
+		cloneDir := t.TempDir()
+		run(t, cloneDir, "git", "-c", "protocol.version="+version, "clone", "--no-checkout", "--filter=blob:none", authed, "partial")
+		cloned := filepath.Join(cloneDir, "partial")
+
+		require.Equal(t, "true", gitOut(t, cloned, "config", "remote.origin.promisor"))
+		missing := gitOut(t, cloned, "rev-list", "--objects", "--missing=print", "HEAD")
+		require.Contains(t, missing, "?", "v%s: the blobs must be filtered out of the clone", version)
+
+		// Checking out fetches the missing blobs from the server.
+		run(t, cloned, "git", "-c", "protocol.version=2", "checkout", "main")
+		content, err := os.ReadFile(filepath.Join(cloned, "README.md"))
+		require.NoError(t, err)
+		require.Equal(t, "hello from go-git backend e2e test\n", string(content))
+		run(t, cloned, "git", "fsck")
+	}
+}
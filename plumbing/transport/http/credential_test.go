@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+)
+
+type fakeHelper struct {
+	username, password string
+	stored, erased     []*credential.Credential
+}
+
+func (h *fakeHelper) Get(_ context.Context, _ *credential.Credential) (*credential.Credential, error) {
+	return &credential.Credential{Username: h.username, Password: h.password}, nil
+}
+
+func (h *fakeHelper) Store(_ context.Context, c *credential.Credential) error {
+	h.stored = append(h.stored, c)
+	return nil
+}
+
+func (h *fakeHelper) Erase(_ context.Context, c *credential.Credential) error {
+	h.erased = append(h.erased, c)
+	return nil
+}
+
+// setupAuthServer serves the basic.git fixture behind HTTP basic
+// authentication for user:secret.
+func setupAuthServer(t *testing.T) *url.URL {
+	t.Helper()
+
+	base, backend := setupSmartServer(t)
+	prepareRepo(t, fixtures.Basic().One(), base, "basic.git")
+
+	target, err := url.Parse(fmt.Sprintf("http://localhost:%d", backend.Port))
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL + "/basic.git")
+	require.NoError(t, err)
+	return u
+}
+
+func TestCredentialHelperRetry(t *testing.T) {
+	t.Parallel()
+
+	endpoint := setupAuthServer(t)
+	helper := &fakeHelper{username: "user", password: "secret"}
+	tr := NewTransport(Options{
+		Credentials: &credential.Manager{Helpers: []credential.Helper{helper}},
+	})
+
+	session, err := tr.Handshake(context.Background(), &transport.Request{
+		URL:     endpoint,
+		Command: transport.UploadPackService,
+	})
+	require.NoError(t, err)
+	defer session.Close()
+
+	refs, err := session.GetRemoteRefs(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, refs.References)
+
+	require.Len(t, helper.stored, 1)
+	assert.Equal(t, "http", helper.stored[0].Protocol)
+	assert.Equal(t, endpoint.Host, helper.stored[0].Host)
+	assert.Equal(t, "user", helper.stored[0].Username)
+	assert.Empty(t, helper.stored[0].Path)
+	assert.Equal(t, []string{`Basic realm="git"`}, helper.stored[0].WWWAuth)
+	assert.Empty(t, helper.erased)
+}
+
+func TestCredentialHelperRejected(t *testing.T) {
+	t.Parallel()
+
+	endpoint := setupAuthServer(t)
+	helper := &fakeHelper{username: "user", password: "wrong"}
+	tr := NewTransport(Options{
+		Credentials: &credential.Manager{Helpers: []credential.Helper{helper}},
+	})
+
+	_, err := tr.Handshake(context.Background(), &transport.Request{
+		URL:     endpoint,
+		Command: transport.UploadPackService,
+	})
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+
+	assert.Empty(t, helper.stored)
+	require.Len(t, helper.erased, 1)
+	assert.Equal(t, "wrong", helper.erased[0].Password)
+}
+
+func TestCredentialHelperNotFound(t *testing.T) {
+	t.Parallel()
+
+	endpoint := setupAuthServer(t)
+	tr := NewTransport(Options{
+		Credentials: &credential.Manager{Helpers: []credential.Helper{&fakeHelper{}}},
+	})
+
+	_, err := tr.Handshake(context.Background(), &transport.Request{
+		URL:     endpoint,
+		Command: transport.UploadPackService,
+	})
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+	require.ErrorIs(t, err, credential.ErrNotFound)
+}
+
+func TestCredentialHelperSkippedWithAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	endpoint := setupAuthServer(t)
+	helper := &fakeHelper{username: "user", password: "secret"}
+	tr := NewTransport(Options{
+		Authorizer:  (&BasicAuth{Username: "user", Password: "wrong"}).Authorizer,
+		Credentials: &credential.Manager{Helpers: []credential.Helper{helper}},
+	})
+
+	_, err := tr.Handshake(context.Background(), &transport.Request{
+		URL:     endpoint,
+		Command: transport.UploadPackService,
+	})
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+	assert.Empty(t, helper.stored)
+	assert.Empty(t, helper.erased)
+}
+
+func TestCredentialPrompt(t *testing.T) {
+	t.Parallel()
+
+	endpoint := setupAuthServer(t)
+	var prompts []string
+	tr := NewTransport(Options{
+		Credentials: &credential.Manager{
+			Prompt: credential.PromptFunc(func(_ context.Context, prompt string, _ bool) (string, error) {
+				prompts = append(prompts, prompt)
+				if len(prompts) == 1 {
+					return "user", nil
+				}
+				return "secret", nil
+			}),
+		},
+	})
+
+	session, err := tr.Handshake(context.Background(), &transport.Request{
+		URL:     endpoint,
+		Command: transport.UploadPackService,
+	})
+	require.NoError(t, err)
+	defer session.Close()
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("Username for 'http://%s': ", endpoint.Host),
+		fmt.Sprintf("Password for 'http://user@%s': ", endpoint.Host),
+	}, prompts)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+)
+
+// Client returns the HTTP client the transport sends its requests with. It
+// follows the redirect policy of the transport, and its TLS and proxy
+// settings.
+func (t *Transport) Client() *http.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == nil {
+		t.client = t.resolveClient()
+	}
+
+	return t.client
+}
+
+// Do sends req with the client of the transport, authenticated as the
+// requests to a repository are: with the user info of its URL and the
+// Authorizer, or else the credentials of the credential helpers once the
+// server answers 401 Unauthorized, which are then used for the following
+// requests. Requests having an Authorization header are sent as they are.
+//
+// It lets services hosted along with a repository, such as Git LFS, be
+// used with the same settings. Responses with an error status are closed,
+// and returned as errors as the transport does.
+//
+// Warning: the credentials are sent whatever the host of req is. Only use Do
+// for URLs on the origin of the repository the transport was set up for;
+// send the requests to other hosts, such as URLs chosen by a server, with
+// Client, which sends no credentials.
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	client := t.Client()
+	if req.Header.Get("Authorization") != "" {
+		return checkResponse(doRequest(client, req))
+	}
+
+	t.mu.Lock()
+	cred := t.cred
+	t.mu.Unlock()
+
+	authorized, err := t.authorize(req, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := doRequest(client, authorized)
+	if err == nil || cred != nil || !errors.Is(err, transport.ErrAuthenticationRequired) ||
+		!t.useCredentialHelpers(req.URL) {
+		return checkResponse(res, err)
+	}
+	_ = res.Body.Close()
+
+	if req.Body != nil && req.GetBody == nil {
+		return nil, fmt.Errorf("http transport: %w: cannot retry request", transport.ErrAuthenticationRequired)
+	}
+
+	ctx := req.Context()
+	cred, err = t.opts.Credentials.Fill(ctx, req.URL, res.Header.Values("WWW-Authenticate"))
+	if err != nil {
+		return nil, fmt.Errorf("http transport: %w: %w", transport.ErrAuthenticationRequired, err)
+	}
+
+	authorized, err = t.authorize(req, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = doRequest(client, authorized)
+	switch {
+	case err == nil:
+		_ = t.opts.Credentials.Approve(ctx, cred)
+		t.mu.Lock()
+		t.cred = cred
+		t.mu.Unlock()
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		_ = t.opts.Credentials.Reject(ctx, cred)
+	}
+
+	return checkResponse(res, err)
+}
+
+// authorize returns a copy of req authenticated with cred, if set, or else
+// with the user info of its URL and the Authorizer.
+func (t *Transport) authorize(req *http.Request, cred *credential.Credential) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("http transport: %w", err)
+		}
+		r.Body = body
+	}
+
+	if cred != nil {
+		r.SetBasicAuth(cred.Username, cred.Password)
+		return r, nil
+	}
+
+	if err := applyAuth(r, req.URL, t.opts.Authorizer); err != nil {
+		return nil, fmt.Errorf("http transport: authorize: %w", err)
+	}
+
+	return r, nil
+}
+
+// checkResponse closes the response to a request that failed with err.
+func checkResponse(res *http.Response, err error) (*http.Response, error) {
+	if err == nil {
+		return res, nil
+	}
+	if res != nil {
+		_ = res.Body.Close()
+	}
+
+	return nil, fmt.Errorf("http transport: %w", err)
+}
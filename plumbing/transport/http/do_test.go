@@ -0,0 +1,96 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+)
+
+// newEchoServer serves the body of the requests authenticated as
+// user:secret back, and records their Authorization header.
+func newEchoServer(t *testing.T, auths *[]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auths = append(*auths, r.Header.Get("Authorization"))
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="lfs"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func doPost(t *testing.T, tr *Transport, url, auth string) (string, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader("body"))
+	require.NoError(t, err)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	res, err := tr.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(b), nil
+}
+
+func TestDoCredentialHelper(t *testing.T) {
+	t.Parallel()
+
+	var auths []string
+	srv := newEchoServer(t, &auths)
+	helper := &fakeHelper{username: "user", password: "secret"}
+	tr := NewTransport(Options{
+		Credentials: &credential.Manager{Helpers: []credential.Helper{helper}},
+	})
+
+	body, err := doPost(t, tr, srv.URL+"/objects/batch", "")
+	require.NoError(t, err)
+	assert.Equal(t, "body", body)
+	assert.Len(t, helper.stored, 1)
+
+	// The approved credentials are reused without asking the helpers again.
+	helper.password = "wrong"
+	body, err = doPost(t, tr, srv.URL+"/objects/batch", "")
+	require.NoError(t, err)
+	assert.Equal(t, "body", body)
+	assert.Len(t, auths, 3)
+}
+
+func TestDoAuthorization(t *testing.T) {
+	t.Parallel()
+
+	var auths []string
+	srv := newEchoServer(t, &auths)
+	tr := NewTransport(Options{Authorizer: (&BasicAuth{Username: "user", Password: "wrong"}).Authorizer})
+
+	_, err := doPost(t, tr, srv.URL, "")
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+
+	// An Authorization header set on the request takes precedence.
+	body, err := doPost(t, tr, srv.URL, "Bearer token")
+	require.NoError(t, err)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, "Bearer token", auths[len(auths)-1])
+}
@@ -30,7 +30,7 @@ import (
 )
 
 func (s *dumbPackSession) fetchDumb(ctx context.Context, st storage.Storer, req *transport.FetchRequest) error {
-	if req.Depth != 0 {
+	if req.IsShallow() {
 		return errors.New("dumb http protocol does not support shallow capabilities")
 	}
 
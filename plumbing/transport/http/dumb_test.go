@@ -97,3 +97,5 @@ func (*dumbUploadPackSuite) TestUploadPackInvalidReq()                  {}
 func (*dumbUploadPackSuite) TestUploadPackMulti()                       {}
 func (*dumbUploadPackSuite) TestUploadPackNoChanges()                   {}
 func (*dumbUploadPackSuite) TestUploadPackPartial()                     {}
+func (*dumbUploadPackSuite) TestUploadPackDeepenNot()                   {}
+func (*dumbUploadPackSuite) TestUploadPackDeepenRelative()              {}
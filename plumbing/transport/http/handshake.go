@@ -16,6 +16,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	transport "github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 	"github.com/go-git/go-git/v6/storage"
 )
 
@@ -44,33 +45,66 @@ func (t *Transport) Handshake(ctx context.Context, req *transport.Request) (tran
 		infoURL += "?service=" + discoverService
 	}
 
-	// Mark this as the initial request so checkRedirect allows
-	// the HTTP client to follow redirects for this discovery request.
-	// Subsequent requests (pack POSTs, object GETs) use a plain
-	// context and will not follow redirects.
-	httpReq, err := http.NewRequestWithContext(withInitialRequest(ctx), http.MethodGet, infoURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("http transport: %w", err)
-	}
+	newRequest := func(cred *credential.Credential) (*http.Request, error) {
+		// Mark this as the initial request so checkRedirect allows
+		// the HTTP client to follow redirects for this discovery request.
+		// Subsequent requests (pack POSTs, object GETs) use a plain
+		// context and will not follow redirects.
+		httpReq, err := http.NewRequestWithContext(withInitialRequest(ctx), http.MethodGet, infoURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("http transport: %w", err)
+		}
 
-	httpReq.Header.Set("User-Agent", capability.DefaultAgent())
-	if !forceDumb {
-		if gp := transport.GitProtocolEnv(discoverProtocol); gp != "" {
-			httpReq.Header.Set("Git-Protocol", gp)
+		httpReq.Header.Set("User-Agent", capability.DefaultAgent())
+		if !forceDumb {
+			if gp := transport.GitProtocolEnv(discoverProtocol); gp != "" {
+				httpReq.Header.Set("Git-Protocol", gp)
+			}
 		}
-	}
-	if baseURL.User != nil {
-		password, _ := baseURL.User.Password()
-		httpReq.SetBasicAuth(baseURL.User.Username(), password)
-	}
-	if t.opts.Authorizer != nil {
-		if err := t.opts.Authorizer(httpReq); err != nil {
-			return nil, fmt.Errorf("http transport: authorize: %w", err)
+		if cred != nil {
+			httpReq.SetBasicAuth(cred.Username, cred.Password)
+		} else if baseURL.User != nil {
+			password, _ := baseURL.User.Password()
+			httpReq.SetBasicAuth(baseURL.User.Username(), password)
+		}
+		if t.opts.Authorizer != nil {
+			if err := t.opts.Authorizer(httpReq); err != nil {
+				return nil, fmt.Errorf("http transport: authorize: %w", err)
+			}
 		}
+		return httpReq, nil
+	}
+
+	httpReq, err := newRequest(nil)
+	if err != nil {
+		return nil, err
 	}
 
 	client := t.resolveClient()
 	resp, err := doRequest(client, httpReq)
+
+	// As canonical git does, only ask the credential helpers once the
+	// server has asked for authentication, and retry the request once
+	// with what they provide.
+	var cred *credential.Credential
+	if errors.Is(err, transport.ErrAuthenticationRequired) && t.useCredentialHelpers(baseURL) {
+		_ = resp.Body.Close()
+
+		cred, err = t.opts.Credentials.Fill(ctx, baseURL, resp.Header.Values("WWW-Authenticate"))
+		if err != nil {
+			return nil, fmt.Errorf("http transport: %w: %w", transport.ErrAuthenticationRequired, err)
+		}
+
+		httpReq, err = newRequest(cred)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = doRequest(client, httpReq)
+		if errors.Is(err, transport.ErrAuthenticationRequired) {
+			_ = t.opts.Credentials.Reject(ctx, cred)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("http transport: %w", err)
 	}
@@ -89,6 +123,10 @@ func (t *Transport) Handshake(ctx context.Context, req *transport.Request) (tran
 	sessReq := *req
 	sessReq.URL = redirectedURL
 	authorizer := t.opts.Authorizer
+	if cred != nil {
+		_ = t.opts.Credentials.Approve(ctx, cred)
+		authorizer = (&BasicAuth{Username: cred.Username, Password: cred.Password}).Authorizer
+	}
 
 	// Clear credentials when the redirect landed on a different host.
 	// The session stores baseURL and re-applies its User field and the
@@ -352,12 +390,13 @@ func (s *smartPackSession) fetchV2(ctx context.Context, st storage.Storer, req *
 	if req.Filter != "" && !internal.FetchSupports(s.caps, "filter") {
 		return transport.ErrFilterNotSupported
 	}
-	if req.Depth > 0 && !internal.FetchSupports(s.caps, "shallow") {
+	if req.IsShallow() && !internal.FetchSupports(s.caps, "shallow") {
 		return transport.ErrShallowNotSupported
 	}
 	if err := transport.ReconcileObjectFormatV2(st, s.caps); err != nil {
 		return err
 	}
+	req = internal.SupportedFetchRequest(s.caps, req)
 
 	round := func(args *packp.FetchArgs) (*packp.FetchOutput, io.Reader, error) {
 		r := &httpRequester{session: s, ctx: ctx}
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -69,6 +70,12 @@ func TestSmartMultiRoundFetch(t *testing.T) {
 	require.NoError(t, clientStorage.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)))
 	haves := commitHaves(t, clientStorage, oldCommit, 40)
 	require.Greater(t, len(haves), 20, "test setup must force multiple have rounds")
+	// Haves are sent from the end: a first batch unknown to the server keeps
+	// it from getting ready, which with no-done would end the negotiation in
+	// the first round.
+	for i := range 16 {
+		haves = append(haves, plumbing.NewHash(fmt.Sprintf("%040x", i+1)))
+	}
 
 	want := plumbing.NewHash(fixture.Head)
 	require.Error(t, clientStorage.HasEncodedObject(want), "seed client should not already have the remote tip")
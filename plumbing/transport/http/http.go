@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 )
 
 // contextKey is an unexported type for context keys in this package.
@@ -55,6 +57,14 @@ type Options struct {
 	// Authorizer mutates outgoing HTTP requests to add authentication.
 	Authorizer func(*http.Request) error
 
+	// Credentials provides credentials from Git credential helpers. When
+	// the server answers the initial request with 401 Unauthorized and no
+	// Authorizer is set, the helpers, and then the user through the
+	// Manager's Prompt, are asked for a username and password and the
+	// request is retried once. Credentials accepted by the server are
+	// stored back with the helpers; rejected ones are erased.
+	Credentials *credential.Manager
+
 	// HTTPProxy returns the proxy URL for a given HTTP request.
 	// If nil, the default http.Transport proxy behavior is used.
 	// Ignored when Client is set.
@@ -75,6 +85,11 @@ type Options struct {
 // Transport implements the http:// and https:// transport protocol.
 type Transport struct {
 	opts Options
+
+	// mu guards the client and the credentials used by Do.
+	mu     sync.Mutex
+	client *http.Client
+	cred   *credential.Credential
 }
 
 var _ transport.Transport = (*Transport)(nil)
@@ -107,6 +122,21 @@ func (t *Transport) resolveClient() *http.Client {
 	}
 }
 
+// useCredentialHelpers reports whether the credential helpers should be
+// asked for credentials for u. An explicit Authorizer or a password in the
+// URL always take precedence over the helpers.
+func (t *Transport) useCredentialHelpers(u *url.URL) bool {
+	if t.opts.Credentials == nil || t.opts.Authorizer != nil {
+		return false
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (o Options) redirectPolicy() RedirectPolicy {
 	if o.FollowRedirects == "" {
 		return FollowInitialRedirects
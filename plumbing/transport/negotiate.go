@@ -7,6 +7,7 @@ import (
 	"io"
 	"slices"
 
+	internal "github.com/go-git/go-git/v6/internal/transport"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
@@ -47,8 +48,11 @@ func applyServerACKs(
 	gotContinue *bool,
 	gotReady *bool,
 	inVein *int,
+	haves *internal.Haves,
 ) {
 	for _, ack := range acks {
+		haves.Ack(ack.Hash)
+
 		if !*gotContinue && ack.Status > 0 {
 			*gotContinue = true
 		}
@@ -164,11 +168,20 @@ func NegotiatePack(
 
 	upreq.Wants = req.Wants
 
-	if req.Depth > 0 {
+	if req.IsShallow() {
 		if !caps.Supports(capability.Shallow) {
 			return nil, ErrShallowNotSupported
 		}
-		upreq.Depth = packp.DepthRequest{Deepen: req.Depth}
+		if len(req.DeepenNot) > 0 && !caps.Supports(capability.DeepenNot) {
+			return nil, ErrDeepenNotNotSupported
+		}
+		if req.DeepenRelative && req.Depth > 0 {
+			if !caps.Supports(capability.DeepenRelative) {
+				return nil, ErrDeepenRelativeNotSupported
+			}
+			upreq.Capabilities.Set(capability.DeepenRelative)
+		}
+		upreq.Depth = packp.DepthRequest{Deepen: req.Depth, DeepenNot: req.DeepenNot}
 		upreq.Shallows, err = st.Shallow()
 		if err != nil {
 			return nil, err
@@ -185,6 +198,14 @@ func NegotiatePack(
 		return nil, ErrNoChange
 	}
 
+	// With no-done, a stateless server sends the packfile right after the
+	// round it got ready in, so the client does not send a last "done" round.
+	noDone := statelessRPC && multiAckDetailed && caps.Supports(capability.NoDone)
+	if noDone {
+		upreq.Capabilities.Set(capability.NoDone)
+	}
+
+	haves := internal.NewHaves(req)
 	common := map[plumbing.Hash]struct{}{}
 	var statelessCommon []plumbing.Hash
 	var inVein int
@@ -214,13 +235,16 @@ func NegotiatePack(
 			}
 		}
 
-		for i := 0; i < batchSize && len(req.Haves) > 0; i++ {
-			uphav.Haves = append(uphav.Haves, req.Haves[len(req.Haves)-1])
-			req.Haves = req.Haves[:len(req.Haves)-1]
+		for i := 0; i < batchSize; i++ {
+			have, ok := haves.Next()
+			if !ok {
+				break
+			}
+			uphav.Haves = append(uphav.Haves, have)
 			inVein++
 		}
 
-		done = sendDoneAfterReady || len(req.Haves) == 0 || (gotContinue && inVein >= maxInVein)
+		done = sendDoneAfterReady || haves.Exhausted() || (gotContinue && inVein >= maxInVein)
 		uphav.Done = done
 
 		if isSubset(req.Wants, uphav.Haves) && len(upreq.Shallows) == 0 {
@@ -269,7 +293,15 @@ func NegotiatePack(
 					readc <- fmt.Errorf("decoding server-response: %w", err)
 					return
 				}
-				applyServerACKs(statelessRPC, srvrs.ACKs, common, &statelessCommon, &gotContinue, &gotReady, &inVein)
+				applyServerACKs(statelessRPC, srvrs.ACKs, common, &statelessCommon, &gotContinue, &gotReady, &inVein, haves)
+				if noDone && gotReady && !done {
+					// The final ACK follows the NAK ending this round.
+					var final packp.ServerResponse
+					if err := final.Decode(reader); err != nil {
+						readc <- fmt.Errorf("decoding server-response: %w", err)
+						return
+					}
+				}
 			}
 			readc <- nil
 		}()
@@ -277,7 +309,7 @@ func NegotiatePack(
 		if err := <-readc; err != nil {
 			return nil, err
 		}
-		if sendDoneAfterReady {
+		if sendDoneAfterReady || (noDone && gotReady) {
 			break
 		}
 		if gotReady {
@@ -313,7 +345,7 @@ func readShallows(
 	shallowInfo **packp.ShallowUpdate,
 	firstRound bool,
 ) error {
-	if (firstRound || statelessRPC) && req.Depth > 0 {
+	if (firstRound || statelessRPC) && req.IsShallow() {
 		var shupd packp.ShallowUpdate
 		if err := shupd.Decode(r); err != nil {
 			return fmt.Errorf("decoding shallow-update: %w", err)
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	internal "github.com/go-git/go-git/v6/internal/transport"
 	"github.com/go-git/go-git/v6/plumbing"
 	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
@@ -278,7 +279,7 @@ func TestApplyServerACKs(t *testing.T) {
 			gotReady := tt.initialGotReady
 			inVein := tt.initialInVein
 
-			applyServerACKs(tt.statelessRPC, tt.acks, common, &statelessCommon, &gotContinue, &gotReady, &inVein)
+			applyServerACKs(tt.statelessRPC, tt.acks, common, &statelessCommon, &gotContinue, &gotReady, &inVein, internal.NewHaves(&FetchRequest{}))
 
 			assert.Equal(t, tt.wantGotContinue, gotContinue)
 			assert.Equal(t, tt.wantGotReady, gotReady)
@@ -338,6 +339,55 @@ func TestNegotiatePackStopsImmediatelyOnACKReady(t *testing.T) {
 	assert.Equal(t, initialFlush, strings.Count(out, "have "), "ACK_ready should stop after the first batch")
 }
 
+func TestNegotiatePackNoDone(t *testing.T) {
+	t.Parallel()
+
+	readyHash := plumbing.NewHash("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	reader := bytes.NewBuffer(nil)
+	for _, l := range []string{"ACK " + readyHash.String() + " ready\n", "NAK\n", "ACK " + readyHash.String() + "\n"} {
+		_, err := pktline.WriteString(reader, l)
+		require.NoError(t, err)
+	}
+
+	caps := capability.List{}
+	caps.Add(capability.MultiACKDetailed)
+	caps.Add(capability.NoDone)
+
+	writer := newMockWriteCloser(nil)
+	req := &FetchRequest{
+		Wants: []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")},
+		Haves: makeSyntheticHaves(40),
+	}
+
+	_, err := NegotiatePack(context.TODO(), memory.NewStorage(), caps, true, reader, writer, req)
+	require.NoError(t, err)
+
+	out := writer.writeBuf.String()
+	assert.Contains(t, out, "no-done")
+	assert.Equal(t, 1, strings.Count(out, "want "), "no-done should skip the terminal done request")
+	assert.NotContains(t, out, "0009done\n")
+	assert.Zero(t, reader.Len(), "the final ACK should be consumed")
+}
+
+func TestNegotiatePackDeepenUnsupported(t *testing.T) {
+	t.Parallel()
+
+	caps := capability.List{}
+	caps.Add(capability.Shallow)
+
+	for _, tc := range []struct {
+		req *FetchRequest
+		err error
+	}{
+		{&FetchRequest{DeepenNot: []string{"refs/heads/branch"}}, ErrDeepenNotNotSupported},
+		{&FetchRequest{Depth: 1, DeepenRelative: true}, ErrDeepenRelativeNotSupported},
+	} {
+		tc.req.Wants = []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+		_, err := NegotiatePack(context.TODO(), memory.NewStorage(), caps, true, bytes.NewBuffer(nil), newMockWriteCloser(nil), tc.req)
+		require.ErrorIs(t, err, tc.err)
+	}
+}
+
 func negotiatePackMultiRound(t *testing.T, statelessRPC bool) *mockWriteCloser {
 	t.Helper()
 
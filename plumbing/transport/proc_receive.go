@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+)
+
+// proc-receive errors.
+var (
+	ErrNoProcReceiveHook   = errors.New("no proc-receive hook")
+	ErrProcReceiveNoStatus = errors.New("proc-receive failed to report status")
+)
+
+// splitProcReceiveCommands splits cmds into those updated by receive-pack
+// and those handed to the proc-receive hook, the ones whose reference is
+// under one of prefixes.
+func splitProcReceiveCommands(prefixes []string, cmds []*packp.Command) (normal, proc []*packp.Command) {
+	if len(prefixes) == 0 {
+		return cmds, nil
+	}
+
+	for _, cmd := range cmds {
+		if matchProcReceiveRef(prefixes, cmd.Name.String()) {
+			proc = append(proc, cmd)
+		} else {
+			normal = append(normal, cmd)
+		}
+	}
+
+	return normal, proc
+}
+
+func matchProcReceiveRef(prefixes []string, name string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		rest, ok := strings.CutPrefix(name, prefix)
+		if ok && (rest == "" || rest[0] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+// procReceive runs hook on the commands of info and returns their
+// statuses, in the order of the commands. Commands the hook does not report
+// are refused, as are all of them when the hook fails.
+func procReceive(
+	ctx context.Context,
+	hook func(context.Context, *ProcReceiveInfo) ([]*packp.CommandStatus, error),
+	info *ProcReceiveInfo,
+	firstErr *error,
+) []*packp.CommandStatus {
+	var (
+		statuses []*packp.CommandStatus
+		err      = ErrNoProcReceiveHook
+	)
+	if hook != nil {
+		statuses, err = hook(ctx, info)
+	}
+
+	reported := make(map[string][]*packp.CommandStatus, len(info.Commands))
+	if err == nil {
+		for _, cs := range statuses {
+			reported[cs.ReferenceName.String()] = append(reported[cs.ReferenceName.String()], cs)
+		}
+	}
+
+	result := make([]*packp.CommandStatus, 0, len(info.Commands))
+	for _, cmd := range info.Commands {
+		css := reported[cmd.Name.String()]
+		if len(css) == 0 {
+			reason := ErrProcReceiveNoStatus
+			if err != nil {
+				reason = err
+			}
+			css = []*packp.CommandStatus{{ReferenceName: cmd.Name, Status: reason.Error()}}
+		}
+
+		for _, cs := range css {
+			if csErr := cs.Error(); csErr != nil && *firstErr == nil {
+				*firstErr = csErr
+			}
+		}
+		result = append(result, css...)
+	}
+
+	return result
+}
+
+// procReceiveApplied returns the updates the proc-receive hook reported to
+// have made for cmds, with the references and values it updated in their
+// place.
+func procReceiveApplied(cmds []*packp.Command, statuses []*packp.CommandStatus) []*packp.Command {
+	byName := make(map[string]*packp.Command, len(cmds))
+	for _, cmd := range cmds {
+		byName[cmd.Name.String()] = cmd
+	}
+
+	var applied []*packp.Command
+	for _, cs := range statuses {
+		cmd, ok := byName[cs.ReferenceName.String()]
+		if !ok || cs.Error() != nil {
+			continue
+		}
+
+		update := &packp.Command{Name: cmd.Name, Old: cmd.Old, New: cmd.New}
+		if cs.RefName != "" {
+			update.Name = cs.RefName
+		}
+		if cs.OldHash != nil {
+			update.Old = *cs.OldHash
+		}
+		if cs.NewHash != nil {
+			update.New = *cs.NewHash
+		}
+		applied = append(applied, update)
+	}
+
+	return applied
+}
@@ -0,0 +1,220 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// procReceiveRequest builds a wire-format receive-pack body creating ref
+// with an empty packfile, advertising reportStatus.
+func procReceiveRequest(t *testing.T, reportStatus capability.Capability, cmds ...*packp.Command) io.ReadCloser {
+	t.Helper()
+
+	req := &packp.UpdateRequests{Commands: cmds}
+	req.Capabilities.Add(reportStatus)
+
+	var buf bytes.Buffer
+	require.NoError(t, req.Encode(&buf))
+	_, err := packfile.NewEncoder(&buf, memory.NewStorage(), false).Encode(nil, 0)
+	require.NoError(t, err)
+	return io.NopCloser(&buf)
+}
+
+func procReceiveStorage(t *testing.T) storage.Storer {
+	t.Helper()
+	st := memory.NewStorage()
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.Receive.ProcReceiveRefs = []string{"refs/for"}
+	require.NoError(t, st.SetConfig(cfg))
+	return st
+}
+
+func decodeReportStatus(t *testing.T, r io.Reader) *packp.ReportStatus {
+	t.Helper()
+	rs := &packp.ReportStatus{}
+	require.NoError(t, rs.Decode(r))
+	return rs
+}
+
+// agitHook updates refs/changes/1 in place of the refs/for/* commands.
+func agitHook(_ context.Context, info *ProcReceiveInfo) ([]*packp.CommandStatus, error) {
+	var statuses []*packp.CommandStatus
+	for _, cmd := range info.Commands {
+		change := plumbing.ReferenceName("refs/changes/1")
+		if err := info.Storer.SetReference(plumbing.NewHashReference(change, cmd.New)); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, &packp.CommandStatus{
+			ReferenceName: cmd.Name,
+			Status:        "ok",
+			RefName:       change,
+		})
+	}
+	return statuses, nil
+}
+
+func TestReceivePackProcReceive(t *testing.T) {
+	t.Parallel()
+
+	st := procReceiveStorage(t)
+	hash := plumbing.NewHash(receivePackTestHash)
+	forRef := plumbing.ReferenceName("refs/for/main/topic")
+	headRef := plumbing.ReferenceName("refs/heads/topic")
+
+	var (
+		out    bytes.Buffer
+		procIn *ProcReceiveInfo
+		post   *PostReceiveInfo
+	)
+	err := ReceivePack(
+		context.Background(),
+		st,
+		procReceiveRequest(t, capability.ReportStatusV2,
+			&packp.Command{Name: forRef, New: hash},
+			&packp.Command{Name: headRef, New: hash},
+		),
+		ioutil.WriteNopCloser(&out),
+		&ReceivePackRequest{
+			StatelessRPC: true,
+			Hooks: ReceivePackHooks{
+				ProcReceive: func(ctx context.Context, info *ProcReceiveInfo) ([]*packp.CommandStatus, error) {
+					procIn = info
+					return agitHook(ctx, info)
+				},
+				PostReceive: func(_ context.Context, info *PostReceiveInfo) error {
+					post = info
+					return nil
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.NotNil(t, procIn)
+	require.Len(t, procIn.Commands, 1)
+	assert.Equal(t, forRef, procIn.Commands[0].Name)
+
+	_, err = st.Reference(forRef)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	ref, err := st.Reference("refs/changes/1")
+	require.NoError(t, err)
+	assert.Equal(t, hash, ref.Hash())
+	_, err = st.Reference(headRef)
+	assert.NoError(t, err)
+
+	require.NotNil(t, post)
+	var applied []plumbing.ReferenceName
+	for _, cmd := range post.Commands {
+		applied = append(applied, cmd.Name)
+	}
+	assert.ElementsMatch(t, []plumbing.ReferenceName{headRef, "refs/changes/1"}, applied)
+
+	rs := decodeReportStatus(t, &out)
+	require.NoError(t, rs.Error())
+	require.Len(t, rs.CommandStatuses, 2)
+	for _, cs := range rs.CommandStatuses {
+		if cs.ReferenceName == forRef {
+			assert.Equal(t, plumbing.ReferenceName("refs/changes/1"), cs.RefName)
+		} else {
+			assert.False(t, cs.HasOptions())
+		}
+	}
+}
+
+func TestReceivePackProcReceiveReportStatusV1(t *testing.T) {
+	t.Parallel()
+
+	st := procReceiveStorage(t)
+	forRef := plumbing.ReferenceName("refs/for/main")
+
+	var out bytes.Buffer
+	err := ReceivePack(
+		context.Background(),
+		st,
+		procReceiveRequest(t, capability.ReportStatus,
+			&packp.Command{Name: forRef, New: plumbing.NewHash(receivePackTestHash)},
+		),
+		ioutil.WriteNopCloser(&out),
+		&ReceivePackRequest{
+			StatelessRPC: true,
+			Hooks:        ReceivePackHooks{ProcReceive: agitHook},
+		},
+	)
+	require.NoError(t, err)
+
+	rs := decodeReportStatus(t, &out)
+	require.NoError(t, rs.Error())
+	require.Len(t, rs.CommandStatuses, 1)
+	assert.Equal(t, forRef, rs.CommandStatuses[0].ReferenceName)
+	assert.False(t, rs.CommandStatuses[0].HasOptions())
+}
+
+func TestReceivePackProcReceiveRefused(t *testing.T) {
+	t.Parallel()
+
+	forRef := plumbing.ReferenceName("refs/for/main")
+	hookErr := errors.New("review required")
+
+	for _, tc := range []struct {
+		name     string
+		hook     func(context.Context, *ProcReceiveInfo) ([]*packp.CommandStatus, error)
+		expected error
+	}{
+		{"no hook", nil, ErrNoProcReceiveHook},
+		{"hook error", func(context.Context, *ProcReceiveInfo) ([]*packp.CommandStatus, error) {
+			return nil, hookErr
+		}, hookErr},
+		{"no status", func(context.Context, *ProcReceiveInfo) ([]*packp.CommandStatus, error) {
+			return nil, nil
+		}, ErrProcReceiveNoStatus},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			err := ReceivePack(
+				context.Background(),
+				procReceiveStorage(t),
+				procReceiveRequest(t, capability.ReportStatusV2,
+					&packp.Command{Name: forRef, New: plumbing.NewHash(receivePackTestHash)},
+				),
+				ioutil.WriteNopCloser(&out),
+				&ReceivePackRequest{
+					StatelessRPC: true,
+					Hooks:        ReceivePackHooks{ProcReceive: tc.hook},
+				},
+			)
+			require.Error(t, err)
+
+			rs := decodeReportStatus(t, &out)
+			require.Len(t, rs.CommandStatuses, 1)
+			assert.Equal(t, forRef, rs.CommandStatuses[0].ReferenceName)
+			assert.Equal(t, tc.expected.Error(), rs.CommandStatuses[0].Status)
+		})
+	}
+}
+
+func TestMatchProcReceiveRef(t *testing.T) {
+	t.Parallel()
+
+	prefixes := []string{"refs/for", "refs/drafts/"}
+	assert.True(t, matchProcReceiveRef(prefixes, "refs/for/main"))
+	assert.True(t, matchProcReceiveRef(prefixes, "refs/drafts/main"))
+	assert.False(t, matchProcReceiveRef(prefixes, "refs/foreign/main"))
+	assert.False(t, matchProcReceiveRef(prefixes, "refs/heads/main"))
+}
@@ -41,6 +41,12 @@ func SendPack(
 	}
 
 	upreq := buildUpdateRequests(caps, req)
+	if req.Signer != nil {
+		if err := signPushCert(ctx, caps, upreq, req); err != nil {
+			return err
+		}
+	}
+
 	if err := upreq.Encode(writer); err != nil {
 		return err
 	}
@@ -98,6 +104,10 @@ func SendPack(
 		return fmt.Errorf("decode report-status: %w", err)
 	}
 
+	if req.Report != nil {
+		*req.Report = *report
+	}
+
 	reportError := report.Error()
 
 	if reportStatus > 0 && len(upreq.Commands) > 0 {
@@ -124,7 +134,9 @@ func SendPack(
 func buildUpdateRequests(caps capability.List, req *PushRequest) *packp.UpdateRequests {
 	upreq := &packp.UpdateRequests{}
 
-	if caps.Supports(capability.ReportStatus) {
+	if caps.Supports(capability.ReportStatusV2) {
+		upreq.Capabilities.Set(capability.ReportStatusV2)
+	} else if caps.Supports(capability.ReportStatus) {
 		upreq.Capabilities.Set(capability.ReportStatus)
 	}
 	if req.Progress != nil {
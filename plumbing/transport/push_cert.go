@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+)
+
+// Push certificate errors.
+var (
+	ErrPushCertNotSupported = errors.New("server does not support signed pushes")
+	ErrNoPushCertVerifier   = errors.New("no push certificate verifier")
+	ErrInconsistentPushOpts = errors.New("inconsistent push options")
+)
+
+// Signer signs push certificates. The signers of the git package, which sign
+// objects, implement it.
+type Signer interface {
+	Sign(ctx context.Context, message io.Reader) ([]byte, error)
+}
+
+// PushCertNonceStatus is the outcome of the check of the nonce of a push
+// certificate, named as git's GIT_PUSH_CERT_NONCE_STATUS values.
+type PushCertNonceStatus string
+
+const (
+	// PushCertNonceUnsolicited is the status of a nonce the server did not
+	// ask for, as it does not advertise push-cert.
+	PushCertNonceUnsolicited PushCertNonceStatus = "UNSOLICITED"
+	// PushCertNonceMissing is the status of a certificate without the
+	// nonce the server asked for.
+	PushCertNonceMissing PushCertNonceStatus = "MISSING"
+	// PushCertNonceBad is the status of a nonce the server did not issue.
+	PushCertNonceBad PushCertNonceStatus = "BAD"
+	// PushCertNonceOK is the status of the nonce the server asked for.
+	PushCertNonceOK PushCertNonceStatus = "OK"
+	// PushCertNonceSlop is the status of a nonce a stateless server issued
+	// longer ago than receive.certNonceSlop allows.
+	PushCertNonceSlop PushCertNonceStatus = "SLOP"
+)
+
+// PushCertInfo describes the push certificate of a signed push.
+type PushCertInfo struct {
+	// Certificate is the certificate the client sent.
+	Certificate *packp.PushCertificate
+	// NonceStatus is the outcome of the check of the nonce of Certificate.
+	NonceStatus PushCertNonceStatus
+	// Signature describes the signature of Certificate, if it is valid.
+	Signature *object.VerifiedSignature
+	// SignatureErr is why the signature of Certificate is not valid.
+	SignatureErr error
+}
+
+// Verified reports whether the certificate has a valid signature and the
+// nonce the server asked for, so it can be trusted to record who requested
+// the push.
+func (i *PushCertInfo) Verified() bool {
+	return i.Signature != nil && i.NonceStatus == PushCertNonceOK
+}
+
+// pushCertNonce returns the nonce advertised with push-cert at stamp, or an
+// empty string when receive.certNonceSeed is not set. As in git, it is the
+// time stamp followed by its HMAC keyed with the seed, so a stateless server
+// can check the nonces it issued in earlier requests.
+func pushCertNonce(cfg *config.Config, stamp int64) string {
+	if cfg.Receive.CertNonceSeed == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha1.New, []byte(cfg.Receive.CertNonceSeed))
+	_, _ = mac.Write([]byte(strconv.FormatInt(stamp, 10)))
+	return fmt.Sprintf("%d-%s", stamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// checkPushCertNonce checks the nonce of a push certificate against nonce,
+// the one the server advertised at stamp.
+func checkPushCertNonce(cfg *config.Config, certNonce, nonce string, stamp int64, statelessRPC bool) PushCertNonceStatus {
+	switch {
+	case nonce == "":
+		return PushCertNonceUnsolicited
+	case certNonce == "":
+		return PushCertNonceMissing
+	case certNonce == nonce:
+		return PushCertNonceOK
+	case !statelessRPC:
+		return PushCertNonceBad
+	}
+
+	// A stateless server advertised the nonce in an earlier request.
+	s, _, ok := strings.Cut(certNonce, "-")
+	if !ok {
+		return PushCertNonceBad
+	}
+	certStamp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || !hmac.Equal([]byte(pushCertNonce(cfg, certStamp)), []byte(certNonce)) {
+		return PushCertNonceBad
+	}
+
+	slop := stamp - certStamp
+	if slop < 0 {
+		slop = -slop
+	}
+	if cfg.Receive.CertNonceSlop > 0 && slop <= int64(cfg.Receive.CertNonceSlop) {
+		return PushCertNonceOK
+	}
+	return PushCertNonceSlop
+}
+
+// checkPushCert checks the nonce and the signature of cert, the nonce
+// advertised at stamp being nonce.
+func checkPushCert(
+	ctx context.Context,
+	cfg *config.Config,
+	cert *packp.PushCertificate,
+	nonce string,
+	stamp int64,
+	opts *ReceivePackRequest,
+) *PushCertInfo {
+	info := &PushCertInfo{
+		Certificate: cert,
+		NonceStatus: checkPushCertNonce(cfg, cert.Nonce, nonce, stamp, opts.StatelessRPC),
+	}
+
+	if opts.PushCertVerifier == nil {
+		info.SignatureErr = ErrNoPushCertVerifier
+		return info
+	}
+
+	var pusher object.Signature
+	pusher.Decode([]byte(cert.Pusher))
+	p := object.NewSignedPayload(cert.Payload(), cert.Signature, pusher)
+	info.Signature, info.SignatureErr = opts.PushCertVerifier.Verify(ctx, p)
+	return info
+}
+
+// signPushCert signs a push certificate of the commands of req, sent in
+// their place in upreq.
+func signPushCert(ctx context.Context, caps capability.List, upreq *packp.UpdateRequests, req *PushRequest) error {
+	nonces := caps.Get(capability.PushCert)
+	if len(nonces) == 0 {
+		return ErrPushCertNotSupported
+	}
+
+	cert := &packp.PushCertificate{
+		Pusher:   req.Pusher,
+		Pushee:   req.Pushee,
+		Nonce:    nonces[0],
+		Commands: req.Commands,
+	}
+	if upreq.Capabilities.Supports(capability.PushOptions) {
+		cert.Options = req.Options
+	}
+
+	sig, err := req.Signer.Sign(ctx, bytes.NewReader(cert.Payload()))
+	if err != nil {
+		return fmt.Errorf("signing push certificate: %w", err)
+	}
+	cert.Signature = sig
+	upreq.Certificate = cert
+
+	return nil
+}
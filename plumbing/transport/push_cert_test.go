@@ -0,0 +1,287 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+type stubSigner struct{ payload []byte }
+
+func (s *stubSigner) Sign(_ context.Context, message io.Reader) ([]byte, error) {
+	var err error
+	s.payload, err = io.ReadAll(message)
+	return []byte("-----BEGIN PGP SIGNATURE-----\n\nstub\n-----END PGP SIGNATURE-----\n"), err
+}
+
+type stubVerifier struct{ payload *object.SignedPayload }
+
+func (v *stubVerifier) Verify(_ context.Context, p *object.SignedPayload) (*object.VerifiedSignature, error) {
+	v.payload = p
+	return &object.VerifiedSignature{Signer: "A U Thor <author@example.com>"}, nil
+}
+
+func pushCertConfig(t *testing.T, st storage.Storer) *config.Config {
+	t.Helper()
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.Receive.CertNonceSeed = "seed"
+	cfg.Receive.CertNonceSlop = 60
+	require.NoError(t, st.SetConfig(cfg))
+	return cfg
+}
+
+// signedPushRequest builds a wire-format receive-pack body whose commands
+// are sent in a push certificate, followed by opts as push options.
+func signedPushRequest(t *testing.T, cert *packp.PushCertificate, opts ...string) io.ReadCloser {
+	t.Helper()
+
+	req := &packp.UpdateRequests{Commands: cert.Commands, Certificate: cert}
+	req.Capabilities.Add(capability.ReportStatus)
+	req.Capabilities.Add(capability.PushOptions)
+
+	var buf bytes.Buffer
+	require.NoError(t, req.Encode(&buf))
+	require.NoError(t, (&packp.PushOptions{Options: opts}).Encode(&buf))
+	return io.NopCloser(&buf)
+}
+
+func TestCheckPushCertNonce(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewConfig()
+	cfg.Receive.CertNonceSeed = "seed"
+	cfg.Receive.CertNonceSlop = 60
+
+	const stamp = 1700000000
+	nonce := pushCertNonce(cfg, stamp)
+	assert.Regexp(t, `^1700000000-[0-9a-f]{40}$`, nonce)
+
+	for _, tc := range []struct {
+		name      string
+		certNonce string
+		nonce     string
+		stateless bool
+		expected  PushCertNonceStatus
+	}{
+		{"unsolicited", nonce, "", false, PushCertNonceUnsolicited},
+		{"missing", "", nonce, false, PushCertNonceMissing},
+		{"ok", nonce, nonce, false, PushCertNonceOK},
+		{"bad", "1700000000-deadbeef", nonce, false, PushCertNonceBad},
+		{"stateful earlier nonce", pushCertNonce(cfg, stamp-10), nonce, false, PushCertNonceBad},
+		{"stateless earlier nonce", pushCertNonce(cfg, stamp-10), nonce, true, PushCertNonceOK},
+		{"stateless stale nonce", pushCertNonce(cfg, stamp-120), nonce, true, PushCertNonceSlop},
+		{"stateless forged nonce", "1699999990-deadbeef", nonce, true, PushCertNonceBad},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, checkPushCertNonce(cfg, tc.certNonce, tc.nonce, stamp, tc.stateless))
+		})
+	}
+}
+
+func TestAdvertiseRefsPushCert(t *testing.T) {
+	t.Parallel()
+
+	ref := plumbing.ReferenceName("refs/heads/main")
+	st := seedRef(t, ref, plumbing.NewHash(receivePackTestHash))
+
+	var out bytes.Buffer
+	err := ReceivePack(context.Background(), st, nil, ioutil.WriteNopCloser(&out), &ReceivePackRequest{
+		AdvertiseRefs: true,
+		StatelessRPC:  true,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "push-cert")
+
+	pushCertConfig(t, st)
+	out.Reset()
+	err = ReceivePack(context.Background(), st, nil, ioutil.WriteNopCloser(&out), &ReceivePackRequest{
+		AdvertiseRefs: true,
+		StatelessRPC:  true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "push-cert=")
+}
+
+func TestReceivePackPushCert(t *testing.T) {
+	t.Parallel()
+
+	ref := plumbing.ReferenceName("refs/heads/main")
+	hash := plumbing.NewHash(receivePackTestHash)
+	st := seedRef(t, ref, hash)
+	cfg := pushCertConfig(t, st)
+
+	cert := &packp.PushCertificate{
+		Pusher:    "A U Thor <author@example.com> 1700000000 +0000",
+		Pushee:    "https://example.com/repo.git",
+		Nonce:     pushCertNonce(cfg, time.Now().Unix()),
+		Options:   []string{"ci.skip"},
+		Commands:  []*packp.Command{deleteCmd(ref, hash)},
+		Signature: []byte("-----BEGIN PGP SIGNATURE-----\n\nstub\n-----END PGP SIGNATURE-----\n"),
+	}
+
+	var (
+		out      bytes.Buffer
+		info     *PreReceiveInfo
+		verifier stubVerifier
+	)
+	err := ReceivePack(
+		context.Background(),
+		st,
+		signedPushRequest(t, cert, "ci.skip"),
+		ioutil.WriteNopCloser(&out),
+		&ReceivePackRequest{
+			StatelessRPC:     true,
+			PushCertVerifier: &verifier,
+			Hooks: ReceivePackHooks{
+				PreReceive: func(_ context.Context, i *PreReceiveInfo) error {
+					info = i
+					return nil
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "ok refs/heads/main")
+
+	require.NotNil(t, info)
+	require.NotNil(t, info.PushCert)
+	assert.Equal(t, PushCertNonceOK, info.PushCert.NonceStatus)
+	assert.NoError(t, info.PushCert.SignatureErr)
+	assert.True(t, info.PushCert.Verified())
+	assert.Equal(t, cert.Pusher, info.PushCert.Certificate.Pusher)
+	assert.Equal(t, []string{"ci.skip"}, info.PushOptions)
+
+	require.NotNil(t, verifier.payload)
+	assert.Equal(t, "author@example.com", verifier.payload.Signer.Email)
+}
+
+func TestReceivePackPushCertWithoutVerifier(t *testing.T) {
+	t.Parallel()
+
+	ref := plumbing.ReferenceName("refs/heads/main")
+	hash := plumbing.NewHash(receivePackTestHash)
+	st := seedRef(t, ref, hash)
+
+	cert := &packp.PushCertificate{
+		Pusher:   "A U Thor <author@example.com> 1700000000 +0000",
+		Nonce:    "1700000000-deadbeef",
+		Commands: []*packp.Command{deleteCmd(ref, hash)},
+	}
+
+	var info *PreReceiveInfo
+	err := ReceivePack(
+		context.Background(),
+		st,
+		signedPushRequest(t, cert),
+		ioutil.WriteNopCloser(&bytes.Buffer{}),
+		&ReceivePackRequest{
+			StatelessRPC: true,
+			Hooks: ReceivePackHooks{
+				PreReceive: func(_ context.Context, i *PreReceiveInfo) error {
+					info = i
+					return nil
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.NotNil(t, info.PushCert)
+	assert.Equal(t, PushCertNonceUnsolicited, info.PushCert.NonceStatus)
+	assert.ErrorIs(t, info.PushCert.SignatureErr, ErrNoPushCertVerifier)
+	assert.False(t, info.PushCert.Verified())
+}
+
+func TestReceivePackPushCertInconsistentOptions(t *testing.T) {
+	t.Parallel()
+
+	ref := plumbing.ReferenceName("refs/heads/main")
+	hash := plumbing.NewHash(receivePackTestHash)
+	st := seedRef(t, ref, hash)
+
+	cert := &packp.PushCertificate{
+		Pusher:   "A U Thor <author@example.com> 1700000000 +0000",
+		Options:  []string{"ci.skip"},
+		Commands: []*packp.Command{deleteCmd(ref, hash)},
+	}
+
+	var out bytes.Buffer
+	err := ReceivePack(
+		context.Background(),
+		st,
+		signedPushRequest(t, cert, "deploy"),
+		ioutil.WriteNopCloser(&out),
+		&ReceivePackRequest{StatelessRPC: true},
+	)
+	require.ErrorIs(t, err, ErrInconsistentPushOpts)
+	assert.Contains(t, out.String(), "ng refs/heads/main")
+
+	_, err = st.Reference(ref)
+	assert.NoError(t, err)
+}
+
+func TestSendPackSigned(t *testing.T) {
+	t.Parallel()
+
+	caps := capability.List{}
+	caps.Add(capability.ReportStatus)
+	caps.Add(capability.PushCert, "1700000000-abcdef")
+
+	reader := io.NopCloser(bytes.NewBufferString("000eunpack ok\n0019ok refs/heads/master\n0000"))
+	writer := newMockWriteCloser(nil)
+
+	var signer stubSigner
+	req := &PushRequest{
+		Commands: []*packp.Command{{
+			Name: plumbing.ReferenceName("refs/heads/master"),
+			Old:  plumbing.NewHash(receivePackTestHash),
+			New:  plumbing.ZeroHash,
+		}},
+		Signer: &signer,
+		Pusher: "A U Thor <author@example.com> 1700000000 +0000",
+		Pushee: "https://example.com/repo.git",
+	}
+	require.NoError(t, SendPack(context.TODO(), nil, caps, writer, reader, req))
+
+	upreq := &packp.UpdateRequests{}
+	require.NoError(t, upreq.Decode(writer.writeBuf))
+	require.NotNil(t, upreq.Certificate)
+	assert.Equal(t, "1700000000-abcdef", upreq.Certificate.Nonce)
+	assert.Equal(t, req.Pushee, upreq.Certificate.Pushee)
+	assert.Equal(t, req.Commands, upreq.Commands)
+	assert.Equal(t, string(signer.payload), string(upreq.Certificate.Payload()))
+}
+
+func TestSendPackSignedNotSupported(t *testing.T) {
+	t.Parallel()
+
+	caps := capability.List{}
+	caps.Add(capability.ReportStatus)
+
+	req := &PushRequest{
+		Commands: []*packp.Command{{
+			Name: plumbing.ReferenceName("refs/heads/master"),
+			Old:  plumbing.NewHash(receivePackTestHash),
+			New:  plumbing.ZeroHash,
+		}},
+		Signer: &stubSigner{},
+	}
+	err := SendPack(context.TODO(), nil, caps, newMockWriteCloser(nil), io.NopCloser(&bytes.Buffer{}), req)
+	assert.True(t, errors.Is(err, ErrPushCertNotSupported))
+}
@@ -5,10 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
@@ -26,6 +29,12 @@ type ReceivePackRequest struct {
 
 	// Hooks are optional server-side callbacks. The zero value installs none.
 	Hooks ReceivePackHooks
+
+	// PushCertVerifier verifies the signatures of the push certificates of
+	// signed pushes, which clients only send when receive.certNonceSeed is
+	// set. The outcome is reported to the hooks, which decide whether to
+	// accept unsigned or unverified pushes.
+	PushCertVerifier object.SignatureVerifier
 }
 
 // ReceivePackHooks holds server-side callbacks for ReceivePack.
@@ -42,6 +51,14 @@ type ReceivePackHooks struct {
 	// not run.
 	PreReceive func(context.Context, *PreReceiveInfo) error
 
+	// ProcReceive handles the updates of the references matching the
+	// prefixes of receive.procReceiveRefs, as git's proc-receive hook does,
+	// in place of the default update. It runs after PreReceive and reports
+	// one or more statuses per command, whose options describe the
+	// references it updated in their place. Commands it does not report
+	// are refused, as are all of them if it returns an error.
+	ProcReceive func(context.Context, *ProcReceiveInfo) ([]*packp.CommandStatus, error)
+
 	// PostReceive runs after refs are updated. Any returned error is ignored
 	// for transport purposes: the refs have already moved and the
 	// report-status sent to the client reflects the ref-update outcome, not
@@ -61,6 +78,26 @@ type PreReceiveInfo struct {
 	// Progress writes to the client's sideband progress channel (band 2) when
 	// negotiated, or is io.Discard otherwise. Valid only during the call.
 	Progress io.Writer
+	// PushCert describes the push certificate of a signed push, nil for an
+	// unsigned one.
+	PushCert *PushCertInfo
+}
+
+// ProcReceiveInfo carries the inputs to a ProcReceive hook.
+type ProcReceiveInfo struct {
+	// Storer reads the proposed new state and records the updates the hook
+	// makes.
+	Storer storage.Storer
+	// Commands are the ref updates handed to the hook. Treat as read-only.
+	Commands []*packp.Command
+	// PushOptions are the client's push options (empty if none).
+	PushOptions []string
+	// Progress writes to the client's sideband progress channel (band 2) when
+	// negotiated, or is io.Discard otherwise. Valid only during the call.
+	Progress io.Writer
+	// PushCert describes the push certificate of a signed push, nil for an
+	// unsigned one.
+	PushCert *PushCertInfo
 }
 
 // PostReceiveInfo carries the inputs to a PostReceive hook.
@@ -75,6 +112,9 @@ type PostReceiveInfo struct {
 	// Progress writes to the client's sideband progress channel (band 2) when
 	// negotiated, or is io.Discard otherwise. Valid only during the call.
 	Progress io.Writer
+	// PushCert describes the push certificate of a signed push, nil for an
+	// unsigned one.
+	PushCert *PushCertInfo
 }
 
 // ReceivePack is a server command that serves the receive-pack service.
@@ -95,6 +135,10 @@ func ReceivePack(
 		opts = &ReceivePackRequest{}
 	}
 
+	cfg := repositoryConfig(st)
+	stamp := time.Now().Unix()
+	nonce := pushCertNonce(cfg, stamp)
+
 	if opts.AdvertiseRefs || !opts.StatelessRPC {
 		v := ProtocolVersion(opts.GitProtocol)
 		switch v {
@@ -105,7 +149,7 @@ func ReceivePack(
 			return fmt.Errorf("%w: %q", ErrUnsupportedVersion, v)
 		}
 
-		if err := AdvertiseRefs(ctx, st, w, ReceivePackService, opts.StatelessRPC, v); err != nil {
+		if err := advertiseRefs(ctx, st, w, ReceivePackService, opts.StatelessRPC, v, nonce); err != nil {
 			return err
 		}
 	}
@@ -150,6 +194,11 @@ func ReceivePack(
 		}
 	}
 
+	var pushCert *PushCertInfo
+	if updreq.Certificate != nil {
+		pushCert = checkPushCert(ctx, cfg, updreq.Certificate, nonce, stamp, opts)
+	}
+
 	// Should we expect a packfile?
 	for _, cmd := range updreq.Commands {
 		if cmd.Action() != packp.Delete {
@@ -196,61 +245,89 @@ func ReceivePack(
 
 	writeCloser := ioutil.NewWriteCloser(writer, w)
 	if unpackErr != nil {
-		res := sendReportStatus(writeCloser, unpackErr, nil)
+		res := sendReportStatus(writeCloser, unpackErr, nil, nil, false)
 		_ = closeWriter(w)
 		return res
 	}
 
+	// refuse refuses every ref with refuseErr as the report-status reason.
+	refuse := func(refuseErr error) error {
+		rejected := make(map[plumbing.ReferenceName]error, len(updreq.Commands))
+		for _, cmd := range updreq.Commands {
+			rejected[cmd.Name] = refuseErr
+		}
+		if err := sendReportStatus(writeCloser, nil, rejected, nil, false); err != nil {
+			_ = closeWriter(w)
+			return err
+		}
+		if useSideband {
+			if err := pktline.WriteFlush(w); err != nil {
+				_ = closeWriter(w)
+				return fmt.Errorf("flushing sideband: %w", err)
+			}
+		}
+		if err := closeWriter(w); err != nil {
+			return err
+		}
+		return refuseErr
+	}
+
+	// The push options are not signed, so they must match those the
+	// certificate records.
+	if pushCert != nil && !slices.Equal(pushCert.Certificate.Options, pushOpts.Options) {
+		return refuse(ErrInconsistentPushOpts)
+	}
+
 	if opts.Hooks.PreReceive != nil {
 		info := &PreReceiveInfo{
 			Storer:      st,
 			Commands:    updreq.Commands,
 			PushOptions: pushOpts.Options,
 			Progress:    progress,
+			PushCert:    pushCert,
 		}
 		if hookErr := opts.Hooks.PreReceive(ctx, info); hookErr != nil {
-			rejected := make(map[plumbing.ReferenceName]error, len(updreq.Commands))
-			for _, cmd := range updreq.Commands {
-				rejected[cmd.Name] = hookErr
-			}
-			if err := sendReportStatus(writeCloser, nil, rejected); err != nil {
-				_ = closeWriter(w)
-				return err
-			}
-			if useSideband {
-				if err := pktline.WriteFlush(w); err != nil {
-					_ = closeWriter(w)
-					return fmt.Errorf("flushing sideband: %w", err)
-				}
-			}
-			if err := closeWriter(w); err != nil {
-				return err
-			}
-			return hookErr
+			return refuse(hookErr)
 		}
 	}
 
 	var firstErr error
 	cmdStatus := make(map[plumbing.ReferenceName]error)
-	updateReferences(st, updreq, cmdStatus, &firstErr)
+	cmds, procCmds := splitProcReceiveCommands(cfg.Receive.ProcReceiveRefs, updreq.Commands)
+	updateReferences(st, cmds, cmdStatus, &firstErr)
+
+	var procStatus []*packp.CommandStatus
+	if len(procCmds) > 0 {
+		info := &ProcReceiveInfo{
+			Storer:      st,
+			Commands:    procCmds,
+			PushOptions: pushOpts.Options,
+			Progress:    progress,
+			PushCert:    pushCert,
+		}
+		procStatus = procReceive(ctx, opts.Hooks.ProcReceive, info, &firstErr)
+	}
 
 	if opts.Hooks.PostReceive != nil {
 		applied := make([]*packp.Command, 0, len(updreq.Commands))
-		for _, cmd := range updreq.Commands {
+		for _, cmd := range cmds {
 			if cmdStatus[cmd.Name] == nil {
 				applied = append(applied, cmd)
 			}
 		}
+		applied = append(applied, procReceiveApplied(procCmds, procStatus)...)
 		info := &PostReceiveInfo{
 			Storer:      st,
 			Commands:    applied,
 			PushOptions: pushOpts.Options,
 			Progress:    progress,
+			PushCert:    pushCert,
 		}
 		_ = opts.Hooks.PostReceive(ctx, info)
 	}
 
-	if err := sendReportStatus(writeCloser, firstErr, cmdStatus); err != nil {
+	v2 := caps.Supports(capability.ReportStatusV2)
+	if err := sendReportStatus(writeCloser, firstErr, cmdStatus, procStatus, v2); err != nil {
 		return err
 	}
 
@@ -278,7 +355,17 @@ func closeWriter(w io.WriteCloser) error {
 	return nil
 }
 
-func sendReportStatus(w io.WriteCloser, unpackErr error, cmdStatus map[plumbing.ReferenceName]error) error {
+// sendReportStatus reports the outcome of the commands, those handed to
+// the proc-receive hook being reported by procStatus. Their options are
+// only sent with report-status-v2, a report-status client getting the
+// first status of each command.
+func sendReportStatus(
+	w io.WriteCloser,
+	unpackErr error,
+	cmdStatus map[plumbing.ReferenceName]error,
+	procStatus []*packp.CommandStatus,
+	v2 bool,
+) error {
 	rs := &packp.ReportStatus{}
 	rs.UnpackStatus = "ok"
 	if unpackErr != nil {
@@ -297,6 +384,18 @@ func sendReportStatus(w io.WriteCloser, unpackErr error, cmdStatus map[plumbing.
 		rs.CommandStatuses = append(rs.CommandStatuses, status)
 	}
 
+	reported := make(map[plumbing.ReferenceName]bool, len(procStatus))
+	for _, cs := range procStatus {
+		if !v2 {
+			if reported[cs.ReferenceName] {
+				continue
+			}
+			cs = &packp.CommandStatus{ReferenceName: cs.ReferenceName, Status: cs.Status}
+		}
+		reported[cs.ReferenceName] = true
+		rs.CommandStatuses = append(rs.CommandStatuses, cs)
+	}
+
 	if err := rs.Encode(w); err != nil {
 		return err
 	}
@@ -320,8 +419,8 @@ func referenceExists(s storer.ReferenceStorer, n plumbing.ReferenceName) (bool,
 	return err == nil, err
 }
 
-func updateReferences(st storage.Storer, req *packp.UpdateRequests, cmdStatus map[plumbing.ReferenceName]error, firstErr *error) {
-	for _, cmd := range req.Commands {
+func updateReferences(st storage.Storer, cmds []*packp.Command, cmdStatus map[plumbing.ReferenceName]error, firstErr *error) {
+	for _, cmd := range cmds {
 		exists, err := referenceExists(st, cmd.Name)
 		if err != nil {
 			setStatus(cmdStatus, firstErr, cmd.Name, err)
@@ -44,6 +44,39 @@ func TestSendPackWithReportStatus(t *testing.T) {
 	assert.True(t, writer.closed)
 }
 
+func TestSendPackWithReportStatusV2(t *testing.T) {
+	t.Parallel()
+	caps := capability.List{}
+	caps.Add(capability.ReportStatusV2)
+
+	reportStatusResponse := strings.Join([]string{
+		"000eunpack ok\n",
+		"001dok refs/for/master/topic\n",
+		"0029option refname refs/changes/24/124/1\n",
+		"0019ok refs/heads/master\n",
+		"0000",
+	}, "")
+	reader := io.NopCloser(bytes.NewReader([]byte(reportStatusResponse)))
+	writer := newMockWriteCloser(nil)
+
+	req := &PushRequest{
+		Commands: []*packp.Command{
+			{
+				Name: plumbing.ReferenceName("refs/for/master/topic"),
+				Old:  plumbing.NewHash("0123456789012345678901234567890123456789"),
+				New:  plumbing.ZeroHash,
+			},
+		},
+		Report: &packp.ReportStatus{},
+	}
+
+	err := SendPack(context.TODO(), nil, caps, writer, reader, req)
+	assert.NoError(t, err)
+	assert.Contains(t, writer.writeBuf.String(), "report-status-v2")
+	assert.Len(t, req.Report.CommandStatuses, 2)
+	assert.Equal(t, plumbing.ReferenceName("refs/changes/24/124/1"), req.Report.CommandStatuses[0].RefName)
+}
+
 func TestSendPackWithReportStatusError(t *testing.T) {
 	t.Parallel()
 	caps := capability.List{}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/config"
@@ -28,12 +29,30 @@ var ErrUpdateReference = errors.New("failed to update ref")
 // the "# service=..." smart-reply line for the v2 request (http-backend.c
 // get_info_refs). Both behaviours are reproduced below.
 func AdvertiseRefs(
+	ctx context.Context,
+	st storage.Storer,
+	w io.Writer,
+	service string,
+	smart bool,
+	version protocol.Version,
+) error {
+	var nonce string
+	if service == ReceivePackService {
+		nonce = pushCertNonce(repositoryConfig(st), time.Now().Unix())
+	}
+	return advertiseRefs(ctx, st, w, service, smart, version, nonce)
+}
+
+// advertiseRefs implements AdvertiseRefs, advertising push-cert with the
+// given nonce when it is not empty.
+func advertiseRefs(
 	_ context.Context,
 	st storage.Storer,
 	w io.Writer,
 	service string,
 	smart bool,
 	version protocol.Version,
+	pushCertNonce string,
 ) error {
 	switch service {
 	case UploadPackService, ReceivePackService:
@@ -54,17 +73,26 @@ func AdvertiseRefs(
 		// TODO: support atomic
 		ar.Capabilities.Set(capability.DeleteRefs)
 		ar.Capabilities.Set(capability.ReportStatus)
+		ar.Capabilities.Set(capability.ReportStatusV2)
 		ar.Capabilities.Set(capability.PushOptions)
 		ar.Capabilities.Set(capability.Quiet)
+		if pushCertNonce != "" {
+			ar.Capabilities.Set(capability.PushCert, pushCertNonce)
+		}
 	} else {
 		// TODO: support include-tag
-		// TODO: support deepen
-		// TODO: support deepen-since
 		ar.Capabilities.Set(capability.MultiACK)
 		ar.Capabilities.Set(capability.MultiACKDetailed)
 		ar.Capabilities.Set(capability.Sideband)
 		ar.Capabilities.Set(capability.NoProgress)
 		ar.Capabilities.Set(capability.Shallow)
+		ar.Capabilities.Set(capability.DeepenSince)
+		ar.Capabilities.Set(capability.DeepenNot)
+		ar.Capabilities.Set(capability.DeepenRelative)
+		ar.Capabilities.Set(capability.NoDone)
+		if repositoryConfig(st).UploadPack.AllowFilter.IsTrue() {
+			ar.Capabilities.Set(capability.Filter)
+		}
 		ar.Capabilities.Set(capability.ObjectFormat, objectFormat(st).String())
 	}
 
@@ -128,22 +156,41 @@ func AdvertiseCapabilities(_ context.Context, st storage.Storer, w io.Writer, se
 //
 // The fetch "shallow" feature covers the whole deepen family (deepen <n>,
 // deepen-since, deepen-not and deepen-relative), all of which are handled, so
-// it is advertised as the single token upstream uses.
+// it is advertised as the single token upstream uses. "wait-for-done" is always
+// honored. The "filter" feature, covering every object filter
+// revlist.ParseFilter accepts, the "ref-in-want", "sideband-all" and
+// "packfile-uris" features and the bundle-uri command depend on the
+// repository's config (uploadpack.allowFilter, uploadpack.allowRefInWant,
+// uploadpack.allowSidebandAll, uploadpack.blobPackfileUri and
+// uploadpack.advertiseBundleURIs), as upstream.
 //
 // TODO: advertise these once implemented:
 //   - ls-refs=unborn       report an unborn HEAD on an empty repository
-//   - fetch=filter         partial-clone object filters
-//   - fetch=ref-in-want    want-ref negotiation
-//   - fetch=sideband-all   sideband for the entire response, not just the packfile
-//   - fetch=packfile-uris  offload pack data to out-of-band URIs
-//   - fetch=wait-for-done  negotiate-only fetch (git fetch --negotiate-only)
 //   - server-option        process client "server-option" lines
-//   - object-info          object size/type queries without a fetch
 func serverV2Capabilities(st storage.Storer) capability.List {
+	cfg := repositoryConfig(st)
+
 	var caps capability.List
 	caps.Set(capability.Agent, capability.DefaultAgent())
 	caps.Set(capability.LsRefs)
-	caps.Set(capability.FetchCmd, "shallow")
+	fetch := []string{"shallow", "wait-for-done"}
+	if cfg.UploadPack.AllowFilter.IsTrue() {
+		fetch = append(fetch, "filter")
+	}
+	if cfg.UploadPack.AllowRefInWant.IsTrue() {
+		fetch = append(fetch, "ref-in-want")
+	}
+	if cfg.UploadPack.AllowSidebandAll.IsTrue() {
+		fetch = append(fetch, "sideband-all")
+	}
+	if len(cfg.UploadPack.BlobPackfileURIs) > 0 {
+		fetch = append(fetch, "packfile-uris")
+	}
+	caps.Set(capability.FetchCmd, fetch...)
+	caps.Set(capability.ObjectInfo)
+	if cfg.UploadPack.AdvertiseBundleURIs.IsTrue() {
+		caps.Set(capability.BundleURI)
+	}
 	caps.Set(capability.ObjectFormat, objectFormat(st).String())
 	return caps
 }
@@ -12,6 +12,7 @@ import (
 	gossh "golang.org/x/crypto/ssh"
 
 	transport "github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 	"github.com/go-git/go-git/v6/plumbing/transport/ssh/knownhosts"
 	"github.com/go-git/go-git/v6/plumbing/transport/ssh/sshagent"
 	"github.com/go-git/go-git/v6/utils/trace"
@@ -191,6 +192,81 @@ func (a *KeyboardInteractive) ClientConfig(_ context.Context, _ *transport.Reque
 	})
 }
 
+// NewPublicKeysFromFileWithPrompt is like NewPublicKeysFromFile, but asks
+// p for the passphrase if the key is encrypted, with the prompt OpenSSH
+// uses.
+func NewPublicKeysFromFileWithPrompt(ctx context.Context, user, pemFile string, p credential.Prompter) (*PublicKeys, error) {
+	signer, err := loadIdentity(ctx, pemFile, p)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKeys{User: user, Signer: signer}, nil
+}
+
+// PromptKeyboardInteractive returns a keyboard-interactive challenge that
+// answers the questions of the server through p. The instruction sent by
+// the server, if any, is shown along with the first question.
+func PromptKeyboardInteractive(ctx context.Context, p credential.Prompter) gossh.KeyboardInteractiveChallenge {
+	return func(_, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, q := range questions {
+			if i == 0 && instruction != "" {
+				q = instruction + "\n" + q
+			}
+			answer, err := p.Prompt(ctx, q, echos[i])
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
+
+// PromptPassword returns a password callback that asks p for the password
+// of user at host, with the prompt OpenSSH uses.
+func PromptPassword(ctx context.Context, p credential.Prompter, user, host string) func() (string, error) {
+	return func() (string, error) {
+		return p.Prompt(ctx, fmt.Sprintf("%s@%s's password: ", user, host), false)
+	}
+}
+
+// loadIdentity reads the private key in file, asking p for its passphrase
+// if it is encrypted and p is not nil.
+func loadIdentity(ctx context.Context, file string, p credential.Prompter) (gossh.Signer, error) {
+	pemBytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := gossh.ParsePrivateKey(pemBytes)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) && p != nil {
+		var passphrase string
+		passphrase, err = p.Prompt(ctx, fmt.Sprintf("Enter passphrase for key '%s': ", file), false)
+		if err != nil {
+			return nil, err
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	}
+	return signer, err
+}
+
+// defaultIdentityFiles returns the identity files OpenSSH tries when none
+// is configured.
+func defaultIdentityFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk", "id_dsa"} {
+		files = append(files, filepath.Join(home, ".ssh", name))
+	}
+	return files
+}
+
 // NewKnownHostsCallback returns ssh.HostKeyCallback based on a known_hosts
 // file. http://man.openbsd.org/sshd#SSH_KNOWN_HOSTS_FILE_FORMAT
 //
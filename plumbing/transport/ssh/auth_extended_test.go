@@ -2,10 +2,12 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/kevinburke/ssh_config"
@@ -15,6 +17,7 @@ import (
 	"golang.org/x/crypto/ssh/testdata"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 )
 
 func TestNewPublicKeysWithEncryptedPEM(t *testing.T) {
@@ -170,3 +173,80 @@ func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+func TestNewPublicKeysFromFileWithPrompt(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("not available in wasm")
+	}
+	t.Parallel()
+
+	f := testdata.PEMEncryptedKeys[2]
+	path := filepath.Join(t.TempDir(), "ssh-test-key")
+	require.NoError(t, os.WriteFile(path, f.PEMBytes, 0o600))
+
+	var prompts []string
+	p := credential.PromptFunc(func(_ context.Context, prompt string, echo bool) (string, error) {
+		assert.False(t, echo)
+		prompts = append(prompts, prompt)
+		return f.EncryptionKey, nil
+	})
+
+	auth, err := NewPublicKeysFromFileWithPrompt(context.Background(), "git", path, p)
+	require.NoError(t, err)
+	require.NotNil(t, auth.Signer)
+	assert.Equal(t, []string{"Enter passphrase for key '" + path + "': "}, prompts)
+
+	// Unencrypted keys never prompt.
+	plain := filepath.Join(t.TempDir(), "plain-key")
+	require.NoError(t, os.WriteFile(plain, testdata.PEMBytes["ed25519"], 0o600))
+	_, err = NewPublicKeysFromFileWithPrompt(context.Background(), "git", plain, p)
+	require.NoError(t, err)
+	assert.Len(t, prompts, 1)
+}
+
+func TestIdentitiesSkipsUnusableKeys(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("not available in wasm")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "id_encrypted")
+	plain := filepath.Join(dir, "id_plain")
+	require.NoError(t, os.WriteFile(encrypted, testdata.PEMEncryptedKeys[2].PEMBytes, 0o600))
+	require.NoError(t, os.WriteFile(plain, testdata.PEMBytes["ed25519"], 0o600))
+
+	p := credential.PromptFunc(func(context.Context, string, bool) (string, error) {
+		return "", errors.New("no tty")
+	})
+
+	signers := identities(context.Background(), []string{filepath.Join(dir, "missing"), encrypted, plain}, p)
+	require.Len(t, signers, 1)
+	want, err := gossh.ParsePrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+	assert.Equal(t, want.PublicKey().Marshal(), signers[0].PublicKey().Marshal())
+}
+
+func TestPromptKeyboardInteractive(t *testing.T) {
+	t.Parallel()
+
+	var prompts []string
+	var echos []bool
+	p := credential.PromptFunc(func(_ context.Context, prompt string, echo bool) (string, error) {
+		prompts = append(prompts, prompt)
+		echos = append(echos, echo)
+		return "answer" + strconv.Itoa(len(prompts)), nil
+	})
+
+	challenge := PromptKeyboardInteractive(context.Background(), p)
+	answers, err := challenge("name", "Log in", []string{"User: ", "Code: "}, []bool{true, false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"answer1", "answer2"}, answers)
+	assert.Equal(t, []string{"Log in\nUser: ", "Code: "}, prompts)
+	assert.Equal(t, []bool{true, false}, echos)
+
+	pass, err := PromptPassword(context.Background(), p, "git", "example.com")()
+	require.NoError(t, err)
+	assert.Equal(t, "answer3", pass)
+	assert.Equal(t, "git@example.com's password: ", prompts[2])
+}
@@ -0,0 +1,217 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// The SSH command variants, telling how to pass options to the command.
+const (
+	VariantSSH           = "ssh"
+	VariantPlink         = "plink"
+	VariantPutty         = "putty"
+	VariantTortoisePlink = "tortoiseplink"
+	VariantSimple        = "simple"
+)
+
+// ResolveSSHCommand returns the SSH command and variant Git would use,
+// given the values of core.sshCommand and ssh.variant: the
+// GIT_SSH_COMMAND environment variable takes precedence over
+// coreSSHCommand, which takes precedence over GIT_SSH. GIT_SSH_VARIANT
+// takes precedence over sshVariant. It returns an empty command if none of
+// them is set, in which case the built-in client is to be used.
+func ResolveSSHCommand(coreSSHCommand, sshVariant string) (command, variant string) {
+	switch {
+	case os.Getenv("GIT_SSH_COMMAND") != "":
+		command = os.Getenv("GIT_SSH_COMMAND")
+	case coreSSHCommand != "":
+		command = coreSSHCommand
+	case os.Getenv("GIT_SSH") != "":
+		// GIT_SSH is a program, not a shell command.
+		var b strings.Builder
+		writeShellQuote(&b, os.Getenv("GIT_SSH"))
+		command = b.String()
+	}
+
+	variant = sshVariant
+	if v := os.Getenv("GIT_SSH_VARIANT"); v != "" {
+		variant = v
+	}
+	return command, variant
+}
+
+// sshVariant returns the variant of command, guessing it from the name of
+// the program if variant is empty or "auto". Unknown programs are assumed
+// to be compatible with OpenSSH.
+func sshVariant(command, variant string) string {
+	if variant != "" && !strings.EqualFold(variant, "auto") {
+		return strings.ToLower(variant)
+	}
+
+	program := strings.TrimSpace(command)
+	if q := program[:min(1, len(program))]; q == "'" || q == `"` {
+		program, _, _ = strings.Cut(program[1:], q)
+	} else if i := strings.IndexAny(program, " \t"); i >= 0 {
+		program = program[:i]
+	}
+	if i := strings.LastIndexAny(program, `/\`); i >= 0 {
+		program = program[i+1:]
+	}
+	name := strings.TrimSuffix(strings.ToLower(program), ".exe")
+
+	switch name {
+	case "plink":
+		return VariantPlink
+	case "putty":
+		return VariantPutty
+	case "tortoiseplink":
+		return VariantTortoisePlink
+	default:
+		return VariantSSH
+	}
+}
+
+// commandArgs returns the arguments passed to the SSH command of variant
+// to run the Git command of req.
+func commandArgs(req *transport.Request, variant string) ([]string, error) {
+	// As git, refuse a destination or port the SSH command would take for
+	// an option, such as ssh://-oProxyCommand=cmd@host/repo.
+	if port := req.URL.Port(); strings.HasPrefix(port, "-") {
+		return nil, fmt.Errorf("strange port %q blocked", port)
+	}
+
+	var args []string
+	switch variant {
+	case VariantSSH:
+		if transport.GitProtocolEnv(req.Protocol) != "" {
+			args = append(args, "-o", "SendEnv=GIT_PROTOCOL")
+		}
+		if port := req.URL.Port(); port != "" {
+			args = append(args, "-p", port)
+		}
+	case VariantTortoisePlink:
+		args = append(args, "-batch")
+		fallthrough
+	case VariantPlink, VariantPutty:
+		if port := req.URL.Port(); port != "" {
+			args = append(args, "-P", port)
+		}
+	case VariantSimple:
+		if req.URL.Port() != "" {
+			return nil, fmt.Errorf("ssh variant 'simple' does not support setting port")
+		}
+	default:
+		return nil, fmt.Errorf("unknown ssh variant %q", variant)
+	}
+
+	host := req.URL.Hostname()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if req.URL.User != nil && req.URL.User.Username() != "" {
+		host = req.URL.User.Username() + "@" + host
+	}
+	if strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("strange hostname %q blocked", host)
+	}
+
+	return append(args, host, buildCommand(req)), nil
+}
+
+// command runs the Git command of req on the server through the external
+// SSH command.
+func (t *Transport) command(_ context.Context, req *transport.Request) (transport.Conn, error) {
+	variant := sshVariant(t.opts.SSHCommand, t.opts.SSHVariant)
+	args, err := commandArgs(req, variant)
+	if err != nil {
+		return nil, err
+	}
+
+	trace.SSH.Printf("ssh: running %q (variant %s) with %q", t.opts.SSHCommand, variant, args)
+
+	// The command is run by the shell, as Git does, with the arguments
+	// appended to it. It outlives the context, which only bounds the
+	// connection setup.
+	cmd := exec.Command("sh", append([]string{"-c", t.opts.SSHCommand + ` "$@"`, t.opts.SSHCommand}, args...)...)
+	cmd.Env = os.Environ()
+	if gp := transport.GitProtocolEnv(req.Protocol); gp != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+gp)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ssh: running %q: %w", t.opts.SSHCommand, err)
+	}
+
+	conn := &commandSSHConn{cmd: cmd, stdin: stdin, stdout: stdout, done: make(chan struct{})}
+	go func() {
+		defer close(conn.done)
+		_, _ = ioutil.CopyBufferPool(&conn.stderr, stderr)
+	}()
+	return conn, nil
+}
+
+// commandSSHConn is a connection to the server through an external SSH
+// command.
+type commandSSHConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+
+	done   chan struct{}
+	stderr bytes.Buffer
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ transport.Conn = (*commandSSHConn)(nil)
+
+func (c *commandSSHConn) Reader() io.Reader      { return c.stdout }
+func (c *commandSSHConn) Writer() io.WriteCloser { return c.stdin }
+
+// Close closes the standard input of the command and waits for it to exit.
+func (c *commandSSHConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		<-c.done
+		err := c.cmd.Wait()
+		if err != nil && c.stderr.Len() > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(c.stderr.String()))
+		}
+		c.closeErr = err
+	})
+	return c.closeErr
+}
+
+// Stderr returns the standard error of the command once it has exited.
+func (c *commandSSHConn) Stderr() io.Reader {
+	select {
+	case <-c.done:
+		return &c.stderr
+	default:
+		return nil
+	}
+}
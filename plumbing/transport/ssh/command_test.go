@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/internal/transport/test"
+	"github.com/go-git/go-git/v6/plumbing/protocol"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+func TestSSHVariant(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		command, variant, want string
+	}{
+		{"ssh", "", VariantSSH},
+		{"/usr/bin/ssh -i key", "", VariantSSH},
+		{`'C:\Program Files\PuTTY\plink.exe'`, "", VariantPlink},
+		{"putty", "auto", VariantPutty},
+		{"TortoisePlink.exe", "", VariantTortoisePlink},
+		{"my-wrapper", "", VariantSSH},
+		{"my-wrapper", "simple", VariantSimple},
+		{"ssh", "Plink", VariantPlink},
+	} {
+		assert.Equal(t, tc.want, sshVariant(tc.command, tc.variant), "%s (%s)", tc.command, tc.variant)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	t.Parallel()
+
+	req := &transport.Request{
+		URL:      mustParseURL("ssh://git@example.com:2222/repo.git"),
+		Command:  transport.UploadPackService,
+		Protocol: protocol.V2,
+	}
+	cmd := "git-upload-pack '/repo.git'"
+
+	for _, tc := range []struct {
+		variant string
+		want    []string
+	}{
+		{VariantSSH, []string{"-o", "SendEnv=GIT_PROTOCOL", "-p", "2222", "git@example.com", cmd}},
+		{VariantPlink, []string{"-P", "2222", "git@example.com", cmd}},
+		{VariantPutty, []string{"-P", "2222", "git@example.com", cmd}},
+		{VariantTortoisePlink, []string{"-batch", "-P", "2222", "git@example.com", cmd}},
+	} {
+		args, err := commandArgs(req, tc.variant)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, args, tc.variant)
+	}
+
+	_, err := commandArgs(req, VariantSimple)
+	require.Error(t, err)
+
+	args, err := commandArgs(&transport.Request{
+		URL:     mustParseURL("ssh://[::1]/repo.git"),
+		Command: transport.UploadPackService,
+	}, VariantSimple)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[::1]", cmd}, args)
+}
+
+func TestCommandArgsOptionInjection(t *testing.T) {
+	t.Parallel()
+
+	for _, rawURL := range []string{
+		"ssh://-oProxyCommand=x@host/repo",
+		"ssh://-oProxyCommand=x/repo",
+	} {
+		_, err := commandArgs(&transport.Request{
+			URL:     mustParseURL(rawURL),
+			Command: transport.UploadPackService,
+		}, VariantSSH)
+		require.ErrorContains(t, err, "blocked", rawURL)
+	}
+}
+
+func TestResolveSSHCommand(t *testing.T) {
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH", "")
+	t.Setenv("GIT_SSH_VARIANT", "")
+
+	command, variant := ResolveSSHCommand("", "")
+	assert.Empty(t, command)
+	assert.Empty(t, variant)
+
+	t.Setenv("GIT_SSH", "/opt/my ssh")
+	command, _ = ResolveSSHCommand("", "")
+	assert.Equal(t, "'/opt/my ssh'", command)
+
+	command, variant = ResolveSSHCommand("ssh -i key", "putty")
+	assert.Equal(t, "ssh -i key", command)
+	assert.Equal(t, "putty", variant)
+
+	t.Setenv("GIT_SSH_COMMAND", "plink -batch")
+	t.Setenv("GIT_SSH_VARIANT", "plink")
+	command, variant = ResolveSSHCommand("ssh -i key", "putty")
+	assert.Equal(t, "plink -batch", command)
+	assert.Equal(t, "plink", variant)
+}
+
+func TestSSHCommand(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	repoFS := test.PrepareRepository(t, fixtures.Basic().One(), t.TempDir(), "basic.git")
+	repoPath := filepath.ToSlash(repoFS.Root())
+
+	// The fake ssh records its arguments and GIT_PROTOCOL, then runs the
+	// remote command locally.
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "fake-ssh")
+	require.NoError(t, os.WriteFile(script, []byte(fmt.Sprintf(`#!/bin/sh
+echo "$GIT_PROTOCOL" "$@" > %q
+for last; do :; done
+exec sh -c "$last"
+`, log)), 0o755))
+
+	tr := NewTransport(Options{SSHCommand: script + " -o Foo=bar"})
+	req := &transport.Request{
+		URL:      mustParseURL(fmt.Sprintf("ssh://git@example.com:2222%s", repoPath)),
+		Command:  transport.UploadPackService,
+		Protocol: protocol.V2,
+	}
+
+	session, err := tr.Handshake(context.Background(), req)
+	require.NoError(t, err)
+
+	refs, err := session.GetRemoteRefs(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, refs.References)
+	require.NoError(t, session.Close())
+
+	b, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"version=2 -o Foo=bar -o SendEnv=GIT_PROTOCOL -p 2222 git@example.com git-upload-pack '"+repoPath+"'",
+		strings.TrimSpace(string(b)),
+	)
+}
@@ -0,0 +1,229 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kevinburke/ssh_config"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// hostConfig holds the ssh_config(5) settings that apply to a connection.
+// Settings that were not configured are left empty, so that the defaults of
+// the transport apply.
+type hostConfig struct {
+	// alias is the host as given in the URL, used to look up the config.
+	alias    string
+	hostname string
+	port     string
+	user     string
+
+	identityFiles    []string
+	identitiesOnly   bool
+	certificateFiles []string
+
+	hostKeyAlgorithms   string
+	userKnownHostsFiles []string
+
+	proxyJump    string
+	proxyCommand string
+}
+
+// addr returns the address to connect to.
+func (hc *hostConfig) addr() string {
+	return net.JoinHostPort(hc.hostname, hc.port)
+}
+
+// knownHostsFiles returns the known_hosts files configured with
+// UserKnownHostsFile, along with the global ones. It returns nil if
+// UserKnownHostsFile is not configured, so that the default files are used.
+func (hc *hostConfig) knownHostsFiles() []string {
+	if len(hc.userKnownHostsFiles) == 0 {
+		return nil
+	}
+	files := make([]string, 0, len(hc.userKnownHostsFiles)+1)
+	files = append(files, hc.userKnownHostsFiles...)
+	return append(files, "/etc/ssh/ssh_known_hosts")
+}
+
+// hostConfig resolves the ssh_config settings for the host of req.
+func (t *Transport) hostConfig(ctx context.Context, req *transport.Request) (*hostConfig, error) {
+	us, err := t.userSettings(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := req.URL.Hostname()
+	hc := &hostConfig{
+		alias:    alias,
+		hostname: alias,
+		port:     req.URL.Port(),
+	}
+
+	if req.URL.User != nil {
+		hc.user = req.URL.User.Username()
+	}
+
+	// The host and user of the URL may be expanded in a ProxyCommand run by
+	// the shell: as OpenSSH, refuse those holding shell metacharacters.
+	if !validHostname(alias) {
+		return nil, fmt.Errorf("ssh: invalid hostname %q", alias)
+	}
+	if !validUser(hc.user) {
+		return nil, fmt.Errorf("ssh: invalid user %q", hc.user)
+	}
+
+	if hc.user == "" {
+		hc.user = configured(us, alias, "User")
+	}
+
+	if hc.port == "" {
+		if port := configured(us, alias, "Port"); port != "" {
+			if _, err := strconv.Atoi(port); err == nil {
+				hc.port = port
+			}
+		}
+	}
+	if hc.port == "" {
+		hc.port = strconv.Itoa(DefaultPort)
+	}
+
+	if hostname := configured(us, alias, "Hostname"); hostname != "" {
+		hc.hostname = hc.expand(hostname)
+	}
+
+	for _, f := range configuredAll(us, alias, "IdentityFile") {
+		if strings.EqualFold(f, "none") {
+			continue
+		}
+		hc.identityFiles = append(hc.identityFiles, hc.expandPath(f))
+	}
+	hc.identitiesOnly = strings.EqualFold(configured(us, alias, "IdentitiesOnly"), "yes")
+	for _, f := range configuredAll(us, alias, "CertificateFile") {
+		if strings.EqualFold(f, "none") {
+			continue
+		}
+		hc.certificateFiles = append(hc.certificateFiles, hc.expandPath(f))
+	}
+
+	hc.hostKeyAlgorithms = configured(us, alias, "HostKeyAlgorithms")
+	if files := configured(us, alias, "UserKnownHostsFile"); files != "" && !strings.EqualFold(files, "none") {
+		for _, f := range strings.Fields(files) {
+			hc.userKnownHostsFiles = append(hc.userKnownHostsFiles, hc.expandPath(f))
+		}
+	}
+
+	if jump := configured(us, alias, "ProxyJump"); !strings.EqualFold(jump, "none") {
+		hc.proxyJump = jump
+	}
+	if command := configured(us, alias, "ProxyCommand"); !strings.EqualFold(command, "none") {
+		hc.proxyCommand = command
+	}
+
+	return hc, nil
+}
+
+// validHostname reports whether the hostname of a URL is safe to be expanded
+// in a shell command, as OpenSSH's valid_hostname.
+func validHostname(s string) bool {
+	return !strings.HasPrefix(s, "-") && !containsShellChars(s, "'`\"$\\;&<>|(){},")
+}
+
+// validUser reports whether the user of a URL is safe to be expanded in a
+// shell command, as OpenSSH's valid_ruser.
+func validUser(s string) bool {
+	return !strings.HasPrefix(s, "-") && !containsShellChars(s, "'`\"$\\;&<>|(){}")
+}
+
+// containsShellChars reports whether s contains any of chars, a space or a
+// control character.
+func containsShellChars(s, chars string) bool {
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(chars, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// configured returns the value of key for alias, or an empty string if it
+// was not configured. ssh_config reports the OpenSSH default of some keys
+// when they are not set; those are treated as not configured.
+func configured(us *ssh_config.UserSettings, alias, key string) string {
+	v := us.Get(alias, key)
+	if v == ssh_config.Default(key) {
+		return ""
+	}
+	return v
+}
+
+// configuredAll is like configured, for keys that can be repeated.
+func configuredAll(us *ssh_config.UserSettings, alias, key string) []string {
+	values := us.GetAll(alias, key)
+	if len(values) == 1 && values[0] == ssh_config.Default(key) {
+		return nil
+	}
+	return values
+}
+
+// expand replaces the ssh_config tokens supported by go-git in s: %% (a
+// literal %), %h (the remote hostname), %p (the port), %r (the remote
+// user), %n (the host as given in the URL), %d (the local home directory)
+// and %u (the local user).
+func (hc *hostConfig) expand(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'h':
+			b.WriteString(hc.hostname)
+		case 'p':
+			b.WriteString(hc.port)
+		case 'r':
+			b.WriteString(hc.user)
+		case 'n':
+			b.WriteString(hc.alias)
+		case 'd':
+			home, _ := os.UserHomeDir()
+			b.WriteString(home)
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		default:
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// expandPath expands tokens and a leading ~ in a file name.
+func (hc *hostConfig) expandPath(p string) string {
+	p = hc.expand(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
@@ -0,0 +1,162 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+func TestHostConfig(t *testing.T) {
+	t.Parallel()
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tr := newTransportWithConfig(t, `
+Host example
+    Hostname %n.internal
+    User alice
+    Port 2222
+    IdentityFile ~/.ssh/id_%h
+    IdentityFile /keys/%r@%n:%p
+    IdentitiesOnly yes
+    CertificateFile /certs/%%%h-cert.pub
+    HostKeyAlgorithms +ssh-rsa
+    UserKnownHostsFile /a/known_hosts ~/b_known_hosts
+    ProxyJump jump1,jump2
+    ProxyCommand nc %h %p
+`)
+
+	hc, err := tr.hostConfig(t.Context(), &transport.Request{
+		URL: mustParseURL("ssh://example/repo.git"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "example", hc.alias)
+	assert.Equal(t, "example.internal", hc.hostname)
+	assert.Equal(t, "alice", hc.user)
+	assert.Equal(t, "example.internal:2222", hc.addr())
+	assert.Equal(t, []string{
+		filepath.Join(home, ".ssh", "id_example.internal"),
+		"/keys/alice@example:2222",
+	}, hc.identityFiles)
+	assert.True(t, hc.identitiesOnly)
+	assert.Equal(t, []string{"/certs/%example.internal-cert.pub"}, hc.certificateFiles)
+	assert.Equal(t, "+ssh-rsa", hc.hostKeyAlgorithms)
+	assert.Equal(t, []string{
+		"/a/known_hosts",
+		filepath.Join(home, "b_known_hosts"),
+		"/etc/ssh/ssh_known_hosts",
+	}, hc.knownHostsFiles())
+	assert.Equal(t, "jump1,jump2", hc.proxyJump)
+	assert.Equal(t, "nc %h %p", hc.proxyCommand)
+	assert.Equal(t, "nc example.internal 2222", hc.expand(hc.proxyCommand))
+}
+
+func TestHostConfigDefaults(t *testing.T) {
+	t.Parallel()
+
+	tr := newTransportWithConfig(t, `
+Host other
+    User bob
+    ProxyJump jump
+`)
+
+	hc, err := tr.hostConfig(t.Context(), &transport.Request{
+		URL: mustParseURL("ssh://git@example/repo.git"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "git", hc.user)
+	assert.Equal(t, "example:22", hc.addr())
+	assert.Empty(t, hc.identityFiles)
+	assert.False(t, hc.identitiesOnly)
+	assert.Empty(t, hc.certificateFiles)
+	assert.Empty(t, hc.hostKeyAlgorithms)
+	assert.Nil(t, hc.knownHostsFiles())
+	assert.Empty(t, hc.proxyJump)
+	assert.Empty(t, hc.proxyCommand)
+}
+
+func TestHostConfigNone(t *testing.T) {
+	t.Parallel()
+
+	tr := newTransportWithConfig(t, `
+Host example
+    IdentityFile none
+    ProxyJump none
+    ProxyCommand none
+    UserKnownHostsFile none
+`)
+
+	hc, err := tr.hostConfig(t.Context(), &transport.Request{
+		URL: mustParseURL("ssh://example/repo.git"),
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, hc.identityFiles)
+	assert.Empty(t, hc.proxyJump)
+	assert.Empty(t, hc.proxyCommand)
+	assert.Nil(t, hc.knownHostsFiles())
+}
+
+func TestHostConfigShellMetacharacters(t *testing.T) {
+	t.Parallel()
+
+	tr := newTransportWithConfig(t, `
+Host *
+    ProxyCommand nc %h %p
+`)
+
+	for _, rawURL := range []string{
+		"ssh://git@x$(id>pwnd)/repo.git",
+		"ssh://git@x;id/repo.git",
+		"ssh://-oProxyCommand=id/repo.git",
+		"ssh://$(id)@example/repo.git",
+		"ssh://a;b@example/repo.git",
+	} {
+		_, err := tr.hostConfig(t.Context(), &transport.Request{URL: mustParseURL(rawURL)})
+		require.ErrorContains(t, err, "invalid", rawURL)
+	}
+}
+
+func TestKnownHostsFilesCopy(t *testing.T) {
+	t.Parallel()
+
+	hc := &hostConfig{userKnownHostsFiles: make([]string, 1, 4)}
+	hc.userKnownHostsFiles[0] = "/a/known_hosts"
+
+	files := hc.knownHostsFiles()
+	files[0] = "/b/known_hosts"
+	assert.Equal(t, []string{"/a/known_hosts"}, hc.userKnownHostsFiles)
+	assert.Equal(t, []string{"/a/known_hosts", "/etc/ssh/ssh_known_hosts"}, hc.knownHostsFiles())
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	known := []string{gossh.KeyAlgoED25519, gossh.KeyAlgoRSASHA256}
+	for _, tc := range []struct {
+		setting string
+		want    []string
+	}{
+		{"", known},
+		{"+ssh-rsa", []string{gossh.KeyAlgoED25519, gossh.KeyAlgoRSASHA256, gossh.KeyAlgoRSA}},
+		{"^ssh-rsa,ssh-ed25519", []string{gossh.KeyAlgoRSA, gossh.KeyAlgoED25519, gossh.KeyAlgoRSASHA256}},
+		{"-rsa-*", []string{gossh.KeyAlgoED25519}},
+		{"ecdsa-sha2-nistp256,ssh-ed25519", []string{gossh.KeyAlgoECDSA256, gossh.KeyAlgoED25519}},
+	} {
+		assert.Equal(t, tc.want, hostKeyAlgorithms(known, tc.setting), tc.setting)
+	}
+
+	assert.Equal(t,
+		append(gossh.SupportedAlgorithms().HostKeys, gossh.KeyAlgoDSA),
+		hostKeyAlgorithms(nil, "+ssh-dss"),
+	)
+}
@@ -0,0 +1,192 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// identitySigner is a signer for an identity file whose private key is only
+// loaded, and its passphrase asked for, when the server accepts the public
+// key. The public key is read from the .pub file next to the identity.
+type identitySigner struct {
+	ctx    context.Context
+	file   string
+	public gossh.PublicKey
+	prompt credential.Prompter
+
+	once   sync.Once
+	signer gossh.Signer
+	err    error
+}
+
+var _ gossh.AlgorithmSigner = (*identitySigner)(nil)
+
+// newIdentitySigner returns a signer for the identity in file. If the public
+// key of the identity is not available, the private key is loaded right
+// away.
+func newIdentitySigner(ctx context.Context, file string, p credential.Prompter) (gossh.Signer, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+
+	s := &identitySigner{ctx: ctx, file: file, prompt: p}
+	if pub, err := readPublicKey(file + ".pub"); err == nil {
+		s.public = pub
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.signer, nil
+}
+
+func (s *identitySigner) load() error {
+	s.once.Do(func() {
+		s.signer, s.err = loadIdentity(s.ctx, s.file, s.prompt)
+		if s.err == nil && s.public != nil &&
+			!bytes.Equal(s.signer.PublicKey().Marshal(), s.public.Marshal()) {
+			s.err = fmt.Errorf("public key %s.pub does not match the private key", s.file)
+		}
+	})
+	return s.err
+}
+
+// PublicKey implements gossh.Signer.
+func (s *identitySigner) PublicKey() gossh.PublicKey {
+	return s.public
+}
+
+// Sign implements gossh.Signer.
+func (s *identitySigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.signer.Sign(rand, data)
+}
+
+// SignWithAlgorithm implements gossh.AlgorithmSigner.
+func (s *identitySigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*gossh.Signature, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	as, ok := s.signer.(gossh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("ssh: key %s does not support algorithm %s", s.file, algorithm)
+	}
+	return as.SignWithAlgorithm(rand, data, algorithm)
+}
+
+// readPublicKey reads a public key or certificate in authorized_keys format.
+func readPublicKey(file string) (gossh.PublicKey, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(b)
+	return pub, err
+}
+
+// identities returns the signers of the given identity files, skipping the
+// files that do not exist or cannot be loaded.
+func identities(ctx context.Context, files []string, p credential.Prompter) []gossh.Signer {
+	var signers []gossh.Signer
+	for _, file := range files {
+		signer, err := newIdentitySigner(ctx, file, p)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				trace.SSH.Printf("ssh: skipping identity %s: %v", file, err)
+			}
+			continue
+		}
+		trace.SSH.Printf("ssh: identity %s: %s %s", file,
+			signer.PublicKey().Type(), gossh.FingerprintSHA256(signer.PublicKey()))
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
+// certificates reads the OpenSSH user certificates in files, skipping the
+// files that do not exist or do not hold a certificate.
+func certificates(files []string) []*gossh.Certificate {
+	var certs []*gossh.Certificate
+	for _, file := range files {
+		pub, err := readPublicKey(file)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				trace.SSH.Printf("ssh: skipping certificate %s: %v", file, err)
+			}
+			continue
+		}
+		cert, ok := pub.(*gossh.Certificate)
+		if !ok {
+			trace.SSH.Printf("ssh: skipping certificate %s: not a certificate", file)
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+// hostSigners returns the signers to authenticate with, in the order they
+// are tried, as OpenSSH does: certificates first, then the agent keys, then
+// the identities not held by the agent. If identitiesOnly is true, only the
+// agent keys matching an identity are used.
+func hostSigners(agent, ids []gossh.Signer, certs []*gossh.Certificate, identitiesOnly bool) []gossh.Signer {
+	seen := make(map[string]bool)
+	var keys []gossh.Signer
+	add := func(s gossh.Signer) {
+		k := string(s.PublicKey().Marshal())
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, s)
+		}
+	}
+
+	if identitiesOnly {
+		wanted := make(map[string]bool, len(ids))
+		for _, s := range ids {
+			wanted[string(s.PublicKey().Marshal())] = true
+		}
+		for _, s := range agent {
+			if wanted[string(s.PublicKey().Marshal())] {
+				add(s)
+			}
+		}
+	} else {
+		for _, s := range agent {
+			add(s)
+		}
+	}
+	for _, s := range ids {
+		add(s)
+	}
+
+	var signers []gossh.Signer
+	for _, cert := range certs {
+		k := string(cert.Key.Marshal())
+		for _, s := range keys {
+			if string(s.PublicKey().Marshal()) != k {
+				continue
+			}
+			cs, err := gossh.NewCertSigner(cert, s)
+			if err != nil {
+				trace.SSH.Printf("ssh: skipping certificate %s: %v", gossh.FingerprintSHA256(cert), err)
+				break
+			}
+			signers = append(signers, cs)
+			break
+		}
+	}
+	return append(signers, keys...)
+}
@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+)
+
+func mustSigner(t *testing.T, name string) gossh.Signer {
+	t.Helper()
+
+	s, err := gossh.ParsePrivateKey(testdata.PEMBytes[name])
+	require.NoError(t, err)
+	return s
+}
+
+func TestIdentitySignerIsLazy(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("not available in wasm")
+	}
+	t.Parallel()
+
+	f := testdata.PEMEncryptedKeys[2]
+	decrypted, err := gossh.ParsePrivateKeyWithPassphrase(f.PEMBytes, []byte(f.EncryptionKey))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "id_test")
+	require.NoError(t, os.WriteFile(path, f.PEMBytes, 0o600))
+	require.NoError(t, os.WriteFile(path+".pub", gossh.MarshalAuthorizedKey(decrypted.PublicKey()), 0o644))
+
+	var prompts int
+	p := credential.PromptFunc(func(context.Context, string, bool) (string, error) {
+		prompts++
+		return f.EncryptionKey, nil
+	})
+
+	signer, err := newIdentitySigner(context.Background(), path, p)
+	require.NoError(t, err)
+	assert.Equal(t, decrypted.PublicKey().Marshal(), signer.PublicKey().Marshal())
+	assert.Zero(t, prompts)
+
+	sig, err := signer.Sign(rand.Reader, []byte("data"))
+	require.NoError(t, err)
+	require.NoError(t, signer.PublicKey().Verify([]byte("data"), sig))
+	assert.Equal(t, 1, prompts)
+
+	_, err = signer.Sign(rand.Reader, []byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, prompts)
+}
+
+func TestHostSigners(t *testing.T) {
+	t.Parallel()
+
+	agentKey := mustSigner(t, "rsa")
+	idKey := mustSigner(t, "ed25519")
+	otherKey := mustSigner(t, "ecdsa")
+
+	cert := &gossh.Certificate{
+		Key:             idKey.PublicKey(),
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{"git"},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, mustSigner(t, "ecdsa")))
+
+	signers := hostSigners(
+		[]gossh.Signer{agentKey, idKey},
+		[]gossh.Signer{idKey, otherKey},
+		[]*gossh.Certificate{cert},
+		false,
+	)
+	require.Len(t, signers, 4)
+	assert.Equal(t, cert.Marshal(), signers[0].PublicKey().Marshal())
+	assert.Equal(t, agentKey.PublicKey(), signers[1].PublicKey())
+	assert.Equal(t, idKey.PublicKey(), signers[2].PublicKey())
+	assert.Equal(t, otherKey.PublicKey(), signers[3].PublicKey())
+
+	signers = hostSigners(
+		[]gossh.Signer{agentKey, idKey},
+		[]gossh.Signer{idKey},
+		nil,
+		true,
+	)
+	require.Len(t, signers, 1)
+	assert.Equal(t, idKey.PublicKey(), signers[0].PublicKey())
+}
+
+func TestCertificates(t *testing.T) {
+	t.Parallel()
+
+	key := mustSigner(t, "ed25519")
+	cert := &gossh.Certificate{
+		Key:         key.PublicKey(),
+		CertType:    gossh.UserCert,
+		ValidBefore: gossh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, mustSigner(t, "ecdsa")))
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "id-cert.pub")
+	plainFile := filepath.Join(dir, "id.pub")
+	require.NoError(t, os.WriteFile(certFile, gossh.MarshalAuthorizedKey(cert), 0o644))
+	require.NoError(t, os.WriteFile(plainFile, gossh.MarshalAuthorizedKey(key.PublicKey()), 0o644))
+
+	certs := certificates([]string{filepath.Join(dir, "missing"), plainFile, certFile})
+	require.Len(t, certs, 1)
+	assert.Equal(t, cert.Marshal(), certs[0].Marshal())
+}
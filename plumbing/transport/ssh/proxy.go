@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// jumpHosts parses a ProxyJump value: a comma-separated list of
+// [user@]host[:port] or ssh:// URLs.
+func jumpHosts(proxyJump string) ([]*url.URL, error) {
+	var hops []*url.URL
+	for _, hop := range strings.Split(proxyJump, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+		if !strings.HasPrefix(hop, "ssh://") {
+			hop = "ssh://" + hop
+		}
+		u, err := url.Parse(hop)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("ssh: invalid ProxyJump host %q", hop)
+		}
+		hops = append(hops, u)
+	}
+	return hops, nil
+}
+
+// dialJump connects to the host of hc through the jump hosts of
+// hc.proxyJump, each of them being connected to through the previous one.
+// The jump hosts use their own ssh_config settings, except ProxyJump and
+// ProxyCommand.
+func (t *Transport) dialJump(ctx context.Context, req *transport.Request, hc *hostConfig) (net.Conn, error) {
+	hops, err := jumpHosts(hc.proxyJump)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []*gossh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			_ = clients[i].Close()
+		}
+	}
+
+	var conn net.Conn
+	for _, hop := range hops {
+		hopReq := &transport.Request{
+			URL:      hop,
+			Command:  req.Command,
+			Protocol: req.Protocol,
+		}
+		hopConfig, err := t.hostConfig(ctx, hopReq)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		hopConfig.proxyJump = ""
+		hopConfig.proxyCommand = ""
+
+		config, err := t.clientConfig(ctx, hopReq, hopConfig)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		trace.SSH.Printf("ssh: jumping through %s", hopConfig.addr())
+		if len(clients) == 0 {
+			conn, err = t.dialTCP(ctx, "tcp", hopConfig.addr())
+		} else {
+			conn, err = clients[len(clients)-1].DialContext(ctx, "tcp", hopConfig.addr())
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		client, err := newClient(conn, hopConfig.addr(), config)
+		if err != nil {
+			_ = conn.Close()
+			closeAll()
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	if len(clients) == 0 {
+		return t.dialTCP(ctx, "tcp", hc.addr())
+	}
+
+	conn, err = clients[len(clients)-1].DialContext(ctx, "tcp", hc.addr())
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return &jumpConn{Conn: conn, clients: clients}, nil
+}
+
+// jumpConn is a connection through jump hosts, closing the connections to
+// the jump hosts when closed.
+type jumpConn struct {
+	net.Conn
+	clients []*gossh.Client
+}
+
+func (c *jumpConn) Close() error {
+	err := c.Conn.Close()
+	for i := len(c.clients) - 1; i >= 0; i-- {
+		_ = c.clients[i].Close()
+	}
+	return err
+}
+
+// dialCommand connects to the host of hc through the standard input and
+// output of hc.proxyCommand, run by the shell.
+func (t *Transport) dialCommand(ctx context.Context, hc *hostConfig) (net.Conn, error) {
+	command := hc.expand(hc.proxyCommand)
+	trace.SSH.Printf("ssh: using ProxyCommand %q", command)
+
+	// The command outlives ctx, which only bounds the connection setup.
+	cmd := exec.Command("sh", "-c", "exec "+command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ssh: ProxyCommand: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, addr: hc.addr()}, nil
+}
+
+// commandConn is a net.Conn over the standard input and output of a
+// ProxyCommand.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	addr   string
+}
+
+var _ net.Conn = (*commandConn)(nil)
+
+func (c *commandConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *commandConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+
+func (c *commandConn) Close() error {
+	_ = c.stdin.Close()
+	if c.cmd.ProcessState == nil {
+		_ = c.cmd.Process.Kill()
+	}
+	err := c.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// The command is expected to be killed, or to exit on its own once
+		// its standard input is closed.
+		err = nil
+	}
+	return err
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr("") }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr(c.addr) }
+
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+// commandAddr is the address of a ProxyCommand connection.
+type commandAddr string
+
+func (a commandAddr) Network() string { return "proxycommand" }
+func (a commandAddr) String() string  { return string(a) }
@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sync/atomic"
 	"testing"
 
 	"github.com/armon/go-socks5"
+	"github.com/gliderlabs/ssh"
 	fixtures "github.com/go-git/go-git-fixtures/v6"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
 	"golang.org/x/net/proxy"
 
 	"github.com/go-git/go-git/v6/internal/transport/test"
@@ -140,3 +146,165 @@ func socksDialProxy(proxyURL *url.URL) func(transport.DialContextFunc) transport
 		}
 	}
 }
+
+// startJumpServer starts an SSH server only allowing port forwarding, and
+// returns its address and the number of forwarded connections.
+func startJumpServer(t *testing.T) (*net.TCPAddr, *atomic.Int32) {
+	t.Helper()
+
+	var forwarded atomic.Int32
+	l := test.ListenTCP(t)
+	server := &ssh.Server{
+		Handler: func(s ssh.Session) { _ = s.Exit(1) },
+		LocalPortForwardingCallback: func(ssh.Context, string, uint32) bool {
+			forwarded.Add(1)
+			return true
+		},
+		ChannelHandlers: map[string]ssh.ChannelHandler{
+			"session":      ssh.DefaultSessionHandler,
+			"direct-tcpip": ssh.DirectTCPIPHandler,
+		},
+	}
+	server.SetOption(ssh.HostKeyPEM(testdata.PEMBytes["ed25519"]))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.ErrorIs(t, server.Serve(l), net.ErrClosed)
+	}()
+	t.Cleanup(func() {
+		_ = l.Close()
+		<-done
+	})
+	return l.Addr().(*net.TCPAddr), &forwarded
+}
+
+// proxyTestTransport returns a transport using the given ssh_config and
+// password authentication, without host key checking.
+func proxyTestTransport(t *testing.T, config string) *Transport {
+	t.Helper()
+
+	tr := newTransportWithConfig(t, config)
+	tr.opts.ClientConfig = func(_ context.Context, _ *transport.Request) (*gossh.ClientConfig, error) {
+		return &gossh.ClientConfig{
+			User:              "git",
+			Auth:              []gossh.AuthMethod{gossh.Password("")},
+			HostKeyCallback:   gossh.InsecureIgnoreHostKey(),
+			HostKeyAlgorithms: []string{gossh.KeyAlgoED25519},
+		}, nil
+	}
+	return tr
+}
+
+func assertUploadPack(t *testing.T, tr *Transport, repoPath string) {
+	t.Helper()
+
+	conn, err := tr.Connect(context.Background(), &transport.Request{
+		URL: &url.URL{
+			Scheme: "ssh",
+			User:   url.User("git"),
+			Host:   "target",
+			Path:   repoPath,
+		},
+		Command: transport.UploadPackService,
+	})
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	n, err := conn.Reader().Read(buf)
+	require.NoError(t, err)
+	require.Greater(t, n, 0)
+	require.NoError(t, conn.Close())
+}
+
+func TestProxyJump(t *testing.T) {
+	t.Parallel()
+
+	addr := startSSHServer(t)
+	jump1, forwarded1 := startJumpServer(t)
+	jump2, forwarded2 := startJumpServer(t)
+	repoFS := test.PrepareRepository(t, fixtures.Basic().One(), t.TempDir(), "basic.git")
+
+	tr := proxyTestTransport(t, fmt.Sprintf(`
+Host target
+    Hostname localhost
+    Port %d
+    ProxyJump git@jump1,ssh://jump2:%d
+
+Host jump1
+    Hostname localhost
+    Port %d
+    ProxyJump ignored
+
+Host jump2
+    Hostname localhost
+`, addr.Port, jump2.Port, jump1.Port))
+
+	assertUploadPack(t, tr, filepath.ToSlash(repoFS.Root()))
+	assert.Equal(t, int32(1), forwarded1.Load())
+	assert.Equal(t, int32(1), forwarded2.Load())
+}
+
+func TestProxyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" {
+		t.Skip("requires bash")
+	}
+	t.Parallel()
+
+	addr := startSSHServer(t)
+	repoFS := test.PrepareRepository(t, fixtures.Basic().One(), t.TempDir(), "basic.git")
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	tr := proxyTestTransport(t, fmt.Sprintf(`
+Host target
+    Hostname localhost
+    Port %d
+    ProxyCommand bash -c 'echo %%n > %s; exec 3<>/dev/tcp/%%h/%%p; cat <&3 & cat >&3'
+`, addr.Port, marker))
+
+	assertUploadPack(t, tr, filepath.ToSlash(repoFS.Root()))
+
+	b, err := os.ReadFile(marker)
+	require.NoError(t, err)
+	assert.Equal(t, "target\n", string(b))
+}
+
+func TestProxyCommandInjection(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" {
+		t.Skip("requires sh")
+	}
+	t.Parallel()
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	tr := proxyTestTransport(t, `
+Host *
+    ProxyCommand true %h %r %p
+`)
+
+	for _, u := range []*url.URL{
+		{Scheme: "ssh", User: url.User("git"), Host: "x$(touch " + marker + ")", Path: "/repo.git"},
+		{Scheme: "ssh", User: url.User("$(touch " + marker + ")"), Host: "example", Path: "/repo.git"},
+	} {
+		_, err := tr.Connect(context.Background(), &transport.Request{
+			URL:     u,
+			Command: transport.UploadPackService,
+		})
+		require.ErrorContains(t, err, "invalid", u.String())
+	}
+	assert.NoFileExists(t, marker)
+}
+
+func TestJumpHosts(t *testing.T) {
+	t.Parallel()
+
+	hops, err := jumpHosts("a, user@b:2222 ,ssh://c")
+	require.NoError(t, err)
+	require.Len(t, hops, 3)
+	assert.Equal(t, "a", hops[0].Host)
+	assert.Equal(t, "user", hops[1].User.Username())
+	assert.Equal(t, "b:2222", hops[1].Host)
+	assert.Equal(t, "c", hops[2].Host)
+
+	_, err = jumpHosts("user@")
+	require.Error(t, err)
+}
@@ -7,7 +7,8 @@ import (
 	"errors"
 	"io"
 	"net"
-	"strconv"
+	"path"
+	"slices"
 	"strings"
 	"sync/atomic"
 
@@ -15,6 +16,7 @@ import (
 	gossh "golang.org/x/crypto/ssh"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/trace"
 )
@@ -29,7 +31,7 @@ const DefaultUsername = "git"
 type Options struct {
 	// ClientConfig provides SSH client configuration for each request.
 	// If nil, SSH agent authentication is used with the username from the
-	// URL (falling back to DefaultUsername).
+	// URL, falling back to the User of ssh_config and then DefaultUsername.
 	ClientConfig func(context.Context, *transport.Request) (*gossh.ClientConfig, error)
 
 	// DialContext is the function used to establish TCP connections.
@@ -40,9 +42,31 @@ type Options struct {
 	// If nil, connections are made directly.
 	DialProxy func(transport.DialContextFunc) transport.DialContextFunc
 
-	// UserSettings provides an SSH configuration (Hostname, Port overrides
-	// from ~/.ssh/config). If nil, [ssh_config.DefaultUserSettings] is used.
+	// Prompt asks the user for key passphrases, keyboard-interactive
+	// answers and passwords when the default authentication is used
+	// (ClientConfig is nil). In that case, the default identity files, if
+	// no IdentityFile is configured, and keyboard-interactive and password
+	// authentication are tried after the SSH agent. If nil, only the SSH
+	// agent and the configured identity files are used.
+	Prompt credential.Prompter
+
+	// UserSettings provides the ssh_config(5) settings. Hostname, Port,
+	// User, IdentityFile, IdentitiesOnly, CertificateFile,
+	// HostKeyAlgorithms, UserKnownHostsFile, ProxyJump and ProxyCommand are
+	// supported. If nil, [ssh_config.DefaultUserSettings] is used.
 	UserSettings func(context.Context, *transport.Request) (*ssh_config.UserSettings, error)
+
+	// SSHCommand is the command used to connect to the server, as in
+	// core.sshCommand and GIT_SSH_COMMAND. If set, the external command
+	// is run instead of the built-in client, and all the options above
+	// are ignored. See ResolveSSHCommand.
+	SSHCommand string
+
+	// SSHVariant is the variant of SSHCommand, telling how to pass it
+	// options, as in ssh.variant and GIT_SSH_VARIANT: "ssh", "plink",
+	// "putty", "tortoiseplink" or "simple". If empty or "auto", it is
+	// guessed from the name of the command.
+	SSHVariant string
 }
 
 // Transport implements the ssh:// transport protocol.
@@ -57,43 +81,46 @@ func NewTransport(opts Options) *Transport {
 
 // Connect implements transport.Connector.
 func (t *Transport) Connect(ctx context.Context, req *transport.Request) (transport.Conn, error) {
-	conn, err := t.connect(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return conn, nil
+	return t.connect(ctx, req)
 }
 
-func (t *Transport) connect(ctx context.Context, req *transport.Request) (*sshConn, error) {
-	config, err := t.resolveConfig(ctx, req)
+func (t *Transport) connect(ctx context.Context, req *transport.Request) (transport.Conn, error) {
+	if t.opts.SSHCommand != "" {
+		return t.command(ctx, req)
+	}
+
+	hc, err := t.hostConfig(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	hostWithPort, err := t.resolveHostWithPort(ctx, req)
+	config, err := t.clientConfig(ctx, req, hc)
 	if err != nil {
 		return nil, err
 	}
 
-	if config.HostKeyCallback == nil {
-		db, err := newKnownHostsDb()
-		if err != nil {
-			return nil, err
-		}
-		config.HostKeyCallback = db.HostKeyCallback()
-		config.HostKeyAlgorithms = db.HostKeyAlgorithms(hostWithPort)
-	} else if len(config.HostKeyAlgorithms) == 0 {
-		db, err := newKnownHostsDb()
-		if err != nil {
-			return nil, err
-		}
-		config.HostKeyAlgorithms = db.HostKeyAlgorithms(hostWithPort)
+	dialCtx, cancel := context.WithCancel(ctx)
+	if config.Timeout > 0 {
+		dialCtx, cancel = context.WithTimeout(ctx, config.Timeout)
 	}
+	defer cancel()
 
-	trace.SSH.Printf("ssh: host key algorithms %s", strings.Join(config.HostKeyAlgorithms, ", "))
+	var conn net.Conn
+	switch {
+	case hc.proxyJump != "":
+		conn, err = t.dialJump(dialCtx, req, hc)
+	case hc.proxyCommand != "":
+		conn, err = t.dialCommand(dialCtx, hc)
+	default:
+		conn, err = t.dialTCP(dialCtx, "tcp", hc.addr())
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := t.dial(ctx, "tcp", hostWithPort, config)
+	client, err := newClient(conn, hc.addr(), config)
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
 
@@ -129,7 +156,7 @@ func (t *Transport) connect(ctx context.Context, req *transport.Request) (*sshCo
 		return nil, err
 	}
 
-	conn := &sshConn{
+	sc := &sshConn{
 		stdout:  stdoutPipe,
 		stdin:   stdinPipe,
 		session: session,
@@ -139,7 +166,7 @@ func (t *Transport) connect(ctx context.Context, req *transport.Request) (*sshCo
 	go func() {
 		var buf bytes.Buffer
 		_, _ = ioutil.CopyBufferPool(&buf, stderrPipe)
-		conn.stderrBuf.Store(&buf)
+		sc.stderrBuf.Store(&buf)
 	}()
 
 	cmd := buildCommand(req)
@@ -149,42 +176,140 @@ func (t *Transport) connect(ctx context.Context, req *transport.Request) (*sshCo
 		return nil, err
 	}
 
-	return conn, nil
+	return sc, nil
 }
 
-func (t *Transport) resolveConfig(ctx context.Context, req *transport.Request) (*gossh.ClientConfig, error) {
+// clientConfig returns the client configuration for the host of hc, with
+// its host key callback and algorithms set.
+func (t *Transport) clientConfig(ctx context.Context, req *transport.Request, hc *hostConfig) (*gossh.ClientConfig, error) {
+	config, err := t.resolveConfig(ctx, req, hc)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HostKeyCallback == nil || len(config.HostKeyAlgorithms) == 0 {
+		db, err := newKnownHostsDb(hc.knownHostsFiles()...)
+		if err != nil {
+			return nil, err
+		}
+		if config.HostKeyCallback == nil {
+			config.HostKeyCallback = db.HostKeyCallback()
+		}
+		if len(config.HostKeyAlgorithms) == 0 {
+			config.HostKeyAlgorithms = hostKeyAlgorithms(db.HostKeyAlgorithms(hc.addr()), hc.hostKeyAlgorithms)
+		}
+	}
+
+	trace.SSH.Printf("ssh: host key algorithms %s", strings.Join(config.HostKeyAlgorithms, ", "))
+	return config, nil
+}
+
+func (t *Transport) resolveConfig(ctx context.Context, req *transport.Request, hc *hostConfig) (*gossh.ClientConfig, error) {
 	if t.opts.ClientConfig != nil {
 		return t.opts.ClientConfig(ctx, req)
 	}
 
-	username := DefaultUsername
-	if req.URL.User != nil {
-		if u := req.URL.User.Username(); u != "" {
-			username = u
-		}
+	username := hc.user
+	if username == "" {
+		username = DefaultUsername
 	}
 
 	trace.SSH.Printf("ssh: Using default auth builder (user: %s)", username)
 
-	auth, err := NewSSHAgentAuth(username)
+	files := hc.identityFiles
+	if len(files) == 0 && t.opts.Prompt != nil {
+		files = defaultIdentityFiles()
+	}
+
+	var agentSigners []gossh.Signer
+	agent, err := NewSSHAgentAuth(username)
+	if err == nil {
+		agentSigners, err = agent.Callback()
+	}
 	if err != nil {
-		return nil, err
+		if len(files) == 0 && t.opts.Prompt == nil {
+			return nil, err
+		}
+		trace.SSH.Printf("ssh: ssh agent unavailable: %v", err)
+	}
+
+	ids := identities(ctx, files, t.opts.Prompt)
+	certFiles := hc.certificateFiles
+	for _, f := range files {
+		certFiles = append(certFiles, f+"-cert.pub")
+	}
+	signers := hostSigners(agentSigners, ids, certificates(certFiles), hc.identitiesOnly)
+
+	methods := []gossh.AuthMethod{
+		tracePublicKeysCallback(func() ([]gossh.Signer, error) { return signers, nil }),
 	}
-	return auth.ClientConfig(ctx, req)
+	if t.opts.Prompt != nil {
+		methods = append(methods,
+			PromptKeyboardInteractive(ctx, t.opts.Prompt),
+			gossh.PasswordCallback(PromptPassword(ctx, t.opts.Prompt, username, hc.hostname)),
+		)
+	}
+
+	return &gossh.ClientConfig{
+		User: username,
+		Auth: methods,
+	}, nil
 }
 
-func (t *Transport) dial(ctx context.Context, network, addr string, config *gossh.ClientConfig) (*gossh.Client, error) {
-	var cancel context.CancelFunc
-	if config.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
-	} else {
-		ctx, cancel = context.WithCancel(ctx)
+// hostKeyAlgorithms applies the HostKeyAlgorithms setting to the algorithms
+// known for the host. As in OpenSSH, a setting starting with + appends
+// algorithms, - removes them, ^ puts them first, and any other replaces
+// them. Removed algorithms can be given as patterns.
+func hostKeyAlgorithms(known []string, setting string) []string {
+	if setting == "" {
+		return known
 	}
-	defer cancel()
 
-	var conn net.Conn
-	var err error
+	base := known
+	if len(base) == 0 {
+		base = gossh.SupportedAlgorithms().HostKeys
+	}
+
+	var list []string
+	for _, a := range strings.Split(setting[1:], ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			list = append(list, a)
+		}
+	}
 
+	switch setting[0] {
+	case '+':
+		return appendMissing(slices.Clone(base), list...)
+	case '^':
+		return appendMissing(list, base...)
+	case '-':
+		var out []string
+		for _, a := range base {
+			if !slices.ContainsFunc(list, func(pattern string) bool {
+				ok, _ := path.Match(pattern, a)
+				return ok
+			}) {
+				out = append(out, a)
+			}
+		}
+		return out
+	default:
+		return strings.Split(setting, ",")
+	}
+}
+
+func appendMissing(list []string, algos ...string) []string {
+	for _, a := range algos {
+		if !slices.Contains(list, a) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// dialTCP establishes a connection to addr, through the configured proxy if
+// any.
+func (t *Transport) dialTCP(ctx context.Context, network, addr string) (net.Conn, error) {
 	switch {
 	case t.opts.DialProxy != nil:
 		dialFn := t.opts.DialContext
@@ -192,16 +317,16 @@ func (t *Transport) dial(ctx context.Context, network, addr string, config *goss
 			dialFn = (&net.Dialer{}).DialContext
 		}
 		trace.SSH.Printf("ssh: using proxyURL for connection")
-		conn, err = t.opts.DialProxy(dialFn)(ctx, network, addr)
+		return t.opts.DialProxy(dialFn)(ctx, network, addr)
 	case t.opts.DialContext != nil:
-		conn, err = t.opts.DialContext(ctx, network, addr)
+		return t.opts.DialContext(ctx, network, addr)
 	default:
-		conn, err = (&net.Dialer{}).DialContext(ctx, network, addr)
-	}
-	if err != nil {
-		return nil, err
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
 	}
+}
 
+// newClient establishes an SSH connection over conn.
+func newClient(conn net.Conn, addr string, config *gossh.ClientConfig) (*gossh.Client, error) {
 	c, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
 	if err != nil {
 		return nil, err
@@ -217,29 +342,11 @@ func (t *Transport) userSettings(ctx context.Context, req *transport.Request) (*
 }
 
 func (t *Transport) resolveHostWithPort(ctx context.Context, req *transport.Request) (string, error) {
-	hostname := req.URL.Hostname()
-	port := req.URL.Port()
-
-	cfg, err := t.userSettings(ctx, req)
+	hc, err := t.hostConfig(ctx, req)
 	if err != nil {
 		return "", err
 	}
-	if configHost := cfg.Get(hostname, "Hostname"); configHost != "" {
-		hostname = configHost
-	}
-	if port == "" {
-		if configPort := cfg.Get(req.URL.Hostname(), "Port"); configPort != "" {
-			if _, err := strconv.Atoi(configPort); err == nil {
-				port = configPort
-			}
-		}
-	}
-
-	if port == "" {
-		port = strconv.Itoa(DefaultPort)
-	}
-
-	return net.JoinHostPort(hostname, port), nil
+	return hc.addr(), nil
 }
 
 type sshConn struct {
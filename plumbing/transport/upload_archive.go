@@ -139,7 +139,7 @@ func UploadArchive(
 		return muxError(mux, w, err)
 	}
 
-	if err = archive.WriteArchive(st, mux, tree, commitHash, commitTime, format, prefix, paths); err != nil {
+	if err = archive.WriteArchive(st, mux, tree, commitHash, commitTime, format, prefix, paths, nil); err != nil {
 		return muxError(mux, w, err)
 	}
 
@@ -113,7 +113,10 @@ func UploadPack(
 	var multiAck, multiAckDetailed bool
 	var caps capability.List
 	var wants []plumbing.Hash
+	var filter *revlist.Filter
+	var sf *shallowFetch
 	var ack packp.ACK
+	var noDone bool
 	firstRound := true
 	for !done {
 		writec := make(chan error)
@@ -125,6 +128,15 @@ func UploadPack(
 
 			wants = upreq.Wants
 			caps = upreq.Capabilities
+			if upreq.Filter != "" {
+				// As upstream, a filter is only accepted when advertised.
+				if !repositoryConfig(st).UploadPack.AllowFilter.IsTrue() {
+					return fmt.Errorf("filtering capability not negotiated")
+				}
+				if filter, err = revlist.ParseFilter(upreq.Filter); err != nil {
+					return err
+				}
+			}
 
 			if err := r.Close(); err != nil {
 				return fmt.Errorf("closing reader: %w", err)
@@ -139,20 +151,31 @@ func UploadPack(
 			// Encode objects to packfile and write to client
 			multiAck = caps.Supports(capability.MultiACK)
 			multiAckDetailed = caps.Supports(capability.MultiACKDetailed)
+			// no-done lets a stateless client skip the round that would only
+			// carry "done": the packfile follows the round the server got
+			// ready in.
+			noDone = opts.StatelessRPC && multiAckDetailed && caps.Supports(capability.NoDone)
+
+			sf, err = newShallowFetch(st, wants, upreq.Shallows, deepenArgs{
+				depth:    upreq.Depth.Deepen,
+				relative: caps.Supports(capability.DeepenRelative),
+				since:    upreq.Depth.DeepenSince,
+				not:      upreq.Depth.DeepenNot,
+			})
+			if err != nil {
+				return err
+			}
 
 			go func() {
-				// TODO: support deepen-since, and deepen-not
-				var shupd packp.ShallowUpdate
 				if !upreq.Depth.IsZero() {
-					if upreq.Depth.Deepen > 0 {
-						if err := getShallowCommits(st, wants, upreq.Depth.Deepen, &shupd); err != nil {
-							writec <- fmt.Errorf("getting shallow commits: %w", err)
-							return
-						}
-					} else {
-						writec <- fmt.Errorf("unsupported depth: %+v", upreq.Depth)
+					shupd, err := sf.update(st, wants)
+					if err != nil {
+						writec <- fmt.Errorf("getting shallow commits: %w", err)
 						return
 					}
+					if shupd == nil {
+						shupd = &packp.ShallowUpdate{}
+					}
 
 					if err := shupd.Encode(w); err != nil {
 						writec <- fmt.Errorf("sending shallow-update: %w", err)
@@ -166,6 +189,14 @@ func UploadPack(
 			if err := <-writec; err != nil {
 				return err
 			}
+
+			// A stateless client sends its wants and depth alone first, to
+			// read the shallow list before it starts negotiating.
+			if opts.StatelessRPC && !upreq.Depth.IsZero() {
+				if _, _, err := pktline.PeekLine(rd); errors.Is(err, io.EOF) {
+					return w.Close()
+				}
+			}
 		}
 
 		var uphav packp.UploadHaves
@@ -181,6 +212,7 @@ func UploadPack(
 		done = uphav.Done
 
 		var acks []packp.ACK
+		var sentReady bool
 		for _, hu := range uphav.Haves {
 			_, ok := havesWithRef[hu]
 
@@ -197,6 +229,7 @@ func UploadPack(
 			if ok || multiAck || multiAckDetailed {
 				ack = packp.ACK{Hash: hu, Status: status}
 				acks = append(acks, ack)
+				sentReady = sentReady || status == packp.ACKReady
 				if !multiAck && !multiAckDetailed {
 					break
 				}
@@ -225,6 +258,15 @@ func UploadPack(
 						return
 					}
 				}
+				if noDone && sentReady {
+					// The client will not send done: send the final ACK
+					// right away, and the packfile after it.
+					srvrsp := packp.ServerResponse{ACKs: []packp.ACK{{Hash: ack.Hash}}}
+					if err := srvrsp.Encode(w); err != nil {
+						writec <- fmt.Errorf("sending final ack server-response: %w", err)
+						return
+					}
+				}
 			case !ack.Hash.IsZero() && (multiAck || multiAckDetailed):
 				// We're done, send the final ACK
 				ack.Status = 0
@@ -257,6 +299,7 @@ func UploadPack(
 			return err
 		}
 
+		done = done || (noDone && sentReady)
 		firstRound = false
 	}
 
@@ -266,10 +309,10 @@ func UploadPack(
 		return fmt.Errorf("closing reader: %w", err)
 	}
 
-	objs, err := objectsToUpload(st, wants, haves)
+	objs, err := sf.objects(st, wants, haves, filter)
 	if err != nil {
 		_ = w.Close()
-		return fmt.Errorf("getting objects to upload: %w", err)
+		return err
 	}
 
 	var (
@@ -314,8 +357,10 @@ func UploadPack(
 	return nil
 }
 
-func objectsToUpload(st storage.Storer, wants, haves []plumbing.Hash) ([]plumbing.Hash, error) {
-	return revlist.Objects(st, wants, haves)
+// objectsToUpload returns the objects to send for wants to a client having
+// haves, omitting those that filter, if any, rejects.
+func objectsToUpload(st storage.Storer, wants, haves []plumbing.Hash, filter *revlist.Filter) ([]plumbing.Hash, error) {
+	return revlist.FilteredObjects(st, wants, haves, filter)
 }
 
 func getShallowCommits(st storage.Storer, heads []plumbing.Hash, depth int, upd *packp.ShallowUpdate) error {
@@ -438,7 +483,8 @@ func shallowFrontierDepth(st storage.Storer, heads, shallows []plumbing.Hash) (i
 	return best, nil
 }
 
-// serveUploadPackV2 handles the git protocol v2 for upload-pack (fetch/ls-refs).
+// serveUploadPackV2 handles the git protocol v2 for upload-pack (fetch, ls-refs,
+// object-info and bundle-uri).
 // It is used when the client requests version=2 via GIT_PROTOCOL.
 func serveUploadPackV2(ctx context.Context, st storage.Storer, rd *bufio.Reader, w io.WriteCloser, opts *UploadPackRequest) error {
 	for {
@@ -466,6 +512,10 @@ func serveUploadPackV2(ctx context.Context, st storage.Storer, rd *bufio.Reader,
 			req.Args = &packp.LsRefsArgs{}
 		case "fetch":
 			req.Args = &packp.FetchArgs{}
+		case "object-info":
+			req.Args = &packp.ObjectInfoArgs{}
+		case "bundle-uri":
+			req.Args = &packp.BundleURIArgs{}
 		default:
 			_, _ = pktline.Writef(w, "error unknown-command %s\n", cmd)
 			_ = pktline.WriteFlush(w)
@@ -496,8 +546,137 @@ func serveUploadPackV2(ctx context.Context, st storage.Storer, rd *bufio.Reader,
 			}
 			// Stateful transport: the round was acknowledgments-only and the
 			// negotiation continues. Loop to read the client's next command.
+		case "object-info":
+			if err := serveObjectInfoV2(ctx, st, w, req.Args.(*packp.ObjectInfoArgs)); err != nil {
+				return err
+			}
+			if opts.StatelessRPC {
+				return nil
+			}
+		case "bundle-uri":
+			if err := serveBundleURIV2(ctx, st, w); err != nil {
+				return err
+			}
+			if opts.StatelessRPC {
+				return nil
+			}
+		}
+	}
+}
+
+// serveObjectInfoV2 responds to an object-info command with the size of each
+// requested object. An object the repository does not have is reported with an
+// empty size rather than failing the command, as upstream's send_info does.
+func serveObjectInfoV2(_ context.Context, st storage.Storer, w io.Writer, args *packp.ObjectInfoArgs) error {
+	out := &packp.ObjectInfoOutput{Size: args.Size}
+	for _, h := range args.OIDs {
+		info := packp.ObjectInfo{Hash: h, Size: -1}
+		if args.Size {
+			size, err := st.EncodedObjectSize(h)
+			switch {
+			case err == nil:
+				info.Size = size
+			case !errors.Is(err, plumbing.ErrObjectNotFound):
+				return fmt.Errorf("reading size of %s: %w", h, err)
+			}
+		}
+		out.Objects = append(out.Objects, info)
+	}
+
+	if err := out.Encode(w); err != nil {
+		return err
+	}
+	return pktline.WriteFlush(w)
+}
+
+// serveBundleURIV2 responds to a bundle-uri command with the bundle list held
+// in the bundle section of the repository's config, one key and value per
+// line. Keys are lowercased, as upstream writes them, except for the bundle
+// ids.
+func serveBundleURIV2(_ context.Context, st storage.Storer, w io.Writer) error {
+	out := &packp.BundleURIOutput{}
+	if cfg, err := st.Config(); err == nil && cfg != nil && cfg.Raw != nil {
+		for _, s := range cfg.Raw.Sections {
+			if !s.IsName("bundle") {
+				continue
+			}
+			for _, o := range s.Options {
+				out.Entries = append(out.Entries, packp.BundleURIEntry{
+					Key:   "bundle." + strings.ToLower(o.Key),
+					Value: o.Value,
+				})
+			}
+			for _, ss := range s.Subsections {
+				for _, o := range ss.Options {
+					out.Entries = append(out.Entries, packp.BundleURIEntry{
+						Key:   "bundle." + ss.Name + "." + strings.ToLower(o.Key),
+						Value: o.Value,
+					})
+				}
+			}
+		}
+	}
+
+	if err := out.Encode(w); err != nil {
+		return err
+	}
+	return pktline.WriteFlush(w)
+}
+
+// excludePackfileURIs removes from objs the blobs configured in
+// uploadpack.blobPackfileUri to be served from a URI with one of the client's
+// accepted protocols, and returns the packfile-uris lines for their packs.
+// Mirroring upstream pack-objects, a pack is listed once however many of its
+// blobs are in objs, and the configured packs are trusted to hold them.
+func excludePackfileURIs(st storage.Storer, objs []plumbing.Hash, protocols []string) ([]plumbing.Hash, *packp.PackfileURIs, error) {
+	cfg, err := st.Config()
+	if err != nil || cfg == nil || len(cfg.UploadPack.BlobPackfileURIs) == 0 {
+		return objs, nil, nil
+	}
+
+	offloaded := make(map[plumbing.Hash]string)
+	for _, v := range cfg.UploadPack.BlobPackfileURIs {
+		fields := strings.Fields(v)
+		if len(fields) != 3 || !plumbing.IsHash(fields[0]) || !plumbing.IsHash(fields[1]) {
+			return nil, nil, fmt.Errorf("invalid uploadpack.blobPackfileUri %q", v)
+		}
+		if !uriHasProtocol(fields[2], protocols) {
+			continue
+		}
+		offloaded[plumbing.NewHash(fields[0])] = fields[1] + " " + fields[2]
+	}
+	if len(offloaded) == 0 {
+		return objs, nil, nil
+	}
+
+	uris := &packp.PackfileURIs{}
+	listed := make(map[string]bool)
+	kept := objs[:0:0]
+	for _, h := range objs {
+		line, ok := offloaded[h]
+		if !ok {
+			kept = append(kept, h)
+			continue
 		}
+		if !listed[line] {
+			listed[line] = true
+			uris.URIs = append(uris.URIs, line)
+		}
+	}
+	if len(uris.URIs) == 0 {
+		return objs, nil, nil
 	}
+	return kept, uris, nil
+}
+
+// uriHasProtocol reports whether uri uses one of protocols.
+func uriHasProtocol(uri string, protocols []string) bool {
+	for _, p := range protocols {
+		if strings.HasPrefix(uri, p+"://") {
+			return true
+		}
+	}
+	return false
 }
 
 // serveLsRefsV2 responds to a ls-refs command using the decoded arguments.
@@ -621,6 +800,15 @@ func peelToNonTag(st storage.Storer, h plumbing.Hash) (plumbing.Hash, bool) {
 	}
 }
 
+// repositoryConfig returns the config of st, or an empty one when it cannot
+// be read.
+func repositoryConfig(st storage.Storer) *config.Config {
+	if cfg, err := st.Config(); err == nil && cfg != nil {
+		return cfg
+	}
+	return config.NewConfig()
+}
+
 // serveFetchV2 handles command=fetch for v2 using the decoded arguments. The
 // acknowledgments, shallow-info, and packfile-header sections are emitted
 // through packp.FetchOutput; this function streams the packfile data after the
@@ -636,17 +824,57 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 	wants := args.Wants
 	haves := args.Haves
 	clientShallows := args.Shallows
-	depth := args.Deepen
 	done := args.Done
+	cfg := repositoryConfig(st)
+
+	// filter, want-ref and sideband-all are only accepted when advertised, as
+	// upstream rejects them as unexpected lines otherwise.
+	var filter *revlist.Filter
+	if args.Filter != "" {
+		if !cfg.UploadPack.AllowFilter.IsTrue() {
+			_ = w.Close()
+			return true, fmt.Errorf("unexpected line: filter")
+		}
+		var err error
+		if filter, err = revlist.ParseFilter(args.Filter); err != nil {
+			_ = w.Close()
+			return true, err
+		}
+	}
+
+	if len(args.WantRefs) > 0 && !cfg.UploadPack.AllowRefInWant.IsTrue() {
+		_ = w.Close()
+		return true, fmt.Errorf("unexpected line: want-ref")
+	}
+	if args.SidebandAll && !cfg.UploadPack.AllowSidebandAll.IsTrue() {
+		_ = w.Close()
+		return true, fmt.Errorf("unexpected line: sideband-all")
+	}
+
+	// want-ref: each reference is resolved now, so the client gets the
+	// objects it points at when the packfile is built, and is told their
+	// values in the wanted-refs section.
+	var wantedRefs []*plumbing.Reference
+	for _, name := range args.WantRefs {
+		ref, err := storer.ResolveReference(st, name)
+		if err != nil {
+			_, _ = pktline.WriteError(w, fmt.Errorf("unknown ref %s", name))
+			_ = w.Close()
+			return true, fmt.Errorf("unknown ref %s", name)
+		}
+		wantedRefs = append(wantedRefs, plumbing.NewHashReference(name, ref.Hash()))
+		wants = append(wants, ref.Hash())
+	}
 
 	// No 'want' lines: the client guessed it didn't want anything. Upstream
 	// emits no response at all here (upload-pack.c, UPLOAD_DONE), so write
-	// nothing and just close the stream, no stray flush packet.
-	if len(wants) == 0 {
+	// nothing and just close the stream, no stray flush packet. A client
+	// sending wait-for-done only negotiates, and may send haves alone.
+	if len(wants) == 0 && !args.WaitForDone {
 		return true, w.Close()
 	}
 
-	out := &packp.FetchOutput{}
+	out := &packp.FetchOutput{SidebandAll: args.SidebandAll}
 
 	// Negotiation (acknowledgments section), per gitprotocol-v2 "fetch":
 	//
@@ -659,6 +887,8 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 	//                        same response. Otherwise the section ends without a
 	//                        packfile and the client negotiates again with more
 	//                        haves (NAK when there is no common object at all).
+	//                        With wait-for-done, "ready" is never sent: the
+	//                        client negotiates until it sends done.
 	if !done && len(haves) > 0 {
 		var common []plumbing.Hash
 		for _, h := range haves {
@@ -674,7 +904,7 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 		// ready (including no common object at all, which encodes as NAK), the
 		// acknowledgments section stands alone and the client refines its haves
 		// in the next request.
-		if len(common) == 0 || !wantsReachableFromHaves(st, wants, common) {
+		if args.WaitForDone || len(common) == 0 || !wantsReachableFromHaves(st, wants, common) {
 			if err := out.Encode(w); err != nil {
 				return true, err
 			}
@@ -690,123 +920,31 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 		out.Acknowledgments.Ready = true
 	}
 
-	// shallow-info: a shallow fetch bounds the history sent. The boundary forms
-	// mirror upstream send_shallow_list (upload-pack.c):
-	//   - deepen <n>: a depth boundary from the wants (getShallowCommits).
-	//   - deepen-since / deepen-not: a date/ref boundary (getShallowCommitsByRevList,
-	//     mirroring deepen_by_rev_list).
-	// Upstream forbids combining deepen with deepen-since/deepen-not, and so do we.
-	// deepen-relative only changes how the depth is counted: for a fresh fetch
-	// (no client shallows) relative and absolute depth coincide, and for an
-	// already-shallow client the depth is offset by the existing boundary's
-	// distance from the wants (see the deepen-relative handling below).
-	since := args.DeepenSince
-	notTips, err := resolveDeepenNot(st, args.DeepenNot)
+	// shallow-info: a shallow fetch bounds the history sent; see
+	// newShallowFetch.
+	sf, err := newShallowFetch(st, wants, clientShallows, deepenArgs{
+		depth:    args.Deepen,
+		relative: args.DeepenRelative,
+		since:    args.DeepenSince,
+		not:      args.DeepenNot,
+	})
 	if err != nil {
 		_ = w.Close()
-		return true, fmt.Errorf("resolving deepen-not: %w", err)
+		return true, err
 	}
-	revList := !since.IsZero() || len(notTips) > 0
-	if depth > 0 && revList {
+	upd, err := sf.update(st, wants)
+	if err != nil {
 		_ = w.Close()
-		return true, fmt.Errorf("deepen and deepen-since (or deepen-not) cannot be used together")
-	}
-
-	// A deepen was requested when depth > 0 or a rev-list bound was given.
-	// haveNewBoundary records that separately from len(newBoundary): a deepen
-	// that reaches full history yields an empty boundary, which still drives
-	// shallow-info and the unshallow lines and must not be mistaken for "no
-	// deepen requested". newBoundary is the grafting boundary for the deepened
-	// view (nil/empty means graft nothing: full history).
-	var newBoundary []plumbing.Hash
-	var haveNewBoundary bool
-	if depth > 0 || revList {
-		var shupd packp.ShallowUpdate
-		computed := true
-		if revList {
-			err = getShallowCommitsByRevList(st, wants, since, notTips, &shupd)
-		} else {
-			effectiveDepth := depth
-			if args.DeepenRelative && len(clientShallows) > 0 {
-				// deepen-relative counts depth from the client's existing
-				// shallow boundary, not from the wants. Mirror upstream
-				// get_shallow_commits (shallow.c): offset the absolute depth by
-				// the depth at which that boundary sits from the wants.
-				cur, derr := shallowFrontierDepth(st, wants, clientShallows)
-				if derr != nil {
-					_ = w.Close()
-					return true, fmt.Errorf("computing shallow frontier depth: %w", derr)
-				}
-				if cur == 0 {
-					// No client shallow is reachable from the wants; upstream
-					// computes no new boundary and leaves the client's view
-					// unchanged. Skip the deepen entirely.
-					computed = false
-				} else {
-					effectiveDepth = depth + cur
-				}
-			}
-			if computed {
-				err = getShallowCommits(st, wants, effectiveDepth, &shupd)
-			}
-		}
-		if err != nil {
-			_ = w.Close()
-			return true, fmt.Errorf("computing shallow commits: %w", err)
-		}
-		if computed {
-			haveNewBoundary = true
-			newBoundary = shupd.Shallows
-		}
-	}
-
-	var objs []plumbing.Hash
-	if len(clientShallows) > 0 {
-		// The client already has a shallow view (it sent "shallow" lines).
-		// A single object walk cannot graft the wanted history at the new
-		// boundary while also grafting the client's have-history at its existing
-		// boundary, so compute two views and send their difference:
-		//   newView    = objects reachable from the wants, grafted at the new
-		//                boundary (the client's deepened view).
-		//   clientView = objects the client already has, reachable from its haves
-		//                grafted at its existing shallow boundary.
-		// newView \ clientView is exactly what the client is missing. It never
-		// omits a needed object; at worst it re-sends one the client has, which
-		// is harmless. This is what bounds a deepen of an already-shallow clone.
-		boundary := clientShallows
-		if haveNewBoundary {
-			// The deepened boundary, which may be empty: a deepen that reaches
-			// full history grafts nothing and unshallows the old boundary.
-			boundary = newBoundary
-		}
-		newView, nerr := objectsToUpload(&shallowBoundaryStorer{Storer: st, boundary: boundary}, wants, nil)
-		if nerr != nil {
-			_ = w.Close()
-			return true, fmt.Errorf("getting objects to upload: %w", nerr)
-		}
-		clientView, cerr := objectsToUpload(&shallowBoundaryStorer{Storer: st, boundary: clientShallows}, haves, nil)
-		if cerr != nil {
-			_ = w.Close()
-			return true, fmt.Errorf("getting client objects: %w", cerr)
-		}
-		objs = hashDifference(newView, clientView)
-		if haveNewBoundary {
-			out.ShallowInfo = &packp.ShallowInfo{
-				Shallows:   newBoundary,
-				Unshallows: unshallowedCommits(clientShallows, newBoundary, newView),
-			}
-		}
-	} else {
-		packSt := st
-		if haveNewBoundary && len(newBoundary) > 0 {
-			out.ShallowInfo = &packp.ShallowInfo{Shallows: newBoundary}
-			packSt = &shallowBoundaryStorer{Storer: st, boundary: newBoundary}
-		}
-		objs, err = objectsToUpload(packSt, wants, haves)
-		if err != nil {
-			_ = w.Close()
-			return true, fmt.Errorf("getting objects to upload: %w", err)
-		}
+		return true, err
+	}
+	if upd != nil {
+		out.ShallowInfo = &packp.ShallowInfo{Shallows: upd.Shallows, Unshallows: upd.Unshallows}
+	}
+
+	objs, err := sf.objects(st, wants, haves, filter)
+	if err != nil {
+		_ = w.Close()
+		return true, err
 	}
 
 	// include-tag: add annotated tags whose target is in the pack (auto-tag
@@ -819,6 +957,20 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 		}
 	}
 
+	// packfile-uris: blobs configured to be served from a pre-generated pack
+	// are left out of the packfile, and the client downloads that pack.
+	if len(args.PackfileURIs) > 0 {
+		objs, out.PackfileURIs, err = excludePackfileURIs(st, objs, args.PackfileURIs)
+		if err != nil {
+			_ = w.Close()
+			return true, err
+		}
+	}
+
+	if len(wantedRefs) > 0 {
+		out.WantedRefs = &packp.WantedRefs{Refs: wantedRefs}
+	}
+
 	// Emit the metadata sections and the "packfile" section header. The client
 	// switches to sideband demux after seeing the header, matching reference git.
 	out.Packfile = true
@@ -853,6 +1005,192 @@ func serveFetchV2(_ context.Context, st storage.Storer, w io.WriteCloser, args *
 	return true, w.Close()
 }
 
+// deepenArgs are the deepen arguments of a fetch request, in either protocol
+// version.
+type deepenArgs struct {
+	depth    int
+	relative bool
+	since    time.Time
+	not      []string
+}
+
+// shallowFetch is the shallow boundary a fetch is served with: the one the
+// client already has and, when it asked to deepen, the new one.
+type shallowFetch struct {
+	clientShallows []plumbing.Hash
+	// boundary is the new boundary, which deepened tells apart from none
+	// being requested: a deepen reaching the full history has an empty one.
+	boundary []plumbing.Hash
+	deepened bool
+}
+
+// newShallowFetch computes the shallow boundary of a fetch of wants by a
+// client whose shallow commits are clientShallows. The boundary forms mirror
+// upstream send_shallow_list (upload-pack.c):
+//   - deepen <n>: a depth boundary from the wants (getShallowCommits).
+//   - deepen-since / deepen-not: a date/ref boundary (getShallowCommitsByRevList,
+//     mirroring deepen_by_rev_list).
+//
+// Upstream forbids combining deepen with deepen-since/deepen-not, and so do we.
+// deepen-relative only changes how the depth is counted: for a fresh fetch
+// (no client shallows) relative and absolute depth coincide, and for an
+// already-shallow client the depth is offset by the existing boundary's
+// distance from the wants.
+func newShallowFetch(st storage.Storer, wants, clientShallows []plumbing.Hash, d deepenArgs) (*shallowFetch, error) {
+	sf := &shallowFetch{clientShallows: clientShallows}
+
+	notTips, err := resolveDeepenNot(st, d.not)
+	if err != nil {
+		return nil, fmt.Errorf("resolving deepen-not: %w", err)
+	}
+	revList := !d.since.IsZero() || len(notTips) > 0
+	if d.depth > 0 && revList {
+		return nil, fmt.Errorf("deepen and deepen-since (or deepen-not) cannot be used together")
+	}
+	if d.depth <= 0 && !revList {
+		return sf, nil
+	}
+
+	var shupd packp.ShallowUpdate
+	if revList {
+		err = getShallowCommitsByRevList(st, wants, d.since, notTips, &shupd)
+	} else {
+		depth := d.depth
+		if d.relative && len(clientShallows) > 0 {
+			// deepen-relative counts depth from the client's existing
+			// shallow boundary, not from the wants. Mirror upstream
+			// get_shallow_commits (shallow.c): offset the absolute depth by
+			// the depth at which that boundary sits from the wants.
+			cur, derr := shallowFrontierDepth(st, wants, clientShallows)
+			if derr != nil {
+				return nil, fmt.Errorf("computing shallow frontier depth: %w", derr)
+			}
+			if cur == 0 {
+				// No client shallow is reachable from the wants; upstream
+				// computes no new boundary and leaves the client's view
+				// unchanged. Skip the deepen entirely.
+				return sf, nil
+			}
+			depth += cur
+		}
+		err = getShallowCommits(st, wants, depth, &shupd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("computing shallow commits: %w", err)
+	}
+
+	sf.deepened = true
+	sf.boundary = shupd.Shallows
+	return sf, nil
+}
+
+// update returns the shallow boundary update to send the client, or nil when
+// there is none: no deepen was requested, or a fresh fetch reached the full
+// history. The client's shallow commits that the deepened view includes as
+// interior commits are unshallowed.
+func (sf *shallowFetch) update(st storage.Storer, wants []plumbing.Hash) (*packp.ShallowUpdate, error) {
+	if !sf.deepened {
+		return nil, nil
+	}
+	if len(sf.clientShallows) == 0 {
+		if len(sf.boundary) == 0 {
+			return nil, nil
+		}
+		return &packp.ShallowUpdate{Shallows: sf.boundary}, nil
+	}
+
+	view, err := commitsWithinBoundary(&shallowBoundaryStorer{Storer: st, boundary: sf.boundary}, wants)
+	if err != nil {
+		return nil, fmt.Errorf("walking the deepened history: %w", err)
+	}
+	return &packp.ShallowUpdate{
+		Shallows:   sf.boundary,
+		Unshallows: unshallowedCommits(sf.clientShallows, sf.boundary, view),
+	}, nil
+}
+
+// objects returns the objects to send for wants to a client having haves,
+// bounded by the shallow boundary and omitting those filter, if any, rejects.
+func (sf *shallowFetch) objects(st storage.Storer, wants, haves []plumbing.Hash, filter *revlist.Filter) ([]plumbing.Hash, error) {
+	if len(sf.clientShallows) == 0 {
+		packSt := st
+		if len(sf.boundary) > 0 {
+			packSt = &shallowBoundaryStorer{Storer: st, boundary: sf.boundary}
+		}
+		objs, err := objectsToUpload(packSt, wants, haves, filter)
+		if err != nil {
+			return nil, fmt.Errorf("getting objects to upload: %w", err)
+		}
+		return objs, nil
+	}
+
+	// The client already has a shallow view (it sent "shallow" lines).
+	// A single object walk cannot graft the wanted history at the new
+	// boundary while also grafting the client's have-history at its existing
+	// boundary, so compute two views and send their difference:
+	//   newView    = objects reachable from the wants, grafted at the new
+	//                boundary (the client's deepened view).
+	//   clientView = objects the client already has, reachable from its haves
+	//                grafted at its existing shallow boundary.
+	// newView \ clientView is exactly what the client is missing. It never
+	// omits a needed object; at worst it re-sends one the client has, which
+	// is harmless. This is what bounds a deepen of an already-shallow clone.
+	boundary := sf.clientShallows
+	if sf.deepened {
+		// The deepened boundary, which may be empty: a deepen that reaches
+		// full history grafts nothing and unshallows the old boundary.
+		boundary = sf.boundary
+	}
+	newView, err := objectsToUpload(&shallowBoundaryStorer{Storer: st, boundary: boundary}, wants, nil, filter)
+	if err != nil {
+		return nil, fmt.Errorf("getting objects to upload: %w", err)
+	}
+	clientView, err := objectsToUpload(&shallowBoundaryStorer{Storer: st, boundary: sf.clientShallows}, haves, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting client objects: %w", err)
+	}
+	return hashDifference(newView, clientView), nil
+}
+
+// commitsWithinBoundary returns the commits reachable from heads without
+// walking past the shallow commits of st, which are included, as the object
+// walk of a shallow fetch does.
+func commitsWithinBoundary(st storage.Storer, heads []plumbing.Hash) (map[plumbing.Hash]struct{}, error) {
+	shallows, err := st.Shallow()
+	if err != nil {
+		return nil, err
+	}
+	stop := make(map[plumbing.Hash]struct{}, len(shallows))
+	for _, h := range shallows {
+		stop[h] = struct{}{}
+	}
+
+	seen := make(map[plumbing.Hash]struct{})
+	var stack []plumbing.Hash
+	for _, h := range heads {
+		if c, ok := peelToCommit(st, h); ok {
+			stack = append(stack, c.Hash)
+		}
+	}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		if _, ok := stop[h]; ok {
+			continue
+		}
+		c, err := object.GetCommit(st, h)
+		if err != nil {
+			continue
+		}
+		stack = append(stack, c.ParentHashes...)
+	}
+	return seen, nil
+}
+
 // hashDifference returns the elements of a that are not in b, preserving a's
 // order. It computes the objects a deepened client is missing (newView minus the
 // client's existing view).
@@ -874,11 +1212,7 @@ func hashDifference(a, b []plumbing.Hash) []plumbing.Hash {
 // now includes as interior commits (their parents are being sent), so the client
 // can clear their shallow mark. Commits still on the new boundary stay shallow.
 // Mirrors upstream send_unshallow (upload-pack.c).
-func unshallowedCommits(clientShallows, newBoundary, newView []plumbing.Hash) []plumbing.Hash {
-	inView := make(map[plumbing.Hash]struct{}, len(newView))
-	for _, h := range newView {
-		inView[h] = struct{}{}
-	}
+func unshallowedCommits(clientShallows, newBoundary []plumbing.Hash, inView map[plumbing.Hash]struct{}) []plumbing.Hash {
 	boundary := make(map[plumbing.Hash]struct{}, len(newBoundary))
 	for _, h := range newBoundary {
 		boundary[h] = struct{}{}
@@ -896,9 +1230,10 @@ func unshallowedCommits(clientShallows, newBoundary, newView []plumbing.Hash) []
 	return out
 }
 
-// resolveDeepenNot resolves each deepen-not argument (a ref name or an object
-// id) to a commit hash, peeling annotated tags, mirroring how upstream feeds
-// "--not <oid>" to rev-list (upload-pack.c send_shallow_list).
+// resolveDeepenNot resolves each deepen-not argument (a ref name, possibly
+// short as in "v1.0", or an object id) to a commit hash, peeling annotated
+// tags, mirroring how upstream feeds "--not <oid>" to rev-list (upload-pack.c
+// send_shallow_list).
 func resolveDeepenNot(st storage.Storer, refs []string) ([]plumbing.Hash, error) {
 	if len(refs) == 0 {
 		return nil, nil
@@ -906,7 +1241,7 @@ func resolveDeepenNot(st storage.Storer, refs []string) ([]plumbing.Hash, error)
 	out := make([]plumbing.Hash, 0, len(refs))
 	for _, r := range refs {
 		var h plumbing.Hash
-		if ref, err := storer.ResolveReference(st, plumbing.ReferenceName(r)); err == nil {
+		if ref, ok := expandRef(st, r); ok {
 			h = ref.Hash()
 		} else if oid, ok := plumbing.FromHex(r); ok {
 			if _, err := st.EncodedObject(plumbing.AnyObject, oid); err != nil {
@@ -924,6 +1259,18 @@ func resolveDeepenNot(st storage.Storer, refs []string) ([]plumbing.Hash, error)
 	return out, nil
 }
 
+// expandRef resolves name with the rules upstream's expand_ref uses, so that
+// a short name matches the reference it abbreviates.
+func expandRef(st storage.Storer, name string) (*plumbing.Reference, bool) {
+	for _, rule := range plumbing.RefRevParseRules {
+		ref, err := storer.ResolveReference(st, plumbing.ReferenceName(fmt.Sprintf(rule, name)))
+		if err == nil {
+			return ref, true
+		}
+	}
+	return nil, false
+}
+
 // reachableCommits returns the set of commits reachable from tips (inclusive),
 // used as the exclusion set for deepen-not.
 func reachableCommits(st storage.Storer, tips []plumbing.Hash) (map[plumbing.Hash]struct{}, error) {
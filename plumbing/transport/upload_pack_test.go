@@ -195,6 +195,71 @@ func (s *UploadPackServeSuite) TestUploadPackStatefulMultiRoundSendsFinalACK() {
 	s.Require().NotEqual(-1, finalAt)
 }
 
+func (s *UploadPackServeSuite) TestUploadPackNoDoneSendsPackAfterReady() {
+	dot, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(s.T().TempDir))
+	s.Require().NoError(err)
+	st := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	s.T().Cleanup(func() { _ = st.Close() })
+
+	head, err := storer.ResolveReference(st, plumbing.HEAD)
+	s.Require().NoError(err)
+	have := plumbing.NewHash("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+
+	var upreq packp.UploadRequest
+	upreq.Capabilities.Add(capability.MultiACKDetailed)
+	upreq.Capabilities.Add(capability.NoDone)
+	upreq.Capabilities.Add(capability.NoProgress)
+	upreq.Wants = append(upreq.Wants, head.Hash())
+
+	// The client gets ready in this round and, with no-done, never sends
+	// done: the final ACK and the packfile must follow the NAK.
+	var uphav packp.UploadHaves
+	uphav.Haves = []plumbing.Hash{have}
+
+	var reqW bytes.Buffer
+	s.Require().NoError(upreq.Encode(&reqW))
+	s.Require().NoError(uphav.Encode(&reqW))
+	buf := testServe(s.T(), st, UploadPack, io.NopCloser(&reqW), &UploadPackRequest{
+		GitProtocol:  "version=1",
+		StatelessRPC: true,
+	})
+
+	expected := fmt.Sprintf("ACK %s ready\n", have)
+	expected = fmt.Sprintf("%04x%s0008NAK\n0031ACK %s\nPACK", len(expected)+4, expected, have)
+	s.Equal(expected, buf.String()[:len(expected)])
+}
+
+func (s *UploadPackServeSuite) TestUploadPackDeepenNot() {
+	dot, err := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(s.T().TempDir))
+	s.Require().NoError(err)
+	st := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	s.T().Cleanup(func() { _ = st.Close() })
+
+	head, err := storer.ResolveReference(st, plumbing.HEAD)
+	s.Require().NoError(err)
+
+	var upreq packp.UploadRequest
+	upreq.Capabilities.Add(capability.NoProgress)
+	upreq.Capabilities.Add(capability.DeepenNot)
+	upreq.Wants = append(upreq.Wants, head.Hash())
+	upreq.Depth = packp.DepthRequest{DeepenNot: []string{"branch"}}
+
+	var uphav packp.UploadHaves
+	uphav.Done = true
+
+	var reqW bytes.Buffer
+	s.Require().NoError(upreq.Encode(&reqW))
+	s.Require().NoError(uphav.Encode(&reqW))
+	buf := testServe(s.T(), st, UploadPack, io.NopCloser(&reqW), &UploadPackRequest{
+		GitProtocol:  "version=1",
+		StatelessRPC: true,
+	})
+
+	// The history of branch is excluded: the tip is the shallow boundary.
+	expected := fmt.Sprintf("0035shallow %s\n00000008NAK\nPACK", head.Hash())
+	s.Equal(expected, buf.String()[:len(expected)])
+}
+
 type ReceivePackServeSuite struct {
 	suite.Suite
 }
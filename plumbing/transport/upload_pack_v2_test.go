@@ -10,6 +10,7 @@ import (
 	fixtures "github.com/go-git/go-git-fixtures/v6"
 	"github.com/stretchr/testify/require"
 
+	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
@@ -80,7 +81,8 @@ func TestUploadPackV2AdvertisementCapabilities(t *testing.T) {
 
 	require.Contains(t, adv, "version 2")
 	require.Contains(t, adv, "ls-refs")
-	require.Contains(t, adv, "fetch=shallow")
+	require.Contains(t, adv, "fetch=shallow wait-for-done")
+	require.Contains(t, adv, "object-info")
 	require.Contains(t, adv, "object-format=")
 
 	// git omits the smart-HTTP "# service=..." line for v2 even over HTTP
@@ -93,9 +95,16 @@ func TestUploadPackV2AdvertisementCapabilities(t *testing.T) {
 	// Capabilities the server does not implement must not be advertised,
 	// otherwise clients request features that are silently dropped or
 	// produce malformed responses.
-	require.NotContains(t, adv, "wait-for-done")
 	require.NotContains(t, adv, "unborn")
 	require.NotContains(t, adv, "server-option")
+
+	// filter, ref-in-want, sideband-all, packfile-uris and bundle-uri are
+	// only advertised when configured.
+	require.NotContains(t, adv, "filter")
+	require.NotContains(t, adv, "ref-in-want")
+	require.NotContains(t, adv, "sideband-all")
+	require.NotContains(t, adv, "packfile-uris")
+	require.NotContains(t, adv, "bundle-uri")
 }
 
 func TestUploadPackV2LsRefsPeeledInline(t *testing.T) {
@@ -142,13 +151,13 @@ func TestObjectsToUploadShallowBounded(t *testing.T) {
 	require.NotEmpty(t, c.ParentHashes, "HEAD must have a parent for this test")
 	parent := c.ParentHashes[0]
 
-	full, err := objectsToUpload(st, []plumbing.Hash{head.Hash()}, nil)
+	full, err := objectsToUpload(st, []plumbing.Hash{head.Hash()}, nil, nil)
 	require.NoError(t, err)
 	require.Contains(t, full, parent, "unbounded pack should include the parent commit")
 
 	bounded, err := objectsToUpload(
 		&shallowBoundaryStorer{Storer: st, boundary: []plumbing.Hash{head.Hash()}},
-		[]plumbing.Hash{head.Hash()}, nil,
+		[]plumbing.Hash{head.Hash()}, nil, nil,
 	)
 	require.NoError(t, err)
 	require.Contains(t, bounded, head.Hash(), "shallow boundary commit must be included")
@@ -292,3 +301,89 @@ func TestUploadPackV2FetchNotReadyContinuesNegotiation(t *testing.T) {
 	require.NotContains(t, out, "ready")
 	require.NotContains(t, out, "packfile")
 }
+
+func TestUploadPackV2FetchWaitForDoneWithholdsReady(t *testing.T) {
+	t.Parallel()
+	st := basicV2Storage(t)
+	head, err := storer.ResolveReference(st, plumbing.HEAD)
+	require.NoError(t, err)
+
+	// The have anchors the want, which would make the server ready, but with
+	// wait-for-done the client negotiates until it sends done.
+	out := serveUploadPackV2Test(t, st, v2Request(t, "fetch", nil, []string{
+		"want " + head.Hash().String(),
+		"have " + head.Hash().String(),
+		"wait-for-done",
+	}))
+
+	require.Contains(t, out, "ACK "+head.Hash().String())
+	require.NotContains(t, out, "ready")
+	require.NotContains(t, out, "packfile")
+}
+
+func TestUploadPackV2FetchWantRef(t *testing.T) {
+	t.Parallel()
+	st := basicV2Storage(t)
+	head, err := storer.ResolveReference(st, plumbing.HEAD)
+	require.NoError(t, err)
+
+	req := []string{"want-ref refs/heads/master", "done"}
+
+	var buf bytes.Buffer
+	err = UploadPack(context.TODO(), st, v2Request(t, "fetch", nil, req),
+		ioutil.WriteNopCloser(&buf),
+		&UploadPackRequest{GitProtocol: "version=2", StatelessRPC: true},
+	)
+	require.ErrorContains(t, err, "want-ref", "want-ref is rejected unless allowed")
+
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.UploadPack.AllowRefInWant = config.NewOptBool(true)
+	require.NoError(t, st.SetConfig(cfg))
+
+	out := serveUploadPackV2Test(t, st, v2Request(t, "fetch", nil, req))
+	require.Contains(t, out, "wanted-refs")
+	require.Contains(t, out, head.Hash().String()+" refs/heads/master")
+	require.Less(t, strings.Index(out, "wanted-refs"), strings.Index(out, "packfile"),
+		"wanted-refs must precede the packfile section")
+}
+
+func TestUploadPackV2FetchSidebandAll(t *testing.T) {
+	t.Parallel()
+	st := basicV2Storage(t)
+	head, err := storer.ResolveReference(st, plumbing.HEAD)
+	require.NoError(t, err)
+
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.UploadPack.AllowSidebandAll = config.NewOptBool(true)
+	require.NoError(t, st.SetConfig(cfg))
+
+	out := serveUploadPackV2Test(t, st, v2Request(t, "fetch", nil, []string{
+		"want " + head.Hash().String(),
+		"have " + head.Hash().String(),
+		"sideband-all",
+	}))
+
+	// Every section line is sent on the data band.
+	rd := strings.NewReader(out)
+	var sections []string
+	for {
+		l, line, err := pktline.ReadLine(rd)
+		require.NoError(t, err)
+		if l == pktline.Flush {
+			break
+		}
+		if l < pktline.LenSize {
+			continue
+		}
+		require.Equal(t, byte(1), line[0], "%q is not on the data band", line)
+		sections = append(sections, strings.TrimSuffix(string(line[1:]), "\n"))
+	}
+	require.Equal(t, []string{
+		"acknowledgments",
+		"ACK " + head.Hash().String(),
+		"ready",
+		"packfile",
+	}, sections[:4])
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bundle"
+	"github.com/go-git/go-git/v6/plumbing/protocol/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+)
+
+// ObjectInfo returns the size of the given objects in the remote repository,
+// without downloading them, using the Protocol v2 object-info command. An
+// object the server does not have is reported with a size of -1.
+//
+// It fails with ErrObjectInfoNotSupported when the session did not negotiate
+// Protocol v2 or the server does not advertise object-info.
+func ObjectInfo(ctx context.Context, sess Session, hashes []plumbing.Hash) ([]packp.ObjectInfo, error) {
+	cmd, ok := sess.(Commander)
+	if !ok || !sess.Capabilities().Supports(capability.ObjectInfo) {
+		return nil, ErrObjectInfoNotSupported
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	out := &packp.ObjectInfoOutput{}
+	if err := cmd.Command(ctx, "object-info", &packp.ObjectInfoArgs{Size: true, OIDs: hashes}, out); err != nil {
+		return nil, err
+	}
+	return out.Objects, nil
+}
+
+// BundleList returns the bundle list the remote repository advertises with
+// the Protocol v2 bundle-uri command, or nil when it advertises no bundles.
+//
+// It fails with ErrBundleURINotSupported when the session did not negotiate
+// Protocol v2 or the server does not advertise bundle-uri.
+func BundleList(ctx context.Context, sess Session) (*bundle.List, error) {
+	cmd, ok := sess.(Commander)
+	if !ok || !sess.Capabilities().Supports(capability.BundleURI) {
+		return nil, ErrBundleURINotSupported
+	}
+
+	out := &packp.BundleURIOutput{}
+	if err := cmd.Command(ctx, "bundle-uri", &packp.BundleURIArgs{}, out); err != nil {
+		return nil, err
+	}
+	if len(out.Entries) == 0 {
+		return nil, nil
+	}
+
+	list := &bundle.List{}
+	for _, e := range out.Entries {
+		if err := list.Set(e.Key, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	internal "github.com/go-git/go-git/v6/internal/transport"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bundle"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// basicBlob is a blob of the basic fixture's master tree (CHANGELOG).
+const basicBlob = "d3ff53e0564a9f87d8e84b6e28e5060e517008aa"
+
+func TestV2ObjectInfo(t *testing.T) {
+	t.Parallel()
+	serverSt := basicV2Storage(t)
+	s := newV2Session(t, serveUploadPackV2Once(serverSt))
+
+	blob := plumbing.NewHash(basicBlob)
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+	size, err := serverSt.EncodedObjectSize(blob)
+	require.NoError(t, err)
+
+	infos, err := ObjectInfo(context.TODO(), s, []plumbing.Hash{blob, missing})
+	require.NoError(t, err)
+	assert.Equal(t, []packp.ObjectInfo{
+		{Hash: blob, Size: size},
+		{Hash: missing, Size: -1},
+	}, infos)
+}
+
+func TestObjectInfoNotSupported(t *testing.T) {
+	t.Parallel()
+
+	_, err := ObjectInfo(context.TODO(), &StreamSession{}, []plumbing.Hash{plumbing.NewHash(basicBlob)})
+	require.ErrorIs(t, err, ErrObjectInfoNotSupported)
+}
+
+func TestV2BundleList(t *testing.T) {
+	t.Parallel()
+	serverSt := basicV2Storage(t)
+
+	cfg, err := serverSt.Config()
+	require.NoError(t, err)
+	require.NoError(t, cfg.Unmarshal([]byte(`[uploadpack]
+	advertiseBundleURIs = true
+[bundle]
+	version = 1
+	mode = all
+	heuristic = creationToken
+[bundle "Daily"]
+	uri = https://example.com/daily.bundle
+	creationToken = 2
+`)))
+	require.NoError(t, serverSt.SetConfig(cfg))
+
+	s := newV2Session(t, serveUploadPackV2Once(serverSt))
+	list, err := BundleList(context.TODO(), s)
+	require.NoError(t, err)
+	assert.Equal(t, &bundle.List{
+		Version:   1,
+		Mode:      bundle.ModeAll,
+		Heuristic: bundle.HeuristicCreationToken,
+		Bundles:   []*bundle.Info{{ID: "Daily", URI: "https://example.com/daily.bundle", CreationToken: 2}},
+	}, list)
+}
+
+func TestV2BundleListNotAdvertised(t *testing.T) {
+	t.Parallel()
+	s := newV2Session(t, serveUploadPackV2Once(basicV2Storage(t)))
+	_, err := BundleList(context.TODO(), s)
+	// No command was sent: closing the session ends the server's loop.
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, err, ErrBundleURINotSupported)
+}
+
+// servePack serves a pack holding objs of st, returning its URL, the pack's
+// checksum and the number of requests served.
+func servePack(t *testing.T, st storage.Storer, objs ...plumbing.Hash) (string, plumbing.Hash, *atomic.Int32) {
+	t.Helper()
+
+	var pack bytes.Buffer
+	checksum, err := packfile.NewEncoder(&pack, st, false).Encode(objs, 10)
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(pack.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/blob.pack", checksum, &requests
+}
+
+func setBlobPackfileURI(t *testing.T, st storage.Storer, blob, pack plumbing.Hash, uri string) {
+	t.Helper()
+	cfg, err := st.Config()
+	require.NoError(t, err)
+	cfg.UploadPack.BlobPackfileURIs = []string{blob.String() + " " + pack.String() + " " + uri}
+	require.NoError(t, st.SetConfig(cfg))
+}
+
+func TestV2FetchPackfileURIs(t *testing.T) {
+	t.Parallel()
+	serverSt := basicV2Storage(t)
+	blob := plumbing.NewHash(basicBlob)
+	uri, checksum, requests := servePack(t, serverSt, blob)
+	setBlobPackfileURI(t, serverSt, blob, checksum, uri)
+
+	s := newV2Session(t, serveUploadPackV2Once(serverSt))
+	require.True(t, internal.FetchSupports(s.caps, "packfile-uris"))
+
+	clientSt := memory.NewStorage()
+	err := s.Fetch(context.TODO(), clientSt, &FetchRequest{
+		Wants:        []plumbing.Hash{plumbing.NewHash(basicMasterHash)},
+		PackfileURIs: []string{"http", "https"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	require.NoError(t, clientSt.HasEncodedObject(blob))
+	require.NoError(t, clientSt.HasEncodedObject(plumbing.NewHash(basicMasterHash)))
+}
+
+func TestV2FetchPackfileURIsNotRequested(t *testing.T) {
+	t.Parallel()
+	serverSt := basicV2Storage(t)
+	blob := plumbing.NewHash(basicBlob)
+	uri, checksum, requests := servePack(t, serverSt, blob)
+	setBlobPackfileURI(t, serverSt, blob, checksum, uri)
+
+	// A client accepting only https URIs gets the blob inline.
+	s := newV2Session(t, serveUploadPackV2Once(serverSt))
+	clientSt := memory.NewStorage()
+	err := s.Fetch(context.TODO(), clientSt, &FetchRequest{
+		Wants:        []plumbing.Hash{plumbing.NewHash(basicMasterHash)},
+		PackfileURIs: []string{"https"},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, requests.Load())
+	require.NoError(t, clientSt.HasEncodedObject(blob))
+}
+
+func TestV2FetchPackfileURIsChecksumMismatch(t *testing.T) {
+	t.Parallel()
+	serverSt := basicV2Storage(t)
+	blob := plumbing.NewHash(basicBlob)
+	uri, _, _ := servePack(t, serverSt, blob)
+	setBlobPackfileURI(t, serverSt, blob, plumbing.NewHash(basicMasterHash), uri)
+
+	s := newV2Session(t, serveUploadPackV2Once(serverSt))
+	err := s.Fetch(context.TODO(), memory.NewStorage(), &FetchRequest{
+		Wants:        []plumbing.Hash{plumbing.NewHash(basicMasterHash)},
+		PackfileURIs: []string{"http"},
+	})
+	require.ErrorContains(t, err, "checksum mismatch")
+}
@@ -188,7 +188,7 @@ func writeV2PackfileSection(w net.Conn, st storage.Storer, wants, haves []plumbi
 	if _, err := pktline.WriteString(w, "packfile\n"); err != nil {
 		return err
 	}
-	objs, err := objectsToUpload(st, wants, haves)
+	objs, err := objectsToUpload(st, wants, haves, nil)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,163 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/protocol"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/promisor"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+)
+
+// noLazyFetchEnv disables the fetching of missing objects from the
+// promisor remote, as it does for git.
+const noLazyFetchEnv = "GIT_NO_LAZY_FETCH"
+
+// enableLazyFetch makes reading an object a promisor remote withheld from a
+// partial clone fetch it from the remote, as git does. Repositories that are
+// not partial clones, or whose storage is not a filesystem one, are left
+// alone.
+func (r *Repository) enableLazyFetch() {
+	if os.Getenv(noLazyFetchEnv) == "1" {
+		return
+	}
+
+	fs, ok := r.Storer.(*filesystem.Storage)
+	if !ok || !isPartialClone(fs) {
+		return
+	}
+
+	r.Storer = promisor.NewStorage(fs, &promisorFetcher{})
+}
+
+// unwrapPromisor returns the storage of s whose reads do not fetch from the
+// promisor remote.
+func unwrapPromisor(s storage.Storer) storage.Storer {
+	if ps, ok := s.(*promisor.Storage); ok {
+		return ps.Unwrap()
+	}
+	return s
+}
+
+// promisorFetcher fetches the objects of a partial clone from its promisor
+// remote, as git does with `git fetch --filter=blob:none --stdin`.
+type promisorFetcher struct{}
+
+// FetchObjects implements promisor.Fetcher.
+func (f *promisorFetcher) FetchObjects(ctx context.Context, st storage.Storer, hashes []plumbing.Hash) (err error) {
+	cfg, err := st.Config()
+	if err != nil {
+		return err
+	}
+	rc := promisorRemote(cfg)
+	if rc == nil {
+		return errors.New("no promisor remote")
+	}
+
+	remote := NewRemote(st, rc)
+	url := rc.URLs[0]
+	opts, err := remote.clientOptions(url, nil)
+	if err != nil {
+		return err
+	}
+	cl, req, err := newClient(url, opts)
+	if err != nil {
+		return err
+	}
+
+	req.Command = transport.UploadPackService
+	req.Protocol = protocol.V2
+	sess, err := cl.Handshake(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(sess, &err)
+
+	// Trees are fetched without the blobs they reference, which are fetched
+	// in turn if they are read.
+	var filter packp.Filter
+	if transport.SupportsFilter(sess.Capabilities()) {
+		filter = packp.FilterBlobNone()
+	}
+
+	err = sess.Fetch(ctx, st, &transport.FetchRequest{
+		Wants:    hashes,
+		Filter:   filter,
+		Promisor: true,
+	})
+	if errors.Is(err, transport.ErrNoChange) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("remote %s: %w", rc.Name, err)
+	}
+	return nil
+}
+
+// promisorRemote returns the remote objects are lazily fetched from: the one
+// named by extensions.partialClone, or else the first promisor remote.
+func promisorRemote(cfg *config.Config) *config.RemoteConfig {
+	name := cfg.Raw.Section("extensions").Options.Get("partialClone")
+	if rc, ok := cfg.Remotes[name]; ok && len(rc.URLs) > 0 {
+		return rc
+	}
+
+	names := make([]string, 0, len(cfg.Remotes))
+	for name, rc := range cfg.Remotes {
+		if rc.Promisor && len(rc.URLs) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return cfg.Remotes[names[0]]
+}
+
+// prefetchChanges fetches in a single request the blobs that checking out
+// changes needs and a promisor remote withheld, rather than one at a time as
+// they are read. Only the changes whose path is in files, if any, are
+// considered.
+func (w *Worktree) prefetchChanges(changes merkletrie.Changes, idx *index.Index, files map[string]struct{}) error {
+	ps, ok := w.r.Storer.(*promisor.Storage)
+	if !ok || len(changes) == 0 {
+		return nil
+	}
+
+	entries := make(map[string]*index.Entry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		entries[e.Name] = e
+	}
+
+	var hashes []plumbing.Hash
+	for _, ch := range changes {
+		if ch.To == nil {
+			continue
+		}
+		name := ch.To.String()
+		if len(files) > 0 && !inFiles(files, name) {
+			continue
+		}
+		e, ok := entries[name]
+		if !ok || e.Mode == filemode.Submodule {
+			continue
+		}
+		hashes = append(hashes, e.Hash)
+	}
+
+	// The fetch is bounded by the timeout of the storage.
+	return ps.FetchMissing(context.Background(), hashes)
+}
@@ -0,0 +1,166 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/promisor"
+)
+
+// TestLazyFetchOnPartialClone covers reading the objects a promisor remote
+// withheld from a blob:none clone: each is fetched on first read and stored in
+// a promisor pack, so git still accepts the repository afterwards.
+func TestLazyFetchOnPartialClone(t *testing.T) {
+	t.Parallel()
+	requireGitPartialClone(t)
+
+	dir := newPartialClone(t, "blob:none")
+	before := missingObjects(t, dir)
+	markers := len(promisorMarkers(t, dir))
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	require.IsType(t, &promisor.Storage{}, r.Storer)
+
+	head, err := r.Reference("refs/remotes/origin/main", true)
+	require.NoError(t, err)
+	commit, err := r.CommitObject(head.Hash())
+	require.NoError(t, err)
+
+	// Reading a withheld blob fetches it.
+	f, err := commit.File("file.txt")
+	require.NoError(t, err)
+	content, err := f.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "four\n", content)
+	assert.Equal(t, before-1, missingObjects(t, dir))
+
+	// Diffing against the parent fetches the blob it needs.
+	parent, err := commit.Parent(0)
+	require.NoError(t, err)
+	patch, err := parent.Patch(commit)
+	require.NoError(t, err)
+	assert.Contains(t, patch.String(), "-three\n+four\n")
+	assert.Equal(t, before-2, missingObjects(t, dir))
+
+	// Checking out populates the worktree.
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&CheckoutOptions{Hash: head.Hash(), Force: true}))
+	b, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "four\n", string(b))
+
+	assert.Greater(t, len(promisorMarkers(t, dir)), markers, "fetched objects must land in promisor packs")
+	requireNoOrphanMarkers(t, dir)
+	requireFsckClean(t, dir)
+
+	// An object the remote does not have stays missing.
+	_, err = r.BlobObject(plumbing.NewHash("0123456789012345678901234567890123456789"))
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+}
+
+func TestLazyFetchDisabled(t *testing.T) { //nolint:paralleltest // sets GIT_NO_LAZY_FETCH
+	requireGitPartialClone(t)
+
+	dir := newPartialClone(t, "blob:none")
+	t.Setenv(noLazyFetchEnv, "1")
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	assert.IsType(t, &filesystem.Storage{}, r.Storer)
+
+	head, err := r.Reference("refs/remotes/origin/main", true)
+	require.NoError(t, err)
+	commit, err := r.CommitObject(head.Hash())
+	require.NoError(t, err)
+	tree, err := commit.Tree()
+	require.NoError(t, err)
+	entry, err := tree.FindEntry("file.txt")
+	require.NoError(t, err)
+	_, err = r.BlobObject(entry.Hash)
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+}
+
+// TestPartialCloneWithFilter covers a blob:none clone served by the go-git
+// upload-pack: the blobs are withheld, git accepts the result, and reading
+// one fetches it from the same server.
+func TestPartialCloneWithFilter(t *testing.T) {
+	t.Parallel()
+	requireGitPartialClone(t)
+
+	src := strings.TrimSpace(git(t, newPartialClone(t, "blob:none"), "config", "remote.origin.url"))
+	dir := filepath.Join(t.TempDir(), "clone")
+
+	r, err := PlainClone(dir, &CloneOptions{
+		URL:           src,
+		ReferenceName: plumbing.NewBranchReferenceName("main"),
+		Filter:        packp.FilterBlobNone(),
+		NoCheckout:    true,
+	})
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	assert.Equal(t, 4, missingObjects(t, dir))
+	requireFsckClean(t, dir)
+
+	head, err := r.Reference("refs/remotes/origin/main", true)
+	require.NoError(t, err)
+	commit, err := r.CommitObject(head.Hash())
+	require.NoError(t, err)
+	f, err := commit.File("file.txt")
+	require.NoError(t, err)
+	content, err := f.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "four\n", content)
+	assert.Equal(t, 3, missingObjects(t, dir))
+	requireFsckClean(t, dir)
+}
+
+// TestFetchOnPartialCloneDoesNotLazyFetch covers fetching new tips into a
+// partial clone: as git, the tips are looked for locally only, rather than
+// each being fetched from the promisor remote before the fetch itself.
+func TestFetchOnPartialCloneDoesNotLazyFetch(t *testing.T) {
+	t.Parallel()
+	requireGitPartialClone(t)
+
+	dir := newPartialClone(t, "blob:none")
+	seed := filepath.Join(filepath.Dir(dir), "seed")
+	for _, branch := range []string{"a", "b", "c"} {
+		git(t, seed, "checkout", "-q", "-b", branch, "main")
+		require.NoError(t, os.WriteFile(filepath.Join(seed, "file.txt"), []byte(branch+"\n"), 0o644))
+		git(t, seed, "commit", "-qam", branch)
+		git(t, seed, "tag", "-a", "-m", branch, "v-"+branch)
+	}
+	git(t, seed, "push", "-q", "--tags", "origin", "a", "b", "c")
+	packs := func() int {
+		m, err := filepath.Glob(filepath.Join(packDir(dir), "*.pack"))
+		require.NoError(t, err)
+		return len(m)
+	}
+	before := packs()
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	require.IsType(t, &promisor.Storage{}, r.Storer)
+
+	require.NoError(t, r.Fetch(&FetchOptions{RemoteName: "origin", Tags: plumbing.AllTags}))
+	assert.Equal(t, before+1, packs(), "the tips must be fetched at once")
+
+	for _, name := range []string{"refs/remotes/origin/a", "refs/tags/v-c"} {
+		_, err := r.Reference(plumbing.ReferenceName(name), false)
+		require.NoError(t, err, name)
+	}
+	requireFsckClean(t, dir)
+}
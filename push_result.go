@@ -0,0 +1,130 @@
+package git
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+)
+
+// ErrRefUpdateNotReported is the error of a reference update the server
+// did not report the outcome of.
+var ErrRefUpdateNotReported = errors.New("server did not report the status of the update")
+
+// PushResult describes the outcome of a push.
+type PushResult struct {
+	// RefUpdates are the outcomes of the reference updates, in the order
+	// the server reported them. A server rewriting a reference may report
+	// more than one update for it.
+	RefUpdates []*PushRefUpdate
+}
+
+// Accepted returns the reference updates the server accepted.
+func (r *PushResult) Accepted() []*PushRefUpdate {
+	var updates []*PushRefUpdate
+	for _, u := range r.RefUpdates {
+		if u.Err == nil {
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// Rejected returns the reference updates the server rejected.
+func (r *PushResult) Rejected() []*PushRefUpdate {
+	var updates []*PushRefUpdate
+	for _, u := range r.RefUpdates {
+		if u.Err != nil {
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// PushRefUpdate is the outcome of the update of a remote reference.
+type PushRefUpdate struct {
+	// Name is the remote reference the push asked to update.
+	Name plumbing.ReferenceName
+	// Ref is the reference the server updated. It is Name unless the server
+	// updated another reference in its place, as with AGit-style
+	// refs/for/* pushes.
+	Ref plumbing.ReferenceName
+	// Old is the value of Ref before the update.
+	Old plumbing.Hash
+	// New is the value of Ref after the update.
+	New plumbing.Hash
+	// ForcedUpdate reports that the server made a non fast-forward update.
+	// It is only known from servers supporting report-status-v2.
+	ForcedUpdate bool
+	// Err is why the server rejected the update, nil if it accepted it.
+	Err error
+}
+
+// newPushResult builds the result of the push of cmds from the report of
+// the server, which is empty if the server sent none.
+func newPushResult(cmds []*packp.Command, report *packp.ReportStatus) *PushResult {
+	result := &PushResult{}
+	if report.UnpackStatus == "" {
+		// The server does not report the status of the updates.
+		for _, cmd := range cmds {
+			result.RefUpdates = append(result.RefUpdates, &PushRefUpdate{
+				Name: cmd.Name,
+				Ref:  cmd.Name,
+				Old:  cmd.Old,
+				New:  cmd.New,
+			})
+		}
+		return result
+	}
+
+	byName := make(map[plumbing.ReferenceName]*packp.Command, len(cmds))
+	for _, cmd := range cmds {
+		byName[cmd.Name] = cmd
+	}
+
+	reported := make(map[plumbing.ReferenceName]bool, len(cmds))
+	for _, cs := range report.CommandStatuses {
+		reported[cs.ReferenceName] = true
+		u := &PushRefUpdate{
+			Name:         cs.ReferenceName,
+			Ref:          cs.ReferenceName,
+			ForcedUpdate: cs.ForcedUpdate,
+			Err:          cs.Error(),
+		}
+		if cmd, ok := byName[cs.ReferenceName]; ok {
+			u.Old, u.New = cmd.Old, cmd.New
+		}
+		if cs.RefName != "" {
+			u.Ref = cs.RefName
+		}
+		if cs.OldHash != nil {
+			u.Old = *cs.OldHash
+		}
+		if cs.NewHash != nil {
+			u.New = *cs.NewHash
+		}
+		result.RefUpdates = append(result.RefUpdates, u)
+	}
+
+	unreported := error(ErrRefUpdateNotReported)
+	if err := report.Error(); err != nil {
+		var unpackErr packp.UnpackStatusErr
+		if errors.As(err, &unpackErr) {
+			unreported = err
+		}
+	}
+	for _, cmd := range cmds {
+		if reported[cmd.Name] {
+			continue
+		}
+		result.RefUpdates = append(result.RefUpdates, &PushRefUpdate{
+			Name: cmd.Name,
+			Ref:  cmd.Name,
+			Old:  cmd.Old,
+			New:  cmd.New,
+			Err:  unreported,
+		})
+	}
+
+	return result
+}
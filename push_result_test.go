@@ -0,0 +1,76 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+)
+
+func TestNewPushResult(t *testing.T) {
+	t.Parallel()
+
+	oldHash := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	newHash := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	changeHash := plumbing.NewHash("3ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	cmds := []*packp.Command{
+		{Name: "refs/for/master/topic", New: newHash},
+		{Name: "refs/heads/master", Old: oldHash, New: newHash},
+		{Name: "refs/heads/other", Old: oldHash, New: newHash},
+	}
+	report := &packp.ReportStatus{
+		UnpackStatus: "ok",
+		CommandStatuses: []*packp.CommandStatus{{
+			ReferenceName: "refs/for/master/topic",
+			Status:        "ok",
+			RefName:       "refs/changes/24/124/1",
+			OldHash:       &oldHash,
+			NewHash:       &changeHash,
+			ForcedUpdate:  true,
+		}, {
+			ReferenceName: "refs/heads/master",
+			Status:        "non-fast-forward",
+		}},
+	}
+
+	result := newPushResult(cmds, report)
+	require.Len(t, result.RefUpdates, 3)
+
+	rewritten := result.RefUpdates[0]
+	assert.Equal(t, plumbing.ReferenceName("refs/for/master/topic"), rewritten.Name)
+	assert.Equal(t, plumbing.ReferenceName("refs/changes/24/124/1"), rewritten.Ref)
+	assert.Equal(t, oldHash, rewritten.Old)
+	assert.Equal(t, changeHash, rewritten.New)
+	assert.True(t, rewritten.ForcedUpdate)
+	assert.NoError(t, rewritten.Err)
+
+	rejected := result.RefUpdates[1]
+	assert.Equal(t, plumbing.ReferenceName("refs/heads/master"), rejected.Ref)
+	assert.Equal(t, oldHash, rejected.Old)
+	assert.Equal(t, newHash, rejected.New)
+	assert.ErrorContains(t, rejected.Err, "non-fast-forward")
+
+	unreported := result.RefUpdates[2]
+	assert.Equal(t, plumbing.ReferenceName("refs/heads/other"), unreported.Name)
+	assert.ErrorIs(t, unreported.Err, ErrRefUpdateNotReported)
+
+	assert.Equal(t, []*PushRefUpdate{rewritten}, result.Accepted())
+	assert.Equal(t, []*PushRefUpdate{rejected, unreported}, result.Rejected())
+}
+
+func TestNewPushResultWithoutReportStatus(t *testing.T) {
+	t.Parallel()
+
+	newHash := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	cmds := []*packp.Command{{Name: "refs/heads/master", New: newHash}}
+
+	result := newPushResult(cmds, &packp.ReportStatus{})
+	require.Len(t, result.RefUpdates, 1)
+	assert.Equal(t, plumbing.ReferenceName("refs/heads/master"), result.RefUpdates[0].Ref)
+	assert.Equal(t, newHash, result.RefUpdates[0].New)
+	assert.NoError(t, result.RefUpdates[0].Err)
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -22,20 +25,27 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/revlist"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+	xhttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	xssh "github.com/go-git/go-git/v6/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/trace"
+	"github.com/go-git/go-git/v6/x/plugin"
 )
 
 // Remote operation errors and sentinel values.
 var (
-	NoErrAlreadyUpToDate     = errors.New("already up-to-date") //nolint:staticcheck,revive // sentinel value, not an error
-	ErrDeleteRefNotSupported = errors.New("server does not support delete-refs")
-	ErrForceNeeded           = errors.New("some refs were not updated")
-	ErrExactSHA1NotSupported = errors.New("server does not support exact SHA1 refspec")
-	ErrEmptyUrls             = errors.New("URLs cannot be empty")
-	ErrRemoteRefNotFound     = errors.New("couldn't find remote ref")
+	NoErrAlreadyUpToDate      = errors.New("already up-to-date") //nolint:staticcheck,revive // sentinel value, not an error
+	ErrDeleteRefNotSupported  = errors.New("server does not support delete-refs")
+	ErrForceNeeded            = errors.New("some refs were not updated")
+	ErrExactSHA1NotSupported  = errors.New("server does not support exact SHA1 refspec")
+	ErrEmptyUrls              = errors.New("URLs cannot be empty")
+	ErrRemoteRefNotFound      = errors.New("couldn't find remote ref")
+	ErrUnshallowComplete      = errors.New("unshallow on a complete repository does not make sense")
+	ErrNegotiationTipNotFound = errors.New("negotiation tip does not match any reference")
+	ErrMissingPusher          = errors.New("pusher field is required")
 )
 
 const (
@@ -47,6 +57,10 @@ const (
 
 	// peeledSuffix is the suffix used to build peeled reference names.
 	peeledSuffix = "^{}"
+
+	// infiniteDepth is the depth requested to unshallow a repository, as
+	// git's INFINITE_DEPTH.
+	infiniteDepth = 0x7fffffff
 )
 
 // Remote represents a connection to a remote repository.
@@ -89,7 +103,16 @@ func (r *Remote) Push(o *PushOptions) error {
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects the
 // transport operations.
-func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
+func (r *Remote) PushContext(ctx context.Context, o *PushOptions) error {
+	_, err := r.PushWithResult(ctx, o)
+	return err
+}
+
+// PushWithResult performs a push to the remote, as PushContext does, and
+// returns the outcome of each reference update. The result is also returned
+// along with the error of a push some references of which were rejected,
+// and nil when no update was sent.
+func (r *Remote) PushWithResult(ctx context.Context, o *PushOptions) (_ *PushResult, err error) {
 	if trace.Performance.Enabled() {
 		start := time.Now()
 		defer func() {
@@ -98,43 +121,48 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 	}
 
 	if err := o.Validate(); err != nil {
-		return err
+		return nil, err
 	}
 
 	if o.RemoteName != r.c.Name {
-		return fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
+		return nil, fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
 	}
 
 	if o.RemoteURL == "" && len(r.c.URLs) > 0 {
 		o.RemoteURL = r.c.URLs[len(r.c.URLs)-1]
 	}
 
-	cl, req, err := newClient(o.RemoteURL, o.ClientOptions)
+	opts, err := r.clientOptions(o.RemoteURL, o.ClientOptions)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	cl, req, err := newClient(o.RemoteURL, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	req.Command = transport.ReceivePackService
 	sess, err := cl.Handshake(ctx, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer ioutil.CheckClose(sess, &err)
 
 	rRefs, err := sess.GetRemoteRefs(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	remoteRefs := referenceStorageFromRefs(rRefs.References, true)
 	if err := r.checkRequireRemoteRefs(o.RequireRemoteRefs, remoteRefs); err != nil {
-		return err
+		return nil, err
 	}
 
 	return r.sendPack(ctx, sess, remoteRefs, o)
 }
 
-func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRefs storer.ReferenceStorer, o *PushOptions) error {
+func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRefs storer.ReferenceStorer, o *PushOptions) (*PushResult, error) {
 	isDelete := false
 	allDelete := true
 	for _, rs := range o.RefSpecs {
@@ -151,7 +179,7 @@ func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRef
 	// TODO: support delete-refs
 	caps := sess.Capabilities() // server capabilities
 	if isDelete && !caps.Supports(capability.DeleteRefs) {
-		return ErrDeleteRefNotSupported
+		return nil, ErrDeleteRefNotSupported
 	}
 
 	if o.Force {
@@ -165,33 +193,33 @@ func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRef
 
 	localRefs, err := reference.References(r.s)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	cmds := make([]*packp.Command, 0)
 	if err := r.addReferencesToUpdate(o.RefSpecs, localRefs, remoteRefs, &cmds, o.Prune, o.ForceWithLease); err != nil {
-		return err
+		return nil, err
 	}
 
 	if o.FollowTags {
 		if err := r.addReachableTags(localRefs, remoteRefs, &cmds); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if len(cmds) == 0 {
-		return NoErrAlreadyUpToDate
+		return nil, NoErrAlreadyUpToDate
 	}
 
 	objects := objectsToPush(cmds)
 	haves, err := referencesToHashes(remoteRefs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stop, err := r.s.Shallow()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// if we have shallow we should include this as part of the objects that
@@ -203,10 +231,14 @@ func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRef
 	if !allDelete {
 		hashesToPush, err = revlist.Objects(r.s, objects, haves)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	if err := r.uploadLFSObjects(ctx, o.RemoteURL, o.ClientOptions, hashesToPush); err != nil {
+		return nil, err
+	}
+
 	if len(hashesToPush) == 0 {
 		allDelete = true
 		for _, command := range cmds {
@@ -217,11 +249,15 @@ func (r *Remote) sendPack(ctx context.Context, sess transport.Session, remoteRef
 		}
 	}
 
-	if err := pushHashes(ctx, sess, r.s, cmds, hashesToPush, allDelete, o); err != nil {
-		return err
+	report, err := pushHashes(ctx, sess, r.s, cmds, hashesToPush, allDelete, o)
+	if err != nil {
+		if report == nil || report.UnpackStatus == "" {
+			return nil, err
+		}
+		return newPushResult(cmds, report), err
 	}
 
-	return r.updateRemoteReferenceStorage(cmds)
+	return newPushResult(cmds, report), r.updateRemoteReferenceStorage(cmds)
 }
 
 func (r *Remote) useRefDeltas(ar *packp.AdvRefs) bool {
@@ -448,7 +484,12 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		o.RemoteURL = r.c.URLs[0]
 	}
 
-	cl, req, err := newClient(o.RemoteURL, o.ClientOptions)
+	opts, err := r.clientOptions(o.RemoteURL, o.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	cl, req, err := newClient(o.RemoteURL, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -472,6 +513,12 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
+	cfgs, err := rawConfigs(r.s)
+	if err != nil {
+		return nil, err
+	}
+	r.fetchBundles(ctx, sess, cl, o, cfgs)
+
 	remoteRefs := referenceStorageFromRefs(rRefs.References, true)
 	localRefs, err := reference.References(r.s)
 	if err != nil {
@@ -482,14 +529,22 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
+	depth := o.Depth
 	var shallows []plumbing.Hash
-	if o.Depth != 0 {
+	if o.Depth != 0 || len(o.DeepenNot) > 0 || o.Unshallow {
 		shallows, err = r.s.Shallow()
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if o.Unshallow {
+		if len(shallows) == 0 {
+			return nil, ErrUnshallowComplete
+		}
+		depth = infiniteDepth
+	}
+
 	isWildcard := true
 	for _, s := range o.RefSpecs {
 		if !s.IsWildcard() {
@@ -499,9 +554,25 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	}
 
 	var haves []plumbing.Hash
-	wants, _ := getWants(r.s, refs, o.Depth)
+	var negotiator transport.Negotiator
+	// As git, the objects of a partial clone are looked for locally only:
+	// the promisor remote is not asked for the tips being fetched.
+	wants, _ := getWants(unwrapPromisor(r.s), refs, depth)
 	if len(wants) > 0 {
-		haves, err = getHaves(localRefs, remoteRefs, r.s, o.Depth)
+		tips, err := negotiationTips(r.s, localRefs, o.NegotiationTips)
+		if err != nil {
+			return nil, err
+		}
+
+		algorithm := o.NegotiationAlgorithm
+		if algorithm == "" {
+			algorithm = negotiationAlgorithm(cfgs)
+		}
+		if algorithm != "" {
+			negotiator, err = newRemoteNegotiator(algorithm, r.s, tips, remoteRefs, shallows)
+		} else {
+			haves, err = getHaves(tips, remoteRefs, r.s, depth)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -529,13 +600,18 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		}
 
 		req := &transport.FetchRequest{
-			Wants:       wants,
-			Haves:       haves,
-			Depth:       o.Depth,
-			Progress:    o.Progress,
-			IncludeTags: isWildcard && o.Tags == plumbing.TagFollowing,
-			Filter:      o.Filter,
-		}
+			Wants:          wants,
+			Haves:          haves,
+			Negotiator:     negotiator,
+			Depth:          depth,
+			DeepenRelative: o.DeepenRelative,
+			DeepenNot:      o.DeepenNot,
+			Progress:       o.Progress,
+			IncludeTags:    isWildcard && o.Tags == plumbing.TagFollowing,
+			Filter:         o.Filter,
+		}
+		req.PackfileURIs = r.uriProtocols(cfgs)
+		req.HTTPClient = httpClient(cl, o.RemoteURL)
 
 		if err := sess.Fetch(ctx, r.s, req); err != nil && !errors.Is(err, transport.ErrNoChange) {
 			// Note: We receive ErrNoChange when remote is the same as local. At
@@ -573,7 +649,7 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	if !updated && !updatedPrune {
 		// No references updated, but may have fetched new objects, check if we now have any of our wants
 		for _, hash := range wants {
-			exists, _ := objectExists(r.s, hash)
+			exists, _ := objectExists(unwrapPromisor(r.s), hash)
 			if exists {
 				updated = true
 				break
@@ -588,6 +664,145 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	return remoteRefs, nil
 }
 
+// fetchBundles downloads the bundles a clone starts from: the bundle at
+// o.bundleURI or, when transfer.bundleURI is true, those the server
+// advertises. Their branches are stored under refs/bundles/, so the fetch that
+// follows only transfers what they lack. As in git, a bundle that cannot be
+// downloaded or applied does not fail the clone, it only leaves more for the
+// fetch; a warning is written to o.Progress.
+func (r *Remote) fetchBundles(ctx context.Context, sess transport.Session, cl *client.Client, o *FetchOptions, cfgs []*formatcfg.Config) {
+	var err error
+	switch {
+	case o.bundleURI != "":
+		err = r.fetchBundleURI(ctx, httpClient(cl, o.bundleURI), o.bundleURI)
+	case o.bundleURIs && r.transferBundleURI(cfgs) &&
+		sess.Capabilities().Supports(capability.BundleURI):
+		err = r.fetchAdvertisedBundles(ctx, sess, httpClient(cl, o.RemoteURL), o.RemoteURL)
+	}
+	if err != nil && o.Progress != nil {
+		_, _ = fmt.Fprintf(o.Progress, "warning: failed to fetch bundles: %v\n", err)
+	}
+}
+
+// transferBundleURI reports whether transfer.bundleURI is true in the
+// repository's config or, when unset there, in the system or global config.
+func (r *Remote) transferBundleURI(cfgs []*formatcfg.Config) bool {
+	if cfg, err := r.s.Config(); err == nil && cfg.Transfer.BundleURI.IsSet() {
+		return cfg.Transfer.BundleURI.IsTrue()
+	}
+	switch strings.ToLower(rawOption(cfgs, "transfer", "bundleURI")) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// uriProtocols returns the packfile URI protocols of fetch.uriProtocols, from
+// the repository's config or, when unset there, the system or global config.
+func (r *Remote) uriProtocols(cfgs []*formatcfg.Config) []string {
+	if cfg, err := r.s.Config(); err == nil && len(cfg.Fetch.URIProtocols) > 0 {
+		return cfg.Fetch.URIProtocols
+	}
+	var protocols []string
+	for p := range strings.SplitSeq(rawOption(cfgs, "fetch", "uriProtocols"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			protocols = append(protocols, p)
+		}
+	}
+	return protocols
+}
+
+// negotiationAlgorithm returns the algorithm fetch.negotiationAlgorithm
+// selects, if set. As in git, unknown values select the consecutive one.
+func negotiationAlgorithm(cfgs []*formatcfg.Config) NegotiationAlgorithm {
+	switch v := rawOption(cfgs, "fetch", "negotiationAlgorithm"); v {
+	case "":
+		return ""
+	case string(SkippingNegotiation), string(NoopNegotiation):
+		return NegotiationAlgorithm(v)
+	default:
+		return ConsecutiveNegotiation
+	}
+}
+
+// negotiationTips returns the local references whose history is negotiated:
+// those matching tips or, when there are none, all of them. A tip is a
+// reference glob, a commit hash or a reference name, short names expanded as
+// git does. As git, a glob matching no reference is ignored, while a hash or
+// a name not found is an error.
+func negotiationTips(
+	s storer.ReferenceStorer,
+	localRefs []*plumbing.Reference,
+	tips []string,
+) ([]*plumbing.Reference, error) {
+	if len(tips) == 0 {
+		return localRefs, nil
+	}
+
+	var refs []*plumbing.Reference
+	for _, tip := range tips {
+		n := len(refs)
+		switch {
+		case strings.ContainsAny(tip, "*?["):
+			pattern := tip
+			if !strings.HasPrefix(pattern, "refs/") {
+				pattern = "refs/" + pattern
+			}
+			for _, ref := range localRefs {
+				if ok, _ := path.Match(pattern, ref.Name().String()); ok {
+					refs = append(refs, ref)
+				}
+			}
+			continue
+		case plumbing.IsHash(tip):
+			refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(tip), plumbing.NewHash(tip)))
+		default:
+			for _, rule := range plumbing.RefRevParseRules {
+				ref, err := storer.ResolveReference(s, plumbing.ReferenceName(fmt.Sprintf(rule, tip)))
+				if err == nil {
+					refs = append(refs, ref)
+					break
+				}
+			}
+		}
+
+		if len(refs) == n {
+			return nil, fmt.Errorf("%w: %s", ErrNegotiationTipNotFound, tip)
+		}
+	}
+
+	return refs, nil
+}
+
+// newRemoteNegotiator returns the negotiator of the given algorithm, walking
+// the history of tips and knowing the commits remoteRefs point to as common.
+func newRemoteNegotiator(
+	algorithm NegotiationAlgorithm,
+	s storage.Storer,
+	tips []*plumbing.Reference,
+	remoteRefs storer.ReferenceStorer,
+	shallows []plumbing.Hash,
+) (transport.Negotiator, error) {
+	common, err := getRemoteRefsFromStorer(remoteRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	commonHashes := make([]plumbing.Hash, 0, len(common))
+	for h := range common {
+		commonHashes = append(commonHashes, h)
+	}
+
+	tipHashes := make([]plumbing.Hash, 0, len(tips))
+	for _, ref := range tips {
+		if ref.Type() == plumbing.HashReference {
+			tipHashes = append(tipHashes, ref.Hash())
+		}
+	}
+
+	return newNegotiator(algorithm, s, tipHashes, commonHashes, shallows), nil
+}
+
 func referenceStorageFromRefs(refs []*plumbing.Reference, filterPeeled bool) memory.ReferenceStorage {
 	refStore := memory.ReferenceStorage{}
 	for _, ref := range refs {
@@ -632,6 +847,133 @@ func newClient(rawURL string, opts []client.Option) (*client.Client, *transport.
 	return cl, &transport.Request{URL: u}, nil
 }
 
+// httpClient returns the client sending the HTTP requests of a fetch with cl
+// made outside of its transport, to the bundle and packfile URIs the server
+// points at, so that they use the proxy and TLS settings of the remote. As
+// those URIs are chosen by the server, only the requests to the origin of
+// rawURL are sent with the credentials of the remote. It is http.DefaultClient
+// when the https scheme is served by another transport.
+func httpClient(cl *client.Client, rawURL string) transport.HTTPClient {
+	tr, err := cl.Transport("https")
+	if err != nil {
+		return http.DefaultClient
+	}
+	htr, ok := tr.(*xhttp.Transport)
+	if !ok {
+		return http.DefaultClient
+	}
+	origin, err := url.Parse(rawURL)
+	if err != nil {
+		return htr.Client()
+	}
+	return &originClient{transport: htr, origin: origin}
+}
+
+// originClient sends the requests to origin with the credentials of
+// transport, and the others with its client alone.
+type originClient struct {
+	transport *xhttp.Transport
+	origin    *url.URL
+}
+
+// Do implements transport.HTTPClient.
+func (c *originClient) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == c.origin.Scheme && req.URL.Host == c.origin.Host {
+		return c.transport.Do(req)
+	}
+	return c.transport.Client().Do(req)
+}
+
+// clientOptions returns opts preceded by the defaults canonical git derives
+// from the system, global and repository config for rawURL: the credential
+// helpers for HTTP remotes, the external SSH command (GIT_SSH_COMMAND,
+// core.sshCommand or GIT_SSH) for SSH remotes, and the askpass program
+// (GIT_ASKPASS, core.askPass or SSH_ASKPASS) used to prompt for missing
+// credentials. Options given by the caller take precedence.
+func (r *Remote) clientOptions(rawURL string, opts []client.Option) ([]client.Option, error) {
+	u, err := transport.ParseURL(rawURL)
+	if err != nil || r.s == nil {
+		return opts, nil
+	}
+
+	cfgs, err := rawConfigs(r.s)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults []client.Option
+	if u.Scheme == "http" || u.Scheme == "https" {
+		if m := credential.NewManagerFromConfig(u, cfgs...); len(m.Helpers) > 0 {
+			defaults = append(defaults, client.WithCredentials(m))
+		}
+	}
+	if u.Scheme == "ssh" {
+		command, variant := xssh.ResolveSSHCommand(
+			rawOption(cfgs, "core", "sshCommand"),
+			rawOption(cfgs, "ssh", "variant"),
+		)
+		if command != "" {
+			defaults = append(defaults, client.WithSSHCommand(command, variant))
+		}
+	}
+	if ap := credential.NewAskPassFromConfig(cfgs...); ap != nil {
+		defaults = append(defaults, client.WithPrompt(ap))
+	}
+
+	return append(defaults, opts...), nil
+}
+
+// rawOption returns the value of the key option of section in cfgs, the
+// last config setting it taking precedence.
+func rawOption(cfgs []*formatcfg.Config, section, key string) string {
+	var v string
+	for _, cfg := range cfgs {
+		if cfg == nil || !cfg.HasSection(section) {
+			continue
+		}
+		if o := cfg.Section(section).Option(key); o != "" {
+			v = o
+		}
+	}
+	return v
+}
+
+// rawConfigs returns the raw system, global and local config, in that
+// order. Global and system config are only read when a config loader
+// plugin is registered.
+func rawConfigs(s config.ConfigStorer) ([]*formatcfg.Config, error) {
+	local, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// Use Has before Get so the key is not frozen when no plugin is
+	// registered, allowing callers to register one later.
+	if !plugin.Has(plugin.ConfigLoader()) {
+		return []*formatcfg.Config{local.Raw}, nil
+	}
+
+	src, err := plugin.Get(plugin.ConfigLoader())
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []*formatcfg.Config
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		cs, err := src.Load(scope)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := cs.Config()
+		if err != nil {
+			return nil, err
+		}
+		cfgs = append(cfgs, cfg.Raw)
+	}
+
+	return append(cfgs, local.Raw), nil
+}
+
 // transportProtocol returns the wire protocol version configured for this
 // remote's repository (the protocol.version setting), defaulting to
 // config.DefaultProtocolVersion. It is used for ref discovery and fetch;
@@ -871,7 +1213,7 @@ func (r *Remote) addObject(rs config.RefSpec,
 		if err := checkTagUpdate(cmd); err != nil {
 			return err
 		}
-		if err := checkFastForwardUpdate(r.s, remoteRefs, cmd); err != nil {
+		if err := checkFastForwardUpdate(unwrapPromisor(r.s), remoteRefs, cmd); err != nil {
 			return err
 		}
 	}
@@ -922,7 +1264,7 @@ func (r *Remote) addReferenceIfRefSpecMatches(rs config.RefSpec,
 		if err := checkTagUpdate(cmd); err != nil {
 			return err
 		}
-		if err := checkFastForwardUpdate(r.s, remoteRefs, cmd); err != nil {
+		if err := checkFastForwardUpdate(unwrapPromisor(r.s), remoteRefs, cmd); err != nil {
 			return err
 		}
 	}
@@ -1366,7 +1708,7 @@ func (r *Remote) updateLocalReferenceStorage(
 			// If the ref exists locally as a non-tag and force is not
 			// specified, only update if the new ref is an ancestor of the old
 			if old != nil && !old.Name().IsTag() && !force && !spec.IsForceUpdate() {
-				ff, err := isFastForward(r.s, old.Hash(), newRef.Hash(), shallows)
+				ff, err := isFastForward(unwrapPromisor(r.s), old.Hash(), newRef.Hash(), shallows)
 				if err != nil {
 					return updated, err
 				}
@@ -1421,7 +1763,7 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage, allTags, force b
 			continue
 		}
 
-		_, err := r.s.EncodedObject(plumbing.AnyObject, ref.Hash())
+		_, err := unwrapPromisor(r.s).EncodedObject(plumbing.AnyObject, ref.Hash())
 		if errors.Is(err, plumbing.ErrObjectNotFound) {
 			continue
 		}
@@ -1487,7 +1829,12 @@ func (r *Remote) list(ctx context.Context, o *ListOptions) (rfs []*plumbing.Refe
 		return nil, ErrEmptyUrls
 	}
 
-	cl, req, err := newClient(r.c.URLs[0], o.ClientOptions)
+	opts, err := r.clientOptions(r.c.URLs[0], o.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	cl, req, err := newClient(r.c.URLs[0], opts)
 	if err != nil {
 		return nil, err
 	}
@@ -1567,13 +1914,13 @@ func pushHashes(
 	hs []plumbing.Hash,
 	allDelete bool,
 	o *PushOptions,
-) error {
+) (*packp.ReportStatus, error) {
 	useRefDeltas := !sess.Capabilities().Supports(capability.OFSDelta)
 	rd, wr := io.Pipe()
 
 	config, err := s.Config()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set buffer size to 1 so the error message can be written when
@@ -1586,6 +1933,22 @@ func pushHashes(
 		Options:  o.Options,
 		Atomic:   o.Atomic,
 		Quiet:    o.Quiet,
+		Report:   &packp.ReportStatus{},
+	}
+
+	if o.Signer != nil {
+		cfgs, err := rawConfigs(s)
+		if err != nil {
+			return nil, err
+		}
+		pusher, err := pushCertPusher(cfgs, o.Pusher)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Signer = o.Signer
+		req.Pusher = pusher
+		req.Pushee = anonymizeURL(o.RemoteURL)
 	}
 
 	if !allDelete {
@@ -1606,14 +1969,66 @@ func pushHashes(
 	if err := sess.Push(ctx, s, req); err != nil {
 		// close the pipe to unlock encode write
 		_ = rd.Close()
-		return err
+		return req.Report, err
 	}
 
 	if err := <-done; err != nil {
-		return err
+		return req.Report, err
 	}
 
-	return nil
+	return req.Report, nil
+}
+
+// pushCertPusher formats the pusher line of a push certificate. When pusher
+// is nil, the committer identity is read from cfgs, the system, global and
+// local config, as git does: committer.name and committer.email take
+// precedence over user.name and user.email.
+func pushCertPusher(cfgs []*formatcfg.Config, pusher *object.Signature) (string, error) {
+	if pusher == nil {
+		name := rawOption(cfgs, "committer", "name")
+		if name == "" {
+			name = rawOption(cfgs, "user", "name")
+		}
+		email := rawOption(cfgs, "committer", "email")
+		if email == "" {
+			email = rawOption(cfgs, "user", "email")
+		}
+		if name == "" || email == "" {
+			return "", ErrMissingPusher
+		}
+		pusher = &object.Signature{Name: name, Email: email, When: time.Now()}
+	}
+
+	var b strings.Builder
+	if err := pusher.Encode(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// anonymizeURL strips the credentials of a remote URL, as git does before
+// recording it in a push certificate. A URL that cannot be parsed is
+// replaced with a placeholder, as it may hold credentials.
+func anonymizeURL(rawURL string) string {
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "<redacted>"
+		}
+		if u.User == nil {
+			return rawURL
+		}
+		u.User = nil
+		return u.String()
+	}
+
+	// scp-like URLs, as in user@host:path.
+	if at := strings.Index(rawURL, "@"); at >= 0 {
+		if colon := strings.Index(rawURL, ":"); colon > at {
+			return rawURL[at+1:]
+		}
+	}
+	return rawURL
 }
 
 func (r *Remote) checkRequireRemoteRefs(requires []config.RefSpec, remoteRefs storer.ReferenceStorer) error {
@@ -85,6 +85,10 @@ var (
 
 // Repository represents a git repository
 type Repository struct {
+	// Storer is the storage of the repository. The filesystem storage of
+	// a partial clone is wrapped by Open and Clone in a *promisor.Storage,
+	// which fetches the objects the promisor remote withheld as they are
+	// read; its Unwrap method returns the *filesystem.Storage.
 	Storer storage.Storer
 
 	r  map[string]*Remote
@@ -273,6 +277,9 @@ func setConfigWorktree(r *Repository, worktree, storage billy.Filesystem) error
 // The worktree can be nil when the repository being opened is bare, if the
 // repository is a normal one (not bare) and worktree is nil the err
 // ErrWorktreeNotProvided is returned
+//
+// If the repository is a partial clone, Repository.Storer is a
+// *promisor.Storage wrapping s, unless GIT_NO_LAZY_FETCH=1 is set.
 func Open(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 	if trace.Performance.Enabled() {
 		start := time.Now()
@@ -296,7 +303,9 @@ func Open(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
-	return newRepository(s, worktree), nil
+	r := newRepository(s, worktree)
+	r.enableLazyFetch()
+	return r, nil
 }
 
 // Clone a repository into the given Storer and worktree Filesystem with the
@@ -960,15 +969,9 @@ func (r *Repository) createTagObject(name string, hash plumbing.Hash, opts *Crea
 	if signer == nil {
 		cfg, err := r.ConfigScoped(config.SystemScope)
 		if err == nil && cfg != nil && cfg.Tag.GpgSign.IsTrue() {
-			// Use Has before Get so the key is not frozen when no plugin is
-			// registered, allowing callers to register one later.
-			if !plugin.Has(plugin.ObjectSigner()) {
-				return plumbing.ZeroHash, fmt.Errorf("cannot auto-sign tag: disable tag.gpgSign or register an ObjectSigner plugin")
-			}
-
-			signer, err = plugin.Get(plugin.ObjectSigner())
+			signer, err = autoSigner(cfg, tag.Tagger)
 			if err != nil {
-				return plumbing.ZeroHash, fmt.Errorf("get object signer: %w", err)
+				return plumbing.ZeroHash, fmt.Errorf("cannot auto-sign tag: %w", err)
 			}
 		}
 	}
@@ -1140,6 +1143,8 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 		Tags:          o.Tags,
 		RemoteName:    o.RemoteName,
 		Filter:        o.Filter,
+		bundleURI:     o.BundleURI,
+		bundleURIs:    true,
 	}, o.ReferenceName)
 
 	hr, err1 := r.Storer.Reference(plumbing.HEAD)
@@ -1156,6 +1161,11 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 		return err
 	}
 
+	// The objects the filter left out are fetched as the checkout reads them.
+	if o.Filter != "" {
+		r.enableLazyFetch()
+	}
+
 	if r.wt != nil && !o.NoCheckout {
 		w, err := r.Worktree()
 		if err != nil {
@@ -1447,16 +1457,26 @@ func (r *Repository) Push(o *PushOptions) error {
 // operation is complete, an error is returned. The context only affects the
 // transport operations.
 func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
+	_, err := r.PushWithResult(ctx, o)
+	return err
+}
+
+// PushWithResult performs a push to the remote named as
+// PushOptions.RemoteName, as PushContext does, and returns the outcome of
+// each reference update. The result is also returned along with the error
+// of a push some references of which were rejected, and nil when no update
+// was sent.
+func (r *Repository) PushWithResult(ctx context.Context, o *PushOptions) (*PushResult, error) {
 	if err := o.Validate(); err != nil {
-		return err
+		return nil, err
 	}
 
 	remote, err := r.Remote(o.RemoteName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return remote.PushContext(ctx, o)
+	return remote.PushWithResult(ctx, o)
 }
 
 // ArchiveOptions stores the options for the Archive operation.
@@ -1479,6 +1499,11 @@ type ArchiveOptions struct {
 	// Progress receives human-readable status from the remote server.
 	// Only used by ArchiveRemote, ignored by Archive.
 	Progress sideband.Progress
+	// WorktreeAttributes, as --worktree-attributes, reads the
+	// export-ignore and export-subst attributes from the .gitattributes
+	// files of the worktree, and then of the index, instead of the ones of
+	// the archived tree. Only used by Archive, ignored by ArchiveRemote.
+	WorktreeAttributes bool
 }
 
 // Validate validates the ArchiveOptions.
@@ -1526,13 +1551,18 @@ func (r *Repository) ArchiveContext(ctx context.Context, o *ArchiveOptions) (io.
 		return nil, err
 	}
 
+	attrs, err := r.archiveAttributes(tree, o.WorktreeAttributes)
+	if err != nil {
+		return nil, err
+	}
+
 	prefix := o.Prefix
 	paths := slices.Clone(o.Paths)
 
 	pr, pw := io.Pipe()
 	cw := ioutil.NewContextWriter(ctx, pw)
 	go func() {
-		err := archive.WriteArchive(r.Storer, cw, tree, commitHash, commitTime, format, prefix, paths)
+		err := archive.WriteArchive(r.Storer, cw, tree, commitHash, commitTime, format, prefix, paths, attrs)
 		_ = pw.CloseWithError(err)
 	}()
 
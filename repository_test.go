@@ -1905,11 +1905,12 @@ func (s *RepositorySuite) TestFetchContext() {
 }
 
 func (s *RepositorySuite) TestFetchWithFilters() {
+	url := s.GetBasicLocalRepositoryURL()
 	r, _ := Init(memory.NewStorage())
 	defer func() { _ = r.Close() }()
 	_, err := r.CreateRemote(&config.RemoteConfig{
 		Name: DefaultRemoteName,
-		URLs: []string{s.GetBasicLocalRepositoryURL()},
+		URLs: []string{url},
 	})
 	s.NoError(err)
 
@@ -1917,6 +1918,26 @@ func (s *RepositorySuite) TestFetchWithFilters() {
 		Filter: packp.FilterBlobNone(),
 	})
 	s.ErrorIs(err, transport.ErrFilterNotSupported)
+
+	// The server only offers filters when uploadpack.allowFilter is set.
+	src, err := PlainOpen(url)
+	s.Require().NoError(err)
+	cfg, err := src.Config()
+	s.Require().NoError(err)
+	cfg.UploadPack.AllowFilter = config.NewOptBool(true)
+	s.Require().NoError(src.SetConfig(cfg))
+	s.Require().NoError(src.Close())
+
+	err = r.Fetch(&FetchOptions{
+		Filter: packp.FilterBlobNone(),
+	})
+	s.NoError(err)
+
+	_, err = r.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	s.NoError(err)
+	blob, err := r.BlobObject(plumbing.NewHash("9a48f23120e880dfbe41f7c9b7b708e9ee62a492"))
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+	s.Nil(blob)
 }
 
 func (s *RepositorySuite) TestFetchWithFiltersReal() {
@@ -4179,9 +4200,9 @@ func TestCreateTagSignerSelection(t *testing.T) { //nolint:paralleltest // modif
 			wantPluginUsed: false,
 		},
 		{
-			name:       "error if tag.signGpg=true and no plugin registered",
+			name:       "error if tag.signGpg=true and no plugin or signing key",
 			tagSignGpg: config.OptBoolTrue,
-			wantErr:    "cannot auto-sign tag: disable tag.gpgSign or register an ObjectSigner plugin",
+			wantErr:    "cannot auto-sign tag: no signing key",
 		},
 		{
 			name:           "CreateTagOptions.Signer takes precedence over plugin",
@@ -4436,6 +4457,70 @@ func TestRepository_ArchiveContext_Cancelled(t *testing.T) {
 	assert.ErrorIs(t, err, context.Canceled)
 }
 
+func TestRepository_ArchiveExportAttributes(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "secret export-ignore\n*.log export-ignore\nversion.txt export-subst\n",
+		"secret/key":     "key",
+		"debug.log":      "log",
+		"keep.txt":       "keep",
+		"version.txt":    "$Format:%h %s$ $Format:%an <%ae>%d$ $Format:no end\n",
+	})
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	h, err := w.Commit("Release\n\nbody\n", &CommitOptions{Author: &object.Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Unix(1700000000, 0).UTC(),
+	}})
+	require.NoError(t, err)
+	_, err = r.CreateTag("v1.0", h, nil)
+	require.NoError(t, err)
+
+	archived := func(o *ArchiveOptions) map[string]string {
+		t.Helper()
+
+		rc, err := r.Archive(o)
+		require.NoError(t, err)
+		defer rc.Close()
+
+		files := make(map[string]string)
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[hdr.Name] = string(content)
+		}
+		return files
+	}
+
+	assert.Equal(t, map[string]string{
+		".gitattributes": "secret export-ignore\n*.log export-ignore\nversion.txt export-subst\n",
+		"keep.txt":       "keep",
+		"version.txt":    h.String()[:7] + " Release foo <foo@foo.foo> (HEAD -> master, tag: v1.0) $Format:no end\n",
+	}, archived(&ArchiveOptions{Treeish: "v1.0"}))
+
+	// Placeholders are only expanded when archiving a commit.
+	commit, err := r.CommitObject(h)
+	require.NoError(t, err)
+	files := archived(&ArchiveOptions{Treeish: commit.TreeHash.String()})
+	assert.Equal(t, "$Format:%h %s$ $Format:%an <%ae>%d$ $Format:no end\n", files["version.txt"])
+
+	require.NoError(t, util.WriteFile(w.Filesystem(), ".gitattributes", []byte("*.log export-ignore\n"), 0o644))
+	files = archived(&ArchiveOptions{Treeish: "v1.0", WorktreeAttributes: true})
+	assert.Equal(t, "key", files["secret/key"])
+	assert.NotContains(t, files, "debug.log")
+	assert.True(t, strings.HasPrefix(files["version.txt"], "$Format:%h"))
+}
+
 // archiveFileNames extracts file names from an archive based on format.
 func archiveFileNames(t *testing.T, format string, data []byte) []string {
 	t.Helper()
@@ -1,10 +1,18 @@
 package git
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"io"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	gossh "golang.org/x/crypto/ssh"
+
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/sshsig"
+	"github.com/go-git/go-git/v6/plumbing/object"
 )
 
 // signableObject is an object which can be signed.
@@ -35,3 +43,52 @@ func signObject(signer Signer, obj signableObject) ([]byte, error) {
 	// TODO: thread a caller-supplied context once Worktree.Commit accepts one.
 	return signer.Sign(context.TODO(), r)
 }
+
+// OpenPGPSigner is a Signer making armored OpenPGP detached signatures, as
+// git does with gpg.format set to openpgp.
+type OpenPGPSigner struct {
+	// Entity holds the signing key, whose private key must be decrypted.
+	Entity *openpgp.Entity
+	// Config is the configuration signatures are made with. If nil,
+	// sensible defaults are used.
+	Config *packet.Config
+}
+
+// NewOpenPGPSigner returns an OpenPGPSigner signing with the key of entity.
+func NewOpenPGPSigner(entity *openpgp.Entity) *OpenPGPSigner {
+	return &OpenPGPSigner{Entity: entity}
+}
+
+// Sign implements Signer.
+func (s *OpenPGPSigner) Sign(_ context.Context, message io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.Entity, message, s.Config); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// SSHSigner is a Signer making SSH signatures, as git does with gpg.format
+// set to ssh.
+type SSHSigner struct {
+	// Signer holds the signing key. It can be a key of an ssh-agent, as
+	// returned by agent.ExtendedAgent.Signers.
+	Signer gossh.Signer
+	// Namespace is the namespace signatures are made in. If empty,
+	// object.DefaultSSHNamespace is used.
+	Namespace string
+}
+
+// NewSSHSigner returns an SSHSigner signing with signer.
+func NewSSHSigner(signer gossh.Signer) *SSHSigner {
+	return &SSHSigner{Signer: signer}
+}
+
+// Sign implements Signer.
+func (s *SSHSigner) Sign(_ context.Context, message io.Reader) ([]byte, error) {
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = object.DefaultSSHNamespace
+	}
+	return sshsig.Sign(rand.Reader, s.Signer, message, namespace)
+}
@@ -0,0 +1,252 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/internal/pathutil"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh/sshagent"
+	"github.com/go-git/go-git/v6/x/plugin"
+)
+
+// ErrNoSigningKey is returned by SignerFromConfig when no signing key
+// matches the configuration.
+var ErrNoSigningKey = errors.New("no signing key")
+
+const sshKeyPrefix = "key::"
+
+// SignerFromConfig returns the built-in Signer selected by gpg.format and
+// user.signingKey in cfg, as git selects the key of commit.gpgSign and
+// tag.gpgSign:
+//
+//   - With the openpgp format, user.signingKey is a key ID, a fingerprint
+//     or a user ID matched against the private keys of keyring. If it is
+//     not set, the key of identity is used. If keyring is empty,
+//     user.signingKey can be the path to a file holding the private key.
+//   - With the ssh format, user.signingKey is the path to a private key, or
+//     to a public key or a "key::" prefixed public key whose private key is
+//     held by the ssh-agent.
+//
+// The x509 format has no built-in signer, and neither are the private keys
+// held by the gpg-agent of GnuPG 2.1 and later supported.
+func SignerFromConfig(cfg *config.Config, identity object.Signature, keyring openpgp.EntityList) (Signer, error) {
+	switch cfg.GPG.Format {
+	case "", string(object.SignatureFormatOpenPGP):
+		return openPGPSignerFromConfig(cfg.User.SigningKey, identity, keyring)
+	case string(object.SignatureFormatSSH):
+		return sshSignerFromConfig(cfg.User.SigningKey)
+	default:
+		return nil, fmt.Errorf("unsupported gpg.format %q", cfg.GPG.Format)
+	}
+}
+
+// autoSigner returns the signer of objects signed because of commit.gpgSign
+// or tag.gpgSign: the ObjectSigner plugin if one is registered, or else the
+// built-in signer selected by cfg, with the keyring of GnuPG.
+func autoSigner(cfg *config.Config, identity object.Signature) (Signer, error) {
+	// Use Has before Get so the key is not frozen when no plugin is
+	// registered, allowing callers to register one later.
+	if plugin.Has(plugin.ObjectSigner()) {
+		signer, err := plugin.Get(plugin.ObjectSigner())
+		if err != nil {
+			return nil, fmt.Errorf("get object signer: %w", err)
+		}
+		return signer, nil
+	}
+
+	var keyring openpgp.EntityList
+	if cfg.GPG.Format == "" || cfg.GPG.Format == string(object.SignatureFormatOpenPGP) {
+		var err error
+		keyring, err = defaultOpenPGPKeyring()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return SignerFromConfig(cfg, identity, keyring)
+}
+
+// defaultOpenPGPKeyring returns the secret keyring of GnuPG, secring.gpg in
+// $GNUPGHOME or ~/.gnupg. It is nil if there is none, as with GnuPG 2.1 and
+// later, which hold the private keys in the gpg-agent.
+func defaultOpenPGPKeyring() (openpgp.EntityList, error) {
+	home := os.Getenv("GNUPGHOME")
+	if home == "" {
+		home, _ = pathutil.ReplaceTildeWithHome("~/.gnupg")
+	}
+
+	path := filepath.Join(home, "secring.gpg")
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && fi.Size() == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read GnuPG keyring: %w", err)
+	}
+
+	keyring, err := readOpenPGPKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GnuPG keyring: %w", err)
+	}
+	return keyring, nil
+}
+
+func openPGPSignerFromConfig(key string, identity object.Signature, keyring openpgp.EntityList) (Signer, error) {
+	if len(keyring) == 0 {
+		if key == "" {
+			return nil, fmt.Errorf("%w: set user.signingKey to an OpenPGP private key file", ErrNoSigningKey)
+		}
+
+		// The file holds the signing key: any of its private keys is used.
+		path, _ := pathutil.ReplaceTildeWithHome(key)
+		keyring, err := readOpenPGPKeyFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %q is not a private key file and there is no GnuPG keyring; signing with the gpg-agent: %w",
+				ErrNoSigningKey, key, errors.ErrUnsupported)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNoSigningKey, err)
+		}
+		entity := findOpenPGPKey(keyring, "")
+		if entity == nil {
+			return nil, fmt.Errorf("%w: no OpenPGP private key in %s", ErrNoSigningKey, key)
+		}
+		return NewOpenPGPSigner(entity), nil
+	}
+
+	if key == "" && identity.Email != "" {
+		key = fmt.Sprintf("%s <%s>", identity.Name, identity.Email)
+	}
+
+	entity := findOpenPGPKey(keyring, key)
+	if entity == nil {
+		return nil, fmt.Errorf("%w: no OpenPGP private key for %q", ErrNoSigningKey, key)
+	}
+	return NewOpenPGPSigner(entity), nil
+}
+
+func readOpenPGPKeyFile(path string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b)); err == nil {
+		return keyring, nil
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// findOpenPGPKey returns the entity of keyring with a private key matching
+// id, as gpg matches --local-user: id is a key ID or fingerprint, or a part
+// of a user ID. An empty id matches any key.
+func findOpenPGPKey(keyring openpgp.EntityList, id string) *openpgp.Entity {
+	hexID := strings.ToUpper(strings.ReplaceAll(id, " ", ""))
+	hexID = strings.TrimSuffix(strings.TrimPrefix(hexID, "0X"), "!")
+
+	for _, e := range keyring {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if id == "" || matchOpenPGPKey(e, hexID) {
+			return e
+		}
+		for name := range e.Identities {
+			if strings.Contains(strings.ToLower(name), strings.ToLower(id)) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// matchOpenPGPKey reports whether the fingerprint of the primary key or a
+// subkey of e ends with the hexadecimal key ID or fingerprint id.
+func matchOpenPGPKey(e *openpgp.Entity, id string) bool {
+	if len(id) < 8 {
+		return false
+	}
+	if strings.HasSuffix(fmt.Sprintf("%X", e.PrimaryKey.Fingerprint), id) {
+		return true
+	}
+	for _, sub := range e.Subkeys {
+		if strings.HasSuffix(fmt.Sprintf("%X", sub.PublicKey.Fingerprint), id) {
+			return true
+		}
+	}
+	return false
+}
+
+func sshSignerFromConfig(key string) (Signer, error) {
+	if key == "" {
+		return nil, fmt.Errorf("%w: set user.signingKey to an SSH key", ErrNoSigningKey)
+	}
+
+	if literal, ok := strings.CutPrefix(key, sshKeyPrefix); ok {
+		pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(literal))
+		if err != nil {
+			return nil, fmt.Errorf("parse user.signingKey: %w", err)
+		}
+		return &sshAgentSigner{key: pub}, nil
+	}
+
+	path, _ := pathutil.ReplaceTildeWithHome(key)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoSigningKey, err)
+	}
+	if pub, _, _, _, err := gossh.ParseAuthorizedKey(b); err == nil {
+		return &sshAgentSigner{key: pub}, nil
+	}
+
+	signer, err := gossh.ParsePrivateKey(b)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		// The key is encrypted: its private key must be in the ssh-agent.
+		return &sshAgentSigner{key: missing.PublicKey}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse user.signingKey: %w", err)
+	}
+	return NewSSHSigner(signer), nil
+}
+
+// sshAgentSigner is a Signer making SSH signatures with a key held by the
+// ssh-agent, which is connected to at each signature.
+type sshAgentSigner struct {
+	key gossh.PublicKey
+}
+
+// Sign implements Signer.
+func (s *sshAgentSigner) Sign(ctx context.Context, message io.Reader) ([]byte, error) {
+	agent, conn, err := sshagent.New()
+	if err != nil {
+		return nil, err
+	}
+	if conn != nil {
+		defer conn.Close()
+	}
+
+	signers, err := agent.Signers()
+	if err != nil {
+		return nil, err
+	}
+	want := s.key.Marshal()
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), want) {
+			return NewSSHSigner(signer).Sign(ctx, message)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not in the ssh-agent", ErrNoSigningKey, gossh.FingerprintSHA256(s.key))
+}
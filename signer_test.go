@@ -3,12 +3,26 @@ package git
 import (
 	"context"
 	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
 
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/format/sshsig"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/memory"
 )
@@ -57,3 +71,212 @@ func ExampleSigner() {
 	fmt.Println(obj.Signature)
 	// Output: dHJlZSA0YjgyNWRjNjQyY2I2ZWI5YTA2MGU1NGJmOGQ2OTI4OGZiZWU0OTA0CmF1dGhvciBKb2huIERvZSA8am9obkBleGFtcGxlLmNvbT4gMTIzNCArMDAwMApjb21taXR0ZXIgSm9obiBEb2UgPGpvaG5AZXhhbXBsZS5jb20+IDEyMzQgKzAwMDAKCmV4YW1wbGUgY29tbWl0
 }
+
+func signedCommit(t *testing.T, signer Signer) *object.Commit {
+	t.Helper()
+
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	hash, err := w.Commit("signed\n", &CommitOptions{
+		Author:            defaultSignature(),
+		Signer:            signer,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+	commit, err := r.CommitObject(hash)
+	require.NoError(t, err)
+	return commit
+}
+
+func TestOpenPGPSigner(t *testing.T) {
+	t.Parallel()
+
+	entity, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	require.NoError(t, err)
+
+	commit := signedCommit(t, NewOpenPGPSigner(entity))
+	assert.True(t, strings.HasPrefix(commit.Signature, "-----BEGIN PGP SIGNATURE-----"))
+
+	vs, err := commit.VerifySignature(context.Background(), &object.OpenPGPVerifier{KeyRing: openpgp.EntityList{entity}})
+	require.NoError(t, err)
+	assert.Equal(t, object.SignatureFormatOpenPGP, vs.Format)
+	assert.Equal(t, "go-git <go-git@example.com>", vs.Signer)
+}
+
+func TestSSHSigner(t *testing.T) {
+	t.Parallel()
+
+	signer, err := gossh.ParsePrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+
+	commit := signedCommit(t, NewSSHSigner(signer))
+	assert.True(t, strings.HasPrefix(commit.Signature, "-----BEGIN SSH SIGNATURE-----"))
+
+	vs, err := commit.VerifySignature(context.Background(), &object.SSHVerifier{
+		AllowedSigners: sshsig.AllowedSigners{{Principals: "*", Key: signer.PublicKey()}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, object.SignatureFormatSSH, vs.Format)
+}
+
+func TestSignerFromConfigOpenPGP(t *testing.T) {
+	t.Parallel()
+
+	alice, err := openpgp.NewEntity("Alice", "", "alice@example.com", nil)
+	require.NoError(t, err)
+	bob, err := openpgp.NewEntity("Bob", "", "bob@example.com", nil)
+	require.NoError(t, err)
+	keyring := openpgp.EntityList{alice, bob}
+	identity := object.Signature{Name: "Bob", Email: "bob@example.com"}
+
+	for key, want := range map[string]*openpgp.Entity{
+		"":                  bob,
+		"alice@example.com": alice,
+		fmt.Sprintf("0x%016X", alice.PrimaryKey.KeyId):  alice,
+		fmt.Sprintf("%X!", bob.PrimaryKey.Fingerprint):  bob,
+		fmt.Sprintf("%X", alice.PrimaryKey.Fingerprint): alice,
+		fmt.Sprintf("%016X", bob.PrimaryKey.KeyId)[8:]:  bob,
+	} {
+		cfg := config.NewConfig()
+		cfg.User.SigningKey = key
+		signer, err := SignerFromConfig(cfg, identity, keyring)
+		require.NoError(t, err, key)
+		require.IsType(t, &OpenPGPSigner{}, signer, key)
+		assert.Same(t, want, signer.(*OpenPGPSigner).Entity, key)
+	}
+
+	cfg := config.NewConfig()
+	cfg.User.SigningKey = "mallory@example.com"
+	_, err = SignerFromConfig(cfg, identity, keyring)
+	require.ErrorIs(t, err, ErrNoSigningKey)
+
+	// Without a keyring, user.signingKey is the path of the key.
+	path := filepath.Join(t.TempDir(), "key.asc")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w, err := armor.Encode(f, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, alice.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	cfg.User.SigningKey = path
+	signer, err := SignerFromConfig(cfg, identity, nil)
+	require.NoError(t, err)
+	assert.Equal(t, alice.PrimaryKey.Fingerprint, signer.(*OpenPGPSigner).Entity.PrimaryKey.Fingerprint)
+
+	cfg.User.SigningKey = ""
+	_, err = SignerFromConfig(cfg, identity, nil)
+	require.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestAutoSignerGnuPGKeyring(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("GNUPGHOME", home)
+
+	cfg := config.NewConfig()
+	cfg.User.SigningKey = "alice@example.com"
+	identity := object.Signature{Name: "Alice", Email: "alice@example.com"}
+
+	// Without secring.gpg, as with GnuPG 2.1 and later, the keys of the
+	// gpg-agent are not supported.
+	_, err := autoSigner(cfg, identity)
+	require.ErrorIs(t, err, ErrNoSigningKey)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	alice, err := openpgp.NewEntity("Alice", "", "alice@example.com", nil)
+	require.NoError(t, err)
+	f, err := os.Create(filepath.Join(home, "secring.gpg"))
+	require.NoError(t, err)
+	require.NoError(t, alice.SerializePrivate(f, nil))
+	require.NoError(t, f.Close())
+
+	signer, err := autoSigner(cfg, identity)
+	require.NoError(t, err)
+	require.IsType(t, &OpenPGPSigner{}, signer)
+	assert.Equal(t, alice.PrimaryKey.Fingerprint, signer.(*OpenPGPSigner).Entity.PrimaryKey.Fingerprint)
+}
+
+func TestSignerFromConfigSSH(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(path, testdata.PEMBytes["ed25519"], 0o600))
+
+	cfg := config.NewConfig()
+	cfg.GPG.Format = "ssh"
+	cfg.User.SigningKey = path
+	signer, err := SignerFromConfig(cfg, object.Signature{}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &SSHSigner{}, signer)
+
+	// The private key of an encrypted key is expected in the ssh-agent.
+	raw, err := gossh.ParseRawPrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(raw, "", []byte("passphrase"))
+	require.NoError(t, err)
+	encrypted := filepath.Join(dir, "id_encrypted")
+	require.NoError(t, os.WriteFile(encrypted, pem.EncodeToMemory(block), 0o600))
+	cfg.User.SigningKey = encrypted
+	signer, err = SignerFromConfig(cfg, object.Signature{}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &sshAgentSigner{}, signer)
+
+	cfg.User.SigningKey = "key::" + string(testdata.SSHCertificates["rsa"])
+	_, err = SignerFromConfig(cfg, object.Signature{}, nil)
+	require.NoError(t, err)
+
+	cfg.User.SigningKey = ""
+	_, err = SignerFromConfig(cfg, object.Signature{}, nil)
+	require.ErrorIs(t, err, ErrNoSigningKey)
+
+	cfg.GPG.Format = "x509"
+	_, err = SignerFromConfig(cfg, object.Signature{}, nil)
+	require.Error(t, err)
+}
+
+func TestCommitAutoSignFromConfig(t *testing.T) { //nolint:paralleltest // modifies global plugin state
+	resetPluginEntry("object-signer")
+
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(path, testdata.PEMBytes["ed25519"], 0o600))
+	key, err := gossh.ParsePrivateKey(testdata.PEMBytes["ed25519"])
+	require.NoError(t, err)
+
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Commit.GpgSign = config.OptBoolTrue
+	cfg.Tag.GpgSign = config.OptBoolTrue
+	cfg.GPG.Format = "ssh"
+	cfg.User.SigningKey = path
+	require.NoError(t, r.SetConfig(cfg))
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	hash, err := w.Commit("signed\n", &CommitOptions{Author: defaultSignature(), AllowEmptyCommits: true})
+	require.NoError(t, err)
+
+	verifier := &object.SSHVerifier{
+		AllowedSigners: sshsig.AllowedSigners{{Principals: "*", Key: key.PublicKey()}},
+	}
+	commit, err := r.CommitObject(hash)
+	require.NoError(t, err)
+	_, err = commit.VerifySignature(context.Background(), verifier)
+	require.NoError(t, err)
+
+	ref, err := r.CreateTag("v1.0.0", hash, &CreateTagOptions{Tagger: defaultSignature(), Message: "release"})
+	require.NoError(t, err)
+	tag, err := r.TagObject(ref.Hash())
+	require.NoError(t, err)
+	_, err = tag.VerifySignature(context.Background(), verifier)
+	require.NoError(t, err)
+}
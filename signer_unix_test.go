@@ -0,0 +1,66 @@
+//go:build !plan9 && unix && !windows
+
+package git
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/testdata"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/format/sshsig"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+func TestSignerFromConfigSSHAgent(t *testing.T) { //nolint:paralleltest // sets SSH_AUTH_SOCK
+	for _, name := range []string{"ed25519", "rsa"} {
+		raw, err := gossh.ParseRawPrivateKey(testdata.PEMBytes[name])
+		require.NoError(t, err)
+		keyring := agent.NewKeyring()
+		require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: raw}))
+		signers, err := keyring.Signers()
+		require.NoError(t, err)
+		pub := signers[0].PublicKey()
+
+		sock := filepath.Join(t.TempDir(), "agent.sock")
+		l, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		defer l.Close()
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					_ = agent.ServeAgent(keyring, conn)
+				}()
+			}
+		}()
+		t.Setenv("SSH_AUTH_SOCK", sock)
+
+		cfg := config.NewConfig()
+		cfg.GPG.Format = "ssh"
+		cfg.User.SigningKey = "key::" + string(gossh.MarshalAuthorizedKey(pub))
+		signer, err := SignerFromConfig(cfg, object.Signature{}, nil)
+		require.NoError(t, err, name)
+
+		commit := signedCommit(t, signer)
+		_, err = commit.VerifySignature(context.Background(), &object.SSHVerifier{
+			AllowedSigners: sshsig.AllowedSigners{{Principals: "*", Key: pub}},
+		})
+		require.NoError(t, err, name)
+
+		require.NoError(t, keyring.RemoveAll())
+		_, err = signer.Sign(context.Background(), strings.NewReader("message"))
+		require.ErrorIs(t, err, ErrNoSigningKey, name)
+	}
+}
@@ -56,6 +56,8 @@ const (
 
 	tmpPackedRefsPrefix = "._packed-refs"
 
+	sharedIndexPrefix = "sharedindex."
+
 	packPrefix = "pack-"
 	packExt    = ".pack"
 
@@ -298,11 +300,60 @@ func (d *DotGit) StatIndex() (os.FileInfo, error) {
 	return d.fs.Stat(indexPath)
 }
 
+// SharedIndexWriter returns a file pointer for write to the shared index
+// file of a split index, whose checksum is h.
+func (d *DotGit) SharedIndexWriter(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Create(sharedIndexPrefix + h.String())
+}
+
+// SharedIndex returns a file pointer for read to the shared index file of a
+// split index, whose checksum is h.
+func (d *DotGit) SharedIndex(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Open(sharedIndexPrefix + h.String())
+}
+
+// DeleteOldSharedIndexes removes the shared index files last modified
+// before t, but that whose checksum is keep.
+func (d *DotGit) DeleteOldSharedIndexes(keep plumbing.Hash, t time.Time) error {
+	files, err := d.fs.ReadDir(".")
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, sharedIndexPrefix) || name == sharedIndexPrefix+keep.String() {
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil || !info.ModTime().Before(t) {
+			continue
+		}
+
+		if err := d.fs.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
 }
 
+// RemoveShallow removes the shallow file, if any.
+func (d *DotGit) RemoveShallow() error {
+	err := d.fs.Remove(shallowPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
 // Shallow returns a file pointer for read to the shallow file
 func (d *DotGit) Shallow() (billy.File, error) {
 	f, err := d.fs.Open(shallowPath)
@@ -2,23 +2,52 @@ package filesystem
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"hash"
 	"os"
+	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/trace"
 )
 
+const (
+	// defaultMaxPercentChange is the percentage of the entries of a split
+	// index that may differ from its shared index, if not configured.
+	defaultMaxPercentChange = 20
+	// sharedIndexExpire is how long an unused shared index is kept, as git
+	// does by default.
+	sharedIndexExpire = 14 * 24 * time.Hour
+	// entriesPerThread is the number of entries of an index worth a thread
+	// to read them, as git's THREAD_COST.
+	entriesPerThread = 10000
+)
+
 // IndexStorage implements index read/write backed by the filesystem.
 type IndexStorage struct {
 	dir      *dotgit.DotGit
 	h        hash.Hash
 	cache    IndexCache
 	skipHash bool
+
+	// splitIndex and maxPercentChange are the core.splitIndex and
+	// splitIndex.maxPercentChange configuration of the repository.
+	splitIndex       config.OptBool
+	maxPercentChange int
+
+	// threads, recordOffsetTable and recordEndOfIndexEntries are the
+	// index.threads, index.recordOffsetTable and
+	// index.recordEndOfIndexEntries configuration of the repository.
+	threads                 string
+	recordOffsetTable       config.OptBool
+	recordEndOfIndexEntries config.OptBool
 }
 
 // SetIndex writes the index to disk and updates the cache.
@@ -42,6 +71,10 @@ func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
 }
 
 func (s *IndexStorage) writeIndex(idx *index.Index) (err error) {
+	if err := s.splitSharedIndex(idx); err != nil {
+		return err
+	}
+
 	f, err := s.dir.IndexWriter()
 	if err != nil {
 		return err
@@ -55,7 +88,7 @@ func (s *IndexStorage) writeIndex(idx *index.Index) (err error) {
 		}
 	}()
 
-	var encOpts []index.Option
+	encOpts := s.offsetTableOptions(idx)
 	if s.skipHash {
 		encOpts = append(encOpts, index.WithSkipHash())
 	}
@@ -109,7 +142,12 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 		sz = fi.Size()
 	}
 
-	var decOpts []index.Option
+	threads, _ := parseIndexThreads(s.threads)
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+
+	decOpts := []index.Option{index.WithThreads(threads)}
 	if s.skipHash {
 		decOpts = append(decOpts, index.WithSkipHash())
 	}
@@ -120,6 +158,10 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 		return nil, err
 	}
 
+	if err := s.readSharedIndex(idx); err != nil {
+		return nil, err
+	}
+
 	if s.cache != nil {
 		s.cache.Set(idx, idx.ModTime, sz)
 	}
@@ -127,6 +169,149 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 	return copyIndex(idx), nil
 }
 
+// readSharedIndex merges the entries of idx, if it is a split index, with
+// those of its shared index.
+func (s *IndexStorage) readSharedIndex(idx *index.Index) (err error) {
+	if idx.SplitIndex == nil || idx.SplitIndex.BaseHash.IsZero() {
+		return nil
+	}
+
+	f, err := s.dir.SharedIndex(idx.SplitIndex.BaseHash)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	base := &index.Index{}
+	if err := index.NewDecoder(bufio.NewReader(f), s.h).Decode(base); err != nil {
+		return err
+	}
+
+	return idx.MergeSharedIndex(base)
+}
+
+// splitSharedIndex sets up the split index of idx before it is written. As
+// git, with core.splitIndex unset, an index is written as it was read.
+// A new shared index is written if there is none yet, or if too many of the
+// entries of idx differ from it.
+func (s *IndexStorage) splitSharedIndex(idx *index.Index) (err error) {
+	switch {
+	case s.splitIndex.IsSet() && !s.splitIndex.IsTrue():
+		idx.SplitIndex = nil
+		return nil
+	case !s.splitIndex.IsTrue() && idx.SplitIndex == nil:
+		return nil
+	}
+
+	maxPercent := s.maxPercentChange
+	if maxPercent == 0 {
+		maxPercent = defaultMaxPercentChange
+	}
+
+	if idx.SplitIndex != nil && idx.SplitIndex.Base != nil &&
+		idx.SplitChanges()*100 <= maxPercent*len(idx.Entries) {
+		return nil
+	}
+
+	base := &index.Index{
+		Version: idx.Version,
+		Entries: make([]*index.Entry, len(idx.Entries)),
+	}
+	for i, e := range idx.Entries {
+		cp := *e
+		base.Entries[i] = &cp
+	}
+
+	var buf bytes.Buffer
+	if err := index.NewEncoder(&buf, s.h).Encode(base); err != nil {
+		return err
+	}
+
+	// The shared index is named after its checksum, which ends it.
+	h, _ := plumbing.FromBytes(buf.Bytes()[buf.Len()-s.h.Size():])
+
+	f, err := s.dir.SharedIndexWriter(h)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The split index of idx may be shared with the cache, so it is
+	// replaced rather than changed.
+	idx.SplitIndex = &index.SplitIndex{BaseHash: h, Base: base}
+
+	if err := s.dir.DeleteOldSharedIndexes(h, time.Now().Add(-sharedIndexExpire)); err != nil {
+		trace.Internal.Printf("index: cannot remove old shared indexes: %v", err)
+	}
+
+	return nil
+}
+
+// offsetTableOptions returns the options writing idx with an index entry
+// offset table and an end of index entries extension, as configured. As
+// git, both are written if index.threads is set to more than one thread,
+// and, if not configured, those the index has are kept.
+func (s *IndexStorage) offsetTableOptions(idx *index.Index) []index.Option {
+	threads, set := parseIndexThreads(s.threads)
+	recordOffsetTable := s.recordOffsetTable
+	if !recordOffsetTable.IsSet() && set {
+		recordOffsetTable = config.NewOptBool(threads != 1)
+	}
+	recordEndOfIndexEntries := s.recordEndOfIndexEntries
+	if !recordEndOfIndexEntries.IsSet() && set {
+		recordEndOfIndexEntries = config.NewOptBool(threads != 1)
+	}
+
+	var opts []index.Option
+	switch {
+	case recordOffsetTable.IsTrue():
+		blocks := threads
+		if blocks == 0 {
+			blocks = min(len(idx.Entries)/entriesPerThread, runtime.NumCPU()-1)
+		}
+		opts = append(opts, index.WithEntryOffsetTable(blocks))
+	case recordOffsetTable.IsSet():
+		opts = append(opts, index.WithEntryOffsetTable(0))
+	}
+
+	if recordEndOfIndexEntries.IsSet() {
+		opts = append(opts, index.WithEndOfIndexEntry(recordEndOfIndexEntries.IsTrue()))
+	}
+
+	return opts
+}
+
+// parseIndexThreads parses index.threads, returning 0 for as many threads
+// as there are CPUs, and whether it is set.
+func parseIndexThreads(v string) (int, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	// 0 and 1 parse as false and true, which mean the same.
+	if b, err := strconv.ParseBool(v); err == nil {
+		if b {
+			return 0, true
+		}
+		return 1, true
+	}
+
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		return n, true
+	}
+
+	return 0, false
+}
+
 // copyIndex returns a shallow copy of the Index struct with its own
 // copy of the Entries slice, so that callers can append/remove entries
 // without affecting the cached copy. Individual *Entry pointers are
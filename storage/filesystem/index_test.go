@@ -267,3 +267,128 @@ func newIndexStorageWithSpy(t *testing.T) (*filesystem.Storage, *spyIndexCache)
 
 	return sto, spy
 }
+
+func newSplitIndexStorage(t *testing.T, dir, config string) *filesystem.Storage {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0o644))
+	sto := filesystem.NewStorageWithOptions(osfs.New(dir), cache.NewObjectLRUDefault(), filesystem.Options{})
+	t.Cleanup(func() { _ = sto.Close() })
+	return sto
+}
+
+func sharedIndexFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "sharedindex.*"))
+	require.NoError(t, err)
+	return files
+}
+
+func TestIndexSplitIndex(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	sto := newSplitIndexStorage(t, dir, "[core]\n\tsplitIndex = true\n")
+
+	idx := &index.Index{Version: 2}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		idx.Entries = append(idx.Entries, &index.Entry{
+			Hash: plumbing.NewHash("880cd14280f4b9b6ed3986d6671f907d7cc2a198"),
+			Name: name,
+		})
+	}
+	require.NoError(t, sto.SetIndex(idx))
+
+	shared := sharedIndexFiles(t, dir)
+	require.Len(t, shared, 1)
+	require.NotNil(t, idx.SplitIndex)
+	assert.Equal(t, "sharedindex."+idx.SplitIndex.BaseHash.String(), filepath.Base(shared[0]))
+
+	// A change to a few entries is written to the index file only.
+	idx.Entries[1] = &index.Entry{Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), Name: "b"}
+	require.NoError(t, sto.SetIndex(idx))
+	assert.Equal(t, shared, sharedIndexFiles(t, dir))
+
+	read, err := newSplitIndexStorage(t, dir, "[core]\n\tsplitIndex = true\n").Index()
+	require.NoError(t, err)
+	require.Len(t, read.Entries, 10)
+	assert.Equal(t, plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"), read.Entries[1].Hash)
+	assert.Equal(t, idx.SplitIndex.BaseHash, read.SplitIndex.BaseHash)
+
+	// Past splitIndex.maxPercentChange, a new shared index is written.
+	read.Entries = read.Entries[:5]
+	require.NoError(t, sto.SetIndex(read))
+	assert.Len(t, sharedIndexFiles(t, dir), 2)
+	assert.NotEqual(t, idx.SplitIndex.BaseHash, read.SplitIndex.BaseHash)
+
+	read, err = newSplitIndexStorage(t, dir, "").Index()
+	require.NoError(t, err)
+	assert.Len(t, read.Entries, 5)
+}
+
+func TestIndexSplitIndexDisabled(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	sto := newSplitIndexStorage(t, dir, "[core]\n\tsplitIndex = true\n")
+
+	idx := &index.Index{
+		Version: 2,
+		Entries: []*index.Entry{
+			{Hash: plumbing.NewHash("880cd14280f4b9b6ed3986d6671f907d7cc2a198"), Name: "foo.go"},
+		},
+	}
+	require.NoError(t, sto.SetIndex(idx))
+
+	// With core.splitIndex unset, the index is kept split.
+	read, err := newSplitIndexStorage(t, dir, "").Index()
+	require.NoError(t, err)
+	require.NotNil(t, read.SplitIndex)
+
+	sto = newSplitIndexStorage(t, dir, "[core]\n\tsplitIndex = false\n")
+	require.NoError(t, sto.SetIndex(read))
+
+	read, err = newSplitIndexStorage(t, dir, "").Index()
+	require.NoError(t, err)
+	assert.Nil(t, read.SplitIndex)
+	require.Len(t, read.Entries, 1)
+	assert.Equal(t, "foo.go", read.Entries[0].Name)
+}
+
+func TestIndexEntryOffsetTable(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	sto := newSplitIndexStorage(t, dir, "[index]\n\tthreads = 4\n")
+
+	idx := &index.Index{Version: 4}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		idx.Entries = append(idx.Entries, &index.Entry{
+			Hash: plumbing.NewHash("880cd14280f4b9b6ed3986d6671f907d7cc2a198"),
+			Name: "dir/" + name,
+		})
+	}
+	require.NoError(t, sto.SetIndex(idx))
+
+	// As git, setting index.threads records the offset table.
+	read, err := newSplitIndexStorage(t, dir, "").Index()
+	require.NoError(t, err)
+	require.NotNil(t, read.EntryOffsetTable)
+	assert.Len(t, read.EntryOffsetTable.Blocks, 4)
+	require.NotNil(t, read.EndOfIndexEntry)
+	assert.EqualExportedValues(t, idx.Entries, read.Entries)
+
+	// Unconfigured, the table the index has is kept.
+	sto = newSplitIndexStorage(t, dir, "")
+	require.NoError(t, sto.SetIndex(read))
+	read, err = newSplitIndexStorage(t, dir, "[index]\n\tthreads = false\n").Index()
+	require.NoError(t, err)
+	require.NotNil(t, read.EntryOffsetTable)
+	assert.Len(t, read.EntryOffsetTable.Blocks, 4)
+
+	sto = newSplitIndexStorage(t, dir, "[index]\n\trecordOffsetTable = false\n\trecordEndOfIndexEntries = false\n")
+	require.NoError(t, sto.SetIndex(read))
+	read, err = newSplitIndexStorage(t, dir, "").Index()
+	require.NoError(t, err)
+	assert.Nil(t, read.EntryOffsetTable)
+	assert.Nil(t, read.EndOfIndexEntry)
+	assert.Len(t, read.Entries, 10)
+}
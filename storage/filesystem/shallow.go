@@ -17,8 +17,14 @@ type ShallowStorage struct {
 
 // SetShallow save the shallows in the shallow file in the .git folder as one
 // commit per line represented by 40-byte hexadecimal object terminated by a
-// newline.
+// newline. The shallow file is removed when there are no shallow commits.
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
+	// git considers a repository with a shallow file shallow, even an empty
+	// one, so a repository with no shallow commits left has none.
+	if len(commits) == 0 {
+		return s.dir.RemoveShallow()
+	}
+
 	f, err := s.dir.ShallowWriter()
 	if err != nil {
 		return err
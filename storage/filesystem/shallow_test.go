@@ -0,0 +1,40 @@
+package filesystem_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+)
+
+func TestSetShallowRemovesEmptyShallowFile(t *testing.T) {
+	t.Parallel()
+	fs := memfs.New()
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	defer func() { _ = sto.Close() }()
+
+	commits := []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+	require.NoError(t, sto.SetShallow(commits))
+	_, err := fs.Stat("shallow")
+	require.NoError(t, err)
+
+	shallows, err := sto.Shallow()
+	require.NoError(t, err)
+	assert.Equal(t, commits, shallows)
+
+	// git considers a repository with an empty shallow file shallow.
+	require.NoError(t, sto.SetShallow(nil))
+	_, err = fs.Stat("shallow")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	shallows, err = sto.Shallow()
+	require.NoError(t, err)
+	assert.Empty(t, shallows)
+	require.NoError(t, sto.SetShallow(nil))
+}
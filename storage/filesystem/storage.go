@@ -150,6 +150,10 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 	readRevIdx := true
 	writeRevIdx := true
 	skipHash := false
+	var splitIndex config.OptBool
+	var maxPercentChange int
+	var indexThreads string
+	var recordOffsetTable, recordEndOfIndexEntries config.OptBool
 
 	f, err := fs.Open("config")
 	if err == nil {
@@ -159,6 +163,11 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 			readRevIdx = cfg.Pack.ReadReverseIndex
 			writeRevIdx = cfg.Pack.WriteReverseIndex
 			skipHash = cfg.Index.SkipHash.IsTrue()
+			splitIndex = cfg.Core.SplitIndex
+			maxPercentChange = cfg.SplitIndex.MaxPercentChange
+			indexThreads = cfg.Index.Threads
+			recordOffsetTable = cfg.Index.RecordOffsetTable
+			recordEndOfIndexEntries = cfg.Index.RecordEndOfIndexEntries
 		}
 
 		_ = f.Close()
@@ -199,7 +208,7 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 
 		ObjectStorage:    NewObjectStorageWithOptions(dir, c, ops),
 		ReferenceStorage: ReferenceStorage{dir: dir},
-		IndexStorage:     IndexStorage{dir: dir, h: hasher.Hash, cache: ops.IndexCache, skipHash: skipHash},
+		IndexStorage:     IndexStorage{dir: dir, h: hasher.Hash, cache: ops.IndexCache, skipHash: skipHash, splitIndex: splitIndex, maxPercentChange: maxPercentChange, threads: indexThreads, recordOffsetTable: recordOffsetTable, recordEndOfIndexEntries: recordEndOfIndexEntries},
 		ShallowStorage:   ShallowStorage{dir: dir},
 		ConfigStorage:    ConfigStorage{dir: dir, objectFormat: ops.ObjectFormat},
 		ModuleStorage:    ModuleStorage{dir: dir, objectFormat: ops.ObjectFormat},
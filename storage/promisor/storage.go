@@ -0,0 +1,145 @@
+// Package promisor provides a storage for partial clones that fetches the
+// objects a promisor remote withheld when they are read, as git does.
+package promisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+)
+
+// Fetcher fetches objects from a promisor remote.
+type Fetcher interface {
+	// FetchObjects fetches the objects with the given hashes into st, in a
+	// pack recorded as coming from a promisor remote. The objects they
+	// reference may be left out, as a filtered fetch does.
+	FetchObjects(ctx context.Context, st storage.Storer, hashes []plumbing.Hash) error
+}
+
+// DefaultTimeout is the default bound of the fetches of a Storage.
+const DefaultTimeout = 5 * time.Minute
+
+// Storage is a filesystem storage of a partial clone. Reading an object
+// that is not stored fetches it from the promisor remote, so a partial
+// clone can be used as if it was complete.
+//
+// Only reads of object contents and sizes fetch: HasEncodedObject and the
+// iterators report the objects that are stored, as git does.
+type Storage struct {
+	*filesystem.Storage
+	fetcher Fetcher
+
+	// Timeout bounds each fetch from the promisor remote, as reads have no
+	// context to cancel them. DefaultTimeout is used if it is zero.
+	Timeout time.Duration
+
+	mu sync.Mutex
+	// unavailable holds the objects the remote failed to send, so they are
+	// not asked for again.
+	unavailable map[plumbing.Hash]struct{}
+}
+
+// NewStorage returns a Storage reading objects from s, and fetching those it
+// does not hold with f.
+func NewStorage(s *filesystem.Storage, f Fetcher) *Storage {
+	return &Storage{
+		Storage:     s,
+		fetcher:     f,
+		unavailable: make(map[plumbing.Hash]struct{}),
+	}
+}
+
+// Unwrap returns the underlying storage, whose reads do not fetch.
+func (s *Storage) Unwrap() *filesystem.Storage {
+	return s.Storage
+}
+
+// EncodedObject honors the storer.EncodedObjectStorer interface. An object
+// that is not stored is fetched from the promisor remote.
+func (s *Storage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.Storage.EncodedObject(t, h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return obj, err
+	}
+	if err := s.fetch(context.Background(), []plumbing.Hash{h}); err != nil {
+		return nil, err
+	}
+	return s.Storage.EncodedObject(t, h)
+}
+
+// EncodedObjectSize honors the storer.EncodedObjectStorer interface. An
+// object that is not stored is fetched from the promisor remote.
+func (s *Storage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	size, err := s.Storage.EncodedObjectSize(h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return size, err
+	}
+	if err := s.fetch(context.Background(), []plumbing.Hash{h}); err != nil {
+		return 0, err
+	}
+	return s.Storage.EncodedObjectSize(h)
+}
+
+// FetchMissing fetches, in a single request, the objects with the given
+// hashes that are not stored. Fetching ahead the objects an operation is
+// about to read spares the round trip of fetching them one at a time. The
+// fetch ends when ctx is done, or after Timeout.
+func (s *Storage) FetchMissing(ctx context.Context, hashes []plumbing.Hash) error {
+	return s.fetch(ctx, hashes)
+}
+
+// fetch fetches the objects of hashes that are neither stored nor known to
+// be unavailable. Fetches are serialized, so that concurrent reads of an
+// object fetch it once.
+func (s *Storage) fetch(ctx context.Context, hashes []plumbing.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[plumbing.Hash]struct{}, len(hashes))
+	var missing []plumbing.Hash
+	for _, h := range hashes {
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		if _, ok := s.unavailable[h]; ok {
+			continue
+		}
+		err := s.Storage.HasEncodedObject(h)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+		missing = append(missing, h)
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := s.fetcher.FetchObjects(ctx, s.Storage, missing); err != nil {
+		return fmt.Errorf("%w: fetch from promisor remote: %w", plumbing.ErrObjectNotFound, err)
+	}
+
+	for _, h := range missing {
+		if errors.Is(s.Storage.HasEncodedObject(h), plumbing.ErrObjectNotFound) {
+			s.unavailable[h] = struct{}{}
+		}
+	}
+	return nil
+}
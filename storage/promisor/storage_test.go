@@ -0,0 +1,137 @@
+package promisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+)
+
+// fakeFetcher serves the objects of remote, recording the requests made.
+type fakeFetcher struct {
+	remote   map[plumbing.Hash]plumbing.EncodedObject
+	requests [][]plumbing.Hash
+	err      error
+}
+
+func (f *fakeFetcher) FetchObjects(_ context.Context, st storage.Storer, hashes []plumbing.Hash) error {
+	f.requests = append(f.requests, hashes)
+	if f.err != nil {
+		return f.err
+	}
+	for _, h := range hashes {
+		if obj, ok := f.remote[h]; ok {
+			if _, err := st.SetEncodedObject(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func blob(content string) plumbing.EncodedObject {
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.BlobObject)
+	_, _ = obj.Write([]byte(content))
+	return obj
+}
+
+func newTestStorage(t *testing.T, remote ...plumbing.EncodedObject) (*Storage, *fakeFetcher) {
+	t.Helper()
+
+	f := &fakeFetcher{remote: make(map[plumbing.Hash]plumbing.EncodedObject)}
+	for _, obj := range remote {
+		f.remote[obj.Hash()] = obj
+	}
+	return NewStorage(filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault()), f), f
+}
+
+func TestEncodedObjectFetches(t *testing.T) {
+	t.Parallel()
+
+	obj := blob("content")
+	s, f := newTestStorage(t, obj)
+
+	require.ErrorIs(t, s.HasEncodedObject(obj.Hash()), plumbing.ErrObjectNotFound)
+	assert.Empty(t, f.requests, "HasEncodedObject must not fetch")
+
+	got, err := s.EncodedObject(plumbing.BlobObject, obj.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, obj.Hash(), got.Hash())
+	require.NoError(t, s.HasEncodedObject(obj.Hash()))
+
+	size, err := s.EncodedObjectSize(obj.Hash())
+	require.NoError(t, err)
+	assert.EqualValues(t, len("content"), size)
+	assert.Len(t, f.requests, 1, "a fetched object is read locally")
+}
+
+func TestEncodedObjectUnavailable(t *testing.T) {
+	t.Parallel()
+
+	s, f := newTestStorage(t)
+	h := blob("absent").Hash()
+
+	_, err := s.EncodedObject(plumbing.AnyObject, h)
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+	_, err = s.EncodedObjectSize(h)
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+	assert.Len(t, f.requests, 1, "an object the remote does not have is asked for once")
+}
+
+func TestEncodedObjectFetchError(t *testing.T) {
+	t.Parallel()
+
+	s, f := newTestStorage(t)
+	f.err = errors.New("connection refused")
+
+	_, err := s.EncodedObject(plumbing.AnyObject, blob("absent").Hash())
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+	require.ErrorContains(t, err, "connection refused")
+}
+
+func TestFetchMissing(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := blob("a"), blob("b"), blob("c")
+	s, f := newTestStorage(t, a, b, c)
+	_, err := s.Unwrap().SetEncodedObject(a)
+	require.NoError(t, err)
+
+	require.NoError(t, s.FetchMissing(context.Background(),
+		[]plumbing.Hash{a.Hash(), b.Hash(), c.Hash(), b.Hash()}))
+	require.Len(t, f.requests, 1)
+	assert.ElementsMatch(t, []plumbing.Hash{b.Hash(), c.Hash()}, f.requests[0],
+		"only the missing objects are fetched, once each")
+
+	require.NoError(t, s.FetchMissing(context.Background(), []plumbing.Hash{b.Hash(), c.Hash()}))
+	assert.Len(t, f.requests, 1, "nothing is left to fetch")
+}
+
+// blockingFetcher blocks until the context of the fetch is done.
+type blockingFetcher struct{}
+
+func (blockingFetcher) FetchObjects(ctx context.Context, _ storage.Storer, _ []plumbing.Hash) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestEncodedObjectFetchTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := NewStorage(filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault()), blockingFetcher{})
+	s.Timeout = 10 * time.Millisecond
+
+	_, err := s.EncodedObject(plumbing.AnyObject, blob("absent").Hash())
+	require.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
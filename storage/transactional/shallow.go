@@ -9,6 +9,9 @@ import (
 type ShallowStorage struct {
 	storer.ShallowStorer
 	temporal storer.ShallowStorer
+	// set is whether SetShallow was called, as an empty temporal storage
+	// may mean no shallow commits are left.
+	set bool
 }
 
 // NewShallowStorage returns a new ShallowStorage based on a base storer and
@@ -22,7 +25,12 @@ func NewShallowStorage(base, temporal storer.ShallowStorer) *ShallowStorage {
 
 // SetShallow honors the storer.ShallowStorer interface.
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
-	return s.temporal.SetShallow(commits)
+	if err := s.temporal.SetShallow(commits); err != nil {
+		return err
+	}
+
+	s.set = true
+	return nil
 }
 
 // Shallow honors the storer.ShallowStorer interface.
@@ -32,7 +40,7 @@ func (s *ShallowStorage) Shallow() ([]plumbing.Hash, error) {
 		return nil, err
 	}
 
-	if len(shallow) != 0 {
+	if s.set || len(shallow) != 0 {
 		return shallow, nil
 	}
 
@@ -43,7 +51,7 @@ func (s *ShallowStorage) Shallow() ([]plumbing.Hash, error) {
 // base storage.
 func (s *ShallowStorage) Commit() error {
 	commits, err := s.temporal.Shallow()
-	if err != nil || len(commits) == 0 {
+	if err != nil || (!s.set && len(commits) == 0) {
 		return err
 	}
 
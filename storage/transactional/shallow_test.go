@@ -68,3 +68,25 @@ func (s *ShallowSuite) TestCommit() {
 	s.Len(commits, 1)
 	s.Equal(commitB, commits[0])
 }
+
+func (s *ShallowSuite) TestCommitUnshallow() {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	rs := NewShallowStorage(base, temporal)
+
+	commitA := plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	s.Nil(base.SetShallow([]plumbing.Hash{commitA}))
+	s.Nil(rs.SetShallow(nil))
+
+	commits, err := rs.Shallow()
+	s.NoError(err)
+	s.Empty(commits)
+
+	s.Nil(rs.Commit())
+
+	commits, err = base.Shallow()
+	s.NoError(err)
+	s.Empty(commits)
+}
@@ -0,0 +1,177 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+var (
+	// ErrUnknownEncoding is returned when looking up an encoding that is
+	// not supported.
+	ErrUnknownEncoding = errors.New("unknown encoding")
+	// ErrBOMRequired is returned when converting content without a byte
+	// order mark from an encoding that requires one, such as UTF-16.
+	ErrBOMRequired = errors.New("BOM is required")
+	// ErrBOMProhibited is returned when converting content with a byte
+	// order mark from an encoding that has its endianness in its name,
+	// such as UTF-16LE.
+	ErrBOMProhibited = errors.New("BOM is prohibited")
+)
+
+var (
+	utf16BEBOM = []byte{0xfe, 0xff}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf32BEBOM = []byte{0x00, 0x00, 0xfe, 0xff}
+	utf32LEBOM = []byte{0xff, 0xfe, 0x00, 0x00}
+)
+
+type bomRule int
+
+const (
+	bomAllowed bomRule = iota
+	bomRequired
+	bomProhibited
+)
+
+// unicodeEncodings are the Unicode encodings, by their name. As with iconv
+// on which git relies, content is written with a big-endian byte order mark
+// in UTF-16 and UTF-32, and in the -BOM encodings, with a byte order mark
+// of their endianness.
+var unicodeEncodings = map[string]struct {
+	enc  encoding.Encoding
+	boms [][]byte
+	rule bomRule
+}{
+	"UTF-16":       {unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), [][]byte{utf16BEBOM, utf16LEBOM}, bomRequired},
+	"UTF-16BE":     {unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), [][]byte{utf16BEBOM, utf16LEBOM}, bomProhibited},
+	"UTF-16LE":     {unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), [][]byte{utf16BEBOM, utf16LEBOM}, bomProhibited},
+	"UTF-16BE-BOM": {unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), [][]byte{utf16BEBOM}, bomRequired},
+	"UTF-16LE-BOM": {unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), [][]byte{utf16LEBOM}, bomRequired},
+	"UTF-32":       {utf32.UTF32(utf32.BigEndian, utf32.ExpectBOM), [][]byte{utf32BEBOM, utf32LEBOM}, bomRequired},
+	"UTF-32BE":     {utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), [][]byte{utf32BEBOM, utf32LEBOM}, bomProhibited},
+	"UTF-32LE":     {utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), [][]byte{utf32BEBOM, utf32LEBOM}, bomProhibited},
+}
+
+// Encoding converts the content of files between the encoding set by their
+// working-tree-encoding attribute, in the worktree, and UTF-8, in the
+// repository.
+type Encoding struct {
+	name string
+	enc  encoding.Encoding
+	boms [][]byte
+	rule bomRule
+}
+
+// LookupEncoding returns the encoding named name, such as UTF-16LE or
+// SHIFT-JIS, matched case-insensitively. It returns nil for UTF-8, whose
+// content needs no conversion.
+func LookupEncoding(name string) (*Encoding, error) {
+	name = normalizeEncodingName(name)
+	if name == "UTF-8" {
+		return nil, nil
+	}
+
+	if u, ok := unicodeEncodings[name]; ok {
+		return &Encoding{name: name, enc: u.enc, boms: u.boms, rule: u.rule}, nil
+	}
+
+	// iconv names use hyphens where IANA ones may use underscores, as
+	// SHIFT-JIS for Shift_JIS.
+	for _, n := range []string{name, strings.ReplaceAll(name, "-", "_")} {
+		if enc, err := ianaindex.IANA.Encoding(n); err == nil && enc != nil {
+			return &Encoding{name: name, enc: enc}, nil
+		}
+		if enc, err := htmlindex.Get(n); err == nil {
+			return &Encoding{name: name, enc: enc}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+}
+
+// normalizeEncodingName upper-cases name, and adds the hyphen UTF8, UTF16
+// and UTF32 may be written without.
+func normalizeEncodingName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if rest, ok := strings.CutPrefix(name, "UTF"); ok && !strings.HasPrefix(rest, "-") {
+		name = "UTF-" + rest
+	}
+	return name
+}
+
+// Name returns the upper-cased name of the encoding.
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// ToGit converts content from the encoding into UTF-8. It fails if content
+// is not valid in the encoding, or misses a byte order mark the encoding
+// requires, or has one it prohibits.
+func (e *Encoding) ToGit(content []byte) ([]byte, error) {
+	if len(content) == 0 {
+		return content, nil
+	}
+
+	hasBOM := false
+	for _, bom := range e.boms {
+		hasBOM = hasBOM || bytes.HasPrefix(content, bom)
+	}
+	switch {
+	case e.rule == bomRequired && !hasBOM:
+		return nil, fmt.Errorf("%w if encoded as %s", ErrBOMRequired, e.name)
+	case e.rule == bomProhibited && hasBOM:
+		return nil, fmt.Errorf("%w if encoded as %s", ErrBOMProhibited, e.name)
+	}
+
+	out, err := e.enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode from %s to UTF-8: %w", e.name, err)
+	}
+
+	// Invalid content is decoded into replacement characters, which do not
+	// convert back into it.
+	if bytes.ContainsRune(out, utf8.RuneError) {
+		if back, err := e.ToWorktree(out); err != nil || !bytes.Equal(back, content) {
+			return nil, fmt.Errorf("failed to encode from %s to UTF-8: invalid content", e.name)
+		}
+	}
+
+	return out, nil
+}
+
+// ToWorktree converts content from UTF-8 into the encoding. It fails if
+// content has characters the encoding cannot represent.
+func (e *Encoding) ToWorktree(content []byte) ([]byte, error) {
+	if len(content) == 0 {
+		return content, nil
+	}
+
+	out, err := e.enc.NewEncoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode from UTF-8 to %s: %w", e.name, err)
+	}
+
+	return out, nil
+}
+
+// RoundTrips reports whether content, in the encoding, is the same once
+// converted into UTF-8 and back, as git checks for the encodings listed in
+// core.checkRoundtripEncoding.
+func (e *Encoding) RoundTrips(content []byte) bool {
+	out, err := e.ToGit(content)
+	if err != nil {
+		return false
+	}
+
+	back, err := e.ToWorktree(out)
+	return err == nil && bytes.Equal(back, content)
+}
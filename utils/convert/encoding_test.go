@@ -0,0 +1,113 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupEncoding(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"UTF-8", "utf8", " Utf-8 "} {
+		e, err := LookupEncoding(name)
+		require.NoError(t, err, name)
+		assert.Nil(t, e, name)
+	}
+
+	for name, expected := range map[string]string{
+		"utf-16le":   "UTF-16LE",
+		"UTF16":      "UTF-16",
+		"SHIFT-JIS":  "SHIFT-JIS",
+		"Shift_JIS":  "SHIFT_JIS",
+		"ISO-8859-1": "ISO-8859-1",
+		"EUC-KR":     "EUC-KR",
+	} {
+		e, err := LookupEncoding(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, e.Name(), name)
+	}
+
+	_, err := LookupEncoding("no-such-encoding")
+	require.ErrorIs(t, err, ErrUnknownEncoding)
+}
+
+func TestEncodingConversion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		worktree []byte
+		git      string
+	}{
+		{"UTF-16LE", []byte{'h', 0, 'i', 0, '\n', 0}, "hi\n"},
+		{"UTF-16BE", []byte{0, 'h', 0, 'i'}, "hi"},
+		{"UTF-16", []byte{0xfe, 0xff, 0, 'h', 0, 'i'}, "hi"},
+		{"UTF-16LE-BOM", []byte{0xff, 0xfe, 'h', 0, 'i', 0}, "hi"},
+		{"UTF-32LE", []byte{'h', 0, 0, 0}, "h"},
+		{"UTF-32", []byte{0, 0, 0xfe, 0xff, 0, 0, 0, 'h'}, "h"},
+		{"SHIFT-JIS", []byte{0x82, 0xa0}, "あ"},
+		{"ISO-8859-1", []byte{'c', 'a', 'f', 0xe9}, "café"},
+		{"UTF-16LE", []byte{}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			e, err := LookupEncoding(test.name)
+			require.NoError(t, err)
+
+			git, err := e.ToGit(test.worktree)
+			require.NoError(t, err)
+			assert.Equal(t, test.git, string(git))
+
+			worktree, err := e.ToWorktree(git)
+			require.NoError(t, err)
+			assert.Equal(t, test.worktree, worktree)
+			assert.True(t, e.RoundTrips(test.worktree))
+		})
+	}
+}
+
+func TestEncodingUTF16ByteOrderMark(t *testing.T) {
+	t.Parallel()
+
+	e, err := LookupEncoding("UTF-16")
+	require.NoError(t, err)
+
+	// The byte order mark sets the endianness, and content is checked out
+	// big-endian as with iconv.
+	git, err := e.ToGit([]byte{0xff, 0xfe, 'h', 0})
+	require.NoError(t, err)
+	assert.Equal(t, "h", string(git))
+	assert.False(t, e.RoundTrips([]byte{0xff, 0xfe, 'h', 0}))
+
+	_, err = e.ToGit([]byte{'h', 0})
+	require.ErrorIs(t, err, ErrBOMRequired)
+
+	e, err = LookupEncoding("UTF-16LE")
+	require.NoError(t, err)
+	_, err = e.ToGit([]byte{0xff, 0xfe, 'h', 0})
+	require.ErrorIs(t, err, ErrBOMProhibited)
+}
+
+func TestEncodingInvalid(t *testing.T) {
+	t.Parallel()
+
+	e, err := LookupEncoding("UTF-16LE")
+	require.NoError(t, err)
+	_, err = e.ToGit([]byte{0x00, 0xd8, 'h', 0})
+	require.Error(t, err)
+
+	// A replacement character is valid content.
+	git, err := e.ToGit([]byte{0xfd, 0xff})
+	require.NoError(t, err)
+	assert.Equal(t, "�", string(git))
+
+	e, err = LookupEncoding("SHIFT-JIS")
+	require.NoError(t, err)
+	_, err = e.ToWorktree([]byte("😀"))
+	require.Error(t, err)
+}
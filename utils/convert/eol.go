@@ -6,6 +6,58 @@ import (
 	"io"
 )
 
+// EOLConversion is the line ending conversion of a file between the
+// repository and the worktree, which git derives from the text and eol
+// attributes of its path and from core.autocrlf and core.eol.
+type EOLConversion int
+
+const (
+	// EOLNone leaves the content as is, as for binary files.
+	EOLNone EOLConversion = iota
+	// EOLInput converts CRLF line endings into LF when adding the file to
+	// the repository, and checks it out with LF line endings.
+	EOLInput
+	// EOLCRLF converts CRLF line endings into LF when adding the file to the
+	// repository, and LF line endings into CRLF when checking it out.
+	EOLCRLF
+	// EOLAutoInput is EOLInput for content detected as text.
+	EOLAutoInput
+	// EOLAutoCRLF is EOLCRLF for content detected as text.
+	EOLAutoCRLF
+)
+
+// CRLF reports whether text is checked out with CRLF line endings.
+func (c EOLConversion) CRLF() bool {
+	return c == EOLCRLF || c == EOLAutoCRLF
+}
+
+// ToGit reports whether content with stat has its CRLF line endings
+// converted into LF when added to the repository.
+func (c EOLConversion) ToGit(stat Stat) bool {
+	switch c {
+	case EOLInput, EOLCRLF:
+		return stat.CRLF > 0
+	case EOLAutoInput, EOLAutoCRLF:
+		return stat.CRLF > 0 && !stat.IsBinary()
+	default:
+		return false
+	}
+}
+
+// ToWorktree reports whether content with stat has its LF line endings
+// converted into CRLF when checked out. As git, content detected as text
+// that already has CR in it is left as is.
+func (c EOLConversion) ToWorktree(stat Stat) bool {
+	switch c {
+	case EOLCRLF:
+		return stat.LoneLF > 0
+	case EOLAutoCRLF:
+		return stat.LoneLF > 0 && stat.CRLF == 0 && stat.LoneCR == 0 && !stat.IsBinary()
+	default:
+		return false
+	}
+}
+
 type crlfToLFWriter struct {
 	w io.Writer
 }
@@ -69,3 +69,42 @@ func TestLFToCRLFConverter(t *testing.T) {
 		})
 	}
 }
+
+func TestEOLConversion(t *testing.T) {
+	t.Parallel()
+
+	lf := Stat{LoneLF: 2, Printable: 10}
+	crlf := Stat{CRLF: 2, Printable: 10}
+	mixed := Stat{LoneLF: 1, CRLF: 1, Printable: 10}
+	binary := Stat{LoneLF: 1, CRLF: 1, NUL: 1, Printable: 10}
+
+	tests := []struct {
+		conversion        EOLConversion
+		stat              Stat
+		toGit, toWorktree bool
+	}{
+		{EOLNone, crlf, false, false},
+		{EOLNone, lf, false, false},
+		{EOLInput, crlf, true, false},
+		{EOLInput, lf, false, false},
+		{EOLInput, binary, true, false},
+		{EOLCRLF, lf, false, true},
+		{EOLCRLF, mixed, true, true},
+		{EOLCRLF, binary, true, true},
+		{EOLAutoInput, crlf, true, false},
+		{EOLAutoInput, binary, false, false},
+		{EOLAutoCRLF, lf, false, true},
+		{EOLAutoCRLF, mixed, true, false},
+		{EOLAutoCRLF, binary, false, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.toGit, test.conversion.ToGit(test.stat), "%d %+v", test.conversion, test.stat)
+		assert.Equal(t, test.toWorktree, test.conversion.ToWorktree(test.stat), "%d %+v", test.conversion, test.stat)
+	}
+
+	assert.True(t, EOLCRLF.CRLF())
+	assert.True(t, EOLAutoCRLF.CRLF())
+	assert.False(t, EOLInput.CRLF())
+	assert.False(t, EOLNone.CRLF())
+}
@@ -0,0 +1,61 @@
+// Package filter runs the external filter drivers selected by the filter
+// gitattribute: single-shot clean and smudge commands, and long-running
+// filter processes speaking the filter protocol.
+//
+// See https://git-scm.com/docs/gitattributes#_filter.
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Run runs the single-shot filter command, with the content read from src
+// as its standard input, and writes its standard output to dst. As git, the
+// command is run by the shell, in dir, and any %f in it is replaced with
+// path, quoted for the shell.
+func Run(ctx context.Context, command, dir, path string, dst io.Writer, src io.Reader) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", expandCommand(command, path))
+	cmd.Dir = dir
+	cmd.Stdin = src
+	cmd.Stdout = dst
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("filter command %q: %w", command, err)
+	}
+
+	return nil
+}
+
+// expandCommand replaces %f with path, and %% with %, in command.
+func expandCommand(command, path string) string {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] != '%' || i+1 == len(command) {
+			b.WriteByte(command[i])
+			continue
+		}
+
+		switch command[i+1] {
+		case 'f':
+			b.WriteString(shellQuote(path))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			continue
+		}
+		i++
+	}
+
+	return b.String()
+}
+
+// shellQuote quotes s between single quotes, as git's sq_quote_buf.
+func shellQuote(s string) string {
+	r := strings.NewReplacer(`'`, `'\''`, `!`, `'\!'`)
+	return "'" + r.Replace(s) + "'"
+}
@@ -0,0 +1,50 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	err := Run(context.Background(), `tr a-z A-Z; echo %f; echo 100%%; pwd`, dir, "it's a file!", &out, strings.NewReader("content\n"))
+	require.NoError(t, err)
+
+	wd, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "CONTENT\nit's a file!\n100%\n"+wd+"\n", out.String())
+
+	err = Run(context.Background(), "exit 1", dir, "file", &out, strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestExpandCommand(t *testing.T) {
+	t.Parallel()
+
+	for command, expected := range map[string]string{
+		"clean %f":      "clean 'a b'",
+		"clean -- %f%":  "clean -- 'a b'%",
+		"clean %% %x":   "clean % %x",
+		"clean":         "clean",
+		"%f %f":         "'a b' 'a b'",
+		"clean %f%%%f":  "clean 'a b'%'a b'",
+		"smudge %%f %f": "smudge %f 'a b'",
+	} {
+		assert.Equal(t, expected, expandCommand(command, "a b"), command)
+	}
+
+	assert.Equal(t, `'it'\''s'\!''`, shellQuote("it's!"))
+}
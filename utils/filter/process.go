@@ -0,0 +1,348 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+// Capabilities a long-running filter process can support.
+const (
+	Clean  = "clean"
+	Smudge = "smudge"
+	Delay  = "delay"
+)
+
+var (
+	// ErrFailed is returned when a filter process fails to filter a file.
+	ErrFailed = errors.New("filter process failed")
+	// ErrAborted is returned when a filter process fails to filter a file,
+	// and asks to not be given any other file for the same command.
+	ErrAborted = errors.New("filter process aborted")
+	// ErrUnsupported is returned when a filter process is given a command
+	// it does not support, or has aborted.
+	ErrUnsupported = errors.New("filter process does not support the command")
+	// ErrProtocol is returned when a filter process does not follow the
+	// filter protocol. The process cannot be used anymore.
+	ErrProtocol = errors.New("filter process protocol error")
+)
+
+const (
+	statusSuccess = "success"
+	statusAbort   = "abort"
+	statusDelayed = "delayed"
+)
+
+// Process is a long-running filter process, as configured with
+// filter.<driver>.process, speaking version 2 of the filter protocol.
+//
+// A Process filters one file at a time, and is not safe for concurrent use.
+type Process struct {
+	command string
+	cmd     *exec.Cmd
+	in      io.WriteCloser
+	out     *bufio.Reader
+
+	caps map[string]bool
+	err  error
+}
+
+// StartProcess starts the filter process command in dir, as git through the
+// shell, and negotiates the capabilities it supports out of caps.
+func StartProcess(ctx context.Context, command, dir string, caps ...string) (*Process, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("filter process %q: %w", command, err)
+	}
+
+	p := &Process{
+		command: command,
+		cmd:     cmd,
+		in:      in,
+		out:     bufio.NewReader(out),
+		caps:    make(map[string]bool),
+	}
+
+	if err := p.handshake(caps); err != nil {
+		err = p.fail(err)
+		_ = p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *Process) handshake(caps []string) error {
+	if err := p.writeList("git-filter-client", "version=2"); err != nil {
+		return err
+	}
+
+	lines, err := p.readList()
+	if err != nil {
+		return err
+	}
+	if len(lines) < 2 || lines[0] != "git-filter-server" || lines[1] != "version=2" {
+		return fmt.Errorf("unexpected welcome %q", lines)
+	}
+
+	wanted := make([]string, 0, len(caps))
+	for _, c := range caps {
+		wanted = append(wanted, "capability="+c)
+	}
+	if err := p.writeList(wanted...); err != nil {
+		return err
+	}
+
+	lines, err = p.readList()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		c, ok := strings.CutPrefix(line, "capability=")
+		if !ok {
+			return fmt.Errorf("unexpected capability %q", line)
+		}
+		p.caps[c] = true
+	}
+
+	return nil
+}
+
+// Supports reports whether the process supports the capability.
+func (p *Process) Supports(capability string) bool {
+	return p.err == nil && p.caps[capability]
+}
+
+// Filter has the process run command, Clean or Smudge, on the file at path
+// whose content is read from src, and writes the result to dst. Content
+// may have been written to dst when it fails.
+//
+// If canDelay is set, and the process supports [Delay], the process can
+// delay the file, in which case nothing is written to dst and delayed is
+// set. The content of delayed files is retrieved with Filter once they are
+// listed by [Process.AvailableBlobs], with an empty src.
+func (p *Process) Filter(command, path string, dst io.Writer, src io.Reader, canDelay bool) (delayed bool, err error) {
+	if !p.Supports(command) {
+		if p.err != nil {
+			return false, p.err
+		}
+		return false, ErrUnsupported
+	}
+
+	header := []string{"command=" + command, "pathname=" + path}
+	if canDelay && p.caps[Delay] {
+		header = append(header, "can-delay=1")
+	}
+	if err := p.writeList(header...); err != nil {
+		return false, p.fail(err)
+	}
+	if err := p.writeContent(src); err != nil {
+		return false, p.fail(err)
+	}
+
+	status, err := p.readStatus(statusSuccess)
+	if err != nil {
+		return false, p.fail(err)
+	}
+
+	switch status {
+	case statusSuccess:
+	case statusDelayed:
+		if !canDelay {
+			return false, p.fail(fmt.Errorf("unexpected delay of %s", path))
+		}
+		return true, nil
+	default:
+		return false, p.statusError(command, path, status)
+	}
+
+	if err := p.readContent(dst); err != nil {
+		return false, p.fail(err)
+	}
+
+	// The status sent after the content overrides the one sent before.
+	status, err = p.readStatus(status)
+	if err != nil {
+		return false, p.fail(err)
+	}
+	if status != statusSuccess {
+		return false, p.statusError(command, path, status)
+	}
+
+	return false, nil
+}
+
+// AvailableBlobs returns the paths of the delayed files whose content is
+// available. It blocks until there is at least one, unless there are no
+// more delayed files, in which case it returns none.
+func (p *Process) AvailableBlobs() ([]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if err := p.writeList("command=list_available_blobs"); err != nil {
+		return nil, p.fail(err)
+	}
+
+	lines, err := p.readList()
+	if err != nil {
+		return nil, p.fail(err)
+	}
+
+	paths := make([]string, 0, len(lines))
+	for _, line := range lines {
+		path, ok := strings.CutPrefix(line, "pathname=")
+		if !ok {
+			return nil, p.fail(fmt.Errorf("unexpected line %q", line))
+		}
+		paths = append(paths, path)
+	}
+
+	status, err := p.readStatus(statusSuccess)
+	if err != nil {
+		return nil, p.fail(err)
+	}
+	if status != statusSuccess {
+		return nil, p.fail(fmt.Errorf("list_available_blobs: status %q", status))
+	}
+
+	return paths, nil
+}
+
+// Close stops the process, closing its standard input and waiting for it
+// to exit.
+func (p *Process) Close() error {
+	err := p.in.Close()
+	if werr := p.cmd.Wait(); err == nil {
+		err = werr
+	}
+
+	if p.err == nil {
+		p.err = fmt.Errorf("filter process %q: closed", p.command)
+	}
+
+	return err
+}
+
+func (p *Process) statusError(command, path, status string) error {
+	if status == statusAbort {
+		delete(p.caps, command)
+		return fmt.Errorf("%w: %s %s", ErrAborted, command, path)
+	}
+
+	return fmt.Errorf("%w: %s %s: status %q", ErrFailed, command, path, status)
+}
+
+// fail makes the process unusable because of err, and returns it.
+func (p *Process) fail(err error) error {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %q: %w", ErrProtocol, p.command, err)
+	}
+	return p.err
+}
+
+func (p *Process) writeList(lines ...string) error {
+	for _, line := range lines {
+		if _, err := pktline.Writeln(p.in, line); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(p.in)
+}
+
+func (p *Process) writeContent(src io.Reader) error {
+	buf := pktline.GetBuffer()
+	defer pktline.PutBuffer(buf)
+
+	chunk := (*buf)[:pktline.MaxPayloadSize]
+	for {
+		n, err := io.ReadFull(src, chunk)
+		if n > 0 {
+			if _, werr := pktline.Write(p.in, chunk[:n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(p.in)
+}
+
+// readList reads the text lines sent by the process up to a flush.
+func (p *Process) readList() ([]string, error) {
+	var lines []string
+	for {
+		l, line, err := pktline.ReadLine(p.out)
+		if err != nil {
+			return nil, err
+		}
+		if l == pktline.Flush {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimSuffix(string(line), "\n"))
+	}
+}
+
+// readStatus reads a list of key=value lines, and returns the value of its
+// last status, or status if it has none.
+func (p *Process) readStatus(status string) (string, error) {
+	lines, err := p.readList()
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range lines {
+		if s, ok := strings.CutPrefix(line, "status="); ok {
+			status = s
+		}
+	}
+
+	return status, nil
+}
+
+// readContent copies the content sent by the process up to a flush to dst.
+func (p *Process) readContent(dst io.Writer) error {
+	buf := pktline.GetBuffer()
+	defer pktline.PutBuffer(buf)
+
+	for {
+		l, err := pktline.Read(p.out, (*buf)[:])
+		var errLine *pktline.ErrorLine
+		if err != nil && (!errors.As(err, &errLine) || l < pktline.LenSize) {
+			// A packet of content starting with "ERR " is still content.
+			return err
+		}
+		if l == pktline.Flush {
+			return nil
+		}
+		if l < pktline.LenSize {
+			return fmt.Errorf("unexpected packet %04x", l)
+		}
+		if _, err := dst.Write((*buf)[pktline.LenSize:l]); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filterProcessEnv makes the test binary run as the filter process of
+// runFilterProcess, supporting the capabilities it lists.
+const filterProcessEnv = "GO_GIT_TEST_FILTER_PROCESS"
+
+func TestMain(m *testing.M) {
+	if caps := os.Getenv(filterProcessEnv); caps != "" {
+		os.Exit(runFilterProcess(os.Stdin, os.Stdout, strings.Split(caps, ",")))
+	}
+
+	os.Exit(m.Run())
+}
+
+func startTestProcess(t *testing.T, serverCaps string, caps ...string) *Process {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	p, err := StartProcess(context.Background(), filterProcessEnv+"="+serverCaps+" "+shellQuote(exe), t.TempDir(), caps...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func TestProcessFilter(t *testing.T) {
+	t.Parallel()
+
+	p := startTestProcess(t, "clean,smudge", Clean, Smudge, Delay)
+	assert.True(t, p.Supports(Clean))
+	assert.True(t, p.Supports(Smudge))
+	assert.False(t, p.Supports(Delay))
+
+	var out bytes.Buffer
+	delayed, err := p.Filter(Smudge, "a.txt", &out, strings.NewReader("content"), true)
+	require.NoError(t, err)
+	assert.False(t, delayed)
+	assert.Equal(t, "CONTENT", out.String())
+
+	// Content larger than a packet, starting as an error packet.
+	content := "ERR " + strings.Repeat("x", 100000)
+	out.Reset()
+	_, err = p.Filter(Clean, "big.txt", &out, strings.NewReader(strings.ToUpper(content)), false)
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToLower(content), out.String())
+
+	out.Reset()
+	_, err = p.Filter(Smudge, "empty.txt", &out, strings.NewReader(""), false)
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+
+	_, err = p.Filter(Smudge, "error.txt", &out, strings.NewReader("content"), false)
+	require.ErrorIs(t, err, ErrFailed)
+
+	_, err = p.Filter(Smudge, "late-error.txt", &out, strings.NewReader("content"), false)
+	require.ErrorIs(t, err, ErrFailed)
+	assert.True(t, p.Supports(Smudge))
+
+	_, err = p.Filter(Smudge, "abort.txt", &out, strings.NewReader("content"), false)
+	require.ErrorIs(t, err, ErrAborted)
+	assert.False(t, p.Supports(Smudge))
+	assert.True(t, p.Supports(Clean))
+
+	_, err = p.Filter(Smudge, "a.txt", &out, strings.NewReader("content"), false)
+	require.ErrorIs(t, err, ErrUnsupported)
+
+	require.NoError(t, p.Close())
+	_, err = p.Filter(Clean, "a.txt", &out, strings.NewReader("content"), false)
+	require.Error(t, err)
+}
+
+func TestProcessDelay(t *testing.T) {
+	t.Parallel()
+
+	p := startTestProcess(t, "smudge,delay", Smudge, Delay)
+	require.True(t, p.Supports(Delay))
+
+	var out bytes.Buffer
+	for _, path := range []string{"delay-a.txt", "delay-b.txt"} {
+		delayed, err := p.Filter(Smudge, path, &out, strings.NewReader(path), true)
+		require.NoError(t, err)
+		assert.True(t, delayed)
+	}
+	assert.Empty(t, out.String())
+
+	paths, err := p.AvailableBlobs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delay-a.txt", "delay-b.txt"}, paths)
+
+	for _, path := range paths {
+		out.Reset()
+		delayed, err := p.Filter(Smudge, path, &out, strings.NewReader(""), false)
+		require.NoError(t, err)
+		assert.False(t, delayed)
+		assert.Equal(t, strings.ToUpper(path), out.String())
+	}
+
+	paths, err = p.AvailableBlobs()
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+func TestStartProcessBadHandshake(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	_, err := StartProcess(context.Background(), "echo hello", t.TempDir(), Clean)
+	require.ErrorIs(t, err, ErrProtocol)
+}
+
+// runFilterProcess serves the filter protocol on r and w, supporting caps.
+// It uppercases smudged content and lowercases cleaned content, fails the
+// files named error.txt, late-error.txt and abort.txt, and delays those
+// whose name starts with delay- until their content is listed as available.
+func runFilterProcess(r io.Reader, w io.Writer, caps []string) int {
+	// The client side helpers read and write pkt-lines both ways.
+	srv := &Process{in: nopCloser{w}, out: bufio.NewReader(r)}
+
+	if _, err := srv.readList(); err != nil {
+		return 1
+	}
+	if err := srv.writeList("git-filter-server", "version=2"); err != nil {
+		return 1
+	}
+	wanted, err := srv.readList()
+	if err != nil {
+		return 1
+	}
+	var supported []string
+	for _, c := range caps {
+		if slices.Contains(wanted, "capability="+c) {
+			supported = append(supported, "capability="+c)
+		}
+	}
+	if err := srv.writeList(supported...); err != nil {
+		return 1
+	}
+
+	var delayed []string
+	available := make(map[string]string)
+	for {
+		header, err := srv.readList()
+		if errors.Is(err, io.EOF) {
+			return 0
+		}
+		if err != nil {
+			return 1
+		}
+
+		fields := make(map[string]string)
+		for _, line := range header {
+			k, v, _ := strings.Cut(line, "=")
+			fields[k] = v
+		}
+
+		if fields["command"] == "list_available_blobs" {
+			var paths []string
+			for _, path := range delayed {
+				paths = append(paths, "pathname="+path)
+			}
+			delayed = nil
+			if srv.writeList(paths...) != nil || srv.writeList("status=success") != nil {
+				return 1
+			}
+			continue
+		}
+
+		var content bytes.Buffer
+		if err := srv.readContent(&content); err != nil {
+			return 1
+		}
+
+		path := fields["pathname"]
+		if c, ok := available[path]; ok {
+			content.Reset()
+			content.WriteString(c)
+			delete(available, path)
+		}
+
+		filtered := content.String()
+		switch fields["command"] {
+		case Smudge:
+			filtered = strings.ToUpper(filtered)
+		case Clean:
+			filtered = strings.ToLower(filtered)
+		}
+
+		var werr error
+		switch {
+		case path == "error.txt":
+			werr = srv.writeList("status=error")
+		case path == "abort.txt":
+			werr = srv.writeList("status=abort")
+		case strings.HasPrefix(path, "delay-") && fields["can-delay"] == "1":
+			delayed = append(delayed, path)
+			available[path] = content.String()
+			werr = srv.writeList("status=delayed")
+		default:
+			status := []string{}
+			if path == "late-error.txt" {
+				status = append(status, "status=error")
+			}
+			werr = errors.Join(
+				srv.writeList("status=success"),
+				srv.writeContent(strings.NewReader(filtered)),
+				srv.writeList(status...),
+			)
+		}
+		if werr != nil {
+			return 1
+		}
+	}
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,343 @@
+// Package merge implements line oriented three-way merges, as
+// git merge-file does: the changes made to a common ancestor by two sides
+// are combined, and those that overlap are written between conflict
+// markers.
+package merge
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/go-git/go-git/v6/utils/diff"
+)
+
+// Style is the style the conflicts are written in, as merge.conflictStyle.
+type Style string
+
+const (
+	// StyleMerge writes the lines of both sides of a conflict, the lines
+	// they have in common being left out of it.
+	StyleMerge Style = "merge"
+	// StyleDiff3 writes the lines of the common ancestor along with those
+	// of both sides of a conflict.
+	StyleDiff3 Style = "diff3"
+	// StyleZDiff3 writes conflicts as StyleDiff3, the lines both sides
+	// have in common at the start and the end of a conflict being left
+	// out of it.
+	StyleZDiff3 Style = "zdiff3"
+)
+
+// DefaultMarkerSize is the length of the conflict markers.
+const DefaultMarkerSize = 7
+
+// Options describes how the conflicts of a merge are written.
+type Options struct {
+	// Style is the style the conflicts are written in, StyleMerge if empty.
+	Style Style
+	// OursLabel, BaseLabel and TheirsLabel are written after the conflict
+	// markers of our side, the common ancestor and their side.
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+	// MarkerSize is the length of the conflict markers, DefaultMarkerSize
+	// if zero.
+	MarkerSize int
+}
+
+// Merge merges the changes made to base by ours and theirs, and returns the
+// result, with conflict markers around the changes that overlap, and
+// whether there were any. If opts is nil, the default options are used.
+func Merge(base, ours, theirs []byte, opts *Options) ([]byte, bool) {
+	m := &merger{}
+	if opts != nil {
+		m.opts = *opts
+	}
+	if m.opts.MarkerSize <= 0 {
+		m.opts.MarkerSize = DefaultMarkerSize
+	}
+
+	baseLines := splitLines(string(base))
+	oursHunks := diffHunks(string(base), string(ours))
+	theirsHunks := diffHunks(string(base), string(theirs))
+
+	var pos, i, j int
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// The hunks of both sides overlapping, or touching, each other
+		// are merged together, as git does.
+		i0, j0 := i, j
+		var start, end int
+		if j == len(theirsHunks) || i < len(oursHunks) && oursHunks[i].start <= theirsHunks[j].start {
+			start, end = oursHunks[i].start, oursHunks[i].end
+			i++
+		} else {
+			start, end = theirsHunks[j].start, theirsHunks[j].end
+			j++
+		}
+
+		for {
+			if i < len(oursHunks) && oursHunks[i].start <= end {
+				end = max(end, oursHunks[i].end)
+				i++
+				continue
+			}
+			if j < len(theirsHunks) && theirsHunks[j].start <= end {
+				end = max(end, theirsHunks[j].end)
+				j++
+				continue
+			}
+			break
+		}
+
+		m.unchanged(baseLines[pos:start])
+		pos = end
+
+		o := applyHunks(baseLines, oursHunks[i0:i], start, end)
+		t := applyHunks(baseLines, theirsHunks[j0:j], start, end)
+		switch {
+		case i == i0:
+			m.changed(t)
+		case j == j0 || slices.Equal(o, t):
+			m.changed(o)
+		default:
+			m.conflict(baseLines[start:end], o, t)
+		}
+	}
+	m.unchanged(baseLines[pos:])
+
+	if m.opts.Style != StyleDiff3 && m.opts.Style != StyleZDiff3 {
+		m.simplifyConflicts()
+	}
+
+	return m.write(), m.conflicts
+}
+
+// hunk is a change of the lines start to end of the common ancestor into
+// lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// diffHunks returns the changes from base to other.
+func diffHunks(base, other string) []hunk {
+	var hunks []hunk
+	var pos int
+	var h *hunk
+	for _, d := range diff.Do(base, other) {
+		lines := splitLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			pos += len(lines)
+			h = nil
+			continue
+		}
+
+		if h == nil {
+			hunks = append(hunks, hunk{start: pos, end: pos})
+			h = &hunks[len(hunks)-1]
+		}
+
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			pos += len(lines)
+			h.end = pos
+		case diffmatchpatch.DiffInsert:
+			h.lines = append(h.lines, lines...)
+		}
+	}
+
+	return hunks
+}
+
+// applyHunks returns the lines start to end of base changed by hunks.
+func applyHunks(base []string, hunks []hunk, start, end int) []string {
+	var lines []string
+	pos := start
+	for _, h := range hunks {
+		lines = append(lines, base[pos:h.start]...)
+		lines = append(lines, h.lines...)
+		pos = h.end
+	}
+
+	return append(lines, base[pos:end]...)
+}
+
+type merger struct {
+	opts      Options
+	segments  []segment
+	conflicts bool
+}
+
+// segment is a part of the result of a merge: lines, or a conflict.
+type segment struct {
+	lines []string
+	// unchanged is whether lines are those of both sides, rather than
+	// the change of one of them.
+	unchanged bool
+
+	conflict           bool
+	base, ours, theirs []string
+}
+
+func (m *merger) unchanged(lines []string) {
+	if n := len(m.segments); n > 0 && m.segments[n-1].unchanged {
+		m.segments[n-1].lines = concatLines(m.segments[n-1].lines, lines)
+		return
+	}
+
+	if len(lines) > 0 {
+		m.segments = append(m.segments, segment{lines: lines, unchanged: true})
+	}
+}
+
+// changed adds the lines of a change, which, even if there are none, keeps
+// the conflicts around it apart.
+func (m *merger) changed(lines []string) {
+	m.segments = append(m.segments, segment{lines: lines})
+}
+
+// conflict adds the conflict of the lines base changed into ours and
+// theirs, in the style of the options.
+func (m *merger) conflict(base, ours, theirs []string) {
+	m.conflicts = true
+
+	switch m.opts.Style {
+	case StyleDiff3:
+		m.segments = append(m.segments, segment{conflict: true, base: base, ours: ours, theirs: theirs})
+	case StyleZDiff3:
+		prefix, suffix := commonLines(ours, theirs)
+		m.unchanged(ours[:prefix])
+		m.segments = append(m.segments, segment{
+			conflict: true,
+			base:     base,
+			ours:     ours[prefix : len(ours)-suffix],
+			theirs:   theirs[prefix : len(theirs)-suffix],
+		})
+		m.unchanged(ours[len(ours)-suffix:])
+	default:
+		m.refinedConflict(ours, theirs)
+	}
+}
+
+// refinedConflict adds the conflict of ours and theirs as git does in the
+// merge style: as a conflict for each of their differences, the lines they
+// have in common being left out of them.
+func (m *merger) refinedConflict(ours, theirs []string) {
+	if len(ours) == 0 || len(theirs) == 0 {
+		m.segments = append(m.segments, segment{conflict: true, ours: ours, theirs: theirs})
+		return
+	}
+
+	var pos int
+	for _, h := range diffHunks(strings.Join(ours, ""), strings.Join(theirs, "")) {
+		m.unchanged(ours[pos:h.start])
+		m.segments = append(m.segments, segment{conflict: true, ours: ours[h.start:h.end], theirs: h.lines})
+		pos = h.end
+	}
+	m.unchanged(ours[pos:])
+}
+
+// simplifyConflicts merges the conflicts only apart from each other by up
+// to three unchanged lines, as git does.
+func (m *merger) simplifyConflicts() {
+	segments := m.segments[:0]
+	for _, s := range m.segments {
+		n := len(segments)
+		if s.conflict && n >= 2 && segments[n-2].conflict && segments[n-1].unchanged && len(segments[n-1].lines) <= 3 {
+			c, gap := &segments[n-2], segments[n-1].lines
+			c.ours = concatLines(c.ours, gap, s.ours)
+			c.theirs = concatLines(c.theirs, gap, s.theirs)
+			segments = segments[:n-1]
+			continue
+		}
+		segments = append(segments, s)
+	}
+	m.segments = segments
+}
+
+// write returns the result of the merge, with conflict markers around its
+// conflicts.
+func (m *merger) write() []byte {
+	var buf bytes.Buffer
+	for _, s := range m.segments {
+		if !s.conflict {
+			writeLines(&buf, s.lines)
+			continue
+		}
+
+		m.marker(&buf, '<', m.opts.OursLabel)
+		writeConflictLines(&buf, s.ours)
+		if m.opts.Style == StyleDiff3 || m.opts.Style == StyleZDiff3 {
+			m.marker(&buf, '|', m.opts.BaseLabel)
+			writeConflictLines(&buf, s.base)
+		}
+		m.marker(&buf, '=', "")
+		writeConflictLines(&buf, s.theirs)
+		m.marker(&buf, '>', m.opts.TheirsLabel)
+	}
+
+	return buf.Bytes()
+}
+
+func (m *merger) marker(buf *bytes.Buffer, c byte, label string) {
+	buf.Write(bytes.Repeat([]byte{c}, m.opts.MarkerSize))
+	if label != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(label)
+	}
+	buf.WriteByte('\n')
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		buf.WriteString(l)
+	}
+}
+
+// writeConflictLines writes the lines of a side of a conflict, ending the
+// last one, as the marker that follows it has to start a line.
+func writeConflictLines(buf *bytes.Buffer, lines []string) {
+	writeLines(buf, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		buf.WriteByte('\n')
+	}
+}
+
+func concatLines(lines ...[]string) []string {
+	var all []string
+	for _, l := range lines {
+		all = append(all, l...)
+	}
+
+	return all
+}
+
+// commonLines returns the number of lines a and b have in common at their
+// start, and then at their end.
+func commonLines(a, b []string) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	return prefix, suffix
+}
+
+// splitLines splits s into its lines, with their line ending.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,84 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	const base = "a\nb\nc\nd\ne\n"
+	labels := Options{OursLabel: "ours", BaseLabel: "base", TheirsLabel: "theirs"}
+	withStyle := func(s Style) *Options {
+		opts := labels
+		opts.Style = s
+		return &opts
+	}
+
+	// The expected results are those of git merge-file -p.
+	tests := []struct {
+		name         string
+		ours, theirs string
+		opts         *Options
+		want         string
+		conflicts    bool
+	}{{
+		name:   "clean",
+		ours:   "a\nB\nc\nd\ne\n",
+		theirs: "a\nb\nc\nD\ne\n",
+		opts:   &labels,
+		want:   "a\nB\nc\nD\ne\n",
+	}, {
+		name:   "same change",
+		ours:   "a\nB\nc\nd\ne\n",
+		theirs: "a\nB\nc\nd\ne\n",
+		opts:   &labels,
+		want:   "a\nB\nc\nd\ne\n",
+	}, {
+		name:      "merge",
+		ours:      "a\nB\nX\nd\ne\n",
+		theirs:    "a\nB\nY\nd\nE\n",
+		opts:      withStyle(StyleMerge),
+		want:      "a\nB\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nd\nE\n",
+		conflicts: true,
+	}, {
+		name:      "diff3",
+		ours:      "a\nB\nX\nd\ne\n",
+		theirs:    "a\nB\nY\nd\nE\n",
+		opts:      withStyle(StyleDiff3),
+		want:      "a\n<<<<<<< ours\nB\nX\n||||||| base\nb\nc\n=======\nB\nY\n>>>>>>> theirs\nd\nE\n",
+		conflicts: true,
+	}, {
+		name:      "zdiff3",
+		ours:      "a\nB\nX\nd\ne\n",
+		theirs:    "a\nB\nY\nd\nE\n",
+		opts:      withStyle(StyleZDiff3),
+		want:      "a\nB\n<<<<<<< ours\nX\n||||||| base\nb\nc\n=======\nY\n>>>>>>> theirs\nd\nE\n",
+		conflicts: true,
+	}, {
+		name:      "no newline",
+		ours:      "a\nb\nc\nd\nX",
+		theirs:    "a\nb\nc\nd\nY",
+		want:      "a\nb\nc\nd\n<<<<<<<\nX\n=======\nY\n>>>>>>>\n",
+		conflicts: true,
+	}, {
+		name:      "marker size",
+		ours:      "X\n",
+		theirs:    "Y\n",
+		opts:      &Options{MarkerSize: 3},
+		want:      "<<<\nX\n===\nY\n>>>\n",
+		conflicts: true,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, conflicts := Merge([]byte(base), []byte(tc.ours), []byte(tc.theirs), tc.opts)
+			assert.Equal(t, tc.want, string(got))
+			assert.Equal(t, tc.conflicts, conflicts)
+		})
+	}
+}
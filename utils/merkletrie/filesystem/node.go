@@ -2,6 +2,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"io"
 	iofs "io/fs"
 	"os"
@@ -31,6 +32,17 @@ type Options struct {
 	// AutoCRLF converts CRLF line endings in text files into LF line endings.
 	AutoCRLF bool
 
+	// EOLConversion, if non-nil, returns the line ending conversion of the
+	// file at path, as resolved from its gitattributes. It takes precedence
+	// over AutoCRLF.
+	EOLConversion func(path string) convert.EOLConversion
+
+	// Clean, if non-nil, returns the content to hash of the file at path,
+	// read from r, and reports whether a clean filter applies to it. If it
+	// does not, the file content is hashed as is. The line ending
+	// conversion applies to the cleaned content.
+	Clean func(path string, r io.Reader) (content []byte, ok bool, err error)
+
 	// Index is used to enable the metadata-first comparison optimization while
 	// correctly handling the "racy git" condition. If no index is provided,
 	// the function works without the optimization.
@@ -52,6 +64,27 @@ type Options struct {
 	// Requires Index to be set: without an index there is no way to identify
 	// tracked entries, so the scope is treated as a no-op.
 	IgnoreScope *gitignore.Scope
+
+	// UntrackedCache, if non-nil, is used to skip listing the directories
+	// that did not change since they were last listed: their tracked
+	// entries are taken from the index, and their untracked files from the
+	// cache. The directories that are listed are recorded in it.
+	//
+	// A directory is taken as unchanged if its modification time and size
+	// are those recorded, its modification time is before the index
+	// ModTime, and its .gitignore has the recorded hash. A changed
+	// .gitignore invalidates the whole directory tree below it.
+	//
+	// Requires Index and IgnoreScope to be set, as the cache holds the
+	// untracked files that are not ignored, and only them.
+	UntrackedCache *index.UntrackedCache
+
+	// FSMonitor reports that a file system monitor vouches for the index
+	// entries whose FSMonitorValid field is set, and for the valid
+	// directories of UntrackedCache, so they are taken as unchanged without
+	// being checked. The caller is expected to have invalidated those the
+	// monitor reported as changed.
+	FSMonitor bool
 }
 
 // The node represents a file or a directory in a billy.Filesystem. It
@@ -69,9 +102,19 @@ type node struct {
 	// walker can keep tracked entries even if their parent directory
 	// matches an ignore rule.
 	trackedDirs map[string]struct{}
+	// tracked holds the names of the tracked files and directories of each
+	// directory. It is populated only when the untracked cache is used.
+	tracked map[string][]string
 
 	options *Options
 
+	// ucd is the untracked cache block of the directory, if the untracked
+	// cache is used. lazy reports that the node was created from the cache
+	// and the index, without its stat data, which are then taken on
+	// demand.
+	ucd  *index.UntrackedCacheDir
+	lazy bool
+
 	// scope is the ignore scope governing this node's entries. On a child it
 	// starts as the parent's scope and is replaced by this directory's own on
 	// the first calculateChildren, which is when the listing that reveals
@@ -142,7 +185,7 @@ func NewRootNodeWithOptions(
 		}
 	}
 
-	return &node{
+	root := &node{
 		fs:          fs,
 		submodules:  submodules,
 		idx:         options.Index,
@@ -155,6 +198,17 @@ func NewRootNodeWithOptions(
 		scope:         options.IgnoreScope,
 		scopeResolved: true,
 	}
+
+	if uc := options.UntrackedCache; uc != nil && options.Index != nil && options.IgnoreScope != nil {
+		if uc.Root == nil {
+			uc.Root = &index.UntrackedCacheDir{}
+		}
+		root.ucd = uc.Root
+		root.lazy = true
+		root.tracked = trackedChildren(options.Index)
+	}
+
+	return root
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -211,6 +265,17 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
+	if n.ucd != nil {
+		if ok, err := n.calculateCachedChildren(); ok || err != nil {
+			return err
+		}
+	}
+
+	// The stat data of the directory are taken before it is listed, so
+	// those recorded in the untracked cache are never newer than its
+	// listing.
+	statted := n.stat()
+
 	files, err := n.fs.ReadDir(n.path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -219,7 +284,7 @@ func (n *node) calculateChildren() error {
 		return err
 	}
 
-	if err := n.resolveScope(files); err != nil {
+	if err := n.resolveScope(hasIgnoreFile(files)); err != nil {
 		return err
 	}
 
@@ -248,15 +313,29 @@ func (n *node) calculateChildren() error {
 		n.children = append(n.children, c)
 	}
 
+	if n.ucd != nil {
+		return n.recordUntracked(statted)
+	}
+
 	return nil
 }
 
+func hasIgnoreFile(files []iofs.DirEntry) bool {
+	for _, f := range files {
+		if f.Name() == gitignore.IgnoreFile && !f.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
 // resolveScope derives this directory's ignore scope from the listing just
-// taken for it. Deferring to this point is the whole benefit of the scoped
+// taken for it, or from its untracked cache block, which tell whether it has
+// a .gitignore. Deferring to this point is the whole benefit of the scoped
 // walk: whether a .gitignore exists is read off a listing the walk needed
 // anyway, the file is opened only in directories actually visited, and
 // Scope.Descend declines to open it at all below an excluded directory.
-func (n *node) resolveScope(files []iofs.DirEntry) error {
+func (n *node) resolveScope(hasIgnoreFile bool) error {
 	if n.scopeResolved {
 		return nil
 	}
@@ -267,13 +346,10 @@ func (n *node) resolveScope(files []iofs.DirEntry) error {
 	}
 
 	var readOwn func() ([]gitignore.Pattern, error)
-	for _, f := range files {
-		if f.Name() == gitignore.IgnoreFile && !f.IsDir() {
-			dir := n.pathComponents()
-			readOwn = func() ([]gitignore.Pattern, error) {
-				return gitignore.DirPatterns(n.fs, dir)
-			}
-			break
+	if hasIgnoreFile {
+		dir := n.pathComponents()
+		readOwn = func() ([]gitignore.Pattern, error) {
+			return gitignore.DirPatterns(n.fs, dir)
 		}
 	}
 
@@ -334,6 +410,7 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 		idx:         n.idx,
 		idxMap:      n.idxMap,
 		trackedDirs: n.trackedDirs,
+		tracked:     n.tracked,
 		options:     n.options,
 
 		// The child inherits this directory's scope and resolves its own on
@@ -359,6 +436,10 @@ func (n *node) calculateHash() {
 		n.hash = make([]byte, 24)
 		return
 	}
+	if !n.stat() {
+		n.hash = plumbing.ZeroHash.Bytes()
+		return
+	}
 	mode, err := filemode.NewFromOSFileMode(n.mode)
 	if err != nil {
 		n.hash = plumbing.ZeroHash.Bytes()
@@ -392,6 +473,10 @@ func (n *node) metadataMatches(entry *index.Entry) bool {
 		return false
 	}
 
+	if n.options != nil && n.options.FSMonitor && entry.FSMonitorValid {
+		return true
+	}
+
 	if uint32(n.size) != entry.Size {
 		return false
 	}
@@ -432,11 +517,24 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 	}
 	defer func() { _ = f.Close() }()
 
-	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, n.size)
+	var src io.ReadSeeker = f
+	size := n.size
+	if n.options != nil && n.options.Clean != nil {
+		content, ok, err := n.options.Clean(n.path, f)
+		if err != nil {
+			return plumbing.ZeroHash
+		}
+		if ok {
+			src = bytes.NewReader(content)
+			size = int64(len(content))
+		}
+	}
+
+	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, size)
 	var dst io.Writer = h
 
-	if n.options != nil && n.options.AutoCRLF {
-		br := sync.GetBufioReader(f)
+	if conv := n.eolConversion(); conv != convert.EOLNone {
+		br := sync.GetBufioReader(src)
 		defer sync.PutBufioReader(br)
 
 		stat, err := convert.GetStat(br)
@@ -444,23 +542,37 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 			return plumbing.ZeroHash
 		}
 
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
 			return plumbing.ZeroHash
 		}
 
-		if !stat.IsBinary() {
-			h.Reset(plumbing.BlobObject, n.size-int64(stat.CRLF))
+		if conv.ToGit(stat) {
+			h.Reset(plumbing.BlobObject, size-int64(stat.CRLF))
 			dst = convert.NewLFWriter(dst)
 		}
 	}
 
-	if _, err := ioutil.CopyBufferPool(dst, f); err != nil {
+	if _, err := ioutil.CopyBufferPool(dst, src); err != nil {
 		return plumbing.ZeroHash
 	}
 
 	return h.Sum()
 }
 
+// eolConversion returns the line ending conversion of the file of n.
+func (n *node) eolConversion() convert.EOLConversion {
+	switch {
+	case n.options == nil:
+		return convert.EOLNone
+	case n.options.EOLConversion != nil:
+		return n.options.EOLConversion(n.path)
+	case n.options.AutoCRLF:
+		return convert.EOLAutoInput
+	default:
+		return convert.EOLNone
+	}
+}
+
 func (n *node) doCalculateHashForSymlink() plumbing.Hash {
 	target, err := n.fs.Readlink(n.path)
 	if err != nil {
@@ -0,0 +1,212 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+// untrackedCacheFixture writes files into a new worktree, dates them and
+// their directories an hour ago, and returns the worktree with an index
+// tracking tracked, written now.
+func untrackedCacheFixture(t *testing.T, files map[string]string, tracked ...string) (billy.Filesystem, *index.Index) {
+	t.Helper()
+
+	dir := t.TempDir()
+	fs := osfs.New(dir, osfs.WithBoundOS())
+	for name, content := range files {
+		require.NoError(t, WriteFile(fs, name, []byte(content), 0o644))
+	}
+
+	idx := &index.Index{Version: 2}
+	for _, name := range tracked {
+		idx.Entries = append(idx.Entries, &index.Entry{
+			Name: name,
+			Hash: blobHash(t, []byte(files[name])),
+			Mode: filemode.Regular,
+			Size: uint32(len(files[name])),
+		})
+	}
+
+	backdate(t, dir)
+	idx.ModTime = time.Now()
+
+	return fs, idx
+}
+
+// backdate sets the modification time of every file and directory below
+// dir, dir included, to an hour ago.
+func backdate(t *testing.T, dir string) {
+	t.Helper()
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, filepath.Walk(dir, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, past, past)
+	}))
+}
+
+func untrackedCacheWalk(t *testing.T, fs billy.Filesystem, idx *index.Index, uc *index.UntrackedCache, path ...string) []string {
+	t.Helper()
+
+	root := NewRootNodeWithOptions(fs, nil, Options{
+		Index:          idx,
+		IgnoreScope:    scope(),
+		UntrackedCache: uc,
+	})
+
+	return childNames(t, root, path...)
+}
+
+// TestUntrackedCacheRecordsDirectories verifies that a walk records the
+// untracked files of the directories it lists, and the stat data the next
+// walk checks them against.
+func TestUntrackedCacheRecordsDirectories(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"tracked.txt":   "tracked\n",
+		"untracked.txt": "untracked\n",
+		"src/main.go":   "package main\n",
+		"src/new.go":    "package main\n",
+	}, "tracked.txt", "src/main.go")
+
+	uc := &index.UntrackedCache{}
+	untrackedCacheWalk(t, fs, idx, uc, "src")
+
+	require.NotNil(t, uc.Root)
+	require.True(t, uc.Root.Valid)
+	require.Equal(t, []string{"untracked.txt"}, uc.Root.Untracked)
+	require.False(t, uc.Root.Stats.ModifiedAt.IsZero())
+
+	src := uc.Root.Dir("src")
+	require.NotNil(t, src)
+	require.True(t, src.Valid)
+	require.Equal(t, []string{"new.go"}, src.Untracked)
+}
+
+// TestUntrackedCacheSkipsUnchangedDirectories verifies that a directory
+// whose stat data did not change is not listed again: a file removed
+// behind the back of the cache, with the directory time restored, is still
+// reported.
+func TestUntrackedCacheSkipsUnchangedDirectories(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"tracked.txt":   "tracked\n",
+		"untracked.txt": "untracked\n",
+	}, "tracked.txt")
+
+	uc := &index.UntrackedCache{}
+	require.ElementsMatch(t, []string{"tracked.txt", "untracked.txt"}, untrackedCacheWalk(t, fs, idx, uc))
+
+	root := fs.Root()
+	fi, err := os.Stat(root)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(root, "untracked.txt")))
+	require.NoError(t, os.Chtimes(root, fi.ModTime(), fi.ModTime()))
+
+	require.ElementsMatch(t, []string{"tracked.txt", "untracked.txt"}, untrackedCacheWalk(t, fs, idx, uc),
+		"an unchanged directory is taken from the cache")
+}
+
+// TestUntrackedCacheListsChangedDirectories verifies that a directory whose
+// modification time changed is listed again, and its block updated.
+func TestUntrackedCacheListsChangedDirectories(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"tracked.txt": "tracked\n",
+		"src/main.go": "package main\n",
+	}, "tracked.txt", "src/main.go")
+
+	uc := &index.UntrackedCache{}
+	require.Equal(t, []string{"main.go"}, untrackedCacheWalk(t, fs, idx, uc, "src"))
+
+	require.NoError(t, WriteFile(fs, "src/new.go", []byte("package main\n"), 0o644))
+	backdate(t, filepath.Join(fs.Root(), "src"))
+	require.NoError(t, os.Chtimes(filepath.Join(fs.Root(), "src"), time.Now().Add(-time.Minute), time.Now().Add(-time.Minute)))
+
+	require.ElementsMatch(t, []string{"main.go", "new.go"}, untrackedCacheWalk(t, fs, idx, uc, "src"))
+	require.Equal(t, []string{"new.go"}, uc.Root.Dir("src").Untracked)
+}
+
+// TestUntrackedCacheIgnoreFileChange verifies that a changed .gitignore
+// invalidates the blocks of its directory tree, even if the directories
+// kept their stat data.
+func TestUntrackedCacheIgnoreFileChange(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"src/.gitignore":   "*.log\n",
+		"src/main.go":      "package main\n",
+		"src/debug.go":     "package main\n",
+		"src/pkg/run.log":  "log\n",
+		"src/pkg/debug.go": "package pkg\n",
+	}, "src/.gitignore", "src/main.go")
+
+	uc := &index.UntrackedCache{}
+	require.ElementsMatch(t, []string{"debug.go"}, untrackedCacheWalk(t, fs, idx, uc, "src", "pkg"))
+
+	src := filepath.Join(fs.Root(), "src")
+	fi, err := os.Stat(src)
+	require.NoError(t, err)
+	require.NoError(t, WriteFile(fs, "src/.gitignore", []byte("debug.go\n"), 0o644))
+	require.NoError(t, os.Chtimes(src, fi.ModTime(), fi.ModTime()))
+
+	require.ElementsMatch(t, []string{".gitignore", "main.go", "pkg"}, untrackedCacheWalk(t, fs, idx, uc, "src"))
+	require.ElementsMatch(t, []string{"run.log"}, untrackedCacheWalk(t, fs, idx, uc, "src", "pkg"),
+		"the rules of the new .gitignore apply below it")
+}
+
+// TestUntrackedCacheRacyDirectory verifies that a directory modified as
+// the index was written is listed again, as later changes could keep its
+// stat data.
+func TestUntrackedCacheRacyDirectory(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"tracked.txt":   "tracked\n",
+		"untracked.txt": "untracked\n",
+	}, "tracked.txt")
+
+	uc := &index.UntrackedCache{}
+	untrackedCacheWalk(t, fs, idx, uc)
+
+	root := fs.Root()
+	fi, err := os.Stat(root)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(root, "untracked.txt")))
+	require.NoError(t, os.Chtimes(root, fi.ModTime(), fi.ModTime()))
+	idx.ModTime = fi.ModTime()
+
+	require.Equal(t, []string{"tracked.txt"}, untrackedCacheWalk(t, fs, idx, uc))
+}
+
+// TestUntrackedCacheInvalidatedByIndex verifies that a file added to the
+// index is no longer reported from the cache as untracked.
+func TestUntrackedCacheInvalidatedByIndex(t *testing.T) {
+	t.Parallel()
+	fs, idx := untrackedCacheFixture(t, map[string]string{
+		"tracked.txt":   "tracked\n",
+		"untracked.txt": "untracked\n",
+	}, "tracked.txt")
+
+	uc := &index.UntrackedCache{}
+	idx.UntrackedCache = uc
+	untrackedCacheWalk(t, fs, idx, uc)
+
+	e, err := idx.Add("untracked.txt")
+	require.NoError(t, err)
+	e.Hash = blobHash(t, []byte("untracked\n"))
+	e.Mode = filemode.Regular
+	require.False(t, uc.Root.Valid)
+
+	require.ElementsMatch(t, []string{"tracked.txt", "untracked.txt"}, untrackedCacheWalk(t, fs, idx, uc))
+	require.Empty(t, uc.Root.Untracked)
+}
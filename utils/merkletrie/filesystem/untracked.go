@@ -0,0 +1,295 @@
+package filesystem
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v6/util"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+// trackedChildren returns the names of the tracked files and directories of
+// each directory of idx, the top one being "". Entries skipped in the
+// worktree are left out, as the walk does not see them as tracked.
+func trackedChildren(idx *index.Index) map[string][]string {
+	tracked := make(map[string][]string)
+	seen := make(map[string]struct{})
+	add := func(p string) bool {
+		if _, ok := seen[p]; ok {
+			return false
+		}
+		seen[p] = struct{}{}
+
+		dir, name := path.Split(p)
+		dir = strings.TrimSuffix(dir, "/")
+		tracked[dir] = append(tracked[dir], name)
+		return true
+	}
+
+	for _, e := range idx.Entries {
+		if e.SkipWorktree || !add(e.Name) {
+			continue
+		}
+
+		for dir := path.Dir(e.Name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if !add(dir) {
+				break
+			}
+		}
+	}
+
+	return tracked
+}
+
+// isTracked reports whether the file at p is tracked, as the untracked
+// cache sees it.
+func (n *node) isTracked(p string) bool {
+	e, ok := n.idxMap[p]
+	return ok && !e.SkipWorktree
+}
+
+// stat takes the stat data of n if it was created without them, and reports
+// whether n has them.
+func (n *node) stat() bool {
+	if !n.lazy {
+		return true
+	}
+
+	p := n.path
+	if p == "" {
+		p = "."
+	}
+
+	fi, err := n.fs.Lstat(p)
+	if err != nil {
+		return false
+	}
+
+	n.lazy = false
+	n.mode = fi.Mode()
+	n.size = fi.Size()
+	n.modTime = fi.ModTime()
+
+	return true
+}
+
+// calculateCachedChildren sets the children of n from its untracked cache
+// block and the index, and reports whether it could, that is whether the
+// directory did not change since the block was recorded.
+func (n *node) calculateCachedChildren() (bool, error) {
+	d := n.ucd
+	if !d.Valid {
+		return false, nil
+	}
+
+	if !n.options.FSMonitor {
+		if !n.stat() || !n.unchangedSince(d) {
+			return false, nil
+		}
+
+		h, err := n.ignoreFileHash()
+		if err != nil {
+			return false, err
+		}
+
+		// The .gitignore rules apply to the whole tree below the
+		// directory.
+		if !sameHash(h, d.ExcludeHash) {
+			invalidateTree(d)
+			return false, nil
+		}
+	}
+
+	if err := n.resolveScope(!d.ExcludeHash.IsZero()); err != nil {
+		return false, err
+	}
+
+	dirs := make(map[string]*index.UntrackedCacheDir, len(d.Dirs))
+	for _, sub := range d.Dirs {
+		dirs[sub.Name] = sub
+	}
+
+	for _, name := range n.tracked[n.path] {
+		p := path.Join(n.path, name)
+		if !n.isTracked(p) {
+			sub, ok := dirs[name]
+			if !ok {
+				sub = &index.UntrackedCacheDir{Name: name}
+				d.Dirs = append(d.Dirs, sub)
+			}
+			delete(dirs, name)
+			n.children = append(n.children, n.cachedChild(name, sub))
+			continue
+		}
+
+		c, err := n.trackedChild(p)
+		if err != nil {
+			return false, err
+		}
+		if c != nil {
+			n.children = append(n.children, c)
+		}
+	}
+
+	for _, name := range d.Untracked {
+		if !n.isTracked(path.Join(n.path, name)) {
+			n.children = append(n.children, n.cachedChild(name, nil))
+		}
+	}
+
+	for _, sub := range d.Dirs {
+		if _, ok := dirs[sub.Name]; ok {
+			n.children = append(n.children, n.cachedChild(sub.Name, sub))
+		}
+	}
+
+	return true, nil
+}
+
+// unchangedSince reports whether the directory of n has the stat data
+// recorded in d, and was not modified as the index was written, in which
+// case later changes could have the same stat data.
+func (n *node) unchangedSince(d *index.UntrackedCacheDir) bool {
+	if n.idx == nil || n.idx.ModTime.IsZero() || !n.modTime.Before(n.idx.ModTime) {
+		return false
+	}
+
+	return n.modTime.Equal(d.Stats.ModifiedAt) && uint32(n.size) == d.Stats.Size
+}
+
+// cachedChild returns the child of n named name, created without its stat
+// data. It is a directory whose untracked cache block is d if d is not nil,
+// and a file otherwise.
+func (n *node) cachedChild(name string, d *index.UntrackedCacheDir) *node {
+	return &node{
+		fs:          n.fs,
+		submodules:  n.submodules,
+		idx:         n.idx,
+		idxMap:      n.idxMap,
+		trackedDirs: n.trackedDirs,
+		tracked:     n.tracked,
+		options:     n.options,
+		scope:       n.scope,
+		ucd:         d,
+		lazy:        true,
+
+		path:  path.Join(n.path, name),
+		isDir: d != nil,
+	}
+}
+
+// trackedChild returns the child of n for the tracked file at p, or nil if
+// it does not exist in the worktree. The stat data of the files the file
+// system monitor vouches for are taken from the index.
+func (n *node) trackedChild(p string) (*node, error) {
+	e := n.idxMap[p]
+	if n.options.FSMonitor && e.FSMonitorValid && e.Mode != filemode.Submodule {
+		if mode, err := e.Mode.ToOSFileMode(); err == nil {
+			c := n.cachedChild(path.Base(p), nil)
+			c.lazy = false
+			c.mode = mode
+			c.size = int64(e.Size)
+			c.modTime = e.ModifiedAt
+			return c, nil
+		}
+	}
+
+	fi, err := n.fs.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if fi.Mode()&os.ModeSocket != 0 {
+		return nil, nil
+	}
+
+	return n.newChildNode(fi)
+}
+
+// recordUntracked records the children of n, just listed, in its untracked
+// cache block. The block is valid if the stat data of the directory were
+// taken before it was listed.
+func (n *node) recordUntracked(statted bool) error {
+	d := n.ucd
+
+	h, err := n.ignoreFileHash()
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[string]*index.UntrackedCacheDir, len(d.Dirs))
+	for _, sub := range d.Dirs {
+		dirs[sub.Name] = sub
+	}
+
+	d.Untracked = nil
+	d.Dirs = nil
+	for _, child := range n.children {
+		c := child.(*node)
+		name := path.Base(c.path)
+
+		if c.isDir {
+			sub, ok := dirs[name]
+			if !ok {
+				sub = &index.UntrackedCacheDir{Name: name}
+			}
+			c.ucd = sub
+			d.Dirs = append(d.Dirs, sub)
+			continue
+		}
+
+		if !n.isTracked(c.path) {
+			d.Untracked = append(d.Untracked, name)
+		}
+	}
+
+	d.CheckOnly = false
+	d.ExcludeHash = h
+	d.Valid = statted
+	d.Stats = index.StatData{}
+	if statted {
+		d.Stats = index.StatData{ModifiedAt: n.modTime, Size: uint32(n.size)}
+	}
+
+	return nil
+}
+
+// ignoreFileHash returns the hash of the .gitignore of the directory of n,
+// or the zero hash if it has none that can be read.
+func (n *node) ignoreFileHash() (plumbing.Hash, error) {
+	content, err := util.ReadFile(n.fs, path.Join(n.path, gitignore.IgnoreFile))
+	if err != nil {
+		return plumbing.ZeroHash, nil
+	}
+
+	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, int64(len(content)))
+	if _, err := h.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.Sum(), nil
+}
+
+func sameHash(a, b plumbing.Hash) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() == b.IsZero()
+	}
+	return a.Equal(b)
+}
+
+// invalidateTree invalidates d and the blocks below it.
+func invalidateTree(d *index.UntrackedCacheDir) {
+	d.Valid = false
+	d.Untracked = nil
+	for _, sub := range d.Dirs {
+		invalidateTree(sub)
+	}
+}
@@ -22,6 +22,9 @@ type node struct {
 	children []noder.Noder
 	isDir    bool
 	skip     bool
+	// expand returns the children of a sparse directory, read only when
+	// they are needed.
+	expand func() ([]noder.Noder, error)
 
 	upholdExecutableBit bool
 }
@@ -29,6 +32,15 @@ type node struct {
 // RootNodeOptions contains configuration for the root node.
 type RootNodeOptions struct {
 	UpholdExecutableBit bool
+	// IgnoreSkipWorktree, if true, the entries with SkipWorktree set are
+	// compared as the others, as when the index is compared with a tree
+	// rather than the worktree. Sparse directory entries are still skipped,
+	// unless SparseDirChildren is set.
+	IgnoreSkipWorktree bool
+	// SparseDirChildren, if set along with IgnoreSkipWorktree, returns the
+	// nodes of the tree of a sparse directory entry: the entry is compared
+	// by the hash of its tree, and only expanded into them if it differs.
+	SparseDirChildren func(e *index.Entry) ([]noder.Noder, error)
 }
 
 // NewRootNode returns the root node of a computed tree from a index.Index,
@@ -44,6 +56,8 @@ func NewRootNodeWithOptions(idx *index.Index, options RootNodeOptions) noder.Nod
 
 	for _, e := range idx.Entries {
 		parts := strings.Split(e.Name, string("/"))
+		expandSparseDir := e.IsSparseDir() && options.IgnoreSkipWorktree && options.SparseDirChildren != nil
+		skip := e.SkipWorktree && (!options.IgnoreSkipWorktree || e.IsSparseDir() && !expandSparseDir)
 
 		var fullpath string
 		for _, part := range parts {
@@ -56,16 +70,20 @@ func NewRootNodeWithOptions(idx *index.Index, options RootNodeOptions) noder.Nod
 			// of the tree needs to have this value set to false so that subdirectories
 			// are not ignored.
 			if parentNode, ok := m[fullpath]; ok {
-				if !e.SkipWorktree {
+				if !skip {
 					parentNode.skip = false
 				}
 				continue
 			}
 
-			n := &node{path: fullpath, skip: e.SkipWorktree, upholdExecutableBit: options.UpholdExecutableBit}
-			if fullpath == e.Name {
+			n := &node{path: fullpath, skip: skip, upholdExecutableBit: options.UpholdExecutableBit}
+			switch {
+			case fullpath == e.Name:
 				n.entry = e
-			} else {
+			case expandSparseDir && fullpath+"/" == e.Name:
+				n.entry, n.isDir = e, true
+				n.expand = func() ([]noder.Noder, error) { return options.SparseDirChildren(e) }
+			default:
 				n.isDir = true
 			}
 
@@ -114,9 +132,23 @@ func (n *node) IsDir() bool {
 }
 
 func (n *node) Children() ([]noder.Noder, error) {
+	if n.expand != nil {
+		children, err := n.expand()
+		if err != nil {
+			return nil, err
+		}
+		n.children, n.expand = children, nil
+	}
+
 	return n.children, nil
 }
 
 func (n *node) NumChildren() (int, error) {
+	// The tree of a sparse directory is not empty; it is only read as its
+	// children are needed.
+	if n.expand != nil {
+		return 1, nil
+	}
+
 	return len(n.children), nil
 }
@@ -93,6 +93,74 @@ func (s *NoderSuite) TestDiffSkipIssue1455() {
 	s.Equal(a, merkletrie.Insert)
 }
 
+func (s *NoderSuite) TestDiffIgnoreSkipWorktree() {
+	indexA := &index.Index{
+		Entries: []*index.Entry{{
+			Name:         path.Join("bar", "baz"),
+			Hash:         plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
+			SkipWorktree: true,
+		}},
+	}
+
+	indexB := &index.Index{}
+
+	ch, err := merkletrie.DiffTree(NewRootNode(indexB), NewRootNode(indexA), isEquals)
+	s.NoError(err)
+	s.Len(ch, 0)
+
+	opts := RootNodeOptions{UpholdExecutableBit: true, IgnoreSkipWorktree: true}
+	ch, err = merkletrie.DiffTree(NewRootNode(indexB), NewRootNodeWithOptions(indexA, opts), isEquals)
+	s.NoError(err)
+	s.Len(ch, 1)
+	a, err := ch[0].Action()
+	s.NoError(err)
+	s.Equal(merkletrie.Insert, a)
+}
+
+func (s *NoderSuite) TestDiffSparseDir() {
+	sparseDir := func(h string) *index.Index {
+		return &index.Index{Entries: []*index.Entry{{
+			Name:         "bar/",
+			Hash:         plumbing.NewHash(h),
+			Mode:         filemode.Dir,
+			SkipWorktree: true,
+		}}}
+	}
+	trees := map[plumbing.Hash]*index.Index{
+		plumbing.NewHash("1111111111111111111111111111111111111111"): {Entries: []*index.Entry{
+			{Name: "baz", Hash: plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d"), Mode: filemode.Regular},
+		}},
+		plumbing.NewHash("2222222222222222222222222222222222222222"): {Entries: []*index.Entry{
+			{Name: "baz", Hash: plumbing.NewHash("aab686eafeb1f44702738c8b0f24f2567c36da6d"), Mode: filemode.Regular},
+		}},
+	}
+
+	var expanded int
+	opts := RootNodeOptions{
+		UpholdExecutableBit: true,
+		IgnoreSkipWorktree:  true,
+		SparseDirChildren: func(e *index.Entry) ([]noder.Noder, error) {
+			expanded++
+			return NewRootNode(trees[e.Hash]).Children()
+		},
+	}
+
+	from := NewRootNodeWithOptions(sparseDir("1111111111111111111111111111111111111111"), opts)
+	ch, err := merkletrie.DiffTree(from, NewRootNodeWithOptions(sparseDir("1111111111111111111111111111111111111111"), opts), isEquals)
+	s.NoError(err)
+	s.Len(ch, 0)
+	s.Equal(0, expanded)
+
+	from = NewRootNodeWithOptions(sparseDir("1111111111111111111111111111111111111111"), opts)
+	ch, err = merkletrie.DiffTree(from, NewRootNodeWithOptions(sparseDir("2222222222222222222222222222222222222222"), opts), isEquals)
+	s.NoError(err)
+	s.Require().Len(ch, 1)
+	a, err := ch[0].Action()
+	s.NoError(err)
+	s.Equal(merkletrie.Modify, a)
+	s.Equal("bar/baz", ch[0].To.String())
+}
+
 func (s *NoderSuite) TestDiffDir() {
 	indexA := &index.Index{
 		Entries: []*index.Entry{{
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -420,7 +421,7 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 
 	var removedFiles []string
 	if opts.Mode == MixedReset || opts.Mode == MergeReset || opts.Mode == HardReset || opts.Mode == KeepReset {
-		if removedFiles, err = w.resetIndex(t, opts.SparseDirs, opts.Files); err != nil {
+		if removedFiles, err = w.resetIndex(cfg, t, opts.SparseDirs, opts.Files); err != nil {
 			return err
 		}
 	}
@@ -495,12 +496,28 @@ func (w *Worktree) Restore(o *RestoreOptions) error {
 	return ErrRestoreWorktreeOnlyNotSupported
 }
 
-func (w *Worktree) resetIndex(t *object.Tree, dirs, files []string) ([]string, error) {
+func (w *Worktree) resetIndex(cfg *config.Config, t *object.Tree, dirs, files []string) ([]string, error) {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return nil, err
 	}
 
+	// The files reset below sparse directories need their entries; the
+	// sparse directories are otherwise updated as a whole, by
+	// updateSparseDirs.
+	var sparseDirs []string
+	if len(files) > 0 {
+		if err := w.expandSparseDirsFor(idx, files...); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, e := range idx.Entries {
+			if e.IsSparseDir() {
+				sparseDirs = append(sparseDirs, e.Name)
+			}
+		}
+	}
+
 	b := newIndexBuilder(idx)
 
 	changes, err := w.diffTreeWithStaging(t, true)
@@ -508,6 +525,15 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs, files []string) ([]string, e
 		return nil, err
 	}
 
+	// Without sparse directories, the files changed are checked out as the
+	// sparse-checkout patterns, as git sparse-checkout set them, ask.
+	var patterns *sparsePatterns
+	if len(dirs) == 0 {
+		if patterns, err = w.sparseCheckoutPatterns(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	removedFiles := make([]string, 0, len(changes))
 	filesMap := buildFilePathMap(files)
 	for _, ch := range changes {
@@ -537,6 +563,15 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs, files []string) ([]string, e
 			}
 		}
 
+		if slices.ContainsFunc(sparseDirs, func(dir string) bool { return strings.HasPrefix(name, dir) }) {
+			continue
+		}
+
+		skip := !patterns.includes(name)
+		if old, ok := b.entries[name]; ok && patterns == nil {
+			skip = old.SkipWorktree
+		}
+
 		b.Remove(name)
 		removedFiles = append(removedFiles, name)
 		if e == nil {
@@ -544,18 +579,29 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs, files []string) ([]string, e
 		}
 
 		b.Add(&index.Entry{
-			Name: name,
-			Hash: e.Hash,
-			Mode: e.Mode,
+			Name:         name,
+			Hash:         e.Hash,
+			Mode:         e.Mode,
+			SkipWorktree: skip,
 		})
 	}
 
 	b.Write(idx)
 
+	if len(files) == 0 {
+		if err := w.updateSparseDirs(idx, t, dirs); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(dirs) > 0 {
 		idx.SkipUnless(dirs)
 	}
 
+	if err := w.applySparseIndex(cfg, idx); err != nil {
+		return nil, err
+	}
+
 	return removedFiles, w.r.Storer.SetIndex(idx)
 }
 
@@ -760,6 +806,13 @@ func (w *Worktree) resetWorktreeToTree(cfg *config.Config, fromTree, toTree *obj
 		return err
 	}
 	b := newIndexBuilder(idx)
+	attrs := w.checkoutAttributes(cfg, toTree)
+	defer attrs.close()
+	attrs.filters.delay = true
+
+	if err := w.prefetchChanges(worktreeChanges, idx, filesMap); err != nil {
+		return err
+	}
 
 	for _, ch := range worktreeChanges {
 		a, err := ch.Action()
@@ -782,17 +835,21 @@ func (w *Worktree) resetWorktreeToTree(cfg *config.Config, fromTree, toTree *obj
 			}
 		}
 
-		if err := w.checkoutChange(cfg, fs, ch, toTree, b); err != nil {
+		if err := w.checkoutChange(attrs, fs, ch, toTree, b); err != nil {
 			return err
 		}
 	}
 
+	if err := w.finishDelayedCheckout(attrs, fs, toTree, b); err != nil {
+		return err
+	}
+
 	// Step 3: remove tracked files that are SkipWorktree=true from disk.
 	// diffStagingWithWorktree builds the index node tree with skip=true for
 	// SkipWorktree entries, so they never appear as Delete actions in step 2.
 	// git removes these files when the sparse-checkout contract excludes them.
 	for _, e := range idx.Entries {
-		if !e.SkipWorktree {
+		if !e.SkipWorktree || e.IsSparseDir() {
 			continue
 		}
 		if len(files) > 0 && !inFiles(filesMap, e.Name) {
@@ -807,6 +864,12 @@ func (w *Worktree) resetWorktreeToTree(cfg *config.Config, fromTree, toTree *obj
 	}
 
 	b.Write(idx)
+
+	// The directories whose files were removed above can now be collapsed.
+	if err := w.applySparseIndex(cfg, idx); err != nil {
+		return err
+	}
+
 	return w.r.Storer.SetIndex(idx)
 }
 
@@ -830,11 +893,18 @@ func (w *Worktree) resetWorktree(cfg *config.Config, t *object.Tree, files []str
 		return err
 	}
 	b := newIndexBuilder(idx)
+	attrs := w.checkoutAttributes(cfg, t)
+	defer attrs.close()
+	attrs.filters.delay = true
 
 	fs, closeFS := w.reusableRootFS()
 	defer closeFS()
 
 	filesMap := buildFilePathMap(files)
+	if err := w.prefetchChanges(changes, idx, filesMap); err != nil {
+		return err
+	}
+
 	for _, ch := range changes {
 		if len(files) > 0 {
 			file := ""
@@ -854,16 +924,20 @@ func (w *Worktree) resetWorktree(cfg *config.Config, t *object.Tree, files []str
 			}
 		}
 
-		if err := w.checkoutChange(cfg, fs, ch, t, b); err != nil {
+		if err := w.checkoutChange(attrs, fs, ch, t, b); err != nil {
 			return err
 		}
 	}
 
+	if err := w.finishDelayedCheckout(attrs, fs, t, b); err != nil {
+		return err
+	}
+
 	b.Write(idx)
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(cfg *config.Config, fs *worktreeFilesystem, ch merkletrie.Change, t *object.Tree, idx *indexBuilder) error {
+func (w *Worktree) checkoutChange(attrs *attributes, fs *worktreeFilesystem, ch merkletrie.Change, t *object.Tree, idx *indexBuilder) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -901,7 +975,7 @@ func (w *Worktree) checkoutChange(cfg *config.Config, fs *worktreeFilesystem, ch
 		return w.checkoutChangeSubmodule(fs, name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(cfg, fs, name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(attrs, fs, name, a, t, e, idx)
 }
 
 func (w *Worktree) containsUnstagedChanges(cfg *config.Config) (bool, error) {
@@ -988,7 +1062,7 @@ func (w *Worktree) checkoutChangeSubmodule(fs *worktreeFilesystem,
 	return nil
 }
 
-func (w *Worktree) checkoutChangeRegularFile(cfg *config.Config,
+func (w *Worktree) checkoutChangeRegularFile(attrs *attributes,
 	fs *worktreeFilesystem,
 	name string,
 	a merkletrie.Action,
@@ -1013,7 +1087,12 @@ func (w *Worktree) checkoutChangeRegularFile(cfg *config.Config,
 			return err
 		}
 
-		if err := w.checkoutFile(cfg, fs, f); err != nil {
+		err = w.checkoutFile(attrs, fs, f)
+		if errors.Is(err, errFilterDelayed) {
+			// Written, and added to the index, by finishDelayedCheckout.
+			return nil
+		}
+		if err != nil {
 			return err
 		}
 
@@ -1064,7 +1143,7 @@ func (w *Worktree) clearBlockingSymlinks(fs *worktreeFilesystem, name string) er
 	return nil
 }
 
-func (w *Worktree) checkoutFile(cfg *config.Config, fs *worktreeFilesystem, f *object.File) (err error) {
+func (w *Worktree) checkoutFile(attrs *attributes, fs *worktreeFilesystem, f *object.File) (err error) {
 	// checkoutFile is the materialisation boundary for tracked entries.
 	// Remove any blocking symlink first so the subsequent OpenFile or
 	// Symlink call writes the entry itself instead of following a planted
@@ -1088,10 +1167,10 @@ func (w *Worktree) checkoutFile(cfg *config.Config, fs *worktreeFilesystem, f *o
 	}
 	defer ioutil.CheckClose(dstFile, &err)
 
-	return w.copyObjectToWorktree(cfg, f, dstFile)
+	return w.copyObjectToWorktree(attrs, f, dstFile)
 }
 
-func (w *Worktree) copyObjectToWorktree(cfg *config.Config, object *object.File, file billy.File) (err error) {
+func (w *Worktree) copyObjectToWorktree(attrs *attributes, object *object.File, file billy.File) (err error) {
 	var src io.ReadCloser
 	var dst io.Writer = file
 
@@ -1101,7 +1180,15 @@ func (w *Worktree) copyObjectToWorktree(cfg *config.Config, object *object.File,
 	}
 	defer ioutil.CheckClose(src, &err)
 
-	if cfg.Core.AutoCRLF == "true" {
+	// As git, the content is converted into its working-tree-encoding, and
+	// then through its smudge filter, once its line endings are converted.
+	var unfiltered *bytes.Buffer
+	if attrs.smudges(object.Name) {
+		unfiltered = &bytes.Buffer{}
+		dst = unfiltered
+	}
+
+	if conv := attrs.eolConversion(object.Name); conv.CRLF() {
 		br := sync.GetBufioReader(src)
 		defer sync.PutBufioReader(br)
 
@@ -1116,15 +1203,49 @@ func (w *Worktree) copyObjectToWorktree(cfg *config.Config, object *object.File,
 		}
 		defer ioutil.CheckClose(src, &err)
 
-		if !stat.IsBinary() {
+		if conv.ToWorktree(stat) {
 			dst = convert.NewCRLFWriter(dst)
 		}
 	}
 
-	_, err = ioutil.CopyBufferPool(dst, src)
+	if _, err = ioutil.CopyBufferPool(dst, src); err != nil || unfiltered == nil {
+		return err
+	}
+
+	content, delayed, err := attrs.smudge(object.Name, unfiltered.Bytes())
+	if err != nil {
+		return err
+	}
+	if delayed {
+		return errFilterDelayed
+	}
+
+	_, err = file.Write(content)
 	return err
 }
 
+// finishDelayedCheckout writes the files of t whose filter process delayed
+// their content, and adds them to idx.
+func (w *Worktree) finishDelayedCheckout(attrs *attributes, fs *worktreeFilesystem, t *object.Tree, idx *indexBuilder) error {
+	return attrs.finishDelayedCheckout(func(name string, content []byte) error {
+		f, err := t.File(name)
+		if err != nil {
+			return err
+		}
+
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+
+		if err := util.WriteFile(fs, name, content, mode.Perm()); err != nil {
+			return err
+		}
+
+		return w.addIndexFromFile(fs, name, f.Hash, idx)
+	})
+}
+
 func (w *Worktree) checkoutFileSymlink(fs *worktreeFilesystem, f *object.File) (err error) {
 	// .gitmodules symlink rejection (and its NTFS / HFS variants) is
 	// enforced by the worktreeFilesystem wrapper's Symlink method via
@@ -1175,14 +1296,26 @@ func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *
 
 func (w *Worktree) addIndexFromFile(fs *worktreeFilesystem, name string, h plumbing.Hash, idx *indexBuilder) error {
 	idx.Remove(name)
-	fi, err := fs.Lstat(name)
+	e, err := newIndexEntryFromFile(fs, name, h)
 	if err != nil {
 		return err
 	}
 
+	idx.Add(e)
+	return nil
+}
+
+// newIndexEntryFromFile returns the index entry of the file name, whose
+// content has the hash h, with the stat information of the file.
+func newIndexEntryFromFile(fs *worktreeFilesystem, name string, h plumbing.Hash) (*index.Entry, error) {
+	fi, err := fs.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
 	mode, err := filemode.NewFromOSFileMode(fi.Mode())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	e := &index.Entry{
@@ -1198,8 +1331,8 @@ func (w *Worktree) addIndexFromFile(fs *worktreeFilesystem, name string, h plumb
 	if fillSystemInfo != nil {
 		fillSystemInfo(e, fi.Sys())
 	}
-	idx.Add(e)
-	return nil
+
+	return e, nil
 }
 
 func (r *Repository) getTreeFromCommitHash(commit plumbing.Hash) (*object.Tree, error) {
@@ -1588,6 +1721,7 @@ func removeDirIfEmpty(fs billy.Filesystem, dir string) (bool, error) {
 
 type indexBuilder struct {
 	entries map[string]*index.Entry
+	uc      *index.UntrackedCache
 }
 
 func newIndexBuilder(idx *index.Index) *indexBuilder {
@@ -1597,6 +1731,7 @@ func newIndexBuilder(idx *index.Index) *indexBuilder {
 	}
 	return &indexBuilder{
 		entries: entries,
+		uc:      idx.UntrackedCache,
 	}
 }
 
@@ -1608,11 +1743,18 @@ func (b *indexBuilder) Write(idx *index.Index) {
 }
 
 func (b *indexBuilder) Add(e *index.Entry) {
+	if _, ok := b.entries[e.Name]; !ok {
+		b.uc.Invalidate(e.Name)
+	}
 	b.entries[e.Name] = e
 }
 
 func (b *indexBuilder) Remove(name string) {
-	delete(b.entries, filepath.ToSlash(name))
+	name = filepath.ToSlash(name)
+	if _, ok := b.entries[name]; ok {
+		b.uc.Invalidate(name)
+	}
+	delete(b.entries, name)
 }
 
 // buildFilePathMap creates a map of cleaned file paths for efficient lookup.
@@ -0,0 +1,290 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v6"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/convert"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	gitattributesFile  = ".gitattributes"
+	infoAttributesFile = "info/attributes"
+)
+
+// attributesSource opens the .gitattributes file at name, failing with an
+// error satisfying os.IsNotExist if there is none.
+type attributesSource func(name string) (io.ReadCloser, error)
+
+// attributes resolves the gitattributes of the paths of a worktree. As git,
+// it reads the .gitattributes file of each directory from the first of its
+// sources having one, the first time a path of the directory is resolved,
+// and gives precedence to $GIT_DIR/info/attributes. Files that cannot be
+// read are ignored. It must be closed to stop the filter processes it
+// starts.
+type attributes struct {
+	cfg     *config.Config
+	sources []attributesSource
+	info    []gitattributes.MatchAttribute
+	filters *filters
+	// hashOnly, set when the files are only hashed, as for status, makes
+	// the content that fails to convert from its encoding be hashed as it
+	// is, as git does.
+	hashOnly bool
+
+	mu       sync.Mutex
+	dirs     map[string][]gitattributes.MatchAttribute
+	matchers map[string]gitattributes.Matcher
+}
+
+// checkinAttributes returns the attributes of the paths added from the
+// worktree, read from the worktree and then from idx.
+func (w *Worktree) checkinAttributes(cfg *config.Config, idx *index.Index) *attributes {
+	return w.newAttributes(cfg, w.worktreeAttributesSource(), w.indexAttributesSource(idx))
+}
+
+// checkoutAttributes returns the attributes of the paths checked out from
+// t, read from t and then from the worktree.
+func (w *Worktree) checkoutAttributes(cfg *config.Config, t *object.Tree) *attributes {
+	return w.newAttributes(cfg, treeAttributesSource(t), w.worktreeAttributesSource())
+}
+
+func (w *Worktree) newAttributes(cfg *config.Config, sources ...attributesSource) *attributes {
+	a := w.r.newAttributes(cfg, sources...)
+	a.filters.w = w
+	return a
+}
+
+// newAttributes returns the attributes read from sources, whose filters
+// cannot be run as there is no worktree to run them in.
+func (r *Repository) newAttributes(cfg *config.Config, sources ...attributesSource) *attributes {
+	a := &attributes{
+		cfg:      cfg,
+		sources:  sources,
+		filters:  &filters{ctx: context.Background()},
+		dirs:     make(map[string][]gitattributes.MatchAttribute),
+		matchers: make(map[string]gitattributes.Matcher),
+	}
+
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+	if st, ok := unwrapPromisor(r.Storer).(fsBased); ok {
+		a.info, _ = gitattributes.ReadAttributesFile(st.Filesystem(), nil, infoAttributesFile, true)
+	}
+
+	return a
+}
+
+func (w *Worktree) worktreeAttributesSource() attributesSource {
+	return func(name string) (io.ReadCloser, error) {
+		// As git, do not follow a .gitattributes symlink.
+		fi, err := w.filesystem.Lstat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, os.ErrNotExist
+		}
+		return w.filesystem.Open(name)
+	}
+}
+
+func (w *Worktree) indexAttributesSource(idx *index.Index) attributesSource {
+	return func(name string) (io.ReadCloser, error) {
+		if idx == nil {
+			return nil, os.ErrNotExist
+		}
+		e, err := idx.Entry(name)
+		if err != nil || e.Mode != filemode.Regular {
+			return nil, os.ErrNotExist
+		}
+		blob, err := object.GetBlob(w.r.Storer, e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return blob.Reader()
+	}
+}
+
+func treeAttributesSource(t *object.Tree) attributesSource {
+	return func(name string) (io.ReadCloser, error) {
+		if t == nil {
+			return nil, os.ErrNotExist
+		}
+		f, err := t.File(name)
+		if err != nil || f.Mode != filemode.Regular {
+			return nil, os.ErrNotExist
+		}
+		return f.Reader()
+	}
+}
+
+// match returns the attributes named names of the file at path, a slash
+// separated path relative to the root of the worktree.
+func (a *attributes) match(path string, names ...string) map[string]gitattributes.Attribute {
+	parts := strings.Split(path, "/")
+	results, _ := a.matcher(parts[:len(parts)-1]).Match(parts, names)
+	return results
+}
+
+// eolConversion returns the line ending conversion of the file at path,
+// from its text and eol attributes and from core.autocrlf and core.eol, as
+// git does.
+func (a *attributes) eolConversion(path string) convert.EOLConversion {
+	attrs := a.match(path, "text", "eol")
+
+	var text, auto bool
+	if attr, ok := attrs["text"]; ok {
+		switch {
+		case attr.IsSet():
+			text = true
+		case attr.IsUnset():
+			return convert.EOLNone
+		case attr.IsValueSet() && attr.Value() == "auto":
+			auto = true
+		}
+	}
+
+	if attr, ok := attrs["eol"]; ok && attr.IsValueSet() {
+		switch attr.Value() {
+		case "lf":
+			if auto {
+				return convert.EOLAutoInput
+			}
+			return convert.EOLInput
+		case "crlf":
+			if auto {
+				return convert.EOLAutoCRLF
+			}
+			return convert.EOLCRLF
+		}
+	}
+
+	switch {
+	case text && a.textEOLIsCRLF():
+		return convert.EOLCRLF
+	case text:
+		return convert.EOLInput
+	case auto && a.textEOLIsCRLF():
+		return convert.EOLAutoCRLF
+	case auto:
+		return convert.EOLAutoInput
+	}
+
+	switch a.cfg.Core.AutoCRLF {
+	case "true":
+		return convert.EOLAutoCRLF
+	case "input":
+		return convert.EOLAutoInput
+	default:
+		return convert.EOLNone
+	}
+}
+
+// textEOLIsCRLF reports whether text files without an eol attribute are
+// checked out with CRLF line endings.
+func (a *attributes) textEOLIsCRLF() bool {
+	switch a.cfg.Core.AutoCRLF {
+	case "true":
+		return true
+	case "input":
+		return false
+	}
+
+	switch a.cfg.Core.EOL {
+	case "crlf":
+		return true
+	case "lf":
+		return false
+	default:
+		return runtime.GOOS == "windows"
+	}
+}
+
+// matcher returns the matcher of the paths in dir.
+func (a *attributes) matcher(dir []string) gitattributes.Matcher {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := path.Join(dir...)
+	if m, ok := a.matchers[key]; ok {
+		return m
+	}
+
+	var stack []gitattributes.MatchAttribute
+	for i := 0; i <= len(dir); i++ {
+		stack = append(stack, a.dir(dir[:i])...)
+	}
+	stack = append(stack, a.info...)
+
+	m := gitattributes.NewMatcher(stack)
+	a.matchers[key] = m
+	return m
+}
+
+// dir returns the attributes of the .gitattributes file of dir.
+func (a *attributes) dir(dir []string) []gitattributes.MatchAttribute {
+	key := path.Join(dir...)
+	if attrs, ok := a.dirs[key]; ok {
+		return attrs
+	}
+
+	attrs := a.read(dir)
+	a.dirs[key] = attrs
+	return attrs
+}
+
+func (a *attributes) read(dir []string) []gitattributes.MatchAttribute {
+	name := path.Join(append(dir[:len(dir):len(dir)], gitattributesFile)...)
+	for _, open := range a.sources {
+		r, err := open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil
+		}
+
+		attrs, err := readAttributes(r, dir)
+		if err != nil {
+			return nil
+		}
+		return attrs
+	}
+
+	return nil
+}
+
+// readAttributes reads the attributes of the .gitattributes file of dir
+// from r, and closes it. As git, macros are only honored at the root.
+func readAttributes(r io.ReadCloser, dir []string) (attrs []gitattributes.MatchAttribute, err error) {
+	defer ioutil.CheckClose(r, &err)
+
+	attrs, err = gitattributes.ReadAttributes(r, dir, true)
+	if err != nil || len(dir) == 0 {
+		return attrs, err
+	}
+
+	filtered := attrs[:0]
+	for _, attr := range attrs {
+		if attr.Pattern != nil {
+			filtered = append(filtered, attr)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,217 @@
+package git
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/utils/convert"
+)
+
+var attributesTestSignature = &object.Signature{Name: "foo", Email: "foo@foo.foo"}
+
+// newAttributesTestRepository initializes a repository with a filesystem
+// storage, so it has an info/attributes file, holding files.
+func newAttributesTestRepository(t *testing.T, files map[string]string) (*Repository, *Worktree) {
+	t.Helper()
+
+	dot := memfs.New()
+	r, err := Init(filesystem.NewStorage(dot, cache.NewObjectLRUDefault()), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	for name, content := range files {
+		require.NoError(t, util.WriteFile(w.Filesystem(), name, []byte(content), 0o644))
+	}
+
+	return r, w
+}
+
+func readBlob(t *testing.T, r *Repository, h plumbing.Hash) string {
+	t.Helper()
+
+	blob, err := r.BlobObject(h)
+	require.NoError(t, err)
+	reader, err := blob.Reader()
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func readWorktreeFile(t *testing.T, w *Worktree, name string) string {
+	t.Helper()
+
+	content, err := util.ReadFile(w.Filesystem(), name)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestAddGitattributesEOL(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes":     "* text=auto\n*.sh eol=lf\n*.bin binary\n*.raw -text\n",
+		"run.sh":             "echo\r\n",
+		"notes.txt":          "notes\r\n",
+		"data.bin":           "data\r\n",
+		"data.raw":           "data\r\n",
+		"zero.txt":           "zero\x00\r\n",
+		"sub/.gitattributes": "*.sh -text\n",
+		"sub/run.sh":         "echo\r\n",
+	})
+
+	added := map[string]string{
+		"run.sh":     "echo\n",
+		"notes.txt":  "notes\n",
+		"data.bin":   "data\r\n",
+		"data.raw":   "data\r\n",
+		"zero.txt":   "zero\x00\r\n",
+		"sub/run.sh": "echo\r\n",
+	}
+	for name, expected := range added {
+		h, err := w.Add(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, readBlob(t, r, h), name)
+	}
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	for name := range added {
+		assert.Equal(t, Unmodified, status.File(name).Worktree, name)
+	}
+}
+
+func TestAddInfoAttributes(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt text\n",
+		"notes.txt":      "notes\r\n",
+	})
+
+	fss, ok := r.Storer.(*filesystem.Storage)
+	require.True(t, ok)
+	require.NoError(t, util.WriteFile(fss.Filesystem(), "info/attributes", []byte("*.txt -text\n"), 0o644))
+
+	h, err := w.Add("notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "notes\r\n", readBlob(t, r, h))
+}
+
+func TestCheckoutGitattributesEOL(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.sh eol=lf\n*.bat eol=crlf\n*.txt text\n",
+		"run.sh":         "echo\n",
+		"run.bat":        "echo\n",
+		"notes.txt":      "notes\n",
+		"plain":          "plain\n",
+	})
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err := w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Core.EOL = "crlf"
+	require.NoError(t, r.SetConfig(cfg))
+
+	// Remove the worktree files, .gitattributes included, so the checkout
+	// reads the attributes from the tree it checks out.
+	for _, name := range []string{".gitattributes", "run.sh", "run.bat", "notes.txt", "plain"} {
+		require.NoError(t, w.Filesystem().Remove(name))
+	}
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+
+	assert.Equal(t, "echo\n", readWorktreeFile(t, w, "run.sh"))
+	assert.Equal(t, "echo\r\n", readWorktreeFile(t, w, "run.bat"))
+	assert.Equal(t, "notes\r\n", readWorktreeFile(t, w, "notes.txt"))
+	assert.Equal(t, "plain\n", readWorktreeFile(t, w, "plain"))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+}
+
+func TestCheckoutGitattributesOverrideAutoCRLF(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.sh eol=lf\n",
+		"run.sh":         "echo\n",
+		"notes.txt":      "notes\n",
+	})
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	commit, err := w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Core.AutoCRLF = "true"
+	require.NoError(t, r.SetConfig(cfg))
+
+	require.NoError(t, w.Filesystem().Remove("run.sh"))
+	require.NoError(t, w.Filesystem().Remove("notes.txt"))
+	require.NoError(t, w.Checkout(&CheckoutOptions{Hash: commit, Force: true}))
+
+	assert.Equal(t, "echo\n", readWorktreeFile(t, w, "run.sh"))
+	assert.Equal(t, "notes\r\n", readWorktreeFile(t, w, "notes.txt"))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+}
+
+func TestAttributesEOLConversion(t *testing.T) {
+	t.Parallel()
+
+	_, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.auto text=auto\n*.text text\n*.lf eol=lf\n*.crlf eol=crlf\n" +
+			"*.autocrlf text=auto eol=crlf\n*.none -text\n*.bin binary\n",
+	})
+
+	tests := []struct {
+		autoCRLF, eol string
+		path          string
+		expected      convert.EOLConversion
+	}{
+		{"", "", "file", convert.EOLNone},
+		{"true", "", "file", convert.EOLAutoCRLF},
+		{"input", "", "file", convert.EOLAutoInput},
+		{"", "lf", "a.auto", convert.EOLAutoInput},
+		{"", "crlf", "a.auto", convert.EOLAutoCRLF},
+		{"true", "lf", "a.text", convert.EOLCRLF},
+		{"input", "crlf", "a.text", convert.EOLInput},
+		{"", "crlf", "a.text", convert.EOLCRLF},
+		{"true", "", "a.lf", convert.EOLInput},
+		{"", "", "a.crlf", convert.EOLCRLF},
+		{"", "", "a.autocrlf", convert.EOLAutoCRLF},
+		{"true", "crlf", "a.none", convert.EOLNone},
+		{"true", "crlf", "a.bin", convert.EOLNone},
+	}
+
+	for _, tc := range tests {
+		cfg := config.NewConfig()
+		cfg.Core.AutoCRLF = tc.autoCRLF
+		cfg.Core.EOL = tc.eol
+
+		attrs := w.checkinAttributes(cfg, nil)
+		assert.Equal(t, tc.expected, attrs.eolConversion(tc.path), "%+v", tc)
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/go-git/go-git/v6/utils/trace"
-	"github.com/go-git/go-git/v6/x/plugin"
 )
 
 var (
@@ -128,6 +127,13 @@ func (w *Worktree) CherryPick(commitOpts *CommitOptions, ortStrategyOption OrtMe
 	fs, closeFS := w.reusableRootFS()
 	defer closeFS()
 
+	var attrs *attributes
+	defer func() {
+		if attrs != nil {
+			attrs.close()
+		}
+	}()
+
 	for _, commit := range commits {
 		var changes object.Changes
 		headRef, err := w.r.Head()
@@ -148,16 +154,22 @@ func (w *Worktree) CherryPick(commitOpts *CommitOptions, ortStrategyOption OrtMe
 			return err
 		}
 
+		toTree := commitTree
 		switch ortStrategyOption {
 		case TheirsMergeStrategy:
 			changes, err = currentTree.Diff(commitTree)
 		case OursMergeStrategy:
 			changes, err = commitTree.Diff(currentTree)
+			toTree = currentTree
 		}
 
 		if err != nil {
 			return err
 		}
+		if attrs != nil {
+			attrs.close()
+		}
+		attrs = w.checkoutAttributes(cfg, toTree)
 		for _, change := range changes {
 			action, err := change.Action()
 			if err != nil {
@@ -181,7 +193,7 @@ func (w *Worktree) CherryPick(commitOpts *CommitOptions, ortStrategyOption OrtMe
 				// worktree write needs the full path so it lands at the
 				// right location and is validated by the wrapper.
 				to.Name = change.To.Name
-				if err := w.checkoutFile(cfg, fs, to); err != nil {
+				if err := w.checkoutFile(attrs, fs, to); err != nil {
 					return err
 				}
 				if _, err := w.Add(to.Name); err != nil {
@@ -218,12 +230,15 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 		return err
 	}
 
+	attrs := w.checkinAttributes(cfg, idx)
+	defer attrs.close()
+
 	for path, fs := range s {
 		if fs.Worktree != Modified && fs.Worktree != Deleted {
 			continue
 		}
 
-		if _, _, err := w.doAddFile(cfg, idx, s, path, nil); err != nil {
+		if _, _, err := w.doAddFile(attrs, idx, s, path, nil); err != nil {
 			return err
 		}
 	}
@@ -259,15 +274,9 @@ func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumb
 	if signer == nil {
 		cfg, err := w.r.ConfigScoped(config.SystemScope)
 		if err == nil && cfg != nil && cfg.Commit.GpgSign.IsTrue() {
-			// Use Has before Get so the key is not frozen when no plugin is
-			// registered, allowing callers to register one later.
-			if !plugin.Has(plugin.ObjectSigner()) {
-				return plumbing.ZeroHash, fmt.Errorf("cannot auto-sign commit: disable commit.gpgSign or register an ObjectSigner plugin")
-			}
-
-			signer, err = plugin.Get(plugin.ObjectSigner())
+			signer, err = autoSigner(cfg, commit.Committer)
 			if err != nil {
-				return plumbing.ZeroHash, fmt.Errorf("get object signer: %w", err)
+				return plumbing.ZeroHash, fmt.Errorf("cannot auto-sign commit: %w", err)
 			}
 		}
 	}
@@ -331,20 +340,26 @@ func (h *buildTreeHelper) commitIndexEntry(e *index.Entry) error {
 		return nil
 	}
 
-	parts := strings.Split(e.Name, "/")
+	// A sparse directory entry stands for the tree of the directory, as is.
+	name := e.Name
+	if e.IsSparseDir() {
+		name = strings.TrimSuffix(name, "/")
+	}
+
+	parts := strings.Split(name, "/")
 
 	var fullpath string
 	for _, part := range parts {
 		parent := fullpath
 		fullpath = path.Join(fullpath, part)
 
-		h.doBuildTree(e, parent, fullpath)
+		h.doBuildTree(e, name, parent, fullpath)
 	}
 
 	return nil
 }
 
-func (h *buildTreeHelper) doBuildTree(e *index.Entry, parent, fullpath string) {
+func (h *buildTreeHelper) doBuildTree(e *index.Entry, name, parent, fullpath string) {
 	if _, ok := h.trees[fullpath]; ok {
 		return
 	}
@@ -355,7 +370,7 @@ func (h *buildTreeHelper) doBuildTree(e *index.Entry, parent, fullpath string) {
 
 	te := object.TreeEntry{Name: path.Base(fullpath)}
 
-	if fullpath == e.Name {
+	if fullpath == name {
 		te.Mode = e.Mode
 		te.Hash = e.Hash
 	} else {
@@ -387,8 +402,14 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 
 		path := path.Join(parent, e.Name)
 
+		// The tree of a sparse directory entry is already stored.
+		sub, ok := h.trees[path]
+		if !ok {
+			continue
+		}
+
 		var err error
-		e.Hash, err = h.copyTreeToStorageRecursive(path, h.trees[path])
+		e.Hash, err = h.copyTreeToStorageRecursive(path, sub)
 		if err != nil {
 			return plumbing.ZeroHash, err
 		}
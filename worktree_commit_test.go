@@ -1196,10 +1196,10 @@ func TestBuildCommitObjectSignerSelection(t *testing.T) { //nolint:paralleltest
 			wantPluginUsed: false,
 		},
 		{
-			name:          "error if commit.signGpg=true and no plugin registered",
+			name:          "error if commit.signGpg=true and no plugin or signing key",
 			commitSignGpg: config.OptBoolTrue,
 			wantSignature: "",
-			wantErr:       "cannot auto-sign commit: disable commit.gpgSign or register an ObjectSigner plugin",
+			wantErr:       "cannot auto-sign commit: no signing key",
 		},
 		{
 			name:           "CommitOptions.Signer takes precedence over plugin",
@@ -0,0 +1,415 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/go-git/go-git/v6/utils/merge"
+)
+
+// Conflict is a path left unmerged in the index by a merge, with the blobs
+// of each of its sides.
+type Conflict struct {
+	Path string
+	// Base is the path in the common ancestor, nil if it was not there.
+	Base *ConflictEntry
+	// Ours is the path in our side of the merge, nil if it was deleted.
+	Ours *ConflictEntry
+	// Theirs is the path in their side of the merge, nil if it was deleted.
+	Theirs *ConflictEntry
+}
+
+// ConflictEntry is a side of a conflict.
+type ConflictEntry struct {
+	Mode filemode.FileMode
+	Hash plumbing.Hash
+	// Blob is the blob of the side, nil for a gitlink, whose hash is that
+	// of a commit of the submodule.
+	Blob *object.Blob
+}
+
+// ConflictResolution is what a conflict is resolved with.
+type ConflictResolution int8
+
+const (
+	// ResolveWithWorktree resolves a conflict with the file in the
+	// worktree, as git add does, or by removing the path if there is none.
+	ResolveWithWorktree ConflictResolution = iota
+	// ResolveWithOurs resolves a conflict with our side of the merge.
+	ResolveWithOurs
+	// ResolveWithTheirs resolves a conflict with their side of the merge.
+	ResolveWithTheirs
+	// ResolveWithBase resolves a conflict with the common ancestor.
+	ResolveWithBase
+)
+
+// ResolveConflictOptions describes how a conflict is resolved.
+type ResolveConflictOptions struct {
+	// Resolution is the side the conflict is resolved with. A side the path
+	// is not in resolves it by removing the path.
+	Resolution ConflictResolution
+	// Content, if not nil, is the content the conflict is resolved with,
+	// instead of a side. The file has the mode of our side, or else of
+	// theirs.
+	Content []byte
+}
+
+// ConflictMarkersOptions describes how the conflict markers of a path are
+// written.
+type ConflictMarkersOptions struct {
+	// Style is the style the conflicts are written in. If empty, that of
+	// merge.conflictStyle is used.
+	Style merge.Style
+	// OursLabel, BaseLabel and TheirsLabel are written after the conflict
+	// markers. If empty, "ours", "base" and "theirs" are used, as
+	// git checkout --merge does.
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+}
+
+// Conflicts returns the paths left unmerged in the index, sorted by path.
+func (w *Worktree) Conflicts() ([]Conflict, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Conflict
+	for _, c := range idx.Conflicts() {
+		conflict := Conflict{Path: c.Path}
+		for _, side := range []struct {
+			e  *index.Entry
+			to **ConflictEntry
+		}{{c.Ancestor, &conflict.Base}, {c.Ours, &conflict.Ours}, {c.Theirs, &conflict.Theirs}} {
+			if side.e == nil {
+				continue
+			}
+
+			entry := &ConflictEntry{Mode: side.e.Mode, Hash: side.e.Hash}
+			if side.e.Mode != filemode.Submodule {
+				if entry.Blob, err = w.r.BlobObject(side.e.Hash); err != nil {
+					return nil, err
+				}
+			}
+			*side.to = entry
+		}
+
+		conflicts = append(conflicts, conflict)
+	}
+
+	return conflicts, nil
+}
+
+// ResolveConflict resolves the conflict of path, as opts asks: the file is
+// written to the worktree, and the path is staged in the index, the entries
+// of its sides being recorded in the resolve undo extension, as git does.
+// It returns index.ErrNotConflicted if path has no conflict.
+func (w *Worktree) ResolveConflict(path string, opts *ResolveConflictOptions) error {
+	if opts == nil {
+		opts = &ResolveConflictOptions{}
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	c, err := indexConflict(idx, path)
+	if err != nil {
+		return err
+	}
+
+	var e *index.Entry
+	switch {
+	case opts.Content != nil:
+		mode := filemode.Regular
+		if side := conflictSide(c, ResolveWithOurs); side != nil {
+			mode = side.Mode
+		} else if side := conflictSide(c, ResolveWithTheirs); side != nil {
+			mode = side.Mode
+		}
+
+		o, err := newBlobObject(w.r.Storer.NewEncodedObject(), opts.Content)
+		if err != nil {
+			return err
+		}
+		h, err := w.r.Storer.SetEncodedObject(o)
+		if err != nil {
+			return err
+		}
+
+		e, err = w.checkoutConflictEntry(cfg, idx, &index.Entry{Name: c.Path, Hash: h, Mode: mode})
+		if err != nil {
+			return err
+		}
+	case opts.Resolution == ResolveWithWorktree:
+		if _, err := w.filesystem.Lstat(c.Path); os.IsNotExist(err) {
+			break
+		}
+
+		attrs := w.checkinAttributes(cfg, idx)
+		h, err := w.copyFileToStorage(attrs, c.Path)
+		attrs.close()
+		if err != nil {
+			return err
+		}
+
+		if e, err = newIndexEntryFromFile(w.filesystem, c.Path, h); err != nil {
+			return err
+		}
+	default:
+		side := conflictSide(c, opts.Resolution)
+		if side == nil {
+			if err := w.removeConflictFile(c.Path); err != nil {
+				return err
+			}
+			break
+		}
+
+		if e, err = w.checkoutConflictEntry(cfg, idx, side); err != nil {
+			return err
+		}
+	}
+
+	if err := idx.ResolveConflict(c.Path, e); err != nil {
+		return err
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// WriteConflictMarkers writes the file of the conflicted path to the
+// worktree as a merge of its sides, as git checkout --merge does: the
+// changes of both sides that overlap are written between conflict markers,
+// in the style of merge.conflictStyle, unless opts asks for another.
+//
+// As git, if a side was deleted, or is binary, a symlink or a gitlink, the
+// file of our side, or else of theirs, is written instead.
+func (w *Worktree) WriteConflictMarkers(path string, opts *ConflictMarkersOptions) error {
+	if opts == nil {
+		opts = &ConflictMarkersOptions{}
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	style := opts.Style
+	if style == "" {
+		style = merge.Style(cfg.Merge.ConflictStyle)
+	}
+	switch style {
+	case "", merge.StyleMerge, merge.StyleDiff3, merge.StyleZDiff3:
+	default:
+		return fmt.Errorf("unknown conflict style %q", style)
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	c, err := indexConflict(idx, path)
+	if err != nil {
+		return err
+	}
+
+	if c.Ours == nil || c.Theirs == nil {
+		side := c.Ours
+		if side == nil {
+			side = c.Theirs
+		}
+
+		return w.writeConflictFile(cfg, idx, side)
+	}
+
+	attrs := w.newAttributes(cfg, w.indexAttributesSource(idx), w.worktreeAttributesSource())
+	mergeAttr := attrs.match(c.Path, "merge", "conflict-marker-size")
+	attrs.close()
+
+	mergeOpts := &merge.Options{
+		Style:       style,
+		OursLabel:   labelOr(opts.OursLabel, "ours"),
+		BaseLabel:   labelOr(opts.BaseLabel, "base"),
+		TheirsLabel: labelOr(opts.TheirsLabel, "theirs"),
+	}
+	if a, ok := mergeAttr["conflict-marker-size"]; ok && a.IsValueSet() {
+		mergeOpts.MarkerSize, _ = strconv.Atoi(a.Value())
+	}
+
+	contents := make([][]byte, 3)
+	for i, e := range []*index.Entry{c.Ancestor, c.Ours, c.Theirs} {
+		if e == nil {
+			continue
+		}
+		if e.Mode == filemode.Symlink || e.Mode == filemode.Submodule {
+			return w.writeConflictFile(cfg, idx, c.Ours)
+		}
+
+		if contents[i], err = w.blobContent(e.Hash); err != nil {
+			return err
+		}
+
+		isBinary, err := binary.IsBinary(bytes.NewReader(contents[i]))
+		if err != nil {
+			return err
+		}
+		if isBinary {
+			return w.writeConflictFile(cfg, idx, c.Ours)
+		}
+	}
+
+	if a, ok := mergeAttr["merge"]; ok && a.IsUnset() {
+		return w.writeConflictFile(cfg, idx, c.Ours)
+	}
+
+	merged, _ := merge.Merge(contents[0], contents[1], contents[2], mergeOpts)
+	o, err := newBlobObject(w.r.Storer.NewEncodedObject(), merged)
+	if err != nil {
+		return err
+	}
+	h, err := w.r.Storer.SetEncodedObject(o)
+	if err != nil {
+		return err
+	}
+
+	return w.writeConflictFile(cfg, idx, &index.Entry{Name: c.Path, Hash: h, Mode: c.Ours.Mode})
+}
+
+// indexConflict returns the conflict of path in idx, or
+// index.ErrNotConflicted if it has none.
+func indexConflict(idx *index.Index, path string) (*index.Conflict, error) {
+	name := filepath.ToSlash(path)
+	for _, c := range idx.Conflicts() {
+		if c.Path == name {
+			return &c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", index.ErrNotConflicted, name)
+}
+
+// conflictSide returns the entry of the side r of the conflict c, if any.
+func conflictSide(c *index.Conflict, r ConflictResolution) *index.Entry {
+	switch r {
+	case ResolveWithOurs:
+		return c.Ours
+	case ResolveWithTheirs:
+		return c.Theirs
+	case ResolveWithBase:
+		return c.Ancestor
+	}
+
+	return nil
+}
+
+// checkoutConflictEntry writes the file of e to the worktree, and returns
+// its entry, with the stat information of the file.
+func (w *Worktree) checkoutConflictEntry(cfg *config.Config, idx *index.Index, e *index.Entry) (*index.Entry, error) {
+	if err := w.writeConflictFile(cfg, idx, e); err != nil {
+		return nil, err
+	}
+	if e.Mode == filemode.Submodule {
+		return &index.Entry{Name: e.Name, Hash: e.Hash, Mode: e.Mode}, nil
+	}
+
+	return newIndexEntryFromFile(w.filesystem, e.Name, e.Hash)
+}
+
+// writeConflictFile writes the file of e to the worktree. For a gitlink,
+// the directory of the submodule is created, as checkout does.
+func (w *Worktree) writeConflictFile(cfg *config.Config, idx *index.Index, e *index.Entry) error {
+	fs, closeFS := w.reusableRootFS()
+	defer closeFS()
+
+	if e.Mode == filemode.Submodule {
+		mode, err := e.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		if err := w.clearBlockingSymlinks(fs, e.Name); err != nil {
+			return err
+		}
+		return fs.MkdirAll(e.Name, mode)
+	}
+
+	blob, err := w.r.BlobObject(e.Hash)
+	if err != nil {
+		return err
+	}
+
+	attrs := w.newAttributes(cfg, w.indexAttributesSource(idx), w.worktreeAttributesSource())
+	defer attrs.close()
+
+	return w.checkoutFile(attrs, fs, object.NewFile(e.Name, e.Mode, blob))
+}
+
+func (w *Worktree) removeConflictFile(name string) error {
+	fs, closeFS := w.reusableRootFS()
+	defer closeFS()
+
+	if _, err := fs.Lstat(name); os.IsNotExist(err) {
+		return nil
+	}
+
+	return rmFileAndDirsIfEmpty(fs, name)
+}
+
+func (w *Worktree) blobContent(h plumbing.Hash) ([]byte, error) {
+	blob, err := w.r.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// newBlobObject writes content to o as a blob, and returns it.
+func newBlobObject(o plumbing.EncodedObject, content []byte) (_ plumbing.EncodedObject, err error) {
+	o.SetType(plumbing.BlobObject)
+	o.SetSize(int64(len(content)))
+
+	w, err := o.Writer()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(w, &err)
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func labelOr(label, def string) string {
+	if label == "" {
+		return def
+	}
+
+	return label
+}
@@ -0,0 +1,311 @@
+package git
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/utils/merge"
+)
+
+// newGitConflictRepository builds with git a repository left with conflicts
+// by a merge: a content conflict in "file", a modify/delete conflict in
+// "deleted" and an add/add conflict in "added".
+func newGitConflictRepository(t *testing.T) string {
+	t.Helper()
+	requireGitBinary(t)
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	commit := func() {
+		git(t, dir, "add", "-A")
+		git(t, dir, "commit", "-q", "-m", "commit")
+	}
+
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "config", "user.name", "a")
+	git(t, dir, "config", "user.email", "a@example.com")
+	write("file", "a\nb\nc\nd\ne\n")
+	write("deleted", "x\n")
+	write("clean", "1\n")
+	commit()
+
+	git(t, dir, "checkout", "-q", "-b", "theirs")
+	write("file", "a\nB\nY\nd\nE\n")
+	write("deleted", "y\n")
+	write("added", "2\n")
+	commit()
+
+	git(t, dir, "checkout", "-q", "main")
+	write("file", "a\nB\nX\nd\ne\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "deleted")))
+	write("added", "1\n")
+	commit()
+
+	out, _ := gitAllowFail(t, dir, "-c", "merge.conflictStyle=merge", "merge", "-q", "theirs")
+	require.Contains(t, out, "Automatic merge failed")
+
+	return dir
+}
+
+func TestWorktreeConflicts(t *testing.T) {
+	t.Parallel()
+	dir := newGitConflictRepository(t)
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	conflicts, err := w.Conflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 3)
+
+	content := func(e *ConflictEntry) string {
+		if e == nil {
+			return ""
+		}
+		assert.Equal(t, filemode.Regular, e.Mode)
+		r, err := e.Blob.Reader()
+		require.NoError(t, err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	for _, tc := range []struct {
+		path                        string
+		base, ours, theirs          string
+		hasBase, hasOurs, hasTheirs bool
+	}{
+		{path: "added", ours: "1\n", theirs: "2\n", hasOurs: true, hasTheirs: true},
+		{path: "deleted", base: "x\n", theirs: "y\n", hasBase: true, hasTheirs: true},
+		{path: "file", base: "a\nb\nc\nd\ne\n", ours: "a\nB\nX\nd\ne\n", theirs: "a\nB\nY\nd\nE\n", hasBase: true, hasOurs: true, hasTheirs: true},
+	} {
+		c := conflicts[0]
+		conflicts = conflicts[1:]
+
+		assert.Equal(t, tc.path, c.Path)
+		assert.Equal(t, tc.hasBase, c.Base != nil, tc.path)
+		assert.Equal(t, tc.hasOurs, c.Ours != nil, tc.path)
+		assert.Equal(t, tc.hasTheirs, c.Theirs != nil, tc.path)
+		assert.Equal(t, tc.base, content(c.Base), tc.path)
+		assert.Equal(t, tc.ours, content(c.Ours), tc.path)
+		assert.Equal(t, tc.theirs, content(c.Theirs), tc.path)
+	}
+}
+
+func TestWorktreeWriteConflictMarkers(t *testing.T) {
+	t.Parallel()
+
+	for _, style := range []merge.Style{merge.StyleMerge, merge.StyleDiff3, merge.StyleZDiff3} {
+		t.Run(string(style), func(t *testing.T) {
+			t.Parallel()
+			gitDir := newGitConflictRepository(t)
+			goDir := newGitConflictRepository(t)
+
+			git(t, gitDir, "checkout", "--conflict="+string(style), "--", "file")
+
+			git(t, goDir, "config", "merge.conflictStyle", string(style))
+			r, err := PlainOpen(goDir)
+			require.NoError(t, err)
+			w, err := r.Worktree()
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(goDir, "file"), nil, 0o644))
+			require.NoError(t, w.WriteConflictMarkers("file", nil))
+
+			want, err := os.ReadFile(filepath.Join(gitDir, "file"))
+			require.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(goDir, "file"))
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+
+			// The conflict is left in the index.
+			assert.Equal(t, git(t, gitDir, "ls-files", "-s"), git(t, goDir, "ls-files", "-s"))
+		})
+	}
+}
+
+func TestWorktreeWriteConflictMarkersOptions(t *testing.T) {
+	t.Parallel()
+	dir := newGitConflictRepository(t)
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("file conflict-marker-size=3\n"), 0o644))
+	require.NoError(t, w.WriteConflictMarkers("file", &ConflictMarkersOptions{
+		Style:       merge.StyleMerge,
+		OursLabel:   "HEAD",
+		TheirsLabel: "theirs",
+	}))
+
+	got, err := os.ReadFile(filepath.Join(dir, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\nB\n<<< HEAD\nX\n===\nY\n>>> theirs\nd\nE\n", string(got))
+
+	// A path with a side deleted is written with the other side.
+	require.NoError(t, w.WriteConflictMarkers("deleted", nil))
+	got, err = os.ReadFile(filepath.Join(dir, "deleted"))
+	require.NoError(t, err)
+	assert.Equal(t, "y\n", string(got))
+
+	assert.ErrorIs(t, w.WriteConflictMarkers("clean", nil), index.ErrNotConflicted)
+	assert.Error(t, w.WriteConflictMarkers("file", &ConflictMarkersOptions{Style: "foo"}))
+}
+
+func TestWorktreeResolveConflict(t *testing.T) {
+	t.Parallel()
+	gitDir := newGitConflictRepository(t)
+	goDir := newGitConflictRepository(t)
+
+	git(t, gitDir, "checkout", "--theirs", "--", "file")
+	git(t, gitDir, "add", "file")
+	git(t, gitDir, "rm", "-q", "deleted")
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "added"), []byte("3\n"), 0o644))
+	git(t, gitDir, "add", "added")
+
+	r, err := PlainOpen(goDir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.ResolveConflict("file", &ResolveConflictOptions{Resolution: ResolveWithTheirs}))
+	require.NoError(t, w.ResolveConflict("deleted", &ResolveConflictOptions{Resolution: ResolveWithOurs}))
+	require.NoError(t, w.ResolveConflict("added", &ResolveConflictOptions{Content: []byte("3\n")}))
+	assert.ErrorIs(t, w.ResolveConflict("file", nil), index.ErrNotConflicted)
+
+	conflicts, err := w.Conflicts()
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	for _, args := range [][]string{
+		{"ls-files", "-s"},
+		{"ls-files", "--resolve-undo"},
+		{"status", "--porcelain"},
+	} {
+		assert.Equal(t, git(t, gitDir, args...), git(t, goDir, args...), "git %v", args)
+	}
+}
+
+func TestWorktreeAddResolvesConflict(t *testing.T) {
+	t.Parallel()
+	gitDir := newGitConflictRepository(t)
+	goDir := newGitConflictRepository(t)
+
+	for _, dir := range []string{gitDir, goDir} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("a\nB\nXY\nd\nE\n"), 0o644))
+		require.NoError(t, os.Remove(filepath.Join(dir, "deleted")))
+	}
+	git(t, gitDir, "add", "file", "deleted")
+
+	r, err := PlainOpen(goDir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	_, err = w.Add("file")
+	require.NoError(t, err)
+	_, err = w.Add("deleted")
+	require.NoError(t, err)
+
+	conflicts, err := w.Conflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "added", conflicts[0].Path)
+
+	for _, args := range [][]string{
+		{"ls-files", "-s"},
+		{"ls-files", "--resolve-undo"},
+		{"status", "--porcelain"},
+	} {
+		assert.Equal(t, git(t, gitDir, args...), git(t, goDir, args...), "git %v", args)
+	}
+}
+
+func TestWorktreeWriteConflictMarkersIndexAttributes(t *testing.T) {
+	t.Parallel()
+	dir := newGitConflictRepository(t)
+
+	// The attributes are read from the index first, as for a checkout.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("file conflict-marker-size=3\n"), 0o644))
+	git(t, dir, "add", ".gitattributes")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("file conflict-marker-size=5\n"), 0o644))
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.WriteConflictMarkers("file", &ConflictMarkersOptions{Style: merge.StyleMerge}))
+
+	got, err := os.ReadFile(filepath.Join(dir, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\nB\n<<< ours\nX\n===\nY\n>>> theirs\nd\nE\n", string(got))
+}
+
+func TestWorktreeConflictsSymlinkAndGitlink(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	blob := func(content string) plumbing.Hash {
+		o, err := newBlobObject(r.Storer.NewEncodedObject(), []byte(content))
+		require.NoError(t, err)
+		h, err := r.Storer.SetEncodedObject(o)
+		require.NoError(t, err)
+		return h
+	}
+	gitlink := plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")
+	idx := &index.Index{Version: 2, Entries: []*index.Entry{
+		{Name: "link", Hash: blob("base"), Mode: filemode.Symlink, Stage: index.AncestorMode},
+		{Name: "link", Hash: blob("ours"), Mode: filemode.Symlink, Stage: index.OurMode},
+		{Name: "link", Hash: blob("theirs"), Mode: filemode.Symlink, Stage: index.TheirMode},
+		{Name: "sub", Hash: gitlink, Mode: filemode.Submodule, Stage: index.OurMode},
+		{Name: "sub", Hash: blob("file\n"), Mode: filemode.Regular, Stage: index.TheirMode},
+	}}
+	require.NoError(t, r.Storer.SetIndex(idx))
+
+	conflicts, err := w.Conflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 2)
+	sub := conflicts[1]
+	assert.Equal(t, "sub", sub.Path)
+	assert.Equal(t, filemode.Submodule, sub.Ours.Mode)
+	assert.Equal(t, gitlink, sub.Ours.Hash)
+	assert.Nil(t, sub.Ours.Blob)
+	assert.NotNil(t, sub.Theirs.Blob)
+
+	// A symlink is not merged, our side is written.
+	require.NoError(t, w.WriteConflictMarkers("link", nil))
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "ours", target)
+
+	// Nor is a gitlink, whose directory is created.
+	require.NoError(t, w.WriteConflictMarkers("sub", nil))
+	fi, err := os.Stat(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	require.NoError(t, w.ResolveConflict("sub", &ResolveConflictOptions{Resolution: ResolveWithOurs}))
+	idx, err = r.Storer.Index()
+	require.NoError(t, err)
+	e, err := idx.Entry("sub")
+	require.NoError(t, err)
+	assert.Equal(t, filemode.Submodule, e.Mode)
+	assert.Equal(t, gitlink, e.Hash)
+}
@@ -0,0 +1,82 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/utils/convert"
+)
+
+// defaultCheckRoundtripEncoding is the encoding checked for round trips
+// when core.checkRoundtripEncoding is not set, as for git.
+const defaultCheckRoundtripEncoding = "SHIFT-JIS"
+
+// workingTreeEncoding returns the encoding of the file at path in the
+// worktree, as set by its working-tree-encoding attribute, or nil if its
+// content is stored as it is: the attribute does not name an encoding, or
+// names UTF-8.
+func (a *attributes) workingTreeEncoding(path string) (*convert.Encoding, error) {
+	attr, ok := a.match(path, "working-tree-encoding")["working-tree-encoding"]
+	if !ok || !attr.IsValueSet() || attr.Value() == "" {
+		return nil, nil
+	}
+
+	enc, err := convert.LookupEncoding(attr.Value())
+	if err != nil {
+		return nil, fmt.Errorf("working-tree-encoding of %s: %w", path, err)
+	}
+
+	return enc, nil
+}
+
+// encodeToGit converts content, the worktree content of the file at path,
+// from enc into UTF-8. As git, it fails if content is not valid, or does not
+// convert back into itself and enc is listed in core.checkRoundtripEncoding,
+// unless the attributes only hash the files, in which case content that
+// does not convert is left as it is.
+func (a *attributes) encodeToGit(path string, enc *convert.Encoding, content []byte) ([]byte, error) {
+	out, err := enc.ToGit(content)
+	switch {
+	case err != nil && a.hashOnly:
+		return content, nil
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", path, err)
+	case a.hashOnly || !a.checkRoundtrip(enc):
+		return out, nil
+	}
+
+	if !enc.RoundTrips(content) {
+		return nil, fmt.Errorf("encoding %s from %s to UTF-8 and back is not the same", path, enc.Name())
+	}
+
+	return out, nil
+}
+
+// encodeToWorktree converts content, the content of the file at path in the
+// repository, from UTF-8 into enc. As git, content that does not convert is
+// left as it is.
+func encodeToWorktree(enc *convert.Encoding, content []byte) []byte {
+	out, err := enc.ToWorktree(content)
+	if err != nil {
+		return content
+	}
+
+	return out
+}
+
+// checkRoundtrip reports whether enc is listed in
+// core.checkRoundtripEncoding.
+func (a *attributes) checkRoundtrip(enc *convert.Encoding) bool {
+	list := a.cfg.Core.CheckRoundtripEncoding
+	if list == "" {
+		list = defaultCheckRoundtripEncoding
+	}
+
+	for _, name := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.EqualFold(name, enc.Name()) {
+			return true
+		}
+	}
+
+	return false
+}
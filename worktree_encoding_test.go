@@ -0,0 +1,111 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/utils/convert"
+)
+
+// utf16LEBOM returns s encoded in UTF-16LE with a byte order mark.
+func utf16LEBOM(s string) string {
+	b := []byte{0xff, 0xfe}
+	for _, r := range s {
+		b = append(b, byte(r), byte(r>>8))
+	}
+	return string(b)
+}
+
+func TestWorkingTreeEncoding(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.strings text working-tree-encoding=UTF-16LE-BOM eol=crlf\n" +
+			"*.utf8 working-tree-encoding=UTF-8\n",
+		"en.strings": utf16LEBOM("hello\r\nworld\r\n"),
+		"en.utf8":    "hello\n",
+	})
+
+	h, err := w.Add("en.strings")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", readBlob(t, r, h))
+
+	h, err = w.Add("en.utf8")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", readBlob(t, r, h))
+
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	checkoutAgain(t, w, "en.strings")
+	assert.Equal(t, utf16LEBOM("hello\r\nworld\r\n"), readWorktreeFile(t, w, "en.strings"))
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	require.NoError(t, util.WriteFile(w.Filesystem(), "en.strings", []byte(utf16LEBOM("bye\r\n")), 0o644))
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Modified, status.File("en.strings").Worktree)
+}
+
+func TestWorkingTreeEncodingInvalid(t *testing.T) {
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt working-tree-encoding=UTF-16\n*.bad working-tree-encoding=NO-SUCH\n",
+		"a.txt":          "no byte order mark",
+		"b.bad":          "content",
+	})
+
+	// Files that do not convert are hashed as they are.
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Untracked, status.File("a.txt").Worktree)
+
+	_, err = w.Add("a.txt")
+	require.ErrorIs(t, err, convert.ErrBOMRequired)
+	_, err = w.Add("b.bad")
+	require.ErrorIs(t, err, convert.ErrUnknownEncoding)
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	assert.Empty(t, idx.Entries)
+}
+
+func TestWorkingTreeEncodingRoundtrip(t *testing.T) {
+	t.Parallel()
+
+	// Both map to U+2252 in Shift-JIS, which converts back into the second.
+	nec, jis := "\x87\x90", "\x81\xe0"
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt working-tree-encoding=SHIFT-JIS\n",
+		"jis.txt":        jis,
+		"nec.txt":        nec,
+	})
+
+	h, err := w.Add("jis.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "≒", readBlob(t, r, h))
+
+	_, err = w.Add("nec.txt")
+	require.ErrorContains(t, err, "nec.txt from SHIFT-JIS to UTF-8 and back is not the same")
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Core.CheckRoundtripEncoding = "UTF-16, UTF-32"
+	require.NoError(t, r.SetConfig(cfg))
+
+	h, err = w.Add("nec.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "≒", readBlob(t, r, h))
+}
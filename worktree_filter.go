@@ -0,0 +1,284 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	"github.com/go-git/go-git/v6/utils/filter"
+	"github.com/go-git/go-git/v6/x/plugin"
+)
+
+// errFilterDelayed is returned when checking out a file whose filter
+// process delayed its content, which is written by finishDelayedCheckout.
+var errFilterDelayed = errors.New("filter delayed the file")
+
+// filters runs the filter drivers of the files of an operation, keeping the
+// long-running filter processes it starts until it is closed. It is not
+// safe for concurrent use.
+type filters struct {
+	ctx context.Context
+	w   *Worktree
+	// delay, set for checkouts, lets the filter processes delay files.
+	delay bool
+
+	cfg       *config.Config
+	plugin    plugin.Filter
+	processes map[string]*filter.Process
+	delayed   map[string]map[string]bool
+	lfs       *lfs.Client
+}
+
+// filterName returns the name of the filter driver of the file at path, as
+// set by its filter attribute, if it has one that is configured, provided by
+// the filter plugin, or built in as the LFS one is when the storage can hold
+// its objects.
+func (a *attributes) filterName(path string) (string, bool) {
+	attr, ok := a.match(path, "filter")["filter"]
+	if !ok || !attr.IsValueSet() {
+		return "", false
+	}
+
+	name := attr.Value()
+	if p := a.filters.contentFilter(); p != nil && p.Supports(name) {
+		return name, true
+	}
+	if name == lfsFilterDriver && lfsStore(a.filters.w.r.Storer) != nil {
+		return name, true
+	}
+
+	_, ok = a.filters.config().Filters[name]
+	return name, ok
+}
+
+// clean returns the content to store in the repository of the file at path
+// whose worktree content is read from r: the output of its clean filter,
+// converted from its working-tree-encoding into UTF-8, as git does before
+// converting line endings. It reports whether the file has a filter driver
+// or an encoding; nothing is read from r when it has neither.
+func (a *attributes) clean(path string, r io.Reader) ([]byte, bool, error) {
+	name, filtered := a.filterName(path)
+	enc, err := a.workingTreeEncoding(path)
+	if err != nil && !a.hashOnly {
+		return nil, true, err
+	}
+	if !filtered && enc == nil {
+		return nil, false, nil
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if filtered {
+		content, _, err = a.filters.run(filter.Clean, name, path, content)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	if enc != nil {
+		content, err = a.encodeToGit(path, enc, content)
+	}
+
+	return content, true, err
+}
+
+// smudges reports whether smudge converts the content of the file at path.
+func (a *attributes) smudges(path string) bool {
+	if _, ok := a.filterName(path); ok {
+		return true
+	}
+
+	enc, _ := a.workingTreeEncoding(path)
+	return enc != nil
+}
+
+// smudge returns the content to check out in the worktree of the file at
+// path, from content once its line endings are converted: converted into
+// its working-tree-encoding, and then through its smudge filter. It reports
+// whether its filter process delayed it.
+func (a *attributes) smudge(path string, content []byte) ([]byte, bool, error) {
+	// As git, content is checked out unconverted if its encoding is
+	// unknown.
+	if enc, _ := a.workingTreeEncoding(path); enc != nil {
+		content = encodeToWorktree(enc, content)
+	}
+
+	name, ok := a.filterName(path)
+	if !ok {
+		return content, false, nil
+	}
+
+	return a.filters.run(filter.Smudge, name, path, content)
+}
+
+// close stops the filter processes started by the operation.
+func (a *attributes) close() {
+	for _, p := range a.filters.processes {
+		if p != nil {
+			_ = p.Close()
+		}
+	}
+	a.filters.processes = nil
+}
+
+// config returns the configuration of the filter drivers. As they are
+// commonly set globally, it includes the global and system configuration
+// if they can be loaded.
+func (f *filters) config() *config.Config {
+	if f.cfg == nil {
+		cfg, err := f.w.r.ConfigScoped(config.SystemScope)
+		if err != nil {
+			cfg = config.NewConfig()
+		}
+		f.cfg = cfg
+	}
+
+	return f.cfg
+}
+
+// contentFilter returns the filter plugin, if one is registered.
+func (f *filters) contentFilter() plugin.Filter {
+	if f.plugin == nil && plugin.Has(plugin.ContentFilter()) {
+		f.plugin, _ = plugin.Get(plugin.ContentFilter())
+	}
+
+	return f.plugin
+}
+
+// run runs command, filter.Clean or filter.Smudge, of the filter driver
+// name on the content of the file at path. The filter plugin takes
+// precedence over the built-in LFS driver, which takes precedence over the
+// configured commands, such as the ones running git-lfs. As git, the
+// content is left unconverted when the driver is not required and fails, or
+// misses the command; errors of the filter plugin and of the LFS driver are
+// always returned.
+func (f *filters) run(command, name, path string, content []byte) (_ []byte, delayed bool, err error) {
+	var out bytes.Buffer
+	if p := f.contentFilter(); p != nil && p.Supports(name) {
+		run := p.Clean
+		if command == filter.Smudge {
+			run = p.Smudge
+		}
+		if err := run(f.ctx, name, path, &out, bytes.NewReader(content)); err != nil {
+			return nil, false, fmt.Errorf("filter %s: %s %s: %w", name, command, path, err)
+		}
+		return out.Bytes(), false, nil
+	}
+
+	if name == lfsFilterDriver && lfsStore(f.w.r.Storer) != nil {
+		out, err := f.runLFS(command, content)
+		if err != nil {
+			return nil, false, fmt.Errorf("filter %s: %s %s: %w", name, command, path, err)
+		}
+		return out, false, nil
+	}
+
+	drv := f.config().Filters[name]
+	cmd := drv.Clean
+	if command == filter.Smudge {
+		cmd = drv.Smudge
+	}
+
+	switch {
+	case drv.Process != "":
+		delayed, err = f.runProcess(command, drv, path, &out, content)
+	case cmd != "":
+		err = filter.Run(f.ctx, cmd, f.w.filesystem.Root(), path, &out, bytes.NewReader(content))
+	default:
+		err = fmt.Errorf("no %s command", command)
+	}
+
+	switch {
+	case err == nil:
+		return out.Bytes(), delayed, nil
+	case drv.Required:
+		return nil, false, fmt.Errorf("filter %s: %s %s: %w", name, command, path, err)
+	default:
+		return content, false, nil
+	}
+}
+
+func (f *filters) runProcess(command string, drv *config.Filter, path string, dst io.Writer, content []byte) (bool, error) {
+	p, err := f.process(drv)
+	if err != nil {
+		return false, err
+	}
+
+	delayed, err := p.Filter(command, path, dst, bytes.NewReader(content), f.delay)
+	if delayed {
+		if f.delayed == nil {
+			f.delayed = make(map[string]map[string]bool)
+		}
+		if f.delayed[drv.Name] == nil {
+			f.delayed[drv.Name] = make(map[string]bool)
+		}
+		f.delayed[drv.Name][path] = true
+	}
+
+	return delayed, err
+}
+
+// process returns the filter process of drv, starting it the first time.
+func (f *filters) process(drv *config.Filter) (*filter.Process, error) {
+	if p, ok := f.processes[drv.Name]; ok {
+		if p == nil {
+			return nil, fmt.Errorf("filter process %q failed to start", drv.Process)
+		}
+		return p, nil
+	}
+
+	if f.processes == nil {
+		f.processes = make(map[string]*filter.Process)
+	}
+
+	p, err := filter.StartProcess(f.ctx, drv.Process, f.w.filesystem.Root(), filter.Clean, filter.Smudge, filter.Delay)
+	f.processes[drv.Name] = p
+	return p, err
+}
+
+// finishDelayedCheckout writes the files delayed by the filter processes
+// of attrs with write, as their content becomes available.
+func (a *attributes) finishDelayedCheckout(write func(path string, content []byte) error) error {
+	f := a.filters
+	for _, name := range slices.Sorted(maps.Keys(f.delayed)) {
+		pending := f.delayed[name]
+		p := f.processes[name]
+
+		for len(pending) > 0 {
+			paths, err := p.AvailableBlobs()
+			if err != nil {
+				return err
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("filter %s: delayed files were not filtered: %q",
+					name, slices.Sorted(maps.Keys(pending)))
+			}
+
+			for _, path := range paths {
+				if !pending[path] {
+					return fmt.Errorf("filter %s: %s was not delayed", name, path)
+				}
+				delete(pending, path)
+
+				var out bytes.Buffer
+				if _, err := p.Filter(filter.Smudge, path, &out, bytes.NewReader(nil), false); err != nil {
+					return err
+				}
+				if err := write(path, out.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	f.delayed = nil
+	return nil
+}
@@ -0,0 +1,285 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/x/plugin"
+)
+
+// filterProcessEnv makes the test binary run as the filter process of
+// runDelayingFilterProcess.
+const filterProcessEnv = "GO_GIT_TEST_FILTER_PROCESS"
+
+func setFilter(t *testing.T, r *Repository, f *config.Filter) {
+	t.Helper()
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Filters[f.Name] = f
+	require.NoError(t, r.SetConfig(cfg))
+}
+
+// checkoutAgain removes names from the worktree and checks them out again.
+func checkoutAgain(t *testing.T, w *Worktree, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		require.NoError(t, w.Filesystem().Remove(name))
+	}
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+}
+
+func TestFilterCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt filter=case\n",
+		"a.txt":          "HELLO\n",
+		"b.md":           "HELLO\n",
+	})
+	setFilter(t, r, &config.Filter{Name: "case", Clean: "tr A-Z a-z", Smudge: "tr a-z A-Z"})
+
+	h, err := w.Add("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", readBlob(t, r, h))
+
+	h, err = w.Add("b.md")
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", readBlob(t, r, h))
+
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	checkoutAgain(t, w, "a.txt")
+	assert.Equal(t, "HELLO\n", readWorktreeFile(t, w, "a.txt"))
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+}
+
+func TestFilterFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt filter=broken\n*.md filter=unknown\n",
+		"a.txt":          "content\n",
+		"b.md":           "content\n",
+	})
+	setFilter(t, r, &config.Filter{Name: "broken", Clean: "exit 1"})
+
+	h, err := w.Add("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content\n", readBlob(t, r, h))
+
+	h, err = w.Add("b.md")
+	require.NoError(t, err)
+	assert.Equal(t, "content\n", readBlob(t, r, h))
+
+	setFilter(t, r, &config.Filter{Name: "broken", Clean: "exit 1", Required: true})
+	require.NoError(t, util.WriteFile(w.Filesystem(), "a.txt", []byte("changed\n"), 0o644))
+	_, err = w.Add("a.txt")
+	require.ErrorContains(t, err, "filter broken: clean a.txt")
+
+	setFilter(t, r, &config.Filter{Name: "broken", Required: true})
+	_, err = w.Add("a.txt")
+	require.ErrorContains(t, err, "no clean command")
+}
+
+type caseFilter struct{}
+
+func (caseFilter) Supports(driver string) bool { return driver == "case" }
+
+func (caseFilter) Clean(_ context.Context, _, _ string, dst io.Writer, src io.Reader) error {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(bytes.ToLower(content))
+	return err
+}
+
+func (caseFilter) Smudge(_ context.Context, _, path string, dst io.Writer, src io.Reader) error {
+	if path == "fail.txt" {
+		return errors.New("cannot smudge")
+	}
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(bytes.ToUpper(content))
+	return err
+}
+
+func TestFilterPlugin(t *testing.T) { //nolint:paralleltest // modifies global plugin state
+	resetPluginEntry("content-filter")
+	t.Cleanup(func() { resetPluginEntry("content-filter") })
+	require.NoError(t, plugin.Register(plugin.ContentFilter(), func() plugin.Filter { return caseFilter{} }))
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt filter=case eol=crlf\n",
+		"a.txt":          "HELLO\r\n",
+		"fail.txt":       "FAIL\r\n",
+	})
+
+	h, err := w.Add("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", readBlob(t, r, h))
+
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	checkoutAgain(t, w, "a.txt")
+	assert.Equal(t, "HELLO\r\n", readWorktreeFile(t, w, "a.txt"))
+
+	require.NoError(t, w.Filesystem().Remove("fail.txt"))
+	err = w.Reset(&ResetOptions{Mode: HardReset})
+	require.ErrorContains(t, err, "cannot smudge")
+}
+
+func TestFilterProcessDelay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	r, w := newAttributesTestRepository(t, map[string]string{
+		".gitattributes": "*.txt filter=delay\n",
+		"a.txt":          "a\n",
+		"b.txt":          "b\n",
+		"c.md":           "c\n",
+	})
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("files", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	setFilter(t, r, &config.Filter{
+		Name:     "delay",
+		Process:  filterProcessEnv + "=1 '" + exe + "'",
+		Required: true,
+	})
+
+	checkoutAgain(t, w, "a.txt", "b.txt", "c.md")
+	assert.Equal(t, "A\n", readWorktreeFile(t, w, "a.txt"))
+	assert.Equal(t, "B\n", readWorktreeFile(t, w, "b.txt"))
+	assert.Equal(t, "c\n", readWorktreeFile(t, w, "c.md"))
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	for _, name := range []string{"a.txt", "b.txt", "c.md"} {
+		e, err := idx.Entry(name)
+		require.NoError(t, err, name)
+		assert.EqualValues(t, 2, e.Size, name)
+	}
+}
+
+// runDelayingFilterProcess serves the filter protocol on r and w, delaying
+// the smudging of every file it can, which uppercases it.
+func runDelayingFilterProcess(r io.Reader, w io.Writer) int {
+	br := bufio.NewReader(r)
+	readList := func() ([]string, error) {
+		var lines []string
+		for {
+			l, p, err := pktline.ReadLine(br)
+			if err != nil {
+				return nil, err
+			}
+			if l == pktline.Flush {
+				return lines, nil
+			}
+			lines = append(lines, strings.TrimSuffix(string(p), "\n"))
+		}
+	}
+	writeList := func(lines ...string) {
+		for _, line := range lines {
+			_, _ = pktline.Writeln(w, line)
+		}
+		_ = pktline.WriteFlush(w)
+	}
+
+	if _, err := readList(); err != nil {
+		return 1
+	}
+	writeList("git-filter-server", "version=2")
+	if _, err := readList(); err != nil {
+		return 1
+	}
+	writeList("capability=smudge", "capability=delay")
+
+	delayed := make(map[string]string)
+	var available []string
+	for {
+		header, err := readList()
+		if errors.Is(err, io.EOF) {
+			return 0
+		}
+		if err != nil {
+			return 1
+		}
+
+		if header[0] == "command=list_available_blobs" {
+			var paths []string
+			for _, path := range available {
+				paths = append(paths, "pathname="+path)
+			}
+			available = nil
+			writeList(paths...)
+			writeList("status=success")
+			continue
+		}
+
+		path := strings.TrimPrefix(header[1], "pathname=")
+		var content bytes.Buffer
+		for {
+			l, p, err := pktline.ReadLine(br)
+			if err != nil {
+				return 1
+			}
+			if l == pktline.Flush {
+				break
+			}
+			content.Write(p)
+		}
+
+		if len(header) > 2 && header[2] == "can-delay=1" {
+			delayed[path] = content.String()
+			available = append(available, path)
+			writeList("status=delayed")
+			continue
+		}
+
+		writeList("status=success")
+		_, _ = pktline.WriteString(w, strings.ToUpper(delayed[path]))
+		_ = pktline.WriteFlush(w)
+		writeList()
+	}
+}
@@ -0,0 +1,204 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"github.com/go-git/go-git/v6/x/plugin"
+)
+
+// errFSMonitorHook is returned when the core.fsmonitor hook fails or answers
+// with an unexpected output.
+var errFSMonitorHook = errors.New("fsmonitor hook failed")
+
+// fsMonitor returns the file system monitor of the worktree: the
+// FileSystemMonitor plugin if one is registered, or else the core.fsmonitor
+// hook, or nil if there is none. The builtin monitor of git, asked for with
+// core.fsmonitor set to true, is not supported.
+func (w *Worktree) fsMonitor(cfg *config.Config) plugin.FSMonitor {
+	if plugin.Has(plugin.FileSystemMonitor()) {
+		if m, err := plugin.Get(plugin.FileSystemMonitor()); err == nil {
+			return m
+		}
+	}
+
+	switch strings.ToLower(cfg.Core.FSMonitor) {
+	case "", "true", "yes", "on", "1", "false", "no", "off", "0":
+		return nil
+	}
+
+	return &fsMonitorHook{command: cfg.Core.FSMonitor, version: cfg.Core.FSMonitorHookVersion}
+}
+
+// refreshFSMonitor queries m for the paths changed since the token of idx,
+// which is then replaced with the token of this query, and invalidates their
+// entries, and their directories in uc. It reports whether the entries and
+// the directories left valid can be trusted, which they cannot on the first
+// query, or when the monitor cannot tell what changed.
+func (w *Worktree) refreshFSMonitor(ctx context.Context, m plugin.FSMonitor, idx *index.Index, uc *index.UntrackedCache) bool {
+	var token string
+	if idx.FSMonitor != nil {
+		token = idx.FSMonitor.Token
+	}
+
+	// As git, a monitor that fails is taken as reporting that everything
+	// changed.
+	next, paths, all, err := m.Changes(ctx, w.filesystem.Root(), token)
+	if err != nil || next == "" {
+		next, all = token, true
+	}
+
+	// The token is replaced rather than updated, as idx may share it with
+	// the copies of the index others hold.
+	idx.FSMonitor = &index.FSMonitor{Token: next}
+
+	if all || token == "" {
+		for _, e := range idx.Entries {
+			e.FSMonitorValid = false
+		}
+		return false
+	}
+
+	files := make(map[string]struct{}, len(paths))
+	dirs := make(map[string]struct{})
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			dirs[strings.TrimSuffix(p, "/")] = struct{}{}
+		} else {
+			files[p] = struct{}{}
+		}
+
+		uc.Invalidate(p)
+
+		// The rules of a .gitignore apply to the whole tree below it.
+		if path.Base(p) == gitignore.IgnoreFile && uc != nil {
+			if dir := path.Dir(p); dir == "." {
+				uc.Root = nil
+			} else {
+				uc.Invalidate(dir + "/")
+			}
+		}
+	}
+
+	for _, e := range idx.Entries {
+		if _, ok := files[e.Name]; ok {
+			e.FSMonitorValid = false
+			continue
+		}
+
+		for dir := path.Dir(e.Name); dir != "." && len(dirs) > 0; dir = path.Dir(dir) {
+			if _, ok := dirs[dir]; ok {
+				e.FSMonitorValid = false
+				break
+			}
+		}
+	}
+
+	return true
+}
+
+// markFSMonitorValid marks valid the entries of idx found unchanged in the
+// worktree, the others being those of changes.
+func markFSMonitorValid(idx *index.Index, changes merkletrie.Changes) {
+	changed := make(map[string]struct{}, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = struct{}{}
+	}
+
+	for _, e := range idx.Entries {
+		_, ok := changed[e.Name]
+		e.FSMonitorValid = !ok && e.Stage == 0 && !e.SkipWorktree && !e.IntentToAdd
+	}
+}
+
+// fsMonitorHook is the file system monitor of the core.fsmonitor hook. It is
+// queried with version 2 of the hook protocol, or version 1, or both in
+// turn if the version is not set.
+type fsMonitorHook struct {
+	command string
+	version int
+}
+
+func (h *fsMonitorHook) Changes(ctx context.Context, root, token string) (string, []string, bool, error) {
+	if h.version != 1 {
+		next, paths, all, err := h.changesV2(ctx, root, token)
+		if err == nil || h.version == 2 {
+			return next, paths, all, err
+		}
+	}
+
+	return h.changesV1(ctx, root, token)
+}
+
+// changesV2 queries the hook with version 2 of its protocol, which answers
+// with its token and the paths, all terminated by a NUL.
+func (h *fsMonitorHook) changesV2(ctx context.Context, root, token string) (string, []string, bool, error) {
+	out, err := h.run(ctx, root, "2", token)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	next, rest, ok := bytes.Cut(out, []byte{0})
+	if !ok || len(next) == 0 {
+		return "", nil, false, errFSMonitorHook
+	}
+
+	paths, all := splitFSMonitorPaths(rest)
+	return string(next), paths, all, nil
+}
+
+// changesV1 queries the hook with version 1 of its protocol, whose token is
+// the time of the previous query, in nanoseconds since the Unix epoch.
+func (h *fsMonitorHook) changesV1(ctx context.Context, root, token string) (string, []string, bool, error) {
+	next := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if _, err := strconv.ParseUint(token, 10, 64); err != nil {
+		return next, nil, true, nil
+	}
+
+	out, err := h.run(ctx, root, "1", token)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	paths, all := splitFSMonitorPaths(out)
+	return next, paths, all, nil
+}
+
+func (h *fsMonitorHook) run(ctx context.Context, root, version, token string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command+` "$@"`, h.command, version, token)
+	cmd.Dir = root
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Join(errFSMonitorHook, err)
+	}
+
+	return out, nil
+}
+
+// splitFSMonitorPaths returns the NUL terminated paths of out, and whether
+// one of them is "/", which stands for everything.
+func splitFSMonitorPaths(out []byte) ([]string, bool) {
+	var paths []string
+	for _, p := range bytes.Split(out, []byte{0}) {
+		switch string(p) {
+		case "":
+		case "/":
+			return nil, true
+		default:
+			paths = append(paths, string(p))
+		}
+	}
+
+	return paths, false
+}
@@ -0,0 +1,154 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+)
+
+// writeFSMonitorHook writes a core.fsmonitor hook answering with the
+// version 2 protocol: a new token on each query, and the paths listed in
+// the changes file of its directory.
+func writeFSMonitorHook(t *testing.T) (hook, changes string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	hook = filepath.Join(dir, "fsmonitor")
+	changes = filepath.Join(dir, "changes")
+	script := "#!/bin/sh\n" +
+		"test \"$1\" = 2 || exit 1\n" +
+		"n=$(cat '" + dir + "/n' 2>/dev/null || echo 0)\n" +
+		"n=$((n+1))\n" +
+		"echo $n > '" + dir + "/n'\n" +
+		"printf 't%s\\0' $n\n" +
+		"cat '" + changes + "'\n"
+
+	require.NoError(t, os.WriteFile(hook, []byte(script), 0o755))
+	require.NoError(t, os.WriteFile(changes, nil, 0o644))
+	return hook, changes
+}
+
+func TestStatusFSMonitorHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	fs := w.Filesystem()
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("a\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "b.txt", []byte("b\n"), 0o644))
+	_, err = w.Add(".")
+	require.NoError(t, err)
+	_, err = w.Commit("init", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	hook, changes := writeFSMonitorHook(t)
+	setCoreConfig(t, r, func(cfg *config.Config) { cfg.Core.FSMonitor = hook })
+
+	// The first query cannot tell what changed, so everything is checked.
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	idx := readIndexFile(t, dir)
+	require.NotNil(t, idx.FSMonitor)
+	assert.Equal(t, "t1", idx.FSMonitor.Token)
+	for _, e := range idx.Entries {
+		assert.True(t, e.FSMonitorValid, e.Name)
+	}
+
+	// As with git, a change the monitor does not report goes unseen.
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("changed\n"), 0o644))
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+	assert.Equal(t, "t2", readIndexFile(t, dir).FSMonitor.Token)
+
+	require.NoError(t, os.WriteFile(changes, []byte("a.txt\x00"), 0o644))
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Modified, status.File("a.txt").Worktree)
+	assert.NotContains(t, status, "b.txt")
+
+	idx = readIndexFile(t, dir)
+	for _, e := range idx.Entries {
+		assert.Equal(t, e.Name != "a.txt", e.FSMonitorValid, e.Name)
+	}
+}
+
+func TestFSMonitorHookV1(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	hook := filepath.Join(t.TempDir(), "fsmonitor")
+	script := "#!/bin/sh\n" +
+		"test \"$1\" = 1 && test \"$2\" = 42 || exit 1\n" +
+		"printf 'a.txt\\0dir/\\0'\n"
+	require.NoError(t, os.WriteFile(hook, []byte(script), 0o755))
+
+	h := &fsMonitorHook{command: hook, version: 1}
+
+	next, paths, all, err := h.Changes(context.Background(), t.TempDir(), "")
+	require.NoError(t, err)
+	assert.True(t, all)
+	assert.Empty(t, paths)
+	assert.NotEmpty(t, next)
+
+	next, paths, all, err = h.Changes(context.Background(), t.TempDir(), "42")
+	require.NoError(t, err)
+	assert.False(t, all)
+	assert.Equal(t, []string{"a.txt", "dir/"}, paths)
+	assert.NotEqual(t, "42", next)
+}
+
+func TestFSMonitorHookFallsBackToV1(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Parallel()
+
+	hook := filepath.Join(t.TempDir(), "fsmonitor")
+	script := "#!/bin/sh\n" +
+		"test \"$1\" = 1 || exit 1\n" +
+		"printf '/\\0'\n"
+	require.NoError(t, os.WriteFile(hook, []byte(script), 0o755))
+
+	h := &fsMonitorHook{command: hook}
+	_, _, all, err := h.Changes(context.Background(), t.TempDir(), "42")
+	require.NoError(t, err)
+	assert.True(t, all)
+
+	h.version = 2
+	_, _, _, err = h.Changes(context.Background(), t.TempDir(), "42")
+	require.ErrorIs(t, err, errFSMonitorHook)
+}
+
+func TestSplitFSMonitorPaths(t *testing.T) {
+	t.Parallel()
+
+	paths, all := splitFSMonitorPaths([]byte("a.txt\x00dir/\x00\x00"))
+	assert.False(t, all)
+	assert.Equal(t, []string{"a.txt", "dir/"}, paths)
+
+	paths, all = splitFSMonitorPaths([]byte("a.txt\x00/\x00"))
+	assert.True(t, all)
+	assert.Nil(t, paths)
+}
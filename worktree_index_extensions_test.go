@@ -0,0 +1,73 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIndexExtensionsFromGit checks the extensions git writes, a resolve-undo
+// of a conflict and an entry offset table, are kept as go-git writes the
+// index, and that git reads the entries in parallel through the table.
+func TestIndexExtensionsFromGit(t *testing.T) {
+	t.Parallel()
+	requireGitBinary(t)
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "config", "user.name", "a")
+	git(t, dir, "config", "user.email", "a@example.com")
+	git(t, dir, "config", "index.threads", "4")
+	for i := range 20 {
+		name := filepath.Join(dir, fmt.Sprintf("d%d", i%3), fmt.Sprintf("f%02d", i))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("%d\n", i)), 0o644))
+	}
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+
+	git(t, dir, "checkout", "-q", "-b", "side")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d0", "f03"), []byte("side\n"), 0o644))
+	git(t, dir, "commit", "-q", "-am", "side")
+	git(t, dir, "checkout", "-q", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d0", "f03"), []byte("main\n"), 0o644))
+	git(t, dir, "commit", "-q", "-am", "main")
+	_, _ = gitAllowFail(t, dir, "merge", "side")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d0", "f03"), []byte("merged\n"), 0o644))
+	git(t, dir, "add", "d0/f03")
+	git(t, dir, "update-index", "--index-version", "4")
+
+	resolveUndo := git(t, dir, "ls-files", "--resolve-undo")
+	require.Contains(t, resolveUndo, "d0/f03")
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	require.NotNil(t, idx.EntryOffsetTable)
+	assert.Len(t, idx.EntryOffsetTable.Blocks, 4)
+
+	require.NoError(t, util.WriteFile(w.Filesystem(), "d1/new", []byte("new\n"), 0o644))
+	_, err = w.Add("d1/new")
+	require.NoError(t, err)
+
+	idx, err = r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), idx.Version)
+	require.NotNil(t, idx.EntryOffsetTable)
+	assert.Len(t, idx.EntryOffsetTable.Blocks, 4)
+	require.NotNil(t, idx.ResolveUndo)
+
+	assert.Equal(t, resolveUndo, git(t, dir, "ls-files", "--resolve-undo"))
+	assert.Equal(t, "M  d0/f03\nA  d1/new\n", git(t, dir, "status", "--porcelain"))
+	assert.Contains(t, git(t, dir, "ls-files"), "d0/f03\nd0/f06\n")
+	assert.Equal(t, git(t, dir, "-c", "index.threads=1", "ls-files", "-s"), git(t, dir, "ls-files", "-s"))
+}
@@ -0,0 +1,643 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// sparseCheckoutFile is the file, in the git directory, holding the patterns
+// of the files checked out in a sparse checkout.
+const sparseCheckoutFile = "info/sparse-checkout"
+
+var (
+	// ErrSparseCheckoutNotEnabled is returned when patterns are added to the
+	// sparse checkout of a worktree that is not sparse.
+	ErrSparseCheckoutNotEnabled = errors.New("sparse checkout is not enabled")
+	// ErrSparseCheckoutConePattern is returned when a pattern given in cone
+	// mode is not a directory.
+	ErrSparseCheckoutConePattern = errors.New("specify directories rather than patterns")
+	// ErrSparseCheckoutStorage is returned when the repository storage has
+	// no git directory to hold the sparse-checkout patterns.
+	ErrSparseCheckoutStorage = errors.New("sparse checkout requires a filesystem storage")
+)
+
+// SparseCheckout manages the sparse checkout of a worktree, as
+// git sparse-checkout does: the patterns of the files in the worktree are
+// kept in $GIT_DIR/info/sparse-checkout, and the files out of them are left
+// out of the worktree, with the skip-worktree bit of their index entry set.
+//
+// In cone mode, the default, the patterns are directories: the files in them
+// and their subdirectories are checked out, along with the files directly
+// in their parent directories. Otherwise they are gitignore-style patterns
+// of the files to check out.
+type SparseCheckout struct {
+	w *Worktree
+}
+
+// SparseCheckout returns the manager of the sparse checkout of the worktree.
+func (w *Worktree) SparseCheckout() *SparseCheckout {
+	return &SparseCheckout{w: w}
+}
+
+// IsEnabled returns whether the worktree is a sparse checkout,
+// core.sparseCheckout being true.
+func (s *SparseCheckout) IsEnabled() (bool, error) {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.Core.SparseCheckout.IsTrue(), nil
+}
+
+// Init enables the sparse checkout of the worktree, as
+// git sparse-checkout init. If there are no patterns yet, only the files at
+// the root of the worktree are checked out.
+func (s *SparseCheckout) Init(opts *SparseCheckoutOptions) error {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	lines, err := s.readPatterns()
+	if errors.Is(err, os.ErrNotExist) {
+		lines = conePatterns(nil)
+	} else if err != nil {
+		return err
+	}
+
+	return s.update(cfg, lines, opts)
+}
+
+// Set replaces the patterns of the sparse checkout by patterns and enables
+// it, as git sparse-checkout set. If opts is nil, the mode of the sparse
+// checkout is kept, cone mode being used if it was not enabled.
+func (s *SparseCheckout) Set(patterns []string, opts *SparseCheckoutOptions) error {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if !sparseCheckoutCone(cfg, opts) {
+		return s.update(cfg, patterns, opts)
+	}
+
+	dirs, err := coneDirs(patterns)
+	if err != nil {
+		return err
+	}
+
+	return s.update(cfg, conePatterns(dirs), opts)
+}
+
+// Add adds patterns to those of the sparse checkout, as
+// git sparse-checkout add. It returns ErrSparseCheckoutNotEnabled if the
+// worktree is not a sparse checkout.
+func (s *SparseCheckout) Add(patterns ...string) error {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Core.SparseCheckout.IsTrue() {
+		return ErrSparseCheckoutNotEnabled
+	}
+
+	lines, err := s.readPatterns()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if !cfg.Core.SparseCheckoutCone.IsTrue() {
+		return s.update(cfg, append(lines, patterns...), nil)
+	}
+
+	dirs, err := coneDirs(patterns)
+	if err != nil {
+		return err
+	}
+
+	p := parseSparsePatterns(lines, true)
+	if !p.cone {
+		return errors.New("existing sparse-checkout patterns do not use cone mode")
+	}
+	for dir := range p.recursive {
+		dirs = append(dirs, dir)
+	}
+
+	return s.update(cfg, conePatterns(dirs), nil)
+}
+
+// List returns the patterns of the sparse checkout, as
+// git sparse-checkout list: in cone mode, the directories checked out.
+func (s *SparseCheckout) List() ([]string, error) {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Core.SparseCheckout.IsTrue() {
+		return nil, ErrSparseCheckoutNotEnabled
+	}
+
+	lines, err := s.readPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	p := parseSparsePatterns(lines, cfg.Core.SparseCheckoutCone.IsTrue())
+	if !p.cone {
+		return lines, nil
+	}
+
+	dirs := make([]string, 0, len(p.recursive))
+	for dir := range p.recursive {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	return dirs, nil
+}
+
+// Reapply applies the patterns of the sparse checkout again to the
+// worktree, as git sparse-checkout reapply: the files they now include are
+// checked out, and the unmodified ones they exclude removed.
+func (s *SparseCheckout) Reapply() error {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Core.SparseCheckout.IsTrue() {
+		return ErrSparseCheckoutNotEnabled
+	}
+
+	p, err := s.w.sparseCheckoutPatterns(cfg)
+	if err != nil {
+		return err
+	}
+
+	return s.w.updateSparseCheckout(cfg, p)
+}
+
+// Disable checks out all the files of the worktree and disables its sparse
+// checkout, as git sparse-checkout disable. The patterns are kept.
+func (s *SparseCheckout) Disable() error {
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.SparseCheckout = config.OptBoolFalse
+	cfg.Core.SparseCheckoutCone = config.OptBoolFalse
+	cfg.Index.Sparse = config.OptBoolFalse
+	if err := s.w.updateSparseCheckout(cfg, nil); err != nil {
+		return err
+	}
+
+	return s.w.r.SetConfig(cfg)
+}
+
+// update checks out the worktree to the sparse-checkout patterns lines, and
+// then records them, with the mode of the sparse checkout in cfg.
+func (s *SparseCheckout) update(cfg *config.Config, lines []string, opts *SparseCheckoutOptions) error {
+	cone := sparseCheckoutCone(cfg, opts)
+	cfg.Core.SparseCheckout = config.OptBoolTrue
+	cfg.Core.SparseCheckoutCone = config.NewOptBool(cone)
+	if opts != nil && opts.SparseIndex {
+		cfg.Index.Sparse = config.OptBoolTrue
+	}
+
+	fs, err := s.w.r.gitDirFilesystem()
+	if err != nil {
+		return err
+	}
+
+	if err := s.w.updateSparseCheckout(cfg, parseSparsePatterns(lines, cone)); err != nil {
+		return err
+	}
+
+	if err := writeSparsePatterns(fs, lines); err != nil {
+		return err
+	}
+
+	return s.w.r.SetConfig(cfg)
+}
+
+func (s *SparseCheckout) readPatterns() ([]string, error) {
+	fs, err := s.w.r.gitDirFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := util.ReadFile(fs, sparseCheckoutFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return readSparsePatternLines(b)
+}
+
+func writeSparsePatterns(fs billy.Filesystem, lines []string) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+
+	if err := fs.MkdirAll(path.Dir(sparseCheckoutFile), 0o755); err != nil {
+		return err
+	}
+
+	return util.WriteFile(fs, sparseCheckoutFile, buf.Bytes(), 0o644)
+}
+
+// gitDirFilesystem returns the filesystem of the git directory of the
+// repository, or ErrSparseCheckoutStorage if it is not stored in one.
+func (r *Repository) gitDirFilesystem() (billy.Filesystem, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+	st, ok := unwrapPromisor(r.Storer).(fsBased)
+	if !ok {
+		return nil, ErrSparseCheckoutStorage
+	}
+
+	return st.Filesystem(), nil
+}
+
+// sparseCheckoutCone returns whether the patterns of a sparse checkout
+// enabled with opts are those of cone mode: as asked by opts, or, if nil,
+// as they were, cone mode being the default.
+func sparseCheckoutCone(cfg *config.Config, opts *SparseCheckoutOptions) bool {
+	switch {
+	case opts != nil:
+		return !opts.NoCone
+	case cfg.Core.SparseCheckout.IsTrue():
+		return cfg.Core.SparseCheckoutCone.IsTrue()
+	}
+
+	return true
+}
+
+// readSparsePatternLines returns the patterns of a sparse-checkout file,
+// without its blank lines and comments.
+func readSparsePatternLines(b []byte) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, sc.Err()
+}
+
+// coneDirs returns the directories of the cone-mode patterns, cleaned, as
+// git checks them.
+func coneDirs(patterns []string) ([]string, error) {
+	dirs := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(p, "/"):
+			return nil, fmt.Errorf("%w (no leading slash): %s", ErrSparseCheckoutConePattern, p)
+		case strings.HasPrefix(p, "!"), strings.ContainsAny(p, `*?[]\`):
+			return nil, fmt.Errorf("%w: %s", ErrSparseCheckoutConePattern, p)
+		}
+
+		if dir := path.Clean(p); dir != "." {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
+// conePatterns returns the sparse-checkout patterns of cone mode for the
+// directories dirs, in the order git writes them: the files at the root,
+// those directly in the parent directories of dirs, and then all the files
+// of dirs, those in a directory of dirs being left out.
+func conePatterns(dirs []string) []string {
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+
+	recursive := make([]string, 0, len(dirs))
+	parents := make(map[string]struct{})
+	for _, dir := range dirs {
+		if hasAncestor(dir, dirs) {
+			continue
+		}
+
+		recursive = append(recursive, dir)
+		for p := path.Dir(dir); p != "."; p = path.Dir(p) {
+			parents[p] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(parents))
+	for p := range parents {
+		sorted = append(sorted, p)
+	}
+	slices.Sort(sorted)
+
+	lines := []string{"/*", "!/*/"}
+	for _, p := range sorted {
+		p = escapeConeDir(p)
+		lines = append(lines, "/"+p+"/", "!/"+p+"/*/")
+	}
+	for _, dir := range recursive {
+		lines = append(lines, "/"+escapeConeDir(dir)+"/")
+	}
+
+	return lines
+}
+
+// hasAncestor returns whether one of the sorted directories dirs is a parent
+// directory of dir.
+func hasAncestor(dir string, dirs []string) bool {
+	for p := path.Dir(dir); p != "."; p = path.Dir(p) {
+		if _, ok := slices.BinarySearch(dirs, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func escapeConeDir(dir string) string {
+	var b strings.Builder
+	for _, c := range dir {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// unescapeConeDir returns the directory of a cone-mode pattern, and false
+// if it has a wildcard.
+func unescapeConeDir(p string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteByte(p[i])
+		case strings.IndexByte(`*?[\`, c) >= 0:
+			return "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), b.Len() > 0
+}
+
+// sparsePatterns are the patterns of a sparse checkout.
+type sparsePatterns struct {
+	// cone is whether the patterns are those of cone mode, their recursive
+	// directories being checked out with all their files, and their parent
+	// directories with the files directly in them.
+	cone      bool
+	recursive map[string]struct{}
+	parents   map[string]struct{}
+
+	matcher gitignore.Matcher
+}
+
+// parseSparsePatterns parses the sparse-checkout patterns lines. As git,
+// the patterns not of cone mode are matched as gitignore-style patterns.
+func parseSparsePatterns(lines []string, cone bool) *sparsePatterns {
+	ps := make([]gitignore.Pattern, 0, len(lines))
+	for _, l := range lines {
+		ps = append(ps, gitignore.ParsePattern(l, nil))
+	}
+
+	p := &sparsePatterns{matcher: gitignore.NewMatcher(ps)}
+	if cone {
+		p.cone = p.parseCone(lines)
+	}
+
+	return p
+}
+
+func (p *sparsePatterns) parseCone(lines []string) bool {
+	p.recursive = make(map[string]struct{})
+	p.parents = make(map[string]struct{})
+	for _, l := range lines {
+		switch {
+		case l == "/*" || l == "!/*/":
+		case strings.HasPrefix(l, "!/") && strings.HasSuffix(l, "/*/") && len(l) > 5:
+			dir, ok := unescapeConeDir(l[2 : len(l)-3])
+			if _, recursive := p.recursive[dir]; !ok || !recursive {
+				return false
+			}
+			delete(p.recursive, dir)
+			p.parents[dir] = struct{}{}
+		case strings.HasPrefix(l, "/") && strings.HasSuffix(l, "/") && len(l) > 2:
+			dir, ok := unescapeConeDir(l[1 : len(l)-1])
+			if !ok {
+				return false
+			}
+			p.recursive[dir] = struct{}{}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// includes returns whether the file name is in the sparse checkout. All
+// files are if p is nil.
+func (p *sparsePatterns) includes(name string) bool {
+	if p == nil {
+		return true
+	}
+
+	if !p.cone {
+		return p.matcher.Match(strings.Split(name, "/"), false)
+	}
+
+	dir := path.Dir(name)
+	if dir == "." {
+		return true
+	}
+	if _, ok := p.parents[dir]; ok {
+		return true
+	}
+	for ; dir != "."; dir = path.Dir(dir) {
+		if _, ok := p.recursive[dir]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// includesDir returns whether files of the directory dir, ending with a
+// slash, may be in the sparse checkout.
+func (p *sparsePatterns) includesDir(dir string) bool {
+	if p == nil || !p.cone {
+		return true
+	}
+
+	for d := path.Dir(dir); d != "."; d = path.Dir(d) {
+		if _, ok := p.recursive[d]; ok {
+			return true
+		}
+	}
+
+	for _, set := range []map[string]struct{}{p.recursive, p.parents} {
+		for d := range set {
+			if strings.HasPrefix(d+"/", dir) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sparseCheckoutPatterns returns the patterns of the sparse checkout of the
+// worktree, as configured in cfg, or nil if it is not a sparse checkout or
+// has no patterns.
+func (w *Worktree) sparseCheckoutPatterns(cfg *config.Config) (*sparsePatterns, error) {
+	if !cfg.Core.SparseCheckout.IsTrue() {
+		return nil, nil
+	}
+
+	fs, err := w.r.gitDirFilesystem()
+	if errors.Is(err, ErrSparseCheckoutStorage) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := util.ReadFile(fs, sparseCheckoutFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := readSparsePatternLines(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSparsePatterns(lines, cfg.Core.SparseCheckoutCone.IsTrue()), nil
+}
+
+// updateSparseCheckout sets the skip-worktree bits of the index entries to
+// the sparse-checkout patterns p, and updates the worktree with them: the
+// files now in the sparse checkout are checked out, and those now out of it
+// removed. As git, the modified files and the conflicts are kept.
+func (w *Worktree) updateSparseCheckout(cfg *config.Config, p *sparsePatterns) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if err := w.expandSparseDirs(idx, p.includesDir); err != nil {
+		return err
+	}
+
+	fs, closeFS := w.reusableRootFS()
+	defer closeFS()
+
+	attrs := w.newAttributes(cfg, w.indexAttributesSource(idx), w.worktreeAttributesSource())
+	defer attrs.close()
+
+	for i, e := range idx.Entries {
+		if e.IsSparseDir() || e.Stage != 0 {
+			continue
+		}
+
+		include := p.includes(e.Name)
+		switch {
+		case include && e.SkipWorktree:
+			if idx.Entries[i], err = w.checkoutSparseEntry(attrs, fs, e); err != nil {
+				return err
+			}
+		case !include && !e.SkipWorktree:
+			if st, ok := status[e.Name]; ok && st.Worktree != Unmodified {
+				continue
+			}
+
+			if err := rmFileAndDirsIfEmpty(fs, e.Name); err != nil {
+				return err
+			}
+
+			skipped := *e
+			skipped.SkipWorktree = true
+			idx.Entries[i] = &skipped
+		}
+	}
+
+	if err := w.applySparseIndex(cfg, idx); err != nil {
+		return err
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// checkoutSparseEntry checks out the file of the entry e, left out of the
+// worktree until now, and returns its new entry. A file already in its
+// place is kept.
+func (w *Worktree) checkoutSparseEntry(attrs *attributes, fs *worktreeFilesystem, e *index.Entry) (*index.Entry, error) {
+	_, err := fs.Lstat(e.Name)
+	if err == nil || e.Mode == filemode.Submodule {
+		if e.Mode == filemode.Submodule {
+			if err := fs.MkdirAll(e.Name, 0o755); err != nil {
+				return nil, err
+			}
+		}
+
+		included := *e
+		included.SkipWorktree = false
+		return &included, nil
+	}
+
+	blob, err := w.r.BlobObject(e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.checkoutFile(attrs, fs, object.NewFile(e.Name, e.Mode, blob)); err != nil {
+		return nil, err
+	}
+
+	return newIndexEntryFromFile(fs, e.Name, e.Hash)
+}
@@ -0,0 +1,228 @@
+package git
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// newGitSparseCheckoutRepository builds with git a repository to be sparsely
+// checked out.
+func newGitSparseCheckoutRepository(t *testing.T) string {
+	t.Helper()
+
+	return newGitRepositoryWithFiles(t, map[string]string{
+		"a/x":         "1\n",
+		"a/b/y":       "2\n",
+		"a/b/c/z":     "3\n",
+		"a/b/c/q/w":   "4\n",
+		"a/e/v":       "5\n",
+		"d/u":         "6\n",
+		"e/s":         "7\n",
+		"f g/t":       "8\n",
+		"docs/README": "9\n",
+		"README.md":   "r\n",
+		"root.txt":    "r\n",
+	})
+}
+
+// worktreeFiles returns the files of the worktree dir.
+func worktreeFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	return files
+}
+
+func TestSparseCheckoutFromGit(t *testing.T) {
+	t.Parallel()
+	src := newGitSparseCheckoutRepository(t)
+
+	gitDir := filepath.Join(t.TempDir(), "git")
+	goDir := filepath.Join(t.TempDir(), "go")
+	git(t, src, "clone", "-q", src, gitDir)
+	git(t, src, "clone", "-q", src, goDir)
+
+	r, err := PlainOpen(goDir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	sc := w.SparseCheckout()
+
+	assertSameAsGit := func(list string) {
+		t.Helper()
+
+		gitPatterns, err := os.ReadFile(filepath.Join(gitDir, ".git", "info", "sparse-checkout"))
+		require.NoError(t, err)
+		goPatterns, err := os.ReadFile(filepath.Join(goDir, ".git", "info", "sparse-checkout"))
+		require.NoError(t, err)
+		assert.Equal(t, string(gitPatterns), string(goPatterns))
+
+		assert.Equal(t, git(t, gitDir, "ls-files", "-t"), git(t, goDir, "ls-files", "-t"))
+		assert.Equal(t, worktreeFiles(t, gitDir), worktreeFiles(t, goDir))
+		assert.Equal(t, "", git(t, goDir, "status", "--porcelain"))
+
+		if list != "" {
+			assert.Equal(t, list, git(t, gitDir, "sparse-checkout", "list"))
+			patterns, err := sc.List()
+			require.NoError(t, err)
+			assert.Equal(t, list, strings.Join(patterns, "\n")+"\n")
+		}
+	}
+
+	git(t, gitDir, "sparse-checkout", "set", "a/b/c", "d", "a/b/c/q", "f g")
+	require.NoError(t, sc.Set([]string{"a/b/c", "d", "a/b/c/q", "f g"}, nil))
+	assertSameAsGit("a/b/c\nd\nf g\n")
+
+	patterns, err := os.ReadFile(filepath.Join(goDir, ".git", "info", "sparse-checkout"))
+	require.NoError(t, err)
+	assert.Equal(t, "/*\n!/*/\n/a/\n!/a/*/\n/a/b/\n!/a/b/*/\n/a/b/c/\n/d/\n/f g/\n", string(patterns))
+
+	git(t, gitDir, "sparse-checkout", "add", "e", "a")
+	require.NoError(t, sc.Add("e", "a"))
+	assertSameAsGit("a\nd\ne\nf g\n")
+
+	git(t, gitDir, "sparse-checkout", "set", "--no-cone", "/*", "!/*/", "*.md", "/a/b/")
+	require.NoError(t, sc.Set([]string{"/*", "!/*/", "*.md", "/a/b/"}, &SparseCheckoutOptions{NoCone: true}))
+	assertSameAsGit("/*\n!/*/\n*.md\n/a/b/\n")
+
+	git(t, gitDir, "sparse-checkout", "disable")
+	require.NoError(t, sc.Disable())
+	assertSameAsGit("")
+
+	enabled, err := sc.IsEnabled()
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.Equal(t, "false", strings.TrimSpace(git(t, goDir, "config", "core.sparseCheckout")))
+}
+
+func TestSparseCheckoutDetectedOnOpen(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseCheckoutRepository(t)
+
+	git(t, dir, "checkout", "-q", "-b", "side")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "x"), []byte("side\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "u"), []byte("side\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "new"), []byte("new\n"), 0o644))
+	git(t, dir, "add", ".")
+	git(t, dir, "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "-m", "side")
+	git(t, dir, "checkout", "-q", "main")
+	git(t, dir, "sparse-checkout", "set", "a")
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	enabled, err := w.SparseCheckout().IsEnabled()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	patterns, err := w.SparseCheckout().List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, patterns)
+
+	require.NoError(t, w.Checkout(&CheckoutOptions{Branch: plumbing.NewBranchReferenceName("side")}))
+
+	assert.Equal(t, []string{"README.md", "a/b/c/q/w", "a/b/c/z", "a/b/y", "a/e/v", "a/x", "root.txt"}, worktreeFiles(t, dir))
+	content, err := os.ReadFile(filepath.Join(dir, "a", "x"))
+	require.NoError(t, err)
+	assert.Equal(t, "side\n", string(content))
+
+	assert.Contains(t, git(t, dir, "ls-files", "-t"), "S d/new\nS d/u\n")
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+}
+
+func TestSparseCheckoutKeepsModifiedFiles(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseCheckoutRepository(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "u"), []byte("changed\n"), 0o644))
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, w.SparseCheckout().Set([]string{"a"}, nil))
+	assert.Equal(t, []string{"README.md", "a/b/c/q/w", "a/b/c/z", "a/b/y", "a/e/v", "a/x", "d/u", "root.txt"}, worktreeFiles(t, dir))
+	assert.Equal(t, " M d/u\n", git(t, dir, "status", "--porcelain"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "u"), []byte("6\n"), 0o644))
+	require.NoError(t, w.SparseCheckout().Reapply())
+	assert.NotContains(t, worktreeFiles(t, dir), "d/u")
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+}
+
+func TestSparseCheckoutErrors(t *testing.T) {
+	t.Parallel()
+
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, "a/x", []byte("x\n"), 0o644))
+	_, err = w.Add("a/x")
+	require.NoError(t, err)
+
+	sc := w.SparseCheckout()
+	assert.ErrorIs(t, sc.Add("a"), ErrSparseCheckoutNotEnabled)
+	assert.ErrorIs(t, sc.Set([]string{"a*"}, nil), ErrSparseCheckoutConePattern)
+	assert.ErrorIs(t, sc.Set([]string{"/a"}, nil), ErrSparseCheckoutConePattern)
+	assert.ErrorIs(t, sc.Set([]string{"!a"}, nil), ErrSparseCheckoutConePattern)
+	assert.ErrorIs(t, sc.Set([]string{"a"}, nil), ErrSparseCheckoutStorage)
+}
+
+func TestConePatterns(t *testing.T) {
+	t.Parallel()
+
+	dirs, err := coneDirs([]string{"a/b/c", "./d/", "a/b/c/q", "", "f g"})
+	require.NoError(t, err)
+	lines := conePatterns(dirs)
+	assert.Equal(t, []string{"/*", "!/*/", "/a/", "!/a/*/", "/a/b/", "!/a/b/*/", "/a/b/c/", "/d/", "/f g/"}, lines)
+
+	p := parseSparsePatterns(lines, true)
+	require.True(t, p.cone)
+	for name, included := range map[string]bool{
+		"root":        true,
+		"a/x":         true,
+		"a/e/v":       false,
+		"a/b/y":       true,
+		"a/b/c/q/w/z": true,
+		"d/u":         true,
+		"e/s":         false,
+	} {
+		assert.Equal(t, included, p.includes(name), name)
+	}
+
+	assert.True(t, p.includesDir("a/"))
+	assert.True(t, p.includesDir("a/b/c/q/"))
+	assert.False(t, p.includesDir("a/e/"))
+
+	assert.False(t, parseSparsePatterns([]string{"/*", "!/*/", "*.md"}, true).cone)
+}
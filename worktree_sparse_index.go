@@ -0,0 +1,269 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie/noder"
+)
+
+// A sparse index, as written by git with index.sparse, holds a single entry
+// for each directory out of a cone-mode sparse checkout: a sparse directory
+// entry, whose hash is that of the tree of the directory. go-git reads the
+// trees of those directories only as their files are needed.
+
+// expandSparseDirs replaces the sparse directory entries of idx for which
+// match returns true by the entries of the files of their tree, which are
+// left out of the worktree.
+func (w *Worktree) expandSparseDirs(idx *index.Index, match func(dir string) bool) error {
+	if !idx.IsSparse() {
+		return nil
+	}
+
+	entries := make([]*index.Entry, 0, len(idx.Entries))
+	var expanded bool
+	for _, e := range idx.Entries {
+		if !e.IsSparseDir() || !match(e.Name) {
+			entries = append(entries, e)
+			continue
+		}
+
+		t, err := object.GetTree(w.r.Storer, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		walker := object.NewTreeWalker(t, true, nil)
+		for {
+			name, te, err := walker.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				walker.Close()
+				return err
+			}
+
+			if te.Mode == filemode.Dir {
+				continue
+			}
+
+			entries = append(entries, &index.Entry{
+				Name:         e.Name + name,
+				Hash:         te.Hash,
+				Mode:         te.Mode,
+				SkipWorktree: true,
+			})
+		}
+		walker.Close()
+		expanded = true
+	}
+
+	if expanded {
+		sortEntriesByName(entries)
+		idx.Entries = entries
+	}
+
+	return nil
+}
+
+// sparseDirChildren returns the nodes of the tree of the sparse directory
+// entry e.
+func (w *Worktree) sparseDirChildren(e *index.Entry) ([]noder.Noder, error) {
+	t, err := object.GetTree(w.r.Storer, e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewTreeRootNode(t).Children()
+}
+
+// expandSparseDirsFor expands the sparse directory entries of idx the paths
+// are in, and those below the paths found in the worktree: the entries of
+// their files are needed to change them.
+func (w *Worktree) expandSparseDirsFor(idx *index.Index, paths ...string) error {
+	return w.expandSparseDirs(idx, func(dir string) bool {
+		for _, p := range paths {
+			p = strings.Trim(p, "/")
+			if p != "." && p != "" && strings.HasPrefix(p+"/", dir) {
+				return true
+			}
+
+			if p == "." || p == "" || strings.HasPrefix(dir, p+"/") {
+				if _, err := w.filesystem.Lstat(strings.TrimSuffix(dir, "/")); err == nil {
+					return true
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+// updateSparseDirs updates the sparse directory entries of idx to t, as a
+// whole rather than by the changes of their files, and expands those
+// the sparse checkout directories dirs now include.
+func (w *Worktree) updateSparseDirs(idx *index.Index, t *object.Tree, dirs []string) error {
+	if !idx.IsSparse() {
+		return nil
+	}
+
+	entries := make([]*index.Entry, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if !e.IsSparseDir() {
+			entries = append(entries, e)
+			continue
+		}
+
+		te, err := t.FindEntry(strings.TrimSuffix(e.Name, "/"))
+		switch {
+		case errors.Is(err, object.ErrEntryNotFound), errors.Is(err, object.ErrDirectoryNotFound):
+			continue
+		case err != nil:
+			return err
+		case te.Mode == filemode.Dir:
+			entries = append(entries, &index.Entry{
+				Name:         e.Name,
+				Hash:         te.Hash,
+				Mode:         filemode.Dir,
+				SkipWorktree: true,
+			})
+		default:
+			entries = append(entries, &index.Entry{
+				Name:         strings.TrimSuffix(e.Name, "/"),
+				Hash:         te.Hash,
+				Mode:         te.Mode,
+				SkipWorktree: true,
+			})
+		}
+	}
+
+	sortEntriesByName(entries)
+	idx.Entries = entries
+
+	return w.expandSparseDirs(idx, func(dir string) bool {
+		for _, d := range dirs {
+			if strings.HasPrefix(dir, d) || strings.HasPrefix(d, dir) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// sortEntriesByName sorts entries as the entries of an index, the stages of
+// an entry being left in their order.
+func sortEntriesByName(entries []*index.Entry) {
+	slices.SortStableFunc(entries, func(a, b *index.Entry) int { return strings.Compare(a.Name, b.Name) })
+}
+
+// applySparseIndex writes idx as a sparse index, or as a full one, as
+// index.sparse in cfg asks. Unset, the index is left as it is. As git, a
+// sparse checkout not in cone mode has a full index.
+func (w *Worktree) applySparseIndex(cfg *config.Config, idx *index.Index) error {
+	cone := cfg.Core.SparseCheckoutCone
+	switch {
+	case cfg.Index.Sparse.IsTrue() && (cone.IsTrue() || !cone.IsSet()):
+		return w.collapseSparseDirs(idx)
+	case cfg.Index.Sparse.IsSet():
+		return w.expandSparseDirs(idx, func(string) bool { return true })
+	}
+
+	return nil
+}
+
+// collapseSparseDirs replaces the entries of the directories entirely out
+// of the sparse checkout, and out of the worktree, by a sparse directory
+// entry. As git, a directory is only collapsed if its tree is stored.
+func (w *Worktree) collapseSparseDirs(idx *index.Index) error {
+	// The entries of the directories have to be next to each other, which
+	// the index builder does not keep.
+	sortEntriesByName(idx.Entries)
+
+	entries, _, _, err := w.collapseSparseDir("", idx.Entries)
+	if err != nil {
+		return err
+	}
+
+	idx.Entries = entries
+	return nil
+}
+
+// collapseSparseDir returns entries, those of the files below dir, with the
+// directories that can be collapsed replaced by a sparse directory entry,
+// and whether dir itself can be, with the hash of its tree.
+func (w *Worktree) collapseSparseDir(dir string, entries []*index.Entry) ([]*index.Entry, plumbing.Hash, bool, error) {
+	out := make([]*index.Entry, 0, len(entries))
+	tree := &object.Tree{}
+	collapsible := true
+
+	for i := 0; i < len(entries); {
+		e := entries[i]
+		name := strings.TrimPrefix(e.Name, dir)
+
+		slash := strings.IndexByte(name, '/')
+		if slash < 0 || e.IsSparseDir() && slash == len(name)-1 {
+			if e.IsSparseDir() {
+				tree.Entries = append(tree.Entries, object.TreeEntry{Name: name[:slash], Mode: filemode.Dir, Hash: e.Hash})
+			} else {
+				collapsible = collapsible && e.Stage == 0 && e.SkipWorktree && !e.IntentToAdd && !e.Hash.IsZero()
+				tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: e.Mode, Hash: e.Hash})
+			}
+
+			out = append(out, e)
+			i++
+			continue
+		}
+
+		sub := dir + name[:slash+1]
+		j := i + 1
+		for j < len(entries) && strings.HasPrefix(entries[j].Name, sub) {
+			j++
+		}
+
+		subEntries, h, ok, err := w.collapseSparseDir(sub, entries[i:j])
+		if err != nil {
+			return nil, plumbing.ZeroHash, false, err
+		}
+
+		if ok {
+			ok = w.r.Storer.HasEncodedObject(h) == nil
+		}
+		if ok {
+			if _, err := w.filesystem.Lstat(strings.TrimSuffix(sub, "/")); !errors.Is(err, os.ErrNotExist) {
+				ok = false
+			}
+		}
+
+		if ok {
+			out = append(out, &index.Entry{Name: sub, Hash: h, Mode: filemode.Dir, SkipWorktree: true})
+		} else {
+			out = append(out, subEntries...)
+		}
+
+		collapsible = collapsible && ok
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name[:slash], Mode: filemode.Dir, Hash: h})
+		i = j
+	}
+
+	if !collapsible || dir == "" {
+		return out, plumbing.ZeroHash, false, nil
+	}
+
+	sort.Sort(sortableEntries(tree.Entries))
+	o := w.r.Storer.NewEncodedObject()
+	if err := tree.Encode(o); err != nil {
+		return nil, plumbing.ZeroHash, false, err
+	}
+
+	return out, o.Hash(), true, nil
+}
@@ -0,0 +1,228 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+// newGitRepositoryWithFiles builds with git a repository with a commit of
+// files, which maps the paths of the files to their content.
+func newGitRepositoryWithFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	requireGitBinary(t)
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	git(t, dir, "add", ".")
+	git(t, dir, "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "-m", "init")
+
+	return dir
+}
+
+// newGitSparseIndexRepository builds with git a repository whose sparse
+// checkout includes a, written as a sparse index: b and d are a sparse
+// directory entry each.
+func newGitSparseIndexRepository(t *testing.T) string {
+	t.Helper()
+
+	dir := newGitRepositoryWithFiles(t, map[string]string{
+		"a/x":      "1\n",
+		"b/y":      "2\n",
+		"b/c/z":    "3\n",
+		"d/w":      "4\n",
+		"root.txt": "r\n",
+	})
+	if out, ok := gitAllowFail(t, dir, "sparse-checkout", "init", "--cone", "--sparse-index"); !ok {
+		t.Skipf("git has no sparse index: %s", out)
+	}
+	git(t, dir, "sparse-checkout", "set", "a")
+
+	return dir
+}
+
+func sparseDirNames(idx *index.Index) []string {
+	var names []string
+	for _, e := range idx.Entries {
+		if e.IsSparseDir() {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+func TestSparseIndexStatusAndCommit(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseIndexRepository(t)
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b/", "d/"}, sparseDirNames(idx))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+
+	require.NoError(t, util.WriteFile(w.Filesystem(), "a/x", []byte("changed\n"), 0o644))
+	_, err = w.Add("a/x")
+	require.NoError(t, err)
+	_, err = w.Commit("change", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+	assert.Equal(t, "a/x\nb/c/z\nb/y\nd/w\nroot.txt\n", git(t, dir, "ls-tree", "-r", "--name-only", "HEAD"))
+	assert.Contains(t, git(t, dir, "ls-files", "--sparse"), "d/\n")
+}
+
+func TestSparseIndexStatusStagedInSparseDir(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseIndexRepository(t)
+
+	// The index is left with d/w changed from HEAD, in the sparse
+	// directory d/.
+	git(t, dir, "sparse-checkout", "set", "a", "d")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "w"), []byte("5\n"), 0o644))
+	git(t, dir, "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "-am", "change")
+	git(t, dir, "sparse-checkout", "set", "a")
+	git(t, dir, "reset", "-q", "--soft", "HEAD~")
+	require.Contains(t, git(t, dir, "ls-files", "--sparse"), "d/\n")
+	require.Equal(t, "M  d/w\n", git(t, dir, "status", "--porcelain"))
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	require.Len(t, status, 1, status)
+	assert.Equal(t, Modified, status.File("d/w").Staging)
+	assert.Equal(t, Unmodified, status.File("d/w").Worktree)
+
+	require.NoError(t, w.Reset(&ResetOptions{Mode: MixedReset}))
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status)
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+}
+
+func TestSparseIndexCheckoutExpands(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseIndexRepository(t)
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, w.Checkout(&CheckoutOptions{
+		Branch:                    "refs/heads/main",
+		Force:                     true,
+		SparseCheckoutDirectories: []string{"a", "b"},
+	}))
+
+	for _, name := range []string{"a/x", "b/y", "b/c/z"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+	}
+	_, err = os.Stat(filepath.Join(dir, "d"))
+	assert.True(t, os.IsNotExist(err))
+
+	// With index.sparse, the directories out of the sparse checkout are
+	// kept collapsed.
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d/"}, sparseDirNames(idx))
+
+	require.NoError(t, w.Checkout(&CheckoutOptions{
+		Branch:                    "refs/heads/main",
+		Force:                     true,
+		SparseCheckoutDirectories: []string{"a"},
+	}))
+
+	idx, err = r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b/", "d/"}, sparseDirNames(idx))
+	_, err = os.Stat(filepath.Join(dir, "b"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Contains(t, git(t, dir, "ls-files", "--sparse", "-s"), "1c3dc321a028103bf2947e46dc366005060f5497 0\tb/\n")
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+}
+
+func TestSparseIndexAddExpands(t *testing.T) {
+	t.Parallel()
+	dir := newGitSparseIndexRepository(t)
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(w.Filesystem(), "d/new", []byte("new\n"), 0o644))
+	_, err = w.Add("d/new")
+	require.NoError(t, err)
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b/"}, sparseDirNames(idx))
+
+	e, err := idx.Entry("d/w")
+	require.NoError(t, err)
+	assert.True(t, e.SkipWorktree)
+	e, err = idx.Entry("d/new")
+	require.NoError(t, err)
+	assert.False(t, e.SkipWorktree)
+}
+
+func TestSplitIndexFromGit(t *testing.T) {
+	t.Parallel()
+	requireGitBinary(t)
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b\n"), 0o644))
+	git(t, dir, "add", ".")
+	git(t, dir, "update-index", "--split-index")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed\n"), 0o644))
+	git(t, dir, "add", "b.txt")
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Added, status.File("a.txt").Staging)
+	assert.Equal(t, Added, status.File("b.txt").Staging)
+	assert.Equal(t, Unmodified, status.File("b.txt").Worktree)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c\n"), 0o644))
+	_, err = w.Add("c.txt")
+	require.NoError(t, err)
+	_, err = w.Commit("init", &CommitOptions{Author: attributesTestSignature})
+	require.NoError(t, err)
+
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	require.NotNil(t, idx.SplitIndex)
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+	assert.Equal(t, "a.txt\nb.txt\nc.txt\n", git(t, dir, "ls-files"))
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -155,9 +156,14 @@ func (w *Worktree) diffStagingWithWorktree(cfg *config.Config, reverse, excludeI
 		return nil, err
 	}
 
+	attrs := w.checkinAttributes(cfg, idx)
+	attrs.hashOnly = true
+	defer attrs.close()
+
 	fsOpts := filesystem.Options{
-		AutoCRLF: cfg.Core.AutoCRLF == "true" || cfg.Core.AutoCRLF == "input",
-		Index:    idx,
+		EOLConversion: attrs.eolConversion,
+		Clean:         attrs.clean,
+		Index:         idx,
 	}
 
 	// When ignored changes are to be filtered out, hand the noder the ignore
@@ -172,16 +178,58 @@ func (w *Worktree) diffStagingWithWorktree(cfg *config.Config, reverse, excludeI
 	// at all. That both removes a second full traversal of the worktree and
 	// keeps an excluded parent authoritative, which a flat pattern list
 	// cannot express.
+	//
+	// The untracked cache records what the walk finds below the directories
+	// that did not change, so it only goes along with the ignore scope.
+	var ucChanged bool
 	if excludeIgnoredChanges {
 		fsOpts.IgnoreScope = w.ignoreScope()
+		fsOpts.UntrackedCache, ucChanged = w.untrackedCache(cfg, idx)
+	}
+
+	monitor := w.fsMonitor(cfg)
+	if monitor != nil {
+		fsOpts.FSMonitor = w.refreshFSMonitor(context.Background(), monitor, idx, fsOpts.UntrackedCache)
 	}
 
 	to := filesystem.NewRootNodeWithOptions(w.filesystem, submodules, fsOpts)
 
+	var changes merkletrie.Changes
 	if reverse {
-		return merkletrie.DiffTree(to, from, diffTreeIsEquals)
+		changes, err = merkletrie.DiffTree(to, from, diffTreeIsEquals)
+	} else {
+		changes, err = merkletrie.DiffTree(from, to, diffTreeIsEquals)
 	}
-	return merkletrie.DiffTree(from, to, diffTreeIsEquals)
+	if err != nil {
+		return nil, err
+	}
+
+	if monitor != nil {
+		markFSMonitorValid(idx, changes)
+	}
+
+	if fsOpts.UntrackedCache != nil || monitor != nil || ucChanged {
+		w.writeIndexCaches(idx)
+	}
+
+	return changes, nil
+}
+
+// writeIndexCaches writes idx back, to keep its untracked cache and file
+// system monitor state for the next diff. It is not written if one of its
+// entries was modified as the index was written, as those are only told
+// apart from later changes by the time of the index; nor is a failure
+// reported, as the caches are only an optimization.
+func (w *Worktree) writeIndexCaches(idx *index.Index) {
+	if !idx.ModTime.IsZero() {
+		for _, e := range idx.Entries {
+			if !e.ModifiedAt.Before(idx.ModTime) {
+				return
+			}
+		}
+	}
+
+	_ = w.r.Storer.SetIndex(idx)
 }
 
 // ignoreScope builds the ignore scope in effect at the root of the worktree:
@@ -260,7 +308,13 @@ func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie
 		return nil, err
 	}
 
-	to := mindex.NewRootNode(idx)
+	// As git, the files out of a sparse checkout are compared as the others,
+	// the sparse directories being compared by the hash of their tree.
+	to := mindex.NewRootNodeWithOptions(idx, mindex.RootNodeOptions{
+		UpholdExecutableBit: true,
+		IgnoreSkipWorktree:  true,
+		SparseDirChildren:   w.sparseDirChildren,
+	})
 
 	if reverse {
 		return merkletrie.DiffTree(to, from, diffTreeIsEquals)
@@ -312,7 +366,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return w.doAdd(path, make([]gitignore.Pattern, 0), false)
 }
 
-func (w *Worktree) doAddDirectory(cfg *config.Config, idx *index.Index, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
+func (w *Worktree) doAddDirectory(attrs *attributes, idx *index.Index, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
 	if len(ignorePattern) > 0 {
 		m := gitignore.NewMatcher(ignorePattern)
 		matchPath := strings.Split(directory, string(os.PathSeparator))
@@ -330,7 +384,7 @@ func (w *Worktree) doAddDirectory(cfg *config.Config, idx *index.Index, s Status
 		}
 
 		var a bool
-		a, _, err = w.doAddFile(cfg, idx, s, name, ignorePattern)
+		a, _, err = w.doAddFile(attrs, idx, s, name, ignorePattern)
 		if err != nil {
 			return added, err
 		}
@@ -418,10 +472,17 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 	}
 	path = filepath.ToSlash(path)
 
+	if err := w.expandSparseDirsFor(idx, path); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	attrs := w.checkinAttributes(cfg, idx)
+	defer attrs.close()
+
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(cfg, idx, s, path, ignorePattern)
+		added, h, err = w.doAddFile(attrs, idx, s, path, ignorePattern)
 	} else {
-		added, err = w.doAddDirectory(cfg, idx, s, path, ignorePattern)
+		added, err = w.doAddDirectory(attrs, idx, s, path, ignorePattern)
 	}
 
 	if err != nil {
@@ -471,6 +532,13 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	if err := w.expandSparseDirsFor(idx, files...); err != nil {
+		return err
+	}
+
+	attrs := w.checkinAttributes(cfg, idx)
+	defer attrs.close()
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.filesystem.Lstat(file)
@@ -480,9 +548,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(cfg, idx, s, file, make([]gitignore.Pattern, 0))
+			added, err = w.doAddDirectory(attrs, idx, s, file, make([]gitignore.Pattern, 0))
 		} else {
-			added, _, err = w.doAddFile(cfg, idx, s, file, make([]gitignore.Pattern, 0))
+			added, _, err = w.doAddFile(attrs, idx, s, file, make([]gitignore.Pattern, 0))
 		}
 
 		if err != nil {
@@ -504,7 +572,7 @@ func (w *Worktree) AddGlob(pattern string) error {
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
 // if s status is nil will skip the status check and update the index anyway
-func (w *Worktree) doAddFile(cfg *config.Config, idx *index.Index, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(attrs *attributes, idx *index.Index, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
 	if s != nil && s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
@@ -517,7 +585,7 @@ func (w *Worktree) doAddFile(cfg *config.Config, idx *index.Index, s Status, pat
 		}
 	}
 
-	h, err = w.copyFileToStorage(cfg, path)
+	h, err = w.copyFileToStorage(attrs, path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -534,7 +602,7 @@ func (w *Worktree) doAddFile(cfg *config.Config, idx *index.Index, s Status, pat
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(cfg *config.Config, path string) (hash plumbing.Hash, err error) {
+func (w *Worktree) copyFileToStorage(attrs *attributes, path string) (hash plumbing.Hash, err error) {
 	fi, err := w.filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -554,7 +622,7 @@ func (w *Worktree) copyFileToStorage(cfg *config.Config, path string) (hash plum
 	if fi.Mode()&os.ModeSymlink != 0 {
 		err = w.fillEncodedObjectFromSymlink(writer, path, fi)
 	} else {
-		err = w.fillEncodedObjectFromFile(cfg, writer, path, fi)
+		err = w.fillEncodedObjectFromFile(attrs, writer, path, fi)
 	}
 
 	if err != nil {
@@ -564,16 +632,26 @@ func (w *Worktree) copyFileToStorage(cfg *config.Config, path string) (hash plum
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
-func (w *Worktree) fillEncodedObjectFromFile(cfg *config.Config, dst io.Writer, path string, _ os.FileInfo) (err error) {
+func (w *Worktree) fillEncodedObjectFromFile(attrs *attributes, dst io.Writer, path string, _ os.FileInfo) (err error) {
 	file, err := w.filesystem.Open(path)
 	if err != nil {
 		return err
 	}
 	defer ioutil.CheckClose(file, &err)
 
-	switch cfg.Core.AutoCRLF {
-	case "true", "input":
-		br := sync.GetBufioReader(file)
+	// As git, the clean filter is run first, and the line ending
+	// conversion applies to its output.
+	var src io.ReadSeeker = file
+	content, ok, err := attrs.clean(path, file)
+	if err != nil {
+		return err
+	}
+	if ok {
+		src = bytes.NewReader(content)
+	}
+
+	if conv := attrs.eolConversion(path); conv != convert.EOLNone {
+		br := sync.GetBufioReader(src)
 		defer sync.PutBufioReader(br)
 
 		stat, err := convert.GetStat(br)
@@ -581,16 +659,16 @@ func (w *Worktree) fillEncodedObjectFromFile(cfg *config.Config, dst io.Writer,
 			return err
 		}
 
-		if _, err = file.Seek(0, io.SeekStart); err != nil {
+		if _, err = src.Seek(0, io.SeekStart); err != nil {
 			return err
 		}
 
-		if !stat.IsBinary() {
+		if conv.ToGit(stat) {
 			dst = convert.NewLFWriter(dst)
 		}
 	}
 
-	_, err = ioutil.CopyBufferPool(dst, file)
+	_, err = ioutil.CopyBufferPool(dst, src)
 	return err
 }
 
@@ -614,6 +692,16 @@ func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h p
 		return w.doAddFileToIndex(idx, filename, h)
 	}
 
+	if e.Stage != 0 {
+		// As git, adding a conflicted path resolves its conflict.
+		resolved := &index.Entry{Name: e.Name}
+		if err := w.doUpdateFileToIndex(resolved, filename, h); err != nil {
+			return err
+		}
+
+		return idx.ResolveConflict(e.Name, resolved)
+	}
+
 	return w.doUpdateFileToIndex(e, filename, h)
 }
 
@@ -633,6 +721,7 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 
 	e.Hash = h
 	e.ModifiedAt = info.ModTime()
+	e.FSMonitorValid = false
 	e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
 	if err != nil {
 		return err
@@ -656,6 +745,10 @@ func (w *Worktree) Remove(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	if err := w.expandSparseDirsFor(idx, filepath.ToSlash(path)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 
 	fi, err := w.filesystem.Lstat(path)
@@ -726,6 +819,10 @@ func (w *Worktree) doRemoveFile(idx *index.Index, path string) (plumbing.Hash, e
 }
 
 func (w *Worktree) deleteFromIndex(idx *index.Index, path string) (plumbing.Hash, error) {
+	if e, err := idx.Entry(path); err == nil && e.Stage != 0 {
+		return e.Hash, idx.ResolveConflict(path, nil)
+	}
+
 	e, err := idx.Remove(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -795,6 +892,10 @@ func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	if err := w.expandSparseDirsFor(idx, filepath.ToSlash(from), filepath.ToSlash(to)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	hash, err := w.deleteFromIndex(idx, from)
 	if err != nil {
 		return plumbing.ZeroHash, err
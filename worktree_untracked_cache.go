@@ -0,0 +1,108 @@
+package git
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v6/util"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+const infoExcludePath = ".git/info/exclude"
+
+// untrackedCache returns the untracked cache of idx to use, once added,
+// reset or removed as core.untrackedCache asks, or nil if none is used. It
+// also reports whether idx changed, which it does when the cache is
+// removed.
+//
+// The cache only records the patterns of the ignore files, so it is not
+// used along with the Excludes of the worktree.
+func (w *Worktree) untrackedCache(cfg *config.Config, idx *index.Index) (uc *index.UntrackedCache, changed bool) {
+	add := false
+	switch strings.ToLower(cfg.Core.UntrackedCache) {
+	case "true", "yes", "on", "1":
+		add = true
+	case "false", "no", "off", "0":
+		changed = idx.UntrackedCache != nil
+		idx.UntrackedCache = nil
+		return nil, changed
+	}
+
+	if len(w.Excludes) > 0 {
+		return nil, false
+	}
+
+	ident := untrackedCacheIdent(w.filesystem.Root())
+	uc = idx.UntrackedCache
+	if uc == nil || !slices.Contains(uc.Environments, ident) || uc.Flags != 0 ||
+		uc.ExcludePerDir != gitignore.IgnoreFile || !uc.ExcludesFileHash.IsZero() {
+		// A cache populated elsewhere, or by git with other flags, is
+		// only replaced if the cache is asked for.
+		if !add {
+			return nil, false
+		}
+
+		uc = &index.UntrackedCache{
+			Environments:  []string{ident},
+			ExcludePerDir: gitignore.IgnoreFile,
+		}
+		idx.UntrackedCache = uc
+	}
+
+	// The info/exclude patterns apply to the whole worktree.
+	if h := w.infoExcludeHash(); !h.Equal(uc.InfoExcludeHash) && !(h.IsZero() && uc.InfoExcludeHash.IsZero()) {
+		uc.InfoExcludeHash = h
+		uc.InfoExcludeStats = index.StatData{}
+		uc.Root = nil
+	}
+
+	return uc, false
+}
+
+// infoExcludeHash returns the hash of $GIT_DIR/info/exclude, or the zero
+// hash if it cannot be read.
+func (w *Worktree) infoExcludeHash() plumbing.Hash {
+	content, err := util.ReadFile(w.filesystem, infoExcludePath)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, int64(len(content)))
+	if _, err := h.Write(content); err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return h.Sum()
+}
+
+// untrackedCacheIdent returns the environment of the untracked cache of the
+// worktree whose top directory is root, as git describes it.
+func untrackedCacheIdent(root string) string {
+	return "Location " + root + ", system " + systemName()
+}
+
+// systemName returns the name of the operating system, as uname reports it.
+func systemName() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "Linux"
+	case "darwin":
+		return "Darwin"
+	case "windows":
+		return "Windows"
+	case "freebsd":
+		return "FreeBSD"
+	case "netbsd":
+		return "NetBSD"
+	case "openbsd":
+		return "OpenBSD"
+	default:
+		return runtime.GOOS
+	}
+}
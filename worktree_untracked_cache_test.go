@@ -0,0 +1,132 @@
+package git
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+// readIndexFile decodes the index file of the repository at dir, as git
+// would read it.
+func readIndexFile(t *testing.T, dir string) *index.Index {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(dir, ".git", "index"))
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	idx := &index.Index{}
+	require.NoError(t, index.NewDecoder(f, crypto.SHA1.New()).Decode(idx))
+	return idx
+}
+
+func setCoreConfig(t *testing.T, r *Repository, set func(cfg *config.Config)) {
+	t.Helper()
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	set(cfg)
+	require.NoError(t, r.SetConfig(cfg))
+}
+
+func TestStatusUntrackedCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	fs := w.Filesystem()
+	require.NoError(t, util.WriteFile(fs, "tracked.txt", []byte("tracked\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "src/main.go", []byte("package main\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, ".gitignore", []byte("*.log\n"), 0o644))
+	_, err = w.Add(".")
+	require.NoError(t, err)
+
+	setCoreConfig(t, r, func(cfg *config.Config) { cfg.Core.UntrackedCache = "true" })
+
+	require.NoError(t, util.WriteFile(fs, "untracked.txt", []byte("untracked\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "src/new.go", []byte("package main\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "debug.log", []byte("log\n"), 0o644))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Untracked, status.File("untracked.txt").Worktree)
+	assert.Equal(t, Untracked, status.File("src/new.go").Worktree)
+	assert.False(t, status.IsUntracked("debug.log"))
+
+	uc := readIndexFile(t, dir).UntrackedCache
+	require.NotNil(t, uc)
+	require.NotNil(t, uc.Root)
+	assert.Equal(t, []string{untrackedCacheIdent(fs.Root())}, uc.Environments)
+	assert.Equal(t, ".gitignore", uc.ExcludePerDir)
+	assert.Equal(t, []string{"untracked.txt"}, uc.Root.Untracked)
+	require.NotNil(t, uc.Root.Dir("src"))
+	assert.Equal(t, []string{"new.go"}, uc.Root.Dir("src").Untracked)
+
+	// Files created, removed and added since are still seen.
+	require.NoError(t, util.WriteFile(fs, "other.txt", []byte("other\n"), 0o644))
+	require.NoError(t, fs.Remove("untracked.txt"))
+	_, err = w.Add("src/new.go")
+	require.NoError(t, err)
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Untracked, status.File("other.txt").Worktree)
+	assert.False(t, status.IsUntracked("untracked.txt"))
+	assert.Equal(t, Added, status.File("src/new.go").Staging)
+	assert.Equal(t, Unmodified, status.File("src/new.go").Worktree)
+
+	setCoreConfig(t, r, func(cfg *config.Config) { cfg.Core.UntrackedCache = "false" })
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Untracked, status.File("other.txt").Worktree)
+	assert.Nil(t, readIndexFile(t, dir).UntrackedCache)
+}
+
+// TestStatusUntrackedCacheKeep verifies that, with core.untrackedCache
+// unset, a cache is neither added nor dropped.
+func TestStatusUntrackedCacheKeep(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	fs := w.Filesystem()
+	require.NoError(t, util.WriteFile(fs, "tracked.txt", []byte("tracked\n"), 0o644))
+	_, err = w.Add("tracked.txt")
+	require.NoError(t, err)
+
+	_, err = w.Status()
+	require.NoError(t, err)
+	assert.Nil(t, readIndexFile(t, dir).UntrackedCache)
+
+	setCoreConfig(t, r, func(cfg *config.Config) { cfg.Core.UntrackedCache = "true" })
+	_, err = w.Status()
+	require.NoError(t, err)
+
+	setCoreConfig(t, r, func(cfg *config.Config) { cfg.Core.UntrackedCache = "" })
+	require.NoError(t, util.WriteFile(fs, "untracked.txt", []byte("untracked\n"), 0o644))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Untracked, status.File("untracked.txt").Worktree)
+
+	uc := readIndexFile(t, dir).UntrackedCache
+	require.NotNil(t, uc)
+	assert.Equal(t, []string{"untracked.txt"}, uc.Root.Untracked)
+}
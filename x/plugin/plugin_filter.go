@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"context"
+	"io"
+)
+
+const contentFilterPlugin Name = "content-filter"
+
+var contentFilter = newKey[Filter](contentFilterPlugin)
+
+// Filter is an in-process filter driver. It converts the content of the
+// files whose filter attribute names one of the drivers it supports, when
+// they are added to the repository and checked out in the worktree.
+type Filter interface {
+	// Supports reports whether the filter implements the driver named
+	// driver.
+	Supports(driver string) bool
+	// Clean writes to dst the content to store in the repository of the
+	// file at path, whose worktree content is read from src.
+	Clean(ctx context.Context, driver, path string, dst io.Writer, src io.Reader) error
+	// Smudge writes to dst the content to check out in the worktree of the
+	// file at path, whose repository content is read from src.
+	Smudge(ctx context.Context, driver, path string, dst io.Writer, src io.Reader) error
+}
+
+// ContentFilter returns the key used to register a filter plugin.
+// When set, the drivers it supports take precedence over the filter.<driver>
+// commands of the configuration.
+func ContentFilter() key[Filter] { //nolint:revive // intentional unexported return type
+	return contentFilter
+}
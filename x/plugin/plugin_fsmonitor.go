@@ -0,0 +1,26 @@
+package plugin
+
+import "context"
+
+const fsMonitorPlugin Name = "fsmonitor"
+
+var fsMonitor = newKey[FSMonitor](fsMonitorPlugin)
+
+// FSMonitor is an in-process file system monitor, such as a watcher of the
+// worktree. It tells which files may have changed since a previous query,
+// so the others are not checked for changes.
+type FSMonitor interface {
+	// Changes returns the paths that may have changed in the worktree whose
+	// top directory is root since the query that returned token, and the
+	// token of this query. The paths are relative to root, and those of
+	// directories end with a slash and stand for everything below them. If
+	// the monitor cannot tell, as when token is empty or unknown to it, it
+	// reports that everything may have changed with all.
+	Changes(ctx context.Context, root, token string) (next string, paths []string, all bool, err error)
+}
+
+// FileSystemMonitor returns the key used to register a file system monitor
+// plugin. When set, it takes precedence over the core.fsmonitor hook.
+func FileSystemMonitor() key[FSMonitor] { //nolint:revive // intentional unexported return type
+	return fsMonitor
+}
@@ -18,7 +18,8 @@ type Signer interface {
 
 // ObjectSigner returns the key used to register an object-signing plugin.
 // When set, this plugin will set the default signer for new commits and
-// tags.
+// tags, taking precedence over the built-in signers selected by gpg.format
+// and user.signingKey.
 func ObjectSigner() key[Signer] { //nolint:revive // intentional unexported return type
 	return objectSigner
 }